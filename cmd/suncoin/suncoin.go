@@ -1,10 +1,11 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"io"
-	"log"
+	"io/ioutil"
 	"net/http"
 	_ "net/http/pprof"
 	"os"
@@ -12,21 +13,31 @@ import (
 	"path/filepath"
 	"runtime/debug"
 	"runtime/pprof"
+	"strconv"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/skycoin/skycoin/src/api/webrpc"
+	"github.com/skycoin/skycoin/src/backup"
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/coin"
 	"github.com/skycoin/skycoin/src/daemon"
 	"github.com/skycoin/skycoin/src/gui"
 	"github.com/skycoin/skycoin/src/util/browser"
 	"github.com/skycoin/skycoin/src/util/cert"
+	"github.com/skycoin/skycoin/src/util/config"
 	"github.com/skycoin/skycoin/src/util/file"
 	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/skycoin/skycoin/src/visor"
 	"github.com/skycoin/skycoin/src/wallet"
 )
 
+// configEnvPrefix is the prefix of the environment variables of the flags, e.g.
+// SUNCOIN_WEB_INTERFACE_PORT
+const configEnvPrefix = "SUNCOIN_"
+
 var (
 	// Version node version which will be set when build wallet by LDFLAGS
 	Version    = "0.0.0"
@@ -50,6 +61,8 @@ var (
 	GenesisAddressStr   = "5L1jvbwtGS8eL3afA2gsqTBc8KEPFDDRjZ"
 	BlockchainPubkeyStr = "0255434580f86e14a26e1d5c59b0626dfa28003741c475155aeedaa92af797d043"
 	BlockchainSeckeyStr = ""
+	// Known-good block hashes, in the format of "seq:hash,seq:hash"
+	CheckpointsStr = ""
 
 	GenesisTimestamp  uint64 = 1494861716
 	GenesisCoinVolume uint64 = 300e12
@@ -74,6 +87,8 @@ type Config struct {
 	DisableIncomingConnections bool
 	// Disables networking altogether
 	DisableNetworking bool
+	// Serve the apis from the existing db without writing it, networking is disabled
+	ReadOnly bool
 	// Only run on localhost and only connect to others on localhost
 	LocalhostOnly bool
 	// Which address to serve on. Leave blank to automatically assign to a
@@ -83,6 +98,36 @@ type Config struct {
 	Port int
 	//max connections to maintain
 	MaxConnections int
+	// Number of incoming connections allowed, the connections are evicted for the new ones
+	MaxIncomingConnections int
+	// How many connections are allowed from the same base IP
+	MaxConnectionsPerIP int
+	// How many connections are allowed from the same /16 subnet
+	MaxConnectionsPerSubnet int
+	// Messages of at least this many bytes are compressed for the peers supporting it
+	CompressionMinSize int
+	// SOCKS5 proxy of the outgoing connections, e.g. Tor
+	Proxy string
+	// Make no direct connection, only through the proxy
+	ProxyOnly bool
+	// Onion address of the Tor hidden service of the node
+	OnionAddress string
+	// Port of the encrypted transport, 0 doesn't listen
+	EncryptedPort int
+	// Comma separated [key@]host:port of the peers connected through the encrypted transport
+	EncryptedPeers string
+	// Comma separated hex keys of the peers allowed on the encrypted port, any key if empty
+	AuthorizedKeys string
+	// Comma separated host:port of the peers of the operator, always connected and never evicted
+	TrustedPeers string
+	// Map the port on the router by UPnP or NAT-PMP
+	PortMapping bool
+	// Lease of the port mapping
+	PortMappingLifetime time.Duration
+	// Comma separated host names resolved to the peer addresses, queried in order
+	DNSSeeds string
+	// How long the addresses resolved from the dns seeds are cached
+	DNSSeedCacheTTL time.Duration
 	// How often to make outgoing connections
 	OutgoingConnectionsRate time.Duration
 	// Wallet Address Version
@@ -94,17 +139,82 @@ type Config struct {
 	WebInterfaceCert  string
 	WebInterfaceKey   string
 	WebInterfaceHTTPS bool
+	// Creates the self signed cert and key if neither exists
+	WebInterfaceAutoCert bool
+	// The max-age of the HSTS header in seconds, it's not sent if 0
+	WebInterfaceHSTSMaxAge int
+	// Comma separated cipher suites of TLS 1.2, the forward secret AEAD suites if empty
+	WebInterfaceTLSCiphers string
+	// The min version of TLS, 1.0, 1.1, 1.2 or 1.3
+	WebInterfaceTLSMinVersion string
+	// Compresses the responses of the requests accepting gzip
+	WebInterfaceGzip bool
+
+	// The api key of the X-API-Key header, the wallet routes and the writes need it if set
+	APIKey string
+	// Enables the X-CSRF-Token header of the writes, the bundled web GUI doesn't send it yet
+	CSRF bool
+	// The reads of the routes not protected don't need the api key
+	APIPublicReads bool
+	// Comma separated prefixes of the routes protected for all the methods, and of the routes
+	// always public
+	APIProtectedRoutes string
+	APIPublicRoutes    string
+
+	// Comma separated origins allowed by the cors policy, * allows all the origins
+	CORSAllowedOrigins string
+	// Comma separated methods and headers allowed by the cors policy
+	CORSAllowedMethods string
+	CORSAllowedHeaders string
+
+	// Limits the api requests of the clients, by the ips or the api keys
+	RateLimit bool
+	// The limits of the request classes, <requests per second>:<burst>, not limited if empty
+	RateLimitRead  string
+	RateLimitWrite string
+	RateLimitHeavy string
+	// Comma separated prefixes of the heavy routes
+	RateLimitHeavyRoutes string
+	// The ip of the client is the first of the X-Forwarded-For header
+	RateLimitTrustForwardedFor bool
+
+	// How long the results of the injections and the spends with the Idempotency-Key are kept
+	IdempotencyWindow time.Duration
+
+	// Enables the GraphQL queries of the blocks, the transactions, the outputs and the addresses
+	GraphQL bool
+
+	// Serves the Prometheus metrics of the node on /metrics
+	Metrics bool
+
+	// Serves the pprof profiles, the execution trace and the goroutine dump on /debug/ of the web
+	// interface, they need the api key if it's set
+	WebInterfacePprof bool
 
 	RPCInterface     bool
 	RPCInterfacePort int
 	RPCInterfaceAddr string
 
+	// The gRPC interface is served by the node built with the grpc tag
+	GRPCInterface     bool
+	GRPCInterfacePort int
+	GRPCInterfaceAddr string
+
 	// Launch System Default Browser after client startup
 	LaunchBrowser bool
 
 	// If true, print the configured client web interface address and exit
 	PrintWebInterfaceAddress bool
 
+	// The process exits if the shutdown takes longer
+	ShutdownTimeout time.Duration
+
+	// TOML config file of the flags, the environment variables SUNCOIN_<FLAG> override it and
+	// the command line overrides both
+	ConfigFile string
+	// Print the effective config as TOML and exit
+	PrintConfig bool
+
 	// Data directory holds app data -- defaults to ~/.suncoin
 	DataDirectory string
 	// GUI directory contains assets for the html gui
@@ -113,6 +223,14 @@ type Config struct {
 	ColorLog bool
 	// This is the value registered with flag, it is converted to LogLevel after parsing
 	LogLevel string
+	// Comma separated levels of the modules overriding LogLevel, e.g. daemon:debug,gui:info
+	LogModuleLevels string
+	// Write the logs as json lines
+	LogJSON bool
+	// Rotate the log file when it exceeds the megabytes, never rotated if 0
+	LogFileMaxSize int
+	// Number of the rotated log files kept, all are kept if 0
+	LogFileMaxBackups int
 
 	// Wallets
 	// Defaults to ${DataDirectory}/wallets/
@@ -127,6 +245,33 @@ type Config struct {
 	BlockchainPubkey cipher.PubKey
 	BlockchainSeckey cipher.SecKey
 
+	// Known-good block hashes, the peers offering conflicting blocks are rejected
+	Checkpoints []visor.Checkpoint
+
+	// How long an unconfirmed txn is held since it was last received, 0 never drops it
+	UnconfirmedMaxAge time.Duration
+
+	// Number of the recent blocks whose bodies are kept, 0 keeps all the blocks
+	PruneDepth uint64
+	// How often the db is compacted when the node starts, 0 disables compaction
+	DBCompactInterval time.Duration
+
+	// Backups of the wallets and the db
+	// Defaults to ${DataDirectory}/backups/
+	BackupDirectory string
+	// How often a backup is created, 0 only creates backups on request
+	BackupInterval time.Duration
+	// Number of the most recent backups kept
+	BackupKeep int
+
+	// Comma separated addresses watched since the node starts
+	WatchAddresses string
+
+	// Command run for each wallet event, e.g. an incoming payment
+	WalletNotify string
+	// Number of confirmations the wallet payments are confirmed at
+	WalletNotifyConfirmations uint64
+
 	/* Developer options */
 
 	// Enable cpu profiling
@@ -143,90 +288,247 @@ type Config struct {
 	Arbitrating  bool
 	RPCThreadNum uint // rpc number
 	Logtofile    bool
+	// Verify the blockchain db from genesis, print the report and exit
+	VerifyDB bool
+	// Write the blocks to the chain file and exit
+	ExportChain string
+	// Seq of the last block written to the chain file, 0 writes till the head
+	ExportChainSeq uint64
+	// Import the blocks of the chain file when the node starts
+	ImportChain string
+	// Print the db migrations that would run when the node starts and exit
+	MigrateDryRun bool
+	// Rebuild the address, transaction and output indexes from the blocks and exit
+	RebuildIndexes bool
 }
 
-func (c *Config) register() {
-	flag.BoolVar(&c.DisablePEX, "disable-pex", c.DisablePEX,
+// register registers the flags of the config on fs, the defaults are the values of c
+func (c *Config) register(fs *flag.FlagSet) {
+	fs.BoolVar(&c.DisablePEX, "disable-pex", c.DisablePEX,
 		"disable PEX peer discovery")
-	flag.BoolVar(&c.DisableOutgoingConnections, "disable-outgoing",
+	fs.BoolVar(&c.DisableOutgoingConnections, "disable-outgoing",
 		c.DisableOutgoingConnections, "Don't make outgoing connections")
-	flag.BoolVar(&c.DisableIncomingConnections, "disable-incoming",
+	fs.BoolVar(&c.DisableIncomingConnections, "disable-incoming",
 		c.DisableIncomingConnections, "Don't make incoming connections")
-	flag.BoolVar(&c.DisableNetworking, "disable-networking",
+	fs.BoolVar(&c.DisableNetworking, "disable-networking",
 		c.DisableNetworking, "Disable all network activity")
-	flag.StringVar(&c.Address, "address", c.Address,
+	fs.BoolVar(&c.ReadOnly, "read-only", c.ReadOnly,
+		"Serve the apis from the existing db without writing it, the txns and blocks are rejected")
+	fs.StringVar(&c.Address, "address", c.Address,
 		"IP Address to run application on. Leave empty to default to a public interface")
-	flag.IntVar(&c.Port, "port", c.Port, "Port to run application on")
-	flag.BoolVar(&c.WebInterface, "web-interface", c.WebInterface,
+	fs.IntVar(&c.Port, "port", c.Port, "Port to run application on")
+	fs.IntVar(&c.MaxConnections, "max-outgoing-connections", c.MaxConnections,
+		"Number of outgoing connections to maintain, reloaded by SIGHUP")
+	fs.IntVar(&c.MaxIncomingConnections, "max-incoming-connections", c.MaxIncomingConnections,
+		"Number of incoming connections allowed, the peers are evicted for the new ones when reached, reloaded by SIGHUP")
+	fs.IntVar(&c.MaxConnectionsPerIP, "max-connections-per-ip", c.MaxConnectionsPerIP,
+		"Number of connections allowed from the same base IP, reloaded by SIGHUP")
+	fs.IntVar(&c.MaxConnectionsPerSubnet, "max-connections-per-subnet", c.MaxConnectionsPerSubnet,
+		"Number of connections allowed from the same /16 subnet, reloaded by SIGHUP")
+	fs.IntVar(&c.CompressionMinSize, "compression-min-size", c.CompressionMinSize,
+		"Compress the messages of at least this many bytes for the peers supporting it, 0 disables the compression")
+	fs.StringVar(&c.Proxy, "proxy", c.Proxy,
+		"SOCKS5 proxy host:port the outgoing connections go through, e.g. Tor's 127.0.0.1:9050")
+	fs.BoolVar(&c.ProxyOnly, "proxy-only", c.ProxyOnly,
+		"Make no direct connection, the outgoing connections go through -proxy and the node listens on localhost only")
+	fs.StringVar(&c.OnionAddress, "onion-address", c.OnionAddress,
+		"Onion address host.onion:port of the Tor hidden service forwarding to the node")
+	fs.IntVar(&c.EncryptedPort, "encrypted-port", c.EncryptedPort,
+		"Port the encrypted connections are accepted on, 0 doesn't listen")
+	fs.StringVar(&c.EncryptedPeers, "encrypted-peers", c.EncryptedPeers,
+		"Comma separated [key@]host:port of the peers always connected through the encrypted transport, the peer must have the hex key if set")
+	fs.StringVar(&c.AuthorizedKeys, "authorized-keys", c.AuthorizedKeys,
+		"Comma separated hex keys of the peers allowed to connect to -encrypted-port, any key if empty")
+	fs.StringVar(&c.TrustedPeers, "trusted-peers", c.TrustedPeers,
+		"Comma separated host:port of the peers always connected, exempt from the connection limits, the eviction and the removal from the peer list, and preferred for downloading the blocks")
+	fs.BoolVar(&c.PortMapping, "port-mapping", c.PortMapping,
+		"Map the port on the router by UPnP or NAT-PMP to accept incoming connections")
+	fs.DurationVar(&c.PortMappingLifetime, "port-mapping-lifetime", c.PortMappingLifetime,
+		"Lease of the port mapping, it's renewed at half of the lease")
+	fs.StringVar(&c.DNSSeeds, "dns-seeds", c.DNSSeeds,
+		"Comma separated dns seeds, host or host:port, whose addresses are added to the peers, queried in order")
+	fs.DurationVar(&c.DNSSeedCacheTTL, "dns-seed-cache-ttl", c.DNSSeedCacheTTL,
+		"How long the addresses of the dns seeds are cached instead of querying the seeds")
+	fs.BoolVar(&c.WebInterface, "web-interface", c.WebInterface,
 		"enable the web interface")
-	flag.IntVar(&c.WebInterfacePort, "web-interface-port",
+	fs.IntVar(&c.WebInterfacePort, "web-interface-port",
 		c.WebInterfacePort, "port to serve web interface on")
-	flag.StringVar(&c.WebInterfaceAddr, "web-interface-addr",
+	fs.StringVar(&c.WebInterfaceAddr, "web-interface-addr",
 		c.WebInterfaceAddr, "addr to serve web interface on")
-	flag.StringVar(&c.WebInterfaceCert, "web-interface-cert",
+	fs.StringVar(&c.WebInterfaceCert, "web-interface-cert",
 		c.WebInterfaceCert, "cert.pem file for web interface HTTPS. "+
 			"If not provided, will use cert.pem in -data-directory")
-	flag.StringVar(&c.WebInterfaceKey, "web-interface-key",
+	fs.StringVar(&c.WebInterfaceKey, "web-interface-key",
 		c.WebInterfaceKey, "key.pem file for web interface HTTPS. "+
 			"If not provided, will use key.pem in -data-directory")
-	flag.BoolVar(&c.WebInterfaceHTTPS, "web-interface-https",
+	fs.BoolVar(&c.WebInterfaceHTTPS, "web-interface-https",
 		c.WebInterfaceHTTPS, "enable HTTPS for web interface")
-
-	flag.BoolVar(&c.RPCInterface, "rpc-interface", c.RPCInterface,
+	fs.BoolVar(&c.WebInterfaceAutoCert, "web-interface-auto-cert",
+		c.WebInterfaceAutoCert, "create the self signed cert.pem and key.pem for web interface HTTPS "+
+			"if neither exists")
+	fs.IntVar(&c.WebInterfaceHSTSMaxAge, "web-interface-hsts-max-age",
+		c.WebInterfaceHSTSMaxAge, "max-age in seconds of the Strict-Transport-Security header of "+
+			"web interface HTTPS, the header is not sent if 0")
+	fs.StringVar(&c.WebInterfaceTLSCiphers, "web-interface-tls-ciphers",
+		c.WebInterfaceTLSCiphers, "comma separated TLS 1.2 cipher suites of web interface HTTPS, "+
+			"e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. If not provided, the forward secret AEAD suites are used")
+	fs.StringVar(&c.WebInterfaceTLSMinVersion, "web-interface-tls-min-version",
+		c.WebInterfaceTLSMinVersion, "min TLS version of web interface HTTPS, 1.0, 1.1, 1.2 or 1.3")
+	fs.BoolVar(&c.WebInterfaceGzip, "web-interface-gzip",
+		c.WebInterfaceGzip, "compress the responses of web interface by gzip if the client accepts it")
+
+	fs.StringVar(&c.APIKey, "api-key", c.APIKey,
+		"api key of the X-API-Key header, the wallet routes and the writes need it if set")
+	fs.BoolVar(&c.CSRF, "csrf", c.CSRF,
+		"the writes need the X-CSRF-Token header of /api/v1/csrf if the api key is not set")
+	fs.BoolVar(&c.APIPublicReads, "api-public-reads", c.APIPublicReads,
+		"the reads of the routes not protected don't need the api key")
+	fs.StringVar(&c.APIProtectedRoutes, "api-protected-routes", c.APIProtectedRoutes,
+		"comma separated prefixes of the routes protected for all the methods")
+	fs.StringVar(&c.APIPublicRoutes, "api-public-routes", c.APIPublicRoutes,
+		"comma separated prefixes of the routes never authenticated")
+
+	fs.StringVar(&c.CORSAllowedOrigins, "cors-allowed-origins", c.CORSAllowedOrigins,
+		"comma separated origins allowed to use the api from the browsers, * allows all the origins unless -api-key or -csrf is set")
+	fs.StringVar(&c.CORSAllowedMethods, "cors-allowed-methods", c.CORSAllowedMethods,
+		"comma separated methods allowed for the cors origins")
+	fs.StringVar(&c.CORSAllowedHeaders, "cors-allowed-headers", c.CORSAllowedHeaders,
+		"comma separated headers allowed for the cors origins")
+
+	fs.BoolVar(&c.RateLimit, "rate-limit", c.RateLimit,
+		"limit the api requests of the clients, by the ips or the api keys")
+	fs.StringVar(&c.RateLimitRead, "rate-limit-read", c.RateLimitRead,
+		"<requests per second>:<burst> of the GET requests of a client, not limited if empty, reloaded by SIGHUP")
+	fs.StringVar(&c.RateLimitWrite, "rate-limit-write", c.RateLimitWrite,
+		"<requests per second>:<burst> of the other requests of a client, not limited if empty, reloaded by SIGHUP")
+	fs.StringVar(&c.RateLimitHeavy, "rate-limit-heavy", c.RateLimitHeavy,
+		"<requests per second>:<burst> of the requests of the heavy routes of a client, not limited if empty, reloaded by SIGHUP")
+	fs.StringVar(&c.RateLimitHeavyRoutes, "rate-limit-heavy-routes", c.RateLimitHeavyRoutes,
+		"comma separated prefixes of the heavy routes")
+	fs.BoolVar(&c.RateLimitTrustForwardedFor, "rate-limit-trust-forwarded-for", c.RateLimitTrustForwardedFor,
+		"the ip of the client is the first of the X-Forwarded-For header, for the node behind a reverse proxy")
+
+	fs.DurationVar(&c.IdempotencyWindow, "idempotency-window", c.IdempotencyWindow,
+		"how long the results of the injections and the spends with the Idempotency-Key header are kept")
+
+	fs.BoolVar(&c.GraphQL, "graphql", c.GraphQL,
+		"enable the GraphQL queries of the explorer data on /api/v1/graphql")
+
+	fs.BoolVar(&c.Metrics, "metrics", c.Metrics,
+		"serve the Prometheus metrics of the node on /metrics of the web interface")
+
+	fs.BoolVar(&c.WebInterfacePprof, "web-interface-pprof", c.WebInterfacePprof,
+		"serve the pprof profiles, the execution trace and the goroutine dump on /debug/ of the web interface")
+
+	fs.BoolVar(&c.RPCInterface, "rpc-interface", c.RPCInterface,
 		"enable the rpc interface")
-	flag.IntVar(&c.RPCInterfacePort, "rpc-interface-port", c.RPCInterfacePort,
+	fs.IntVar(&c.RPCInterfacePort, "rpc-interface-port", c.RPCInterfacePort,
 		"port to serve rpc interface on")
-	flag.StringVar(&c.RPCInterfaceAddr, "rpc-interface-addr", c.RPCInterfaceAddr,
+	fs.StringVar(&c.RPCInterfaceAddr, "rpc-interface-addr", c.RPCInterfaceAddr,
 		"addr to serve rpc interface on")
-	flag.UintVar(&c.RPCThreadNum, "rpc-thread-num", 5, "rpc thread number")
+	fs.UintVar(&c.RPCThreadNum, "rpc-thread-num", 5, "rpc thread number")
+
+	fs.BoolVar(&c.GRPCInterface, "grpc-interface", c.GRPCInterface,
+		"enable the grpc interface, the node must be built with the grpc tag")
+	fs.IntVar(&c.GRPCInterfacePort, "grpc-interface-port", c.GRPCInterfacePort,
+		"port to serve grpc interface on")
+	fs.StringVar(&c.GRPCInterfaceAddr, "grpc-interface-addr", c.GRPCInterfaceAddr,
+		"addr to serve grpc interface on")
 
-	flag.BoolVar(&c.LaunchBrowser, "launch-browser", c.LaunchBrowser,
+	fs.BoolVar(&c.LaunchBrowser, "launch-browser", c.LaunchBrowser,
 		"launch system default webbrowser at client startup")
-	flag.BoolVar(&c.PrintWebInterfaceAddress, "print-web-interface-address",
+	fs.BoolVar(&c.PrintWebInterfaceAddress, "print-web-interface-address",
 		c.PrintWebInterfaceAddress, "print configured web interface address and exit")
-	flag.StringVar(&c.DataDirectory, "data-dir", c.DataDirectory,
+	fs.DurationVar(&c.ShutdownTimeout, "shutdown-timeout", c.ShutdownTimeout,
+		"exit if the shutdown takes longer, the state not flushed yet is lost")
+	fs.StringVar(&c.ConfigFile, "config", c.ConfigFile,
+		"TOML config file of the flags, overridden by the environment variables "+configEnvPrefix+"<FLAG> and the command line")
+	fs.BoolVar(&c.PrintConfig, "print-config", c.PrintConfig,
+		"print the effective config as TOML and exit")
+	fs.StringVar(&c.DataDirectory, "data-dir", c.DataDirectory,
 		"directory to store app data (defaults to ~/.suncoin)")
-	flag.StringVar(&c.ConnectTo, "connect-to", c.ConnectTo,
+	fs.StringVar(&c.ConnectTo, "connect-to", c.ConnectTo,
 		"connect to this ip only")
-	flag.BoolVar(&c.ProfileCPU, "profile-cpu", c.ProfileCPU,
+	fs.BoolVar(&c.ProfileCPU, "profile-cpu", c.ProfileCPU,
 		"enable cpu profiling")
-	flag.StringVar(&c.ProfileCPUFile, "profile-cpu-file",
+	fs.StringVar(&c.ProfileCPUFile, "profile-cpu-file",
 		c.ProfileCPUFile, "where to write the cpu profile file")
-	flag.BoolVar(&c.HTTPProf, "http-prof", c.HTTPProf,
+	fs.BoolVar(&c.HTTPProf, "http-prof", c.HTTPProf,
 		"Run the http profiling interface")
-	flag.BoolVar(&c.ColorLog, "color-log", c.ColorLog,
+	fs.BoolVar(&c.ColorLog, "color-log", c.ColorLog,
 		"Add terminal colors to log output")
-	flag.BoolVar(&c.Logtofile, "logtofile", false, "log to file")
-
-	flag.StringVar(&c.GUIDirectory, "gui-dir", c.GUIDirectory,
+	fs.BoolVar(&c.Logtofile, "logtofile", false, "log to file")
+	fs.StringVar(&c.LogLevel, "log-level", c.LogLevel,
+		"log level: critical, error, warning, notice, info or debug, reloaded by SIGHUP")
+	fs.StringVar(&c.LogModuleLevels, "log-module-levels", c.LogModuleLevels,
+		"comma separated levels of the modules overriding -log-level, e.g. daemon:debug,gui:info, reloaded by SIGHUP")
+	fs.BoolVar(&c.LogJSON, "log-json", c.LogJSON, "write the logs as json lines")
+	fs.IntVar(&c.LogFileMaxSize, "log-file-max-size", c.LogFileMaxSize,
+		"rotate the log file when it exceeds the megabytes, never rotated if 0")
+	fs.IntVar(&c.LogFileMaxBackups, "log-file-max-backups", c.LogFileMaxBackups,
+		"number of the rotated log files kept, all are kept if 0")
+
+	fs.StringVar(&c.GUIDirectory, "gui-dir", c.GUIDirectory,
 		"static content directory for the html gui")
 
 	//Key Configuration Data
-	flag.BoolVar(&c.RunMaster, "master", c.RunMaster,
+	fs.BoolVar(&c.RunMaster, "master", c.RunMaster,
 		"run the daemon as blockchain master server")
 
-	flag.StringVar(&BlockchainPubkeyStr, "master-public-key", BlockchainPubkeyStr,
+	fs.StringVar(&BlockchainPubkeyStr, "master-public-key", BlockchainPubkeyStr,
 		"public key of the master chain")
-	flag.StringVar(&BlockchainSeckeyStr, "master-secret-key", BlockchainSeckeyStr,
+	fs.StringVar(&BlockchainSeckeyStr, "master-secret-key", BlockchainSeckeyStr,
 		"secret key, set for master")
 
-	flag.StringVar(&GenesisAddressStr, "genesis-address", GenesisAddressStr,
+	fs.StringVar(&GenesisAddressStr, "genesis-address", GenesisAddressStr,
 		"genesis address")
-	flag.StringVar(&GenesisSignatureStr, "genesis-signature", GenesisSignatureStr,
+	fs.StringVar(&GenesisSignatureStr, "genesis-signature", GenesisSignatureStr,
 		"genesis block signature")
-	flag.Uint64Var(&c.GenesisTimestamp, "genesis-timestamp", c.GenesisTimestamp,
+	fs.StringVar(&CheckpointsStr, "checkpoints", CheckpointsStr,
+		"Known-good block hashes in the format of seq:hash,seq:hash")
+	fs.Uint64Var(&c.GenesisTimestamp, "genesis-timestamp", c.GenesisTimestamp,
 		"genesis block timestamp")
 
-	flag.StringVar(&c.WalletDirectory, "wallet-dir", c.WalletDirectory,
+	fs.StringVar(&c.WalletDirectory, "wallet-dir", c.WalletDirectory,
 		"location of the wallet files. Defaults to ~/.suncoin/wallet/")
 
-	flag.DurationVar(&c.OutgoingConnectionsRate, "connection-rate",
+	fs.DurationVar(&c.OutgoingConnectionsRate, "connection-rate",
 		c.OutgoingConnectionsRate, "How often to make an outgoing connection")
-	flag.BoolVar(&c.LocalhostOnly, "localhost-only", c.LocalhostOnly,
+	fs.DurationVar(&c.UnconfirmedMaxAge, "unconfirmed-max-age", c.UnconfirmedMaxAge,
+		"How long an unconfirmed transaction is held since it was last received, 0 never drops it")
+	fs.Uint64Var(&c.PruneDepth, "prune-depth", c.PruneDepth,
+		"Discard the bodies of the blocks older than this number of blocks, 0 keeps all the blocks")
+	fs.DurationVar(&c.DBCompactInterval, "db-compact-interval", c.DBCompactInterval,
+		"How often the db is compacted when the node starts, 0 disables compaction")
+	fs.StringVar(&c.BackupDirectory, "backup-dir", c.BackupDirectory,
+		"location of the wallet and db backups. Defaults to ~/.suncoin/backups/")
+	fs.DurationVar(&c.BackupInterval, "backup-interval", c.BackupInterval,
+		"How often the wallets and the db are backed up, 0 only backs up on request")
+	fs.IntVar(&c.BackupKeep, "backup-keep", c.BackupKeep,
+		"Number of the most recent backups kept")
+	fs.StringVar(&c.WatchAddresses, "watch-addresses", c.WatchAddresses,
+		"Comma separated addresses watched since the node starts, reloaded by SIGHUP")
+	fs.StringVar(&c.WalletNotify, "wallet-notify", c.WalletNotify,
+		"Command run for each wallet event, %t, %w and %s are replaced by the event type, wallet id and txid, the event json is written to its stdin")
+	fs.Uint64Var(&c.WalletNotifyConfirmations, "wallet-notify-confirmations", c.WalletNotifyConfirmations,
+		"Number of confirmations the wallet payments are confirmed at")
+	fs.BoolVar(&c.LocalhostOnly, "localhost-only", c.LocalhostOnly,
 		"Run on localhost and only connect to localhost peers")
-	flag.BoolVar(&c.Arbitrating, "arbitrating", c.Arbitrating, "Run node in arbitrating mode")
-
-	flag.StringVar(&c.DBPath, "dbname", "data.db", "boltdb file name")
+	fs.BoolVar(&c.Arbitrating, "arbitrating", c.Arbitrating, "Run node in arbitrating mode")
+
+	fs.StringVar(&c.DBPath, "dbname", "data.db", "boltdb file name")
+	fs.BoolVar(&c.VerifyDB, "verify-db", false,
+		"Verify the blockchain db from genesis, print the report and exit")
+	fs.StringVar(&c.ExportChain, "export-chain", "",
+		"Write the blocks to the chain file and exit")
+	fs.Uint64Var(&c.ExportChainSeq, "export-chain-seq", 0,
+		"Seq of the last block written by -export-chain, 0 writes till the head")
+	fs.StringVar(&c.ImportChain, "import-chain", "",
+		"Import the blocks of the chain file when the node starts")
+	fs.BoolVar(&c.MigrateDryRun, "migrate-dry-run", false,
+		"Run the pending db migrations without saving the changes, print them and exit")
+	fs.BoolVar(&c.RebuildIndexes, "rebuild-indexes", false,
+		"Rebuild the address, transaction and output indexes from the blocks and exit")
 }
 
 var devConfig Config = Config{
@@ -246,32 +548,87 @@ var devConfig Config = Config{
 	//gnet uses this for TCP incoming and outgoing
 	Port: 7200,
 
-	MaxConnections: 16,
+	MaxConnections:          16,
+	MaxIncomingConnections:  64,
+	MaxConnectionsPerIP:     3,
+	MaxConnectionsPerSubnet: 8,
+	CompressionMinSize:      1024,
+	Proxy:                   "",
+	ProxyOnly:               false,
+	OnionAddress:            "",
+	EncryptedPort:           0,
+	EncryptedPeers:          "",
+	AuthorizedKeys:          "",
+	TrustedPeers:            "",
+	PortMapping:             false,
+	PortMappingLifetime:     time.Minute * 20,
+	DNSSeeds:                "",
+	DNSSeedCacheTTL:         time.Hour * 24,
 	// How often to make outgoing connections, in seconds
 	OutgoingConnectionsRate: time.Second * 5,
 	// Wallet Address Version
 	//AddressVersion: "test",
 	// Remote web interface
-	WebInterface:             true,
-	WebInterfacePort:         7620,
-	WebInterfaceAddr:         "127.0.0.1",
-	WebInterfaceCert:         "",
-	WebInterfaceKey:          "",
-	WebInterfaceHTTPS:        false,
-	PrintWebInterfaceAddress: false,
+	WebInterface:              true,
+	WebInterfacePort:          7620,
+	WebInterfaceAddr:          "127.0.0.1",
+	WebInterfaceCert:          "",
+	WebInterfaceKey:           "",
+	WebInterfaceHTTPS:         false,
+	WebInterfaceAutoCert:      true,
+	WebInterfaceHSTSMaxAge:    0,
+	WebInterfaceTLSCiphers:    "",
+	WebInterfaceTLSMinVersion: "1.2",
+	WebInterfaceGzip:          true,
+	PrintWebInterfaceAddress:  false,
+	ShutdownTimeout:           time.Second * 30,
+	ConfigFile:                "",
+	PrintConfig:               false,
+
+	APIKey:             "",
+	CSRF:               false,
+	APIPublicReads:     true,
+	APIProtectedRoutes: strings.Join(gui.DefaultProtectedRoutes, ","),
+	APIPublicRoutes:    "",
+
+	CORSAllowedOrigins: "",
+	CORSAllowedMethods: strings.Join(gui.NewCORSConfig().AllowedMethods, ","),
+	CORSAllowedHeaders: strings.Join(gui.NewCORSConfig().AllowedHeaders, ","),
+
+	RateLimit:                  false,
+	RateLimitRead:              gui.NewRateLimitConfig().Limits[gui.RateClassRead].String(),
+	RateLimitWrite:             gui.NewRateLimitConfig().Limits[gui.RateClassWrite].String(),
+	RateLimitHeavy:             gui.NewRateLimitConfig().Limits[gui.RateClassHeavy].String(),
+	RateLimitHeavyRoutes:       strings.Join(gui.DefaultHeavyRoutes, ","),
+	RateLimitTrustForwardedFor: false,
+
+	IdempotencyWindow: gui.DefaultIdempotencyWindow,
+
+	GraphQL: false,
+
+	Metrics: false,
+
+	WebInterfacePprof: false,
 
 	RPCInterface:     true,
 	RPCInterfacePort: 7630,
 	RPCInterfaceAddr: "127.0.0.1",
 
+	GRPCInterface:     false,
+	GRPCInterfacePort: 7640,
+	GRPCInterfaceAddr: "127.0.0.1",
+
 	LaunchBrowser: true,
 	// Data directory holds app data -- defaults to ~/.suncoin
 	DataDirectory: ".suncoin",
 	// Web GUI static resources
 	GUIDirectory: "./src/gui/static/",
 	// Logging
-	ColorLog: true,
-	LogLevel: "DEBUG",
+	ColorLog:          true,
+	LogLevel:          "DEBUG",
+	LogJSON:           false,
+	LogFileMaxSize:    100,
+	LogFileMaxBackups: 10,
 
 	// Wallets
 	WalletDirectory: "",
@@ -285,6 +642,17 @@ var devConfig Config = Config{
 	GenesisTimestamp: GenesisTimestamp,
 	GenesisSignature: cipher.Sig{},
 
+	UnconfirmedMaxAge: time.Hour * 48,
+
+	DBCompactInterval: time.Hour * 24 * 7,
+
+	// Backups
+	BackupDirectory: "",
+	BackupInterval:  time.Hour * 24,
+	BackupKeep:      7,
+
+	WalletNotifyConfirmations: 1,
+
 	/* Developer options */
 
 	// Enable cpu profiling
@@ -299,11 +667,224 @@ var devConfig Config = Config{
 }
 
 func (c *Config) Parse() {
-	c.register()
+	defaultConfig = *c
+	c.register(flag.CommandLine)
 	flag.Parse()
+
+	loader, err := c.load(flag.CommandLine)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	if c.PrintConfig {
+		if err := loader.WriteTOML(os.Stdout, "config", "print-config"); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
 	c.postProcess()
 }
 
+// load sets the flags of fs not set on the command line from the environment variables, and then
+// from the config file
+func (c *Config) load(fs *flag.FlagSet) (*config.Loader, error) {
+	loader := config.NewLoader(fs, configEnvPrefix)
+	if err := loader.LoadEnv(os.Environ()); err != nil {
+		return nil, err
+	}
+
+	if c.ConfigFile != "" {
+		if err := loader.LoadFile(c.ConfigFile); err != nil {
+			return nil, fmt.Errorf("load config file %s failed: %v", c.ConfigFile, err)
+		}
+	}
+	return loader, nil
+}
+
+// defaultConfig is the config before the flags are parsed, the config is reloaded from it
+var defaultConfig Config
+
+// reloadedConfig parses the command line, the environment variables and the config file again
+func reloadedConfig() (*Config, error) {
+	// the genesis block and the master keys are only used when the node starts, their globals
+	// are restored after parsing
+	globals := []*string{&GenesisSignatureStr, &GenesisAddressStr, &BlockchainPubkeyStr,
+		&BlockchainSeckeyStr, &CheckpointsStr}
+	saved := make([]string, len(globals))
+	for i, g := range globals {
+		saved[i] = *g
+	}
+	defer func() {
+		for i, g := range globals {
+			*g = saved[i]
+		}
+	}()
+
+	c := defaultConfig
+	fs := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	c.register(fs)
+	if err := fs.Parse(os.Args[1:]); err != nil {
+		return nil, err
+	}
+	if _, err := c.load(fs); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// configReloader applies the hot-reloadable settings of the reloaded config to the running node:
+// the peer limits, the rate limits, the log levels and the watched addresses
+type configReloader struct {
+	sync.Mutex
+	c *Config
+	d *daemon.Daemon
+}
+
+// reload reloads the config and returns the changed flags. All the settings are validated
+// before any is applied.
+func (r *configReloader) reload() (map[string]string, error) {
+	r.Lock()
+	defer r.Unlock()
+
+	nc, err := reloadedConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	limits, err := rateLimits(nc)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := logging.LogLevel(nc.LogLevel); err != nil {
+		return nil, fmt.Errorf("invalid -log-level: %v", err)
+	}
+	moduleLevels, err := logging.ParseModuleLevels(nc.LogModuleLevels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -log-module-levels: %v", err)
+	}
+	watched, err := parseAddresses(nc.WatchAddresses)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -watch-addresses: %v", err)
+	}
+
+	c := r.c
+	changed := make(map[string]string)
+	diff := func(name, prev, next string) bool {
+		if prev == next {
+			return false
+		}
+		changed[name] = next
+		return true
+	}
+
+	outgoingMax := diff("max-outgoing-connections", strconv.Itoa(c.MaxConnections), strconv.Itoa(nc.MaxConnections))
+	incomingMax := diff("max-incoming-connections", strconv.Itoa(c.MaxIncomingConnections), strconv.Itoa(nc.MaxIncomingConnections))
+	ipCountsMax := diff("max-connections-per-ip", strconv.Itoa(c.MaxConnectionsPerIP), strconv.Itoa(nc.MaxConnectionsPerIP))
+	subnetCountsMax := diff("max-connections-per-subnet", strconv.Itoa(c.MaxConnectionsPerSubnet), strconv.Itoa(nc.MaxConnectionsPerSubnet))
+
+	rateRead := diff("rate-limit-read", c.RateLimitRead, nc.RateLimitRead)
+	rateWrite := diff("rate-limit-write", c.RateLimitWrite, nc.RateLimitWrite)
+	rateHeavy := diff("rate-limit-heavy", c.RateLimitHeavy, nc.RateLimitHeavy)
+
+	logLevel := diff("log-level", c.LogLevel, nc.LogLevel)
+	logModuleLevels := diff("log-module-levels", c.LogModuleLevels, nc.LogModuleLevels)
+
+	if diff("watch-addresses", c.WatchAddresses, nc.WatchAddresses) {
+		prev, _ := parseAddresses(c.WatchAddresses)
+		kept := make(map[cipher.Address]bool, len(watched))
+		for _, a := range watched {
+			kept[a] = true
+		}
+		var removed []cipher.Address
+		for _, a := range prev {
+			if !kept[a] {
+				removed = append(removed, a)
+			}
+		}
+
+		// the new addresses are watched first, nothing is applied if the watch list is full
+		if err := r.d.Gateway.WatchAddresses(watched); err != nil {
+			return nil, err
+		}
+		r.d.Gateway.UnwatchAddresses(removed)
+		c.WatchAddresses = nc.WatchAddresses
+	}
+
+	if outgoingMax || incomingMax || ipCountsMax || subnetCountsMax {
+		r.d.Gateway.SetPeerLimits(daemon.PeerLimits{
+			OutgoingMax:     nc.MaxConnections,
+			IncomingMax:     nc.MaxIncomingConnections,
+			IPCountsMax:     nc.MaxConnectionsPerIP,
+			SubnetCountsMax: nc.MaxConnectionsPerSubnet,
+		})
+		c.MaxConnections = nc.MaxConnections
+		c.MaxIncomingConnections = nc.MaxIncomingConnections
+		c.MaxConnectionsPerIP = nc.MaxConnectionsPerIP
+		c.MaxConnectionsPerSubnet = nc.MaxConnectionsPerSubnet
+	}
+
+	if rateRead || rateWrite || rateHeavy {
+		if gui.Limiter != nil {
+			gui.Limiter.SetLimits(limits)
+		} else {
+			logger.Warning("The rate limits are not applied, the node runs without -rate-limit")
+		}
+		c.RateLimitRead = nc.RateLimitRead
+		c.RateLimitWrite = nc.RateLimitWrite
+		c.RateLimitHeavy = nc.RateLimitHeavy
+	}
+
+	if logLevel || logModuleLevels {
+		if err := logging.SetLevels(nc.LogLevel, moduleLevels); err != nil {
+			return nil, err
+		}
+		c.LogLevel = nc.LogLevel
+		c.LogModuleLevels = nc.LogModuleLevels
+	}
+
+	logger.Notice("Config reloaded, %d settings changed: %v", len(changed), changed)
+	return changed, nil
+}
+
+// rateLimits parses the limits of the request classes, the classes of the empty limits aren't
+// limited
+func rateLimits(c *Config) (map[string]gui.RateLimit, error) {
+	limits := make(map[string]gui.RateLimit)
+	for class, l := range map[string]string{
+		gui.RateClassRead:  c.RateLimitRead,
+		gui.RateClassWrite: c.RateLimitWrite,
+		gui.RateClassHeavy: c.RateLimitHeavy,
+	} {
+		if l == "" {
+			continue
+		}
+
+		limit, err := gui.ParseRateLimit(l)
+		if err != nil {
+			return nil, fmt.Errorf("invalid -rate-limit-%s: %v", class, err)
+		}
+		limits[class] = limit
+	}
+	return limits, nil
+}
+
+// parseAddresses parses the comma separated addresses
+func parseAddresses(s string) ([]cipher.Address, error) {
+	var addrs []cipher.Address
+	for _, a := range splitList(s) {
+		addr, err := cipher.DecodeBase58Address(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid address %s: %v", a, err)
+		}
+		addrs = append(addrs, addr)
+	}
+	return addrs, nil
+}
+
 func (c *Config) postProcess() {
 	var err error
 	if GenesisSignatureStr != "" {
@@ -326,6 +907,10 @@ func (c *Config) postProcess() {
 	if BlockchainSeckeyStr != "" {
 		c.BlockchainSeckey = cipher.SecKey{}
 	}
+	if CheckpointsStr != "" {
+		c.Checkpoints, err = visor.ParseCheckpoints(CheckpointsStr)
+		panicIfError(err, "Invalid Checkpoints")
+	}
 
 	c.DataDirectory, err = file.InitDataDir(c.DataDirectory)
 	panicIfError(err, "Invalid DataDirectory")
@@ -341,12 +926,16 @@ func (c *Config) postProcess() {
 		c.WalletDirectory = filepath.Join(c.DataDirectory, "wallets/")
 	}
 
+	if c.BackupDirectory == "" {
+		c.BackupDirectory = filepath.Join(c.DataDirectory, "backups/")
+	}
+
 	c.DBPath = filepath.Join(c.DataDirectory, c.DBPath)
 }
 
 func panicIfError(err error, msg string, args ...interface{}) {
 	if err != nil {
-		log.Panicf(msg+": %v", append(args, err)...)
+		logger.Panicf(msg+": %v", append(args, err)...)
 	}
 }
 
@@ -388,40 +977,54 @@ func catchDebug() {
 	}
 }
 
+// Catches SIGHUP and reloads the config
+func catchReload(reload func() (map[string]string, error)) {
+	sigchan := make(chan os.Signal, 1)
+	signal.Notify(sigchan, syscall.SIGHUP)
+	for range sigchan {
+		if _, err := reload(); err != nil {
+			logger.Error("Reload config failed: %v", err)
+		}
+	}
+}
+
 // init logging settings
-func initLogging(dataDir string, level string, color, logtofile bool) (func(), error) {
+func initLogging(c *Config) (func(), error) {
 	logCfg := logging.DevLogConfig(logModules)
 	logCfg.Format = logFormat
-	logCfg.Colors = color
-	logCfg.Level = level
+	logCfg.Colors = c.ColorLog
+	logCfg.Level = c.LogLevel
+	logCfg.JSON = c.LogJSON
 
-	var fd *os.File
-	if logtofile {
-		logDir := filepath.Join(dataDir, "logs")
+	if _, err := logging.LogLevel(c.LogLevel); err != nil {
+		return nil, fmt.Errorf("invalid -log-level: %v", err)
+	}
+
+	moduleLevels, err := logging.ParseModuleLevels(c.LogModuleLevels)
+	if err != nil {
+		return nil, fmt.Errorf("invalid -log-module-levels: %v", err)
+	}
+	logCfg.ModuleLevels = moduleLevels
+
+	if c.Logtofile {
+		logDir := filepath.Join(c.DataDirectory, "logs")
 		if err := createDirIfNotExist(logDir); err != nil {
-			log.Println("initial logs folder failed", err)
 			return nil, fmt.Errorf("init log folder fail, %v", err)
 		}
 
-		// open log file
 		tf := "2006-01-02-030405"
-		logfile := filepath.Join(logDir,
+		logCfg.File = filepath.Join(logDir,
 			fmt.Sprintf("%s-v%s.log", time.Now().Format(tf), Version))
-		var err error
-		fd, err = os.OpenFile(logfile, os.O_RDWR|os.O_CREATE, 0666)
-		if err != nil {
-			return nil, err
-		}
-
-		logCfg.Output = io.MultiWriter(os.Stdout, fd)
+		logCfg.FileMaxSize = int64(c.LogFileMaxSize) * 1024 * 1024
+		logCfg.FileMaxBackups = c.LogFileMaxBackups
 	}
 
-	logCfg.InitLogger()
+	closeFile := logCfg.InitLogger()
 
 	return func() {
 		logger.Info("Log file closed")
-		if fd != nil {
-			fd.Close()
+		if err := closeFile(); err != nil {
+			fmt.Println("close log file failed:", err)
 		}
 	}, nil
 }
@@ -430,14 +1033,14 @@ func initProfiling(httpProf, profileCPU bool, profileCPUFile string) {
 	if profileCPU {
 		f, err := os.Create(profileCPUFile)
 		if err != nil {
-			log.Fatal(err)
+			logger.Fatal(err)
 		}
 		pprof.StartCPUProfile(f)
 		defer pprof.StopCPUProfile()
 	}
 	if httpProf {
 		go func() {
-			log.Println(http.ListenAndServe("localhost:6060", nil))
+			logger.Error("http profiling interface stopped: %v", http.ListenAndServe("localhost:6060", nil))
 		}()
 	}
 }
@@ -455,6 +1058,24 @@ func configureDaemon(c *Config) daemon.Config {
 	dc.Daemon.Address = c.Address
 	dc.Daemon.LocalhostOnly = c.LocalhostOnly
 	dc.Daemon.OutgoingMax = c.MaxConnections
+	dc.Daemon.IncomingMax = c.MaxIncomingConnections
+	dc.Daemon.IPCountsMax = c.MaxConnectionsPerIP
+	dc.Daemon.SubnetCountsMax = c.MaxConnectionsPerSubnet
+	dc.Daemon.CompressionMinSize = c.CompressionMinSize
+	dc.Daemon.Proxy = c.Proxy
+	dc.Daemon.ProxyOnly = c.ProxyOnly
+	dc.Daemon.OnionAddress = c.OnionAddress
+	dc.Daemon.EncryptedPort = c.EncryptedPort
+	dc.Daemon.EncryptedPeers = splitList(c.EncryptedPeers)
+	dc.Daemon.AuthorizedKeys = splitList(c.AuthorizedKeys)
+	dc.Daemon.TrustedPeers = splitList(c.TrustedPeers)
+	dc.Daemon.EncryptionKeyFile = filepath.Join(c.DataDirectory, "peer.key")
+	dc.NAT.Enabled = c.PortMapping
+	dc.NAT.Lifetime = c.PortMappingLifetime
+	dc.DNSSeed.Seeds = splitList(c.DNSSeeds)
+	dc.DNSSeed.Port = c.Port
+	dc.DNSSeed.CacheTTL = c.DNSSeedCacheTTL
+	dc.DNSSeed.CacheFile = filepath.Join(c.DataDirectory, "dnsseeds.json")
 	dc.Daemon.DataDirectory = c.DataDirectory
 
 	daemon.DefaultConnections = DefaultConnections
@@ -473,11 +1094,139 @@ func configureDaemon(c *Config) daemon.Config {
 	dc.Visor.Config.GenesisSignature = c.GenesisSignature
 	dc.Visor.Config.GenesisTimestamp = c.GenesisTimestamp
 	dc.Visor.Config.GenesisCoinVolume = GenesisCoinVolume
+	dc.Visor.Config.Checkpoints = c.Checkpoints
+	dc.Visor.Config.UnconfirmedMaxAge = c.UnconfirmedMaxAge
+	dc.Visor.Config.PruneDepth = c.PruneDepth
+	dc.Visor.Config.DBCompactInterval = c.DBCompactInterval
 	dc.Visor.Config.DBPath = c.DBPath
 	dc.Visor.Config.Arbitrating = c.Arbitrating
+	dc.Visor.Config.ReadOnly = c.ReadOnly
+
+	// the read-only node doesn't sync the chain or relay the txns
+	if c.ReadOnly {
+		dc.Daemon.DisableNetworking = true
+	}
+
+	dc.Backup.Dir = c.BackupDirectory
+	dc.Backup.Interval = c.BackupInterval
+	dc.Backup.Keep = c.BackupKeep
+	dc.Backup.WalletDir = c.WalletDirectory
+	dc.Backup.DBPath = c.DBPath
+	// the db can't be restored in read-only mode
+	if c.ReadOnly {
+		dc.Backup.Dir = ""
+	}
 	return dc
 }
 
+// verifyDB re-validates the blockchain db from genesis, and prints the report
+func verifyDB(c visor.Config) error {
+	// the visor is not run, so it's not closed, the process exits after verifying
+	v, _, err := visor.NewVisor(c)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Verifying blockchain db %s", c.DBPath)
+	report, err := v.VerifyBlockchain(func(seq uint64) {
+		if seq%1000 == 0 {
+			logger.Info("Verified blocks up to %d", seq)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	d, err := json.MarshalIndent(report, "", "    ")
+	if err != nil {
+		return err
+	}
+	fmt.Println(string(d))
+
+	if !report.OK() {
+		return errors.New("The blockchain db is corrupted, see the report for details")
+	}
+
+	logger.Info("Blockchain db verified")
+	return nil
+}
+
+func migrateDryRun(dbPath string) error {
+	ms, err := visor.DryRunMigrations(dbPath)
+	if err != nil {
+		return err
+	}
+
+	if len(ms) == 0 {
+		fmt.Println("No pending db migrations")
+		return nil
+	}
+
+	for _, m := range ms {
+		fmt.Printf("%d: %s\n", m.Version, m.Name)
+	}
+	return nil
+}
+
+// rebuildIndexes rebuilds the indexes from the blocks, and prints the progress
+func rebuildIndexes(c visor.Config) error {
+	// the visor is not run, so it's not closed, the process exits after rebuilding
+	v, _, err := visor.NewVisor(c)
+	if err != nil {
+		return err
+	}
+
+	return v.RebuildIndexes(func(p visor.IndexProgress) {
+		fmt.Printf("Parsed %d/%d blocks\n", p.ParsedSeq, p.HeadSeq)
+	})
+}
+
+func exportChain(c visor.Config, path string, upToSeq uint64) error {
+	// the visor is not run, so it's not closed, the process exits after exporting
+	v, _, err := visor.NewVisor(c)
+	if err != nil {
+		return err
+	}
+
+	if head := v.Blockchain.Head(); upToSeq == 0 && head != nil {
+		upToSeq = head.Seq()
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	logger.Info("Exporting blocks till %d to %s", upToSeq, path)
+	if err := v.ExportChain(f, upToSeq); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	logger.Info("Chain exported")
+	return nil
+}
+
+func importChain(d *daemon.Daemon, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	logger.Info("Importing blocks from %s", path)
+	if err := d.Visor.ImportChain(f); err != nil {
+		return err
+	}
+
+	logger.Info("Chain imported, head block %d", d.Visor.HeadBkSeq())
+	return nil
+}
+
 // Run starts the suncoin node
 func Run(c *Config) {
 	defer func() {
@@ -504,12 +1253,44 @@ func Run(c *Config) {
 
 	initProfiling(c.HTTPProf, c.ProfileCPU, c.ProfileCPUFile)
 
-	closelog, err := initLogging(c.DataDirectory, c.LogLevel, c.ColorLog, c.Logtofile)
+	closelog, err := initLogging(c)
 	if err != nil {
 		fmt.Println(err)
 		return
 	}
 
+	if c.VerifyDB {
+		if err := verifyDB(configureDaemon(c).Visor.Config); err != nil {
+			logger.Error("%v", err)
+		}
+		closelog()
+		return
+	}
+
+	if c.MigrateDryRun {
+		if err := migrateDryRun(configureDaemon(c).Visor.Config.DBPath); err != nil {
+			logger.Error("Migration dry run failed: %v", err)
+		}
+		closelog()
+		return
+	}
+
+	if c.RebuildIndexes {
+		if err := rebuildIndexes(configureDaemon(c).Visor.Config); err != nil {
+			logger.Error("Rebuild indexes failed: %v", err)
+		}
+		closelog()
+		return
+	}
+
+	if c.ExportChain != "" {
+		if err := exportChain(configureDaemon(c).Visor.Config, c.ExportChain, c.ExportChainSeq); err != nil {
+			logger.Error("Export chain failed: %v", err)
+		}
+		closelog()
+		return
+	}
+
 	// If the user Ctrl-C's, shutdown properly
 	quit := make(chan struct{})
 
@@ -519,6 +1300,20 @@ func Run(c *Config) {
 
 	gui.InitWalletRPC(c.WalletDirectory, wallet.OptCoin("sun"))
 
+	watched, err := parseAddresses(c.WatchAddresses)
+	if err != nil {
+		logger.Error("Invalid -watch-addresses: %v", err)
+		return
+	}
+
+	// the db restored from a backup replaces the db before it's opened
+	if !c.ReadOnly {
+		if _, err := backup.ApplyRestoredDB(c.DBPath); err != nil {
+			logger.Error("Restore db failed: %v", err)
+			return
+		}
+	}
+
 	dconf := configureDaemon(c)
 	d, err := daemon.NewDaemon(dconf)
 	if err != nil {
@@ -532,6 +1327,40 @@ func Run(c *Config) {
 		errC <- d.Run()
 	}()
 
+	if err := d.Gateway.WatchAddresses(watched); err != nil {
+		logger.Error("Watch -watch-addresses failed: %v", err)
+		return
+	}
+
+	// reloads the hot-reloadable settings by SIGHUP and by the api
+	reloader := &configReloader{c: c, d: d}
+	gui.ConfigReloader = reloader.reload
+	go catchReload(reloader.reload)
+
+	if c.ImportChain != "" {
+		go func() {
+			if err := importChain(d, c.ImportChain); err != nil {
+				logger.Error("Import chain failed: %v", err)
+			}
+		}()
+	}
+
+	// emits the wallet events to the notify command
+	wc := gui.NewWalletNotifierConfig()
+	wc.Confirmations = c.WalletNotifyConfirmations
+	wc.Command = c.WalletNotify
+	wn := gui.NewWalletNotifier(d.Gateway, wc)
+	go wn.Run()
+
+	// delivers the events to the webhooks saved in the data directory
+	whc := gui.NewWebhookConfig()
+	whc.Path = filepath.Join(c.DataDirectory, "webhooks.json")
+	if err := gui.InitWebhooks(d.Gateway, whc); err != nil {
+		logger.Error("%v", err)
+		return
+	}
+	go gui.Wh.Run()
+
 	var rpc *webrpc.WebRPC
 	// start the webrpc
 	if c.RPCInterface {
@@ -550,28 +1379,93 @@ func Run(c *Config) {
 		}()
 	}
 
+	// start the grpc
+	if c.GRPCInterface {
+		if err := gui.LaunchGRPC(fmt.Sprintf("%v:%v", c.GRPCInterfaceAddr, c.GRPCInterfacePort), d); err != nil {
+			logger.Error("%v", err)
+			return
+		}
+	}
+
 	// Debug only - forces connection on start.  Violates thread safety.
 	if c.ConnectTo != "" {
-		if err := d.Pool.Pool.Connect(c.ConnectTo); err != nil {
+		if err := d.Connect(c.ConnectTo); err != nil {
 			logger.Error("Force connect %s failed, %v", c.ConnectTo, err)
 			return
 		}
 	}
 
 	if c.WebInterface {
+		ac := gui.NewAPIAuthConfig()
+		ac.APIKey = c.APIKey
+		ac.CSRF = c.CSRF
+		ac.PublicReads = c.APIPublicReads
+		ac.ProtectedRoutes = splitList(c.APIProtectedRoutes)
+		ac.PublicRoutes = splitList(c.APIPublicRoutes)
+		gui.InitAPIAuth(ac)
+
+		cc := gui.NewCORSConfig()
+		cc.AllowedOrigins = splitList(c.CORSAllowedOrigins)
+		cc.AllowedMethods = splitList(c.CORSAllowedMethods)
+		cc.AllowedHeaders = splitList(c.CORSAllowedHeaders)
+		if err := gui.InitCORS(cc, ac); err != nil {
+			logger.Error("%v", err)
+			return
+		}
+		gui.GzipEnabled = c.WebInterfaceGzip
+		gui.InitIdempotency(c.IdempotencyWindow)
+		gui.GraphQLEnabled = c.GraphQL
+		gui.MetricsEnabled = c.Metrics
+		gui.PprofEnabled = c.WebInterfacePprof
+
+		if c.RateLimit {
+			rc := gui.NewRateLimitConfig()
+			rc.HeavyRoutes = splitList(c.RateLimitHeavyRoutes)
+			rc.TrustForwardedFor = c.RateLimitTrustForwardedFor
+			limits, err := rateLimits(c)
+			if err != nil {
+				logger.Error("%v", err)
+				return
+			}
+			rc.Limits = limits
+			gui.InitRateLimit(rc)
+		}
+		if c.APIKey == "" && c.WebInterfaceAddr != "127.0.0.1" && c.WebInterfaceAddr != "localhost" {
+			logger.Warning("Web interface is exposed on %s without -api-key, anyone can use the loaded wallets", c.WebInterfaceAddr)
+			if c.WebInterfacePprof {
+				logger.Warning("The pprof profiles and the goroutine dump are exposed on %s without -api-key", c.WebInterfaceAddr)
+			}
+		}
+
 		var err error
 		if c.WebInterfaceHTTPS {
-			// Verify cert/key parameters, and if neither exist, create them
-			errs := cert.CreateCertIfNotExists(host, c.WebInterfaceCert, c.WebInterfaceKey, "Suncoind")
-			if len(errs) != 0 {
-				for _, err := range errs {
-					logger.Error(err.Error())
-				}
-				logger.Error("gui.CreateCertIfNotExists failure")
+			tc := gui.TLSConfig{
+				CertFile:   c.WebInterfaceCert,
+				KeyFile:    c.WebInterfaceKey,
+				HSTSMaxAge: c.WebInterfaceHSTSMaxAge,
+			}
+			if tc.CipherSuites, err = gui.ParseCipherSuites(c.WebInterfaceTLSCiphers); err != nil {
+				logger.Error("Invalid -web-interface-tls-ciphers: %v", err)
+				return
+			}
+			if tc.MinVersion, err = gui.ParseTLSVersion(c.WebInterfaceTLSMinVersion); err != nil {
+				logger.Error("Invalid -web-interface-tls-min-version: %v", err)
 				return
 			}
 
-			err = gui.LaunchWebInterfaceHTTPS(host, c.GUIDirectory, d, c.WebInterfaceCert, c.WebInterfaceKey)
+			if c.WebInterfaceAutoCert {
+				// Verify cert/key parameters, and if neither exist, create them
+				errs := cert.CreateCertIfNotExists(host, c.WebInterfaceCert, c.WebInterfaceKey, "Suncoind")
+				if len(errs) != 0 {
+					for _, err := range errs {
+						logger.Error(err.Error())
+					}
+					logger.Error("gui.CreateCertIfNotExists failure")
+					return
+				}
+			}
+
+			err = gui.LaunchWebInterfaceHTTPS(host, c.GUIDirectory, d, tc)
 		} else {
 			err = gui.LaunchWebInterface(host, c.GUIDirectory, d)
 		}
@@ -628,11 +1522,23 @@ func Run(c *Config) {
 
 	logger.Info("Shutting down...")
 
+	// the shutdown is bounded, a second interrupt exits at once too
+	time.AfterFunc(c.ShutdownTimeout, func() {
+		logger.Critical("Shutdown is not done in %v, exiting", c.ShutdownTimeout)
+		os.Exit(1)
+	})
+
+	// no write starts while the state is flushed
+	gui.StopWrites()
+
 	if rpc != nil {
 		rpc.Shutdown()
 	}
 
+	gui.ShutdownGRPC()
 	gui.Shutdown()
+	wn.Shutdown()
+	gui.Wh.Shutdown()
 	d.Shutdown()
 	closelog()
 	logger.Info("Goodbye")
@@ -772,7 +1678,7 @@ func InitTransaction() coin.Transaction {
 	err := tx.Verify()
 
 	if err != nil {
-		log.Panic(err)
+		logger.Panic(err)
 	}
 
 	return tx
@@ -785,3 +1691,14 @@ func createDirIfNotExist(dir string) error {
 
 	return os.Mkdir(dir, 0777)
 }
+
+// splitRoutes splits the comma separated route prefixes
+func splitList(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
@@ -0,0 +1,56 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/visor"
+
+	gcli "github.com/urfave/cli"
+)
+
+func combineTxCMD() gcli.Command {
+	name := "combineTransactions"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Combine the signatures of the copies of an unsigned transaction",
+		ArgsUsage: "[unsigned transaction files...]",
+		Description: `
+  The owners of a multisig address can sign the copies of the unsigned
+        transaction in parallel with the signTransaction command, instead of
+        passing it on in turn. The copies must be of the same transaction, the
+        signatures are checked before they're combined.
+
+        The signed transaction is printed in json, or in hex with the "--hex"
+        option, if every input has enough signatures. Otherwise the unsigned
+        transaction with the combined signatures is printed.`,
+		Flags: []gcli.Flag{
+			gcli.BoolFlag{
+				Name:  "hex",
+				Usage: "Returns the signed transaction in hex.",
+			},
+		},
+		OnUsageError: onCommandUsageError(name),
+		Action: func(c *gcli.Context) error {
+			if c.NArg() < 2 {
+				gcli.ShowSubcommandHelp(c)
+				return nil
+			}
+
+			uts := make([]visor.UnsignedTransaction, 0, c.NArg())
+			for _, fn := range c.Args() {
+				ut, err := readUnsignedTx(fn)
+				if err != nil {
+					return fmt.Errorf("read %s failed: %v", fn, err)
+				}
+				uts = append(uts, *ut)
+			}
+
+			ut, err := visor.CombineUnsignedTransactions(uts...)
+			if err != nil {
+				return err
+			}
+
+			return printSignedTx(ut, c.Bool("hex"))
+		},
+	}
+}
@@ -0,0 +1,59 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/wallet"
+
+	gcli "github.com/urfave/cli"
+)
+
+func paperWalletCMD() gcli.Command {
+	name := "paperWallet"
+	return gcli.Command{
+		Name:         name,
+		Usage:        "Generate a paper wallet",
+		ArgsUsage:    " ",
+		OnUsageError: onCommandUsageError(name),
+		Description: `Generates an address with its seed and secret key to print, with the
+		payloads of the QR codes of the address and the secret key. Nothing is
+		written to disk and no node is needed, so it can be run offline.
+
+		The seed restores the address as the first address of a wallet, and
+		the secret key can be swept into a wallet by the /wallet/sweep api.
+
+		Use caution when using the "-s" option. If you have command history
+		enabled your seed can be recovered from the history log.`,
+		Flags: []gcli.Flag{
+			gcli.StringFlag{
+				Name:  "s",
+				Usage: "Your seed, a random seed of 12 dictionary words is generated if not set",
+			},
+			gcli.BoolFlag{
+				Name:  "json,j",
+				Usage: "Returns the results in JSON format.",
+			},
+		},
+		Action: paperWallet,
+	}
+}
+
+func paperWallet(c *gcli.Context) error {
+	pw, err := wallet.NewPaperWallet(c.String("s"))
+	if err != nil {
+		return err
+	}
+
+	if !c.Bool("json") {
+		fmt.Print(pw.Printable())
+		return nil
+	}
+
+	d, err := json.MarshalIndent(pw.ToReadable(), "", "    ")
+	if err != nil {
+		return errJSONMarshal
+	}
+	fmt.Println(string(d))
+	return nil
+}
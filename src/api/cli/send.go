@@ -0,0 +1,164 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/skycoin/skycoin/src/visor"
+
+	gcli "github.com/urfave/cli"
+)
+
+func sendCMD() gcli.Command {
+	name := "send"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Send skycoin from a wallet or an address to a recipient address",
+		ArgsUsage: "[to address] [amount]",
+		Description: `
+		Note: the [amount] argument is the coins you will spend, 1 coins = 1e6 drops.
+
+        If you are sending from a wallet the coins will be taken recursively from all 
+        addresses within the wallet starting with the first address until the amount of 
+        the transaction is met. 
+
+        In a terminal, the inputs, the outputs, the change and the coin hours burned
+        are shown before the transaction is broadcast, and it's sent only after it's
+        confirmed. Use the "-y" option to skip the confirmation.
+        
+        Use caution when using the “-p” command. If you have command history enabled 
+        your wallet encryption password can be recovered from the history log. 
+        If you do not include the “-p” option you will be prompted to enter your password 
+        after you enter your command.`,
+		Flags: []gcli.Flag{
+			gcli.StringFlag{
+				Name:  "f",
+				Usage: "[wallet file or path] From wallet. If no path is specified your default wallet path will be used.",
+			},
+			gcli.StringFlag{
+				Name:  "a",
+				Usage: "[address] From address",
+			},
+			gcli.StringFlag{
+				Name: "c",
+				Usage: `[changeAddress] Specify change address, by default the from address or 
+				the wallet's coinbase address will be used`,
+			},
+			// gcli.StringFlag{
+			// 	Name:  "p",
+			// 	Usage: "[password] Password for address or wallet.",
+			// },
+			gcli.StringFlag{
+				Name: "m",
+				Usage: `[send to many] use JSON string to set multiple recive addresses and coins,
+				example: -m '[{"addr":"$addr1", "coins": 10}, {"addr":"$addr2", "coins": 20}]'`,
+			},
+			gcli.BoolFlag{
+				Name:  "json,j",
+				Usage: "Returns the results in JSON format.",
+			},
+			gcli.BoolFlag{
+				Name:  "yes,y",
+				Usage: "Broadcast the transaction without the confirmation.",
+			},
+		},
+		OnUsageError: onCommandUsageError(name),
+		Action: func(c *gcli.Context) error {
+			rtx, err := createTransaction(c)
+			if err != nil {
+				errorWithHelp(c, err)
+				return nil
+				// return err
+			}
+
+			// the hours burned are shown before the transaction can't be taken back
+			if !c.Bool("yes") && terminal.IsTerminal(int(os.Stdin.Fd())) {
+				printSpendPreview(rtx)
+				if !confirm("Send the transaction? [y/N]: ") {
+					fmt.Println("canceled")
+					return nil
+				}
+			}
+
+			txid, err := broadcastTx(hex.EncodeToString(rtx.Txn.Serialize()))
+			if err != nil {
+				return err
+			}
+
+			jsonFmt := c.Bool("json")
+			if jsonFmt {
+				var rlt = struct {
+					Txid string `json:"txid"`
+				}{
+					txid,
+				}
+				d, err := json.MarshalIndent(rlt, "", "    ")
+				if err != nil {
+					return errJSONMarshal
+				}
+				fmt.Println(string(d))
+			} else {
+				fmt.Printf("txid:%s\n", txid)
+			}
+
+			return nil
+		},
+	}
+	// Commands = append(Commands, cmd)
+}
+
+// printSpendPreview prints the inputs, the outputs, the change and the coin hours burned
+// by the transaction, the hours of the inputs that are not sent to the outputs are burned
+func printSpendPreview(rtx *rawTransaction) {
+	var hoursIn, hoursOut uint64
+	fmt.Println("Inputs:")
+	for _, o := range rtx.Spends {
+		// the calculated hours are not returned by the old nodes
+		hours := o.CalculatedHours
+		if hours < o.Hours {
+			hours = o.Hours
+		}
+		hoursIn += hours
+
+		coins, _ := strconv.ParseUint(o.Coins, 10, 64)
+		fmt.Printf("  %s  %s coins  %d hours\n", o.Address, visor.StrBalance(coins), hours)
+	}
+
+	fmt.Println("Outputs:")
+	for _, o := range rtx.Txn.Out {
+		hoursOut += o.Hours
+
+		var note string
+		if o.Address.String() == rtx.ChangeAddr {
+			note = "  (change)"
+		}
+		fmt.Printf("  %s  %s coins  %d hours%s\n", o.Address, visor.StrBalance(o.Coins), o.Hours, note)
+	}
+
+	if hoursIn >= hoursOut {
+		fmt.Printf("Coin hours burned: %d of %d\n", hoursIn-hoursOut, hoursIn)
+	}
+}
+
+// confirm prompts the question and returns true if the answer is yes
+func confirm(question string) bool {
+	fmt.Print(question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"golang.org/x/crypto/ssh/terminal"
+
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	gcli "github.com/urfave/cli"
+)
+
+func signTxCMD() gcli.Command {
+	name := "signTransaction"
+	return gcli.Command{
+		Name:      name,
+		Usage:     "Sign an unsigned transaction offline with the wallet",
+		ArgsUsage: "[unsigned transaction file]",
+		Description: fmt.Sprintf(`
+  The unsigned transaction is the json returned by the /createUnsignedTransaction
+        api of a node, it can be created by a node without the secret keys. The
+        signing needs no connection to the node, so the wallet can be kept on an
+        air-gapped machine. Review the inputs, the outputs and the fee of the
+        transaction before signing it.
+
+        The default wallet(%s/%s) will be used if no wallet was specificed.
+
+        The inputs of a multisig address need the signatures of several owners,
+        if the wallet can't add all of them, the unsigned transaction with the
+        signatures added is printed instead, pass it on to the next owner to sign.
+
+        The signed transaction is printed in json, which can be injected by the
+        /injectTransactionJSON api, or in hex with the "--hex" option, which can
+        be broadcast by the broadcastTransaction command.
+
+        Use caution when using the "-p" command. If you have command history enabled
+        your wallet encryption password can be recovered from the history log. If you
+        do not include the "-p" option you will be prompted to enter your password
+        if the wallet is encrypted.`, cfg.WalletDir, cfg.DefaultWalletName),
+		Flags: []gcli.Flag{
+			gcli.StringFlag{
+				Name:  "f",
+				Usage: "[wallet file or path], sign with the wallet",
+			},
+			gcli.StringFlag{
+				Name:  "p",
+				Usage: "[password] password of the encrypted wallet",
+			},
+			gcli.BoolFlag{
+				Name:  "hex",
+				Usage: "Returns the signed transaction in hex.",
+			},
+		},
+		OnUsageError: onCommandUsageError(name),
+		Action: func(c *gcli.Context) error {
+			fn := c.Args().First()
+			if fn == "" {
+				gcli.ShowSubcommandHelp(c)
+				return nil
+			}
+
+			wltFile, _, err := fromWalletOrAddress(c)
+			if err != nil {
+				errorWithHelp(c, err)
+				return nil
+			}
+
+			ut, err := signTx(fn, wltFile, c.String("p"))
+			if err != nil {
+				return err
+			}
+
+			return printSignedTx(ut, c.Bool("hex"))
+		},
+	}
+}
+
+// printSignedTx prints the signed transaction in json, or in hex if hexOut is true. The
+// unsigned transaction is printed instead if the multisig inputs need the signatures of
+// the other owners.
+func printSignedTx(ut *visor.UnsignedTransaction, hexOut bool) error {
+	if !ut.Complete() {
+		b, err := json.MarshalIndent(ut, "", "    ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	txn, err := ut.Finalize()
+	if err != nil {
+		return err
+	}
+
+	if hexOut {
+		fmt.Println(hex.EncodeToString(txn.Serialize()))
+		return nil
+	}
+
+	fmt.Println(visor.TransactionToJSON(txn))
+	return nil
+}
+
+func signTx(fn, wltFile, password string) (*visor.UnsignedTransaction, error) {
+	ut, err := readUnsignedTx(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	wlt, err := wallet.Load(wltFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if wlt.IsEncrypted() {
+		if password == "" {
+			fmt.Print("enter password: ")
+			pwd, err := terminal.ReadPassword(int(os.Stdin.Fd()))
+			fmt.Println()
+			if err != nil {
+				return nil, err
+			}
+			password = string(pwd)
+		}
+
+		if wlt, err = wlt.Decrypt([]byte(password)); err != nil {
+			return nil, err
+		}
+	}
+
+	n, err := ut.Sign(*wlt)
+	if err != nil {
+		return nil, err
+	}
+
+	if n == 0 {
+		return nil, errors.New("no input of the transaction can be signed by the wallet")
+	}
+
+	return ut, nil
+}
+
+func readUnsignedTx(fn string) (*visor.UnsignedTransaction, error) {
+	b, err := ioutil.ReadFile(fn)
+	if err != nil {
+		return nil, err
+	}
+
+	var ut visor.UnsignedTransaction
+	if err := json.Unmarshal(b, &ut); err != nil {
+		return nil, errJSONUnmarshal
+	}
+	return &ut, nil
+}
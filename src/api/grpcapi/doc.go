@@ -0,0 +1,10 @@
+// Package grpcapi holds the protobuf definition of the gRPC services of the node, and the
+// code generated from it. The generated code needs google.golang.org/grpc and
+// github.com/golang/protobuf, it's generated by:
+//
+//	go generate ./src/api/grpcapi
+//
+// The services are implemented in the gui package, by the node built with the grpc tag.
+package grpcapi
+
+//go:generate protoc --go_out=plugins=grpc:. suncoin.proto
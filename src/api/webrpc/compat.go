@@ -0,0 +1,164 @@
+package webrpc
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// The bitcoind style methods of the core operations, for the existing client libraries.
+// The params are positional, the ids can be numbers.
+
+// RawTxnResult is the verbose result of getrawtransaction
+type RawTxnResult struct {
+	Hex         string                   `json:"hex"`
+	Transaction *visor.TransactionResult `json:"transaction"`
+}
+
+// request params: [] or none
+func getStatusCompatHandler(req Request, gateway Gatewayer) Response {
+	if req.emptyParams() {
+		req.Params = nil
+	}
+	return getStatusHandler(req, gateway)
+}
+
+// request params: [hash] or [seq]
+func getBlockCompatHandler(req Request, gateway Gatewayer) Response {
+	var params []json.RawMessage
+	if err := req.DecodeParams(&params); err != nil || len(params) != 1 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	var hash string
+	var seq uint64
+	var b interface{}
+	switch {
+	case json.Unmarshal(params[0], &hash) == nil:
+		h, err := cipher.SHA256FromHex(hash)
+		if err != nil {
+			return makeErrorResponse(errCodeInvalidParams, "invalid block hash")
+		}
+		if block, ok := gateway.GetBlockByHash(h); ok {
+			b = visor.NewReadableBlock(&block)
+		}
+	case json.Unmarshal(params[0], &seq) == nil:
+		if block, ok := gateway.GetBlockBySeq(seq); ok {
+			b = visor.NewReadableBlock(&block)
+		}
+	default:
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	if b == nil {
+		return makeErrorResponse(errCodeInvalidRequest, "block doesn't exist")
+	}
+	return makeSuccessResponse(req.ID, b)
+}
+
+// request params: [txid] or [txid, verbose], verbose is a bool or 0/1
+func getRawTransactionHandler(req Request, gateway Gatewayer) Response {
+	var params []json.RawMessage
+	if err := req.DecodeParams(&params); err != nil || len(params) == 0 || len(params) > 2 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	var txid string
+	if err := json.Unmarshal(params[0], &txid); err != nil {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	var verbose bool
+	if len(params) == 2 {
+		var n int
+		switch {
+		case json.Unmarshal(params[1], &verbose) == nil:
+		case json.Unmarshal(params[1], &n) == nil:
+			verbose = n != 0
+		default:
+			return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+		}
+	}
+
+	t, err := cipher.SHA256FromHex(txid)
+	if err != nil {
+		return makeErrorResponse(errCodeInvalidParams, "invalid transaction hash")
+	}
+
+	txn, err := gateway.GetTransaction(t)
+	if err != nil {
+		logger.Debugf("%v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+
+	if txn == nil {
+		return makeErrorResponse(errCodeInvalidRequest, "transaction doesn't exist")
+	}
+
+	rawtx := hex.EncodeToString(txn.Txn.Serialize())
+	if !verbose {
+		return makeSuccessResponse(req.ID, rawtx)
+	}
+
+	return makeSuccessResponse(req.ID, RawTxnResult{
+		Hex: rawtx,
+		Transaction: &visor.TransactionResult{
+			Transaction: visor.NewReadableTransaction(txn),
+			Status:      txn.Status,
+		},
+	})
+}
+
+// request params: [rawtx], the txid is returned
+func sendRawTransactionHandler(req Request, gateway Gatewayer) Response {
+	var rawtx []string
+	if err := req.DecodeParams(&rawtx); err != nil || len(rawtx) != 1 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	b, err := hex.DecodeString(rawtx[0])
+	if err != nil {
+		return makeErrorResponse(errCodeInvalidParams, fmt.Sprintf("invalid raw transaction:%v", err))
+	}
+
+	txn, err := deserializeTx(b)
+	if err != nil {
+		return makeErrorResponse(errCodeInvalidParams, fmt.Sprintf("%v", err))
+	}
+
+	t, err := gateway.InjectTransaction(txn)
+	if err != nil {
+		return makeErrorResponse(errCodeInternalError, fmt.Sprintf("inject transaction failed:%v", err))
+	}
+
+	return makeSuccessResponse(req.ID, t.Hash().Hex())
+}
+
+// request params: [addr1, addr2, ...], the confirmed and predicted balance of the
+// addresses in total
+func getBalanceHandler(req Request, gateway Gatewayer) Response {
+	var addrStrs []string
+	if err := req.DecodeParams(&addrStrs); err != nil || len(addrStrs) == 0 {
+		return makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)
+	}
+
+	addrs := make([]cipher.Address, len(addrStrs))
+	for i, a := range addrStrs {
+		addr, err := cipher.DecodeBase58Address(strings.TrimSpace(a))
+		if err != nil {
+			return makeErrorResponse(errCodeInvalidParams, fmt.Sprintf("invalid address: %v", a))
+		}
+		addrs[i] = addr
+	}
+
+	bal, err := gateway.AddressesBalance(addrs)
+	if err != nil {
+		logger.Error("get balance failed: %v", err)
+		return makeErrorResponse(errCodeInternalError, errMsgInternalError)
+	}
+	return makeSuccessResponse(req.ID, bal)
+}
@@ -0,0 +1,138 @@
+package webrpc
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+func Test_getBlockCompatHandler(t *testing.T) {
+	b := coin.Block{Head: coin.BlockHeader{BkSeq: 3, Time: 1500000000}}
+	m := NewGatewayerMock()
+	m.On("GetBlockByHash", b.HashHeader()).Return(b, true)
+	m.On("GetBlockByHash", cipher.SHA256{}).Return(coin.Block{}, false)
+	m.On("GetBlockBySeq", uint64(3)).Return(b, true)
+	m.On("GetBlockBySeq", uint64(4)).Return(coin.Block{}, false)
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{"by hash", `["` + b.HashHeader().Hex() + `"]`, makeSuccessResponse("1", visor.NewReadableBlock(&b))},
+		{"by seq", `[3]`, makeSuccessResponse("1", visor.NewReadableBlock(&b))},
+		{"hash not exist", `["` + cipher.SHA256{}.Hex() + `"]`, makeErrorResponse(errCodeInvalidRequest, "block doesn't exist")},
+		{"seq not exist", `[4]`, makeErrorResponse(errCodeInvalidRequest, "block doesn't exist")},
+		{"invalid hash", `["abc"]`, makeErrorResponse(errCodeInvalidParams, "invalid block hash")},
+		{"invalid seq", `[-1]`, makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)},
+		{"empty params", `[]`, makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)},
+	}
+
+	for _, tt := range tests {
+		req := Request{ID: "1", Jsonrpc: jsonRPC, Method: "getblock", Params: []byte(tt.params)}
+		if got := getBlockCompatHandler(req, m); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. getBlockCompatHandler() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_getRawTransactionHandler(t *testing.T) {
+	txid := "bdc4a85a3e9d17a8fe00aa7430d0347c7f1dd6480a16da7147b6e43905057d43"
+	gateway := &fakeGateway{transactions: map[string]string{txid: rawTxS}}
+	tx := decodeRawTransaction(rawTxS)
+	verbose := makeSuccessResponse("1", RawTxnResult{
+		Hex: rawTxS,
+		Transaction: &visor.TransactionResult{
+			Transaction: visor.NewReadableTransaction(tx),
+			Status:      tx.Status,
+		},
+	})
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{"raw", `["` + txid + `"]`, makeSuccessResponse("1", rawTxS)},
+		{"not verbose", `["` + txid + `", false]`, makeSuccessResponse("1", rawTxS)},
+		{"verbose", `["` + txid + `", true]`, verbose},
+		{"verbose number", `["` + txid + `", 1]`, verbose},
+		{"invalid verbose", `["` + txid + `", "yes"]`, makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)},
+		{"not exist", `["bdc4a85a3e9d17a8fe00aa7430d0347c7f1dd6480a16da7147b6e43905057d44"]`,
+			makeErrorResponse(errCodeInvalidRequest, "transaction doesn't exist")},
+		{"invalid hash", `["abc"]`, makeErrorResponse(errCodeInvalidParams, "invalid transaction hash")},
+		{"too many params", `["` + txid + `", true, 1]`, makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)},
+	}
+
+	for _, tt := range tests {
+		req := Request{ID: "1", Jsonrpc: jsonRPC, Method: "getrawtransaction", Params: []byte(tt.params)}
+		if got := getRawTransactionHandler(req, gateway); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. getRawTransactionHandler() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func Test_getBalanceHandler(t *testing.T) {
+	addr := cipher.MustDecodeBase58Address("fyqX5YuwXMUs4GEUE3LjLyhrqvNztFHQ4B")
+	bal := wallet.BalancePair{
+		Confirmed: wallet.Balance{Coins: 10e6, Hours: 100},
+		Predicted: wallet.Balance{Coins: 9e6, Hours: 80},
+	}
+	m := NewGatewayerMock()
+	m.On("AddressesBalance", []cipher.Address{addr}).Return(bal, nil)
+
+	tests := []struct {
+		name   string
+		params string
+		want   Response
+	}{
+		{"normal", `["fyqX5YuwXMUs4GEUE3LjLyhrqvNztFHQ4B"]`, makeSuccessResponse("1", bal)},
+		{"invalid address", `["fyqX5YuwXMUs4GEUE3LjLyhrqvNztFHQ4"]`,
+			makeErrorResponse(errCodeInvalidParams, "invalid address: fyqX5YuwXMUs4GEUE3LjLyhrqvNztFHQ4")},
+		{"empty params", `[]`, makeErrorResponse(errCodeInvalidParams, errMsgInvalidParams)},
+	}
+
+	for _, tt := range tests {
+		req := Request{ID: "1", Jsonrpc: jsonRPC, Method: "getbalance", Params: []byte(tt.params)}
+		if got := getBalanceHandler(req, m); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q. getBalanceHandler() = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNumericID(t *testing.T) {
+	quit := make(chan struct{})
+	defer close(quit)
+	rpc, err := New("0.0.0.0:8082", ChanBuffSize(1), ThreadNum(1), Gateway(&fakeGateway{}), Quit(quit))
+	require.NoError(t, err)
+	rpc.dispatch()
+
+	// the bitcoind style request, the number id and the empty params
+	body := []byte(`{"jsonrpc": "2.0", "id": 7, "method": "getstatus", "params": []}`)
+	r := httptest.NewRequest("POST", "/webrpc", bytes.NewBuffer(body))
+	w := httptest.NewRecorder()
+	rpc.Handler(w, r)
+
+	var res map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Nil(t, res["error"])
+	assert.Equal(t, float64(7), res["id"])
+
+	// the string id is echoed as a string
+	var req Request
+	require.NoError(t, json.Unmarshal([]byte(`{"jsonrpc": "2.0", "id": "7", "method": "getstatus"}`), &req))
+	assert.Equal(t, "7", req.ID)
+	assert.False(t, req.numericID)
+
+	assert.Error(t, json.Unmarshal([]byte(`{"jsonrpc": "2.0", "id": true, "method": "getstatus"}`), &req))
+}
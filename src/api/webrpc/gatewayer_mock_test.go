@@ -14,6 +14,7 @@ import (
 	daemon "github.com/skycoin/skycoin/src/daemon"
 	visor "github.com/skycoin/skycoin/src/visor"
 	historydb "github.com/skycoin/skycoin/src/visor/historydb"
+	wallet "github.com/skycoin/skycoin/src/wallet"
 )
 
 // GatewayerMock mock
@@ -25,6 +26,33 @@ func NewGatewayerMock() *GatewayerMock {
 	return &GatewayerMock{}
 }
 
+// AddressesBalance mocked method
+func (m *GatewayerMock) AddressesBalance(p0 []cipher.Address) (wallet.BalancePair, error) {
+
+	ret := m.Called(p0)
+
+	var r0 wallet.BalancePair
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case wallet.BalancePair:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 error
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case error:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
 // GetAddrUxOuts mocked method
 func (m *GatewayerMock) GetAddrUxOuts(p0 cipher.Address) ([]*historydb.UxOutJSON, error) {
 
@@ -52,6 +80,60 @@ func (m *GatewayerMock) GetAddrUxOuts(p0 cipher.Address) ([]*historydb.UxOutJSON
 
 }
 
+// GetBlockByHash mocked method
+func (m *GatewayerMock) GetBlockByHash(p0 cipher.SHA256) (coin.Block, bool) {
+
+	ret := m.Called(p0)
+
+	var r0 coin.Block
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case coin.Block:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 bool
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case bool:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
+// GetBlockBySeq mocked method
+func (m *GatewayerMock) GetBlockBySeq(p0 uint64) (coin.Block, bool) {
+
+	ret := m.Called(p0)
+
+	var r0 coin.Block
+	switch res := ret.Get(0).(type) {
+	case nil:
+	case coin.Block:
+		r0 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	var r1 bool
+	switch res := ret.Get(1).(type) {
+	case nil:
+	case bool:
+		r1 = res
+	default:
+		panic(fmt.Sprintf("unexpected type: %v", res))
+	}
+
+	return r0, r1
+
+}
+
 // GetBlocks mocked method
 func (m *GatewayerMock) GetBlocks(p0 uint64, p1 uint64) *visor.ReadableBlocks {
 
@@ -0,0 +1,398 @@
+package webrpc
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	"encoding/json"
+
+	wh "github.com/skycoin/skycoin/src/util/http"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	"bytes"
+	"strings"
+)
+
+var (
+	errCodeParseError     = -32700 // Parse error	Invalid JSON was received by the server. An error occurred on the server while parsing the JSON text.
+	errCodeInvalidRequest = -32600 // Invalid Request	The JSON sent is not a valid Request object.
+	errCodeMethodNotFound = -32601 // Method not found	The method does not exist / is not available.
+	errCodeInvalidParams  = -32602 // Invalid params	Invalid method parameter(s).
+	errCodeInternalError  = -32603 // Internal error	Internal JSON-RPC error.
+
+	errMsgParseError     = "Parse error"
+	errMsgInvalidRequest = "Invalid Request"
+	errMsgMethodNotFound = "Method not found"
+	errMsgInvalidParams  = "Invalid params"
+	errMsgInternalError  = "Internal error"
+
+	errMsgNotPost = "only support http POST"
+
+	errMsgInvalidJsonrpc = "invalid jsonrpc"
+
+	// -32000 to -32099	Server error	Reserved for implementation-defined server-errors.
+
+	jsonRPC = "2.0"
+)
+
+var logger = logging.MustGetLogger("webrpc")
+
+// Request rpc request struct
+type Request struct {
+	ID      string          `json:"id"`
+	Jsonrpc string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	// whether the id is a number, the bitcoind style clients send the number ids
+	numericID bool
+}
+
+// UnmarshalJSON decodes the request, the id can be a string or a number
+func (r *Request) UnmarshalJSON(b []byte) error {
+	type request Request
+	var v struct {
+		request
+		ID json.RawMessage `json:"id"`
+	}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return err
+	}
+
+	*r = Request(v.request)
+	r.ID = ""
+	r.numericID = false
+
+	id := bytes.TrimSpace(v.ID)
+	switch {
+	case len(id) == 0 || string(id) == "null":
+	case id[0] == '"':
+		return json.Unmarshal(id, &r.ID)
+	default:
+		var n json.Number
+		if err := json.Unmarshal(id, &n); err != nil {
+			return err
+		}
+		r.ID = n.String()
+		r.numericID = true
+	}
+	return nil
+}
+
+// emptyParams returns whether the request has no params, the null and empty array
+// params are sent by some clients for no params
+func (r *Request) emptyParams() bool {
+	p := string(bytes.TrimSpace(r.Params))
+	return p == "" || p == "null" || strings.Join(strings.Fields(p), "") == "[]"
+}
+
+// RPCError response error
+type RPCError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data,omitempty"`
+}
+
+// Response rpc response struct
+type Response struct {
+	ID      *string         `json:"id"`
+	Jsonrpc string          `json:"jsonrpc"`
+	Error   *RPCError       `json:"error,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	// whether the id is echoed as a number
+	numericID bool
+}
+
+// MarshalJSON encodes the response, the number id of the request is echoed as a number
+func (r Response) MarshalJSON() ([]byte, error) {
+	type response Response
+	if !r.numericID || r.ID == nil {
+		return json.Marshal(response(r))
+	}
+
+	return json.Marshal(struct {
+		response
+		ID json.RawMessage `json:"id"`
+	}{response(r), json.RawMessage(*r.ID)})
+}
+
+// NewRequest create new webrpc request.
+func NewRequest(method string, params interface{}, id string) (*Request, error) {
+	var p json.RawMessage
+	if params != nil {
+		var err error
+		p, err = json.Marshal(params)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return &Request{
+		Jsonrpc: jsonRPC,
+		Method:  method,
+		Params:  p,
+		ID:      id,
+	}, nil
+}
+
+// DecodeParams decodes request params to specific value.
+func (r *Request) DecodeParams(v interface{}) error {
+	return json.NewDecoder(bytes.NewBuffer(r.Params)).Decode(v)
+}
+
+func makeSuccessResponse(id string, result interface{}) Response {
+	rlt, _ := json.Marshal(result)
+	return Response{
+		ID:      &id,
+		Result:  rlt,
+		Jsonrpc: jsonRPC,
+	}
+}
+
+func makeErrorResponse(code int, msgs ...string) Response {
+	msg := strings.Join(msgs[:], "\n")
+	return Response{
+		Error:   &RPCError{Code: code, Message: msg},
+		Jsonrpc: jsonRPC,
+	}
+}
+
+type operation func(rpc *WebRPC)
+
+// HandlerFunc represents the function type for processing the request
+type HandlerFunc func(req Request, gateway Gatewayer) Response
+
+// WebRPC manage the web rpc state and handles
+type WebRPC struct {
+	addr      string // service address
+	workerNum uint
+	ops       chan operation // request channel
+	close     chan struct{}
+	mux       *http.ServeMux
+	handlers  map[string]HandlerFunc
+	gateway   Gatewayer
+	listener  net.Listener
+	quit      chan struct{}
+}
+
+// Option is the argument type for creating webrpc instance.
+type Option func(*WebRPC)
+
+// New creates webrpc instance
+func New(addr string, ops ...Option) (*WebRPC, error) {
+	rpc := &WebRPC{
+		addr: addr,
+		quit: make(chan struct{}),
+	}
+
+	for _, opt := range ops {
+		opt(rpc)
+	}
+
+	rpc.handlers = make(map[string]HandlerFunc)
+	rpc.mux = http.NewServeMux()
+
+	rpc.mux.HandleFunc("/webrpc", rpc.Handler)
+
+	if err := rpc.initHandlers(); err != nil {
+		return nil, err
+	}
+
+	return rpc, nil
+}
+
+// initHandlers initialize webrpc handlers
+func (rpc *WebRPC) initHandlers() error {
+	handles := map[string]HandlerFunc{
+		// get service status
+		"get_status": getStatusHandler,
+		// get blocks by seq
+		"get_blocks_by_seq": getBlocksBySeqHandler,
+		// get last N blocks
+		"get_lastblocks": getLastBlocksHandler,
+		// get blocks in specific seq range
+		"get_blocks": getBlocksHandler,
+		// get unspent outputs of address
+		"get_outputs": getOutputsHandler,
+		// get transaction by txid
+		"get_transaction": getTransactionHandler,
+		// broadcast transaction
+		"inject_transaction": injectTransactionHandler,
+		// get address affected uxouts
+		"get_address_uxouts": getAddrUxOutsHandler,
+
+		// the bitcoind style methods of the core operations
+		// get service status
+		"getstatus": getStatusCompatHandler,
+		// get block by hash or seq
+		"getblock": getBlockCompatHandler,
+		// get raw transaction by txid, or the verbose transaction
+		"getrawtransaction": getRawTransactionHandler,
+		// broadcast raw transaction
+		"sendrawtransaction": sendRawTransactionHandler,
+		// get balance of addresses
+		"getbalance": getBalanceHandler,
+	}
+
+	// register handlers
+	for path, handle := range handles {
+		if err := rpc.HandleFunc(path, handle); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Run starts the webrpc service.
+func (rpc *WebRPC) Run() error {
+	logger.Infof("start webrpc on http://%s", rpc.addr)
+	defer logger.Info("webrpc service closed")
+
+	l, err := net.Listen("tcp", rpc.addr)
+	if err != nil {
+		return err
+	}
+
+	rpc.listener = l
+
+	errC := make(chan error, 1)
+	go func() {
+		if err := http.Serve(l, rpc); err != nil {
+			select {
+			case <-rpc.quit:
+				return
+			default:
+				// the webrpc service failed unexpectly, notify the
+				errC <- err
+			}
+		}
+	}()
+
+	return <-errC
+}
+
+// Shutdown close the webrpc service
+func (rpc *WebRPC) Shutdown() {
+	close(rpc.quit)
+	rpc.listener.Close()
+}
+
+// HandleFunc registers handler function
+func (rpc *WebRPC) HandleFunc(method string, h HandlerFunc) error {
+	if _, ok := rpc.handlers[method]; ok {
+		return fmt.Errorf("%s method already exist", method)
+	}
+
+	rpc.handlers[method] = h
+	return nil
+}
+
+// ServHTTP implements the interface of http.Handler
+func (rpc *WebRPC) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rpc.dispatch()
+	rpc.mux.ServeHTTP(w, r)
+}
+
+// Handler processes the http request
+func (rpc *WebRPC) Handler(w http.ResponseWriter, r *http.Request) {
+	var (
+		req Request
+		res Response
+	)
+
+	for {
+		// only support post.
+		if r.Method != "POST" {
+			res = makeErrorResponse(errCodeInvalidRequest, errMsgNotPost)
+			break
+		}
+
+		// deocder request.
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			res = makeErrorResponse(errCodeParseError, errMsgParseError)
+			break
+		}
+
+		if req.Jsonrpc != jsonRPC {
+			res = makeErrorResponse(errCodeInvalidParams, errMsgInvalidJsonrpc)
+			break
+		}
+
+		resC := make(chan Response)
+		rpc.ops <- func(rpc *WebRPC) {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Critical(fmt.Sprintf("%v", r))
+					resC <- makeErrorResponse(errCodeInternalError, errMsgInternalError)
+				}
+			}()
+			if handler, ok := rpc.handlers[req.Method]; ok {
+				logger.Info("method: %v", req.Method)
+				res := handler(req, rpc.gateway)
+				res.numericID = req.numericID && res.ID != nil
+				resC <- res
+				return
+			}
+			resC <- makeErrorResponse(errCodeMethodNotFound, errMsgMethodNotFound)
+		}
+		res = <-resC
+		break
+	}
+
+	wh.SendOr404(w, &res)
+}
+
+// dispatch will create numbers of goroutines, each routine will
+func (rpc *WebRPC) dispatch() {
+	for i := uint(0); i < rpc.workerNum; i++ {
+		go func(seq uint) {
+			for {
+				select {
+				case <-rpc.close:
+					// logger.Infof("[%d]rpc job handler quit", seq)
+					return
+				case op := <-rpc.ops:
+					func() {
+						defer func() {
+							if r := recover(); r != nil {
+								logger.Error("recover: %v", r)
+							}
+						}()
+
+						op(rpc)
+					}()
+				}
+			}
+		}(i)
+	}
+}
+
+// ChanBuffSize set request channel buffer size
+func ChanBuffSize(n uint) Option {
+	return func(rpc *WebRPC) {
+		rpc.ops = make(chan operation, n)
+	}
+}
+
+// ThreadNum set concurrent request processor number
+func ThreadNum(n uint) Option {
+	return func(rpc *WebRPC) {
+		rpc.workerNum = n
+	}
+}
+
+// Gateway set gateway
+func Gateway(gateway Gatewayer) Option {
+	return func(rpc *WebRPC) {
+		rpc.gateway = gateway
+	}
+}
+
+// Quit set closing channel
+func Quit(c chan struct{}) Option {
+	return func(rpc *WebRPC) {
+		rpc.close = c
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/skycoin/skycoin/src/daemon"
 	"github.com/skycoin/skycoin/src/visor"
 	"github.com/skycoin/skycoin/src/visor/historydb"
+	"github.com/skycoin/skycoin/src/wallet"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -98,6 +99,18 @@ func (fg fakeGateway) GetAddrUxOuts(addr cipher.Address) ([]*historydb.UxOutJSON
 	return nil, nil
 }
 
+func (fg fakeGateway) GetBlockByHash(hash cipher.SHA256) (coin.Block, bool) {
+	return coin.Block{}, false
+}
+
+func (fg fakeGateway) GetBlockBySeq(seq uint64) (coin.Block, bool) {
+	return coin.Block{}, false
+}
+
+func (fg fakeGateway) AddressesBalance(addrs []cipher.Address) (wallet.BalancePair, error) {
+	return wallet.BalancePair{}, nil
+}
+
 func (fg fakeGateway) GetTimeNow() uint64 {
 	return 0
 }
@@ -0,0 +1,384 @@
+// Package backup writes rotating copies of the wallet files and the blockchain db, and
+// restores them
+package backup
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/file"
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+var (
+	logger = logging.MustGetLogger("backup")
+
+	// ErrBackupNotFound is returned if the backup of the name doesn't exist
+	ErrBackupNotFound = errors.New("backup not found")
+)
+
+const (
+	// the backup name is the time it's created, with a suffix if there's one in the
+	// same second
+	nameFormat = "20060102-150405"
+	walletsDir = "wallets"
+	dbFile     = "data.db"
+	// the backup being written, it's renamed when complete
+	tmpSuffix = ".tmp"
+	// the db restored from a backup, it replaces the db when the node starts
+	restoreSuffix = ".restore"
+)
+
+// Config backup configuration
+type Config struct {
+	// Directory the backups are written to
+	Dir string
+	// How often a backup is created, 0 only creates backups on request
+	Interval time.Duration
+	// Number of the most recent backups kept
+	Keep int
+	// Directory of the wallet files
+	WalletDir string
+	// Path of the blockchain db
+	DBPath string
+}
+
+// NewConfig returns a Config with defaults set
+func NewConfig() Config {
+	return Config{
+		Interval: time.Hour * 24,
+		Keep:     7,
+	}
+}
+
+// Backup is a copy of the wallet files and the db
+type Backup struct {
+	Name string `json:"name"`
+	// unix time the backup is created
+	Time    int64    `json:"time"`
+	Wallets []string `json:"wallets"`
+	DB      bool     `json:"db"`
+	// total size of the files in bytes
+	Size int64 `json:"size"`
+}
+
+// Manager creates, rotates and restores the backups
+type Manager struct {
+	Config Config
+	// writes a consistent copy of the db
+	writeDB func(w io.Writer) error
+	sync.Mutex
+}
+
+// NewManager creates the backup directory, writeDB writes a copy of the db, the db is
+// not backed up if it's nil.
+func NewManager(c Config, writeDB func(w io.Writer) error) (*Manager, error) {
+	if c.Dir == "" {
+		return nil, errors.New("backup dir is empty")
+	}
+
+	if c.Keep < 1 {
+		return nil, errors.New("at least 1 backup must be kept")
+	}
+
+	if err := os.MkdirAll(c.Dir, 0700); err != nil {
+		return nil, err
+	}
+
+	// removes the backups not completed when the node stopped
+	entries, err := ioutil.ReadDir(c.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), tmpSuffix) {
+			if err := os.RemoveAll(filepath.Join(c.Dir, e.Name())); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &Manager{
+		Config:  c,
+		writeDB: writeDB,
+	}, nil
+}
+
+// Create writes a new backup and removes the oldest ones beyond Config.Keep
+func (m *Manager) Create() (*Backup, error) {
+	m.Lock()
+	defer m.Unlock()
+
+	b, err := m.create()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.rotate(); err != nil {
+		logger.Error("Rotate backups failed: %v", err)
+	}
+
+	logger.Info("Created backup %s, %d wallets, %d bytes", b.Name, len(b.Wallets), b.Size)
+	return b, nil
+}
+
+// List returns the backups, the most recent first
+func (m *Manager) List() ([]Backup, error) {
+	m.Lock()
+	defer m.Unlock()
+	return m.list()
+}
+
+// Restore copies the wallet files and/or the db of the backup back. The wallet files
+// replace the ones of the same names, the wallets need to be reloaded. The db can't be
+// replaced while the node is running, it's restored when the node restarts. A backup of
+// the current files is created first.
+func (m *Manager) Restore(name string, wallets, db bool) error {
+	if !wallets && !db {
+		return errors.New("nothing to restore")
+	}
+
+	m.Lock()
+	defer m.Unlock()
+
+	b, err := m.get(name)
+	if err != nil {
+		return err
+	}
+
+	if db && !b.DB {
+		return fmt.Errorf("backup %s has no db", name)
+	}
+
+	cur, err := m.create()
+	if err != nil {
+		return fmt.Errorf("backup the current files failed: %v", err)
+	}
+	logger.Info("Created backup %s before restoring %s", cur.Name, name)
+
+	dir := filepath.Join(m.Config.Dir, name)
+	if wallets {
+		for _, w := range b.Wallets {
+			data, err := ioutil.ReadFile(filepath.Join(dir, walletsDir, w))
+			if err != nil {
+				return err
+			}
+
+			if err := file.SaveBinary(filepath.Join(m.Config.WalletDir, w), data, 0600); err != nil {
+				return err
+			}
+		}
+		logger.Info("Restored %d wallets from backup %s", len(b.Wallets), name)
+	}
+
+	if db {
+		if err := copyFile(filepath.Join(dir, dbFile), m.Config.DBPath+restoreSuffix); err != nil {
+			return err
+		}
+		logger.Info("The db of backup %s will be restored when the node restarts", name)
+	}
+
+	return nil
+}
+
+// ApplyRestoredDB replaces the db with the one restored from a backup, if any. It must
+// be called before the db is opened, returns true if the db is replaced.
+func ApplyRestoredDB(dbPath string) (bool, error) {
+	path := dbPath + restoreSuffix
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return false, nil
+	}
+
+	if err := os.Rename(path, dbPath); err != nil {
+		return false, err
+	}
+
+	logger.Info("Restored db %s from backup", dbPath)
+	return true, nil
+}
+
+func (m *Manager) create() (*Backup, error) {
+	name := m.newName(time.Now())
+	tmp := filepath.Join(m.Config.Dir, name+tmpSuffix)
+
+	if err := m.write(tmp); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+
+	if err := os.Rename(tmp, filepath.Join(m.Config.Dir, name)); err != nil {
+		os.RemoveAll(tmp)
+		return nil, err
+	}
+
+	return m.get(name)
+}
+
+// write copies the wallet files and the db to dir
+func (m *Manager) write(dir string) error {
+	wltDir := filepath.Join(dir, walletsDir)
+	if err := os.MkdirAll(wltDir, 0700); err != nil {
+		return err
+	}
+
+	entries, err := ioutil.ReadDir(m.Config.WalletDir)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, e := range entries {
+		if !e.Mode().IsRegular() || !strings.HasSuffix(e.Name(), "."+wallet.WalletExt) {
+			continue
+		}
+
+		if err := copyFile(filepath.Join(m.Config.WalletDir, e.Name()), filepath.Join(wltDir, e.Name())); err != nil {
+			return err
+		}
+	}
+
+	if m.writeDB == nil {
+		return nil
+	}
+
+	f, err := os.OpenFile(filepath.Join(dir, dbFile), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if err := m.writeDB(f); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// newName returns the name of the backup created at t
+func (m *Manager) newName(t time.Time) string {
+	base := t.UTC().Format(nameFormat)
+	name := base
+	for i := 1; ; i++ {
+		if _, err := os.Stat(filepath.Join(m.Config.Dir, name)); os.IsNotExist(err) {
+			return name
+		}
+		name = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+func (m *Manager) get(name string) (*Backup, error) {
+	if len(name) < len(nameFormat) || strings.ContainsAny(name, `/\`) || strings.HasSuffix(name, tmpSuffix) {
+		return nil, ErrBackupNotFound
+	}
+
+	t, err := time.Parse(nameFormat, name[:len(nameFormat)])
+	if err != nil {
+		return nil, ErrBackupNotFound
+	}
+
+	dir := filepath.Join(m.Config.Dir, name)
+	if fi, err := os.Stat(dir); err != nil || !fi.IsDir() {
+		return nil, ErrBackupNotFound
+	}
+
+	b := &Backup{
+		Name:    name,
+		Time:    t.Unix(),
+		Wallets: []string{},
+	}
+
+	entries, err := ioutil.ReadDir(filepath.Join(dir, walletsDir))
+	if err != nil && !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	for _, e := range entries {
+		b.Wallets = append(b.Wallets, e.Name())
+		b.Size += e.Size()
+	}
+
+	if fi, err := os.Stat(filepath.Join(dir, dbFile)); err == nil {
+		b.DB = true
+		b.Size += fi.Size()
+	}
+
+	return b, nil
+}
+
+func (m *Manager) list() ([]Backup, error) {
+	entries, err := ioutil.ReadDir(m.Config.Dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// the entries are sorted by name, which is in the order of the time
+	bs := []Backup{}
+	for i := len(entries) - 1; i >= 0; i-- {
+		b, err := m.get(entries[i].Name())
+		if err == ErrBackupNotFound {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		bs = append(bs, *b)
+	}
+	return bs, nil
+}
+
+// rotate removes the oldest backups beyond Config.Keep
+func (m *Manager) rotate() error {
+	bs, err := m.list()
+	if err != nil {
+		return err
+	}
+
+	for i := m.Config.Keep; i < len(bs); i++ {
+		if err := os.RemoveAll(filepath.Join(m.Config.Dir, bs[i].Name)); err != nil {
+			return err
+		}
+		logger.Info("Removed backup %s", bs[i].Name)
+	}
+	return nil
+}
+
+// copyFile copies src to dst, dst is written to a temporary file that's renamed when
+// complete
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := dst + tmpSuffix
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		os.Remove(tmp)
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
@@ -0,0 +1,106 @@
+package backup
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager(t *testing.T) {
+	dir, err := ioutil.TempDir("", "backup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	wltDir := filepath.Join(dir, "wallets")
+	require.NoError(t, os.Mkdir(wltDir, 0700))
+	writeWallet := func(name, data string) {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(wltDir, name), []byte(data), 0600))
+	}
+	readWallet := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(wltDir, name))
+		require.NoError(t, err)
+		return string(data)
+	}
+	writeWallet("a.wlt", "a1")
+	writeWallet("b.wlt", "b1")
+	writeWallet("notes.nts", "n")
+
+	dbData := "db1"
+	c := NewConfig()
+	c.Dir = filepath.Join(dir, "backups")
+	c.Keep = 2
+	c.WalletDir = wltDir
+	c.DBPath = filepath.Join(dir, "data.db")
+
+	// the backup left unfinished is removed
+	require.NoError(t, os.MkdirAll(filepath.Join(c.Dir, "20180101-000000"+tmpSuffix), 0700))
+
+	m, err := NewManager(c, func(w io.Writer) error {
+		_, err := io.WriteString(w, dbData)
+		return err
+	})
+	require.NoError(t, err)
+
+	bs, err := m.List()
+	require.NoError(t, err)
+	assert.Empty(t, bs)
+
+	b1, err := m.Create()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a.wlt", "b.wlt"}, b1.Wallets)
+	assert.True(t, b1.DB)
+	assert.Equal(t, int64(7), b1.Size)
+
+	writeWallet("a.wlt", "a2")
+	dbData = "db2"
+	b2, err := m.Create()
+	require.NoError(t, err)
+	assert.NotEqual(t, b1.Name, b2.Name)
+
+	bs, err = m.List()
+	require.NoError(t, err)
+	require.Len(t, bs, 2)
+	assert.Equal(t, b2.Name, bs[0].Name)
+	assert.Equal(t, b1.Name, bs[1].Name)
+
+	assert.Equal(t, ErrBackupNotFound, m.Restore("20180101-000000", true, false))
+	assert.Equal(t, ErrBackupNotFound, m.Restore("../backups", true, false))
+	assert.Error(t, m.Restore(b1.Name, false, false))
+
+	// restores the wallets of the first backup, the current ones are backed up first
+	writeWallet("c.wlt", "c1")
+	require.NoError(t, m.Restore(b1.Name, true, false))
+	assert.Equal(t, "a1", readWallet("a.wlt"))
+	assert.Equal(t, "b1", readWallet("b.wlt"))
+	assert.Equal(t, "c1", readWallet("c.wlt"))
+
+	bs, err = m.List()
+	require.NoError(t, err)
+	require.Len(t, bs, 3)
+	assert.Equal(t, []string{"a.wlt", "b.wlt", "c.wlt"}, bs[0].Wallets)
+
+	// the db is restored when the node restarts
+	require.NoError(t, m.Restore(b1.Name, false, true))
+	ok, err := ApplyRestoredDB(c.DBPath)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	data, err := ioutil.ReadFile(c.DBPath)
+	require.NoError(t, err)
+	assert.Equal(t, "db1", string(data))
+
+	ok, err = ApplyRestoredDB(c.DBPath)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	// the oldest backups beyond Keep are removed
+	_, err = m.Create()
+	require.NoError(t, err)
+	bs, err = m.List()
+	require.NoError(t, err)
+	assert.Len(t, bs, 2)
+}
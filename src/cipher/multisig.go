@@ -0,0 +1,114 @@
+package cipher
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+const (
+	// MultisigAddressVersion is the version of the m-of-n multisig address
+	MultisigAddressVersion byte = 0x01
+	// MaxMultisigKeys is the most public keys of a multisig address
+	MaxMultisigKeys = 16
+)
+
+var (
+	// ErrMultisigDuplicateKey is returned if a public key is repeated in the multisig keys
+	ErrMultisigDuplicateKey = errors.New("duplicate public key of multisig address")
+	// ErrMultisigKeysNotSorted is returned if the multisig keys are not in the canonical order
+	ErrMultisigKeysNotSorted = errors.New("public keys of multisig address are not sorted")
+)
+
+// MultisigAddress creates the address spendable by m signatures of the n public keys, it
+// is RIPMD160(SHA256(SHA256(m|n|pubkeys))) of the sorted public keys, so the order of the
+// keys doesn't matter
+func MultisigAddress(m int, pubKeys []PubKey) (Address, error) {
+	keys := SortPubKeys(pubKeys)
+	if err := verifyMultisigKeys(m, keys); err != nil {
+		return Address{}, err
+	}
+
+	r1 := SumSHA256(multisigScript(m, keys))
+	r2 := SumSHA256(r1[:])
+	return Address{
+		Version: MultisigAddressVersion,
+		Key:     HashRipemd160(r2[:]),
+	}, nil
+}
+
+// IsMultisig returns true if the address is a multisig address
+func (addr Address) IsMultisig() bool {
+	return addr.Version == MultisigAddressVersion
+}
+
+// VerifyMultisig checks that the address is the multisig address of m of the public keys,
+// the keys must be sorted
+func (addr Address) VerifyMultisig(m int, pubKeys []PubKey) error {
+	if !addr.IsMultisig() {
+		return errors.New("Address version invalid")
+	}
+
+	if err := verifyMultisigKeys(m, pubKeys); err != nil {
+		return err
+	}
+
+	a, err := MultisigAddress(m, pubKeys)
+	if err != nil {
+		return err
+	}
+
+	if a != addr {
+		return errors.New("Public keys invalid for multisig address")
+	}
+	return nil
+}
+
+// SortPubKeys returns a copy of the public keys in the canonical order of the multisig
+// address
+func SortPubKeys(pubKeys []PubKey) []PubKey {
+	keys := append([]PubKey(nil), pubKeys...)
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i][:], keys[j][:]) < 0
+	})
+	return keys
+}
+
+func verifyMultisigKeys(m int, keys []PubKey) error {
+	n := len(keys)
+	if n < 1 || n > MaxMultisigKeys {
+		return fmt.Errorf("multisig address must have 1 to %d public keys", MaxMultisigKeys)
+	}
+
+	if m < 1 || m > n {
+		return fmt.Errorf("invalid required signatures %d of %d public keys", m, n)
+	}
+
+	for i, k := range keys {
+		if err := k.Verify(); err != nil {
+			return err
+		}
+
+		if i == 0 {
+			continue
+		}
+
+		switch bytes.Compare(keys[i-1][:], k[:]) {
+		case 0:
+			return ErrMultisigDuplicateKey
+		case 1:
+			return ErrMultisigKeysNotSorted
+		}
+	}
+	return nil
+}
+
+func multisigScript(m int, keys []PubKey) []byte {
+	b := make([]byte, 0, 2+len(keys)*len(PubKey{}))
+	b = append(b, byte(m), byte(len(keys)))
+	for _, k := range keys {
+		b = append(b, k[:]...)
+	}
+	return b
+}
@@ -0,0 +1,60 @@
+package cipher
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMultisigAddress(t *testing.T) {
+	var keys []PubKey
+	for i := 0; i < 3; i++ {
+		p, _ := GenerateKeyPair()
+		keys = append(keys, p)
+	}
+
+	a, err := MultisigAddress(2, keys)
+	require.NoError(t, err)
+	assert.True(t, a.IsMultisig())
+	assert.False(t, AddressFromPubKey(keys[0]).IsMultisig())
+
+	// the order of the keys doesn't change the address
+	a2, err := MultisigAddress(2, []PubKey{keys[2], keys[0], keys[1]})
+	require.NoError(t, err)
+	assert.Equal(t, a, a2)
+
+	// m is part of the address
+	a3, err := MultisigAddress(3, keys)
+	require.NoError(t, err)
+	assert.NotEqual(t, a, a3)
+
+	a4, err := DecodeBase58Address(a.String())
+	require.NoError(t, err)
+	assert.Equal(t, a, a4)
+
+	sorted := SortPubKeys(keys)
+	assert.NoError(t, a.VerifyMultisig(2, sorted))
+	assert.Error(t, a.VerifyMultisig(3, sorted))
+	assert.Error(t, a.VerifyMultisig(2, sorted[:2]))
+	assert.Error(t, AddressFromPubKey(keys[0]).VerifyMultisig(2, sorted))
+	if sorted[0] != keys[0] || sorted[1] != keys[1] {
+		assert.Equal(t, ErrMultisigKeysNotSorted, a.VerifyMultisig(2, keys))
+	}
+
+	_, err = MultisigAddress(0, keys)
+	assert.Error(t, err)
+	_, err = MultisigAddress(4, keys)
+	assert.Error(t, err)
+	_, err = MultisigAddress(1, nil)
+	assert.Error(t, err)
+	_, err = MultisigAddress(2, []PubKey{keys[0], keys[0]})
+	assert.Equal(t, ErrMultisigDuplicateKey, err)
+
+	many := make([]PubKey, MaxMultisigKeys+1)
+	for i := range many {
+		many[i], _ = GenerateKeyPair()
+	}
+	_, err = MultisigAddress(1, many)
+	assert.Error(t, err)
+}
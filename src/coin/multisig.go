@@ -0,0 +1,192 @@
+package coin
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+/*
+The witnesses of the inputs are stored in Sigs in the order of the inputs, so the
+transaction format is not changed.
+
+- the witness of a single key input is its signature
+- the witness of a multisig input is a header, followed by the n sorted public keys of the
+  address, and then the m signatures in the order of the signers' public keys
+
+The header is m and n in the first 2 bytes, and multisigMarker in the last byte where a
+signature has the recovery id, the rest is zero. Each public key takes a Sig, the last 32
+bytes are zero.
+*/
+
+// multisigMarker is the last byte of the multisig header, the recovery id of a signature
+// is at most 3, so the header can't be mistaken for a signature
+const multisigMarker byte = 0xff
+
+// ErrInvalidWitness is returned if the witnesses can't be decoded from the Sigs
+var ErrInvalidWitness = errors.New("Invalid witness of input")
+
+// InputWitness authorizes spending an input, it's the signature of the single key
+// address, or the signatures and the public keys of the multisig address
+type InputWitness struct {
+	Sig      cipher.Sig
+	Multisig *MultisigWitness
+}
+
+// MultisigWitness is the witness of a multisig input, Sigs are Required signatures of the
+// PubKeys, in the order of the signers' public keys
+type MultisigWitness struct {
+	Required int
+	// sorted by cipher.SortPubKeys
+	PubKeys []cipher.PubKey
+	Sigs    []cipher.Sig
+}
+
+// SigHash returns the hash signed to spend the input i, the signatures don't change it
+func (txn *Transaction) SigHash(i int) cipher.SHA256 {
+	return cipher.AddSHA256(txn.InnerHash, txn.In[i])
+}
+
+// SetWitnesses stores the witnesses of the inputs in Sigs
+func (txn *Transaction) SetWitnesses(ws []InputWitness) error {
+	if len(ws) != len(txn.In) {
+		return errors.New("Invalid number of witnesses")
+	}
+
+	sigs := make([]cipher.Sig, 0, len(ws))
+	for i, w := range ws {
+		if w.Multisig == nil {
+			sigs = append(sigs, w.Sig)
+			continue
+		}
+
+		ms := w.Multisig
+		n := len(ms.PubKeys)
+		if n < 1 || n > cipher.MaxMultisigKeys || ms.Required < 1 || ms.Required > n {
+			return fmt.Errorf("Invalid multisig witness of input %d", i)
+		}
+
+		if len(ms.Sigs) != ms.Required {
+			return fmt.Errorf("Multisig input %d needs %d signatures, has %d", i, ms.Required, len(ms.Sigs))
+		}
+
+		var header cipher.Sig
+		header[0] = byte(ms.Required)
+		header[1] = byte(n)
+		header[len(header)-1] = multisigMarker
+		sigs = append(sigs, header)
+
+		for _, k := range ms.PubKeys {
+			var s cipher.Sig
+			copy(s[:], k[:])
+			sigs = append(sigs, s)
+		}
+		sigs = append(sigs, ms.Sigs...)
+	}
+
+	txn.Sigs = sigs
+	return nil
+}
+
+// Witnesses decodes the witnesses of the inputs from Sigs
+func (txn Transaction) Witnesses() ([]InputWitness, error) {
+	ws := make([]InputWitness, 0, len(txn.In))
+	for i := 0; i < len(txn.Sigs); i++ {
+		s := txn.Sigs[i]
+		if s[len(s)-1] != multisigMarker {
+			ws = append(ws, InputWitness{Sig: s})
+			continue
+		}
+
+		m, n := int(s[0]), int(s[1])
+		if !isZero(s[2:len(s)-1]) || n < 1 || n > cipher.MaxMultisigKeys || m < 1 || m > n {
+			return nil, ErrInvalidWitness
+		}
+
+		if i+n+m >= len(txn.Sigs) {
+			return nil, ErrInvalidWitness
+		}
+
+		ms := &MultisigWitness{
+			Required: m,
+			PubKeys:  make([]cipher.PubKey, n),
+			Sigs:     make([]cipher.Sig, m),
+		}
+		for j := range ms.PubKeys {
+			k := txn.Sigs[i+1+j]
+			if !isZero(k[len(cipher.PubKey{}):]) {
+				return nil, ErrInvalidWitness
+			}
+			copy(ms.PubKeys[j][:], k[:])
+		}
+		copy(ms.Sigs, txn.Sigs[i+1+n:i+1+n+m])
+
+		ws = append(ws, InputWitness{Multisig: ms})
+		i += n + m
+	}
+
+	if len(ws) != len(txn.In) {
+		return nil, errors.New("Invalid number of signatures")
+	}
+	return ws, nil
+}
+
+// Verify checks that the signatures are valid for the hash, it can't check that they
+// authorize spending the address without the address
+func (w InputWitness) Verify(hash cipher.SHA256) error {
+	if w.Multisig == nil {
+		return cipher.VerifySignedHash(w.Sig, hash)
+	}
+	return w.Multisig.verifySigs(hash)
+}
+
+// VerifyAddress checks that the witness authorizes spending the address
+func (w InputWitness) VerifyAddress(addr cipher.Address, hash cipher.SHA256) error {
+	if w.Multisig == nil {
+		return cipher.ChkSig(addr, hash, w.Sig)
+	}
+
+	if err := addr.VerifyMultisig(w.Multisig.Required, w.Multisig.PubKeys); err != nil {
+		return err
+	}
+	return w.Multisig.verifySigs(hash)
+}
+
+// verifySigs checks that the signatures are signed by different public keys, in the order
+// of the keys
+func (ms MultisigWitness) verifySigs(hash cipher.SHA256) error {
+	if len(ms.Sigs) != ms.Required {
+		return ErrInvalidWitness
+	}
+
+	next := 0
+	for _, sig := range ms.Sigs {
+		if err := cipher.VerifySignedHash(sig, hash); err != nil {
+			return err
+		}
+
+		pub, err := cipher.PubKeyFromSig(sig, hash)
+		if err != nil {
+			return err
+		}
+
+		for next < len(ms.PubKeys) && ms.PubKeys[next] != pub {
+			next++
+		}
+		if next == len(ms.PubKeys) {
+			return errors.New("Signature of multisig input is not signed by its public keys in order")
+		}
+		next++
+	}
+	return nil
+}
+
+func isZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,97 @@
+package coin
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestTransactionMultisigWitness(t *testing.T) {
+	var pubs []cipher.PubKey
+	secs := make(map[cipher.PubKey]cipher.SecKey)
+	for i := 0; i < 3; i++ {
+		p, s := cipher.GenerateKeyPair()
+		pubs = append(pubs, p)
+		secs[p] = s
+	}
+	pubs = cipher.SortPubKeys(pubs)
+	msAddr, err := cipher.MultisigAddress(2, pubs)
+	require.NoError(t, err)
+
+	p, s := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(p)
+
+	uxs := UxArray{
+		{Body: UxBody{SrcTransaction: cipher.SumSHA256([]byte("a")), Address: addr, Coins: 1e6, Hours: 10}},
+		{Body: UxBody{SrcTransaction: cipher.SumSHA256([]byte("b")), Address: msAddr, Coins: 2e6, Hours: 10}},
+	}
+
+	txn := Transaction{}
+	for _, ux := range uxs {
+		txn.PushInput(ux.Hash())
+	}
+	txn.PushOutput(makeAddress(), 3e6, 10)
+	txn.UpdateHeader()
+
+	sign := func(k cipher.PubKey) cipher.Sig {
+		return cipher.SignHash(txn.SigHash(1), secs[k])
+	}
+	ws := []InputWitness{
+		{Sig: cipher.SignHash(txn.SigHash(0), s)},
+		{Multisig: &MultisigWitness{
+			Required: 2,
+			PubKeys:  pubs,
+			Sigs:     []cipher.Sig{sign(pubs[0]), sign(pubs[2])},
+		}},
+	}
+	require.NoError(t, txn.SetWitnesses(ws))
+	assert.Len(t, txn.Sigs, 1+1+3+2)
+	txn.UpdateHeader()
+	require.NoError(t, txn.Verify())
+	require.NoError(t, txn.VerifyInput(uxs))
+
+	// round trip through the serialization
+	txn2 := TransactionDeserialize(txn.Serialize())
+	ws2, err := txn2.Witnesses()
+	require.NoError(t, err)
+	assert.Equal(t, ws, ws2)
+
+	// signatures out of the order of the keys
+	bad := *ws[1].Multisig
+	bad.Sigs = []cipher.Sig{sign(pubs[2]), sign(pubs[0])}
+	assert.Error(t, InputWitness{Multisig: &bad}.VerifyAddress(msAddr, txn.SigHash(1)))
+
+	// the same key twice
+	bad.Sigs = []cipher.Sig{sign(pubs[0]), sign(pubs[0])}
+	assert.Error(t, InputWitness{Multisig: &bad}.VerifyAddress(msAddr, txn.SigHash(1)))
+
+	// not enough signatures
+	bad.Sigs = []cipher.Sig{sign(pubs[0])}
+	assert.Error(t, InputWitness{Multisig: &bad}.VerifyAddress(msAddr, txn.SigHash(1)))
+	assert.Error(t, txn.SetWitnesses([]InputWitness{ws[0], {Multisig: &bad}}))
+
+	// keys of another address
+	other := *ws[1].Multisig
+	other.Required = 1
+	other.Sigs = other.Sigs[:1]
+	assert.Error(t, InputWitness{Multisig: &other}.VerifyAddress(msAddr, txn.SigHash(1)))
+
+	// the multisig address can't be spent by a single signature
+	single := InputWitness{Sig: sign(pubs[0])}
+	assert.Error(t, single.VerifyAddress(msAddr, txn.SigHash(1)))
+
+	// truncated witness
+	txn3 := txn
+	txn3.Sigs = txn.Sigs[:len(txn.Sigs)-1]
+	_, err = txn3.Witnesses()
+	assert.Equal(t, ErrInvalidWitness, err)
+
+	// malformed header
+	txn3.Sigs = append([]cipher.Sig(nil), txn.Sigs...)
+	txn3.Sigs[1][0] = 4
+	_, err = txn3.Witnesses()
+	assert.Equal(t, ErrInvalidWitness, err)
+}
@@ -0,0 +1,389 @@
+package daemon
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+var (
+	// ErrHeaderNotConnected the header doesn't follow the last verified header, the peer is on
+	// another branch
+	ErrHeaderNotConnected = errors.New("header is not connected to the verified headers")
+	// ErrHeaderInvalidSignature the header isn't signed by the blockchain key
+	ErrHeaderInvalidSignature = errors.New("header signature is invalid")
+)
+
+// SignedHeader is the header of a signed block, the signature signs the hash of the header so
+// the header is verified without the body
+type SignedHeader struct {
+	Head coin.BlockHeader
+	Sig  cipher.Sig
+}
+
+// blockRequest is a range of blocks requested from a peer
+type blockRequest struct {
+	Addr string
+	// the first seq of the range
+	Start       uint64
+	Count       uint64
+	RequestedAt time.Time
+}
+
+// BlockSync downloads the headers ahead of the bodies. The headers are verified by the signature
+// and the hash of the previous header, then the bodies are downloaded in ranges from the peers in
+// parallel and applied in order. It's only accessed in the daemon loop.
+type BlockSync struct {
+	pubkey cipher.PubKey
+	// blocks per range requested
+	rangeSize uint64
+	// how far ahead of the head of the chain the bodies are downloaded
+	window uint64
+	// ranges in flight per peer
+	perPeer int
+	// how long a peer has to answer a range before it's reassigned
+	timeout time.Duration
+
+	// the last verified header, or the head of the chain if no header is ahead of it
+	lastSeq  uint64
+	lastHash cipher.SHA256
+	// the verified headers ahead of the head of the chain by seq
+	headers map[uint64]SignedHeader
+	// the bodies received ahead of the head of the chain by seq
+	blocks map[uint64]coin.SignedBlock
+	// the ranges in flight by their first seq
+	requests map[uint64]blockRequest
+	// the download stats of the peers
+	peers map[string]*peerSyncStats
+	// the peers assigned the ranges before the others, e.g. the trusted peers
+	preferred map[string]bool
+}
+
+// peerSyncStats are the download stats of a peer
+type peerSyncStats struct {
+	// blocks received in the ranges requested
+	Blocks uint64
+	// time the answered ranges took
+	Elapsed time.Duration
+	// ranges not answered in time
+	Stalls int
+	// no range is assigned to the peer until then after a stall
+	StalledUntil time.Time
+}
+
+// PeerSyncStatus is the download throughput of a peer
+type PeerSyncStatus struct {
+	Addr            string  `json:"addr"`
+	Blocks          uint64  `json:"blocks"`
+	BlocksPerSecond float64 `json:"blocks_per_second"`
+	InFlight        int     `json:"in_flight"`
+	Stalls          int     `json:"stalls"`
+	Stalled         bool    `json:"stalled"`
+	Preferred       bool    `json:"preferred"`
+}
+
+// NewBlockSync creates BlockSync
+func NewBlockSync(pubkey cipher.PubKey, rangeSize, window uint64, perPeer int,
+	timeout time.Duration) *BlockSync {
+	return &BlockSync{
+		pubkey:    pubkey,
+		rangeSize: rangeSize,
+		window:    window,
+		perPeer:   perPeer,
+		timeout:   timeout,
+		headers:   make(map[uint64]SignedHeader),
+		blocks:    make(map[uint64]coin.SignedBlock),
+		requests:  make(map[uint64]blockRequest),
+		peers:     make(map[string]*peerSyncStats),
+		preferred: make(map[string]bool),
+	}
+}
+
+// SetPreferred assigns the ranges to the peer before the peers not preferred
+func (bs *BlockSync) SetPreferred(addr string) {
+	bs.preferred[addr] = true
+}
+
+// LastSeq returns the seq of the last verified header
+func (bs *BlockSync) LastSeq() uint64 {
+	return bs.lastSeq
+}
+
+// Active returns whether verified headers are ahead of the head of the chain
+func (bs *BlockSync) Active(headSeq uint64) bool {
+	return bs.lastSeq > headSeq && len(bs.headers) > 0
+}
+
+// SetHead prunes the headers and the bodies up to the head of the chain. If the head passed
+// the verified headers, e.g. the blocks came from a peer not serving the headers, or the head is
+// on another branch, the headers continue from the head.
+func (bs *BlockSync) SetHead(seq uint64, hash cipher.SHA256) {
+	if h, ok := bs.headers[seq]; seq >= bs.lastSeq || (ok && h.Head.Hash() != hash) {
+		bs.lastSeq = seq
+		bs.lastHash = hash
+		bs.headers = make(map[uint64]SignedHeader)
+		bs.blocks = make(map[uint64]coin.SignedBlock)
+		bs.requests = make(map[uint64]blockRequest)
+		return
+	}
+
+	for s := range bs.headers {
+		if s <= seq {
+			delete(bs.headers, s)
+		}
+	}
+	for s := range bs.blocks {
+		if s <= seq {
+			delete(bs.blocks, s)
+		}
+	}
+	for s, r := range bs.requests {
+		if s+r.Count-1 <= seq {
+			delete(bs.requests, s)
+		}
+	}
+}
+
+// AddHeaders verifies the headers and appends them to the verified headers, the headers already
+// verified are skipped. Returns the number of the headers added.
+func (bs *BlockSync) AddHeaders(headers []SignedHeader) (int, error) {
+	n := 0
+	for _, h := range headers {
+		seq := h.Head.BkSeq
+		if seq <= bs.lastSeq {
+			if known, ok := bs.headers[seq]; ok && known.Head.Hash() != h.Head.Hash() {
+				return n, ErrHeaderNotConnected
+			}
+			continue
+		}
+
+		if seq != bs.lastSeq+1 || h.Head.PrevHash != bs.lastHash {
+			return n, ErrHeaderNotConnected
+		}
+
+		hash := h.Head.Hash()
+		if err := cipher.VerifySignature(bs.pubkey, h.Sig, hash); err != nil {
+			return n, ErrHeaderInvalidSignature
+		}
+
+		bs.headers[seq] = h
+		bs.lastSeq = seq
+		bs.lastHash = hash
+		n++
+	}
+	return n, nil
+}
+
+// Schedule returns the ranges of the bodies to request from the peers, the ranges neither
+// received nor in flight within the window ahead of the head are assigned to the preferred
+// peers with the fewest ranges in flight, then to the other peers, the peers that stalled
+// recently are skipped
+func (bs *BlockSync) Schedule(peers []string, headSeq uint64, now time.Time) []blockRequest {
+	if len(peers) == 0 || !bs.Active(headSeq) {
+		return nil
+	}
+
+	inFlight := make(map[string]int)
+	for _, r := range bs.requests {
+		inFlight[r.Addr]++
+	}
+
+	end := bs.lastSeq
+	if bs.window > 0 && headSeq+bs.window < end {
+		end = headSeq + bs.window
+	}
+
+	var reqs []blockRequest
+	for seq := headSeq + 1; seq <= end; {
+		if bs.pending(seq) {
+			seq++
+			continue
+		}
+
+		// the preferred peer with the fewest ranges in flight, in the order of the peers on a tie
+		addr := ""
+		for _, p := range peers {
+			if st, ok := bs.peers[p]; ok && now.Before(st.StalledUntil) {
+				continue
+			}
+			if inFlight[p] >= bs.perPeer {
+				continue
+			}
+			if addr == "" || (bs.preferred[p] && !bs.preferred[addr]) ||
+				(bs.preferred[p] == bs.preferred[addr] && inFlight[p] < inFlight[addr]) {
+				addr = p
+			}
+		}
+		if addr == "" {
+			break
+		}
+
+		// the range ends before the next seq received or in flight
+		count := uint64(1)
+		for count < bs.rangeSize && seq+count <= end && !bs.pending(seq+count) {
+			count++
+		}
+
+		r := blockRequest{
+			Addr:        addr,
+			Start:       seq,
+			Count:       count,
+			RequestedAt: now,
+		}
+		bs.requests[seq] = r
+		inFlight[addr]++
+		reqs = append(reqs, r)
+		seq += count
+	}
+
+	return reqs
+}
+
+// pending returns whether the body of seq is received or in flight
+func (bs *BlockSync) pending(seq uint64) bool {
+	if _, ok := bs.blocks[seq]; ok {
+		return true
+	}
+	for s, r := range bs.requests {
+		if seq >= s && seq < s+r.Count {
+			return true
+		}
+	}
+	return false
+}
+
+// AddBlocks keeps the blocks matching the verified headers and completes the range of the peer
+// they answer, the time the range took counts in the throughput of the peer. Returns the blocks
+// not matching the verified headers.
+func (bs *BlockSync) AddBlocks(addr string, blocks []coin.SignedBlock, now time.Time) []coin.SignedBlock {
+	if len(blocks) == 0 {
+		return blocks
+	}
+
+	// the missing blocks of a short answer are requested again
+	start := blocks[0].Block.Head.BkSeq
+	if r, ok := bs.requests[start]; ok && r.Addr == addr {
+		delete(bs.requests, start)
+
+		st := bs.peerStats(addr)
+		st.Blocks += uint64(len(blocks))
+		st.Elapsed += now.Sub(r.RequestedAt)
+	}
+
+	var rest []coin.SignedBlock
+	for _, b := range blocks {
+		h, ok := bs.headers[b.Block.Head.BkSeq]
+		if !ok || h.Head.Hash() != b.Block.HashHeader() {
+			rest = append(rest, b)
+			continue
+		}
+		bs.blocks[b.Block.Head.BkSeq] = b
+	}
+	return rest
+}
+
+// Next returns the body following the head of the chain if it's received
+func (bs *BlockSync) Next(headSeq uint64) (coin.SignedBlock, bool) {
+	b, ok := bs.blocks[headSeq+1]
+	return b, ok
+}
+
+// DropBlock forgets the body of seq that failed to execute, it's requested again
+func (bs *BlockSync) DropBlock(seq uint64) {
+	delete(bs.blocks, seq)
+}
+
+// RemovePeer forgets the ranges in flight and the stats of the peer, the ranges are assigned to
+// the other peers
+func (bs *BlockSync) RemovePeer(addr string) {
+	bs.removeRequests(addr)
+	delete(bs.peers, addr)
+	delete(bs.preferred, addr)
+}
+
+func (bs *BlockSync) removeRequests(addr string) {
+	for s, r := range bs.requests {
+		if r.Addr == addr {
+			delete(bs.requests, s)
+		}
+	}
+}
+
+func (bs *BlockSync) peerStats(addr string) *peerSyncStats {
+	st, ok := bs.peers[addr]
+	if !ok {
+		st = &peerSyncStats{}
+		bs.peers[addr] = st
+	}
+	return st
+}
+
+// CheckStalls reassigns the ranges not answered within the timeout. All the ranges of a
+// stalled peer are reassigned, and no range is assigned to it for the timeout. Returns the
+// stalled peers.
+func (bs *BlockSync) CheckStalls(now time.Time) []string {
+	var stalled []string
+	for _, r := range bs.requests {
+		if now.Sub(r.RequestedAt) < bs.timeout {
+			continue
+		}
+		st := bs.peerStats(r.Addr)
+		if now.Before(st.StalledUntil) {
+			// another range of the peer stalled
+			continue
+		}
+		st.Stalls++
+		st.StalledUntil = now.Add(bs.timeout)
+		stalled = append(stalled, r.Addr)
+	}
+
+	for _, addr := range stalled {
+		bs.removeRequests(addr)
+	}
+	sort.Strings(stalled)
+	return stalled
+}
+
+// Requests returns the ranges in flight ordered by the first seq
+func (bs *BlockSync) Requests() []blockRequest {
+	reqs := make([]blockRequest, 0, len(bs.requests))
+	for _, r := range bs.requests {
+		reqs = append(reqs, r)
+	}
+	sort.Slice(reqs, func(i, j int) bool {
+		return reqs[i].Start < reqs[j].Start
+	})
+	return reqs
+}
+
+// Stats returns the download throughput of the peers ordered by the address
+func (bs *BlockSync) Stats(now time.Time) []PeerSyncStatus {
+	inFlight := make(map[string]int)
+	for _, r := range bs.requests {
+		inFlight[r.Addr]++
+		bs.peerStats(r.Addr)
+	}
+
+	stats := make([]PeerSyncStatus, 0, len(bs.peers))
+	for addr, st := range bs.peers {
+		s := PeerSyncStatus{
+			Addr:      addr,
+			Blocks:    st.Blocks,
+			InFlight:  inFlight[addr],
+			Stalls:    st.Stalls,
+			Stalled:   now.Before(st.StalledUntil),
+			Preferred: bs.preferred[addr],
+		}
+		if st.Elapsed > 0 {
+			s.BlocksPerSecond = float64(st.Blocks) / st.Elapsed.Seconds()
+		}
+		stats = append(stats, s)
+	}
+	sort.Slice(stats, func(i, j int) bool {
+		return stats[i].Addr < stats[j].Addr
+	})
+	return stats
+}
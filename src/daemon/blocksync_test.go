@@ -0,0 +1,203 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// makeSignedHeaders makes n signed headers following the header of prev
+func makeSignedHeaders(sk cipher.SecKey, prev coin.BlockHeader, n int) []SignedHeader {
+	headers := make([]SignedHeader, n)
+	for i := range headers {
+		h := coin.BlockHeader{
+			Time:     prev.Time + 10,
+			BkSeq:    prev.BkSeq + 1,
+			PrevHash: prev.Hash(),
+		}
+		headers[i] = SignedHeader{
+			Head: h,
+			Sig:  cipher.SignHash(h.Hash(), sk),
+		}
+		prev = h
+	}
+	return headers
+}
+
+func signedBlock(h SignedHeader) coin.SignedBlock {
+	return coin.SignedBlock{
+		Block: coin.Block{Head: h.Head},
+		Sig:   h.Sig,
+	}
+}
+
+func TestBlockSyncAddHeaders(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	genesis := coin.BlockHeader{Time: 100}
+	bs := NewBlockSync(pk, 2, 0, 1, time.Minute)
+	bs.SetHead(0, genesis.Hash())
+
+	headers := makeSignedHeaders(sk, genesis, 5)
+	n, err := bs.AddHeaders(headers[:3])
+	require.NoError(t, err)
+	require.Equal(t, 3, n)
+	require.Equal(t, uint64(3), bs.LastSeq())
+	require.True(t, bs.Active(0))
+
+	// the known headers are skipped
+	n, err = bs.AddHeaders(headers)
+	require.NoError(t, err)
+	require.Equal(t, 2, n)
+	require.Equal(t, uint64(5), bs.LastSeq())
+
+	// a header not following the last header
+	_, err = bs.AddHeaders(makeSignedHeaders(sk, genesis, 7)[6:])
+	require.Equal(t, ErrHeaderNotConnected, err)
+
+	// a header signed by another key
+	_, sk2 := cipher.GenerateKeyPair()
+	_, err = bs.AddHeaders(makeSignedHeaders(sk2, headers[4].Head, 1))
+	require.Equal(t, ErrHeaderInvalidSignature, err)
+	require.Equal(t, uint64(5), bs.LastSeq())
+}
+
+func TestBlockSyncSchedule(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	genesis := coin.BlockHeader{Time: 100}
+	bs := NewBlockSync(pk, 2, 6, 1, time.Minute)
+	bs.SetHead(0, genesis.Hash())
+
+	headers := makeSignedHeaders(sk, genesis, 8)
+	_, err := bs.AddHeaders(headers)
+	require.NoError(t, err)
+
+	now := time.Now()
+	require.Empty(t, bs.Schedule(nil, 0, now))
+
+	// the ranges are spread over the peers, one range in flight per peer
+	reqs := bs.Schedule([]string{"a", "b"}, 0, now)
+	require.Equal(t, []blockRequest{
+		{Addr: "a", Start: 1, Count: 2, RequestedAt: now},
+		{Addr: "b", Start: 3, Count: 2, RequestedAt: now},
+	}, reqs)
+	require.Empty(t, bs.Schedule([]string{"a", "b"}, 0, now))
+
+	// the blocks arrive out of order, the rest of the short answer is requested again
+	rest := bs.AddBlocks("b", []coin.SignedBlock{signedBlock(headers[2])}, now)
+	require.Empty(t, rest)
+	_, ok := bs.Next(0)
+	require.False(t, ok)
+
+	reqs = bs.Schedule([]string{"a", "b"}, 0, now)
+	require.Equal(t, []blockRequest{
+		{Addr: "b", Start: 4, Count: 2, RequestedAt: now},
+	}, reqs)
+
+	// the blocks not matching the headers are returned
+	other := makeSignedHeaders(sk, coin.BlockHeader{Time: 1}, 1)[0]
+	rest = bs.AddBlocks("a", []coin.SignedBlock{signedBlock(headers[0]), signedBlock(other)}, now)
+	require.Equal(t, []coin.SignedBlock{signedBlock(other)}, rest)
+
+	b, ok := bs.Next(0)
+	require.True(t, ok)
+	require.Equal(t, uint64(1), b.Block.Head.BkSeq)
+	bs.SetHead(1, headers[0].Head.Hash())
+
+	// the window ends at the head + 6, the range of the disconnected peer is reassigned
+	bs.RemovePeer("b")
+	reqs = bs.Schedule([]string{"a", "c"}, 1, now)
+	require.Equal(t, []blockRequest{
+		{Addr: "a", Start: 2, Count: 1, RequestedAt: now},
+		{Addr: "c", Start: 4, Count: 2, RequestedAt: now},
+	}, reqs)
+
+	// the head passed the headers
+	bs.SetHead(8, headers[7].Head.Hash())
+	require.False(t, bs.Active(8))
+	require.Empty(t, bs.Schedule([]string{"a", "c"}, 8, now))
+}
+
+func TestBlockSyncCheckStalls(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	genesis := coin.BlockHeader{Time: 100}
+	bs := NewBlockSync(pk, 2, 0, 2, time.Minute)
+	bs.SetHead(0, genesis.Hash())
+
+	headers := makeSignedHeaders(sk, genesis, 6)
+	_, err := bs.AddHeaders(headers)
+	require.NoError(t, err)
+
+	now := time.Now()
+	reqs := bs.Schedule([]string{"a", "b"}, 0, now)
+	require.Len(t, reqs, 3)
+	require.Empty(t, bs.CheckStalls(now.Add(time.Second*59)))
+
+	// b answers in 2 seconds, a doesn't answer in time
+	bs.AddBlocks("b", []coin.SignedBlock{signedBlock(headers[2]), signedBlock(headers[3])},
+		now.Add(time.Second*2))
+	now = now.Add(time.Minute)
+	require.Equal(t, []string{"a"}, bs.CheckStalls(now))
+
+	// the ranges of a are requested from b
+	reqs = bs.Schedule([]string{"a", "b"}, 0, now)
+	require.Equal(t, []blockRequest{
+		{Addr: "b", Start: 1, Count: 2, RequestedAt: now},
+		{Addr: "b", Start: 5, Count: 2, RequestedAt: now},
+	}, reqs)
+
+	require.Equal(t, []PeerSyncStatus{
+		{Addr: "a", Stalls: 1, Stalled: true},
+		{Addr: "b", Blocks: 2, BlocksPerSecond: 1, InFlight: 2},
+	}, bs.Stats(now))
+	require.Equal(t, uint64(1), bs.Requests()[0].Start)
+
+	// a is assigned ranges again after the timeout
+	bs.RemovePeer("b")
+	now = now.Add(time.Minute)
+	reqs = bs.Schedule([]string{"a"}, 0, now)
+	require.Len(t, reqs, 2)
+	require.Equal(t, "a", reqs[0].Addr)
+}
+
+func TestBlockSyncSchedulePreferred(t *testing.T) {
+	pk, sk := cipher.GenerateKeyPair()
+	genesis := coin.BlockHeader{Time: 100}
+	bs := NewBlockSync(pk, 2, 0, 2, time.Minute)
+	bs.SetHead(0, genesis.Hash())
+
+	headers := makeSignedHeaders(sk, genesis, 10)
+	_, err := bs.AddHeaders(headers)
+	require.NoError(t, err)
+
+	// the preferred peer is assigned its ranges first, the rest go to the other peers
+	bs.SetPreferred("b")
+	now := time.Now()
+	reqs := bs.Schedule([]string{"a", "b"}, 0, now)
+	require.Equal(t, []blockRequest{
+		{Addr: "b", Start: 1, Count: 2, RequestedAt: now},
+		{Addr: "b", Start: 3, Count: 2, RequestedAt: now},
+		{Addr: "a", Start: 5, Count: 2, RequestedAt: now},
+		{Addr: "a", Start: 7, Count: 2, RequestedAt: now},
+	}, reqs)
+
+	stats := bs.Stats(now)
+	require.Len(t, stats, 2)
+	require.False(t, stats[0].Preferred)
+	require.True(t, stats[1].Preferred)
+
+	// a stalled preferred peer is skipped
+	now = now.Add(time.Minute)
+	require.Equal(t, []string{"a", "b"}, bs.CheckStalls(now))
+	bs.RemovePeer("a")
+	reqs = bs.Schedule([]string{"b", "c"}, 0, now)
+	require.Len(t, reqs, 2)
+	require.Equal(t, "c", reqs[0].Addr)
+
+	// the preference is forgotten with the peer
+	bs.RemovePeer("b")
+	require.False(t, bs.preferred["b"])
+}
@@ -0,0 +1,116 @@
+package daemon
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/skycoin/skycoin/src/daemon/gnet"
+)
+
+const (
+	// CompressionDeflate the message is compressed by DEFLATE
+	CompressionDeflate uint8 = 1
+)
+
+// ErrDecompressedTooLarge the compressed message decompresses to more than the max message length
+var ErrDecompressedTooLarge = errors.New("decompressed message is too large")
+
+// compressData compresses the data by DEFLATE
+func compressData(data []byte) ([]byte, error) {
+	var b bytes.Buffer
+	w, err := flate.NewWriter(&b, flate.DefaultCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// decompressData decompresses the data of the algorithm, the decompressed data is limited to max
+// bytes so a small message can't decompress to a huge one
+func decompressData(algorithm uint8, data []byte, max int) ([]byte, error) {
+	if algorithm != CompressionDeflate {
+		return nil, fmt.Errorf("unknown compression algorithm %d", algorithm)
+	}
+
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	b, err := ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > max {
+		return nil, ErrDecompressedTooLarge
+	}
+	return b, nil
+}
+
+// CompressedMessage carries a compressed message, it's sent to the peers that negotiated
+// FeatureCompression in place of the large messages
+type CompressedMessage struct {
+	Algorithm uint8
+	// the message encoded by gnet.EncodeMessage and compressed
+	Data []byte
+}
+
+// Handle decompresses the message and handles it as if it was received uncompressed
+func (cm *CompressedMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	d := daemon.(*Daemon)
+
+	data, err := decompressData(cm.Algorithm, cm.Data, d.Pool.Pool.Config.MaxMessageLength)
+	if err == nil {
+		var m gnet.Message
+		m, err = gnet.DecodeMessage(data)
+		if err == nil {
+			if _, nested := m.(*CompressedMessage); !nested {
+				return m.Handle(mc, daemon)
+			}
+			err = errors.New("nested compressed message")
+		}
+	}
+
+	logger.Info("Invalid compressed message from %s: %v", mc.Addr, err)
+	d.Pool.Pool.Disconnect(mc.Addr, ErrDisconnectMalformedMessage)
+	return ErrDisconnectMalformedMessage
+}
+
+// sendMessage sends the message to the peer, compressed if the peer negotiated FeatureCompression
+// and the message is large
+func (dm *Daemon) sendMessage(addr string, msg gnet.Message) error {
+	if !dm.peerHasFeature(addr, FeatureCompression) || dm.Config.CompressionMinSize <= 0 {
+		return dm.Pool.Pool.SendMessage(addr, msg)
+	}
+
+	data := gnet.EncodeMessage(msg)
+	if len(data) < dm.Config.CompressionMinSize {
+		return dm.Pool.Pool.SendMessage(addr, msg)
+	}
+
+	compressed, err := compressData(data)
+	if err != nil {
+		logger.Error("Compress message failed: %v", err)
+		return dm.Pool.Pool.SendMessage(addr, msg)
+	}
+	if len(compressed) >= len(data) {
+		return dm.Pool.Pool.SendMessage(addr, msg)
+	}
+
+	compressionInCounter.Add(float64(len(data)))
+	compressionOutCounter.Add(float64(len(compressed)))
+	compressionRatioGauge.Set(compressionOutCounter.Value() / compressionInCounter.Value())
+	return dm.Pool.Pool.SendMessage(addr, &CompressedMessage{
+		Algorithm: CompressionDeflate,
+		Data:      compressed,
+	})
+}
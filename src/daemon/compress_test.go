@@ -0,0 +1,30 @@
+package daemon
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompressData(t *testing.T) {
+	data := bytes.Repeat([]byte("suncoin block "), 1000)
+
+	compressed, err := compressData(data)
+	require.NoError(t, err)
+	require.True(t, len(compressed) < len(data))
+
+	decompressed, err := decompressData(CompressionDeflate, compressed, len(data))
+	require.NoError(t, err)
+	require.Equal(t, data, decompressed)
+
+	// the decompressed data is limited
+	_, err = decompressData(CompressionDeflate, compressed, len(data)-1)
+	require.Equal(t, ErrDecompressedTooLarge, err)
+
+	_, err = decompressData(2, compressed, len(data))
+	require.Error(t, err)
+
+	_, err = decompressData(CompressionDeflate, data[:100], len(data))
+	require.Error(t, err)
+}
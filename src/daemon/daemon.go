@@ -0,0 +1,1326 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"reflect"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skycoin/src/backup"
+	"github.com/skycoin/skycoin/src/daemon/dnsseed"
+	"github.com/skycoin/skycoin/src/daemon/gnet"
+	"github.com/skycoin/skycoin/src/daemon/pex"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+	"github.com/skycoin/skycoin/src/util/metrics"
+	"github.com/skycoin/skycoin/src/util/nat"
+	"github.com/skycoin/skycoin/src/util/noise"
+	"github.com/skycoin/skycoin/src/util/socks5"
+	"github.com/skycoin/skycoin/src/util/utc"
+)
+
+/*
+Todo
+- verify that minimum/maximum connections are working
+- keep max connections
+- maintain minimum number of outgoing connections per server?
+
+
+*/
+var (
+	// ErrDisconnectReasons invalid version
+	ErrDisconnectInvalidVersion gnet.DisconnectReason = errors.New("Invalid version")
+	// ErrDisconnectProtocolVersion the protocol versions of the peer and the node don't overlap
+	ErrDisconnectProtocolVersion gnet.DisconnectReason = errors.New("Protocol version not supported")
+	// ErrDisconnectIntroductionTimeout timeout
+	ErrDisconnectIntroductionTimeout gnet.DisconnectReason = errors.New("Version timeout")
+	// ErrDisconnectVersionSendFailed version send failed
+	ErrDisconnectVersionSendFailed gnet.DisconnectReason = errors.New("Version send failed")
+	// ErrDisconnectIsBlacklisted is blacklisted
+	ErrDisconnectIsBlacklisted gnet.DisconnectReason = errors.New("Blacklisted")
+	// ErrDisconnectSelf self connnect
+	ErrDisconnectSelf gnet.DisconnectReason = errors.New("Self connect")
+	// ErrDisconnectConnectedTwice connect twice
+	ErrDisconnectConnectedTwice gnet.DisconnectReason = errors.New("Already connected")
+	// ErrDisconnectIdle idle
+	ErrDisconnectIdle gnet.DisconnectReason = errors.New("Idle")
+	// ErrDisconnectNoIntroduction no introduction
+	ErrDisconnectNoIntroduction gnet.DisconnectReason = errors.New("First message was not an Introduction")
+	// ErrDisconnectIPLimitReached ip limit reached
+	ErrDisconnectIPLimitReached gnet.DisconnectReason = errors.New("Maximum number of connections for this IP was reached")
+	// ErrDisconnectSubnetLimitReached subnet limit reached
+	ErrDisconnectSubnetLimitReached gnet.DisconnectReason = errors.New("Maximum number of connections for this subnet was reached")
+	// ErrDisconnectIncomingLimitReached no incoming connection can be evicted for the new one
+	ErrDisconnectIncomingLimitReached gnet.DisconnectReason = errors.New("Maximum number of incoming connections was reached")
+	// ErrDisconnectEvicted the connection is evicted for a new incoming connection
+	ErrDisconnectEvicted gnet.DisconnectReason = errors.New("Evicted")
+	// ErrDisconnectInvalidHeaders the peer sent block headers with invalid signatures
+	ErrDisconnectInvalidHeaders gnet.DisconnectReason = errors.New("Invalid block headers")
+	// ErrDisconnectMalformedMessage the peer sent a compressed message that doesn't decode
+	ErrDisconnectMalformedMessage gnet.DisconnectReason = errors.New("Malformed message")
+	// ErrDisconnectCheckpointMismatch the peer offers blocks conflicting with the checkpoints
+	ErrDisconnectCheckpointMismatch gnet.DisconnectReason = errors.New("Blocks conflict with the checkpoints")
+	// ErrDisconnectShutdown the node shuts down
+	ErrDisconnectShutdown gnet.DisconnectReason = errors.New("Node shutdown")
+	// ErrDisconnectByPeer the peer sent the DisconnectMessage
+	ErrDisconnectByPeer gnet.DisconnectReason = errors.New("Disconnected by the peer")
+	// ErrDisconnectOtherError this is returned when a seemingly impossible error is encountered
+	// e.g. net.Conn.Addr() returns an invalid ip:port
+	ErrDisconnectOtherError gnet.DisconnectReason = errors.New("Incomprehensible error")
+
+	logger = logging.MustGetLogger("daemon")
+
+	connectionsGauge = metrics.NewGauge("suncoin_connections",
+		"Number of the peer connections")
+	outgoingConnectionsGauge = metrics.NewGauge("suncoin_outgoing_connections",
+		"Number of the outgoing peer connections")
+	disconnectsCounter = metrics.NewCounter("suncoin_disconnects_total",
+		"Number of the peer disconnections")
+	evictionsCounter = metrics.NewCounter("suncoin_evictions_total",
+		"Number of the incoming connections evicted for new ones")
+	blockRequestStallsCounter = metrics.NewCounter("suncoin_block_request_stalls_total",
+		"Number of the peers that didn't answer the block ranges requested in time")
+	relaySkippedCounter = metrics.NewCounter("suncoin_relay_announcements_skipped_total",
+		"Number of the txn hashes not announced to the peers that have them")
+	compressionInCounter = metrics.NewCounter("suncoin_compression_in_bytes_total",
+		"Number of the bytes of the messages compressed")
+	compressionOutCounter = metrics.NewCounter("suncoin_compression_out_bytes_total",
+		"Number of the bytes of the compressed messages sent")
+	compressionRatioGauge = metrics.NewGauge("suncoin_compression_ratio",
+		"Ratio of the compressed bytes sent to the bytes of the messages compressed")
+	encryptedConnectionsGauge = metrics.NewGauge("suncoin_encrypted_connections",
+		"Number of the peer connections through the encrypted transport")
+	encryptionHandshakeFailuresCounter = metrics.NewCounter("suncoin_encryption_handshake_failures_total",
+		"Number of the encrypted transport handshakes failed or rejected")
+)
+
+// Config subsystem configurations
+type Config struct {
+	Daemon   DaemonConfig
+	Messages MessagesConfig
+	Pool     PoolConfig
+	Peers    PeersConfig
+	Gateway  GatewayConfig
+	Visor    VisorConfig
+	Backup   backup.Config
+	NAT      nat.Config
+	DNSSeed  dnsseed.Config
+}
+
+// NewConfig returns a Config with defaults set
+func NewConfig() Config {
+	return Config{
+		Daemon:   NewDaemonConfig(),
+		Pool:     NewPoolConfig(),
+		Peers:    NewPeersConfig(),
+		Gateway:  NewGatewayConfig(),
+		Messages: NewMessagesConfig(),
+		Visor:    NewVisorConfig(),
+		Backup:   backup.NewConfig(),
+		NAT:      nat.NewConfig(),
+		DNSSeed:  dnsseed.NewConfig(),
+	}
+}
+
+// preprocess preprocess for config
+func (cfg *Config) preprocess() Config {
+	config := *cfg
+	if config.Daemon.LocalhostOnly {
+		if config.Daemon.Address == "" {
+			local, err := LocalhostIP()
+			if err != nil {
+				logger.Panicf("Failed to obtain localhost IP: %v", err)
+			}
+			config.Daemon.Address = local
+		} else {
+			if !IsLocalhost(config.Daemon.Address) {
+				logger.Panicf("Invalid address for localhost-only: %s",
+					config.Daemon.Address)
+			}
+		}
+		config.Peers.AllowLocalhost = true
+	}
+	if config.Daemon.ProxyOnly {
+		if config.Daemon.Proxy == "" {
+			logger.Panic("Proxy-only requires a proxy")
+		}
+		if config.Daemon.Address == "" {
+			config.Daemon.Address = "127.0.0.1"
+		} else if !IsLocalhost(config.Daemon.Address) {
+			logger.Panicf("Invalid address for proxy-only: %s", config.Daemon.Address)
+		}
+	}
+	if config.Daemon.OnionAddress != "" {
+		host, _, err := SplitAddr(config.Daemon.OnionAddress)
+		if err != nil || !strings.HasSuffix(host, ".onion") {
+			logger.Panicf("Invalid onion address: %s", config.Daemon.OnionAddress)
+		}
+	}
+	config.Pool.port = config.Daemon.Port
+	config.Pool.address = config.Daemon.Address
+
+	if config.Daemon.DisableNetworking {
+		config.Peers.Disabled = true
+		config.Daemon.DisableIncomingConnections = true
+		config.Daemon.DisableOutgoingConnections = true
+	} else {
+		if config.Daemon.DisableIncomingConnections {
+			logger.Info("Incoming connections are disabled.")
+		}
+		if config.Daemon.DisableOutgoingConnections {
+			logger.Info("Outgoing connections are disabled.")
+		}
+		if config.Daemon.Proxy != "" {
+			logger.Info("Outgoing connections go through the proxy %s, proxy only: %v",
+				config.Daemon.Proxy, config.Daemon.ProxyOnly)
+		}
+		if config.Daemon.OnionAddress != "" {
+			logger.Info("Onion address: %s", config.Daemon.OnionAddress)
+		}
+	}
+
+	// resolving the seeds would leak the node to the dns servers without the proxy
+	if len(config.DNSSeed.Seeds) > 0 && (config.Daemon.DisableOutgoingConnections ||
+		config.Daemon.LocalhostOnly || config.Daemon.ProxyOnly) {
+		logger.Info("Dns seeds are disabled, the node doesn't make direct outgoing connections")
+		config.DNSSeed.Seeds = nil
+	}
+
+	// the port isn't mapped if it's not reachable from the router, or the node hides its address
+	if config.NAT.Enabled && (config.Daemon.DisableIncomingConnections ||
+		config.Daemon.LocalhostOnly || config.Daemon.ProxyOnly) {
+		logger.Info("Port mapping is disabled, the node doesn't accept connections from the LAN")
+		config.NAT.Enabled = false
+	}
+
+	return config
+}
+
+// DaemonConfig configuration for the Daemon
+type DaemonConfig struct {
+	// Application version. TODO -- manage version better
+	Version int32
+	// IP Address to serve on. Leave empty for automatic assignment
+	Address string
+	// TCP/UDP port for connections
+	Port int
+	// Directory where application data is stored
+	DataDirectory string
+	// How often to check and initiate an outgoing connection if needed
+	OutgoingRate time.Duration
+	// How often to re-attempt to fill any missing private (aka required)
+	// connections
+	PrivateRate time.Duration
+	// Number of outgoing connections to maintain
+	OutgoingMax int
+	// Maximum number of connections to try at once
+	PendingMax int
+	// How long to wait for a version packet
+	IntroductionWait time.Duration
+	// How often to check for peers that have decided to stop communicating
+	CullInvalidRate time.Duration
+	// Number of incoming connections allowed, the connections are evicted for the new ones
+	// when the limit is reached
+	IncomingMax int
+	// How many connections are allowed from the same base IP
+	IPCountsMax int
+	// How many connections are allowed from the same /16 subnet
+	SubnetCountsMax int
+	// Messages of at least this many bytes are compressed for the peers supporting it, 0 disables
+	// the compression
+	CompressionMinSize int
+	// Disable all networking activity
+	DisableNetworking bool
+	// Don't make outgoing connections
+	DisableOutgoingConnections bool
+	// Don't allow incoming connections
+	DisableIncomingConnections bool
+	// Run on localhost and only connect to localhost peers
+	LocalhostOnly bool
+	// How long to wait for the DisconnectMessages sent to the peers when shutting down
+	GoodbyeWait time.Duration
+	// SOCKS5 proxy the outgoing connections are made through, host:port, e.g. Tor's 127.0.0.1:9050
+	Proxy string
+	// Make no direct connection, the outgoing connections go through Proxy and the incoming
+	// connections are only accepted on localhost, e.g. from a Tor hidden service
+	ProxyOnly bool
+	// Onion address of the Tor hidden service of the node, host.onion:port
+	OnionAddress string
+	// Port the encrypted connections are accepted on, 0 doesn't listen
+	EncryptedPort int
+	// Peers always connected through the encrypted transport, [key@]host:port of their encrypted
+	// port, the peer must have the key if set
+	EncryptedPeers []string
+	// Hex public keys of the peers allowed to connect to EncryptedPort, any key if empty
+	AuthorizedKeys []string
+	// File of the static private key of the encrypted transport, it's generated if missing
+	EncryptionKeyFile string
+	// How long the handshake of the encrypted transport may take
+	EncryptionHandshakeWait time.Duration
+	// Peers of the operator always connected, host:port. They're exempt from the connection
+	// limits, the eviction and the removal from the peer list, and preferred for downloading
+	// the blocks.
+	TrustedPeers []string
+}
+
+// NewDaemonConfig creates daemon config
+func NewDaemonConfig() DaemonConfig {
+	return DaemonConfig{
+		Version:                    2,
+		Address:                    "",
+		Port:                       6677,
+		OutgoingRate:               time.Second * 5,
+		PrivateRate:                time.Second * 5,
+		OutgoingMax:                16,
+		PendingMax:                 16,
+		IntroductionWait:           time.Second * 30,
+		CullInvalidRate:            time.Second * 3,
+		IncomingMax:                64,
+		IPCountsMax:                3,
+		SubnetCountsMax:            8,
+		CompressionMinSize:         1024,
+		DisableNetworking:          false,
+		DisableOutgoingConnections: false,
+		DisableIncomingConnections: false,
+		LocalhostOnly:              false,
+		GoodbyeWait:                time.Second,
+		Proxy:                      "",
+		ProxyOnly:                  false,
+		OnionAddress:               "",
+		EncryptedPort:              0,
+		EncryptionKeyFile:          "peer.key",
+		EncryptionHandshakeWait:    time.Second * 10,
+	}
+}
+
+// Daemon stateful properties of the daemon
+type Daemon struct {
+	// Daemon configuration
+	Config DaemonConfig
+
+	// Components
+	Messages *Messages
+	Pool     *Pool
+	Peers    *Peers
+	Gateway  *Gateway
+	Visor    *Visor
+	// Dials the outgoing connections through Config.Proxy, nil if no proxy
+	proxy *socks5.Dialer
+	// Encrypted transport, nil if no encrypted port or peer is configured
+	encryption *Encryption
+	// Peers of the operator configured by Config.TrustedPeers
+	trustedPeers *TrustedPeers
+	// Backups of the wallets and the db, nil if the backup dir is not set
+	Backups *backup.Manager
+	// Maps the port on the router by UPnP or NAT-PMP, nil if the port mapping is disabled
+	PortMapper *nat.PortMapper
+	// Resolves the dns seeds, nil if no seed is configured
+	seeder *dnsseed.Seeder
+	// Addresses resolved from the dns seeds
+	seedAddresses chan []string
+
+	DefaultConnections []string
+
+	// Separate index of outgoing connections. The pool aggregates all
+	// connections.
+	outgoingConnections *OutgoingConnections
+	// Number of connections waiting to be formed or timeout
+	pendingConnections *PendingConnections
+	// Keep track of unsolicited clients who should notify us of their version
+	expectingIntroductions *ExpectIntroductions
+	// Keep track of a connection's mirror value, to avoid double
+	// connections (one to their listener, and one to our listener)
+	// Maps from addr to mirror value
+	connectionMirrors *ConnectionMirrors
+	// Maps from mirror value to a map of ip (no port)
+	// We use a map of ip as value because multiple peers can have the same
+	// mirror (to avoid attacks enabled by our use of mirrors),
+	// but only one per base ip
+	mirrorConnections *MirrorConnections
+	// Client connection callbacks
+	onConnectEvent chan ConnectEvent
+	// Client disconnection callbacks
+	onDisconnectEvent chan DisconnectEvent
+	// Connection failure events
+	connectionErrors chan ConnectionError
+	// Tracking connections from the same base IP.  Multiple connections
+	// from the same base IP are allowed but limited.
+	ipCounts *IPCount
+	// Tracking the connections by subnet for the subnet limit and the eviction
+	peerConnections *connectionTracker
+	// Protocol negotiated with the peers by the FeaturesMessage
+	peerProtocols map[string]PeerProtocol
+	// Headers-first download of the blocks
+	blockSync *BlockSync
+	// Number of the pool connections, they're told when the node shuts down
+	connections int
+	// Message handling queue
+	messageEvents chan MessageEvent
+	// quit channel
+	quitC chan chan struct{}
+}
+
+// NewDaemon returns a Daemon with primitives allocated
+func NewDaemon(config Config) (*Daemon, error) {
+	config = config.preprocess()
+	vs, err := NewVisor(config.Visor)
+	if err != nil {
+		return nil, err
+	}
+
+	peers, err := NewPeers(config.Peers)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &Daemon{
+		Config:   config.Daemon,
+		Messages: NewMessages(config.Messages),
+		Peers:    peers,
+		Visor:    vs,
+
+		DefaultConnections: DefaultConnections, //passed in from top level
+
+		expectingIntroductions: NewExpectIntroductions(),
+		connectionMirrors:      NewConnectionMirrors(),
+		mirrorConnections:      NewMirrorConnections(),
+		ipCounts:               NewIPCount(),
+		peerConnections:        newConnectionTracker(),
+		peerProtocols:          make(map[string]PeerProtocol),
+		blockSync: NewBlockSync(config.Visor.Config.BlockchainPubkey,
+			config.Visor.BlocksResponseCount, config.Visor.BlocksDownloadWindow,
+			config.Visor.BlocksRequestsPerPeer, config.Visor.BlocksRequestTimeout),
+		// TODO -- if there are performance problems from blocking chans,
+		// Its because we are connecting to more things than OutgoingMax
+		// if we have private peers
+		onConnectEvent:      make(chan ConnectEvent, config.Daemon.OutgoingMax),
+		onDisconnectEvent:   make(chan DisconnectEvent, config.Daemon.OutgoingMax),
+		connectionErrors:    make(chan ConnectionError, config.Daemon.OutgoingMax),
+		outgoingConnections: NewOutgoingConnections(config.Daemon.OutgoingMax),
+		pendingConnections:  NewPendingConnections(config.Daemon.PendingMax),
+		messageEvents:       make(chan MessageEvent, config.Pool.EventChannelSize),
+		quitC:               make(chan chan struct{}),
+	}
+
+	if config.Backup.Dir != "" {
+		var writeDB func(w io.Writer) error
+		if vs.v != nil {
+			writeDB = vs.v.WriteDB
+		}
+
+		d.Backups, err = backup.NewManager(config.Backup, writeDB)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.Daemon.Proxy != "" {
+		d.proxy = &socks5.Dialer{
+			ProxyAddress: config.Daemon.Proxy,
+			Timeout:      config.Pool.DialTimeout,
+		}
+	}
+
+	d.trustedPeers, err = NewTrustedPeers(config.Daemon.TrustedPeers)
+	if err != nil {
+		return nil, err
+	}
+	// the trusted peers are kept in the peer list as trusted, they're listed with the other
+	// trusted peers
+	for _, addr := range d.trustedPeers.Addrs() {
+		if _, err := peers.Peers.AddPeer(addr); err != nil {
+			logger.Warning("Add trusted peer %s to the peer list failed: %v", addr, err)
+			continue
+		}
+		peers.Peers.SetTrustState(addr, true)
+	}
+
+	if config.Daemon.EncryptedPort > 0 || len(config.Daemon.EncryptedPeers) > 0 {
+		kp, err := noise.LoadKeypair(config.Daemon.EncryptionKeyFile)
+		if err != nil {
+			return nil, err
+		}
+
+		d.encryption, err = NewEncryption(kp, config.Daemon.EncryptedPeers,
+			config.Daemon.AuthorizedKeys, config.Daemon.EncryptionHandshakeWait)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if config.NAT.Enabled {
+		d.PortMapper = nat.NewPortMapper(config.NAT, config.Daemon.Port)
+	}
+
+	if len(config.DNSSeed.Seeds) > 0 {
+		d.seeder = dnsseed.NewSeeder(config.DNSSeed)
+		d.seedAddresses = make(chan []string, 1)
+	}
+
+	// tell the peers the node understands the FeaturesMessage
+	d.Messages.Mirror |= featuresMirrorBit
+
+	d.Gateway = NewGateway(config.Gateway, d)
+	// the peers are told why the connections are closed
+	d.Messages.Config.Messages = append(d.Messages.Config.Messages,
+		NewMessageConfig("DISC", DisconnectMessage{}),
+		// the protocol versions and the features are negotiated
+		NewMessageConfig("FEAT", FeaturesMessage{}),
+		// the headers are downloaded ahead of the blocks from the peers serving them
+		NewMessageConfig("GETH", GetHeadersMessage{}),
+		NewMessageConfig("GIVH", GiveHeadersMessage{}),
+		// the large messages are compressed for the peers supporting it
+		NewMessageConfig("CMPR", CompressedMessage{}))
+	d.Messages.Config.Register()
+	d.Pool = NewPool(config.Pool, d)
+
+	return d, nil
+}
+
+// ConnectEvent generated when a client connects
+type ConnectEvent struct {
+	Addr      string
+	Solicited bool
+}
+
+// DisconnectEvent generated when a connection terminated
+type DisconnectEvent struct {
+	Addr   string
+	Reason gnet.DisconnectReason
+}
+
+// ConnectionError represent a failure to connect/dial a connection, with context
+type ConnectionError struct {
+	Addr  string
+	Error error
+}
+
+// MessageEvent encapsulates a deserialized message from the network
+type MessageEvent struct {
+	Message AsyncMessage
+	Context *gnet.MessageContext
+}
+
+// Shutdown Terminates all subsystems safely.  The run loop tells the peers the node shuts down
+// and is stopped first, then the port mapping is deleted, the connections are closed, the peers
+// are saved and the visor closes the db.
+func (dm *Daemon) Shutdown() {
+	// close the daemon loop first
+	q := make(chan struct{}, 1)
+	dm.quitC <- q
+	<-q
+
+	if dm.PortMapper != nil {
+		dm.PortMapper.Shutdown()
+	}
+	if dm.encryption != nil {
+		dm.encryption.Shutdown()
+	}
+	dm.Pool.Shutdown()
+	dm.Peers.Shutdown()
+	dm.Visor.Shutdown()
+}
+
+// Run main loop for peer/connection management. Send anything to quit to shut it
+// down
+func (dm *Daemon) Run() (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger.Errorf("recover:%v\n stack:%v", r, string(debug.Stack()))
+		}
+
+		logger.Info("Daemon closed")
+	}()
+
+	errC := make(chan error)
+
+	// start visor
+	go func() {
+		errC <- dm.Visor.Run()
+	}()
+
+	if !dm.Config.DisableIncomingConnections {
+		go func() {
+			errC <- dm.Pool.Run()
+		}()
+
+		if dm.encryption != nil && dm.Config.EncryptedPort > 0 {
+			addr := net.JoinHostPort(dm.Config.Address, strconv.Itoa(dm.Config.EncryptedPort))
+			go func() {
+				errC <- dm.encryption.Run(addr, dm.Pool.Pool.AcceptConn)
+			}()
+		}
+	}
+
+	if dm.PortMapper != nil {
+		go dm.PortMapper.Run()
+	}
+
+	// the seeds are resolved in the background, the saved peers are tried meanwhile
+	if dm.seeder != nil {
+		go func() {
+			addrs, err := dm.seeder.Resolve()
+			if err != nil {
+				logger.Warning("Dns seeds: %v", err)
+				return
+			}
+			dm.seedAddresses <- addrs
+		}()
+	}
+
+	// TODO -- run blockchain stuff in its own goroutine
+	blockInterval := time.Duration(dm.Visor.Config.Config.BlockCreationInterval)
+	blockCreationTicker := time.NewTicker(time.Second * blockInterval)
+	if !dm.Visor.Config.Config.IsMaster {
+		blockCreationTicker.Stop()
+	}
+
+	unconfirmedRefreshTicker := time.Tick(dm.Visor.Config.Config.UnconfirmedRefreshRate)
+	unconfirmedResendTicker := time.Tick(dm.Visor.Config.Config.UnconfirmedResendPeriod)
+	blocksRequestTicker := time.Tick(dm.Visor.Config.BlocksRequestRate)
+	blocksAnnounceTicker := time.Tick(dm.Visor.Config.BlocksAnnounceRate)
+	blocksStallCheckTicker := time.Tick(dm.Visor.Config.BlocksStallCheckRate)
+
+	privateConnectionsTicker := time.Tick(dm.Config.PrivateRate)
+	cullInvalidTicker := time.Tick(dm.Config.CullInvalidRate)
+	outgoingConnectionsTicker := time.Tick(dm.Config.OutgoingRate)
+	clearOldPeersTicker := time.Tick(dm.Peers.Config.CullRate)
+	requestPeersTicker := time.Tick(dm.Peers.Config.RequestRate)
+	clearStaleConnectionsTicker := time.Tick(dm.Pool.Config.ClearStaleRate)
+	idleCheckTicker := time.Tick(dm.Pool.Config.IdleCheckRate)
+
+	var backupTicker <-chan time.Time
+	if dm.Backups != nil && dm.Backups.Config.Interval > 0 {
+		backupTicker = time.Tick(dm.Backups.Config.Interval)
+	}
+
+	// connecto to trusted peers
+	if !dm.Config.DisableOutgoingConnections {
+		go dm.connectToTrustPeer()
+	}
+
+	for {
+		select {
+		case err = <-errC:
+			return
+		case qc := <-dm.quitC:
+			if !dm.Config.DisableNetworking {
+				dm.sayGoodbye()
+			}
+			qc <- struct{}{}
+			return
+		// Remove connections that failed to complete the handshake
+		case <-cullInvalidTicker:
+			if !dm.Config.DisableNetworking {
+				dm.cullInvalidConnections()
+			}
+		// Request peers via PEX
+		case <-requestPeersTicker:
+			dm.Peers.requestPeers(dm.Pool)
+		// Remove peers we haven't seen in a while
+		case <-clearOldPeersTicker:
+			if !dm.Peers.Config.Disabled {
+				dm.Peers.Peers.ClearOld(dm.Peers.Config.Expiration)
+			}
+		// Remove connections that haven't said anything in a while
+		case <-clearStaleConnectionsTicker:
+			if !dm.Config.DisableNetworking {
+				dm.Pool.clearStaleConnections()
+			}
+		// Sends pings as needed
+		case <-idleCheckTicker:
+			if !dm.Config.DisableNetworking {
+				dm.Pool.sendPings()
+			}
+		// Fill up our outgoing connections
+		case <-outgoingConnectionsTicker:
+			trustPeerNum := len(dm.Peers.Peers.GetAllTrustedPeers())
+			if !dm.Config.DisableOutgoingConnections &&
+				dm.outgoingConnections.Len() < (dm.Config.OutgoingMax+trustPeerNum) &&
+				dm.pendingConnections.Len() < dm.Config.PendingMax {
+				dm.connectToRandomPeer()
+			}
+		// Always try to stay connected to our private peers
+		// TODO (also, connect to all of them on start)
+		case <-privateConnectionsTicker:
+			if !dm.Config.DisableOutgoingConnections {
+				dm.makePrivateConnections()
+			}
+		// Add the addresses of the dns seeds to the peer list
+		case addrs := <-dm.seedAddresses:
+			n := dm.Peers.Peers.AddPeers(addrs)
+			logger.Info("Added %d of %d peers from the dns seeds", n, len(addrs))
+		// Process callbacks for when a client connects. No disconnect chan
+		// is needed because the callback is triggered by HandleDisconnectEvent
+		// which is already select{}ed here
+		case r := <-dm.onConnectEvent:
+			if dm.Config.DisableNetworking {
+				logger.Error("There should be no connect events")
+				return
+			}
+			dm.onConnect(r)
+		case de := <-dm.onDisconnectEvent:
+			if dm.Config.DisableNetworking {
+				logger.Error("There should be no disconnect events")
+				return
+			}
+			dm.onDisconnect(de)
+		// Handle connection errors
+		case r := <-dm.connectionErrors:
+			if dm.Config.DisableNetworking {
+				logger.Error("There should be no connection errors")
+				return
+			}
+			dm.handleConnectionError(r)
+		// Process message sending results
+		case r := <-dm.Pool.Pool.SendResults:
+			if dm.Config.DisableNetworking {
+				logger.Error("There should be nothing in SendResults")
+				return
+			}
+			dm.handleMessageSendResult(r)
+		// Message handlers
+		case m := <-dm.messageEvents:
+			if dm.Config.DisableNetworking {
+				logger.Error("There should be no message events")
+				return
+			}
+			dm.processMessageEvent(m)
+		// Process any pending RPC requests
+		case req := <-dm.Gateway.requests:
+			req()
+		// TODO -- run these in the Visor
+		// Create blocks, if master chain
+		case <-blockCreationTicker.C:
+			if dm.Visor.Config.Config.IsMaster {
+				err := dm.Visor.CreateAndPublishBlock(dm.Pool)
+				if err != nil {
+					logger.Error("Failed to create block: %v", err)
+					continue
+				}
+
+				// Not a critical error, but we want it visible in logs
+				logger.Critical("Created and published a new block")
+			}
+		case <-unconfirmedRefreshTicker:
+			// get the transactions that turn to valid
+			validTxns := dm.Visor.RefreshUnconfirmed()
+			// announce this transactions
+			dm.Visor.AnnounceTxns(dm.Pool, validTxns)
+		case <-unconfirmedResendTicker:
+			dm.Visor.RebroadcastTxns(dm.Pool)
+		case <-blocksRequestTicker:
+			dm.Visor.RequestBlocks(dm.Pool)
+			dm.requestHeaders()
+		case <-blocksStallCheckTicker:
+			dm.reassignStalledBlocks()
+		case <-blocksAnnounceTicker:
+			dm.Visor.AnnounceBlocks(dm.Pool)
+		// Copying the db takes a while, it's not run in the loop
+		case <-backupTicker:
+			go func() {
+				if _, err := dm.Backups.Create(); err != nil {
+					logger.Error("Create backup failed: %v", err)
+				}
+			}()
+		}
+	}
+}
+
+// GetListenPort returns the ListenPort for a given address.  If no port is found, 0 is
+// returned
+func (dm *Daemon) GetListenPort(addr string) uint16 {
+	m, ok := dm.connectionMirrors.Get(addr)
+	if !ok {
+		return 0
+	}
+
+	ip, _, err := SplitAddr(addr)
+	if err != nil {
+		logger.Error("GetListenPort received invalid addr: %v", err)
+		return 0
+	}
+
+	p, ok := dm.mirrorConnections.Get(m, ip)
+	if !ok {
+		return 0
+	}
+	return p
+}
+
+// Connects to a given peer.  Returns an error if no connection attempt was
+// made.  If the connection attempt itself fails, the error is sent to
+// the connectionErrors channel.
+func (dm *Daemon) connectToPeer(p *pex.Peer) error {
+	if dm.Config.DisableOutgoingConnections {
+		return errors.New("Outgoing connections disabled")
+	}
+	a, _, err := SplitAddr(p.Addr)
+	if err != nil {
+		logger.Warning("PEX gave us an invalid peer: %v", err)
+		return errors.New("Invalid peer")
+	}
+	if dm.Config.LocalhostOnly && !IsLocalhost(a) {
+		return errors.New("Not localhost")
+	}
+	// the node is only connected to the encrypted peers through the encrypted transport
+	if dm.encryption != nil && dm.encryption.HasPeerIP(a) && !dm.encryption.IsPeer(p.Addr) {
+		return errors.New("Peer is connected through the encrypted transport")
+	}
+
+	conned, err := dm.Pool.Pool.IsConnExist(p.Addr)
+	if err != nil {
+		return err
+	}
+
+	if conned {
+		return errors.New("Already connected")
+	}
+
+	if _, ok := dm.pendingConnections.Get(p.Addr); ok {
+		return errors.New("Connection is pending")
+	}
+	// the trusted peers are exempt from the limits, e.g. the nodes of a cluster share the subnet
+	trusted := dm.trustedPeers.Has(p.Addr)
+	cnt, ok := dm.ipCounts.Get(a)
+	if !dm.Config.LocalhostOnly && !trusted && ok && cnt != 0 {
+		return errors.New("Already connected to a peer with this base IP")
+	}
+	if !trusted && dm.subnetCountMaxed(p.Addr) {
+		return errors.New("Max connections for this subnet reached")
+	}
+	logger.Debug("Trying to connect to %s", p.Addr)
+	dm.pendingConnections.Add(p.Addr, p)
+	go func() {
+		if err := dm.Connect(p.Addr); err != nil {
+			dm.connectionErrors <- ConnectionError{p.Addr, err}
+		}
+	}()
+	return nil
+}
+
+// Connect makes an outgoing connection to the address, through the proxy if configured, the
+// encrypted peers are connected through the encrypted transport
+func (dm *Daemon) Connect(addr string) error {
+	encrypted := dm.encryption != nil && dm.encryption.IsPeer(addr)
+	if dm.proxy == nil && !encrypted {
+		return dm.Pool.Pool.Connect(addr)
+	}
+
+	conned, err := dm.Pool.Pool.IsConnExist(addr)
+	if err != nil {
+		return err
+	}
+	if conned {
+		return nil
+	}
+
+	if encrypted {
+		logger.Debug("Making encrypted connection to %s", addr)
+		conn, err := dm.encryption.Dial(addr, dm.dial)
+		if err != nil {
+			return err
+		}
+		return dm.Pool.Pool.ConnectConn(conn)
+	}
+
+	conn, err := dm.dial(addr)
+	if err != nil {
+		return err
+	}
+	return dm.Pool.Pool.ConnectConn(conn)
+}
+
+// dial makes a TCP connection to the address, through the proxy if configured
+func (dm *Daemon) dial(addr string) (net.Conn, error) {
+	if dm.proxy == nil {
+		logger.Debug("Making TCP Connection to %s", addr)
+		return net.DialTimeout("tcp", addr, dm.Pool.Config.DialTimeout)
+	}
+
+	logger.Debug("Making TCP Connection to %s through the proxy %s", addr, dm.Config.Proxy)
+	return dm.proxy.Dial(addr)
+}
+
+// Connects to all private, trusted and encrypted peers
+func (dm *Daemon) makePrivateConnections() {
+	if dm.Config.DisableOutgoingConnections {
+		return
+	}
+	addrs := dm.Peers.Peers.GetPrivateAddresses()
+	for _, addr := range addrs {
+		p, exist := dm.Peers.Peers.GetPeerByAddr(addr)
+		if exist {
+			logger.Info("Private peer attempt: %s", p.Addr)
+			if err := dm.connectToPeer(&p); err != nil {
+				logger.Debug("Did not connect to private peer: %v", err)
+			}
+		}
+	}
+
+	// the trusted peers are connected regardless of the retry backoff of the peer list
+	for _, addr := range dm.trustedPeers.Addrs() {
+		if err := dm.connectToPeer(pex.NewPeer(addr)); err != nil {
+			logger.Debug("Did not connect to trusted peer %s: %v", addr, err)
+		}
+	}
+
+	// the encrypted peers aren't in the peer list, they're not exchanged
+	if dm.encryption != nil {
+		for _, addr := range dm.encryption.Peers() {
+			if err := dm.connectToPeer(pex.NewPeer(addr)); err != nil {
+				logger.Debug("Did not connect to encrypted peer %s: %v", addr, err)
+			}
+		}
+	}
+}
+
+func (dm *Daemon) connectToTrustPeer() {
+	if dm.Config.DisableIncomingConnections {
+		return
+	}
+
+	logger.Info("connect to trusted peers")
+	// make connections to all trusted peers
+	peers := dm.Peers.Peers.GetPublicTrustPeers()
+	for _, p := range peers {
+		dm.connectToPeer(p)
+	}
+}
+
+// Attempts to connect to a random peer. If it fails, the peer is removed
+func (dm *Daemon) connectToRandomPeer() {
+	if dm.Config.DisableOutgoingConnections {
+		return
+	}
+	// Make a connection to a random (public) peer
+	peers := dm.Peers.Peers.RandomPublic(0)
+	for _, p := range peers {
+		// check if the peer has public port
+		if p.HasIncomePort {
+			// try to connect the peer if it's ip:mirror does not exist
+			if _, exist := dm.getMirrorPort(p.Addr, dm.Messages.Mirror); !exist {
+				dm.connectToPeer(p)
+				continue
+			}
+		} else {
+			// try to connect to the peer if we don't know whether the peer have public port
+			dm.connectToPeer(p)
+		}
+	}
+
+	if len(peers) == 0 {
+		// reset the retry times of all peers
+		dm.Peers.Peers.ResetAllRetryTimes()
+	}
+}
+
+// We remove a peer from the Pex if we failed to connect
+// Failure to connect
+// Use exponential backoff, not peer list
+func (dm *Daemon) handleConnectionError(c ConnectionError) {
+	logger.Debug("Failed to connect to %s with error: %v", c.Addr, c.Error)
+
+	dm.pendingConnections.Remove(c.Addr)
+
+	// the trusted peers don't back off, they're still protected from the eviction
+	if !dm.trustedPeers.Has(c.Addr) {
+		dm.Peers.Peers.IncreaseRetryTimes(c.Addr)
+	}
+}
+
+// Removes unsolicited connections who haven't sent a version
+func (dm *Daemon) cullInvalidConnections() {
+	// This method only handles the erroneous people from the DHT, but not
+	// malicious nodes
+	now := utc.Now()
+	addrs, err := dm.expectingIntroductions.CullInvalidConns(func(addr string, t time.Time) (bool, error) {
+		conned, err := dm.Pool.Pool.IsConnExist(addr)
+		if err != nil {
+			return false, err
+		}
+
+		if !conned {
+			return true, nil
+		}
+
+		if t.Add(dm.Config.IntroductionWait).Before(now) {
+			return true, nil
+		}
+		return false, nil
+	})
+
+	if err != nil {
+		logger.Error("expectingIntroduction cull invalid connections failed: %v", err)
+		return
+	}
+
+	for _, a := range addrs {
+		exist, err := dm.Pool.Pool.IsConnExist(a)
+		if err != nil {
+			logger.Error("%v", err)
+			return
+		}
+
+		if exist {
+			logger.Info("Removing %s for not sending a version", a)
+			if err := dm.Pool.Pool.Disconnect(a, ErrDisconnectIntroductionTimeout); err != nil {
+				logger.Error("%v", err)
+				return
+			}
+			if !dm.trustedPeers.Has(a) {
+				dm.Peers.RemovePeer(a)
+			}
+		}
+	}
+}
+
+// Records an AsyncMessage to the messageEvent chan.  Do not access
+// messageEvent directly.
+func (dm *Daemon) recordMessageEvent(m AsyncMessage,
+	c *gnet.MessageContext) error {
+	dm.messageEvents <- MessageEvent{m, c}
+	return nil
+}
+
+// check if the connection needs introduction message
+func (dm *Daemon) needsIntro(addr string) bool {
+	_, exist := dm.expectingIntroductions.Get(addr)
+	return exist
+}
+
+// Processes a queued AsyncMessage.
+func (dm *Daemon) processMessageEvent(e MessageEvent) {
+	// The first message received must be an Introduction
+	// We have to check at process time and not record time because
+	// Introduction message does not update ExpectingIntroductions until its
+	// Process() is called
+	// _, needsIntro := self.expectingIntroductions[e.Context.Addr]
+	// if needsIntro {
+	_, isIntro := e.Message.(*IntroductionMessage)
+	if dm.needsIntro(e.Context.Addr) {
+		if !isIntro {
+			dm.Pool.Pool.Disconnect(e.Context.Addr, ErrDisconnectNoIntroduction)
+		}
+	}
+	e.Message.Process(dm)
+
+	// the introduction is accepted
+	if isIntro && !dm.needsIntro(e.Context.Addr) {
+		dm.sendFeatures(e.Context.Addr)
+	}
+}
+
+// Called when a ConnectEvent is processed off the onConnectEvent channel
+func (dm *Daemon) onConnect(e ConnectEvent) {
+	a := e.Addr
+
+	if e.Solicited {
+		logger.Info("Connected to %s as we requested", a)
+	} else {
+		logger.Info("Received unsolicited connection from %s", a)
+	}
+
+	dm.pendingConnections.Remove(a)
+
+	exist, err := dm.Pool.Pool.IsConnExist(a)
+	if err != nil {
+		logger.Error("%v", err)
+		return
+	}
+
+	if !exist {
+		logger.Warning("While processing an onConnect event, no pool " +
+			"connection was found")
+		return
+	}
+
+	// every pool connection is followed by a disconnect event
+	dm.connections++
+	connectionsGauge.Inc()
+
+	trusted := dm.trustedPeers.Has(a)
+
+	if !trusted && dm.ipCountMaxed(a) {
+		logger.Info("Max connections for %s reached, disconnecting", a)
+		dm.Pool.Pool.Disconnect(a, ErrDisconnectIPLimitReached)
+		return
+	}
+
+	if !trusted && dm.subnetCountMaxed(a) {
+		logger.Info("Max connections for the subnet of %s reached, disconnecting", a)
+		dm.Pool.Pool.Disconnect(a, ErrDisconnectSubnetLimitReached)
+		return
+	}
+
+	// a trusted peer is accepted even if no connection can be evicted for it
+	if !e.Solicited && dm.peerConnections.IncomingLen() >= dm.Config.IncomingMax {
+		if !dm.evictIncoming() && !trusted {
+			logger.Info("Max incoming connections reached and no peer can be evicted, disconnecting %s", a)
+			dm.Pool.Pool.Disconnect(a, ErrDisconnectIncomingLimitReached)
+			return
+		}
+	}
+
+	dm.recordIPCount(a)
+	dm.peerConnections.Add(a, !e.Solicited, utc.Now())
+	if trusted {
+		dm.blockSync.SetPreferred(a)
+	}
+
+	if e.Solicited {
+		dm.outgoingConnections.Add(a)
+		outgoingConnectionsGauge.Set(float64(dm.outgoingConnections.Len()))
+	}
+
+	dm.expectingIntroductions.Add(a, utc.Now())
+	logger.Debug("Sending introduction message to %s, mirror:%d", a, dm.Messages.Mirror)
+	m := NewIntroductionMessage(dm.Messages.Mirror, dm.Config.Version,
+		dm.Pool.Pool.Config.Port)
+	dm.Pool.Pool.SendMessage(a, m)
+}
+
+func (dm *Daemon) onDisconnect(e DisconnectEvent) {
+	logger.Info("%s disconnected because: %v", e.Addr, e.Reason)
+
+	dm.outgoingConnections.Remove(e.Addr)
+	dm.expectingIntroductions.Remove(e.Addr)
+	dm.connections--
+	connectionsGauge.Dec()
+	outgoingConnectionsGauge.Set(float64(dm.outgoingConnections.Len()))
+	disconnectsCounter.Inc()
+	dm.Visor.RemoveConnection(e.Addr)
+	dm.removeIPCount(e.Addr)
+	dm.peerConnections.Remove(e.Addr)
+	delete(dm.peerProtocols, e.Addr)
+	// the ranges of the peer are requested from the other peers
+	dm.blockSync.RemovePeer(e.Addr)
+	dm.Visor.relay.RemovePeer(e.Addr)
+	if dm.encryption != nil {
+		dm.encryption.Remove(e.Addr)
+	}
+	dm.requestBlockBodies()
+	dm.removeConnectionMirror(e.Addr)
+}
+
+// subnetCountMaxed returns whether the subnet of the address has the maximum connections, the
+// localhost connections are not limited
+func (dm *Daemon) subnetCountMaxed(addr string) bool {
+	ip, _, err := SplitAddr(addr)
+	if err == nil && IsLocalhost(ip) {
+		return false
+	}
+	return dm.peerConnections.SubnetCount(addr) >= dm.Config.SubnetCountsMax
+}
+
+// evictIncoming disconnects an incoming connection for a new one, the trusted peers are never
+// evicted. Returns false if all the incoming connections are protected.
+func (dm *Daemon) evictIncoming() bool {
+	// the incoming connections come from other ports than the listening ports of the peers
+	trusted := make(map[string]bool)
+	for _, p := range dm.Peers.Peers.GetAllTrustedPeers() {
+		if ip, _, err := SplitAddr(p.Addr); err == nil {
+			trusted[ip] = true
+		}
+	}
+
+	addr, ok := dm.peerConnections.SelectEviction(func(addr string) bool {
+		ip, _, err := SplitAddr(addr)
+		return (err == nil && trusted[ip]) || dm.trustedPeers.Has(addr)
+	})
+	if !ok {
+		return false
+	}
+
+	logger.Info("Max incoming connections reached, evicting %s", addr)
+	evictionsCounter.Inc()
+	// the connection is forgotten at once, its disconnect event comes later
+	dm.peerConnections.Remove(addr)
+	dm.Pool.Pool.Disconnect(addr, ErrDisconnectEvicted)
+	return true
+}
+
+// Triggered when an gnet.Connection terminates
+func (dm *Daemon) onGnetDisconnect(addr string, reason gnet.DisconnectReason) {
+	e := DisconnectEvent{
+		Addr:   addr,
+		Reason: reason,
+	}
+	select {
+	case dm.onDisconnectEvent <- e:
+	default:
+		logger.Info("onDisconnectEvent channel is full")
+	}
+}
+
+// Triggered when an gnet.Connection is connected
+func (dm *Daemon) onGnetConnect(addr string, solicited bool) {
+	dm.onConnectEvent <- ConnectEvent{Addr: addr, Solicited: solicited}
+}
+
+// Returns whether the ipCount maximum has been reached
+func (dm *Daemon) ipCountMaxed(addr string) bool {
+	ip, _, err := SplitAddr(addr)
+	if err != nil {
+		logger.Warning("ipCountMaxed called with invalid addr: %v", err)
+		return true
+	}
+
+	// the connections of the hidden service come from localhost
+	if dm.Config.OnionAddress != "" && IsLocalhost(ip) {
+		return false
+	}
+
+	if cnt, ok := dm.ipCounts.Get(ip); ok {
+		return cnt >= dm.Config.IPCountsMax
+	}
+	return false
+}
+
+// Adds base IP to ipCount or returns error if max is reached
+func (dm *Daemon) recordIPCount(addr string) {
+	ip, _, err := SplitAddr(addr)
+	if err != nil {
+		logger.Warning("recordIPCount called with invalid addr: %v", err)
+		return
+	}
+	dm.ipCounts.Increase(ip)
+}
+
+// Removes base IP from ipCount
+func (dm *Daemon) removeIPCount(addr string) {
+	ip, _, err := SplitAddr(addr)
+	if err != nil {
+		logger.Warning("removeIPCount called with invalid addr: %v", err)
+		return
+	}
+	dm.ipCounts.Decrease(ip)
+}
+
+// Adds addr + mirror to the connectionMirror mappings
+func (dm *Daemon) recordConnectionMirror(addr string, mirror uint32) error {
+	ip, port, err := SplitAddr(addr)
+	if err != nil {
+		logger.Warning("recordConnectionMirror called with invalid addr: %v",
+			err)
+		return err
+	}
+	dm.connectionMirrors.Add(addr, mirror)
+	dm.mirrorConnections.Add(mirror, ip, port)
+	return nil
+}
+
+// Removes an addr from the connectionMirror mappings
+func (dm *Daemon) removeConnectionMirror(addr string) {
+	mirror, ok := dm.connectionMirrors.Get(addr)
+	if !ok {
+		return
+	}
+	ip, _, err := SplitAddr(addr)
+	if err != nil {
+		logger.Warning("removeConnectionMirror called with invalid addr: %v",
+			err)
+		return
+	}
+
+	// remove ip from specific mirror
+	dm.mirrorConnections.Remove(mirror, ip)
+
+	dm.connectionMirrors.Remove(addr)
+}
+
+// Returns whether an addr+mirror's port and whether the port exists
+func (dm *Daemon) getMirrorPort(addr string, mirror uint32) (uint16, bool) {
+	ip, _, err := SplitAddr(addr)
+	if err != nil {
+		logger.Warning("getMirrorPort called with invalid addr: %v", err)
+		return 0, false
+	}
+	return dm.mirrorConnections.Get(mirror, ip)
+}
+
+// When an async message send finishes, its result is handled by this
+func (dm *Daemon) handleMessageSendResult(r gnet.SendResult) {
+	if r.Error != nil {
+		logger.Warning("Failed to send %s to %s: %v",
+			reflect.TypeOf(r.Message).Name(), r.Addr, r.Error)
+		return
+	}
+	switch r.Message.(type) {
+	case SendingTxnsMessage:
+		dm.Visor.SetTxnsAnnounced(r.Message.(SendingTxnsMessage).GetTxns())
+	default:
+	}
+}
+
+// sayGoodbye sends the DisconnectMessage to the peers, and waits till the messages are sent or
+// GoodbyeWait passes
+func (dm *Daemon) sayGoodbye() {
+	n := dm.connections
+	if n <= 0 {
+		return
+	}
+
+	logger.Info("Telling %d peers the node shuts down", n)
+	if err := dm.Pool.Pool.BroadcastMessage(NewDisconnectMessage(ErrDisconnectShutdown)); err != nil {
+		logger.Debug("Broadcast DisconnectMessage failed: %v", err)
+		return
+	}
+
+	timeout := time.After(dm.Config.GoodbyeWait)
+	for n > 0 {
+		select {
+		case r := <-dm.Pool.Pool.SendResults:
+			if _, ok := r.Message.(*DisconnectMessage); ok {
+				n--
+			}
+			dm.handleMessageSendResult(r)
+		case <-timeout:
+			logger.Warning("%d DisconnectMessages are not sent in %v", n, dm.Config.GoodbyeWait)
+			return
+		}
+	}
+}
+
+// LocalhostIP returns the address for localhost on the machine
+func LocalhostIP() (string, error) {
+	tt, err := net.Interfaces()
+	if err != nil {
+		return "", err
+	}
+	for _, t := range tt {
+		aa, err := t.Addrs()
+		if err != nil {
+			return "", err
+		}
+		for _, a := range aa {
+			if ipnet, ok := a.(*net.IPNet); ok && ipnet.IP.IsLoopback() {
+				return ipnet.IP.String(), nil
+			}
+		}
+	}
+	return "", errors.New("No local IP found")
+}
+
+// IsLocalhost returns true if addr is a localhost address
+func IsLocalhost(addr string) bool {
+	return net.ParseIP(addr).IsLoopback()
+}
+
+// SplitAddr splits an ip:port string to ip, port
+func SplitAddr(addr string) (string, uint16, error) {
+	pts := strings.Split(addr, ":")
+	if len(pts) != 2 {
+		return pts[0], 0, fmt.Errorf("Invalid addr %s", addr)
+	}
+	port64, err := strconv.ParseUint(pts[1], 10, 16)
+	if err != nil {
+		return pts[0], 0, fmt.Errorf("Invalid port in %s", addr)
+	}
+	return pts[0], uint16(port64), nil
+}
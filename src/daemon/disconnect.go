@@ -0,0 +1,42 @@
+package daemon
+
+import (
+	"github.com/skycoin/skycoin/src/daemon/gnet"
+)
+
+// maxDisconnectReasonLen is the max length of the reason logged
+const maxDisconnectReasonLen = 256
+
+// DisconnectMessage tells the peer why the connection is closed, it's sent to all the peers when
+// the node shuts down so that they don't wait for the idle timeout
+type DisconnectMessage struct {
+	Reason string
+	c      *gnet.MessageContext `enc:"-"`
+}
+
+// NewDisconnectMessage creates message
+func NewDisconnectMessage(reason gnet.DisconnectReason) *DisconnectMessage {
+	return &DisconnectMessage{
+		Reason: reason.Error(),
+	}
+}
+
+// Handle handles message
+func (dcm *DisconnectMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	dcm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(dcm, mc)
+}
+
+// Process closes the connection of the peer
+func (dcm *DisconnectMessage) Process(d *Daemon) {
+	reason := dcm.Reason
+	if len(reason) > maxDisconnectReasonLen {
+		reason = reason[:maxDisconnectReasonLen]
+	}
+	logger.Info("Peer %s is disconnecting: %q", dcm.c.Addr, reason)
+
+	if err := d.Pool.Pool.Disconnect(dcm.c.Addr, ErrDisconnectByPeer); err != nil {
+		logger.Debug("Disconnect %s failed: %v", dcm.c.Addr, err)
+	}
+}
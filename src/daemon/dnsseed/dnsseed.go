@@ -0,0 +1,196 @@
+// Package dnsseed discovers the peers by resolving the DNS seeds, the host names whose A records
+// are the addresses of the nodes. The resolved addresses are cached, so the seeds are not queried
+// on every start and the cache is used if no seed answers.
+package dnsseed
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/file"
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+var (
+	logger = logging.MustGetLogger("dnsseed")
+
+	// ErrNoAddresses is returned if no seed answers and there's no cached address
+	ErrNoAddresses = errors.New("no address from the dns seeds")
+)
+
+// Config dns seed configuration
+type Config struct {
+	// Host names of the seeds, host or host:port, queried in order
+	Seeds []string
+	// Port of the addresses of the seeds without a port
+	Port int
+	// How long to wait for a seed to answer
+	Timeout time.Duration
+	// Stop querying the next seeds once this many addresses are resolved
+	MaxAddresses int
+	// File the resolved addresses are cached in, no cache if empty
+	CacheFile string
+	// How long the cached addresses are used instead of querying the seeds
+	CacheTTL time.Duration
+}
+
+// NewConfig returns a Config with defaults set
+func NewConfig() Config {
+	return Config{
+		Port:         6677,
+		Timeout:      time.Second * 10,
+		MaxAddresses: 64,
+		CacheTTL:     time.Hour * 24,
+	}
+}
+
+// cache is the content of the cache file
+type cache struct {
+	// unix time the addresses are resolved
+	Time      int64    `json:"time"`
+	Seeds     []string `json:"seeds"`
+	Addresses []string `json:"addresses"`
+}
+
+// Seeder resolves the seeds
+type Seeder struct {
+	Config Config
+	// resolves the host name, it's net.DefaultResolver except in the tests
+	lookup func(ctx context.Context, host string) ([]string, error)
+}
+
+// NewSeeder creates a Seeder
+func NewSeeder(c Config) *Seeder {
+	return &Seeder{
+		Config: c,
+		lookup: net.DefaultResolver.LookupHost,
+	}
+}
+
+// Resolve returns the ip:port addresses of the nodes. The addresses cached within CacheTTL are
+// returned, otherwise the seeds are queried in order till MaxAddresses are resolved. If no seed
+// answers, the expired cache is returned.
+func (s *Seeder) Resolve() ([]string, error) {
+	c, err := s.loadCache()
+	if err != nil && !os.IsNotExist(err) {
+		logger.Warning("Load dns seed cache failed: %v", err)
+	}
+
+	if c != nil && sameSeeds(c.Seeds, s.Config.Seeds) &&
+		time.Since(time.Unix(c.Time, 0)) < s.Config.CacheTTL && len(c.Addresses) > 0 {
+		logger.Debug("Using %d cached dns seed addresses", len(c.Addresses))
+		return c.Addresses, nil
+	}
+
+	addrs := s.query()
+	if len(addrs) > 0 {
+		if err := s.saveCache(addrs); err != nil {
+			logger.Warning("Save dns seed cache failed: %v", err)
+		}
+		return addrs, nil
+	}
+
+	if c != nil && len(c.Addresses) > 0 {
+		logger.Warning("No dns seed answers, using %d cached addresses resolved at %s",
+			len(c.Addresses), time.Unix(c.Time, 0).UTC().Format(time.RFC3339))
+		return c.Addresses, nil
+	}
+
+	return nil, ErrNoAddresses
+}
+
+// query resolves the seeds in order, the IPv6 addresses are skipped since the peer list only
+// holds IPv4 addresses
+func (s *Seeder) query() []string {
+	var addrs []string
+	seen := make(map[string]bool)
+
+	for _, seed := range s.Config.Seeds {
+		if s.Config.MaxAddresses > 0 && len(addrs) >= s.Config.MaxAddresses {
+			break
+		}
+
+		host, port := seed, s.Config.Port
+		if h, p, err := net.SplitHostPort(seed); err == nil {
+			n, err := strconv.ParseUint(p, 10, 16)
+			if err != nil {
+				logger.Warning("Invalid dns seed %s", seed)
+				continue
+			}
+			host, port = h, int(n)
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), s.Config.Timeout)
+		ips, err := s.lookup(ctx, host)
+		cancel()
+		if err != nil {
+			logger.Warning("Resolve dns seed %s failed: %v", host, err)
+			continue
+		}
+
+		n := 0
+		for _, ip := range ips {
+			parsed := net.ParseIP(ip)
+			if parsed == nil || parsed.To4() == nil {
+				continue
+			}
+
+			addr := net.JoinHostPort(parsed.String(), strconv.Itoa(port))
+			if seen[addr] {
+				continue
+			}
+			seen[addr] = true
+			addrs = append(addrs, addr)
+			n++
+
+			if s.Config.MaxAddresses > 0 && len(addrs) >= s.Config.MaxAddresses {
+				break
+			}
+		}
+		logger.Info("Dns seed %s resolved %d addresses", host, n)
+	}
+
+	return addrs
+}
+
+func (s *Seeder) loadCache() (*cache, error) {
+	if s.Config.CacheFile == "" {
+		return nil, nil
+	}
+
+	var c cache
+	if err := file.LoadJSON(s.Config.CacheFile, &c); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+func (s *Seeder) saveCache(addrs []string) error {
+	if s.Config.CacheFile == "" {
+		return nil
+	}
+
+	return file.SaveJSON(s.Config.CacheFile, cache{
+		Time:      time.Now().Unix(),
+		Seeds:     s.Config.Seeds,
+		Addresses: addrs,
+	}, 0600)
+}
+
+// sameSeeds returns whether the cache is of the same seeds, the cache of other seeds is not
+// used within the TTL
+func sameSeeds(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
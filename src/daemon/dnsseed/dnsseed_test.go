@@ -0,0 +1,84 @@
+package dnsseed
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func newTestSeeder(t *testing.T, records map[string][]string) (*Seeder, *[]string, func()) {
+	dir, err := ioutil.TempDir("", "dnsseed")
+	require.NoError(t, err)
+
+	c := NewConfig()
+	c.Seeds = []string{"seed1.example.com", "seed2.example.com:7200", "seed3.example.com"}
+	c.Port = 6000
+	c.MaxAddresses = 4
+	c.CacheFile = filepath.Join(dir, "dnsseeds.json")
+
+	var queried []string
+	s := NewSeeder(c)
+	s.lookup = func(ctx context.Context, host string) ([]string, error) {
+		queried = append(queried, host)
+		ips, ok := records[host]
+		if !ok {
+			return nil, errors.New("no such host")
+		}
+		return ips, nil
+	}
+
+	return s, &queried, func() { os.RemoveAll(dir) }
+}
+
+func TestResolve(t *testing.T) {
+	records := map[string][]string{
+		"seed2.example.com": {"1.1.1.1", "2001:db8::1", "2.2.2.2"},
+		"seed3.example.com": {"2.2.2.2", "3.3.3.3", "4.4.4.4", "5.5.5.5"},
+	}
+	s, queried, cleanup := newTestSeeder(t, records)
+	defer cleanup()
+
+	// seed1 fails, the next seeds are queried till MaxAddresses, the IPv6 address is skipped
+	addrs, err := s.Resolve()
+	require.NoError(t, err)
+	require.Equal(t, []string{"1.1.1.1:7200", "2.2.2.2:7200", "2.2.2.2:6000", "3.3.3.3:6000"}, addrs)
+	require.Equal(t, []string{"seed1.example.com", "seed2.example.com", "seed3.example.com"}, *queried)
+
+	// the cache is used within the TTL
+	*queried = nil
+	addrs2, err := s.Resolve()
+	require.NoError(t, err)
+	require.Equal(t, addrs, addrs2)
+	require.Empty(t, *queried)
+
+	// the expired cache is used if no seed answers
+	s.Config.CacheTTL = 0
+	delete(records, "seed2.example.com")
+	delete(records, "seed3.example.com")
+	addrs2, err = s.Resolve()
+	require.NoError(t, err)
+	require.Equal(t, addrs, addrs2)
+	require.Len(t, *queried, 3)
+
+	// the cache of the other seeds is not used within the TTL
+	s.Config.CacheTTL = time.Hour
+	s.Config.Seeds = []string{"seed4.example.com"}
+	records["seed4.example.com"] = []string{"6.6.6.6"}
+	addrs, err = s.Resolve()
+	require.NoError(t, err)
+	require.Equal(t, []string{"6.6.6.6:6000"}, addrs)
+}
+
+func TestResolveNoAddresses(t *testing.T) {
+	s, _, cleanup := newTestSeeder(t, nil)
+	defer cleanup()
+
+	_, err := s.Resolve()
+	require.Equal(t, ErrNoAddresses, err)
+}
@@ -0,0 +1,265 @@
+package daemon
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/noise"
+)
+
+var (
+	// ErrEncryptionDisabled is returned if the encrypted transport is disabled
+	ErrEncryptionDisabled = errors.New("encrypted transport is disabled")
+	// ErrEncryptionKeyMismatch the peer doesn't have the key pinned for it
+	ErrEncryptionKeyMismatch = errors.New("peer key doesn't match the pinned key")
+	// ErrEncryptionUnauthorized the key of the peer isn't authorized
+	ErrEncryptionUnauthorized = errors.New("peer key is not authorized")
+)
+
+// encryptedPeer is a peer connected through the encrypted transport
+type encryptedPeer struct {
+	Addr string
+	// the key the peer must have if pinned
+	Key    noise.Key
+	Pinned bool
+}
+
+// parseEncryptedPeer parses [key@]host:port, the key is pinned if set
+func parseEncryptedPeer(s string) (encryptedPeer, error) {
+	p := encryptedPeer{Addr: s}
+	if i := strings.Index(s, "@"); i >= 0 {
+		k, err := noise.ParseKey(s[:i])
+		if err != nil {
+			return p, fmt.Errorf("invalid key of encrypted peer %s", s)
+		}
+		p.Addr = s[i+1:]
+		p.Key = k
+		p.Pinned = true
+	}
+	if _, _, err := SplitAddr(p.Addr); err != nil {
+		return p, fmt.Errorf("invalid address of encrypted peer %s", s)
+	}
+	return p, nil
+}
+
+// encryptedConn is a connection of the encrypted transport
+type encryptedConn struct {
+	Key      noise.Key
+	Outgoing bool
+}
+
+// Encryption accepts and dials the connections of the encrypted transport, the connections are
+// encrypted by the Noise protocol and the peers authenticate by their static keys. The listener
+// and the dialing goroutines record the connections, the daemon loop removes them.
+type Encryption struct {
+	keypair noise.Keypair
+	// how long the handshake may take
+	timeout time.Duration
+	// the keys allowed to connect, any key is allowed if empty
+	authorized map[noise.Key]struct{}
+	// the peers dialed through the encrypted transport by address
+	peers map[string]encryptedPeer
+
+	sync.Mutex
+	conns    map[string]encryptedConn
+	listener net.Listener
+}
+
+// NewEncryption creates Encryption, peers are [key@]host:port and authorizedKeys the hex keys
+func NewEncryption(keypair noise.Keypair, peers, authorizedKeys []string,
+	timeout time.Duration) (*Encryption, error) {
+	e := &Encryption{
+		keypair:    keypair,
+		timeout:    timeout,
+		authorized: make(map[noise.Key]struct{}),
+		peers:      make(map[string]encryptedPeer),
+		conns:      make(map[string]encryptedConn),
+	}
+
+	for _, s := range peers {
+		p, err := parseEncryptedPeer(s)
+		if err != nil {
+			return nil, err
+		}
+		e.peers[p.Addr] = p
+	}
+
+	for _, s := range authorizedKeys {
+		k, err := noise.ParseKey(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid authorized key %s", s)
+		}
+		e.authorized[k] = struct{}{}
+	}
+
+	return e, nil
+}
+
+// PublicKey returns the static public key of the node
+func (e *Encryption) PublicKey() noise.Key {
+	return e.keypair.Public
+}
+
+// IsPeer returns whether the address is dialed through the encrypted transport
+func (e *Encryption) IsPeer(addr string) bool {
+	_, ok := e.peers[addr]
+	return ok
+}
+
+// HasPeerIP returns whether a peer dialed through the encrypted transport has the ip
+func (e *Encryption) HasPeerIP(ip string) bool {
+	for addr := range e.peers {
+		if a, _, err := SplitAddr(addr); err == nil && a == ip {
+			return true
+		}
+	}
+	return false
+}
+
+// Peers returns the addresses dialed through the encrypted transport
+func (e *Encryption) Peers() []string {
+	addrs := make([]string, 0, len(e.peers))
+	for addr := range e.peers {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// authorize checks the key of an incoming connection
+func (e *Encryption) authorize(k noise.Key) error {
+	if len(e.authorized) == 0 {
+		return nil
+	}
+	if _, ok := e.authorized[k]; !ok {
+		return ErrEncryptionUnauthorized
+	}
+	return nil
+}
+
+// Dial dials the peer by dial and runs the handshake, the connection fails if the peer doesn't
+// have the pinned key
+func (e *Encryption) Dial(addr string, dial func(addr string) (net.Conn, error)) (net.Conn, error) {
+	c, err := dial(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	nc, err := noise.Client(c, e.keypair, e.timeout)
+	if err != nil {
+		encryptionHandshakeFailuresCounter.Inc()
+		return nil, err
+	}
+
+	if p := e.peers[addr]; p.Pinned && nc.RemoteKey() != p.Key {
+		nc.Close()
+		encryptionHandshakeFailuresCounter.Inc()
+		return nil, ErrEncryptionKeyMismatch
+	}
+
+	e.add(nc, true)
+	return nc, nil
+}
+
+// Accept runs the handshake of the incoming connection, the connection fails if the peer's key
+// isn't authorized
+func (e *Encryption) Accept(c net.Conn) (net.Conn, error) {
+	nc, err := noise.Server(c, e.keypair, e.timeout)
+	if err != nil {
+		encryptionHandshakeFailuresCounter.Inc()
+		return nil, err
+	}
+
+	if err := e.authorize(nc.RemoteKey()); err != nil {
+		nc.Close()
+		encryptionHandshakeFailuresCounter.Inc()
+		return nil, err
+	}
+
+	e.add(nc, false)
+	return nc, nil
+}
+
+func (e *Encryption) add(nc *noise.Conn, outgoing bool) {
+	e.Lock()
+	defer e.Unlock()
+	e.conns[nc.RemoteAddr().String()] = encryptedConn{
+		Key:      nc.RemoteKey(),
+		Outgoing: outgoing,
+	}
+	encryptedConnectionsGauge.Set(float64(len(e.conns)))
+}
+
+// Remove forgets the connection
+func (e *Encryption) Remove(addr string) {
+	e.Lock()
+	defer e.Unlock()
+	delete(e.conns, addr)
+	encryptedConnectionsGauge.Set(float64(len(e.conns)))
+}
+
+// Conns returns the encrypted connections by address
+func (e *Encryption) Conns() map[string]encryptedConn {
+	e.Lock()
+	defer e.Unlock()
+	conns := make(map[string]encryptedConn, len(e.conns))
+	for addr, c := range e.conns {
+		conns[addr] = c
+	}
+	return conns
+}
+
+// Run listens on the address and hands the connections completing the handshake to handle
+func (e *Encryption) Run(address string, handle func(net.Conn) error) error {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return err
+	}
+
+	e.Lock()
+	e.listener = ln
+	e.Unlock()
+
+	logger.Info("Listening for encrypted connections on %s, public key %s", address, e.keypair.Public)
+	for {
+		c, err := ln.Accept()
+		if err != nil {
+			e.Lock()
+			closed := e.listener == nil
+			e.Unlock()
+			if closed {
+				return nil
+			}
+			logger.Error("Accept encrypted connection failed: %v", err)
+			continue
+		}
+
+		// a slow handshake doesn't hold the other connections
+		go func() {
+			nc, err := e.Accept(c)
+			if err != nil {
+				logger.Info("Encrypted handshake with %s failed: %v", c.RemoteAddr(), err)
+				return
+			}
+			if err := handle(nc); err != nil {
+				logger.Error("Add encrypted connection %s failed: %v", nc.RemoteAddr(), err)
+				e.Remove(nc.RemoteAddr().String())
+			}
+		}()
+	}
+}
+
+// Shutdown closes the listener
+func (e *Encryption) Shutdown() {
+	e.Lock()
+	defer e.Unlock()
+	if e.listener != nil {
+		e.listener.Close()
+		e.listener = nil
+	}
+}
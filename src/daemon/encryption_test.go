@@ -0,0 +1,93 @@
+package daemon
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/util/noise"
+)
+
+func TestParseEncryptedPeer(t *testing.T) {
+	kp, err := noise.GenerateKeypair()
+	require.NoError(t, err)
+
+	p, err := parseEncryptedPeer("127.0.0.1:7300")
+	require.NoError(t, err)
+	require.Equal(t, encryptedPeer{Addr: "127.0.0.1:7300"}, p)
+
+	p, err = parseEncryptedPeer(kp.Public.String() + "@127.0.0.1:7300")
+	require.NoError(t, err)
+	require.Equal(t, encryptedPeer{Addr: "127.0.0.1:7300", Key: kp.Public, Pinned: true}, p)
+
+	_, err = parseEncryptedPeer("abcd@127.0.0.1:7300")
+	require.Error(t, err)
+	_, err = parseEncryptedPeer("127.0.0.1")
+	require.Error(t, err)
+}
+
+// pipeDialer returns the client end of a pipe accepted by the encryption of the server
+func pipeDialer(server *Encryption, accepted chan error) func(string) (net.Conn, error) {
+	return func(string) (net.Conn, error) {
+		c1, c2 := net.Pipe()
+		go func() {
+			_, err := server.Accept(c2)
+			accepted <- err
+		}()
+		return c1, nil
+	}
+}
+
+func TestEncryptionDialAccept(t *testing.T) {
+	serverKey, err := noise.GenerateKeypair()
+	require.NoError(t, err)
+	clientKey, err := noise.GenerateKeypair()
+	require.NoError(t, err)
+	otherKey, err := noise.GenerateKeypair()
+	require.NoError(t, err)
+
+	addr := "127.0.0.1:7300"
+	server, err := NewEncryption(serverKey, nil, []string{clientKey.Public.String()}, time.Second)
+	require.NoError(t, err)
+	accepted := make(chan error, 1)
+
+	// the pinned key matches and the client is authorized
+	client, err := NewEncryption(clientKey, []string{serverKey.Public.String() + "@" + addr}, nil,
+		time.Second)
+	require.NoError(t, err)
+	require.True(t, client.IsPeer(addr))
+	require.True(t, client.HasPeerIP("127.0.0.1"))
+	require.False(t, client.IsPeer("127.0.0.1:7200"))
+
+	c, err := client.Dial(addr, pipeDialer(server, accepted))
+	require.NoError(t, err)
+	require.NoError(t, <-accepted)
+	defer c.Close()
+
+	conns := client.Conns()
+	require.Len(t, conns, 1)
+	for _, ec := range conns {
+		require.Equal(t, encryptedConn{Key: serverKey.Public, Outgoing: true}, ec)
+	}
+	for _, ec := range server.Conns() {
+		require.Equal(t, encryptedConn{Key: clientKey.Public}, ec)
+	}
+
+	// the server doesn't have the pinned key
+	other, err := NewEncryption(otherKey, nil, nil, time.Second)
+	require.NoError(t, err)
+	_, err = client.Dial(addr, pipeDialer(other, accepted))
+	require.Equal(t, ErrEncryptionKeyMismatch, err)
+	<-accepted
+
+	// the client isn't authorized
+	unauthorized, err := NewEncryption(otherKey, []string{addr}, nil, time.Second)
+	require.NoError(t, err)
+	unauthorized.Dial(addr, pipeDialer(server, accepted))
+	require.Equal(t, ErrEncryptionUnauthorized, <-accepted)
+
+	_, err = NewEncryption(clientKey, nil, []string{"abcd"}, time.Second)
+	require.Error(t, err)
+}
@@ -0,0 +1,152 @@
+package daemon
+
+import (
+	"net"
+	"sort"
+	"time"
+)
+
+const (
+	// number of the incoming peers that sent new blocks or transactions most recently protected
+	// from the eviction
+	evictionProtectUseful = 4
+	// fraction of the remaining incoming peers connected the longest protected from the eviction
+	evictionProtectLongest = 2
+)
+
+// connectionInfo is the state of a connection the limits and the eviction policy use
+type connectionInfo struct {
+	Addr        string
+	Subnet      string
+	Incoming    bool
+	ConnectedAt time.Time
+	// last time the peer sent a block or a transaction new to us
+	LastUseful time.Time
+}
+
+// connectionTracker tracks the connections by subnet for the limits and the eviction, it's only
+// accessed in the daemon loop
+type connectionTracker struct {
+	conns map[string]*connectionInfo
+}
+
+// newConnectionTracker creates connectionTracker
+func newConnectionTracker() *connectionTracker {
+	return &connectionTracker{
+		conns: make(map[string]*connectionInfo),
+	}
+}
+
+// Add records the connection
+func (cs *connectionTracker) Add(addr string, incoming bool, now time.Time) {
+	cs.conns[addr] = &connectionInfo{
+		Addr:        addr,
+		Subnet:      subnet(addr),
+		Incoming:    incoming,
+		ConnectedAt: now,
+	}
+}
+
+// Remove forgets the connection
+func (cs *connectionTracker) Remove(addr string) {
+	delete(cs.conns, addr)
+}
+
+// MarkUseful records that the peer sent a block or a transaction new to us
+func (cs *connectionTracker) MarkUseful(addr string, now time.Time) {
+	if c, ok := cs.conns[addr]; ok {
+		c.LastUseful = now
+	}
+}
+
+// IncomingLen returns the number of the incoming connections
+func (cs *connectionTracker) IncomingLen() int {
+	n := 0
+	for _, c := range cs.conns {
+		if c.Incoming {
+			n++
+		}
+	}
+	return n
+}
+
+// SubnetCount returns the number of the connections in the subnet of the address
+func (cs *connectionTracker) SubnetCount(addr string) int {
+	s := subnet(addr)
+	n := 0
+	for _, c := range cs.conns {
+		if c.Subnet == s {
+			n++
+		}
+	}
+	return n
+}
+
+// SelectEviction returns the incoming connection to disconnect for a new incoming connection,
+// false if all of them are protected. The peers protected by the caller, e.g. the trusted peers,
+// the peers that sent new blocks or transactions most recently, and then half of the peers
+// connected the longest are not evicted.
+// The youngest connection of the subnet with the most connections is evicted, so an attacker
+// can't take the slots of the long-lived peers by connecting from many addresses.
+func (cs *connectionTracker) SelectEviction(protected func(addr string) bool) (string, bool) {
+	var candidates []*connectionInfo
+	for _, c := range cs.conns {
+		if c.Incoming && !protected(c.Addr) {
+			candidates = append(candidates, c)
+		}
+	}
+
+	// protect the peers useful most recently
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].LastUseful.After(candidates[j].LastUseful)
+	})
+	n := 0
+	for n < len(candidates) && n < evictionProtectUseful && !candidates[n].LastUseful.IsZero() {
+		n++
+	}
+	candidates = candidates[n:]
+
+	// protect the longest-lived peers
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].ConnectedAt.Before(candidates[j].ConnectedAt)
+	})
+	candidates = candidates[len(candidates)/evictionProtectLongest:]
+
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	// the youngest candidate of the subnet with the most incoming connections, the candidates
+	// are sorted by age
+	counts := make(map[string]int)
+	for _, c := range cs.conns {
+		if c.Incoming {
+			counts[c.Subnet]++
+		}
+	}
+
+	evicted := candidates[0]
+	for _, c := range candidates[1:] {
+		if counts[c.Subnet] >= counts[evicted.Subnet] {
+			evicted = c
+		}
+	}
+	return evicted.Addr, true
+}
+
+// subnet returns the /16 of the IPv4 address, or the host of the other addresses
+func subnet(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return host
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		return ip4.Mask(net.CIDRMask(16, 32)).String() + "/16"
+	}
+	return ip.Mask(net.CIDRMask(32, 128)).String() + "/32"
+}
@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSubnet(t *testing.T) {
+	require.Equal(t, "10.1.0.0/16", subnet("10.1.2.3:7200"))
+	require.Equal(t, "10.1.0.0/16", subnet("10.1.200.3:6000"))
+	require.Equal(t, "2001:db8::/32", subnet("[2001:db8::1]:7200"))
+	require.Equal(t, "expyuzz4wqqyqhjn.onion", subnet("expyuzz4wqqyqhjn.onion:7200"))
+}
+
+func TestConnectionsSelectEviction(t *testing.T) {
+	now := time.Now()
+	cs := newConnectionTracker()
+	notProtected := func(addr string) bool { return false }
+
+	_, ok := cs.SelectEviction(notProtected)
+	require.False(t, ok)
+
+	// the outgoing connections are never evicted
+	cs.Add("1.1.1.1:7200", false, now)
+	_, ok = cs.SelectEviction(notProtected)
+	require.False(t, ok)
+
+	// two long-lived peers, one of them useful, and the young peers of the same subnet
+	cs.Add("2.2.2.2:50000", true, now.Add(time.Second))
+	cs.Add("3.3.3.3:50000", true, now.Add(time.Second*2))
+	cs.MarkUseful("3.3.3.3:50000", now.Add(time.Minute))
+	cs.Add("4.4.1.1:50000", true, now.Add(time.Second*3))
+	cs.Add("4.4.2.2:50000", true, now.Add(time.Second*4))
+	cs.Add("5.5.5.5:50000", true, now.Add(time.Second*5))
+	require.Equal(t, 5, cs.IncomingLen())
+	require.Equal(t, 2, cs.SubnetCount("4.4.3.3:7200"))
+
+	// 3.3.3.3 is useful, 2.2.2.2 and 4.4.1.1 are the older half of the rest, the youngest of
+	// the largest subnet is evicted
+	addr, ok := cs.SelectEviction(notProtected)
+	require.True(t, ok)
+	require.Equal(t, "4.4.2.2:50000", addr)
+
+	cs.Remove(addr)
+	addr, ok = cs.SelectEviction(notProtected)
+	require.True(t, ok)
+	require.Equal(t, "5.5.5.5:50000", addr)
+
+	// the protected peers are not evicted
+	addr, ok = cs.SelectEviction(func(addr string) bool {
+		return addr == "5.5.5.5:50000"
+	})
+	require.True(t, ok)
+	require.Equal(t, "4.4.1.1:50000", addr)
+
+	cs.Remove("5.5.5.5:50000")
+	cs.Remove("4.4.1.1:50000")
+	_, ok = cs.SelectEviction(func(addr string) bool {
+		return addr == "2.2.2.2:50000"
+	})
+	require.False(t, ok)
+}
@@ -0,0 +1,153 @@
+package daemon
+
+import (
+	"sort"
+
+	"github.com/skycoin/skycoin/src/daemon/gnet"
+)
+
+// The version of the IntroductionMessage is frozen, the nodes of any version disconnect on
+// a mismatch of it. The protocol changes bump ProtocolVersion instead, and the optional
+// changes are negotiated as features, so the upgraded nodes keep talking to the older ones.
+const (
+	// ProtocolVersion is the version of the wire protocol of the node
+	ProtocolVersion uint32 = 1
+	// MinProtocolVersion is the oldest protocol version of the peers kept connected
+	MinProtocolVersion uint32 = 1
+
+	// featuresMirrorBit is set in the mirror of the IntroductionMessage by the nodes that
+	// understand the FeaturesMessage. The older nodes disconnect on an unknown message, and
+	// the mirror is an opaque random value to them, so the FeaturesMessage is only sent to the
+	// peers whose mirror has the bit.
+	featuresMirrorBit uint32 = 1 << 31
+)
+
+// Features is a bitmask of the optional protocol features
+type Features uint64
+
+const (
+	// FeatureHeadersFirst the peer serves the block headers, see GetHeadersMessage
+	FeatureHeadersFirst Features = 1 << iota
+	// FeatureCompactRelay the peer is announced the new blocks and txns instead of being sent
+	// them, see Relay
+	FeatureCompactRelay
+	// FeatureCompression the peer decodes the CompressedMessage
+	FeatureCompression
+)
+
+// featureNames are the names of the features shown by the api
+var featureNames = map[Features]string{
+	FeatureHeadersFirst: "headers-first",
+	FeatureCompactRelay: "compact-relay",
+	FeatureCompression:  "compression",
+}
+
+// SupportedFeatures are the features the node implements
+var SupportedFeatures = FeatureHeadersFirst | FeatureCompactRelay | FeatureCompression
+
+// Has returns whether all the features of f2 are set
+func (f Features) Has(f2 Features) bool {
+	return f&f2 == f2
+}
+
+// Names returns the names of the features set, the unknown features are skipped
+func (f Features) Names() []string {
+	names := []string{}
+	for feature, name := range featureNames {
+		if f.Has(feature) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// PeerProtocol is the protocol negotiated with a peer. The peers that don't send
+// the FeaturesMessage have the version 0 and no feature, they speak the base protocol.
+type PeerProtocol struct {
+	// the lower of the protocol versions of the peer and the node
+	Version uint32
+	// the features both the peer and the node support
+	Features Features
+}
+
+// negotiateProtocol returns the protocol used with a peer of the version range and features,
+// false if the version ranges don't overlap
+func negotiateProtocol(version, minVersion uint32, features Features) (PeerProtocol, bool) {
+	if version < MinProtocolVersion || minVersion > ProtocolVersion {
+		return PeerProtocol{}, false
+	}
+
+	if version > ProtocolVersion {
+		version = ProtocolVersion
+	}
+	return PeerProtocol{
+		Version:  version,
+		Features: features & SupportedFeatures,
+	}, true
+}
+
+// FeaturesMessage tells the peer the protocol versions and the features the node supports,
+// it's sent after the introduction of the peer is processed
+type FeaturesMessage struct {
+	Version    uint32
+	MinVersion uint32
+	Features   uint64
+	c          *gnet.MessageContext `enc:"-"`
+}
+
+// NewFeaturesMessage creates message
+func NewFeaturesMessage() *FeaturesMessage {
+	return &FeaturesMessage{
+		Version:    ProtocolVersion,
+		MinVersion: MinProtocolVersion,
+		Features:   uint64(SupportedFeatures),
+	}
+}
+
+// Handle handles message
+func (fm *FeaturesMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	fm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(fm, mc)
+}
+
+// Process records the protocol negotiated with the peer
+func (fm *FeaturesMessage) Process(d *Daemon) {
+	p, ok := negotiateProtocol(fm.Version, fm.MinVersion, Features(fm.Features))
+	if !ok {
+		logger.Info("Peer %s protocol versions %d-%d not supported, disconnecting",
+			fm.c.Addr, fm.MinVersion, fm.Version)
+		d.Pool.Pool.Disconnect(fm.c.Addr, ErrDisconnectProtocolVersion)
+		return
+	}
+
+	logger.Debug("Peer %s protocol version %d, features %v", fm.c.Addr, p.Version,
+		p.Features.Names())
+	d.peerProtocols[fm.c.Addr] = p
+
+	if p.Features.Has(FeatureCompactRelay) {
+		d.Visor.relay.SetCompact(fm.c.Addr)
+	}
+	if p.Features.Has(FeatureHeadersFirst) {
+		d.requestHeadersFromAddr(fm.c.Addr)
+	}
+}
+
+// sendFeatures sends the FeaturesMessage to the peer once its introduction is processed,
+// if the peer understands it
+func (dm *Daemon) sendFeatures(addr string) {
+	mirror, ok := dm.connectionMirrors.Get(addr)
+	if !ok || mirror&featuresMirrorBit == 0 {
+		return
+	}
+
+	if err := dm.Pool.Pool.SendMessage(addr, NewFeaturesMessage()); err != nil {
+		logger.Debug("Send FeaturesMessage to %s failed: %v", addr, err)
+	}
+}
+
+// peerHasFeature returns whether the feature is negotiated with the peer
+func (dm *Daemon) peerHasFeature(addr string, f Features) bool {
+	return dm.peerProtocols[addr].Features.Has(f)
+}
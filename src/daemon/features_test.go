@@ -0,0 +1,45 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiateProtocol(t *testing.T) {
+	// the same version
+	p, ok := negotiateProtocol(ProtocolVersion, MinProtocolVersion, SupportedFeatures)
+	require.True(t, ok)
+	require.Equal(t, PeerProtocol{Version: ProtocolVersion, Features: SupportedFeatures}, p)
+
+	// a newer peer still supporting our version, the unknown features are dropped
+	p, ok = negotiateProtocol(ProtocolVersion+1, ProtocolVersion, SupportedFeatures|1<<63)
+	require.True(t, ok)
+	require.Equal(t, ProtocolVersion, p.Version)
+	require.Equal(t, SupportedFeatures, p.Features)
+	require.False(t, p.Features.Has(1<<63))
+
+	// a newer peer not supporting our version
+	_, ok = negotiateProtocol(ProtocolVersion+2, ProtocolVersion+1, 0)
+	require.False(t, ok)
+
+	// a peer older than our min version
+	_, ok = negotiateProtocol(MinProtocolVersion-1, 0, 0)
+	require.False(t, ok)
+}
+
+func TestFeaturesNames(t *testing.T) {
+	defer func(names map[Features]string) {
+		featureNames = names
+	}(featureNames)
+	featureNames = map[Features]string{
+		1: "b",
+		2: "a",
+		4: "c",
+	}
+
+	require.Equal(t, []string{}, Features(0).Names())
+	require.Equal(t, []string{"a", "b"}, Features(3|8).Names())
+	require.True(t, Features(7).Has(5))
+	require.False(t, Features(3).Has(5))
+}
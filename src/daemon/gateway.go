@@ -0,0 +1,1100 @@
+package daemon
+
+import (
+	"errors"
+	"io"
+	"time"
+
+	"github.com/skycoin/skycoin/src/backup"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/daemon/gnet"
+	"github.com/skycoin/skycoin/src/util/nat"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// Exposes a read-only api for use by the gui rpc interface
+
+// GatewayConfig configuration set of gateway.
+type GatewayConfig struct {
+	BufferSize int
+}
+
+// NewGatewayConfig create and init an GatewayConfig
+func NewGatewayConfig() GatewayConfig {
+	return GatewayConfig{
+		BufferSize: 32,
+	}
+}
+
+// Gateway RPC interface wrapper for daemon state
+type Gateway struct {
+	Config GatewayConfig
+	drpc   RPC
+	vrpc   visor.RPC
+
+	// Backref to Daemon
+	d *Daemon
+	// Backref to Visor
+	v *visor.Visor
+	// Requests are queued on this channel
+	requests chan func()
+	// When a request is done processing, it is placed on this channel
+	// Responses chan interface{}
+}
+
+// NewGateway create and init an Gateway instance.
+func NewGateway(c GatewayConfig, D *Daemon) *Gateway {
+	return &Gateway{
+		Config:   c,
+		drpc:     RPC{},
+		vrpc:     visor.RPC{},
+		d:        D,
+		v:        D.Visor.v,
+		requests: make(chan func(), c.BufferSize),
+	}
+}
+
+func (gw *Gateway) strand(f func()) {
+	done := make(chan struct{})
+	gw.requests <- func() {
+		defer close(done)
+		f()
+	}
+	<-done
+}
+
+// GetConnections returns a *Connections
+func (gw *Gateway) GetConnections() interface{} {
+	var conns interface{}
+	gw.strand(func() {
+		conns = gw.drpc.GetConnections(gw.d)
+	})
+	return conns
+}
+
+// GetDefaultConnections returns default connections
+func (gw *Gateway) GetDefaultConnections() interface{} {
+	var conns interface{}
+	gw.strand(func() {
+		conns = gw.drpc.GetDefaultConnections(gw.d)
+	})
+	return conns
+}
+
+// GetConnection returns a *Connection of specific address
+func (gw *Gateway) GetConnection(addr string) interface{} {
+	logger.Critical("here")
+	var conn interface{}
+	gw.strand(func() {
+		conn = gw.drpc.GetConnection(gw.d, addr)
+	})
+	return conn
+}
+
+// GetTrustConnections returns all trusted connections,
+// including private and public
+func (gw *Gateway) GetTrustConnections() interface{} {
+	var conn interface{}
+	gw.strand(func() {
+		conn = gw.drpc.GetTrustConnections(gw.d)
+	})
+	return conn
+}
+
+// GetExchgConnection returns all exchangeable connections,
+// including private and public
+func (gw *Gateway) GetExchgConnection() interface{} {
+	var conn interface{}
+	gw.strand(func() {
+		conn = gw.drpc.GetAllExchgConnections(gw.d)
+	})
+	return conn
+}
+
+/* Blockchain & Transaction status */
+//DEPRECATE
+
+// GetBlockchainProgress returns a *BlockchainProgress
+func (gw *Gateway) GetBlockchainProgress() interface{} {
+	var bcp interface{}
+	gw.strand(func() {
+		bcp = gw.drpc.GetBlockchainProgress(gw.d.Visor)
+	})
+	return bcp
+}
+
+// ResendTransaction resent the transaction and return a *ResendResult
+func (gw *Gateway) ResendTransaction(txn cipher.SHA256) interface{} {
+	var result interface{}
+	gw.strand(func() {
+		result = gw.drpc.ResendTransaction(gw.d.Visor, gw.d.Pool, txn)
+	})
+	return result
+}
+
+// ResendUnconfirmedTxns resents all unconfirmed transactions
+func (gw *Gateway) ResendUnconfirmedTxns() (rlt *ResendResult) {
+	gw.strand(func() {
+		rlt = gw.drpc.ResendUnconfirmedTxns(gw.d.Visor, gw.d.Pool)
+	})
+	return
+}
+
+// GetBlockchainMetadata returns a *visor.BlockchainMetadata
+func (gw *Gateway) GetBlockchainMetadata() interface{} {
+	var bcm interface{}
+	gw.strand(func() {
+		bcm = gw.vrpc.GetBlockchainMetadata(gw.v)
+	})
+	return bcm
+}
+
+// GetBlockByHash returns the block by hash
+func (gw *Gateway) GetBlockByHash(hash cipher.SHA256) (block coin.Block, ok bool) {
+	gw.strand(func() {
+		b := gw.v.GetBlockByHash(hash)
+		if b == nil {
+			return
+		}
+		block = *b
+		ok = true
+	})
+	return
+}
+
+// GetBlockBySeq returns blcok by seq
+func (gw *Gateway) GetBlockBySeq(seq uint64) (block coin.Block, ok bool) {
+	gw.strand(func() {
+		b := gw.v.GetBlockBySeq(seq)
+		if b == nil {
+			return
+		}
+		block = *b
+		ok = true
+	})
+	return
+}
+
+// GetBlockVerbose returns readable block with resolved transaction inputs
+func (gw *Gateway) GetBlockVerbose(b *coin.Block) (rb visor.ReadableBlockVerbose, err error) {
+	gw.strand(func() {
+		rb, err = gw.v.GetReadableBlockVerbose(b)
+	})
+	return
+}
+
+// GetBlocks returns a *visor.ReadableBlocks
+func (gw *Gateway) GetBlocks(start, end uint64) *visor.ReadableBlocks {
+	var blocks *visor.ReadableBlocks
+	gw.strand(func() {
+		blocks = gw.vrpc.GetBlocks(gw.v, start, end)
+	})
+	return blocks
+}
+
+// EncodeBlocksToWriter writes the readable blocks in range of start and end to w as json, the
+// blocks are fetched in batches under the strand and written outside it, so that a slow client
+// doesn't block the daemon
+func (gw *Gateway) EncodeBlocksToWriter(w io.Writer, start, end uint64) error {
+	return visor.EncodeBlocks(w, start, end, func(start, end uint64) (blocks []coin.Block) {
+		gw.strand(func() {
+			blocks = gw.v.GetBlocks(start, end)
+		})
+		return
+	})
+}
+
+// GetBlocksPage returns a page of readable blocks in range of start and end
+func (gw *Gateway) GetBlocksPage(start, end uint64, pageSize int) (*visor.ReadableBlocksPage, error) {
+	var (
+		page *visor.ReadableBlocksPage
+		err  error
+	)
+	gw.strand(func() {
+		page, err = gw.v.GetReadableBlocksInRange(start, end, pageSize)
+	})
+	return page, err
+}
+
+// GetBlocksInDepth returns blocks in different depth
+func (gw *Gateway) GetBlocksInDepth(vs []uint64) *visor.ReadableBlocks {
+	var blocks *visor.ReadableBlocks
+	gw.strand(func() {
+		blks := visor.ReadableBlocks{}
+		for _, n := range vs {
+			if b := gw.vrpc.GetBlockInDepth(gw.v, n); b != nil {
+				blks.Blocks = append(blks.Blocks, *b)
+			}
+		}
+		blocks = &blks
+	})
+	return blocks
+}
+
+// GetLastBlocks get last N blocks
+func (gw *Gateway) GetLastBlocks(num uint64) *visor.ReadableBlocks {
+	var blocks *visor.ReadableBlocks
+	gw.strand(func() {
+		headSeq := gw.v.HeadBkSeq()
+		var start uint64
+		if (headSeq + 1) > num {
+			start = headSeq - num + 1
+		}
+
+		blocks = gw.vrpc.GetBlocks(gw.v, start, headSeq)
+	})
+	return blocks
+}
+
+// OutputsFilter used as optional arguments in GetUnspentOutputs method
+type OutputsFilter func(outputs []visor.ReadableOutput) []visor.ReadableOutput
+
+// GetUnspentOutputs gets unspent outputs and returns the filtered results,
+// Note: all filters will be executed as the pending sequence in 'AND' mode.
+func (gw *Gateway) GetUnspentOutputs(filters ...OutputsFilter) (visor.ReadableOutputSet, error) {
+	var allOutputs []visor.ReadableOutput
+	var spendingOutputs []visor.ReadableOutput
+	var inOutputs []visor.ReadableOutput
+	var err error
+	gw.strand(func() {
+		allOutputs, err = gw.v.GetUnspentOutputReadables()
+		if err != nil {
+			err = fmt.Errorf("get unspent output readables failed: %v", err)
+			return
+		}
+		spendingOutputs, err = gw.v.AllSpendsOutputs()
+		if err != nil {
+			err = fmt.Errorf("get all spends outputs failed: %v", err)
+			return
+		}
+
+		inOutputs, err = gw.v.AllIncomingOutputs()
+		if err != nil {
+			err = fmt.Errorf("get all incomming outputs failed: %v", err)
+			return
+		}
+	})
+
+	if err != nil {
+		return visor.ReadableOutputSet{}, err
+	}
+
+	for _, flt := range filters {
+		allOutputs = flt(allOutputs)
+		spendingOutputs = flt(spendingOutputs)
+		inOutputs = flt(inOutputs)
+	}
+
+	return visor.ReadableOutputSet{
+		HeadOutputs:      allOutputs,
+		OutgoingOutputs:  spendingOutputs,
+		IncommingOutputs: inOutputs,
+	}, nil
+}
+
+// FbyAddressesNotIncluded filters the unspent outputs that are not owned by the addresses
+func FbyAddressesNotIncluded(addrs []string) OutputsFilter {
+	return func(outputs []visor.ReadableOutput) []visor.ReadableOutput {
+		addrMatch := []visor.ReadableOutput{}
+		addrMap := make(map[string]bool)
+		for _, addr := range addrs {
+			addrMap[addr] = false
+		}
+
+		for _, u := range outputs {
+			_, ok := addrMap[u.Address]
+			if !ok {
+				addrMatch = append(addrMatch, u)
+			}
+		}
+		return addrMatch
+	}
+}
+
+// FbyAddresses filters the unspent outputs that owned by the addresses
+func FbyAddresses(addrs []string) OutputsFilter {
+	return func(outputs []visor.ReadableOutput) []visor.ReadableOutput {
+		addrMatch := []visor.ReadableOutput{}
+		addrMap := make(map[string]bool)
+		for _, addr := range addrs {
+			addrMap[addr] = true
+		}
+
+		for _, u := range outputs {
+			if _, ok := addrMap[u.Address]; ok {
+				addrMatch = append(addrMatch, u)
+			}
+		}
+		return addrMatch
+	}
+}
+
+// FbyHashes filters the unspent outputs that have hashes matched.
+func FbyHashes(hashes []string) OutputsFilter {
+	return func(outputs []visor.ReadableOutput) []visor.ReadableOutput {
+		hsMatch := []visor.ReadableOutput{}
+		hsMap := make(map[string]bool)
+		for _, h := range hashes {
+			hsMap[h] = true
+		}
+
+		for _, u := range outputs {
+			if _, ok := hsMap[u.Hash]; ok {
+				hsMatch = append(hsMatch, u)
+			}
+		}
+		return hsMatch
+	}
+}
+
+// GetRichList returns the top N addresses ordered by coins
+func (gw *Gateway) GetRichList(topN int, includeDistribution bool) (*visor.RichList, error) {
+	var (
+		rl  *visor.RichList
+		err error
+	)
+	gw.strand(func() {
+		rl, err = gw.v.RichList(topN, includeDistribution)
+	})
+	return rl, err
+}
+
+// GetSupplyStats returns the distribution statistics of the coin supply
+func (gw *Gateway) GetSupplyStats() (*visor.SupplyStats, error) {
+	var (
+		ss  *visor.SupplyStats
+		err error
+	)
+	gw.strand(func() {
+		ss, err = gw.v.GetSupplyStats()
+	})
+	return ss, err
+}
+
+// GetTransaction returns transaction by txid
+func (gw *Gateway) GetTransaction(txid cipher.SHA256) (tx *visor.Transaction, err error) {
+	gw.strand(func() {
+		tx, err = gw.v.GetTransaction(txid)
+	})
+	return
+}
+
+// GetTransactionHistory gets the status changes of the transaction
+func (gw *Gateway) GetTransactionHistory(txid cipher.SHA256) (chs []visor.TransactionStatusChange) {
+	gw.strand(func() {
+		chs = gw.v.GetTransactionHistory(txid)
+	})
+	return
+}
+
+// GetTransactionProof gets the inclusion proof of the confirmed transaction
+func (gw *Gateway) GetTransactionProof(txid cipher.SHA256) (p *visor.TransactionProof, err error) {
+	gw.strand(func() {
+		p, err = gw.v.GetTransactionProof(txid)
+	})
+	return
+}
+
+// GetTransactionResult gets transaction result by txid.
+func (gw *Gateway) GetTransactionResult(txid cipher.SHA256) (*visor.TransactionResult, error) {
+	var tx *visor.TransactionResult
+	var err error
+	gw.strand(func() {
+		tx, err = gw.vrpc.GetTransaction(gw.v, txid)
+	})
+	return tx, err
+}
+
+// InjectTransaction injects transaction
+func (gw *Gateway) InjectTransaction(txn coin.Transaction) (tx coin.Transaction, err error) {
+	gw.strand(func() {
+		tx, err = gw.d.Visor.InjectTransaction(txn, gw.d.Pool)
+	})
+	return
+}
+
+// InjectTransactions injects the chained txns atomically, returns the txns in the order
+// they are added
+func (gw *Gateway) InjectTransactions(txns coin.Transactions) (ordered coin.Transactions, err error) {
+	gw.strand(func() {
+		ordered, err = gw.d.Visor.InjectTransactions(txns, gw.d.Pool)
+	})
+	return
+}
+
+// GetPendingRebroadcasts returns the locally created txns that are being rebroadcasted
+func (gw *Gateway) GetPendingRebroadcasts() (prs []visor.PendingRebroadcast) {
+	gw.strand(func() {
+		prs = gw.v.GetPendingRebroadcasts()
+	})
+	return
+}
+
+// GetReorgEvents returns the recent reorganizations of the chain
+func (gw *Gateway) GetReorgEvents() (evs []visor.ReorgEvent) {
+	gw.strand(func() {
+		evs = gw.v.GetReorgEvents()
+	})
+	return
+}
+
+// GetDBStats returns the statistics of the db, the db is safe for concurrent reads, so
+// it's not called in the strand.
+func (gw *Gateway) GetDBStats() (*visor.DBStats, error) {
+	return gw.v.GetDBStats()
+}
+
+// StartRebuildIndexes starts rebuilding the indexes from the blocks, the rebuild runs in
+// the blockchain parser, so it's not called in the strand.
+func (gw *Gateway) StartRebuildIndexes() error {
+	return gw.v.StartRebuildIndexes()
+}
+
+// GetIndexProgress returns the progress of rebuilding the indexes
+func (gw *Gateway) GetIndexProgress() visor.IndexProgress {
+	return gw.v.GetIndexProgress()
+}
+
+// RequestCompaction requests compacting the db when the node restarts, the db can't be
+// compacted while it's open
+func (gw *Gateway) RequestCompaction() error {
+	return gw.v.RequestCompaction()
+}
+
+// ErrNetworkingDisabled is returned if the node runs without networking
+var ErrNetworkingDisabled = errors.New("networking is disabled")
+
+// Resync requests the blocks after the head from all the peers and announces the head at once,
+// without waiting for the request interval
+func (gw *Gateway) Resync() (err error) {
+	gw.strand(func() {
+		if gw.d.Config.DisableNetworking {
+			err = ErrNetworkingDisabled
+			return
+		}
+		gw.d.Visor.RequestBlocks(gw.d.Pool)
+		gw.d.Visor.AnnounceBlocks(gw.d.Pool)
+	})
+	return
+}
+
+// ErrBackupsDisabled is returned if the backup dir is not set
+var ErrBackupsDisabled = errors.New("backups are disabled")
+
+// CreateBackup creates a backup of the wallets and the db, the backup manager is safe for
+// concurrent use, so it's not called in the strand.
+func (gw *Gateway) CreateBackup() (*backup.Backup, error) {
+	if gw.d.Backups == nil {
+		return nil, ErrBackupsDisabled
+	}
+	return gw.d.Backups.Create()
+}
+
+// GetBackups returns the backups, the most recent first
+func (gw *Gateway) GetBackups() ([]backup.Backup, error) {
+	if gw.d.Backups == nil {
+		return nil, ErrBackupsDisabled
+	}
+	return gw.d.Backups.List()
+}
+
+// RestoreBackup restores the wallets and/or the db of the backup, the db is restored when
+// the node restarts
+func (gw *Gateway) RestoreBackup(name string, wallets, db bool) error {
+	if gw.d.Backups == nil {
+		return ErrBackupsDisabled
+	}
+	return gw.d.Backups.Restore(name, wallets, db)
+}
+
+// SubscribeEvents subscribes the blockchain events of the types, all events if no type is
+// given. The event bus is safe for concurrent use, so it's not called in the strand.
+func (gw *Gateway) SubscribeEvents(bufSize int, types ...visor.EventType) (<-chan visor.Event, func()) {
+	return gw.v.SubscribeEvents(bufSize, types...)
+}
+
+// WatchAddresses adds the addresses to the watch list
+func (gw *Gateway) WatchAddresses(addrs []cipher.Address) (err error) {
+	gw.strand(func() {
+		err = gw.v.WatchAddresses(addrs)
+	})
+	return
+}
+
+// UnwatchAddresses removes the addresses from the watch list
+func (gw *Gateway) UnwatchAddresses(addrs []cipher.Address) {
+	gw.strand(func() {
+		gw.v.UnwatchAddresses(addrs)
+	})
+}
+
+// PeerLimits are the connection limits of DaemonConfig
+type PeerLimits struct {
+	OutgoingMax     int
+	IncomingMax     int
+	IPCountsMax     int
+	SubnetCountsMax int
+}
+
+// SetPeerLimits changes the number of the outgoing connections maintained, the number of the
+// incoming connections and the numbers of the connections allowed from the same base ip and
+// subnet, the existing connections are kept
+func (gw *Gateway) SetPeerLimits(limits PeerLimits) {
+	gw.strand(func() {
+		gw.d.Config.OutgoingMax = limits.OutgoingMax
+		gw.d.Config.IncomingMax = limits.IncomingMax
+		gw.d.Config.IPCountsMax = limits.IPCountsMax
+		gw.d.Config.SubnetCountsMax = limits.SubnetCountsMax
+	})
+}
+
+// ProxyStatus is the proxy config of the outgoing connections
+type ProxyStatus struct {
+	Proxy        string `json:"proxy"`
+	ProxyOnly    bool   `json:"proxy_only"`
+	OnionAddress string `json:"onion_address"`
+}
+
+// GetProxyStatus returns the proxy config and the onion address of the node
+func (gw *Gateway) GetProxyStatus() ProxyStatus {
+	var s ProxyStatus
+	gw.strand(func() {
+		s = ProxyStatus{
+			Proxy:        gw.d.Config.Proxy,
+			ProxyOnly:    gw.d.Config.ProxyOnly,
+			OnionAddress: gw.d.Config.OnionAddress,
+		}
+	})
+	return s
+}
+
+// ErrPortMappingDisabled is returned if the port mapping is disabled
+var ErrPortMappingDisabled = errors.New("port mapping is disabled")
+
+// GetPortMappingStatus returns the external address and port mapped on the router
+func (gw *Gateway) GetPortMappingStatus() (nat.Status, error) {
+	if gw.d.PortMapper == nil {
+		return nat.Status{}, ErrPortMappingDisabled
+	}
+	return gw.d.PortMapper.Status(), nil
+}
+
+// EncryptionStatus is the encrypted transport config and connections
+type EncryptionStatus struct {
+	Port int `json:"port"`
+	// hex static public key of the node
+	PublicKey      string   `json:"public_key"`
+	AuthorizedKeys []string `json:"authorized_keys"`
+	Peers          []string `json:"peers"`
+	// encrypted connections by address
+	Connections map[string]EncryptedConnStatus `json:"connections"`
+}
+
+// EncryptedConnStatus is an encrypted connection
+type EncryptedConnStatus struct {
+	PublicKey string `json:"public_key"`
+	Outgoing  bool   `json:"outgoing"`
+}
+
+// GetEncryptionStatus returns the public key of the node and the encrypted connections
+func (gw *Gateway) GetEncryptionStatus() (EncryptionStatus, error) {
+	e := gw.d.encryption
+	if e == nil {
+		return EncryptionStatus{}, ErrEncryptionDisabled
+	}
+
+	s := EncryptionStatus{
+		Port:           gw.d.Config.EncryptedPort,
+		PublicKey:      e.PublicKey().String(),
+		AuthorizedKeys: append([]string{}, gw.d.Config.AuthorizedKeys...),
+		Peers:          append([]string{}, gw.d.Config.EncryptedPeers...),
+		Connections:    make(map[string]EncryptedConnStatus),
+	}
+	for addr, c := range e.Conns() {
+		s.Connections[addr] = EncryptedConnStatus{
+			PublicKey: c.Key.String(),
+			Outgoing:  c.Outgoing,
+		}
+	}
+	return s, nil
+}
+
+// ProtocolStatus is the protocol of the node and the protocols negotiated with the peers
+type ProtocolStatus struct {
+	Version    uint32   `json:"version"`
+	MinVersion uint32   `json:"min_version"`
+	Features   []string `json:"features"`
+	// negotiated protocols by the address of the connection
+	Peers map[string]PeerProtocolStatus `json:"peers"`
+}
+
+// PeerProtocolStatus is the protocol negotiated with a peer, the version 0 is the base protocol
+// of the peers that don't negotiate
+type PeerProtocolStatus struct {
+	Version  uint32   `json:"version"`
+	Features []string `json:"features"`
+}
+
+// GetProtocolStatus returns the protocol versions and features of the node and of the connections
+func (gw *Gateway) GetProtocolStatus() (ProtocolStatus, error) {
+	s := ProtocolStatus{
+		Version:    ProtocolVersion,
+		MinVersion: MinProtocolVersion,
+		Features:   SupportedFeatures.Names(),
+		Peers:      make(map[string]PeerProtocolStatus),
+	}
+
+	var err error
+	gw.strand(func() {
+		var conns []gnet.Connection
+		conns, err = gw.d.Pool.Pool.GetConnections()
+		if err != nil {
+			return
+		}
+
+		for _, c := range conns {
+			p := gw.d.peerProtocols[c.Addr()]
+			s.Peers[c.Addr()] = PeerProtocolStatus{
+				Version:  p.Version,
+				Features: p.Features.Names(),
+			}
+		}
+	})
+	if err != nil {
+		return ProtocolStatus{}, err
+	}
+	return s, nil
+}
+
+// BlockSyncStatus is the progress of the headers-first block download
+type BlockSyncStatus struct {
+	// seq of the head block
+	Head uint64 `json:"head"`
+	// seq of the last verified header
+	LastHeader uint64 `json:"last_header"`
+	// whether the blocks of the verified headers are downloading
+	Syncing  bool               `json:"syncing"`
+	Requests []BlockRangeStatus `json:"requests"`
+	Peers    []PeerSyncStatus   `json:"peers"`
+}
+
+// BlockRangeStatus is a range of blocks requested from a peer
+type BlockRangeStatus struct {
+	Addr  string `json:"addr"`
+	Start uint64 `json:"start"`
+	Count uint64 `json:"count"`
+	// unix time of the request
+	RequestedAt int64 `json:"requested_at"`
+}
+
+// GetBlockSyncStatus returns the ranges of blocks in flight and the download throughput of
+// the peers
+func (gw *Gateway) GetBlockSyncStatus() BlockSyncStatus {
+	var s BlockSyncStatus
+	gw.strand(func() {
+		bs := gw.d.blockSync
+		if !gw.d.Visor.Config.Disabled {
+			s.Head = gw.d.Visor.HeadBkSeq()
+		}
+		s.LastHeader = bs.LastSeq()
+		s.Syncing = bs.Active(s.Head)
+		s.Requests = []BlockRangeStatus{}
+		for _, r := range bs.Requests() {
+			s.Requests = append(s.Requests, BlockRangeStatus{
+				Addr:        r.Addr,
+				Start:       r.Start,
+				Count:       r.Count,
+				RequestedAt: r.RequestedAt.Unix(),
+			})
+		}
+		s.Peers = bs.Stats(utc.Now())
+	})
+	return s
+}
+
+// GetWatchedAddresses returns the watched addresses
+func (gw *Gateway) GetWatchedAddresses() (addrs []string) {
+	gw.strand(func() {
+		addrs = gw.v.GetWatchedAddresses()
+	})
+	return
+}
+
+// GetAddressHours returns the coin hours of the unspent outputs of the address, at the
+// head time and projected at the unix time at
+func (gw *Gateway) GetAddressHours(addr cipher.Address, at uint64) (*visor.HoursProjection, error) {
+	var (
+		hp  *visor.HoursProjection
+		err error
+	)
+	gw.strand(func() {
+		hp, err = gw.v.GetAddressHours(addr, at)
+	})
+	return hp, err
+}
+
+// GetUxOutHours returns the coin hours of the unspent output, at the head time and
+// projected at the unix time at
+func (gw *Gateway) GetUxOutHours(uxid cipher.SHA256, at uint64) (*visor.HoursProjection, error) {
+	var (
+		hp  *visor.HoursProjection
+		err error
+	)
+	gw.strand(func() {
+		hp, err = gw.v.GetUxOutHours(uxid, at)
+	})
+	return hp, err
+}
+
+// VerifyTxnAgainstState checks the txn against the unspent pool and the unconfirmed
+// pool without injecting it
+func (gw *Gateway) VerifyTxnAgainstState(txn coin.Transaction) (visor.TxnPreview, error) {
+	var (
+		pv  visor.TxnPreview
+		err error
+	)
+	gw.strand(func() {
+		pv, err = gw.v.VerifyTxnAgainstState(txn)
+	})
+	return pv, err
+}
+
+// GetFeeEstimate estimates the fee per byte to get a txn confirmed in targetBlocks blocks
+func (gw *Gateway) GetFeeEstimate(targetBlocks int) (*visor.FeeEstimate, error) {
+	var (
+		fe  *visor.FeeEstimate
+		err error
+	)
+	gw.strand(func() {
+		fe, err = gw.v.GetFeeEstimate(targetBlocks)
+	})
+	return fe, err
+}
+
+// GetAddressSummary returns the aggregated activity of the address, nil if it never appears
+func (gw *Gateway) GetAddressSummary(addr cipher.Address) (*visor.AddressSummary, error) {
+	var (
+		as  *visor.AddressSummary
+		err error
+	)
+	gw.strand(func() {
+		as, err = gw.v.GetAddressSummary(addr)
+	})
+	return as, err
+}
+
+// CancelRebroadcast stops rebroadcasting the txn, returns false if it's not pending
+func (gw *Gateway) CancelRebroadcast(txid cipher.SHA256) (ok bool) {
+	gw.strand(func() {
+		ok = gw.v.CancelRebroadcast(txid)
+	})
+	return
+}
+
+// GetAddressTxns returns a *visor.TransactionResults
+func (gw *Gateway) GetAddressTxns(a cipher.Address) (tx *visor.TransactionResults, err error) {
+	gw.strand(func() {
+		tx, err = gw.vrpc.GetAddressTxns(gw.v, a)
+	})
+	return
+}
+
+// GetAddressTxnsPage returns a page of the transactions of the address
+func (gw *Gateway) GetAddressTxnsPage(addr cipher.Address, cursor uint64, pageSize int) (page *visor.AddressTxnsPage, err error) {
+	gw.strand(func() {
+		page, err = gw.v.GetAddressTxnsPage(addr, cursor, pageSize)
+	})
+	return
+}
+
+// GetAddressUxOutsPage returns a page of the outputs of the address
+func (gw *Gateway) GetAddressUxOutsPage(addr cipher.Address, cursor uint64, pageSize int) (page *visor.AddressUxOutsPage, err error) {
+	gw.strand(func() {
+		page, err = gw.v.GetAddressUxOutsPage(addr, cursor, pageSize)
+	})
+	return
+}
+
+// GetUxOutByID gets UxOut by hash id.
+func (gw *Gateway) GetUxOutByID(id cipher.SHA256) (*historydb.UxOut, error) {
+	var uxout *historydb.UxOut
+	var err error
+	gw.strand(func() {
+		uxout, err = gw.v.GetUxOutByID(id)
+	})
+	return uxout, err
+}
+
+// GetAddrUxOuts gets all the address affected UxOuts.
+func (gw *Gateway) GetAddrUxOuts(addr cipher.Address) ([]*historydb.UxOutJSON, error) {
+	var (
+		uxouts []*historydb.UxOut
+		err    error
+	)
+	gw.strand(func() {
+		uxouts, err = gw.v.GetAddrUxOuts(addr)
+	})
+	uxs := make([]*historydb.UxOutJSON, len(uxouts))
+	for i, ux := range uxouts {
+		uxs[i] = historydb.NewUxOutJSON(ux)
+	}
+	return uxs, err
+}
+
+// GetUxOutVerbose gets the verbose readable UxOut of given id.
+func (gw *Gateway) GetUxOutVerbose(id cipher.SHA256) (*visor.ReadableOutputVerbose, error) {
+	var (
+		uxout *visor.ReadableOutputVerbose
+		err   error
+	)
+	gw.strand(func() {
+		uxout, err = gw.v.GetReadableOutputVerbose(id)
+	})
+	return uxout, err
+}
+
+// GetAddrUxOutsVerbose gets all the address affected UxOuts in verbose readable format.
+func (gw *Gateway) GetAddrUxOutsVerbose(addr cipher.Address) ([]visor.ReadableOutputVerbose, error) {
+	var (
+		uxouts []visor.ReadableOutputVerbose
+		err    error
+	)
+	gw.strand(func() {
+		uxouts, err = gw.v.GetAddrReadableOutputsVerbose(addr)
+	})
+	return uxouts, err
+}
+
+// GetAddressUxOuts gets all the address affected UxOuts.
+func (gw *Gateway) GetAddressUxOuts(addr cipher.Address) ([]*historydb.UxOut, error) {
+	var (
+		uxouts []*historydb.UxOut
+		err    error
+	)
+	gw.strand(func() {
+		uxouts, err = gw.v.GetAddrUxOuts(addr)
+	})
+	return uxouts, err
+}
+
+// MaxMessageLength returns the max length of the messages to the peers, a txn larger than
+// it can't be broadcast
+func (gw *Gateway) MaxMessageLength() int {
+	return gw.d.Pool.Pool.Config.MaxMessageLength
+}
+
+// GetTimeNow returns the current Unix time
+func (gw *Gateway) GetTimeNow() uint64 {
+	return uint64(time.Now().Unix())
+}
+
+// GetAllUnconfirmedTxns returns all unconfirmed transactions
+func (gw *Gateway) GetAllUnconfirmedTxns() (txns []visor.UnconfirmedTxn) {
+	gw.strand(func() {
+		txns = gw.v.GetAllUnconfirmedTxns()
+	})
+	return
+}
+
+// GetReadableUnconfirmedTxns returns all readable unconfirmed transactions sorted by received time
+func (gw *Gateway) GetReadableUnconfirmedTxns() (txns []visor.ReadableUnconfirmedTxn) {
+	gw.strand(func() {
+		txns = gw.v.GetReadableUnconfirmedTxns()
+	})
+	return
+}
+
+// GetUnconfirmedTxnsForAddress returns readable unconfirmed transactions related to the address
+func (gw *Gateway) GetUnconfirmedTxnsForAddress(addr cipher.Address) (txns []visor.ReadableUnconfirmedTxn) {
+	gw.strand(func() {
+		txns = gw.v.GetUnconfirmedTxnsForAddress(addr)
+	})
+	return
+}
+
+// GetUnconfirmedTxns returns addresses related unconfirmed transactions
+func (gw *Gateway) GetUnconfirmedTxns(addrs []cipher.Address) (txns []visor.UnconfirmedTxn) {
+	gw.strand(func() {
+		txns = gw.v.GetUnconfirmedTxns(visor.ToAddresses(addrs))
+	})
+	return
+}
+
+// GetLastTxs returns last confirmed transactions, return nil if empty
+func (gw *Gateway) GetLastTxs() (txns []*visor.Transaction, err error) {
+	gw.strand(func() {
+		txns, err = gw.v.GetLastTxs()
+	})
+	return
+}
+
+// GetUnspent returns the unspent pool
+func (gw *Gateway) GetUnspent() (unspent *blockdb.UnspentPool) {
+	gw.strand(func() {
+		unspent = gw.vrpc.GetUnspent(gw.v)
+	})
+	return
+}
+
+// CreateSpendingTransaction creates spending transactions, the outputs are chosen by sel
+// and the change is sent to the change address, or the first spent address if it's not set.
+// The inputs are signed by signer, the keys of the wallet are used if it's nil.
+func (gw *Gateway) CreateSpendingTransaction(wlt wallet.Wallet,
+	amt wallet.Balance,
+	dest, change cipher.Address, sel visor.CoinSelector, signer wallet.TxnSigner) (tx coin.Transaction, err error) {
+	var spends coin.UxArray
+	gw.strand(func() {
+		tx, spends, err = gw.vrpc.BuildSpendingTransaction(gw.v, wlt, amt, dest, change, sel)
+	})
+	if err != nil {
+		return
+	}
+
+	// the external signer may wait for the user to confirm on the device, so the txn is
+	// signed out of the strand
+	if signer == nil {
+		signer = wallet.NewKeySigner(&wlt)
+	}
+	return visor.SignSpends(tx, spends, signer)
+}
+
+// CreateUnsignedTransaction creates the txn sending amt to dest from the addresses without
+// signing it, the multisig addresses of the addresses must be in multisigs
+func (gw *Gateway) CreateUnsignedTransaction(addrs []cipher.Address, amt wallet.Balance,
+	dest, change cipher.Address, multisigs []wallet.Multisig,
+	sel visor.CoinSelector) (ut *visor.UnsignedTransaction, err error) {
+	gw.strand(func() {
+		ut, err = gw.v.CreateUnsignedTransaction(addrs, amt, dest, change, multisigs, sel)
+	})
+	return
+}
+
+// CreateSweepTransaction creates the signed transaction sending all the unspent outputs of
+// the secret key to dest
+func (gw *Gateway) CreateSweepTransaction(sec cipher.SecKey, dest cipher.Address) (txn coin.Transaction, err error) {
+	gw.strand(func() {
+		txn, err = gw.v.CreateSweepTransaction(sec, dest)
+	})
+	return
+}
+
+// PreviewSpend previews sending amt to dest from the addresses without signing it, the
+// multisig addresses of the addresses must be in multisigs
+func (gw *Gateway) PreviewSpend(addrs []cipher.Address, amt wallet.Balance,
+	dest, change cipher.Address, multisigs []wallet.Multisig,
+	sel visor.CoinSelector) (pv *visor.SpendPreview, err error) {
+	gw.strand(func() {
+		pv, err = gw.v.PreviewSpend(addrs, amt, dest, change, multisigs, sel)
+	})
+	return
+}
+
+// WalletBalance returns balance pair of specific wallet
+func (gw *Gateway) WalletBalance(wlt wallet.Wallet) (balance wallet.BalancePair, err error) {
+	gw.strand(func() {
+
+		auxs := gw.vrpc.GetUnspent(gw.v).GetUnspentsOfAddrs(wlt.GetAddresses())
+
+		puxs, err := gw.vrpc.GetUnconfirmedSpends(gw.v, wlt.GetAddresses())
+		if err != nil {
+			err = fmt.Errorf("get unconfimed spends failed when checking wallet balance: %v", err)
+			return
+		}
+
+		coins1, hours1 := gw.v.AddressBalance(auxs)
+		coins2, hours2 := gw.v.AddressBalance(auxs.Sub(puxs))
+		balance = wallet.BalancePair{
+			Confirmed: wallet.Balance{Coins: coins1, Hours: hours1},
+			Predicted: wallet.Balance{Coins: coins2, Hours: hours2},
+		}
+	})
+	return
+}
+
+// AddressesBalance gets balance of given addresses
+func (gw *Gateway) AddressesBalance(addrs []cipher.Address) (balance wallet.BalancePair, err error) {
+	gw.strand(func() {
+		auxs := gw.vrpc.GetUnspent(gw.v).GetUnspentsOfAddrs(addrs)
+
+		puxs, err := gw.vrpc.GetUnconfirmedSpends(gw.v, addrs)
+		if err != nil {
+			err = fmt.Errorf("get unconfirmed spends failed when checking addresses balance: %v", err)
+			return
+		}
+
+		coins1, hours1 := gw.v.AddressBalance(auxs)
+		coins2, hours2 := gw.v.AddressBalance(auxs.Sub(puxs))
+		balance = wallet.BalancePair{
+			Confirmed: wallet.Balance{Coins: coins1, Hours: hours1},
+			Predicted: wallet.Balance{Coins: coins2, Hours: hours2},
+		}
+	})
+	return
+}
+
+// GetBalances gets the balance of each address
+func (gw *Gateway) GetBalances(addrs []cipher.Address) (bals map[string]wallet.BalancePair, err error) {
+	gw.strand(func() {
+		bals, err = gw.v.GetBalances(addrs)
+	})
+	return
+}
+
+// GetBlocksCSVRecords returns the CSV records of the output movements of blocks in the seq
+// range, the records are written by visor.WriteCSV outside of the strand.
+func (gw *Gateway) GetBlocksCSVRecords(start, end uint64) (records [][]string, err error) {
+	gw.strand(func() {
+		records, err = gw.v.BlocksCSVRecords(start, end)
+	})
+	return
+}
+
+// GetAddressCSVRecords returns the CSV records of the output movements of the address
+func (gw *Gateway) GetAddressCSVRecords(addr cipher.Address) (records [][]string, err error) {
+	gw.strand(func() {
+		records, err = gw.v.AddressCSVRecords(addr)
+	})
+	return
+}
+
+// AddressesUsed checks whether each of the addresses has been used in the blockchain or in
+// the unconfirmed txns
+func (gw *Gateway) AddressesUsed(addrs []cipher.Address) (used []bool, err error) {
+	gw.strand(func() {
+		used, err = gw.v.AddressesUsed(addrs)
+	})
+	return
+}
+
+// GetWalletHistory returns the transactions affecting the addresses of the wallet with
+// the running balance
+func (gw *Gateway) GetWalletHistory(wlt wallet.Wallet) (entries []visor.WalletHistoryEntry, err error) {
+	gw.strand(func() {
+		entries, err = gw.v.GetWalletHistory(wlt.GetAddresses())
+	})
+	return
+}
+
+// GetWalletTxn returns the wallet history entry of the txn for the addresses, nil if the
+// txn doesn't affect them
+func (gw *Gateway) GetWalletTxn(txn coin.Transaction, confirmed bool, addrs []cipher.Address) (e *visor.WalletHistoryEntry, err error) {
+	gw.strand(func() {
+		e, err = gw.v.GetWalletTxn(&txn, confirmed, addrs)
+	})
+	return
+}
+
+// GetWalletDir returns wallet dir path
+func (gw *Gateway) GetWalletDir() string {
+	return gw.d.Config.DataDirectory + "/wallets"
+}
+
+
@@ -0,0 +1,13 @@
+package gnet
+
+// EncodeMessage serializes the message with its id and without the length prefix, so it can be
+// carried inside another message, e.g. compressed
+func EncodeMessage(msg Message) []byte {
+	// skip the length prefix
+	return encodeMessage(msg)[4:]
+}
+
+// DecodeMessage deserializes a message serialized by EncodeMessage
+func DecodeMessage(data []byte) (Message, error) {
+	return convertToMessage(0, data, false)
+}
@@ -0,0 +1,42 @@
+package gnet
+
+import (
+	"net"
+)
+
+// ConnectConn adds a connection dialed by the caller, e.g. through a SOCKS5 proxy, to the pool
+// as if it was dialed by Connect. The connection is keyed by its RemoteAddr, so a proxied
+// connection must report the address of the peer instead of the proxy.
+func (pool *ConnectionPool) ConnectConn(conn net.Conn) error {
+	exist, err := pool.IsConnExist(conn.RemoteAddr().String())
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if exist {
+		conn.Close()
+		return nil
+	}
+
+	go pool.handleConnection(conn, true)
+	return nil
+}
+
+// AcceptConn adds a connection accepted by the caller, e.g. on another listener after a
+// handshake, to the pool as if it was accepted by the pool's listener
+func (pool *ConnectionPool) AcceptConn(conn net.Conn) error {
+	exist, err := pool.IsConnExist(conn.RemoteAddr().String())
+	if err != nil {
+		conn.Close()
+		return err
+	}
+
+	if exist {
+		conn.Close()
+		return nil
+	}
+
+	go pool.handleConnection(conn, false)
+	return nil
+}
@@ -0,0 +1,202 @@
+package daemon
+
+import (
+	"sort"
+
+	"github.com/skycoin/skycoin/src/daemon/gnet"
+	"github.com/skycoin/skycoin/src/util/utc"
+)
+
+// GetHeadersMessage requests the signed headers since LastBlock, it's sent to the peers that
+// negotiated FeatureHeadersFirst
+type GetHeadersMessage struct {
+	LastBlock        uint64
+	RequestedHeaders uint64
+	c                *gnet.MessageContext `enc:"-"`
+}
+
+// NewGetHeadersMessage creates GetHeadersMessage
+func NewGetHeadersMessage(lastBlock uint64, requestedHeaders uint64) *GetHeadersMessage {
+	return &GetHeadersMessage{
+		LastBlock:        lastBlock,
+		RequestedHeaders: requestedHeaders,
+	}
+}
+
+// Handle handles message
+func (ghm *GetHeadersMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	ghm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(ghm, mc)
+}
+
+// Process sends the signed headers since LastBlock
+func (ghm *GetHeadersMessage) Process(d *Daemon) {
+	if d.Visor.Config.Disabled {
+		return
+	}
+	d.Visor.RecordBlockchainLength(ghm.c.Addr, ghm.LastBlock)
+
+	count := ghm.RequestedHeaders
+	if count > d.Visor.Config.HeadersResponseCount {
+		count = d.Visor.Config.HeadersResponseCount
+	}
+	headers := d.Visor.GetSignedHeadersSince(ghm.LastBlock, count)
+	logger.Debug("Got %d headers since %d", len(headers), ghm.LastBlock)
+	if len(headers) == 0 {
+		return
+	}
+	d.sendMessage(ghm.c.Addr, NewGiveHeadersMessage(headers))
+}
+
+// GiveHeadersMessage sent in response to GetHeadersMessage
+type GiveHeadersMessage struct {
+	Headers []SignedHeader
+	c       *gnet.MessageContext `enc:"-"`
+}
+
+// NewGiveHeadersMessage creates GiveHeadersMessage
+func NewGiveHeadersMessage(headers []SignedHeader) *GiveHeadersMessage {
+	return &GiveHeadersMessage{
+		Headers: headers,
+	}
+}
+
+// Handle handles message
+func (ghm *GiveHeadersMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	ghm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(ghm, mc)
+}
+
+// Process verifies the headers, then requests the next headers from the peer and the bodies
+// from the peers serving the headers
+func (ghm *GiveHeadersMessage) Process(d *Daemon) {
+	if d.Visor.Config.Disabled {
+		return
+	}
+
+	d.syncHead()
+	n, err := d.blockSync.AddHeaders(ghm.Headers)
+	switch err {
+	case nil:
+	case ErrHeaderInvalidSignature:
+		logger.Info("Peer %s sent headers with invalid signatures, disconnecting", ghm.c.Addr)
+		d.Pool.Pool.Disconnect(ghm.c.Addr, ErrDisconnectInvalidHeaders)
+		return
+	case ErrHeaderNotConnected:
+		// the peer is on another branch, the blocks are downloaded one by one so the fork
+		// point is found
+		logger.Info("Headers from %s are not connected, requesting blocks", ghm.c.Addr)
+		m := NewGetBlocksMessage(d.Visor.HeadBkSeq(), d.Visor.Config.BlocksResponseCount)
+		d.Pool.Pool.SendMessage(ghm.c.Addr, m)
+		return
+	}
+
+	if n > 0 {
+		logger.Debug("Verified %d headers from %s, last header %d", n, ghm.c.Addr,
+			d.blockSync.LastSeq())
+	}
+
+	// a full answer, the peer has more headers
+	if n > 0 && uint64(len(ghm.Headers)) >= d.Visor.Config.HeadersResponseCount {
+		d.requestHeadersFromAddr(ghm.c.Addr)
+	}
+	d.requestBlockBodies()
+}
+
+// headersPeers returns the connections serving the headers
+func (dm *Daemon) headersPeers() []string {
+	var addrs []string
+	for addr, p := range dm.peerProtocols {
+		if p.Features.Has(FeatureHeadersFirst) {
+			addrs = append(addrs, addr)
+		}
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// requestHeadersFromAddr requests the headers after the last verified header from the peer
+func (dm *Daemon) requestHeadersFromAddr(addr string) {
+	if dm.Visor.Config.Disabled {
+		return
+	}
+
+	dm.syncHead()
+	m := NewGetHeadersMessage(dm.blockSync.LastSeq(), dm.Visor.Config.HeadersResponseCount)
+	if err := dm.Pool.Pool.SendMessage(addr, m); err != nil {
+		logger.Debug("Send GetHeadersMessage to %s failed: %v", addr, err)
+	}
+}
+
+// requestHeaders requests the headers from all the peers serving them
+func (dm *Daemon) requestHeaders() {
+	for _, addr := range dm.headersPeers() {
+		dm.requestHeadersFromAddr(addr)
+	}
+}
+
+// requestBlockBodies requests the bodies of the verified headers from the peers serving the
+// headers, the ranges are spread over the peers
+func (dm *Daemon) requestBlockBodies() {
+	if dm.Visor.Config.Disabled {
+		return
+	}
+
+	head := dm.Visor.HeadBkSeq()
+	for _, r := range dm.blockSync.Schedule(dm.headersPeers(), head, utc.Now()) {
+		m := NewGetBlocksMessage(r.Start-1, r.Count)
+		if err := dm.Pool.Pool.SendMessage(r.Addr, m); err != nil {
+			logger.Debug("Send GetBlocksMessage to %s failed: %v", r.Addr, err)
+		}
+	}
+}
+
+// reassignStalledBlocks requests the ranges not answered in time from the other peers
+func (dm *Daemon) reassignStalledBlocks() {
+	if dm.Visor.Config.Disabled {
+		return
+	}
+
+	stalled := dm.blockSync.CheckStalls(utc.Now())
+	if len(stalled) == 0 {
+		return
+	}
+	for _, addr := range stalled {
+		logger.Info("Peer %s didn't send the blocks requested in %v, requesting them from the other peers",
+			addr, dm.Visor.Config.BlocksRequestTimeout)
+		blockRequestStallsCounter.Inc()
+	}
+	dm.requestBlockBodies()
+}
+
+// applySyncedBlocks executes the bodies received ahead of the head in order, returns the number
+// of the blocks executed
+func (dm *Daemon) applySyncedBlocks() int {
+	n := 0
+	for {
+		b, ok := dm.blockSync.Next(dm.Visor.HeadBkSeq())
+		if !ok {
+			break
+		}
+
+		if err := dm.Visor.ExecuteSignedBlock(b); err != nil {
+			logger.Critical("Failed to execute synced block %d: %v", b.Block.Head.BkSeq, err)
+			dm.blockSync.DropBlock(b.Block.Head.BkSeq)
+			break
+		}
+		logger.Critical("Added new block %d", b.Block.Head.BkSeq)
+		n++
+	}
+
+	dm.syncHead()
+	return n
+}
+
+// syncHead prunes the headers and bodies of the block sync up to the head of the chain
+func (dm *Daemon) syncHead() {
+	if seq, hash, ok := dm.Visor.HeadBlock(); ok {
+		dm.blockSync.SetHead(seq, hash)
+	}
+}
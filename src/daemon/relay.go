@@ -0,0 +1,107 @@
+package daemon
+
+import (
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// knownTxns is a rolling set of the txn hashes a peer has, the oldest hash is forgotten when
+// it's full
+type knownTxns struct {
+	hashes map[cipher.SHA256]struct{}
+	ring   []cipher.SHA256
+	next   int
+}
+
+func newKnownTxns(max int) *knownTxns {
+	return &knownTxns{
+		hashes: make(map[cipher.SHA256]struct{}, max),
+		ring:   make([]cipher.SHA256, 0, max),
+	}
+}
+
+func (k *knownTxns) has(h cipher.SHA256) bool {
+	_, ok := k.hashes[h]
+	return ok
+}
+
+func (k *knownTxns) add(h cipher.SHA256) {
+	if k.has(h) || cap(k.ring) == 0 {
+		return
+	}
+
+	if len(k.ring) < cap(k.ring) {
+		k.ring = append(k.ring, h)
+	} else {
+		delete(k.hashes, k.ring[k.next])
+		k.ring[k.next] = h
+		k.next = (k.next + 1) % len(k.ring)
+	}
+	k.hashes[h] = struct{}{}
+}
+
+// Relay tracks the txns the peers have, so a txn is announced once to a peer and the txns are
+// only sent to the peers asking for them. The peers that negotiated FeatureCompactRelay are
+// announced the new blocks and txns, the older peers are sent them. It's only accessed in the
+// daemon loop.
+type Relay struct {
+	// the hashes remembered per peer
+	knownMax int
+	peers    map[string]*knownTxns
+	compact  map[string]bool
+}
+
+// NewRelay creates Relay
+func NewRelay(knownMax int) *Relay {
+	return &Relay{
+		knownMax: knownMax,
+		peers:    make(map[string]*knownTxns),
+		compact:  make(map[string]bool),
+	}
+}
+
+// SetCompact records that the peer negotiated FeatureCompactRelay
+func (r *Relay) SetCompact(addr string) {
+	r.compact[addr] = true
+}
+
+// Compact returns whether the peer negotiated FeatureCompactRelay
+func (r *Relay) Compact(addr string) bool {
+	return r.compact[addr]
+}
+
+// RemovePeer forgets the peer
+func (r *Relay) RemovePeer(addr string) {
+	delete(r.peers, addr)
+	delete(r.compact, addr)
+}
+
+func (r *Relay) known(addr string) *knownTxns {
+	k, ok := r.peers[addr]
+	if !ok {
+		k = newKnownTxns(r.knownMax)
+		r.peers[addr] = k
+	}
+	return k
+}
+
+// MarkKnown records that the peer has the txns
+func (r *Relay) MarkKnown(addr string, hashes []cipher.SHA256) {
+	k := r.known(addr)
+	for _, h := range hashes {
+		k.add(h)
+	}
+}
+
+// Unknown returns the txns the peer doesn't have, and records that it has them once they're
+// sent
+func (r *Relay) Unknown(addr string, hashes []cipher.SHA256) []cipher.SHA256 {
+	k := r.known(addr)
+	var unknown []cipher.SHA256
+	for _, h := range hashes {
+		if !k.has(h) {
+			unknown = append(unknown, h)
+			k.add(h)
+		}
+	}
+	return unknown
+}
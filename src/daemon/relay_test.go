@@ -0,0 +1,36 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestRelayUnknown(t *testing.T) {
+	h1 := cipher.SumSHA256([]byte("1"))
+	h2 := cipher.SumSHA256([]byte("2"))
+	h3 := cipher.SumSHA256([]byte("3"))
+
+	r := NewRelay(2)
+	r.MarkKnown("a", []cipher.SHA256{h1})
+
+	// the hashes are announced once
+	require.Equal(t, []cipher.SHA256{h2}, r.Unknown("a", []cipher.SHA256{h1, h2}))
+	require.Empty(t, r.Unknown("a", []cipher.SHA256{h1, h2}))
+	require.Equal(t, []cipher.SHA256{h1, h2}, r.Unknown("b", []cipher.SHA256{h1, h2}))
+
+	// the oldest hash is forgotten
+	require.Equal(t, []cipher.SHA256{h3}, r.Unknown("a", []cipher.SHA256{h3}))
+	require.Equal(t, []cipher.SHA256{h1}, r.Unknown("a", []cipher.SHA256{h1, h3}))
+	require.Equal(t, []cipher.SHA256{h2}, r.Unknown("a", []cipher.SHA256{h2, h1}))
+
+	require.False(t, r.Compact("a"))
+	r.SetCompact("a")
+	require.True(t, r.Compact("a"))
+
+	r.RemovePeer("a")
+	require.False(t, r.Compact("a"))
+	require.Equal(t, []cipher.SHA256{h1}, r.Unknown("a", []cipher.SHA256{h1}))
+}
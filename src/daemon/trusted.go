@@ -0,0 +1,43 @@
+package daemon
+
+import (
+	"fmt"
+	"sort"
+)
+
+// TrustedPeers are the peers of the operator, e.g. the nodes of a cluster behind an explorer.
+// They're always connected, exempt from the connection limits, the eviction and the removal from
+// the peer list, and preferred for downloading the blocks. The incoming connections of a trusted
+// peer come from other ports, so the peers are matched by ip.
+type TrustedPeers struct {
+	addrs []string
+	ips   map[string]bool
+}
+
+// NewTrustedPeers creates TrustedPeers of the host:port addresses
+func NewTrustedPeers(addrs []string) (*TrustedPeers, error) {
+	tp := &TrustedPeers{
+		ips: make(map[string]bool),
+	}
+	for _, addr := range addrs {
+		ip, _, err := SplitAddr(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted peer %s", addr)
+		}
+		tp.addrs = append(tp.addrs, addr)
+		tp.ips[ip] = true
+	}
+	sort.Strings(tp.addrs)
+	return tp, nil
+}
+
+// Addrs returns the addresses of the trusted peers
+func (tp *TrustedPeers) Addrs() []string {
+	return tp.addrs
+}
+
+// Has returns whether the connection is from or to a trusted peer
+func (tp *TrustedPeers) Has(addr string) bool {
+	ip, _, err := SplitAddr(addr)
+	return err == nil && tp.ips[ip]
+}
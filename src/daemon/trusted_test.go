@@ -0,0 +1,27 @@
+package daemon
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustedPeers(t *testing.T) {
+	tp, err := NewTrustedPeers([]string{"10.0.0.3:7200", "10.0.0.2:7200"})
+	require.NoError(t, err)
+	require.Equal(t, []string{"10.0.0.2:7200", "10.0.0.3:7200"}, tp.Addrs())
+
+	// the incoming connections of the trusted peers come from other ports
+	require.True(t, tp.Has("10.0.0.2:7200"))
+	require.True(t, tp.Has("10.0.0.2:51324"))
+	require.False(t, tp.Has("10.0.0.4:7200"))
+	require.False(t, tp.Has("10.0.0.2"))
+
+	tp, err = NewTrustedPeers(nil)
+	require.NoError(t, err)
+	require.Empty(t, tp.Addrs())
+	require.False(t, tp.Has("10.0.0.2:7200"))
+
+	_, err = NewTrustedPeers([]string{"10.0.0.2"})
+	require.Error(t, err)
+}
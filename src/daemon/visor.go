@@ -0,0 +1,970 @@
+package daemon
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	//"github.com/skycoin/skycoin/src/daemon/gnet"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/daemon/gnet"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor"
+	//"github.com/skycoin/skycoin/src/wallet"
+)
+
+//TODO
+//- use CXO for blocksync
+
+/*
+Visor should not be duplicated
+- this should be pushed into /src/visor
+*/
+
+// VisorConfig represents the configuration of visor
+type VisorConfig struct {
+	Config visor.Config
+	// Disabled the visor completely
+	Disabled bool
+	// How often to request blocks from peers
+	BlocksRequestRate time.Duration
+	// How often to announce our blocks to peers
+	BlocksAnnounceRate time.Duration
+	// How many blocks to respond with to a GetBlocksMessage
+	BlocksResponseCount uint64
+	// How many headers to respond with to a GetHeadersMessage
+	HeadersResponseCount uint64
+	// How many blocks ahead of the head the bodies are downloaded from the peers serving the headers
+	BlocksDownloadWindow uint64
+	// How many block ranges are requested from a peer at once
+	BlocksRequestsPerPeer int
+	// How long a peer has to answer a block range before it's requested from another peer
+	BlocksRequestTimeout time.Duration
+	// How often to check for the block ranges not answered in time
+	BlocksStallCheckRate time.Duration
+	//how long between saving copies of the blockchain
+	BlockchainBackupRate time.Duration
+	// Max announce txns hash number
+	MaxTxnAnnounceNum int
+	// How many txn hashes are remembered per peer so a txn is announced once to a peer
+	RelayKnownTxnsMax int
+	// How often to announce our unconfirmed txns to peers
+	TxnsAnnounceRate time.Duration
+}
+
+// NewVisorConfig creates default visor config
+func NewVisorConfig() VisorConfig {
+	return VisorConfig{
+		Config:                visor.NewVisorConfig(),
+		Disabled:              false,
+		BlocksRequestRate:     time.Second * 60, //backup, could be disabled
+		BlocksAnnounceRate:    time.Second * 60, //backup, could be disabled
+		BlocksResponseCount:   20,
+		HeadersResponseCount:  1000,
+		BlocksDownloadWindow:  500,
+		BlocksRequestsPerPeer: 4,
+		BlocksRequestTimeout:  time.Second * 30,
+		BlocksStallCheckRate:  time.Second * 5,
+		BlockchainBackupRate:  time.Second * 30,
+		MaxTxnAnnounceNum:     16,
+		RelayKnownTxnsMax:     4096,
+		TxnsAnnounceRate:      time.Minute,
+	}
+}
+
+// Visor struct
+type Visor struct {
+	Config VisorConfig
+	v      *visor.Visor
+	// Peer-reported blockchain length.  Use to estimate download progress
+	blockchainLengths map[string]uint64
+	// The txns the peers have
+	relay    *Relay
+	reqC     chan reqFunc // all request will go through this channel, to keep writing and reading member variable thread safe.
+	Shutdown context.CancelFunc
+}
+
+type reqFunc func(context.Context)
+
+// NewVisor creates visor instance
+func NewVisor(c VisorConfig) (*Visor, error) {
+	if c.Disabled {
+		return &Visor{
+			Config:            c,
+			blockchainLengths: make(map[string]uint64),
+			relay:             NewRelay(c.RelayKnownTxnsMax),
+			reqC:              make(chan reqFunc, 100),
+		}, nil
+	}
+
+	var v *visor.Visor
+	v, closeVs, err := visor.NewVisor(c.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	vs := &Visor{
+		Config:            c,
+		v:                 v,
+		blockchainLengths: make(map[string]uint64),
+		relay:             NewRelay(c.RelayKnownTxnsMax),
+		reqC:              make(chan reqFunc, 100),
+	}
+
+	vs.Shutdown = func() {
+		// close the visor
+		closeVs()
+	}
+
+	return vs, nil
+}
+
+// Run starts the visor
+func (vs *Visor) Run() error {
+	defer logger.Info("Visor closed")
+	errC := make(chan error, 1)
+	go func() {
+		// vs.Shutdown will notify the vs.v.Run to return.
+		errC <- vs.v.Run()
+	}()
+
+	for {
+		select {
+		case err := <-errC:
+			return err
+		case req := <-vs.reqC:
+			func() {
+				cxt, cancel := context.WithDeadline(context.Background(), time.Now().Add(3*time.Second))
+				defer cancel()
+				req(cxt)
+			}()
+		}
+	}
+}
+
+// the callback function must not be blocked.
+func (vs *Visor) strand(f func()) {
+	done := make(chan struct{})
+	vs.reqC <- func(cxt context.Context) {
+		defer close(done)
+		c := make(chan struct{})
+		go func() {
+			defer close(c)
+			f()
+		}()
+		select {
+		case <-cxt.Done():
+			return
+		case <-c:
+			return
+		}
+	}
+	<-done
+}
+
+// RefreshUnconfirmed checks unconfirmed txns against the blockchain and purges ones too old
+func (vs *Visor) RefreshUnconfirmed() (hashes []cipher.SHA256) {
+	if vs.Config.Disabled {
+		return
+	}
+	vs.strand(func() {
+		hashes = vs.v.RefreshUnconfirmed()
+	})
+	return
+}
+
+// RequestBlocks Sends a GetBlocksMessage to all connections
+func (vs *Visor) RequestBlocks(pool *Pool) {
+	if vs.Config.Disabled {
+		return
+	}
+	vs.strand(func() {
+		m := NewGetBlocksMessage(vs.v.HeadBkSeq(), vs.Config.BlocksResponseCount)
+		pool.Pool.BroadcastMessage(m)
+	})
+}
+
+// AnnounceBlocks sends an AnnounceBlocksMessage to all connections
+func (vs *Visor) AnnounceBlocks(pool *Pool) {
+	if vs.Config.Disabled {
+		return
+	}
+	vs.strand(func() {
+		m := NewAnnounceBlocksMessage(vs.v.HeadBkSeq())
+		pool.Pool.BroadcastMessage(m)
+	})
+}
+
+// AnnounceAllTxns announces local unconfirmed transactions
+func (vs *Visor) AnnounceAllTxns(pool *Pool) {
+	if vs.Config.Disabled {
+		return
+	}
+	vs.strand(func() {
+		// get local unconfirmed transaction hashes.
+		hashes := vs.v.GetAllValidUnconfirmedTxHashes()
+		vs.announceTxns(pool, hashes)
+	})
+}
+
+// AnnounceTxns announce given transaction hashes.
+func (vs *Visor) AnnounceTxns(pool *Pool, txns []cipher.SHA256) {
+	if vs.Config.Disabled {
+		return
+	}
+	if len(txns) > 0 {
+		vs.announceTxns(pool, txns)
+	}
+}
+
+// announceTxns announces each peer the txns it doesn't have
+func (vs *Visor) announceTxns(pool *Pool, hashes []cipher.SHA256) {
+	conns, err := pool.Pool.GetConnections()
+	if err != nil {
+		logger.Debug("Broadcast AnnounceTxnsMessage failed, err:%v", err)
+		return
+	}
+
+	for _, c := range conns {
+		addr := c.Addr()
+		unknown := vs.relay.Unknown(addr, hashes)
+		relaySkippedCounter.Add(float64(len(hashes) - len(unknown)))
+		if len(unknown) == 0 {
+			continue
+		}
+
+		for _, hs := range divideHashes(unknown, vs.Config.MaxTxnAnnounceNum) {
+			if err := pool.Pool.SendMessage(addr, NewAnnounceTxnsMessage(hs)); err != nil {
+				logger.Debug("Send AnnounceTxnsMessage to %s failed, err:%v", addr, err)
+				break
+			}
+		}
+	}
+}
+
+// broadcastTxns sends the txns to all the peers, the peers that negotiated
+// FeatureCompactRelay are announced the hashes and ask for the txns they don't have
+func (vs *Visor) broadcastTxns(txns coin.Transactions, pool *Pool) {
+	conns, err := pool.Pool.GetConnections()
+	if err != nil {
+		logger.Error("Broadcast GivenTxnsMessage failed: %v", err)
+		return
+	}
+
+	logger.Debug("Broadcasting %d txns to %d conns", len(txns), len(conns))
+	hashes := txns.Hashes()
+	for _, c := range conns {
+		addr := c.Addr()
+		var m gnet.Message = NewGiveTxnsMessage(txns)
+		if vs.relay.Compact(addr) {
+			m = NewAnnounceTxnsMessage(hashes)
+		}
+		if err := pool.Pool.SendMessage(addr, m); err != nil {
+			logger.Debug("Send txns to %s failed: %v", addr, err)
+			continue
+		}
+		vs.relay.MarkKnown(addr, hashes)
+	}
+}
+
+func divideHashes(hashes []cipher.SHA256, n int) [][]cipher.SHA256 {
+	if len(hashes) == 0 {
+		return [][]cipher.SHA256{}
+	}
+	var j int
+	var hashesArray [][]cipher.SHA256
+	if len(hashes) > n {
+		for i := range hashes {
+			if len(hashes[j:i]) == n {
+				hs := make([]cipher.SHA256, n)
+				copy(hs, hashes[j:i])
+				hashesArray = append(hashesArray, hs)
+				j = i
+			}
+		}
+	}
+	hs := make([]cipher.SHA256, len(hashes)-j)
+	copy(hs, hashes[j:])
+	hashesArray = append(hashesArray, hs)
+	return hashesArray
+}
+
+// RequestBlocksFromAddr sends a GetBlocksMessage to one connected address
+func (vs *Visor) RequestBlocksFromAddr(pool *Pool, addr string) error {
+	if vs.Config.Disabled {
+		return errors.New("Visor disabled")
+	}
+	var err error
+	vs.strand(func() {
+		m := NewGetBlocksMessage(vs.v.HeadBkSeq(), vs.Config.BlocksResponseCount)
+		var exist bool
+		exist, err = pool.Pool.IsConnExist(addr)
+		if err != nil {
+			return
+		}
+
+		if !exist {
+			err = fmt.Errorf("Tried to send GetBlocksMessage to %s, but we're "+
+				"not connected", addr)
+			return
+		}
+		err = pool.Pool.SendMessage(addr, m)
+	})
+	return err
+}
+
+// SetTxnsAnnounced sets all txns as announced
+func (vs *Visor) SetTxnsAnnounced(txns []cipher.SHA256) {
+	vs.strand(func() {
+		now := utc.Now()
+		for _, h := range txns {
+			vs.v.Unconfirmed.SetAnnounced(h, now)
+		}
+	})
+}
+
+// Sends a signed block to all connections, the peers that negotiated FeatureCompactRelay are
+// announced the block and request it
+func (vs *Visor) broadcastBlock(sb coin.SignedBlock, pool *Pool) {
+	if vs.Config.Disabled {
+		return
+	}
+
+	conns, err := pool.Pool.GetConnections()
+	if err != nil {
+		logger.Error("Broadcast GiveBlocksMessage failed: %v", err)
+		return
+	}
+
+	for _, c := range conns {
+		addr := c.Addr()
+		var m gnet.Message = NewGiveBlocksMessage([]coin.SignedBlock{sb})
+		if vs.relay.Compact(addr) {
+			m = NewAnnounceBlocksMessage(sb.Block.Head.BkSeq)
+		}
+		if err := pool.Pool.SendMessage(addr, m); err != nil {
+			logger.Debug("Send block to %s failed: %v", addr, err)
+		}
+	}
+}
+
+// BroadcastTransaction broadcasts a single transaction to all peers.
+func (vs *Visor) BroadcastTransaction(t coin.Transaction, pool *Pool) {
+	if vs.Config.Disabled {
+		logger.Debug("broadcast tx disabled")
+		return
+	}
+	vs.broadcastTxns(coin.Transactions{t}, pool)
+}
+
+// InjectTransaction injects transaction
+func (vs *Visor) InjectTransaction(txn coin.Transaction, pool *Pool) (coin.Transaction, error) {
+	var err error
+	vs.strand(func() {
+		err = visor.VerifyTransactionFee(vs.v.Blockchain, &txn)
+		if err != nil {
+			return
+		}
+
+		err = txn.Verify()
+		if err != nil {
+			err = fmt.Errorf("Transaction Verification Failed, %v", err)
+			return
+		}
+
+		_, err = vs.v.InjectTxn(txn)
+		if err != nil {
+			return
+		}
+		vs.BroadcastTransaction(txn, pool)
+		// keeps rebroadcasting until it's confirmed, in case the announcement is dropped
+		vs.v.TrackRebroadcast(txn.Hash())
+	})
+	return txn, err
+}
+
+// InjectTransactions injects the chained txns atomically and broadcasts them in the
+// order they are added, see visor.Visor.InjectTxns
+func (vs *Visor) InjectTransactions(txns coin.Transactions, pool *Pool) (coin.Transactions, error) {
+	var (
+		ordered coin.Transactions
+		err     error
+	)
+	vs.strand(func() {
+		ordered, err = vs.v.InjectTxns(txns)
+		if err != nil {
+			return
+		}
+
+		if !vs.Config.Disabled {
+			vs.broadcastTxns(ordered, pool)
+		}
+
+		for _, txn := range ordered {
+			vs.v.TrackRebroadcast(txn.Hash())
+		}
+	})
+	return ordered, err
+}
+
+// ResendTransaction resends a known UnconfirmedTxn.
+func (vs *Visor) ResendTransaction(h cipher.SHA256, pool *Pool) {
+	if vs.Config.Disabled {
+		return
+	}
+	vs.strand(func() {
+		if ut, ok := vs.v.Unconfirmed.Get(h); ok {
+			vs.BroadcastTransaction(ut.Txn, pool)
+		}
+	})
+	return
+}
+
+// ResendUnconfirmedTxns resents all unconfirmed transactions
+func (vs *Visor) ResendUnconfirmedTxns(pool *Pool) []cipher.SHA256 {
+	var txids []cipher.SHA256
+	if vs.Config.Disabled {
+		return txids
+	}
+	vs.strand(func() {
+		txns := vs.v.GetAllUnconfirmedTxns()
+
+		for i := range txns {
+			logger.Debugf("Rebroadcast tx %s", txns[i].Hash().Hex())
+			vs.BroadcastTransaction(txns[i].Txn, pool)
+			txids = append(txids, txns[i].Txn.Hash())
+		}
+	})
+	return txids
+}
+
+// RebroadcastTxns rebroadcasts the locally created unconfirmed txns that are due
+func (vs *Visor) RebroadcastTxns(pool *Pool) {
+	if vs.Config.Disabled {
+		return
+	}
+	vs.strand(func() {
+		for _, txn := range vs.v.DueRebroadcasts() {
+			logger.Debugf("Rebroadcast local tx %s", txn.Hash().Hex())
+			vs.BroadcastTransaction(txn, pool)
+		}
+	})
+}
+
+// CreateAndPublishBlock creates a block from unconfirmed transactions and sends it to the network.
+// Will panic if not running as a master chain.  Returns creation error and
+// whether it was published or not
+func (vs *Visor) CreateAndPublishBlock(pool *Pool) error {
+	if vs.Config.Disabled {
+		return errors.New("Visor disabled")
+	}
+	var err error
+	vs.strand(func() {
+		var sb coin.SignedBlock
+		sb, err = vs.v.CreateAndExecuteBlock()
+		if err != nil {
+			return
+		}
+		vs.broadcastBlock(sb, pool)
+	})
+	return err
+}
+
+// RemoveConnection updates internal state when a connection disconnects
+func (vs *Visor) RemoveConnection(addr string) {
+	vs.strand(func() {
+		delete(vs.blockchainLengths, addr)
+	})
+}
+
+// RecordBlockchainLength saves a peer-reported blockchain length
+func (vs *Visor) RecordBlockchainLength(addr string, bkLen uint64) {
+	vs.strand(func() {
+		vs.blockchainLengths[addr] = bkLen
+	})
+}
+
+// EstimateBlockchainLength returns the blockchain length estimated from peer reports
+// Deprecate. Should not need. Just report time of last block
+func (vs *Visor) EstimateBlockchainLength() uint64 {
+	var maxLen uint64
+	vs.strand(func() {
+		ourLen := vs.v.HeadBkSeq() + 1
+		if len(vs.blockchainLengths) < 2 {
+			maxLen = ourLen
+			return
+		}
+		for _, seq := range vs.blockchainLengths {
+			if maxLen < seq {
+				maxLen = seq
+			}
+		}
+	})
+	return maxLen
+}
+
+// HeadBkSeq returns the head sequence
+func (vs *Visor) HeadBkSeq() uint64 {
+	var seq uint64
+	vs.strand(func() {
+		seq = vs.v.HeadBkSeq()
+	})
+	return seq
+}
+
+// HeadBlock returns the seq and the header hash of the head block, false if there's no block
+func (vs *Visor) HeadBlock() (seq uint64, hash cipher.SHA256, ok bool) {
+	vs.strand(func() {
+		b := vs.v.Blockchain.Head()
+		if b == nil {
+			return
+		}
+		seq, hash, ok = b.Head.BkSeq, b.HashHeader(), true
+	})
+	return
+}
+
+// ExecuteSignedBlock executes signed block
+func (vs *Visor) ExecuteSignedBlock(b coin.SignedBlock) error {
+	var err error
+	vs.strand(func() {
+		err = vs.v.ExecuteSignedBlock(b)
+	})
+	return err
+}
+
+// ImportChain executes the blocks of the chain file read from r, each block is executed
+// in the strand, the node keeps serving the requests while importing. No block is executed
+// if the checksum of the file doesn't match.
+func (vs *Visor) ImportChain(r io.ReadSeeker) error {
+	return visor.ReadChain(r, func(sb coin.SignedBlock) error {
+		for i := 0; ; i++ {
+			var err error
+			vs.strand(func() {
+				err = vs.v.ImportSignedBlock(sb)
+			})
+
+			// the genesis block is created after the visor starts
+			if err != visor.ErrNoGenesisBlock || i >= 100 {
+				return err
+			}
+			time.Sleep(100 * time.Millisecond)
+		}
+	})
+}
+
+// HasBlock returns whether the block of hash is in the chain
+func (vs *Visor) HasBlock(hash cipher.SHA256) (ok bool) {
+	vs.strand(func() {
+		ok = vs.v.GetBlockByHash(hash) != nil
+	})
+	return
+}
+
+// GetSignedBlocksSince returns numbers of signed blocks since seq.
+func (vs *Visor) GetSignedBlocksSince(seq uint64, num uint64) []coin.SignedBlock {
+	var sbs []coin.SignedBlock
+	vs.strand(func() {
+		sbs = vs.v.GetSignedBlocksSince(seq, num)
+	})
+	return sbs
+}
+
+// GetSignedHeadersSince returns numbers of signed headers since seq.
+func (vs *Visor) GetSignedHeadersSince(seq uint64, num uint64) []SignedHeader {
+	sbs := vs.GetSignedBlocksSince(seq, num)
+	headers := make([]SignedHeader, len(sbs))
+	for i, b := range sbs {
+		headers[i] = SignedHeader{
+			Head: b.Block.Head,
+			Sig:  b.Sig,
+		}
+	}
+	return headers
+}
+
+// UnConfirmFilterKnown returns all unknow transaction hashes
+func (vs *Visor) UnConfirmFilterKnown(txns []cipher.SHA256) []cipher.SHA256 {
+	var ts []cipher.SHA256
+	vs.strand(func() {
+		ts = vs.v.Unconfirmed.FilterKnown(txns)
+	})
+	return ts
+}
+
+// UnConfirmKnow returns all know tansactions
+func (vs *Visor) UnConfirmKnow(hashes []cipher.SHA256) (txns coin.Transactions) {
+	vs.strand(func() {
+		txns = vs.v.Unconfirmed.GetKnown(hashes)
+	})
+	return
+}
+
+// InjectTxn only try to append transaction into local blockchain, don't broadcast it.
+func (vs *Visor) InjectTxn(tx coin.Transaction) (know bool, err error) {
+	vs.strand(func() {
+		know, err = vs.v.InjectTxn(tx)
+	})
+	return
+}
+
+// Communication layer for the coin pkg
+
+// GetBlocksMessage sent to request blocks since LastBlock
+type GetBlocksMessage struct {
+	LastBlock       uint64
+	RequestedBlocks uint64
+	c               *gnet.MessageContext `enc:"-"`
+}
+
+// NewGetBlocksMessage creates GetBlocksMessage
+func NewGetBlocksMessage(lastBlock uint64, requestedBlocks uint64) *GetBlocksMessage {
+	return &GetBlocksMessage{
+		LastBlock:       lastBlock,
+		RequestedBlocks: requestedBlocks, //count of blocks requested
+	}
+}
+
+// Handle handles message
+func (gbm *GetBlocksMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	gbm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(gbm, mc)
+}
+
+// Process should send number to be requested, with request
+func (gbm *GetBlocksMessage) Process(d *Daemon) {
+	// TODO -- we need the sig to be sent with the block, but only the master
+	// can sign blocks.  Thus the sig needs to be stored with the block.
+	// TODO -- move 20 to either Messages.Config or Visor.Config
+	if d.Visor.Config.Disabled {
+		return
+	}
+	// Record this as this peer's highest block
+	d.Visor.RecordBlockchainLength(gbm.c.Addr, gbm.LastBlock)
+	// Fetch and return signed blocks since LastBlock
+	blocks := d.Visor.GetSignedBlocksSince(gbm.LastBlock, gbm.RequestedBlocks)
+	logger.Debug("Got %d blocks since %d", len(blocks), gbm.LastBlock)
+	if len(blocks) == 0 {
+		return
+	}
+	m := NewGiveBlocksMessage(blocks)
+	d.sendMessage(gbm.c.Addr, m)
+}
+
+// GiveBlocksMessage sent in response to GetBlocksMessage, or unsolicited
+type GiveBlocksMessage struct {
+	Blocks []coin.SignedBlock
+	c      *gnet.MessageContext `enc:"-"`
+}
+
+// NewGiveBlocksMessage creates GiveBlocksMessage
+func NewGiveBlocksMessage(blocks []coin.SignedBlock) *GiveBlocksMessage {
+	return &GiveBlocksMessage{
+		Blocks: blocks,
+	}
+}
+
+// Handle handle message
+func (gbm *GiveBlocksMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	gbm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(gbm, mc)
+}
+
+// Process process message
+func (gbm *GiveBlocksMessage) Process(d *Daemon) {
+	if d.Visor.Config.Disabled {
+		logger.Critical("Visor disabled, ignoring GiveBlocksMessage")
+		return
+	}
+	// the blocks of the verified headers are applied in order, they may arrive out of order
+	// from several peers
+	blocks := d.blockSync.AddBlocks(gbm.c.Addr, gbm.Blocks, utc.Now())
+	synced := len(blocks) < len(gbm.Blocks)
+	processed := d.applySyncedBlocks()
+
+	forked := false
+	maxSeq := d.Visor.HeadBkSeq()
+	for _, b := range blocks {
+		// To minimize waste when receiving multiple responses from peers
+		// we only break out of the loop if the block itself is invalid.
+		// E.g. if we request 20 blocks since 0 from 2 peers, and one peer
+		// replies with 15 and the other 20, if we did not do this check and
+		// the reply with 15 was received first, we would toss the one with 20
+		// even though we could process it at the time.
+		// The blocks not in our chain are passed to the visor as competing blocks.
+		if b.Block.Head.BkSeq <= maxSeq && d.Visor.HasBlock(b.Block.HashHeader()) {
+			continue
+		}
+		err := d.Visor.ExecuteSignedBlock(b)
+		if err == nil {
+			logger.Critical("Added new block %d", b.Block.Head.BkSeq)
+			processed++
+		} else if err == visor.ErrCheckpointMismatch {
+			// the peer is on a fake chain
+			logger.Critical("Block %d from %s conflicts with the checkpoints", b.Block.Head.BkSeq, gbm.c.Addr)
+			d.Pool.Pool.Disconnect(gbm.c.Addr, ErrDisconnectCheckpointMismatch)
+			return
+		} else if err == visor.ErrBlockNotConnected {
+			// the peer is on another branch, keeps the rest blocks of the branch
+			logger.Info("Received competing block %d", b.Block.Head.BkSeq)
+			forked = true
+		} else {
+			logger.Critical("Failed to execute received block: %v", err)
+			// Blocks must be received in order, so if one fails its assumed
+			// the rest are failing
+			break
+		}
+	}
+	if processed > 0 || synced {
+		d.peerConnections.MarkUseful(gbm.c.Addr, utc.Now())
+	}
+	d.syncHead()
+	if d.blockSync.Active(d.Visor.HeadBkSeq()) {
+		d.requestBlockBodies()
+	}
+	if processed == 0 {
+		if forked && len(gbm.Blocks) > 0 {
+			// requests the earlier blocks of the peer, until the fork point is found
+			seq := gbm.Blocks[0].Block.Head.BkSeq
+			count := d.Visor.Config.BlocksResponseCount
+			if seq > count {
+				seq -= count
+			} else {
+				seq = 0
+			}
+			m := NewGetBlocksMessage(seq, count)
+			d.Pool.Pool.SendMessage(gbm.c.Addr, m)
+		}
+		return
+	}
+
+	// Announce our new blocks to peers
+	m1 := NewAnnounceBlocksMessage(d.Visor.HeadBkSeq())
+	d.Pool.Pool.BroadcastMessage(m1)
+	// the bodies of the verified headers are requested from the peers serving the headers
+	if d.blockSync.Active(d.Visor.HeadBkSeq()) {
+		return
+	}
+	//request more blocks.
+	m2 := NewGetBlocksMessage(d.Visor.HeadBkSeq(), d.Visor.Config.BlocksResponseCount)
+	d.Pool.Pool.BroadcastMessage(m2)
+}
+
+// AnnounceBlocksMessage tells a peer our highest known BkSeq. The receiving peer can choose
+// to send GetBlocksMessage in response
+type AnnounceBlocksMessage struct {
+	MaxBkSeq uint64
+	c        *gnet.MessageContext `enc:"-"`
+}
+
+// NewAnnounceBlocksMessage creates message
+func NewAnnounceBlocksMessage(seq uint64) *AnnounceBlocksMessage {
+	return &AnnounceBlocksMessage{
+		MaxBkSeq: seq,
+	}
+}
+
+// Handle handles message
+func (abm *AnnounceBlocksMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	abm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(abm, mc)
+}
+
+// Process process message
+func (abm *AnnounceBlocksMessage) Process(d *Daemon) {
+	if d.Visor.Config.Disabled {
+		return
+	}
+	headBkSeq := d.Visor.HeadBkSeq()
+	if headBkSeq >= abm.MaxBkSeq {
+		return
+	}
+	if d.peerHasFeature(abm.c.Addr, FeatureHeadersFirst) {
+		if d.blockSync.LastSeq() < abm.MaxBkSeq {
+			d.requestHeadersFromAddr(abm.c.Addr)
+		}
+		return
+	}
+	//should this be block get request for current sequence?
+	//if client is not caught up, wont attempt to get block
+	m := NewGetBlocksMessage(headBkSeq, d.Visor.Config.BlocksResponseCount)
+	d.Pool.Pool.SendMessage(abm.c.Addr, m)
+}
+
+// SendingTxnsMessage send transaction message interface
+type SendingTxnsMessage interface {
+	GetTxns() []cipher.SHA256
+}
+
+// AnnounceTxnsMessage tells a peer that we have these transactions
+type AnnounceTxnsMessage struct {
+	Txns []cipher.SHA256
+	c    *gnet.MessageContext `enc:"-"`
+}
+
+// NewAnnounceTxnsMessage creates announce txns message
+func NewAnnounceTxnsMessage(txns []cipher.SHA256) *AnnounceTxnsMessage {
+	return &AnnounceTxnsMessage{
+		Txns: txns,
+	}
+}
+
+// GetTxns returns txns
+func (atm *AnnounceTxnsMessage) GetTxns() []cipher.SHA256 {
+	return atm.Txns
+}
+
+// Handle handle message
+func (atm *AnnounceTxnsMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	atm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(atm, mc)
+}
+
+// Process process message
+func (atm *AnnounceTxnsMessage) Process(d *Daemon) {
+	if d.Visor.Config.Disabled {
+		return
+	}
+	d.Visor.relay.MarkKnown(atm.c.Addr, atm.Txns)
+	unknown := d.Visor.UnConfirmFilterKnown(atm.Txns)
+	if len(unknown) == 0 {
+		return
+	}
+	m := NewGetTxnsMessage(unknown)
+	d.Pool.Pool.SendMessage(atm.c.Addr, m)
+}
+
+// GetTxnsMessage request transactions of given hash
+type GetTxnsMessage struct {
+	Txns []cipher.SHA256
+	c    *gnet.MessageContext `enc:"-"`
+}
+
+// NewGetTxnsMessage creates GetTxnsMessage
+func NewGetTxnsMessage(txns []cipher.SHA256) *GetTxnsMessage {
+	return &GetTxnsMessage{
+		Txns: txns,
+	}
+}
+
+// Handle handle message
+func (gtm *GetTxnsMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	gtm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(gtm, mc)
+}
+
+// Process process message
+func (gtm *GetTxnsMessage) Process(d *Daemon) {
+	if d.Visor.Config.Disabled {
+		return
+	}
+	// Locate all txns from the unconfirmed pool
+	// reply to sender with GiveTxnsMessage
+	known := d.Visor.UnConfirmKnow(gtm.Txns)
+	if len(known) == 0 {
+		return
+	}
+	logger.Debug("%d/%d txns known", len(known), len(gtm.Txns))
+	m := NewGiveTxnsMessage(known)
+	if err := d.sendMessage(gtm.c.Addr, m); err == nil {
+		d.Visor.relay.MarkKnown(gtm.c.Addr, known.Hashes())
+	}
+}
+
+// GiveTxnsMessage tells the transaction of given hashes
+type GiveTxnsMessage struct {
+	Txns coin.Transactions
+	c    *gnet.MessageContext `enc:"-"`
+}
+
+// NewGiveTxnsMessage creates GiveTxnsMessage
+func NewGiveTxnsMessage(txns coin.Transactions) *GiveTxnsMessage {
+	return &GiveTxnsMessage{
+		Txns: txns,
+	}
+}
+
+// GetTxns returns transactions hashes
+func (gtm *GiveTxnsMessage) GetTxns() []cipher.SHA256 {
+	return gtm.Txns.Hashes()
+}
+
+// Handle handle message
+func (gtm *GiveTxnsMessage) Handle(mc *gnet.MessageContext,
+	daemon interface{}) error {
+	gtm.c = mc
+	return daemon.(*Daemon).recordMessageEvent(gtm, mc)
+}
+
+// Process process message
+func (gtm *GiveTxnsMessage) Process(d *Daemon) {
+	if d.Visor.Config.Disabled {
+		return
+	}
+	if len(gtm.Txns) > 32 {
+		logger.Warning("More than 32 transactions in pool. Implement breaking transactions transmission into multiple packets")
+	}
+
+	d.Visor.relay.MarkKnown(gtm.c.Addr, gtm.Txns.Hashes())
+	hashes := make([]cipher.SHA256, 0, len(gtm.Txns))
+	// Update unconfirmed pool with these transactions
+	for _, txn := range gtm.Txns {
+		// Only announce transactions that are new to us, so that peers can't
+		// spam relays
+		if known, err := d.Visor.InjectTxn(txn); err == nil && !known {
+			hashes = append(hashes, txn.Hash())
+		} else {
+			if !known {
+				logger.Warning("Failed to record txn: %v", err)
+			} else {
+				logger.Warning("Duplicate Transaction: %s", txn.Hash().Hex())
+			}
+		}
+	}
+	// Announce these transactions to peers
+	if len(hashes) != 0 {
+		d.peerConnections.MarkUseful(gtm.c.Addr, utc.Now())
+		logger.Debugf("Announce %d transactions", len(hashes))
+		d.Visor.AnnounceTxns(d.Pool, hashes)
+	}
+}
+
+// BlockchainLengths an array of uint64
+type BlockchainLengths []uint64
+
+// Len for sorting
+func (bcl BlockchainLengths) Len() int {
+	return len(bcl)
+}
+
+// Swap for sorting
+func (bcl BlockchainLengths) Swap(i, j int) {
+	bcl[i], bcl[j] = bcl[j], bcl[i]
+}
+
+// Less for sorting
+func (bcl BlockchainLengths) Less(i, j int) bool {
+	return bcl[i] < bcl[j]
+}
+
+type byTxnRecvTime []visor.UnconfirmedTxn
+
+func (txs byTxnRecvTime) Len() int {
+	return len(txs)
+}
+
+func (txs byTxnRecvTime) Swap(i, j int) {
+	txs[i], txs[j] = txs[j], txs[i]
+}
+
+func (txs byTxnRecvTime) Less(i, j int) bool {
+	return txs[i].Received < txs[j].Received
+}
@@ -0,0 +1,83 @@
+package gui
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// defaultAddressPageSize is the page size of the address transactions and outputs
+const defaultAddressPageSize = 20
+
+// RegisterAddressHandlers register the address handlers
+func RegisterAddressHandlers(mux Mux, gateway *daemon.Gateway) {
+	// get the transactions or the outputs of the address by page
+	// 		GET /address/:addr/transactions
+	// 		GET /address/:addr/uxouts
+	// 		Arguments:
+	// 			cursor: [optional] the next_cursor of the previous page, 0 by default
+	// 			page_size: [optional] 20 by default, at most 100
+	mux.HandleFunc("/address/", addressHandler(gateway))
+}
+
+// addressHandler returns the transactions or the outputs of the address by page
+// method: GET
+// url: /address/:addr/transactions?cursor=[:cursor]&page_size=[:page_size]
+// or /address/:addr/uxouts?cursor=[:cursor]&page_size=[:page_size]
+// params: the next_cursor in response can be used as cursor of next page
+func addressHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/address/"), "/")
+		if len(parts) != 2 || (parts[1] != "transactions" && parts[1] != "uxouts") {
+			wh.Error404(w)
+			return
+		}
+
+		addr, err := cipher.DecodeBase58Address(parts[0])
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("address %s is invalid: %v", parts[0], err))
+			return
+		}
+
+		var cursor uint64
+		if s := r.FormValue("cursor"); s != "" {
+			cursor, err = strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("Invalid cursor value \"%s\"", s))
+				return
+			}
+		}
+
+		pageSize := defaultAddressPageSize
+		if s := r.FormValue("page_size"); s != "" {
+			pageSize, err = strconv.Atoi(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("Invalid page_size value \"%s\"", s))
+				return
+			}
+		}
+
+		var page interface{}
+		if parts[1] == "transactions" {
+			page, err = gateway.GetAddressTxnsPage(addr, cursor, pageSize)
+		} else {
+			page, err = gateway.GetAddressUxOutsPage(addr, cursor, pageSize)
+		}
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, page)
+	}
+}
@@ -0,0 +1,187 @@
+package gui
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/visor"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// maintenance is 1 when the node is in maintenance mode
+var maintenance int32
+
+// SetMaintenance turns the maintenance mode on or off. In maintenance mode the requests of the web
+// interface except the admin routes are responded with 503, the node keeps syncing.
+func SetMaintenance(on bool) {
+	var v int32
+	if on {
+		v = 1
+	}
+	atomic.StoreInt32(&maintenance, v)
+}
+
+// InMaintenance returns whether the node is in maintenance mode
+func InMaintenance() bool {
+	return atomic.LoadInt32(&maintenance) == 1
+}
+
+// isAdminPath returns whether the path is of an admin route, legacy or versioned
+func isAdminPath(path string) bool {
+	return strings.HasPrefix(path, "/admin/") || strings.HasPrefix(path, apiPrefix+"/admin/")
+}
+
+// maintenanceHandler rejects the requests except the admin routes in maintenance mode
+func maintenanceHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if InMaintenance() && !isAdminPath(r.URL.Path) {
+			wh.Error503(w, "node is in maintenance mode")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// RegisterAdminHandlers registers the handlers of the node management, they're protected routes
+func RegisterAdminHandlers(mux Mux, gateway *daemon.Gateway) {
+	// Returns the maintenance mode, the index rebuild progress and the compaction request
+	mux.HandleFunc("/admin/status", getAdminStatus(gateway))
+	// Turns the maintenance mode on or off
+	mux.HandleFunc("/admin/maintenance", setMaintenance)
+	// Requests the blocks from the peers at once
+	mux.HandleFunc("/admin/resync", adminResync(gateway))
+	// Starts rebuilding the indexes
+	mux.HandleFunc("/admin/rebuild-indexes", rebuildIndexes(gateway))
+	// Requests compacting the db when the node restarts
+	mux.HandleFunc("/admin/compact-db", compactDB(gateway))
+	// Rebroadcasts the unconfirmed transactions
+	mux.HandleFunc("/admin/rebroadcast", resendUnconfirmedTxns(gateway))
+	// Replaces the api key
+	mux.HandleFunc("/admin/rotate-api-key", rotateAPIKey)
+}
+
+// AdminStatus is the result of /admin/status
+type AdminStatus struct {
+	Maintenance   bool                `json:"maintenance"`
+	IndexProgress visor.IndexProgress `json:"index_progress"`
+	// the db is compacted when the node restarts
+	CompactionRequested bool `json:"compaction_requested"`
+}
+
+// method: GET
+// url: /admin/status
+func getAdminStatus(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		stats, err := gateway.GetDBStats()
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, AdminStatus{
+			Maintenance:         InMaintenance(),
+			IndexProgress:       gateway.GetIndexProgress(),
+			CompactionRequested: stats.CompactionRequested,
+		})
+	}
+}
+
+// setMaintenance turns the maintenance mode on or off, it's off after restarting
+// method: POST
+// params: enabled, true or false
+func setMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		wh.Error405(w, "")
+		return
+	}
+
+	on, err := strconv.ParseBool(r.FormValue("enabled"))
+	if err != nil {
+		wh.Error400(w, "invalid enabled")
+		return
+	}
+
+	SetMaintenance(on)
+	logger.Notice("Maintenance mode enabled: %v", on)
+	wh.SendOr404(w, struct {
+		Maintenance bool `json:"maintenance"`
+	}{on})
+}
+
+// adminResync requests the blocks after the head from the peers, without waiting for the
+// request interval
+// method: POST
+func adminResync(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		if err := gateway.Resync(); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetBlockchainProgress())
+	}
+}
+
+// compactDB requests compacting the db when the node restarts, the db can't be compacted
+// while it's open
+// method: POST
+func compactDB(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		if err := gateway.RequestCompaction(); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		stats, err := gateway.GetDBStats()
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, stats)
+	}
+}
+
+// RotateAPIKeyResult is the result of /admin/rotate-api-key
+type RotateAPIKeyResult struct {
+	APIKey string `json:"api_key"`
+}
+
+// rotateAPIKey replaces the api key, the requests with the old key are rejected at once. The
+// new key isn't saved, the key of -api-key is used again after restarting.
+// method: POST
+// params: key (optional, a random key is generated if empty)
+func rotateAPIKey(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		wh.Error405(w, "")
+		return
+	}
+
+	if Auth == nil {
+		wh.Error404(w, "api auth is disabled")
+		return
+	}
+
+	key := Auth.RotateAPIKey(r.FormValue("key"))
+	logger.Notice("Api key is rotated")
+	wh.SendOr404(w, RotateAPIKeyResult{APIKey: key})
+}
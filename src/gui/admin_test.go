@@ -0,0 +1,43 @@
+package gui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenanceMode(t *testing.T) {
+	defer SetMaintenance(false)
+
+	mux := http.NewServeMux()
+	api := NewAPIMux(mux, nil, nil)
+	api.HandleFunc("/blocks", func(w http.ResponseWriter, r *http.Request) {})
+	api.HandleFunc("/admin/maintenance", setMaintenance)
+	h := maintenanceHandler(mux)
+
+	do := func(method, url, body string) int {
+		r := httptest.NewRequest(method, url, strings.NewReader(body))
+		if body != "" {
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, do("GET", "/api/v1/blocks", ""))
+	require.Equal(t, http.StatusBadRequest, do("POST", "/api/v1/admin/maintenance", "enabled=maybe"))
+
+	require.Equal(t, http.StatusOK, do("POST", "/api/v1/admin/maintenance", "enabled=true"))
+	require.True(t, InMaintenance())
+	require.Equal(t, http.StatusServiceUnavailable, do("GET", "/api/v1/blocks", ""))
+	require.Equal(t, http.StatusServiceUnavailable, do("GET", "/blocks", ""))
+
+	// the admin routes are served in maintenance mode
+	require.Equal(t, http.StatusOK, do("POST", "/admin/maintenance", "enabled=false"))
+	require.False(t, InMaintenance())
+	require.Equal(t, http.StatusOK, do("GET", "/api/v1/blocks", ""))
+}
@@ -119,7 +119,7 @@ func getKeyEntry(pub cipher.PubKey, sec cipher.SecKey) KeyEntry {
 }
 
 // RegisterAPIHandlers registers api handlers
-func RegisterAPIHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterAPIHandlers(mux Mux, gateway *daemon.Gateway) {
 	//  Generates wallet bitcoin/skycoin addresses and seckey,pubkey
 	// GET/POST
 	// 	bc - bool - is bitcoin type (optional) - default: true
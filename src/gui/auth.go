@@ -0,0 +1,287 @@
+package gui
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+const (
+	// APIKeyHeader is the header of the api key
+	APIKeyHeader = "X-API-Key"
+	// CSRFTokenHeader is the header of the csrf token of /api/v1/csrf
+	CSRFTokenHeader = "X-CSRF-Token"
+)
+
+var (
+	// ErrAPIKeyRequired is returned if the api key is set and the request hasn't it
+	ErrAPIKeyRequired = errors.New("api key is required")
+	// ErrAPIKeyInvalid is returned if the api key of the request is wrong
+	ErrAPIKeyInvalid = errors.New("api key is invalid")
+	// ErrCSRFTokenRequired is returned if the csrf is enabled and the request hasn't the token
+	ErrCSRFTokenRequired = errors.New("csrf token is required")
+	// ErrCSRFTokenInvalid is returned if the csrf token isn't issued by the node
+	ErrCSRFTokenInvalid = errors.New("csrf token is invalid")
+	// ErrCSRFTokenExpired is returned if the csrf token is expired
+	ErrCSRFTokenExpired = errors.New("csrf token is expired")
+)
+
+// DefaultProtectedRoutes are the routes protected for all the methods, they read the wallets
+// or change the node
+var DefaultProtectedRoutes = []string{
+	"/wallet",
+	"/notes",
+	"/backups",
+	"/webhooks",
+	"/api/create-address",
+	"/resendUnconfirmedTxns",
+	"/network/connections/",
+	"/logging/",
+	"/debug/",
+	"/config/",
+	"/admin/",
+}
+
+// Auth global authentication of the api, the api isn't authenticated if nil
+var Auth *APIAuth
+
+// InitAPIAuth init the authentication of the api, it's applied to the handlers registered after
+func InitAPIAuth(c APIAuthConfig) {
+	Auth = NewAPIAuth(c)
+}
+
+// APIAuthConfig configures the APIAuth.
+//
+// The protected requests are the requests of the protected routes, and the requests of the other
+// routes not by GET or HEAD. They need the api key if it's set, otherwise the csrf token if the
+// csrf is enabled. The requests with the api key never need the csrf token, the browsers can't
+// send the header to the other origins not allowed by the cors policy.
+type APIAuthConfig struct {
+	// the api key of the X-API-Key header
+	APIKey string
+	// enables the X-CSRF-Token header of /api/v1/csrf
+	CSRF bool
+	// how long the csrf token is valid
+	CSRFTokenDuration time.Duration
+	// the GET and HEAD requests of the routes not protected don't need the api key
+	PublicReads bool
+	// the prefixes of the legacy paths of the routes protected for all the methods
+	ProtectedRoutes []string
+	// the prefixes of the legacy paths of the routes never authenticated, they override the
+	// protected routes
+	PublicRoutes []string
+}
+
+// NewAPIAuthConfig returns an APIAuthConfig with defaults set
+func NewAPIAuthConfig() APIAuthConfig {
+	return APIAuthConfig{
+		CSRFTokenDuration: 30 * time.Minute,
+		PublicReads:       true,
+		ProtectedRoutes:   DefaultProtectedRoutes,
+	}
+}
+
+type routeAccess int
+
+const (
+	// the writes are protected, the reads are public if PublicReads
+	accessDefault routeAccess = iota
+	accessPublic
+	accessProtected
+)
+
+// APIAuth authenticates the api requests by the api key and the csrf tokens
+type APIAuth struct {
+	c APIAuthConfig
+	// guards c.APIKey, which is rotated at runtime
+	keyMu sync.RWMutex
+	// the key of the hmac of the csrf tokens, the tokens are invalidated by restarting
+	csrfKey []byte
+	now     func() time.Time
+}
+
+// NewAPIAuth creates an APIAuth
+func NewAPIAuth(c APIAuthConfig) *APIAuth {
+	return &APIAuth{
+		c:       c,
+		csrfKey: cipher.RandByte(32),
+		now:     time.Now,
+	}
+}
+
+func (a *APIAuth) routeAccess(pattern string) routeAccess {
+	for _, p := range a.c.PublicRoutes {
+		if strings.HasPrefix(pattern, p) {
+			return accessPublic
+		}
+	}
+	for _, p := range a.c.ProtectedRoutes {
+		if strings.HasPrefix(pattern, p) {
+			return accessProtected
+		}
+	}
+	return accessDefault
+}
+
+// Handler wraps the handler of the route pattern with the authentication
+func (a *APIAuth) Handler(pattern string, handler http.Handler) http.Handler {
+	access := a.routeAccess(pattern)
+	if access == accessPublic {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.authenticate(r, access); err != nil {
+			if err == ErrAPIKeyRequired || err == ErrAPIKeyInvalid {
+				wh.Error401(w, err.Error())
+			} else {
+				wh.Error403(w, err.Error())
+			}
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (a *APIAuth) authenticate(r *http.Request, access routeAccess) error {
+	protected := access == accessProtected || (r.Method != "GET" && r.Method != "HEAD")
+	if !protected && a.c.PublicReads {
+		return nil
+	}
+
+	apiKey := a.apiKey()
+	if key := r.Header.Get(APIKeyHeader); key != "" {
+		if apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+			return ErrAPIKeyInvalid
+		}
+		return nil
+	}
+
+	if apiKey != "" {
+		return ErrAPIKeyRequired
+	}
+
+	if !protected || !a.c.CSRF {
+		return nil
+	}
+
+	token := r.Header.Get(CSRFTokenHeader)
+	if token == "" {
+		return ErrCSRFTokenRequired
+	}
+	return a.VerifyCSRFToken(token)
+}
+
+// keyClient returns the client of the valid api key of the request, the hash of the key so
+// that the key isn't kept in memory. It returns empty if the request hasn't the valid api key,
+// the requests with any other key can't get a client of their own.
+func (a *APIAuth) keyClient(r *http.Request) string {
+	if a == nil {
+		return ""
+	}
+
+	key := r.Header.Get(APIKeyHeader)
+	apiKey := a.apiKey()
+	if key == "" || apiKey == "" || subtle.ConstantTimeCompare([]byte(key), []byte(apiKey)) != 1 {
+		return ""
+	}
+
+	h := sha256.Sum256([]byte(key))
+	return "key:" + hex.EncodeToString(h[:])
+}
+
+func (a *APIAuth) apiKey() string {
+	a.keyMu.RLock()
+	defer a.keyMu.RUnlock()
+	return a.c.APIKey
+}
+
+// RotateAPIKey replaces the api key with key, or with a random key if key is empty, and returns
+// the new key. The requests with the old key are rejected at once. The key isn't saved, the key
+// of the config is used again after restarting.
+func (a *APIAuth) RotateAPIKey(key string) string {
+	if key == "" {
+		key = hex.EncodeToString(cipher.RandByte(32))
+	}
+
+	a.keyMu.Lock()
+	defer a.keyMu.Unlock()
+	a.c.APIKey = key
+	return key
+}
+
+// NewCSRFToken issues a csrf token, it's valid for CSRFTokenDuration.
+// The token is <expire time>.<nonce>.<hmac of them>, it isn't saved.
+func (a *APIAuth) NewCSRFToken() string {
+	expire := a.now().Add(a.c.CSRFTokenDuration).Unix()
+	payload := fmt.Sprintf("%d.%s", expire, hex.EncodeToString(cipher.RandByte(16)))
+	return payload + "." + a.csrfMAC(payload)
+}
+
+// VerifyCSRFToken checks the csrf token is issued by NewCSRFToken and isn't expired
+func (a *APIAuth) VerifyCSRFToken(token string) error {
+	i := strings.LastIndex(token, ".")
+	if i < 0 {
+		return ErrCSRFTokenInvalid
+	}
+
+	payload, mac := token[:i], token[i+1:]
+	if !hmac.Equal([]byte(mac), []byte(a.csrfMAC(payload))) {
+		return ErrCSRFTokenInvalid
+	}
+
+	expire, err := strconv.ParseInt(strings.SplitN(payload, ".", 2)[0], 10, 64)
+	if err != nil {
+		return ErrCSRFTokenInvalid
+	}
+	if a.now().Unix() >= expire {
+		return ErrCSRFTokenExpired
+	}
+	return nil
+}
+
+func (a *APIAuth) csrfMAC(payload string) string {
+	mac := hmac.New(sha256.New, a.csrfKey)
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RegisterAuthHandlers register the handler of the csrf tokens
+func RegisterAuthHandlers(m *APIMux) {
+	// issue a csrf token
+	m.handleVersioned("/csrf", http.HandlerFunc(getCSRFToken(m.auth)))
+}
+
+// CSRFTokenResult is the result of /api/v1/csrf
+type CSRFTokenResult struct {
+	CSRFToken string `json:"csrf_token"`
+}
+
+// method: GET
+// url: /api/v1/csrf
+func getCSRFToken(auth *APIAuth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		if auth == nil || !auth.c.CSRF {
+			wh.Error404(w, "csrf is disabled")
+			return
+		}
+
+		wh.SendOr404(w, CSRFTokenResult{CSRFToken: auth.NewCSRFToken()})
+	}
+}
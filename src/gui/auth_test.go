@@ -0,0 +1,139 @@
+package gui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIAuth(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+
+	newMux := func(c APIAuthConfig) (*http.ServeMux, *APIAuth) {
+		mux := http.NewServeMux()
+		auth := NewAPIAuth(c)
+		api := NewAPIMux(mux, auth, nil)
+		api.HandleFunc("/wallet/spend", ok)
+		api.HandleFunc("/wallet/balance", ok)
+		api.HandleFunc("/blocks", ok)
+		api.HandleFunc("/injectTransaction", ok)
+		RegisterAuthHandlers(api)
+		return mux, auth
+	}
+
+	keyConfig := NewAPIAuthConfig()
+	keyConfig.APIKey = "key"
+	keyConfig.PublicRoutes = []string{"/wallet/balance"}
+	keyMux, _ := newMux(keyConfig)
+
+	privateConfig := keyConfig
+	privateConfig.PublicReads = false
+	privateMux, _ := newMux(privateConfig)
+
+	csrfConfig := NewAPIAuthConfig()
+	csrfConfig.CSRF = true
+	csrfMux, csrfAuth := newMux(csrfConfig)
+	token := csrfAuth.NewCSRFToken()
+
+	openMux, _ := newMux(NewAPIAuthConfig())
+
+	tests := []struct {
+		name    string
+		mux     *http.ServeMux
+		method  string
+		url     string
+		headers map[string]string
+		code    int
+	}{
+		{"key spend", keyMux, "POST", "/api/v1/wallet/spend", map[string]string{APIKeyHeader: "key"}, http.StatusOK},
+		{"key spend legacy", keyMux, "POST", "/wallet/spend", map[string]string{APIKeyHeader: "key"}, http.StatusOK},
+		{"no key spend", keyMux, "POST", "/api/v1/wallet/spend", nil, http.StatusUnauthorized},
+		{"wrong key spend", keyMux, "POST", "/api/v1/wallet/spend", map[string]string{APIKeyHeader: "yek"}, http.StatusUnauthorized},
+		{"no key protected read", keyMux, "GET", "/api/v1/wallet/spend", nil, http.StatusUnauthorized},
+		{"no key inject", keyMux, "POST", "/api/v1/injectTransaction", nil, http.StatusUnauthorized},
+		{"no key public read", keyMux, "GET", "/api/v1/blocks", nil, http.StatusOK},
+		{"no key public route", keyMux, "POST", "/api/v1/wallet/balance", nil, http.StatusOK},
+		{"no key private read", privateMux, "GET", "/api/v1/blocks", nil, http.StatusUnauthorized},
+		{"key private read", privateMux, "GET", "/api/v1/blocks", map[string]string{APIKeyHeader: "key"}, http.StatusOK},
+		{"csrf spend", csrfMux, "POST", "/api/v1/wallet/spend", map[string]string{CSRFTokenHeader: token}, http.StatusOK},
+		{"no csrf spend", csrfMux, "POST", "/api/v1/wallet/spend", nil, http.StatusForbidden},
+		{"invalid csrf spend", csrfMux, "POST", "/api/v1/wallet/spend", map[string]string{CSRFTokenHeader: token + "0"}, http.StatusForbidden},
+		{"no csrf read", csrfMux, "GET", "/api/v1/blocks", nil, http.StatusOK},
+		{"key not set", csrfMux, "POST", "/api/v1/wallet/spend", map[string]string{APIKeyHeader: "key"}, http.StatusUnauthorized},
+		{"open spend", openMux, "POST", "/api/v1/wallet/spend", nil, http.StatusOK},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.url, nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			tc.mux.ServeHTTP(w, r)
+			assert.Equal(t, tc.code, w.Code, w.Body.String())
+		})
+	}
+
+	// the csrf token of the handler
+	w := httptest.NewRecorder()
+	csrfMux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/csrf", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+	var res CSRFTokenResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.NoError(t, csrfAuth.VerifyCSRFToken(res.CSRFToken))
+
+	// the csrf is disabled
+	w = httptest.NewRecorder()
+	keyMux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/csrf", nil))
+	require.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestRotateAPIKey(t *testing.T) {
+	c := NewAPIAuthConfig()
+	c.APIKey = "key"
+	auth := NewAPIAuth(c)
+
+	mux := http.NewServeMux()
+	NewAPIMux(mux, auth, nil).HandleFunc("/wallet/spend", func(w http.ResponseWriter, r *http.Request) {})
+	do := func(key string) int {
+		r := httptest.NewRequest("POST", "/api/v1/wallet/spend", nil)
+		r.Header.Set(APIKeyHeader, key)
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	key := auth.RotateAPIKey("")
+	require.Len(t, key, 64)
+	require.Equal(t, http.StatusUnauthorized, do("key"))
+	require.Equal(t, http.StatusOK, do(key))
+
+	require.Equal(t, "new", auth.RotateAPIKey("new"))
+	require.Equal(t, http.StatusUnauthorized, do(key))
+	require.Equal(t, http.StatusOK, do("new"))
+}
+
+func TestCSRFToken(t *testing.T) {
+	c := NewAPIAuthConfig()
+	c.CSRF = true
+	auth := NewAPIAuth(c)
+	now := time.Unix(1500000000, 0)
+	auth.now = func() time.Time { return now }
+
+	token := auth.NewCSRFToken()
+	require.NoError(t, auth.VerifyCSRFToken(token))
+
+	// issued by the other node
+	require.Equal(t, ErrCSRFTokenInvalid, NewAPIAuth(c).VerifyCSRFToken(token))
+	require.Equal(t, ErrCSRFTokenInvalid, auth.VerifyCSRFToken("abc"))
+	require.Equal(t, ErrCSRFTokenInvalid, auth.VerifyCSRFToken(""))
+
+	now = now.Add(c.CSRFTokenDuration)
+	require.Equal(t, ErrCSRFTokenExpired, auth.VerifyCSRFToken(token))
+}
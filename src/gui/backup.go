@@ -0,0 +1,118 @@
+package gui
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/skycoin/skycoin/src/backup"
+	"github.com/skycoin/skycoin/src/daemon"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// RegisterBackupHandlers registers the backup handlers
+func RegisterBackupHandlers(mux Mux, gateway *daemon.Gateway) {
+	// Lists the backups
+	mux.HandleFunc("/backups", getBackups(gateway))
+	// Creates a backup
+	mux.HandleFunc("/backups/create", createBackup(gateway))
+	// Restores a backup
+	mux.HandleFunc("/backups/restore", restoreBackup(gateway))
+}
+
+// getBackups returns the backups of the wallets and the db, the most recent first
+func getBackups(gate *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		bs, err := gate.GetBackups()
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, bs)
+	}
+}
+
+// createBackup creates a backup now, the oldest backups beyond the retention are removed
+func createBackup(gate *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		b, err := gate.CreateBackup()
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, b)
+	}
+}
+
+// restoreBackup restores the wallets and/or the db of the backup.
+// method: POST
+// params: name, wallets (default true), db (default false)
+// The wallets are reloaded, the db is restored when the node restarts.
+func restoreBackup(gate *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		name := r.FormValue("name")
+		if name == "" {
+			wh.Error400(w, "name is empty")
+			return
+		}
+
+		wallets := true
+		if s := r.FormValue("wallets"); s != "" {
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid wallets: %v", err))
+				return
+			}
+			wallets = v
+		}
+
+		var db bool
+		if s := r.FormValue("db"); s != "" {
+			v, err := strconv.ParseBool(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid db: %v", err))
+				return
+			}
+			db = v
+		}
+
+		if err := gate.RestoreBackup(name, wallets, db); err != nil {
+			switch err {
+			case backup.ErrBackupNotFound:
+				wh.Error404(w, "")
+			case daemon.ErrBackupsDisabled:
+				wh.Error500(w, err.Error())
+			default:
+				wh.Error400(w, err.Error())
+			}
+			return
+		}
+
+		if wallets {
+			if err := Wg.ReloadWallets(); err != nil {
+				wh.Error500(w, fmt.Sprintf("reload wallets failed: %v", err))
+				return
+			}
+		}
+
+		wh.SendOr404(w, map[string]bool{"wallets": wallets, "db": db})
+	}
+}
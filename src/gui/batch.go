@@ -0,0 +1,218 @@
+package gui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+const (
+	// maxBatchRequests is the max number of the requests of a batch
+	maxBatchRequests = 50
+	// the max size of the body of the batch
+	maxBatchBodySize = 4 * 1024 * 1024
+)
+
+// the headers of the batch copied to its requests, the requests are authenticated and limited as
+// the same client
+var batchInheritedHeaders = []string{
+	APIKeyHeader,
+	CSRFTokenHeader,
+	"X-Forwarded-For",
+}
+
+// the headers the requests of the batch can't set, they'd make the requests authenticated and
+// limited as another client
+var batchForbiddenHeaders = append([]string{"Forwarded", "X-Real-Ip"}, batchInheritedHeaders...)
+
+// batchContextKey marks the context of the requests of a batch, the batches in a batch are
+// rejected
+type batchContextKey struct{}
+
+// BatchRequest is a request of the batch
+type BatchRequest struct {
+	// the id of the request chosen by the client, it's returned in the result
+	ID string `json:"id,omitempty"`
+	// GET by default
+	Method string `json:"method,omitempty"`
+	// the path of the route with the query, with or without the /api/v1 prefix
+	Path    string            `json:"path"`
+	Headers map[string]string `json:"headers,omitempty"`
+	// the form is sent as application/x-www-form-urlencoded unless the Content-Type header is set
+	Body string `json:"body,omitempty"`
+}
+
+// BatchResult is the result of a request of the batch
+type BatchResult struct {
+	ID     string `json:"id,omitempty"`
+	Status int    `json:"status"`
+	// the json response
+	Body json.RawMessage `json:"body,omitempty"`
+	// the error message of the responses not in json
+	Error string `json:"error,omitempty"`
+}
+
+// batchResponseWriter keeps the response of a request of the batch
+type batchResponseWriter struct {
+	header http.Header
+	code   int
+	buf    bytes.Buffer
+}
+
+func (w *batchResponseWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *batchResponseWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+}
+
+func (w *batchResponseWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.buf.Write(b)
+}
+
+// RegisterBatchHandlers register the handler of the batch requests
+func RegisterBatchHandlers(m *APIMux) {
+	// execute the api requests in one round trip
+	m.handleAPI("/batch", http.HandlerFunc(batchHandler(m.mux)))
+}
+
+// batchHandler executes the requests of the batch by the handlers of mux in order, the results
+// are in the order of the requests. The batch is authenticated and rate limited, and every
+// request is authenticated and rate limited like it's sent alone by the client of the batch. The
+// failure of a request doesn't stop the others.
+// method: POST
+// url: /api/v1/batch
+func batchHandler(mux http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		if r.Context().Value(batchContextKey{}) != nil {
+			wh.Error400(w, "batch can't be nested")
+			return
+		}
+
+		var reqs []BatchRequest
+		if err := json.NewDecoder(io.LimitReader(r.Body, maxBatchBodySize)).Decode(&reqs); err != nil {
+			wh.Error400(w, fmt.Sprintf("invalid batch: %v", err))
+			return
+		}
+
+		if len(reqs) == 0 {
+			wh.Error400(w, "batch is empty")
+			return
+		}
+
+		if len(reqs) > maxBatchRequests {
+			wh.Error400(w, fmt.Sprintf("batch has more than %d requests", maxBatchRequests))
+			return
+		}
+
+		results := make([]BatchResult, len(reqs))
+		for i, req := range reqs {
+			results[i] = doBatchRequest(mux, r, req)
+		}
+
+		wh.SendOr404(w, results)
+	}
+}
+
+func doBatchRequest(mux http.Handler, batch *http.Request, req BatchRequest) BatchResult {
+	res := BatchResult{ID: req.ID}
+
+	method := strings.ToUpper(req.Method)
+	if method == "" {
+		method = "GET"
+	}
+
+	p := req.Path
+	if !strings.HasPrefix(p, "/") {
+		res.Status = http.StatusBadRequest
+		res.Error = "path must begin with /"
+		return res
+	}
+	if !strings.HasPrefix(p, apiPrefix+"/") {
+		p = apiPrefix + p
+	}
+
+	u, err := url.Parse(p)
+	if err != nil {
+		res.Status = http.StatusBadRequest
+		res.Error = err.Error()
+		return res
+	}
+
+	// the batches aren't nested, the path is compared decoded. The batch handler rejects the
+	// requests of a batch by the context too.
+	if path.Clean(u.Path) == apiPrefix+"/batch" {
+		res.Status = http.StatusBadRequest
+		res.Error = "batch can't be nested"
+		return res
+	}
+
+	for k := range req.Headers {
+		for _, h := range batchForbiddenHeaders {
+			if http.CanonicalHeaderKey(k) == http.CanonicalHeaderKey(h) {
+				res.Status = http.StatusBadRequest
+				res.Error = fmt.Sprintf("header %s can't be set", h)
+				return res
+			}
+		}
+	}
+
+	r, err := http.NewRequest(method, p, strings.NewReader(req.Body))
+	if err != nil {
+		res.Status = http.StatusBadRequest
+		res.Error = err.Error()
+		return res
+	}
+	r = r.WithContext(context.WithValue(batch.Context(), batchContextKey{}, true))
+	r.RemoteAddr = batch.RemoteAddr
+	r.Host = batch.Host
+
+	for _, h := range batchInheritedHeaders {
+		if v := batch.Header.Get(h); v != "" {
+			r.Header.Set(h, v)
+		}
+	}
+	for k, v := range req.Headers {
+		r.Header.Set(k, v)
+	}
+	if req.Body != "" && r.Header.Get("Content-Type") == "" {
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	w := &batchResponseWriter{header: make(http.Header)}
+	mux.ServeHTTP(w, r)
+
+	res.Status = w.code
+	if res.Status == 0 {
+		res.Status = http.StatusOK
+	}
+
+	body := w.buf.Bytes()
+	switch {
+	case len(body) == 0:
+	case json.Valid(body):
+		res.Body = body
+	default:
+		res.Error = strings.TrimSpace(string(body))
+	}
+	return res
+}
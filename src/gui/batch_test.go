@@ -0,0 +1,134 @@
+package gui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+func TestBatchHandler(t *testing.T) {
+	c := NewAPIAuthConfig()
+	c.APIKey = "key"
+	c.ProtectedRoutes = []string{"/wallet"}
+
+	mux := http.NewServeMux()
+	api := NewAPIMux(mux, NewAPIAuth(c), nil)
+	api.HandleFunc("/blockchain/metadata", func(w http.ResponseWriter, r *http.Request) {
+		wh.SendOr404(w, map[string]string{"head": r.FormValue("n")})
+	})
+	api.HandleFunc("/wallet/spend", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+		fmt.Fprintf(w, `{"dst": "%s", "type": "%s"}`, r.FormValue("dst"), r.Header.Get("Content-Type"))
+	})
+	RegisterBatchHandlers(api)
+
+	do := func(method, body, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, "/api/v1/batch", strings.NewReader(body))
+		if key != "" {
+			r.Header.Set(APIKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	batch := `[
+		{"id": "head", "path": "/blockchain/metadata?n=1"},
+		{"id": "v1", "path": "/api/v1/blockchain/metadata?n=2"},
+		{"id": "spend", "method": "post", "path": "/wallet/spend", "body": "dst=a"},
+		{"path": "/wallet/spend"},
+		{"path": "/batch"},
+		{"path": "blockchain/metadata"},
+		{"path": "/api/v1/%62atch"},
+		{"path": "/blockchain/metadata", "headers": {"x-api-key": "other"}},
+		{"path": "/blockchain/metadata", "headers": {"X-Forwarded-For": "1.2.3.4"}}
+	]`
+
+	w := do("POST", batch, "key")
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var res []BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res, 9)
+
+	assert.Equal(t, "head", res[0].ID)
+	assert.Equal(t, http.StatusOK, res[0].Status)
+	assert.JSONEq(t, `{"head": "1"}`, string(res[0].Body))
+	assert.JSONEq(t, `{"head": "2"}`, string(res[1].Body))
+
+	assert.Equal(t, http.StatusOK, res[2].Status)
+	assert.JSONEq(t, `{"dst": "a", "type": "application/x-www-form-urlencoded"}`, string(res[2].Body))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, res[3].Status)
+	assert.Empty(t, res[3].Body)
+
+	// the nested batches, the encoded path too, and the headers of the client
+	for _, r := range res[4:] {
+		assert.Equal(t, http.StatusBadRequest, r.Status)
+	}
+	assert.Equal(t, "batch can't be nested", res[6].Error)
+	assert.Equal(t, "header X-API-Key can't be set", res[7].Error)
+	assert.Equal(t, "header X-Forwarded-For can't be set", res[8].Error)
+
+	// the batch is authenticated
+	w = do("POST", batch, "")
+	require.Equal(t, http.StatusUnauthorized, w.Code)
+
+	// the invalid batches
+	assert.Equal(t, http.StatusMethodNotAllowed, do("GET", "", "").Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "{}", "key").Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "[]", "key").Code)
+	assert.Equal(t, http.StatusBadRequest, do("POST", "["+strings.Repeat(`{"path": "/"},`, maxBatchRequests)+`{"path": "/"}]`, "key").Code)
+}
+
+func TestBatchHandlerRateLimit(t *testing.T) {
+	c := NewRateLimitConfig()
+	c.Limits = map[string]RateLimit{
+		RateClassRead:  {Rate: 0.01, Burst: 2},
+		RateClassWrite: {Rate: 0.01, Burst: 1},
+	}
+
+	mux := http.NewServeMux()
+	api := NewAPIMux(mux, nil, NewRateLimiter(c))
+	api.HandleFunc("/blockchain/metadata", func(w http.ResponseWriter, r *http.Request) {
+		wh.SendOr404(w, map[string]string{})
+	})
+	RegisterBatchHandlers(api)
+
+	do := func(body string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/api/v1/batch", strings.NewReader(body))
+		r.RemoteAddr = "1.2.3.4:51000"
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	// every request is counted in the bucket of the client of the batch
+	w := do(`[
+		{"path": "/blockchain/metadata"},
+		{"path": "/blockchain/metadata"},
+		{"path": "/blockchain/metadata"}
+	]`)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var res []BatchResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	require.Len(t, res, 3)
+	assert.Equal(t, http.StatusOK, res[0].Status)
+	assert.Equal(t, http.StatusOK, res[1].Status)
+	assert.Equal(t, http.StatusTooManyRequests, res[2].Status)
+
+	// the batch is limited too
+	assert.Equal(t, http.StatusTooManyRequests, do(`[{"path": "/blockchain/metadata"}]`).Code)
+}
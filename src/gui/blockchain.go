@@ -17,8 +17,10 @@ import (
 
 const lastBlockNum = 10
 
+const defaultBlocksPageSize = 20
+
 // RegisterBlockchainHandlers registers blockchain handlers
-func RegisterBlockchainHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterBlockchainHandlers(mux Mux, gateway *daemon.Gateway) {
 	mux.HandleFunc("/blockchain/metadata", blockchainHandler(gateway))
 	mux.HandleFunc("/blockchain/progress", blockchainProgressHandler(gateway))
 
@@ -28,8 +30,19 @@ func RegisterBlockchainHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	// mux.HandleFunc("/block/seq", getBlockBySeq(gateway))
 	// get blocks in specific range
 	mux.HandleFunc("/blocks", getBlocks(gateway))
+	// get blocks in specific range by page
+	mux.HandleFunc("/blocks_page", getBlocksPage(gateway))
 	// get last 10 blocks
 	mux.HandleFunc("/last_blocks", getLastBlocks(gateway))
+	// get recent chain reorganizations
+	mux.HandleFunc("/reorgs", getReorgEvents(gateway))
+	// get db size, bucket and compaction statistics
+	mux.HandleFunc("/blockchain/db_stats", getDBStats(gateway))
+
+	// Rebuilds the address, transaction and output indexes from the blocks
+	mux.HandleFunc("/blockchain/indexes/rebuild", rebuildIndexes(gateway))
+	// Progress of rebuilding the indexes
+	mux.HandleFunc("/blockchain/indexes/progress", getIndexProgress(gateway))
 }
 
 func blockchainHandler(gateway *daemon.Gateway) http.HandlerFunc {
@@ -48,6 +61,8 @@ func blockchainProgressHandler(gateway *daemon.Gateway) http.HandlerFunc {
 // method: GET
 // url: /block?hash=[:hash]  or /block?seq[:seq]
 // params: hash or seq, should only specify one filter.
+// set verbose=1 to resolve the transaction inputs.
+// set human_time=1 to emit RFC3339 timestamps alongside the unix times.
 func getBlock(gate *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
@@ -87,7 +102,27 @@ func getBlock(gate *daemon.Gateway) http.HandlerFunc {
 			wh.SendOr404(w, nil)
 			return
 		}
-		wh.SendOr404(w, visor.NewReadableBlock(&b))
+
+		if r.FormValue("verbose") == "1" {
+			rb, err := gate.GetBlockVerbose(&b)
+			if err != nil {
+				logger.Error("get verbose block failed: %v", err)
+				wh.Error500(w, err.Error())
+				return
+			}
+			wh.SendOr404(w, &rb)
+			return
+		}
+
+		wh.SendOr404(w, visor.NewReadableBlockWithOptions(&b, encodeOptions(r)))
+	}
+}
+
+// encodeOptions parses the readable encode options from request
+func encodeOptions(r *http.Request) visor.ReadableEncodeOptions {
+	return visor.ReadableEncodeOptions{
+		HumanTime:    r.FormValue("human_time") == "1",
+		DecimalCoins: r.FormValue("decimal_coins") == "1",
 	}
 }
 
@@ -110,7 +145,60 @@ func getBlocks(gateway *daemon.Gateway) http.HandlerFunc {
 			wh.Error400(w, fmt.Sprintf("Invalid end value \"%s\"", send))
 			return
 		}
-		wh.SendOr404(w, gateway.GetBlocks(start, end))
+
+		if end >= start && end-start >= visor.MaxStreamBlocks {
+			wh.Error400(w, fmt.Sprintf("at most %d blocks can be requested at once", visor.MaxStreamBlocks))
+			return
+		}
+
+		// the blocks are streamed, so that large range won't be loaded into memory
+		w.Header().Set("Content-Type", "application/json")
+		if err := gateway.EncodeBlocksToWriter(newFlushWriter(w), start, end); err != nil {
+			logger.Error("Encode blocks failed: %v", err)
+		}
+	}
+}
+
+// get blocks in range by page
+// method: GET
+// url: /blocks_page?start=[:start]&end=[:end]&page_size=[:page_size]
+// params: page_size is optional, the next_cursor in response can be used as start of next page.
+func getBlocksPage(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+		sstart := r.FormValue("start")
+		start, err := strconv.ParseUint(sstart, 10, 64)
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("Invalid start value \"%s\"", sstart))
+			return
+		}
+
+		send := r.FormValue("end")
+		end, err := strconv.ParseUint(send, 10, 64)
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("Invalid end value \"%s\"", send))
+			return
+		}
+
+		pageSize := defaultBlocksPageSize
+		if ps := r.FormValue("page_size"); ps != "" {
+			pageSize, err = strconv.Atoi(ps)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("Invalid page_size value \"%s\"", ps))
+				return
+			}
+		}
+
+		page, err := gateway.GetBlocksPage(start, end, pageSize)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, page)
 	}
 }
 
@@ -137,3 +225,61 @@ func getLastBlocks(gateway *daemon.Gateway) http.HandlerFunc {
 		wh.SendOr404(w, gateway.GetLastBlocks(n))
 	}
 }
+
+// getReorgEvents returns the recent chain reorganizations, with the blocks that were
+// disconnected from the chain
+func getReorgEvents(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetReorgEvents())
+	}
+}
+
+func getDBStats(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		stats, err := gateway.GetDBStats()
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, stats)
+	}
+}
+
+// rebuildIndexes starts rebuilding the indexes, returns the progress
+func rebuildIndexes(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		if err := gateway.StartRebuildIndexes(); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetIndexProgress())
+	}
+}
+
+func getIndexProgress(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetIndexProgress())
+	}
+}
@@ -0,0 +1,40 @@
+package gui
+
+import (
+	"net/http"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// ConfigReloader reloads the hot-reloadable settings of the node from the config file, the
+// environment variables and the command line, and returns the changed settings. It's set by the
+// node, the config can't be reloaded if nil.
+var ConfigReloader func() (map[string]string, error)
+
+// RegisterConfigHandlers registers the handlers of the node config
+func RegisterConfigHandlers(mux Mux) {
+	// Reloads the hot-reloadable settings
+	mux.HandleFunc("/config/reload", reloadConfig)
+}
+
+// reloadConfig reloads the peer limits, the rate limits, the log levels and the watched addresses
+// of the config, as SIGHUP does. It returns the changed settings by flag name.
+// method: POST
+func reloadConfig(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		wh.Error405(w, "")
+		return
+	}
+
+	if ConfigReloader == nil {
+		wh.Error503(w, "config reload is not supported")
+		return
+	}
+
+	changed, err := ConfigReloader()
+	if err != nil {
+		wh.Error400(w, err.Error())
+		return
+	}
+	wh.SendOr404(w, changed)
+}
@@ -0,0 +1,157 @@
+package gui
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// CORS global cors policy of the web interface, the cross origin requests aren't allowed if nil
+var CORS *CORSConfig
+
+// ErrCORSAllOrigins is returned if all the origins are allowed with the authentication, any page
+// could read the csrf tokens and use the protected routes
+var ErrCORSAllOrigins = errors.New("cors origin * can't be allowed with the api key or the csrf")
+
+// InitCORS init the cors policy, it's applied to the web interface launched after. The policy is
+// validated with the authentication of the api.
+func InitCORS(c CORSConfig, auth APIAuthConfig) error {
+	if err := c.Validate(auth); err != nil {
+		return err
+	}
+
+	if len(c.AllowedOrigins) == 0 {
+		CORS = nil
+		return nil
+	}
+	CORS = &c
+	return nil
+}
+
+// CORSConfig configures the cors policy.
+//
+// The allowed origins can use the api like the web GUI, the api key and the csrf token headers
+// are allowed by default.
+type CORSConfig struct {
+	// the origins allowed, e.g. https://explorer.example.com, * allows all the origins
+	AllowedOrigins []string
+	AllowedMethods []string
+	AllowedHeaders []string
+	// how long in seconds the browsers cache the preflight results, they're not cached if 0
+	MaxAge int
+}
+
+// NewCORSConfig returns a CORSConfig with defaults set, no origin is allowed
+func NewCORSConfig() CORSConfig {
+	return CORSConfig{
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", APIKeyHeader, CSRFTokenHeader},
+		MaxAge:         600,
+	}
+}
+
+// Validate checks the policy with the authentication of auth, all the origins can't be allowed
+// if the api key or the csrf is enabled
+func (c CORSConfig) Validate(auth APIAuthConfig) error {
+	if auth.APIKey == "" && !auth.CSRF {
+		return nil
+	}
+
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			return ErrCORSAllOrigins
+		}
+	}
+	return nil
+}
+
+func (c *CORSConfig) originAllowed(origin string) bool {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSConfig) methodAllowed(method string) bool {
+	for _, m := range c.AllowedMethods {
+		if strings.EqualFold(m, method) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c *CORSConfig) headersAllowed(headers string) bool {
+	for _, h := range strings.Split(headers, ",") {
+		h = strings.TrimSpace(h)
+		if h == "" {
+			continue
+		}
+
+		allowed := false
+		for _, a := range c.AllowedHeaders {
+			if strings.EqualFold(a, h) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// corsHandler answers the preflight requests and sets the cors headers of the allowed origins
+func corsHandler(c *CORSConfig, handler http.Handler) http.Handler {
+	if c == nil {
+		return handler
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		if origin == "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		preflight := r.Method == "OPTIONS" && r.Header.Get("Access-Control-Request-Method") != ""
+		if !c.originAllowed(origin) {
+			if preflight {
+				w.WriteHeader(http.StatusForbidden)
+				return
+			}
+			// the browser blocks the response without the cors headers
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		h.Set("Access-Control-Allow-Origin", origin)
+		if !preflight {
+			h.Set("Access-Control-Expose-Headers", "Deprecation, Link")
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		if !c.methodAllowed(r.Header.Get("Access-Control-Request-Method")) ||
+			!c.headersAllowed(r.Header.Get("Access-Control-Request-Headers")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		h.Set("Access-Control-Allow-Methods", strings.Join(c.AllowedMethods, ", "))
+		h.Set("Access-Control-Allow-Headers", strings.Join(c.AllowedHeaders, ", "))
+		if c.MaxAge > 0 {
+			h.Set("Access-Control-Max-Age", strconv.Itoa(c.MaxAge))
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
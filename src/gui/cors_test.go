@@ -0,0 +1,111 @@
+package gui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCORSHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	c := NewCORSConfig()
+	c.AllowedOrigins = []string{"https://explorer.example.com"}
+	h := corsHandler(&c, ok)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		code    int
+		body    string
+		allow   string
+		methods string
+	}{
+		{"same origin", "GET", nil, http.StatusOK, "ok", "", ""},
+		{"allowed origin", "GET", map[string]string{"Origin": "https://explorer.example.com"},
+			http.StatusOK, "ok", "https://explorer.example.com", ""},
+		{"origin not allowed", "GET", map[string]string{"Origin": "https://evil.example.com"},
+			http.StatusOK, "ok", "", ""},
+		{"preflight", "OPTIONS", map[string]string{
+			"Origin":                         "https://explorer.example.com",
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "content-type, x-api-key",
+		}, http.StatusNoContent, "", "https://explorer.example.com", "GET, POST"},
+		{"preflight origin not allowed", "OPTIONS", map[string]string{
+			"Origin":                        "https://evil.example.com",
+			"Access-Control-Request-Method": "POST",
+		}, http.StatusForbidden, "", "", ""},
+		{"preflight method not allowed", "OPTIONS", map[string]string{
+			"Origin":                        "https://explorer.example.com",
+			"Access-Control-Request-Method": "DELETE",
+		}, http.StatusForbidden, "", "https://explorer.example.com", ""},
+		{"preflight header not allowed", "OPTIONS", map[string]string{
+			"Origin":                         "https://explorer.example.com",
+			"Access-Control-Request-Method":  "POST",
+			"Access-Control-Request-Headers": "X-Custom",
+		}, http.StatusForbidden, "", "https://explorer.example.com", ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, "/api/v1/blocks", nil)
+			for k, v := range tc.headers {
+				r.Header.Set(k, v)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			assert.Equal(t, tc.code, w.Code)
+			assert.Equal(t, tc.body, w.Body.String())
+			assert.Equal(t, tc.allow, w.Header().Get("Access-Control-Allow-Origin"))
+			assert.Equal(t, tc.methods, w.Header().Get("Access-Control-Allow-Methods"))
+		})
+	}
+
+	// all the origins
+	c.AllowedOrigins = []string{"*"}
+	r := httptest.NewRequest("GET", "/api/v1/blocks", nil)
+	r.Header.Set("Origin", "https://wallet.example.org")
+	w := httptest.NewRecorder()
+	corsHandler(&c, ok).ServeHTTP(w, r)
+	assert.Equal(t, "https://wallet.example.org", w.Header().Get("Access-Control-Allow-Origin"))
+
+	// no cors policy
+	require.NoError(t, InitCORS(NewCORSConfig(), NewAPIAuthConfig()))
+	assert.Nil(t, CORS)
+}
+
+func TestInitCORSAllOrigins(t *testing.T) {
+	defer func(c *CORSConfig) { CORS = c }(CORS)
+	CORS = nil
+
+	c := NewCORSConfig()
+	c.AllowedOrigins = []string{"https://explorer.example.com", "*"}
+
+	// any page could read the csrf token and use the protected routes
+	ac := NewAPIAuthConfig()
+	ac.CSRF = true
+	require.Equal(t, ErrCORSAllOrigins, InitCORS(c, ac))
+	assert.Nil(t, CORS)
+
+	ac = NewAPIAuthConfig()
+	ac.APIKey = "key"
+	require.Equal(t, ErrCORSAllOrigins, InitCORS(c, ac))
+	assert.Nil(t, CORS)
+
+	// the origins are listed
+	ac.CSRF = true
+	c.AllowedOrigins = []string{"https://explorer.example.com"}
+	require.NoError(t, InitCORS(c, ac))
+	require.NotNil(t, CORS)
+
+	// the api isn't authenticated
+	c.AllowedOrigins = []string{"*"}
+	require.NoError(t, InitCORS(c, NewAPIAuthConfig()))
+	require.Equal(t, []string{"*"}, CORS.AllowedOrigins)
+}
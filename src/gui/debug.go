@@ -0,0 +1,50 @@
+package gui
+
+import (
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rpprof "runtime/pprof"
+	"strconv"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// PprofEnabled enables the pprof profiles, the execution trace and the goroutine dump of the web
+// interface launched after. They're protected routes, set the api key when exposing them.
+var PprofEnabled = false
+
+// RegisterDebugHandlers registers the runtime profiling handlers
+func RegisterDebugHandlers(mux Mux) {
+	// the index of the profiles, and the profiles by name, e.g. heap, goroutine, block, mutex
+	// 		GET /debug/pprof/[:name]?debug=[:debug]&seconds=[:seconds]
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	// the command line of the node
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	// the cpu profile of the seconds
+	// 		GET /debug/pprof/profile?seconds=[:seconds]
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	// the symbols of the program counters
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	// the execution trace of the seconds
+	// 		GET /debug/pprof/trace?seconds=[:seconds]
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	// the stacks of all the goroutines
+	// 		GET /debug/goroutines
+	mux.HandleFunc("/debug/goroutines", getGoroutines)
+}
+
+// getGoroutines dumps the stacks of all the goroutines as text, in the format of an unrecovered
+// panic, for diagnosing the deadlocks
+func getGoroutines(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		wh.Error405(w, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("X-Goroutine-Count", strconv.Itoa(runtime.NumGoroutine()))
+	if err := rpprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		logger.Error("Dump goroutines failed: %v", err)
+	}
+}
@@ -0,0 +1,31 @@
+package gui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetGoroutines(t *testing.T) {
+	rr := httptest.NewRecorder()
+	getGoroutines(rr, httptest.NewRequest("GET", "/debug/goroutines", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	require.NotEmpty(t, rr.Header().Get("X-Goroutine-Count"))
+	require.True(t, strings.HasPrefix(rr.Body.String(), "goroutine "))
+	require.Contains(t, rr.Body.String(), "TestGetGoroutines")
+
+	rr = httptest.NewRecorder()
+	getGoroutines(rr, httptest.NewRequest("POST", "/debug/goroutines", nil))
+	require.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func TestDebugRoutesProtected(t *testing.T) {
+	c := NewAPIAuthConfig()
+	c.APIKey = "secret"
+	a := NewAPIAuth(c)
+	require.Equal(t, accessProtected, a.routeAccess("/debug/pprof/"))
+	require.Equal(t, accessProtected, a.routeAccess("/debug/goroutines"))
+}
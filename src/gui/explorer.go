@@ -1,6 +1,7 @@
 package gui
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -12,11 +13,24 @@ import (
 )
 
 // RegisterExplorerHandlers register explorer handlers
-func RegisterExplorerHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterExplorerHandlers(mux Mux, gateway *daemon.Gateway) {
 	// get set of pending transactions
 	mux.HandleFunc("/explorer/address", getTransactionsForAddress(gateway))
 
 	mux.HandleFunc("/explorer/getEffectiveOutputs", getCoinSupply(gateway))
+
+	// get the first seen, last active block and the totals of address
+	mux.HandleFunc("/explorer/address_summary", getAddressSummary(gateway))
+
+	// get the top holders
+	mux.HandleFunc("/explorer/richlist", getRichList(gateway))
+
+	// get the distribution statistics of the supply
+	mux.HandleFunc("/explorer/supply-stats", getSupplyStats(gateway))
+
+	// export the output movements of blocks or address as CSV
+	mux.HandleFunc("/explorer/export/blocks", exportBlocksCSV(gateway))
+	mux.HandleFunc("/explorer/export/address", exportAddressCSV(gateway))
 }
 
 var addrList = []string{
@@ -169,6 +183,178 @@ func getCoinSupply(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// method: GET
+// url: /explorer/richlist?n=${n}&include-distribution=${bool}
+// n defaults to 20, set n=0 to list all addresses.
+func getRichList(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w)
+			return
+		}
+
+		topN := 20
+		if n := r.FormValue("n"); n != "" {
+			var err error
+			topN, err = strconv.Atoi(n)
+			if err != nil {
+				wh.Error400(w, "invalid n")
+				return
+			}
+		}
+
+		var includeDistribution bool
+		if d := r.FormValue("include-distribution"); d != "" {
+			var err error
+			includeDistribution, err = strconv.ParseBool(d)
+			if err != nil {
+				wh.Error400(w, "invalid include-distribution")
+				return
+			}
+		}
+
+		rl, err := gateway.GetRichList(topN, includeDistribution)
+		if err != nil {
+			wh.Error500(w)
+			logger.Error("Get rich list failed: %v", err)
+			return
+		}
+
+		wh.SendOr404(w, rl)
+	}
+}
+
+// method: GET
+// url: /explorer/address_summary?address=${address}
+func getAddressSummary(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w)
+			return
+		}
+
+		addr, err := cipher.DecodeBase58Address(r.FormValue("address"))
+		if err != nil {
+			wh.Error400(w, "invalid address")
+			return
+		}
+
+		as, err := gateway.GetAddressSummary(addr)
+		if err != nil {
+			wh.Error500(w)
+			logger.Error("Get address summary failed: %v", err)
+			return
+		}
+
+		if as == nil {
+			wh.Error404(w, "not found")
+			return
+		}
+
+		wh.SendOr404(w, as)
+	}
+}
+
+// method: GET
+// url: /explorer/supply-stats
+func getSupplyStats(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w)
+			return
+		}
+
+		ss, err := gateway.GetSupplyStats()
+		if err != nil {
+			wh.Error500(w)
+			logger.Error("Get supply stats failed: %v", err)
+			return
+		}
+
+		wh.SendOr404(w, ss)
+	}
+}
+
+// method: GET
+// url: /explorer/export/blocks?start=${start}&end=${end}
+func exportBlocksCSV(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w)
+			return
+		}
+
+		start, err := strconv.ParseUint(r.FormValue("start"), 10, 64)
+		if err != nil {
+			wh.Error400(w, "invalid start")
+			return
+		}
+
+		end, err := strconv.ParseUint(r.FormValue("end"), 10, 64)
+		if err != nil {
+			wh.Error400(w, "invalid end")
+			return
+		}
+
+		if start > end {
+			wh.Error400(w, "start must not be greater than end")
+			return
+		}
+
+		if end-start >= visor.MaxExportBlocks {
+			wh.Error400(w, fmt.Sprintf("at most %d blocks can be exported at once", visor.MaxExportBlocks))
+			return
+		}
+
+		records, err := gateway.GetBlocksCSVRecords(start, end)
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		// the rows are written after the visor is released, so a slow client doesn't block it
+		setCSVHeader(w, fmt.Sprintf("blocks-%d-%d.csv", start, end))
+		if err := visor.WriteCSV(newFlushWriter(w), records); err != nil {
+			// the rows may have been sent, so the status code can't be changed
+			logger.Error("Export blocks failed: %v", err)
+		}
+	}
+}
+
+// method: GET
+// url: /explorer/export/address?address=${address}
+func exportAddressCSV(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w)
+			return
+		}
+
+		addr, err := cipher.DecodeBase58Address(r.FormValue("address"))
+		if err != nil {
+			wh.Error400(w, "invalid address")
+			return
+		}
+
+		records, err := gateway.GetAddressCSVRecords(addr)
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		setCSVHeader(w, fmt.Sprintf("%s.csv", addr.String()))
+		if err := visor.WriteCSV(newFlushWriter(w), records); err != nil {
+			// the rows may have been sent, so the status code can't be changed
+			logger.Error("Export address failed: %v", err)
+		}
+	}
+}
+
+func setCSVHeader(w http.ResponseWriter, filename string) {
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+}
+
 // method: GET
 // url: /explorer/address?address=${address}
 func getTransactionsForAddress(gateway *daemon.Gateway) http.HandlerFunc {
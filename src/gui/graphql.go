@@ -0,0 +1,508 @@
+package gui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/util/graphql"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+const (
+	// graphqlMaxBlocks is the max number of the blocks of a blocks query
+	graphqlMaxBlocks = 100
+	// graphqlMaxDepth is the max depth of the objects of a query
+	graphqlMaxDepth = 8
+	// the max size of the body of the GraphQL request
+	maxGraphQLBodySize = graphql.MaxQuerySize + 64*1024
+)
+
+// GraphQLEnabled enables the GraphQL explorer queries of the web interface launched after
+var GraphQLEnabled = false
+
+// graphqlBlock is the Block of the GraphQL queries
+type graphqlBlock struct {
+	visor.ReadableBlockHeader
+	Pruned bool `json:"pruned"`
+	txns   []visor.ReadableTransaction
+}
+
+func newGraphQLBlock(b visor.ReadableBlock) *graphqlBlock {
+	return &graphqlBlock{
+		ReadableBlockHeader: b.Head,
+		Pruned:              b.Pruned,
+		txns:                b.Body.Transactions,
+	}
+}
+
+// graphqlTxn is the Transaction of the GraphQL queries, the status and the fee are loaded when
+// they're selected, the transactions of the block bodies don't have them
+type graphqlTxn struct {
+	visor.ReadableTransaction
+	Time   uint64 `json:"time"`
+	result *visor.TransactionResult
+}
+
+func (t *graphqlTxn) load(gateway *daemon.Gateway) (*visor.TransactionResult, error) {
+	if t.result != nil {
+		return t.result, nil
+	}
+
+	txid, err := cipher.SHA256FromHex(t.Hash)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := gateway.GetTransactionResult(txid)
+	if err != nil {
+		return nil, err
+	}
+	if res == nil {
+		return nil, fmt.Errorf("transaction %s not found", t.Hash)
+	}
+	t.result = res
+	return res, nil
+}
+
+func newGraphQLTxnFromResult(r *visor.TransactionResult) *graphqlTxn {
+	return &graphqlTxn{
+		ReadableTransaction: r.Transaction,
+		Time:                r.Time,
+		result:              r,
+	}
+}
+
+// newExplorerSchema creates the GraphQL schema of the blocks, the transactions, the outputs and
+// the addresses. The fields are named like the json fields of the other api.
+func newExplorerSchema(gateway *daemon.Gateway) *graphql.Schema {
+	status := &graphql.Object{
+		Name: "TransactionStatus",
+		Fields: map[string]*graphql.FieldDef{
+			"confirmed":     {},
+			"unconfirmed":   {},
+			"height":        {},
+			"block_seq":     {},
+			"confirmations": {},
+			"block_hash":    {},
+			"unknown":       {},
+		},
+	}
+
+	txn := &graphql.Object{Name: "Transaction"}
+	output := &graphql.Object{Name: "Output"}
+	block := &graphql.Object{Name: "Block"}
+
+	getTxn := func(hash string) (interface{}, error) {
+		if hash == "" {
+			return nil, nil
+		}
+		txid, err := cipher.SHA256FromHex(hash)
+		if err != nil {
+			return nil, err
+		}
+		res, err := gateway.GetTransactionResult(txid)
+		if err != nil || res == nil {
+			return nil, err
+		}
+		return newGraphQLTxnFromResult(res), nil
+	}
+
+	getOutput := func(hash string) (interface{}, error) {
+		uxid, err := cipher.SHA256FromHex(hash)
+		if err != nil {
+			return nil, err
+		}
+		out, err := gateway.GetUxOutVerbose(uxid)
+		if err != nil || out == nil {
+			return nil, err
+		}
+		return out, nil
+	}
+
+	getBlock := func(b coin.Block, ok bool) (interface{}, error) {
+		if !ok {
+			return nil, nil
+		}
+		return newGraphQLBlock(visor.NewReadableBlock(&b)), nil
+	}
+
+	output.Fields = map[string]*graphql.FieldDef{
+		"hash":            {},
+		"src_tx":          {},
+		"src_block_seq":   {},
+		"time":            {},
+		"address":         {},
+		"coins":           {},
+		"hours":           {},
+		"spent":           {},
+		"spent_tx":        {},
+		"spent_block_seq": {},
+		"source_transaction": {
+			Type: txn,
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				return getTxn(p.Source.(*visor.ReadableOutputVerbose).SourceTransaction)
+			},
+		},
+		"spent_transaction": {
+			Type: txn,
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				return getTxn(p.Source.(*visor.ReadableOutputVerbose).SpentTxID)
+			},
+		},
+	}
+
+	txnOutput := &graphql.Object{
+		Name: "TransactionOutput",
+		Fields: map[string]*graphql.FieldDef{
+			"uxid":  {},
+			"dst":   {},
+			"coins": {},
+			"hours": {},
+			// the spending of the output
+			"output": {
+				Type: output,
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					return getOutput(p.Source.(visor.ReadableTransactionOutput).Hash)
+				},
+			},
+		},
+	}
+
+	txn.Fields = map[string]*graphql.FieldDef{
+		"txid":       {},
+		"inner_hash": {},
+		"length":     {},
+		"type":       {},
+		"time":       {},
+		"block_seq":  {},
+		"sigs":       {},
+		"fee": {
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				res, err := p.Source.(*graphqlTxn).load(gateway)
+				if err != nil {
+					return nil, err
+				}
+				return res.Transaction.Fee, nil
+			},
+		},
+		"status": {
+			Type: status,
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				res, err := p.Source.(*graphqlTxn).load(gateway)
+				if err != nil {
+					return nil, err
+				}
+				return res.Status, nil
+			},
+		},
+		// the outputs spent, resolved
+		"inputs": {
+			Type: output,
+			List: true,
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				in := p.Source.(*graphqlTxn).In
+				outs := make([]interface{}, len(in))
+				for i, h := range in {
+					out, err := getOutput(h)
+					if err != nil {
+						return nil, err
+					}
+					outs[i] = out
+				}
+				return outs, nil
+			},
+		},
+		"outputs": {
+			Type: txnOutput,
+			List: true,
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				return p.Source.(*graphqlTxn).Out, nil
+			},
+		},
+		"block": {
+			Type: block,
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				res, err := p.Source.(*graphqlTxn).load(gateway)
+				if err != nil || !res.Status.Confirmed {
+					return nil, err
+				}
+				return getBlock(gateway.GetBlockBySeq(res.Status.BlockSeq))
+			},
+		},
+	}
+
+	block.Fields = map[string]*graphql.FieldDef{
+		"seq":                 {},
+		"block_hash":          {},
+		"previous_block_hash": {},
+		"timestamp":           {},
+		"fee":                 {},
+		"version":             {},
+		"tx_body_hash":        {},
+		"ux_hash":             {},
+		"pruned":              {},
+		"transactions": {
+			Type: txn,
+			List: true,
+			Resolve: func(p graphql.Params) (interface{}, error) {
+				b := p.Source.(*graphqlBlock)
+				txns := make([]*graphqlTxn, len(b.txns))
+				for i := range b.txns {
+					txns[i] = &graphqlTxn{
+						ReadableTransaction: b.txns[i],
+						Time:                b.Time,
+					}
+					seq := b.BkSeq
+					txns[i].BlockSeq = &seq
+				}
+				return txns, nil
+			},
+		},
+	}
+
+	balance := &graphql.Object{
+		Name: "Balance",
+		Fields: map[string]*graphql.FieldDef{
+			"coins": {},
+			"hours": {},
+		},
+	}
+
+	summary := &graphql.Object{
+		Name: "AddressSummary",
+		Fields: map[string]*graphql.FieldDef{
+			"first_seen_block_seq":  {},
+			"first_seen_time":       {},
+			"last_active_block_seq": {},
+			"last_active_time":      {},
+			"total_received":        {},
+			"total_sent":            {},
+			"txn_count":             {},
+		},
+	}
+
+	address := &graphql.Object{
+		Name: "Address",
+		Fields: map[string]*graphql.FieldDef{
+			"address": {
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					return p.Source.(cipher.Address).String(), nil
+				},
+			},
+			"balance": {
+				Type: &graphql.Object{
+					Name: "BalancePair",
+					Fields: map[string]*graphql.FieldDef{
+						"confirmed": {Type: balance},
+						"predicted": {Type: balance},
+					},
+				},
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					return gateway.AddressesBalance([]cipher.Address{p.Source.(cipher.Address)})
+				},
+			},
+			"summary": {
+				Type: summary,
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					as, err := gateway.GetAddressSummary(p.Source.(cipher.Address))
+					if err != nil || as == nil {
+						return nil, err
+					}
+					return as, nil
+				},
+			},
+			"transactions": {
+				Type: txn,
+				List: true,
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					res, err := gateway.GetAddressTxns(p.Source.(cipher.Address))
+					if err != nil {
+						return nil, err
+					}
+					txns := make([]*graphqlTxn, len(res.Txns))
+					for i := range res.Txns {
+						txns[i] = newGraphQLTxnFromResult(&res.Txns[i])
+					}
+					return txns, nil
+				},
+			},
+			// the outputs received by the address, spent or not
+			"outputs": {
+				Type: output,
+				List: true,
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					outs, err := gateway.GetAddrUxOutsVerbose(p.Source.(cipher.Address))
+					if err != nil {
+						return nil, err
+					}
+					l := make([]*visor.ReadableOutputVerbose, len(outs))
+					for i := range outs {
+						l[i] = &outs[i]
+					}
+					return l, nil
+				},
+			},
+		},
+	}
+
+	query := &graphql.Object{
+		Name: "Query",
+		Fields: map[string]*graphql.FieldDef{
+			// the block by seq or hash
+			"block": {
+				Type: block,
+				Args: map[string]graphql.Arg{
+					"seq":  {Type: graphql.Int},
+					"hash": {Type: graphql.String},
+				},
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					seq, hasSeq := p.Args["seq"].(int)
+					hash, hasHash := p.Args["hash"].(string)
+					switch {
+					case hasSeq == hasHash:
+						return nil, fmt.Errorf("should specify one filter, hash or seq")
+					case hasHash:
+						h, err := cipher.SHA256FromHex(hash)
+						if err != nil {
+							return nil, err
+						}
+						return getBlock(gateway.GetBlockByHash(h))
+					case seq < 0:
+						return nil, fmt.Errorf("invalid seq %d", seq)
+					default:
+						return getBlock(gateway.GetBlockBySeq(uint64(seq)))
+					}
+				},
+			},
+			// the blocks of the seq range, both included
+			"blocks": {
+				Type: block,
+				List: true,
+				Args: map[string]graphql.Arg{
+					"start": {Type: graphql.Int, Required: true},
+					"end":   {Type: graphql.Int, Required: true},
+				},
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					start, end := p.Args["start"].(int), p.Args["end"].(int)
+					if start < 0 || end < start {
+						return nil, fmt.Errorf("invalid range %d-%d", start, end)
+					}
+					if end-start >= graphqlMaxBlocks {
+						return nil, fmt.Errorf("the range has more than %d blocks", graphqlMaxBlocks)
+					}
+
+					rbs := gateway.GetBlocks(uint64(start), uint64(end))
+					if rbs == nil {
+						return nil, nil
+					}
+					blocks := make([]*graphqlBlock, len(rbs.Blocks))
+					for i := range rbs.Blocks {
+						blocks[i] = newGraphQLBlock(rbs.Blocks[i])
+					}
+					return blocks, nil
+				},
+			},
+			// the head block
+			"head": {
+				Type: block,
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					rbs := gateway.GetLastBlocks(1)
+					if rbs == nil || len(rbs.Blocks) == 0 {
+						return nil, nil
+					}
+					return newGraphQLBlock(rbs.Blocks[0]), nil
+				},
+			},
+			"transaction": {
+				Type: txn,
+				Args: map[string]graphql.Arg{
+					"txid": {Type: graphql.String, Required: true},
+				},
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					return getTxn(p.Args["txid"].(string))
+				},
+			},
+			"output": {
+				Type: output,
+				Args: map[string]graphql.Arg{
+					"uxid": {Type: graphql.String, Required: true},
+				},
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					return getOutput(p.Args["uxid"].(string))
+				},
+			},
+			"address": {
+				Type: address,
+				Args: map[string]graphql.Arg{
+					"address": {Type: graphql.String, Required: true},
+				},
+				Resolve: func(p graphql.Params) (interface{}, error) {
+					return cipher.DecodeBase58Address(p.Args["address"].(string))
+				},
+			},
+		},
+	}
+
+	return &graphql.Schema{
+		Query:    query,
+		MaxDepth: graphqlMaxDepth,
+	}
+}
+
+// RegisterGraphQLHandlers register the GraphQL explorer queries handler
+func RegisterGraphQLHandlers(m *APIMux, gateway *daemon.Gateway) {
+	// query the blocks, the transactions, the outputs and the addresses
+	m.handleAPI("/graphql", http.HandlerFunc(graphqlHandler(newExplorerSchema(gateway))))
+}
+
+// graphqlHandler executes the GraphQL queries, the query of GET is in the query, operationName and
+// variables params, the query of POST is the json body. The errors of the query are in the result.
+// method: GET, POST
+// url: /api/v1/graphql
+func graphqlHandler(schema *graphql.Schema) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req graphql.Request
+		switch r.Method {
+		case "GET":
+			req.Query = r.FormValue("query")
+			req.OperationName = r.FormValue("operationName")
+			if vars := r.FormValue("variables"); vars != "" {
+				if err := json.Unmarshal([]byte(vars), &req.Variables); err != nil {
+					wh.Error400(w, fmt.Sprintf("invalid variables: %v", err))
+					return
+				}
+			}
+		case "POST":
+			body := io.LimitReader(r.Body, maxGraphQLBodySize)
+			if strings.HasPrefix(r.Header.Get("Content-Type"), "application/graphql") {
+				d, err := ioutil.ReadAll(body)
+				if err != nil {
+					wh.Error400(w, err.Error())
+					return
+				}
+				req.Query = string(d)
+			} else if err := json.NewDecoder(body).Decode(&req); err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid request: %v", err))
+				return
+			}
+		default:
+			wh.Error405(w, "")
+			return
+		}
+
+		if req.Query == "" {
+			wh.Error400(w, "query is empty")
+			return
+		}
+
+		wh.SendOr404(w, schema.Do(req))
+	}
+}
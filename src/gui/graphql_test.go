@@ -0,0 +1,81 @@
+package gui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/util/graphql"
+)
+
+func TestGraphQLHandler(t *testing.T) {
+	schema := &graphql.Schema{
+		Query: &graphql.Object{
+			Name: "Query",
+			Fields: map[string]*graphql.FieldDef{
+				"echo": {
+					Args: map[string]graphql.Arg{"s": {Type: graphql.String}},
+					Resolve: func(p graphql.Params) (interface{}, error) {
+						return p.Args["s"], nil
+					},
+				},
+			},
+		},
+	}
+
+	mux := http.NewServeMux()
+	api := NewAPIMux(mux, nil, nil)
+	api.handleAPI("/graphql", graphqlHandler(schema))
+
+	q := url.Values{
+		"query":     {`query ($s: String) { echo(s: $s) }`},
+		"variables": {`{"s": "a"}`},
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		url         string
+		contentType string
+		body        string
+		code        int
+		result      string
+	}{
+		{"get", "GET", "/api/v1/graphql?" + q.Encode(), "", "", http.StatusOK,
+			"{\n    \"data\": {\n        \"echo\": \"a\"\n    }\n}"},
+		{"post json", "POST", "/api/v1/graphql", "application/json",
+			`{"query": "query ($s: String) { echo(s: $s) }", "variables": {"s": "b"}}`, http.StatusOK,
+			"{\n    \"data\": {\n        \"echo\": \"b\"\n    }\n}"},
+		{"post graphql", "POST", "/api/v1/graphql", "application/graphql",
+			`{ echo(s: "c") }`, http.StatusOK,
+			"{\n    \"data\": {\n        \"echo\": \"c\"\n    }\n}"},
+		{"query error", "POST", "/api/v1/graphql", "application/graphql",
+			`{ nope }`, http.StatusOK,
+			"{\n    \"data\": null,\n    \"errors\": [\n        {\n            \"message\": \"cannot query field \\\"nope\\\" on type Query\"\n        }\n    ]\n}"},
+		{"no query", "GET", "/api/v1/graphql", "", "", http.StatusBadRequest, ""},
+		{"invalid variables", "GET", "/api/v1/graphql?query=%7Becho%7D&variables=x", "", "", http.StatusBadRequest, ""},
+		{"invalid body", "POST", "/api/v1/graphql", "application/json", "x", http.StatusBadRequest, ""},
+		{"method", "PUT", "/api/v1/graphql", "", "", http.StatusMethodNotAllowed, ""},
+		{"no legacy path", "GET", "/graphql?" + q.Encode(), "", "", http.StatusNotFound, ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest(tc.method, tc.url, strings.NewReader(tc.body))
+			if tc.contentType != "" {
+				r.Header.Set("Content-Type", tc.contentType)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+
+			require.Equal(t, tc.code, w.Code)
+			if tc.result != "" {
+				require.Equal(t, tc.result, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,410 @@
+//go:build grpc
+// +build grpc
+
+package gui
+
+import (
+	"context"
+	"encoding/hex"
+	"fmt"
+	"net"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/skycoin/skycoin/src/api/grpcapi"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+// grpcEventsBufSize is the buffer of the events of a stream, the events are dropped if the
+// client can't keep up
+const grpcEventsBufSize = 256
+
+var grpcServer *grpc.Server
+
+// LaunchGRPC begins serving the gRPC services on addr
+func LaunchGRPC(addr string, daemon *daemon.Daemon) error {
+	logger.Info("Starting gRPC interface on %s", addr)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	s := grpc.NewServer()
+	grpcapi.RegisterNodeServer(s, &grpcNodeServer{daemon.Gateway})
+	grpcapi.RegisterWalletServer(s, &grpcWalletServer{daemon.Gateway})
+	grpcServer = s
+
+	go func() {
+		if err := s.Serve(l); err != nil {
+			logger.Error("gRPC interface stopped: %v", err)
+		}
+	}()
+	return nil
+}
+
+// ShutdownGRPC stops the gRPC services, the streams are closed
+func ShutdownGRPC() {
+	if grpcServer != nil {
+		grpcServer.Stop()
+		grpcServer = nil
+	}
+}
+
+// grpcNodeServer implements grpcapi.NodeServer
+type grpcNodeServer struct {
+	gateway *daemon.Gateway
+}
+
+func (s *grpcNodeServer) GetStatus(ctx context.Context, req *grpcapi.GetStatusRequest) (*grpcapi.Status, error) {
+	blocks := s.gateway.GetLastBlocks(1)
+	if blocks == nil || len(blocks.Blocks) == 0 {
+		return nil, status.Error(codes.Unavailable, "no blocks")
+	}
+
+	head := blocks.Blocks[0].Head
+	res := &grpcapi.Status{
+		HeadSeq:         head.BkSeq,
+		HeadHash:        head.BlockHash,
+		HeadTime:        head.Time,
+		UnconfirmedTxns: uint64(len(s.gateway.GetAllUnconfirmedTxns())),
+	}
+	if now := s.gateway.GetTimeNow(); now > head.Time {
+		res.TimeSinceLastBlock = now - head.Time
+	}
+	return res, nil
+}
+
+func (s *grpcNodeServer) GetBlock(ctx context.Context, req *grpcapi.GetBlockRequest) (*grpcapi.Block, error) {
+	var b coin.Block
+	var ok bool
+	switch by := req.By.(type) {
+	case *grpcapi.GetBlockRequest_Hash:
+		h, err := cipher.SHA256FromHex(by.Hash)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid block hash: %v", err)
+		}
+		b, ok = s.gateway.GetBlockByHash(h)
+	case *grpcapi.GetBlockRequest_Seq:
+		b, ok = s.gateway.GetBlockBySeq(by.Seq)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "block hash or seq is not set")
+	}
+
+	if !ok {
+		return nil, status.Error(codes.NotFound, "block doesn't exist")
+	}
+	return grpcBlock(b), nil
+}
+
+func (s *grpcNodeServer) GetLastBlocks(ctx context.Context, req *grpcapi.GetLastBlocksRequest) (*grpcapi.Blocks, error) {
+	rbs := s.gateway.GetLastBlocks(req.Num)
+	res := &grpcapi.Blocks{}
+	if rbs == nil {
+		return res, nil
+	}
+
+	for _, rb := range rbs.Blocks {
+		b, ok := s.gateway.GetBlockBySeq(rb.Head.BkSeq)
+		if !ok {
+			return nil, status.Errorf(codes.Internal, "block %d doesn't exist", rb.Head.BkSeq)
+		}
+		res.Blocks = append(res.Blocks, grpcBlock(b))
+	}
+	return res, nil
+}
+
+func (s *grpcNodeServer) GetTransaction(ctx context.Context, req *grpcapi.GetTransactionRequest) (*grpcapi.Transaction, error) {
+	txid, err := cipher.SHA256FromHex(req.Txid)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid txid: %v", err)
+	}
+
+	tx, err := s.gateway.GetTransaction(txid)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	if tx == nil {
+		return nil, status.Error(codes.NotFound, "transaction doesn't exist")
+	}
+	return grpcTransaction(tx.Txn, tx.Status), nil
+}
+
+func (s *grpcNodeServer) InjectTransaction(ctx context.Context, req *grpcapi.InjectTransactionRequest) (*grpcapi.InjectTransactionResponse, error) {
+	b, err := hex.DecodeString(req.Raw)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid raw transaction: %v", err)
+	}
+
+	txn, err := grpcDeserializeTxn(b)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid raw transaction: %v", err)
+	}
+
+	txn, err = s.gateway.InjectTransaction(txn)
+	if err != nil {
+		return nil, status.Errorf(codes.FailedPrecondition, "inject transaction failed: %v", err)
+	}
+	return &grpcapi.InjectTransactionResponse{Txid: txn.Hash().Hex()}, nil
+}
+
+func (s *grpcNodeServer) GetBalance(ctx context.Context, req *grpcapi.GetBalanceRequest) (*grpcapi.BalancePair, error) {
+	if len(req.Addresses) == 0 {
+		return nil, status.Error(codes.InvalidArgument, "addresses is empty")
+	}
+
+	addrs := make([]cipher.Address, len(req.Addresses))
+	for i, a := range req.Addresses {
+		addr, err := cipher.DecodeBase58Address(a)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "address %s is invalid: %v", a, err)
+		}
+		addrs[i] = addr
+	}
+
+	bal, err := s.gateway.AddressesBalance(addrs)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return grpcBalancePair(bal), nil
+}
+
+func (s *grpcNodeServer) StreamBlocks(req *grpcapi.StreamBlocksRequest, stream grpcapi.Node_StreamBlocksServer) error {
+	events, unsub := s.gateway.SubscribeEvents(grpcEventsBufSize, visor.EventNewBlock)
+	defer unsub()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "event bus closed")
+			}
+			if err := stream.Send(grpcBlock(*ev.Block)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (s *grpcNodeServer) StreamMempool(req *grpcapi.StreamMempoolRequest, stream grpcapi.Node_StreamMempoolServer) error {
+	events, unsub := s.gateway.SubscribeEvents(grpcEventsBufSize,
+		visor.EventNewUnconfirmedTxn,
+		visor.EventTxnConfirmed,
+		visor.EventTxnDropped)
+	defer unsub()
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				return status.Error(codes.Unavailable, "event bus closed")
+			}
+
+			me := &grpcapi.MempoolEvent{Txid: ev.TxID.Hex()}
+			switch ev.Type {
+			case visor.EventNewUnconfirmedTxn:
+				tx, err := s.gateway.GetTransaction(ev.TxID)
+				if err != nil || tx == nil {
+					continue
+				}
+				me.Type = grpcapi.MempoolEvent_NEW
+				me.Transaction = grpcTransaction(tx.Txn, tx.Status)
+			case visor.EventTxnConfirmed:
+				me.Type = grpcapi.MempoolEvent_CONFIRMED
+				me.BlockSeq = ev.Block.Seq()
+			case visor.EventTxnDropped:
+				me.Type = grpcapi.MempoolEvent_DROPPED
+				me.Reason = ev.Reason
+			}
+
+			if err := stream.Send(me); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// grpcWalletServer implements grpcapi.WalletServer on the loaded wallets
+type grpcWalletServer struct {
+	gateway *daemon.Gateway
+}
+
+func (s *grpcWalletServer) ListWallets(ctx context.Context, req *grpcapi.ListWalletsRequest) (*grpcapi.WalletList, error) {
+	wlts, err := Wg.ListWallets()
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	res := &grpcapi.WalletList{}
+	for _, w := range wlts {
+		res.Wallets = append(res.Wallets, &grpcapi.WalletInfo{
+			Id:        w.ID,
+			Loaded:    w.Loaded,
+			Label:     w.Label,
+			Type:      w.Type,
+			Encrypted: w.Encrypted,
+			Addresses: uint32(w.Addresses),
+		})
+	}
+	return res, nil
+}
+
+func (s *grpcWalletServer) GetWalletBalance(ctx context.Context, req *grpcapi.WalletRequest) (*grpcapi.BalancePair, error) {
+	if Wg.GetWallet(req.Id) == nil {
+		return nil, status.Errorf(codes.NotFound, "wallet id %s does not exist", req.Id)
+	}
+
+	bal, err := Wg.GetWalletBalance(s.gateway, req.Id)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return grpcBalancePair(bal), nil
+}
+
+func (s *grpcWalletServer) NewAddresses(ctx context.Context, req *grpcapi.NewAddressesRequest) (*grpcapi.Addresses, error) {
+	if Wg.GetWallet(req.Id) == nil {
+		return nil, status.Errorf(codes.NotFound, "wallet id %s does not exist", req.Id)
+	}
+
+	num := req.Num
+	if num == 0 {
+		num = 1
+	}
+
+	addrs, err := Wg.NewAddresses(req.Id, int(num), []byte(req.Password))
+	if err != nil {
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	}
+
+	res := &grpcapi.Addresses{}
+	for _, a := range addrs {
+		res.Addresses = append(res.Addresses, a.String())
+	}
+	return res, nil
+}
+
+func (s *grpcWalletServer) Spend(ctx context.Context, req *grpcapi.SpendRequest) (*grpcapi.SpendResponse, error) {
+	if Wg.GetWallet(req.Id) == nil {
+		return nil, status.Errorf(codes.NotFound, "wallet id %s does not exist", req.Id)
+	}
+
+	dst, err := cipher.DecodeBase58Address(req.Destination)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid destination address: %v", err)
+	}
+
+	var change cipher.Address
+	if req.Change != "" {
+		change, err = cipher.DecodeBase58Address(req.Change)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid change address: %v", err)
+		}
+	}
+
+	sel, err := visor.GetCoinSelector(req.Selection)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	ret := Spend(s.gateway, Wg, req.Id, []byte(req.Password), wallet.NewBalance(req.Coins, 0), 0, dst, change, sel)
+	if ret.PolicyError != nil {
+		return nil, status.Error(codes.PermissionDenied, ret.Error)
+	}
+	if ret.Error != "" {
+		return nil, status.Error(codes.FailedPrecondition, ret.Error)
+	}
+
+	txid, err := cipher.SHA256FromHex(ret.Transaction.Hash)
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	tx, err := s.gateway.GetTransaction(txid)
+	if err != nil || tx == nil {
+		return nil, status.Errorf(codes.Internal, "get sent transaction %s failed: %v", ret.Transaction.Hash, err)
+	}
+
+	return &grpcapi.SpendResponse{
+		Transaction: grpcTransaction(tx.Txn, tx.Status),
+		Balance:     grpcBalancePair(ret.Balance),
+	}, nil
+}
+
+func grpcBlock(b coin.Block) *grpcapi.Block {
+	res := &grpcapi.Block{
+		Seq:          b.Seq(),
+		Hash:         b.HashHeader().Hex(),
+		PreviousHash: b.Head.PrevHash.Hex(),
+		BodyHash:     b.Head.BodyHash.Hex(),
+		Time:         b.Head.Time,
+		Fee:          b.Head.Fee,
+		Version:      b.Head.Version,
+	}
+
+	st := visor.TransactionStatus{Confirmed: true, BlockSeq: b.Seq()}
+	for _, txn := range b.Body.Transactions {
+		res.Transactions = append(res.Transactions, grpcTransaction(txn, st))
+	}
+	return res
+}
+
+func grpcTransaction(txn coin.Transaction, st visor.TransactionStatus) *grpcapi.Transaction {
+	txid := txn.Hash()
+	res := &grpcapi.Transaction{
+		Txid:      txid.Hex(),
+		InnerHash: txn.InnerHash.Hex(),
+		Length:    txn.Length,
+		Type:      uint32(txn.Type),
+		Status: &grpcapi.TransactionStatus{
+			Confirmed:   st.Confirmed,
+			Unconfirmed: st.Unconfirmed,
+			Height:      st.Height,
+			BlockSeq:    st.BlockSeq,
+		},
+		Raw: hex.EncodeToString(txn.Serialize()),
+	}
+
+	for _, in := range txn.In {
+		res.Inputs = append(res.Inputs, in.Hex())
+	}
+	for _, o := range txn.Out {
+		res.Outputs = append(res.Outputs, &grpcapi.TransactionOutput{
+			Uxid:    o.UxID(txid).Hex(),
+			Address: o.Address.String(),
+			Coins:   o.Coins,
+			Hours:   o.Hours,
+		})
+	}
+	for _, sig := range txn.Sigs {
+		res.Sigs = append(res.Sigs, sig.Hex())
+	}
+	return res
+}
+
+func grpcBalancePair(b wallet.BalancePair) *grpcapi.BalancePair {
+	return &grpcapi.BalancePair{
+		Confirmed: &grpcapi.Balance{Coins: b.Confirmed.Coins, Hours: b.Confirmed.Hours},
+		Predicted: &grpcapi.Balance{Coins: b.Predicted.Coins, Hours: b.Predicted.Hours},
+	}
+}
+
+// grpcDeserializeTxn decodes the raw transaction, coin.TransactionDeserialize panics on the
+// invalid bytes
+func grpcDeserializeTxn(b []byte) (txn coin.Transaction, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	txn = coin.TransactionDeserialize(b)
+	return
+}
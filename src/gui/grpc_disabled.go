@@ -0,0 +1,21 @@
+//go:build !grpc
+// +build !grpc
+
+package gui
+
+import (
+	"errors"
+
+	"github.com/skycoin/skycoin/src/daemon"
+)
+
+// ErrGRPCDisabled is returned if the node is built without the grpc tag
+var ErrGRPCDisabled = errors.New("grpc interface is not built in, build with -tags grpc")
+
+// LaunchGRPC returns ErrGRPCDisabled, the gRPC services are built with the grpc tag
+func LaunchGRPC(addr string, daemon *daemon.Daemon) error {
+	return ErrGRPCDisabled
+}
+
+// ShutdownGRPC does nothing without the grpc tag
+func ShutdownGRPC() {}
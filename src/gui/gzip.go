@@ -0,0 +1,194 @@
+package gui
+
+import (
+	"bufio"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// streamFlushSize is how many bytes of the streamed responses are buffered before flushed as a
+// chunk
+const streamFlushSize = 64 * 1024
+
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} {
+		return gzip.NewWriter(nil)
+	},
+}
+
+// GzipEnabled enables the gzip compression of the responses of the web interface launched after
+var GzipEnabled = true
+
+// gzipResponseWriter compresses the response if it's not encoded by the handler
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	gz          *gzip.Writer
+	wroteHeader bool
+	hijacked    bool
+}
+
+func (w *gzipResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+
+	h := w.Header()
+	if h.Get("Content-Encoding") == "" && code != http.StatusNoContent &&
+		code != http.StatusNotModified && code != http.StatusPartialContent {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		w.gz = gzipWriterPool.Get().(*gzip.Writer)
+		w.gz.Reset(w.ResponseWriter)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		if w.Header().Get("Content-Type") == "" {
+			// detects by the uncompressed bytes like the ResponseWriter
+			w.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+
+	if w.gz == nil {
+		return w.ResponseWriter.Write(b)
+	}
+	return w.gz.Write(b)
+}
+
+// Flush sends the compressed bytes written as a chunk
+func (w *gzipResponseWriter) Flush() {
+	if w.gz != nil {
+		w.gz.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection if nothing is written
+func (w *gzipResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok || w.wroteHeader {
+		return nil, nil, errors.New("connection can't be hijacked")
+	}
+	w.hijacked = true
+	return hj.Hijack()
+}
+
+func (w *gzipResponseWriter) close() {
+	if w.hijacked {
+		return
+	}
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriterPool.Put(w.gz)
+		w.gz = nil
+	}
+}
+
+// gzipHandler compresses the responses of the requests accepting gzip
+func gzipHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		// the websocket upgrades and the byte ranges of the files are sent as they're
+		if !acceptsGzip(r) || r.Method == "HEAD" || r.Header.Get("Upgrade") != "" || r.Header.Get("Range") != "" {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		gw := &gzipResponseWriter{ResponseWriter: w}
+		defer gw.close()
+		handler.ServeHTTP(gw, r)
+	})
+}
+
+func acceptsGzip(r *http.Request) bool {
+	for _, enc := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		pts := strings.Split(enc, ";")
+		if strings.TrimSpace(pts[0]) != "gzip" {
+			continue
+		}
+
+		// gzip;q=0 refuses gzip
+		for _, p := range pts[1:] {
+			p = strings.TrimSpace(p)
+			if strings.HasPrefix(p, "q=") {
+				if q, err := strconv.ParseFloat(p[2:], 64); err == nil && q == 0 {
+					return false
+				}
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// flushWriter flushes the response as a chunk for every streamFlushSize bytes, the client gets
+// the large responses progressively
+type flushWriter struct {
+	w       io.Writer
+	flusher http.Flusher
+	n       int
+}
+
+func newFlushWriter(w http.ResponseWriter) io.Writer {
+	f, ok := w.(http.Flusher)
+	if !ok {
+		return w
+	}
+	return &flushWriter{w: w, flusher: f}
+}
+
+func (fw *flushWriter) Write(b []byte) (int, error) {
+	n, err := fw.w.Write(b)
+	fw.n += n
+	if fw.n >= streamFlushSize {
+		fw.flusher.Flush()
+		fw.n = 0
+	}
+	return n, err
+}
+
+// encodeJSONArray writes the n elements returned by elem as a json array, the output is the same
+// as the indented json of the http json helpers. The elements are encoded one by one.
+func encodeJSONArray(w io.Writer, n int, elem func(i int) interface{}) error {
+	if n == 0 {
+		_, err := io.WriteString(w, "[]")
+		return err
+	}
+
+	const indent = "    "
+	for i := 0; i < n; i++ {
+		sep := ",\n" + indent
+		if i == 0 {
+			sep = "[\n" + indent
+		}
+
+		d, err := json.MarshalIndent(elem(i), indent, indent)
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.WriteString(w, sep); err != nil {
+			return err
+		}
+		if _, err := w.Write(d); err != nil {
+			return err
+		}
+	}
+
+	_, err := io.WriteString(w, "\n]")
+	return err
+}
@@ -0,0 +1,104 @@
+package gui
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGzipHandler(t *testing.T) {
+	body := strings.Repeat(`{"seq": 1}`, 1000)
+	h := gzipHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/encoded" {
+			w.Header().Set("Content-Encoding", "br")
+		}
+		fw := newFlushWriter(w)
+		for i := 0; i < 10; i++ {
+			fw.Write([]byte(body[i*1000 : (i+1)*1000]))
+		}
+	}))
+
+	tests := []struct {
+		name     string
+		url      string
+		encoding string
+		gzip     bool
+	}{
+		{"gzip", "/", "gzip, deflate", true},
+		{"gzip q", "/", "deflate, gzip;q=0.5", true},
+		{"gzip refused", "/", "gzip;q=0", false},
+		{"no gzip", "/", "", false},
+		{"encoded by handler", "/encoded", "gzip", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tc.url, nil)
+			if tc.encoding != "" {
+				r.Header.Set("Accept-Encoding", tc.encoding)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			if !tc.gzip {
+				assert.NotEqual(t, "gzip", w.Header().Get("Content-Encoding"))
+				assert.Equal(t, body, w.Body.String())
+				return
+			}
+
+			assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "text/plain; charset=utf-8", w.Header().Get("Content-Type"))
+			assert.True(t, w.Body.Len() < len(body))
+
+			gr, err := gzip.NewReader(w.Body)
+			require.NoError(t, err)
+			d, err := ioutil.ReadAll(gr)
+			require.NoError(t, err)
+			assert.Equal(t, body, string(d))
+		})
+	}
+}
+
+func TestFlushWriter(t *testing.T) {
+	w := httptest.NewRecorder()
+	fw := newFlushWriter(w)
+
+	fw.Write(make([]byte, streamFlushSize-1))
+	assert.False(t, w.Flushed)
+	fw.Write([]byte{0})
+	assert.True(t, w.Flushed)
+}
+
+func TestEncodeJSONArray(t *testing.T) {
+	type entry struct {
+		TxID  string   `json:"txid"`
+		Addrs []string `json:"addresses"`
+	}
+	entries := []entry{
+		{"a", []string{"x", "y"}},
+		{"b", nil},
+		{"<c>", []string{}},
+	}
+
+	for n := 0; n <= len(entries); n++ {
+		var b bytes.Buffer
+		require.NoError(t, encodeJSONArray(&b, n, func(i int) interface{} {
+			return entries[i]
+		}))
+
+		// the same as the json helpers
+		exp, err := json.MarshalIndent(entries[:n], "", "    ")
+		require.NoError(t, err)
+		require.Equal(t, string(exp), b.String())
+	}
+}
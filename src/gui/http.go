@@ -1,6 +1,7 @@
 package gui
 
 import (
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
@@ -19,6 +20,7 @@ import (
 var (
 	logger   = logging.MustGetLogger("gui")
 	listener net.Listener
+	server   *http.Server
 	quit     chan struct{}
 )
 
@@ -46,17 +48,17 @@ func LaunchWebInterface(host, staticDir string, daemon *daemon.Daemon) error {
 	}
 
 	// Runs http.Serve() in a goroutine
-	serve(listener, NewGUIMux(appLoc, daemon), quit)
+	serve(listener, webHandler(NewGUIMux(appLoc, daemon)), quit)
 	return nil
 }
 
 // LaunchWebInterfaceHTTPS begins listening on https://$host, for enabling remote web access
 // Uses HTTPS
-func LaunchWebInterfaceHTTPS(host, staticDir string, daemon *daemon.Daemon, certFile, keyFile string) error {
+func LaunchWebInterfaceHTTPS(host, staticDir string, daemon *daemon.Daemon, c TLSConfig) error {
 	quit = make(chan struct{})
 	logger.Info("Starting web interface on https://%s", host)
-	logger.Info("Using %s for the certificate", certFile)
-	logger.Info("Using %s for the key", keyFile)
+	logger.Info("Using %s for the certificate", c.CertFile)
+	logger.Info("Using %s for the key", c.KeyFile)
 	logger.Info("Web resources directory: %s", staticDir)
 
 	appLoc, err := file.DetermineResourcePath(staticDir, devDir, resourceDir)
@@ -64,25 +66,35 @@ func LaunchWebInterfaceHTTPS(host, staticDir string, daemon *daemon.Daemon, cert
 		return err
 	}
 
-	certs := make([]tls.Certificate, 1)
-	if certs[0], err = tls.LoadX509KeyPair(certFile, keyFile); err != nil {
+	tc, err := newTLSConfig(c)
+	if err != nil {
 		return err
 	}
 
-	listener, err = tls.Listen("tcp", host, &tls.Config{Certificates: certs})
+	listener, err = tls.Listen("tcp", host, tc)
 	if err != nil {
 		return err
 	}
 
 	// Runs http.Serve() in a goroutine
-	serve(listener, NewGUIMux(appLoc, daemon), quit)
+	serve(listener, hstsHandler(c.HSTSMaxAge, webHandler(NewGUIMux(appLoc, daemon))), quit)
 	return nil
 }
 
-func serve(listener net.Listener, mux *http.ServeMux, q chan struct{}) {
+// webHandler wraps the mux with the cors policy, the gzip compression, the rejection of the
+// writes when the node shuts down and the maintenance mode
+func webHandler(mux http.Handler) http.Handler {
+	if GzipEnabled {
+		mux = gzipHandler(mux)
+	}
+	return corsHandler(CORS, shutdownHandler(maintenanceHandler(mux)))
+}
+
+func serve(listener net.Listener, mux http.Handler, q chan struct{}) {
+	server = &http.Server{Handler: mux}
 	go func() {
 		for {
-			if err := http.Serve(listener, mux); err != nil {
+			if err := server.Serve(listener); err != nil {
 				select {
 				case <-q:
 					return
@@ -94,13 +106,21 @@ func serve(listener net.Listener, mux *http.ServeMux, q chan struct{}) {
 	}()
 }
 
-// Shutdown close http service
+// Shutdown stops the writes, closes the listener and waits for the requests in progress till
+// ShutdownTimeout, the event streams are closed
 func Shutdown() {
+	StopWrites()
 	if quit != nil {
 		// must close quit first
 		close(quit)
-		listener.Close()
+
+		ctx, cancel := context.WithTimeout(context.Background(), ShutdownTimeout)
+		defer cancel()
+		if err := server.Shutdown(ctx); err != nil {
+			logger.Error("Web interface shutdown: %v", err)
+		}
 		listener = nil
+		logger.Info("Web interface closed")
 	}
 }
 
@@ -118,20 +138,55 @@ func NewGUIMux(appLoc string, daemon *daemon.Daemon) *http.ServeMux {
 		mux.Handle(route, http.FileServer(http.Dir(appLoc)))
 	}
 
+	// The api is served under /api/v1, the legacy paths are the deprecated aliases
+	api := NewAPIMux(mux, Auth, Limiter)
+
 	// Wallet interface
-	RegisterWalletHandlers(mux, daemon.Gateway)
+	RegisterWalletHandlers(api, daemon.Gateway)
 	// Blockchain interface
-	RegisterBlockchainHandlers(mux, daemon.Gateway)
+	RegisterBlockchainHandlers(api, daemon.Gateway)
 	// Network stats interface
-	RegisterNetworkHandlers(mux, daemon.Gateway)
+	RegisterNetworkHandlers(api, daemon.Gateway)
 	// Network API handler
-	RegisterAPIHandlers(mux, daemon.Gateway)
+	RegisterAPIHandlers(api, daemon.Gateway)
 	// Transaction handler
-	RegisterTxHandlers(mux, daemon.Gateway)
+	RegisterTxHandlers(api, daemon.Gateway)
 	// UxOUt api handler
-	RegisterUxOutHandlers(mux, daemon.Gateway)
+	RegisterUxOutHandlers(api, daemon.Gateway)
+	// address transactions and outputs handler
+	RegisterAddressHandlers(api, daemon.Gateway)
 	// expplorer handler
-	RegisterExplorerHandlers(mux, daemon.Gateway)
+	RegisterExplorerHandlers(api, daemon.Gateway)
+	// backup handler
+	RegisterBackupHandlers(api, daemon.Gateway)
+	// websocket subscriptions handler
+	RegisterWebsocketHandlers(api, daemon.Gateway)
+	// webhook handler
+	RegisterWebhookHandlers(api, daemon.Gateway)
+	// log levels handler
+	RegisterLoggingHandlers(api)
+	// config reload handler
+	RegisterConfigHandlers(api)
+	// node management handler
+	RegisterAdminHandlers(api, daemon.Gateway)
+	// pprof and goroutine dump handler
+	if PprofEnabled {
+		RegisterDebugHandlers(api)
+	}
+	// api routes listing handler
+	RegisterRoutesHandlers(api)
+	// batch requests handler
+	RegisterBatchHandlers(api)
+	// GraphQL explorer queries handler
+	if GraphQLEnabled {
+		RegisterGraphQLHandlers(api, daemon.Gateway)
+	}
+	// Prometheus metrics handler
+	if MetricsEnabled {
+		RegisterMetricsHandlers(api)
+	}
+	// csrf token handler
+	RegisterAuthHandlers(api)
 	return mux
 }
 
@@ -0,0 +1,278 @@
+package gui
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+const (
+	// IdempotencyKeyHeader is the header of the client request id of the injections and spends
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader is set on the responses replayed for the duplicate requests
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	// DefaultIdempotencyWindow is how long the results are kept
+	DefaultIdempotencyWindow = 24 * time.Hour
+
+	// the max length of the idempotency key
+	maxIdempotencyKeyLen = 255
+	// the max size of the request body of the idempotent routes
+	maxIdempotentBodySize = 4 * 1024 * 1024
+	// the results larger than it aren't kept, the duplicate requests are executed again
+	maxIdempotentResultSize = 1024 * 1024
+	// the max number of the results kept, the pending ones included
+	maxIdempotentResults = 10000
+	// the max total size of the results kept, the pending results reserve the max result size
+	maxIdempotentCacheSize = 64 * 1024 * 1024
+)
+
+// ErrIdempotencyCacheFull is returned if the results can't be kept till the old ones expire
+var ErrIdempotencyCacheFull = errors.New("too many idempotent requests, retry later")
+
+// Idem global results of the idempotent requests
+var Idem = NewIdempotencyCache(DefaultIdempotencyWindow)
+
+// InitIdempotency init the results of the idempotent requests with the window
+func InitIdempotency(window time.Duration) {
+	Idem = NewIdempotencyCache(window)
+}
+
+type idempotentResult struct {
+	// the hash of the method, the path and the body of the request, the key can't be reused for
+	// the other requests
+	fingerprint [32]byte
+	// closed when the result is set
+	done    chan struct{}
+	code    int
+	header  http.Header
+	body    []byte
+	expire  time.Time
+	discard bool
+}
+
+// IdempotencyCache keeps the results of the requests with the idempotency key in the window, the
+// duplicate requests get the original results
+type IdempotencyCache struct {
+	window time.Duration
+	// the limits of the number and the total size of the results
+	maxResults int
+	maxSize    int
+
+	sync.Mutex
+	results map[string]*idempotentResult
+	// the total size of the results, the pending results count as maxIdempotentResultSize
+	size      int
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewIdempotencyCache creates an IdempotencyCache
+func NewIdempotencyCache(window time.Duration) *IdempotencyCache {
+	return &IdempotencyCache{
+		window:     window,
+		maxResults: maxIdempotentResults,
+		maxSize:    maxIdempotentCacheSize,
+		results:    make(map[string]*idempotentResult),
+		now:        time.Now,
+	}
+}
+
+// begin returns the result of the key, the result is new if created. ErrIdempotencyCacheFull is
+// returned if a new result is over the limits.
+func (c *IdempotencyCache) begin(key string, fingerprint [32]byte) (*idempotentResult, bool, error) {
+	c.Lock()
+	defer c.Unlock()
+
+	now := c.now()
+	c.prune(now)
+
+	res, ok := c.results[key]
+	if ok && (res.expire.IsZero() || now.Before(res.expire)) {
+		return res, false, nil
+	}
+	if ok {
+		c.remove(key, res)
+	}
+
+	if len(c.results) >= c.maxResults || c.size+maxIdempotentResultSize > c.maxSize {
+		return nil, false, ErrIdempotencyCacheFull
+	}
+
+	res = &idempotentResult{
+		fingerprint: fingerprint,
+		done:        make(chan struct{}),
+	}
+	c.results[key] = res
+	c.size += maxIdempotentResultSize
+	return res, true, nil
+}
+
+// finish sets the result, the results of the server errors and the large results are removed,
+// the requests can be retried
+func (c *IdempotencyCache) finish(key string, res *idempotentResult) {
+	c.Lock()
+	defer c.Unlock()
+
+	// the reserved size is replaced by the size of the body
+	c.size -= maxIdempotentResultSize
+	if res.discard || res.code >= 500 {
+		delete(c.results, key)
+	} else {
+		res.expire = c.now().Add(c.window)
+		c.size += len(res.body)
+	}
+	close(res.done)
+}
+
+// remove removes the finished result of the key
+func (c *IdempotencyCache) remove(key string, res *idempotentResult) {
+	delete(c.results, key)
+	c.size -= len(res.body)
+}
+
+func (c *IdempotencyCache) prune(now time.Time) {
+	if now.Sub(c.lastPrune) < time.Minute {
+		return
+	}
+	c.lastPrune = now
+
+	for k, res := range c.results {
+		if !res.expire.IsZero() && !now.Before(res.expire) {
+			c.remove(k, res)
+		}
+	}
+}
+
+// idempotentWriter writes the response and keeps a copy of it
+type idempotentWriter struct {
+	http.ResponseWriter
+	res *idempotentResult
+	buf bytes.Buffer
+}
+
+func (w *idempotentWriter) WriteHeader(code int) {
+	if w.res.code != 0 {
+		return
+	}
+	w.res.code = code
+	w.res.header = make(http.Header, len(w.Header()))
+	for k, v := range w.Header() {
+		w.res.header[k] = append([]string(nil), v...)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *idempotentWriter) Write(b []byte) (int, error) {
+	if w.res.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.res.discard {
+		if w.buf.Len()+len(b) > maxIdempotentResultSize {
+			w.res.discard = true
+			w.buf.Reset()
+		} else {
+			w.buf.Write(b)
+		}
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// idempotent makes the handler idempotent by the Idempotency-Key header of the request, the
+// requests without the header are handled as they're.
+//
+// The duplicate requests in the window get the status, the headers and the body of the original
+// response, with the Idempotent-Replayed header. The key used by a different request gets 422,
+// the duplicate requests sent before the original one is done get 409. The responses of the server
+// errors aren't kept, the requests can be retried with the same key. The keys are scoped by the
+// valid api key of the request. The requests get 503 if the results are over the limits of the
+// cache.
+func idempotent(handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			handler(w, r)
+			return
+		}
+
+		if len(key) > maxIdempotencyKeyLen {
+			wh.Error400(w, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxIdempotentBodySize+1))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+		if len(body) > maxIdempotentBodySize {
+			wh.Error400(w, "request body is too large")
+			return
+		}
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		h := sha256.New()
+		io.WriteString(h, r.Method+" "+r.URL.Path+"?"+r.URL.RawQuery+"\n")
+		h.Write(body)
+		var fp [32]byte
+		copy(fp[:], h.Sum(nil))
+
+		cache := Idem
+		// the keys of the routes and the valid api keys don't conflict, the requests with any other
+		// api key share the keys
+		ck := r.URL.Path + "|" + Auth.keyClient(r) + "|" + key
+		res, created, err := cache.begin(ck, fp)
+		if err != nil {
+			w.Header().Set("Retry-After", "60")
+			wh.Error503(w, err.Error())
+			return
+		}
+		if !created {
+			if res.fingerprint != fp {
+				wh.HTTPError(w, http.StatusUnprocessableEntity, "Unprocessable entity",
+					[]string{"Idempotency-Key is used by a different request"})
+				return
+			}
+
+			select {
+			case <-res.done:
+			default:
+				wh.HTTPError(w, http.StatusConflict, "Conflict",
+					[]string{"the request of the Idempotency-Key is in progress"})
+				return
+			}
+
+			for k, v := range res.header {
+				w.Header()[k] = v
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(res.code)
+			w.Write(res.body)
+			return
+		}
+
+		iw := &idempotentWriter{ResponseWriter: w, res: res}
+		defer func() {
+			if p := recover(); p != nil {
+				res.discard = true
+				cache.finish(ck, res)
+				panic(p)
+			}
+
+			if res.code == 0 {
+				res.code = http.StatusOK
+				res.header = http.Header{}
+			}
+			res.body = iw.buf.Bytes()
+			cache.finish(ck, res)
+		}()
+		handler(iw, r)
+	}
+}
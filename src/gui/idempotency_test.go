@@ -0,0 +1,187 @@
+package gui
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotent(t *testing.T) {
+	defer func(c *IdempotencyCache) { Idem = c }(Idem)
+	InitIdempotency(time.Hour)
+	defer func(a *APIAuth) { Auth = a }(Auth)
+	ac := NewAPIAuthConfig()
+	ac.APIKey = "other"
+	Auth = NewAPIAuth(ac)
+	now := time.Unix(1500000000, 0)
+	Idem.now = func() time.Time { return now }
+
+	var calls int
+	fail := false
+	release := make(chan struct{})
+	h := idempotent(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.FormValue("block") != "" {
+			<-release
+		}
+		if fail {
+			http.Error(w, "internal error", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"txid": "%s", "call": %d}`, r.FormValue("dst"), calls)
+	})
+
+	do := func(key string, form url.Values) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/wallet/spend", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if key != "" {
+			r.Header.Set(IdempotencyKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+
+	form := url.Values{"dst": {"a"}}
+
+	// the original request and the duplicate
+	w := do("k1", form)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `{"txid": "a", "call": 1}`, w.Body.String())
+	require.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+
+	w = do("k1", form)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, `{"txid": "a", "call": 1}`, w.Body.String())
+	require.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	require.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	require.Equal(t, 1, calls)
+
+	// the key of a different request
+	w = do("k1", url.Values{"dst": {"b"}})
+	require.Equal(t, http.StatusUnprocessableEntity, w.Code)
+
+	// the requests without the key and of the other keys
+	do("", form)
+	do("", form)
+	do("k2", form)
+	require.Equal(t, 4, calls)
+
+	// the key of the other api key
+	doKey := func(apiKey string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/wallet/spend", strings.NewReader(form.Encode()))
+		r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		r.Header.Set(IdempotencyKeyHeader, "k1")
+		r.Header.Set(APIKeyHeader, apiKey)
+		w := httptest.NewRecorder()
+		h(w, r)
+		return w
+	}
+	doKey("other")
+	require.Equal(t, 5, calls)
+
+	// the invalid api key doesn't scope the key
+	w = doKey("guess")
+	require.Equal(t, "true", w.Header().Get(IdempotentReplayedHeader))
+	require.Equal(t, 5, calls)
+
+	// the server errors aren't kept
+	fail = true
+	w = do("k3", form)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	fail = false
+	w = do("k3", form)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Equal(t, 7, calls)
+
+	// the duplicate in progress
+	done := make(chan struct{})
+	blocking := url.Values{"dst": {"c"}, "block": {"1"}}
+	go func() {
+		defer close(done)
+		assert.Equal(t, http.StatusOK, do("k4", blocking).Code)
+	}()
+	for started := false; !started; time.Sleep(time.Millisecond) {
+		Idem.Lock()
+		_, started = Idem.results["/wallet/spend||k4"]
+		Idem.Unlock()
+	}
+	require.Equal(t, http.StatusConflict, do("k4", blocking).Code)
+	close(release)
+	<-done
+
+	// expired
+	now = now.Add(time.Hour)
+	w = do("k1", form)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.Empty(t, w.Header().Get(IdempotentReplayedHeader))
+
+	// too long key
+	w = do(strings.Repeat("k", maxIdempotencyKeyLen+1), form)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestIdempotencyCacheLimits(t *testing.T) {
+	c := NewIdempotencyCache(time.Hour)
+	c.maxResults = 2
+	now := time.Unix(1500000000, 0)
+	c.now = func() time.Time { return now }
+
+	var fp [32]byte
+	r1, created, err := c.begin("k1", fp)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Equal(t, maxIdempotentResultSize, c.size)
+
+	_, _, err = c.begin("k2", fp)
+	require.NoError(t, err)
+	_, _, err = c.begin("k3", fp)
+	require.Equal(t, ErrIdempotencyCacheFull, err)
+
+	// the kept result counts by its size
+	r1.code = http.StatusOK
+	r1.body = []byte("result")
+	c.finish("k1", r1)
+	require.Equal(t, maxIdempotentResultSize+len("result"), c.size)
+
+	// the existing results are returned when the cache is full
+	res, created, err := c.begin("k1", fp)
+	require.NoError(t, err)
+	require.False(t, created)
+	require.Equal(t, r1, res)
+
+	// the expired results are removed
+	now = now.Add(time.Hour)
+	_, created, err = c.begin("k3", fp)
+	require.NoError(t, err)
+	require.True(t, created)
+	require.Len(t, c.results, 2)
+	require.Equal(t, 2*maxIdempotentResultSize, c.size)
+
+	// the total size
+	c = NewIdempotencyCache(time.Hour)
+	c.maxSize = maxIdempotentResultSize
+	_, _, err = c.begin("k1", fp)
+	require.NoError(t, err)
+	_, _, err = c.begin("k2", fp)
+	require.Equal(t, ErrIdempotencyCacheFull, err)
+
+	// the handler responds 503
+	defer func(c *IdempotencyCache) { Idem = c }(Idem)
+	Idem = c
+	h := idempotent(func(w http.ResponseWriter, r *http.Request) {})
+	r := httptest.NewRequest("POST", "/wallet/spend", nil)
+	r.Header.Set(IdempotencyKeyHeader, "k2")
+	w := httptest.NewRecorder()
+	h(w, r)
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "60", w.Header().Get("Retry-After"))
+}
@@ -0,0 +1,57 @@
+package gui
+
+import (
+	"net/http"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// RegisterLoggingHandlers registers the handlers of the log levels
+func RegisterLoggingHandlers(mux Mux) {
+	// Lists the log levels of the modules
+	mux.HandleFunc("/logging/levels", getLogLevels)
+	// Changes the log level of a module
+	mux.HandleFunc("/logging/level", setLogLevel)
+}
+
+// getLogLevels returns the log levels of the modules, the module of the default level is empty
+func getLogLevels(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		wh.Error405(w, "")
+		return
+	}
+
+	levels := logging.GetModuleLevels()
+	if levels == nil {
+		wh.Error503(w, "logger is not initialized")
+		return
+	}
+	wh.SendOr404(w, levels)
+}
+
+// setLogLevel changes the log level of a module at runtime, it's not kept after restarting.
+// method: POST
+// params: level, module (optional, the default level of the modules not set if empty)
+func setLogLevel(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		wh.Error405(w, "")
+		return
+	}
+
+	level := r.FormValue("level")
+	if level == "" {
+		wh.Error400(w, "level is empty")
+		return
+	}
+
+	module := r.FormValue("module")
+	if err := logging.SetModuleLevel(module, level); err != nil {
+		wh.Error400(w, err.Error())
+		return
+	}
+
+	logger.Notice("Log level of module %q changed to %s", module, level)
+	wh.SendOr404(w, logging.GetModuleLevels())
+}
@@ -0,0 +1,91 @@
+package gui
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/metrics"
+)
+
+// MetricsEnabled enables /metrics of the web interface launched after, the metrics of the node
+// are served in the Prometheus text format
+var MetricsEnabled = false
+
+var (
+	apiRequestsCounter = metrics.NewCounterVec("suncoin_api_requests_total",
+		"Number of the api requests", "route", "code")
+	apiRequestDuration = metrics.NewHistogramVec("suncoin_api_request_duration_seconds",
+		"Latency of the api requests", nil, "route")
+)
+
+// RegisterMetricsHandlers registers /metrics, it's authenticated and limited like the api routes
+func RegisterMetricsHandlers(m *APIMux) {
+	// the counters, the gauges and the histograms of the node
+	// 		GET /metrics
+	m.mux.Handle("/metrics", m.protect("/metrics", metrics.DefaultRegistry.Handler()))
+}
+
+// metricsHandler counts the requests of the route pattern by the status code, and observes the
+// latencies. The websocket and the server-sent events streams are counted but not observed.
+func metricsHandler(pattern string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		handler.ServeHTTP(sw, r)
+
+		code := sw.code
+		if code == 0 {
+			code = http.StatusOK
+		}
+		apiRequestsCounter.With(pattern, strconv.Itoa(code)).Inc()
+
+		if !sw.streamed {
+			apiRequestDuration.With(pattern).Observe(time.Since(start).Seconds())
+		}
+	})
+}
+
+// statusWriter records the status code of the response
+type statusWriter struct {
+	http.ResponseWriter
+	code int
+	// whether the connection is hijacked or the response is an event stream
+	streamed bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+		w.streamed = w.Header().Get("Content-Type") == "text/event-stream"
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the response if it's supported by the wrapped writer
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection for the websocket, the status is 101 Switching Protocols
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("hijacking is not supported")
+	}
+	w.code = http.StatusSwitchingProtocols
+	w.streamed = true
+	return hj.Hijack()
+}
@@ -0,0 +1,39 @@
+package gui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetricsHandler(t *testing.T) {
+	h := metricsHandler("/test_metrics", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+
+	for _, path := range []string{"/", "/", "/missing"} {
+		rr := httptest.NewRecorder()
+		h.ServeHTTP(rr, httptest.NewRequest("GET", path, nil))
+	}
+
+	require.Equal(t, float64(2), apiRequestsCounter.With("/test_metrics", "200").Value())
+	require.Equal(t, float64(1), apiRequestsCounter.With("/test_metrics", "404").Value())
+}
+
+func TestStatusWriterEventStream(t *testing.T) {
+	rr := httptest.NewRecorder()
+	sw := &statusWriter{ResponseWriter: rr}
+	sw.Header().Set("Content-Type", "text/event-stream")
+	sw.Write([]byte("retry: 3000\n\n"))
+	sw.Flush()
+
+	require.Equal(t, http.StatusOK, sw.code)
+	require.True(t, sw.streamed)
+	require.True(t, rr.Flushed)
+}
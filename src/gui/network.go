@@ -42,11 +42,99 @@ func exchgConnectionsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// method: GET
+// url: /network/proxy
+func proxyHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+		wh.SendOr404(w, gateway.GetProxyStatus())
+	}
+}
+
+// method: GET
+// url: /network/port-mapping
+func portMappingHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		status, err := gateway.GetPortMappingStatus()
+		if err != nil {
+			wh.Error404(w, err.Error())
+			return
+		}
+		wh.SendOr404(w, status)
+	}
+}
+
+// method: GET
+// url: /network/protocol
+func protocolHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		status, err := gateway.GetProtocolStatus()
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+		wh.SendOr404(w, status)
+	}
+}
+
+// method: GET
+// url: /network/encryption
+func encryptionHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		status, err := gateway.GetEncryptionStatus()
+		if err != nil {
+			wh.Error404(w, err.Error())
+			return
+		}
+		wh.SendOr404(w, status)
+	}
+}
+
+// method: GET
+// url: /network/sync
+func blockSyncHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+		wh.SendOr404(w, gateway.GetBlockSyncStatus())
+	}
+}
+
 // RegisterNetworkHandlers registers network handlers
-func RegisterNetworkHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterNetworkHandlers(mux Mux, gateway *daemon.Gateway) {
 	mux.HandleFunc("/network/connection", connectionHandler(gateway))
 	mux.HandleFunc("/network/connections", connectionsHandler(gateway))
 	mux.HandleFunc("/network/defaultConnections", defaultConnectionsHandler(gateway))
 	mux.HandleFunc("/network/connections/trust", trustConnectionsHandler(gateway))
 	mux.HandleFunc("/network/connections/exchange", exchgConnectionsHandler(gateway))
+	// Returns the proxy of the outgoing connections and the onion address
+	mux.HandleFunc("/network/proxy", proxyHandler(gateway))
+	// Returns the external address and port mapped on the router by UPnP or NAT-PMP
+	mux.HandleFunc("/network/port-mapping", portMappingHandler(gateway))
+	// Returns the protocol versions and features of the node and negotiated with the peers
+	mux.HandleFunc("/network/protocol", protocolHandler(gateway))
+	// Returns the public key of the encrypted transport and the encrypted connections
+	mux.HandleFunc("/network/encryption", encryptionHandler(gateway))
+	// Returns the block ranges requested from the peers and their download throughput
+	mux.HandleFunc("/network/sync", blockSyncHandler(gateway))
 }
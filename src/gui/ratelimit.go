@@ -0,0 +1,240 @@
+package gui
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// The classes of the api requests, they're limited separately
+const (
+	// RateClassRead is the GET and HEAD requests
+	RateClassRead = "read"
+	// RateClassWrite is the requests by the other methods
+	RateClassWrite = "write"
+	// RateClassHeavy is the requests of the heavy routes, e.g. the exports
+	RateClassHeavy = "heavy"
+)
+
+// DefaultHeavyRoutes are the routes scanning the blockchain
+var DefaultHeavyRoutes = []string{
+	"/explorer/",
+	"/blocks",
+	"/address/",
+	"/address_uxouts",
+	"/transaction_history",
+	"/graphql",
+}
+
+// Limiter global rate limiter of the api, the api isn't limited if nil
+var Limiter *RateLimiter
+
+// InitRateLimit init the rate limiter of the api, it's applied to the handlers registered after
+func InitRateLimit(c RateLimitConfig) {
+	Limiter = NewRateLimiter(c)
+}
+
+// RateLimit is the limit of a class of the requests of a client, the tokens of the bucket are
+// refilled at Rate per second up to Burst
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseRateLimit parses the limit of <requests per second>:<burst>, e.g. 10:20
+func ParseRateLimit(s string) (RateLimit, error) {
+	pts := strings.Split(s, ":")
+	if len(pts) != 2 {
+		return RateLimit{}, fmt.Errorf("invalid rate limit %s, must be <rate>:<burst>", s)
+	}
+
+	rate, err := strconv.ParseFloat(pts[0], 64)
+	if err != nil || rate <= 0 {
+		return RateLimit{}, fmt.Errorf("invalid rate of rate limit %s", s)
+	}
+
+	burst, err := strconv.Atoi(pts[1])
+	if err != nil || burst < 1 {
+		return RateLimit{}, fmt.Errorf("invalid burst of rate limit %s", s)
+	}
+
+	return RateLimit{Rate: rate, Burst: burst}, nil
+}
+
+// String returns the limit as <rate>:<burst>
+func (l RateLimit) String() string {
+	return fmt.Sprintf("%v:%d", l.Rate, l.Burst)
+}
+
+// RateLimitConfig configures the RateLimiter.
+//
+// The clients are the api keys of the requests with the valid api key, otherwise the ips.
+type RateLimitConfig struct {
+	// the limits of the request classes, the class isn't limited if it has no limit
+	Limits map[string]RateLimit
+	// the prefixes of the legacy paths of the heavy routes
+	HeavyRoutes []string
+	// the ip of the client is the first of the X-Forwarded-For header, for the node behind a
+	// reverse proxy
+	TrustForwardedFor bool
+}
+
+// NewRateLimitConfig returns a RateLimitConfig with defaults set
+func NewRateLimitConfig() RateLimitConfig {
+	return RateLimitConfig{
+		Limits: map[string]RateLimit{
+			RateClassRead:  {Rate: 20, Burst: 40},
+			RateClassWrite: {Rate: 2, Burst: 10},
+			RateClassHeavy: {Rate: 1, Burst: 5},
+		},
+		HeavyRoutes: DefaultHeavyRoutes,
+	}
+}
+
+// rateLimitIdle is how long the bucket of an idle client is kept
+const rateLimitIdle = 10 * time.Minute
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter limits the api requests of the clients by the token buckets
+type RateLimiter struct {
+	c RateLimitConfig
+
+	sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+// NewRateLimiter creates a RateLimiter
+func NewRateLimiter(c RateLimitConfig) *RateLimiter {
+	return &RateLimiter{
+		c:       c,
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+func (rl *RateLimiter) class(pattern string, r *http.Request) string {
+	for _, p := range rl.c.HeavyRoutes {
+		if strings.HasPrefix(pattern, p) {
+			return RateClassHeavy
+		}
+	}
+	if r.Method == "GET" || r.Method == "HEAD" {
+		return RateClassRead
+	}
+	return RateClassWrite
+}
+
+// client returns the client of the request, the valid api key of auth, otherwise the ip. The
+// requests with the invalid keys are limited by the ip, the keys can't be guessed quickly.
+func (rl *RateLimiter) client(r *http.Request, auth *APIAuth) string {
+	if c := auth.keyClient(r); c != "" {
+		return c
+	}
+
+	if rl.c.TrustForwardedFor {
+		if fwd := r.Header.Get("X-Forwarded-For"); fwd != "" {
+			return "ip:" + strings.TrimSpace(strings.Split(fwd, ",")[0])
+		}
+	}
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	return "ip:" + host
+}
+
+// Allow takes a token of the bucket of the class of the client, it returns how long to wait for
+// the next token if the bucket is empty
+func (rl *RateLimiter) Allow(class, client string) (bool, time.Duration) {
+	rl.Lock()
+	defer rl.Unlock()
+
+	limit, ok := rl.c.Limits[class]
+	if !ok {
+		return true, 0
+	}
+
+	now := rl.now()
+	rl.prune(now)
+
+	k := class + "|" + client
+	b, ok := rl.buckets[k]
+	if !ok {
+		b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		rl.buckets[k] = b
+	}
+
+	b.tokens = math.Min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+	b.last = now
+
+	if b.tokens < 1 {
+		wait := time.Duration((1 - b.tokens) / limit.Rate * float64(time.Second))
+		return false, wait
+	}
+
+	b.tokens--
+	return true, 0
+}
+
+// SetLimits replaces the limits of the request classes, the class isn't limited if it has no
+// limit. The tokens of the buckets are capped by the new bursts when the clients request next.
+func (rl *RateLimiter) SetLimits(limits map[string]RateLimit) {
+	rl.Lock()
+	defer rl.Unlock()
+	rl.c.Limits = limits
+}
+
+// Limits returns the limits of the request classes
+func (rl *RateLimiter) Limits() map[string]RateLimit {
+	rl.Lock()
+	defer rl.Unlock()
+	limits := make(map[string]RateLimit, len(rl.c.Limits))
+	for class, l := range rl.c.Limits {
+		limits[class] = l
+	}
+	return limits
+}
+
+// prune removes the buckets idle for rateLimitIdle, the clients get the full burst back
+func (rl *RateLimiter) prune(now time.Time) {
+	if now.Sub(rl.lastPrune) < rateLimitIdle {
+		return
+	}
+	rl.lastPrune = now
+
+	for k, b := range rl.buckets {
+		if now.Sub(b.last) >= rateLimitIdle {
+			delete(rl.buckets, k)
+		}
+	}
+}
+
+// Handler wraps the handler of the route pattern with the rate limit, the requests over the limit
+// are responded with 429 and the Retry-After header in seconds. The api keys are validated by
+// auth, the requests are limited by the ip if it's nil.
+func (rl *RateLimiter) Handler(pattern string, auth *APIAuth, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ok, wait := rl.Allow(rl.class(pattern, r), rl.client(r, auth))
+		if !ok {
+			retry := int(math.Ceil(wait.Seconds()))
+			w.Header().Set("Retry-After", strconv.Itoa(retry))
+			wh.Error429(w, fmt.Sprintf("Too many requests, retry after %d seconds", retry))
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
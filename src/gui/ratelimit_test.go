@@ -0,0 +1,144 @@
+package gui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRateLimit(t *testing.T) {
+	l, err := ParseRateLimit("0.5:3")
+	require.NoError(t, err)
+	require.Equal(t, RateLimit{Rate: 0.5, Burst: 3}, l)
+	require.Equal(t, "0.5:3", l.String())
+
+	for _, s := range []string{"", "1", "a:1", "1:a", "0:1", "1:0", "1:2:3"} {
+		_, err := ParseRateLimit(s)
+		require.Error(t, err, s)
+	}
+}
+
+func TestRateLimiterAllow(t *testing.T) {
+	c := NewRateLimitConfig()
+	c.Limits = map[string]RateLimit{RateClassWrite: {Rate: 2, Burst: 3}}
+	rl := NewRateLimiter(c)
+	now := time.Unix(1500000000, 0)
+	rl.now = func() time.Time { return now }
+
+	// the burst
+	for i := 0; i < 3; i++ {
+		ok, _ := rl.Allow(RateClassWrite, "ip:1.2.3.4")
+		require.True(t, ok)
+	}
+	ok, wait := rl.Allow(RateClassWrite, "ip:1.2.3.4")
+	require.False(t, ok)
+	require.Equal(t, 500*time.Millisecond, wait)
+
+	// the other clients and the classes without limit
+	ok, _ = rl.Allow(RateClassWrite, "ip:4.3.2.1")
+	require.True(t, ok)
+	ok, _ = rl.Allow(RateClassRead, "ip:1.2.3.4")
+	require.True(t, ok)
+
+	// refilled at the rate
+	now = now.Add(500 * time.Millisecond)
+	ok, _ = rl.Allow(RateClassWrite, "ip:1.2.3.4")
+	require.True(t, ok)
+	ok, _ = rl.Allow(RateClassWrite, "ip:1.2.3.4")
+	require.False(t, ok)
+
+	// the idle buckets are pruned
+	now = now.Add(rateLimitIdle)
+	ok, _ = rl.Allow(RateClassWrite, "ip:1.2.3.4")
+	require.True(t, ok)
+	require.Len(t, rl.buckets, 1)
+}
+
+func TestRateLimiterSetLimits(t *testing.T) {
+	c := NewRateLimitConfig()
+	c.Limits = map[string]RateLimit{RateClassWrite: {Rate: 1, Burst: 2}}
+	rl := NewRateLimiter(c)
+	now := time.Unix(1500000000, 0)
+	rl.now = func() time.Time { return now }
+
+	for i := 0; i < 2; i++ {
+		ok, _ := rl.Allow(RateClassWrite, "ip:1.2.3.4")
+		require.True(t, ok)
+	}
+
+	// the reads are limited, the writes aren't
+	rl.SetLimits(map[string]RateLimit{RateClassRead: {Rate: 1, Burst: 1}})
+	require.Equal(t, map[string]RateLimit{RateClassRead: {Rate: 1, Burst: 1}}, rl.Limits())
+	ok, _ := rl.Allow(RateClassWrite, "ip:1.2.3.4")
+	require.True(t, ok)
+	ok, _ = rl.Allow(RateClassRead, "ip:1.2.3.4")
+	require.True(t, ok)
+	ok, _ = rl.Allow(RateClassRead, "ip:1.2.3.4")
+	require.False(t, ok)
+}
+
+func TestRateLimiterHandler(t *testing.T) {
+	c := NewRateLimitConfig()
+	c.Limits = map[string]RateLimit{
+		RateClassRead:  {Rate: 1, Burst: 2},
+		RateClassWrite: {Rate: 1, Burst: 1},
+		RateClassHeavy: {Rate: 0.1, Burst: 1},
+	}
+
+	mux := http.NewServeMux()
+	api := NewAPIMux(mux, nil, NewRateLimiter(c))
+	api.HandleFunc("/blockchain/metadata", func(w http.ResponseWriter, r *http.Request) {})
+	api.HandleFunc("/explorer/richlist", func(w http.ResponseWriter, r *http.Request) {})
+
+	do := func(method, url, ip, key string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, url, nil)
+		r.RemoteAddr = ip + ":51000"
+		if key != "" {
+			r.Header.Set(APIKeyHeader, key)
+		}
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, r)
+		return w
+	}
+
+	// the legacy and the versioned paths share the bucket
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/blockchain/metadata", "1.2.3.4", "").Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/blockchain/metadata", "1.2.3.4", "").Code)
+	w := do("GET", "/api/v1/blockchain/metadata", "1.2.3.4", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// the writes are limited separately
+	assert.Equal(t, http.StatusOK, do("POST", "/api/v1/blockchain/metadata", "1.2.3.4", "").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("POST", "/api/v1/blockchain/metadata", "1.2.3.4", "").Code)
+
+	// the heavy routes
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/explorer/richlist", "1.2.3.4", "").Code)
+	w = do("GET", "/api/v1/explorer/richlist", "1.2.3.4", "")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "10", w.Header().Get("Retry-After"))
+
+	// the requests with the api key are limited by the ip without the authentication
+	assert.Equal(t, http.StatusTooManyRequests, do("GET", "/api/v1/explorer/richlist", "1.2.3.4", "key").Code)
+
+	ac := NewAPIAuthConfig()
+	ac.APIKey = "key"
+	mux = http.NewServeMux()
+	api = NewAPIMux(mux, NewAPIAuth(ac), NewRateLimiter(c))
+	api.HandleFunc("/explorer/richlist", func(w http.ResponseWriter, r *http.Request) {})
+
+	// the requests with the valid api key are limited by the key
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/explorer/richlist", "1.2.3.4", "").Code)
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/explorer/richlist", "1.2.3.4", "key").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("GET", "/api/v1/explorer/richlist", "4.3.2.1", "key").Code)
+
+	// the requests with the invalid keys are limited by the ip, a new key per request doesn't
+	// get a new bucket
+	assert.Equal(t, http.StatusOK, do("GET", "/api/v1/explorer/richlist", "5.6.7.8", "guess1").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("GET", "/api/v1/explorer/richlist", "5.6.7.8", "guess2").Code)
+	assert.Equal(t, http.StatusTooManyRequests, do("GET", "/api/v1/explorer/richlist", "5.6.7.8", "").Code)
+}
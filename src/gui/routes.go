@@ -0,0 +1,150 @@
+package gui
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// APIVersion is the version of the stable api namespace, the routes are served under /api/v1.
+// Within a version the routes, their arguments and the fields of the responses are not removed
+// or renamed, the breaking changes go to a new version.
+const APIVersion = "v1"
+
+const apiPrefix = "/api/" + APIVersion
+
+// APIVersions are the versions of the api served
+var APIVersions = []string{APIVersion}
+
+// Mux registers the handlers of the routes, it's implemented by http.ServeMux and APIMux
+type Mux interface {
+	Handle(pattern string, handler http.Handler)
+	HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request))
+}
+
+// APIRoute is a route of the api
+type APIRoute struct {
+	Path    string `json:"path"`
+	Version string `json:"version"`
+	// the legacy path of the route, it's deprecated and will be removed
+	DeprecatedAlias string `json:"deprecated_alias,omitempty"`
+}
+
+// APIMux registers the api handlers under /api/v1, and on their legacy paths as the deprecated
+// aliases. The responses of the legacy paths have the Deprecation header, and the Link header of
+// the /api/v1 path.
+type APIMux struct {
+	mux     *http.ServeMux
+	auth    *APIAuth
+	limiter *RateLimiter
+	routes  []APIRoute
+}
+
+// NewAPIMux creates an APIMux registering the handlers on mux, the handlers are authenticated by
+// auth and limited by limiter if they're not nil
+func NewAPIMux(mux *http.ServeMux, auth *APIAuth, limiter *RateLimiter) *APIMux {
+	return &APIMux{mux: mux, auth: auth, limiter: limiter}
+}
+
+// Handle registers the handler on /api/v1 + pattern, and on pattern as the deprecated alias.
+// The handler sees the legacy path in r.URL.Path either way.
+func (m *APIMux) Handle(pattern string, handler http.Handler) {
+	handler = m.protect(pattern, handler)
+	m.mux.Handle(apiPrefix+pattern, http.StripPrefix(apiPrefix, handler))
+	m.mux.Handle(pattern, deprecatedHandler(handler))
+	m.routes = append(m.routes, APIRoute{
+		Path:            apiPrefix + pattern,
+		Version:         APIVersion,
+		DeprecatedAlias: pattern,
+	})
+}
+
+// HandleFunc registers the handler function like Handle
+func (m *APIMux) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+	m.Handle(pattern, http.HandlerFunc(handler))
+}
+
+// handleAPI registers the handler on /api/v1 + pattern only, for the routes added with the
+// version. The handler is authenticated and limited like Handle, it sees the path without the
+// /api/v1 prefix.
+func (m *APIMux) handleAPI(pattern string, handler http.Handler) {
+	m.mux.Handle(apiPrefix+pattern, http.StripPrefix(apiPrefix, m.protect(pattern, handler)))
+	m.routes = append(m.routes, APIRoute{
+		Path:    apiPrefix + pattern,
+		Version: APIVersion,
+	})
+}
+
+// protect wraps the handler of the route pattern with the authentication, the rate limit and
+// the metrics
+func (m *APIMux) protect(pattern string, handler http.Handler) http.Handler {
+	if m.auth != nil {
+		handler = m.auth.Handler(pattern, handler)
+	}
+	// the limit is before the authentication, the api keys can't be guessed quickly
+	if m.limiter != nil {
+		handler = m.limiter.Handler(pattern, m.auth, handler)
+	}
+	// the rejected requests are counted too
+	return metricsHandler(pattern, handler)
+}
+
+// handleVersioned registers the handler on /api/v1 + pattern only, for the routes added with the
+// version. The handler isn't authenticated.
+func (m *APIMux) handleVersioned(pattern string, handler http.Handler) {
+	m.mux.Handle(apiPrefix+pattern, handler)
+	m.routes = append(m.routes, APIRoute{
+		Path:    apiPrefix + pattern,
+		Version: APIVersion,
+	})
+}
+
+// Routes returns the routes registered, sorted by path
+func (m *APIMux) Routes() []APIRoute {
+	routes := make([]APIRoute, len(m.routes))
+	copy(routes, m.routes)
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+	return routes
+}
+
+func deprecatedHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", apiPrefix, r.URL.Path))
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// RegisterRoutesHandlers register the handler listing the api routes
+func RegisterRoutesHandlers(m *APIMux) {
+	// list the api versions and the routes
+	m.handleVersioned("/routes", http.HandlerFunc(getAPIRoutes(m)))
+}
+
+// APIRoutesResult is the result of /api/v1/routes
+type APIRoutesResult struct {
+	Version  string     `json:"version"`
+	Versions []string   `json:"versions"`
+	Routes   []APIRoute `json:"routes"`
+}
+
+// method: GET
+// url: /api/v1/routes
+func getAPIRoutes(m *APIMux) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		wh.SendOr404(w, APIRoutesResult{
+			Version:  APIVersion,
+			Versions: APIVersions,
+			Routes:   m.Routes(),
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package gui
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIMux(t *testing.T) {
+	mux := http.NewServeMux()
+	api := NewAPIMux(mux, nil, nil)
+	echo := func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.Path))
+	}
+	api.HandleFunc("/wallet", echo)
+	api.HandleFunc("/address/", echo)
+	RegisterRoutesHandlers(api)
+
+	tests := []struct {
+		name       string
+		url        string
+		path       string
+		deprecated bool
+		link       string
+	}{
+		{"v1", "/api/v1/wallet", "/wallet", false, ""},
+		{"legacy", "/wallet", "/wallet", true, "</api/v1/wallet>; rel=\"successor-version\""},
+		{"v1 subtree", "/api/v1/address/abc/transactions", "/address/abc/transactions", false, ""},
+		{"legacy subtree", "/address/abc/transactions", "/address/abc/transactions", true,
+			"</api/v1/address/abc/transactions>; rel=\"successor-version\""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, httptest.NewRequest("GET", tc.url, nil))
+			require.Equal(t, http.StatusOK, w.Code)
+			// the handler sees the legacy path
+			assert.Equal(t, tc.path, w.Body.String())
+			assert.Equal(t, tc.deprecated, w.Header().Get("Deprecation") == "true")
+			assert.Equal(t, tc.link, w.Header().Get("Link"))
+		})
+	}
+
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/api/v1/routes", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var res APIRoutesResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &res))
+	assert.Equal(t, APIRoutesResult{
+		Version:  "v1",
+		Versions: []string{"v1"},
+		Routes: []APIRoute{
+			{Path: "/api/v1/address/", Version: "v1", DeprecatedAlias: "/address/"},
+			{Path: "/api/v1/routes", Version: "v1"},
+			{Path: "/api/v1/wallet", Version: "v1", DeprecatedAlias: "/wallet"},
+		},
+	}, res)
+
+	// the routes added with the version have no legacy path
+	w = httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest("GET", "/routes", nil))
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,33 @@
+package gui
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// ShutdownTimeout is how long Shutdown waits for the requests in progress
+var ShutdownTimeout = 10 * time.Second
+
+// writesStopped is 1 once StopWrites is called
+var writesStopped int32
+
+// StopWrites rejects the api requests not by GET or HEAD with 503, it's called first when the node
+// shuts down so that no write starts while the state is flushed
+func StopWrites() {
+	atomic.StoreInt32(&writesStopped, 1)
+}
+
+// shutdownHandler rejects the writes after StopWrites, the reads are served till the server is
+// shut down
+func shutdownHandler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.LoadInt32(&writesStopped) == 1 && r.Method != "GET" && r.Method != "HEAD" {
+			wh.Error503(w, "node is shutting down")
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,29 @@
+package gui
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownHandler(t *testing.T) {
+	defer atomic.StoreInt32(&writesStopped, 0)
+
+	h := shutdownHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	do := func(method string) int {
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, httptest.NewRequest(method, "/injectTransaction", nil))
+		return w.Code
+	}
+
+	require.Equal(t, http.StatusOK, do("POST"))
+
+	StopWrites()
+	require.Equal(t, http.StatusServiceUnavailable, do("POST"))
+	require.Equal(t, http.StatusServiceUnavailable, do("DELETE"))
+	require.Equal(t, http.StatusOK, do("GET"))
+	require.Equal(t, http.StatusOK, do("HEAD"))
+}
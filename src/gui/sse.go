@@ -0,0 +1,140 @@
+package gui
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/skycoin/skycoin/src/daemon"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+const (
+	// sseMaxConns is the max number of the server-sent events streams
+	sseMaxConns = 64
+	// sseKeepAlive is the interval of the comments sent on the idle streams, the proxies don't
+	// close them
+	sseKeepAlive = 15 * time.Second
+	// sseRetry is how long the clients wait before reconnecting, in milliseconds
+	sseRetry = 3000
+)
+
+// sseHandler streams the subscribed events as server-sent events. The topics and the addresses
+// are the same as the websocket subscriptions, the event name is the topic and the data is the
+// readable json of the event.
+// method: GET
+// url: /events?topics=[:topics]&addresses=[:addresses]
+// params: topics is comma separated new_block, new_unconfirmed_txn or address_activity,
+// addresses is comma separated addresses of address_activity.
+func sseHandler(gateway *daemon.Gateway, watcher *wsWatcher) http.HandlerFunc {
+	var conns int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			wh.Error500(w, "streaming is not supported")
+			return
+		}
+
+		topics := splitParam(r.FormValue("topics"))
+		if len(topics) == 0 {
+			wh.Error400(w, "topics is empty")
+			return
+		}
+		addrs := splitParam(r.FormValue("addresses"))
+
+		if atomic.AddInt32(&conns, 1) > sseMaxConns {
+			atomic.AddInt32(&conns, -1)
+			wh.Error503(w, "too many event streams")
+			return
+		}
+		defer atomic.AddInt32(&conns, -1)
+
+		sub := newEventSubscription(gateway, watcher)
+		defer sub.release()
+
+		for _, topic := range topics {
+			req := wsRequest{Op: "subscribe", Topic: topic}
+			if topic == wsTopicAddressActivity {
+				req.Addresses = addrs
+			}
+			if reply := sub.handle(req); reply.Error != "" {
+				wh.Error400(w, reply.Error)
+				return
+			}
+		}
+
+		events, unsubscribe := sub.subscribe()
+		defer unsubscribe()
+
+		h := w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		// the nginx doesn't buffer the stream
+		h.Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, "retry: %d\n\n", sseRetry)
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(sseKeepAlive)
+		defer keepAlive.Stop()
+
+		// the streams are closed when the web interface shuts down
+		q := quit
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case <-q:
+				return
+			case ev, ok := <-events:
+				if !ok {
+					return
+				}
+				msg := sub.message(ev)
+				if msg == nil {
+					continue
+				}
+				if err := writeSSEEvent(w, msg.Topic, msg.Data); err != nil {
+					logger.Debug("write event stream failed: %v", err)
+					return
+				}
+			case <-keepAlive.C:
+				if _, err := io.WriteString(w, ": keepalive\n\n"); err != nil {
+					return
+				}
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSEEvent writes the event of the name with the json of data, the json has no newline
+func writeSSEEvent(w io.Writer, name string, data interface{}) error {
+	d, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", name, d)
+	return err
+}
+
+// splitParam splits the comma separated param, the empty items are removed
+func splitParam(s string) []string {
+	var l []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			l = append(l, v)
+		}
+	}
+	return l
+}
@@ -0,0 +1,24 @@
+package gui
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteSSEEvent(t *testing.T) {
+	var b bytes.Buffer
+	err := writeSSEEvent(&b, "new_block", map[string]interface{}{
+		"seq":  1,
+		"memo": "a\nb",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "event: new_block\ndata: {\"memo\":\"a\\nb\",\"seq\":1}\n\n", b.String())
+}
+
+func TestSplitParam(t *testing.T) {
+	require.Nil(t, splitParam(""))
+	require.Nil(t, splitParam(" , ,"))
+	require.Equal(t, []string{"new_block", "address_activity"}, splitParam("new_block, address_activity,"))
+}
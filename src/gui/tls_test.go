@@ -0,0 +1,81 @@
+package gui
+
+import (
+	"crypto/tls"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/util/cert"
+)
+
+func TestParseCipherSuites(t *testing.T) {
+	suites, err := ParseCipherSuites("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256, TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305")
+	require.NoError(t, err)
+	require.Equal(t, []uint16{
+		tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+		tls.TLS_ECDHE_RSA_WITH_CHACHA20_POLY1305,
+	}, suites)
+
+	suites, err = ParseCipherSuites("")
+	require.NoError(t, err)
+	require.Nil(t, suites)
+
+	_, err = ParseCipherSuites("TLS_RSA_WITH_RC4_128_SHA")
+	require.Error(t, err)
+}
+
+func TestParseTLSVersion(t *testing.T) {
+	v, err := ParseTLSVersion("1.2")
+	require.NoError(t, err)
+	require.Equal(t, uint16(tls.VersionTLS12), v)
+
+	_, err = ParseTLSVersion("3.0")
+	require.Error(t, err)
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "tls")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	c := TLSConfig{
+		CertFile: filepath.Join(dir, "cert.pem"),
+		KeyFile:  filepath.Join(dir, "key.pem"),
+	}
+
+	_, err = newTLSConfig(c)
+	require.Error(t, err)
+
+	require.Empty(t, cert.CreateCertIfNotExists("127.0.0.1", c.CertFile, c.KeyFile, "test"))
+
+	tc, err := newTLSConfig(c)
+	require.NoError(t, err)
+	require.Equal(t, DefaultCipherSuites, tc.CipherSuites)
+	require.Equal(t, uint16(tls.VersionTLS12), tc.MinVersion)
+
+	c.CipherSuites = []uint16{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}
+	c.MinVersion = tls.VersionTLS13
+	tc, err = newTLSConfig(c)
+	require.NoError(t, err)
+	require.Equal(t, c.CipherSuites, tc.CipherSuites)
+	require.Equal(t, uint16(tls.VersionTLS13), tc.MinVersion)
+}
+
+func TestHSTSHandler(t *testing.T) {
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	w := httptest.NewRecorder()
+	hstsHandler(31536000, ok).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"))
+
+	w = httptest.NewRecorder()
+	hstsHandler(0, ok).ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+}
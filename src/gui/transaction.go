@@ -4,18 +4,22 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/coin"
 	"github.com/skycoin/skycoin/src/daemon"
 	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
 
 	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
 )
 
 // RegisterTxHandlers registers transaction handlers
-func RegisterTxHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterTxHandlers(mux Mux, gateway *daemon.Gateway) {
 	// get set of pending transactions
 	mux.HandleFunc("/pendingTxs", getPendingTxs(gateway))
 	// get latest confirmed transactions
@@ -23,13 +27,36 @@ func RegisterTxHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	// get txn by txid
 	mux.HandleFunc("/transaction", getTransactionByID(gateway))
 	//inject a transaction into network
-	mux.HandleFunc("/injectTransaction", injectTransaction(gateway))
+	mux.HandleFunc("/injectTransaction", idempotent(injectTransaction(gateway)))
+	//inject a raw hex transaction into network, the request body is the hex string
+	mux.HandleFunc("/injectRawTransaction", idempotent(injectRawTransaction(gateway)))
+	// inject a batch of chained transactions atomically
+	mux.HandleFunc("/injectTransactions", idempotent(injectTransactions(gateway)))
+	// inject a transaction in the json format of TransactionToJSON, e.g. signed offline
+	mux.HandleFunc("/injectTransactionJSON", idempotent(injectTransactionJSON(gateway)))
+	// create a transaction without signing it, it's signed offline by the wallet tool
+	mux.HandleFunc("/createUnsignedTransaction", createUnsignedTransaction(gateway))
+	// merge the signatures of the copies of an unsigned txn signed by the cosigners
+	mux.HandleFunc("/combineUnsignedTransactions", combineUnsignedTransactions(gateway))
+	// estimate the fee per byte to get a transaction confirmed in the target blocks
+	mux.HandleFunc("/fee_estimate", getFeeEstimate(gateway))
+	// check a transaction against the current state without injecting it
+	mux.HandleFunc("/verifyTransaction", verifyTransaction(gateway))
 	mux.HandleFunc("/resendUnconfirmedTxns", resendUnconfirmedTxns(gateway))
+	// list the locally created txns that are being rebroadcasted
+	mux.HandleFunc("/rebroadcasts", getPendingRebroadcasts(gateway))
+	// stop rebroadcasting the txn
+	mux.HandleFunc("/rebroadcasts/cancel", cancelRebroadcast(gateway))
 	// get raw tx by txid.
 	mux.HandleFunc("/rawtx", getRawTx(gateway))
+	// get the inclusion proof of confirmed txn by txid
+	mux.HandleFunc("/transaction_proof", getTransactionProof(gateway))
+	// get the status changes of txn by txid
+	mux.HandleFunc("/transaction_history", getTransactionHistory(gateway))
 }
 
 // Returns pending transactions
+// set address to only return the transactions that spend from or send to the address
 func getPendingTxs(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
@@ -37,14 +64,20 @@ func getPendingTxs(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
-		txns := gateway.GetAllUnconfirmedTxns()
-		ret := make([]*visor.ReadableUnconfirmedTxn, 0, len(txns))
-		for _, unconfirmedTxn := range txns {
-			readable := visor.NewReadableUnconfirmedTxn(&unconfirmedTxn)
-			ret = append(ret, &readable)
+		if addr := r.FormValue("address"); addr != "" {
+			a, err := cipher.DecodeBase58Address(addr)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("address %s is invalid: %v", addr, err))
+				return
+			}
+
+			txns := gateway.GetUnconfirmedTxnsForAddress(a)
+			wh.SendOr404(w, &txns)
+			return
 		}
 
-		wh.SendOr404(w, &ret)
+		txns := gateway.GetReadableUnconfirmedTxns()
+		wh.SendOr404(w, &txns)
 	}
 }
 
@@ -73,6 +106,8 @@ func getLastTxs(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// getTransactionByID returns transaction by txid, set human_time=1 to emit RFC3339
+// timestamp alongside the unix time
 func getTransactionByID(gate *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
@@ -102,7 +137,7 @@ func getTransactionByID(gate *daemon.Gateway) http.HandlerFunc {
 		}
 
 		resTx := visor.TransactionResult{
-			Transaction: visor.NewReadableTransaction(tx),
+			Transaction: visor.NewReadableTransactionWithOptions(tx, encodeOptions(r)),
 			Status:      tx.Status,
 		}
 		wh.SendOr404(w, &resTx)
@@ -127,14 +162,80 @@ func injectTransaction(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
-		b, err := hex.DecodeString(v.Rawtx)
+		txn, err := visor.TransactionFromHex(v.Rawtx)
+		if err != nil {
+			logger.Error("%v", err)
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		t, err := gateway.InjectTransaction(txn)
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("inject tx failed:%v", err))
+			return
+		}
+
+		wh.SendOr404(w, t.Hash().Hex())
+	}
+}
+
+// injectRawTransaction injects the transaction whose hex string is sent as the request body
+func injectRawTransaction(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		// the hex of a txn is twice its size
+		r.Body = http.MaxBytesReader(w, r.Body, int64(2*gateway.MaxMessageLength()))
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			logger.Error("bad request: %v", err)
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		txn, err := visor.TransactionFromHex(string(b))
+		if err != nil {
+			logger.Error("%v", err)
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		t, err := gateway.InjectTransaction(txn)
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("inject tx failed:%v", err))
+			return
+		}
+
+		wh.SendOr404(w, t.Hash().Hex())
+	}
+}
+
+// injectTransactionJSON injects the transaction whose json is sent as the request body,
+// the json is the format of TransactionToJSON, unknown fields are rejected
+func injectTransactionJSON(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		b, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			logger.Error("bad request: %v", err)
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		txn, err := visor.TransactionFromJSONStrict(string(b))
 		if err != nil {
 			logger.Error("%v", err)
 			wh.Error400(w, err.Error())
 			return
 		}
 
-		txn := coin.TransactionDeserialize(b)
 		t, err := gateway.InjectTransaction(txn)
 		if err != nil {
 			wh.Error400(w, fmt.Sprintf("inject tx failed:%v", err))
@@ -145,6 +246,262 @@ func injectTransaction(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// createUnsignedTransaction creates the transaction spending the coins of the wallet or
+// the addresses without signing it, the node needs no secret keys. The multisig addresses
+// in addrs must be added to the wallet of the id. If the change is not set and the wallet
+// sends the change to new addresses, a new address is generated, which needs the password
+// of the encrypted wallet.
+// method: POST
+// url: /createUnsignedTransaction
+// params: id and/or addrs, dst, coins, change, selection, password
+func createUnsignedTransaction(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		var addrs []cipher.Address
+		id, saddrs := r.FormValue("id"), r.FormValue("addrs")
+		if id == "" && saddrs == "" {
+			wh.Error400(w, "missing id or addrs")
+			return
+		}
+
+		for _, s := range strings.Split(saddrs, ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+
+			a, err := cipher.DecodeBase58Address(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid address %s: %v", s, err))
+				return
+			}
+			addrs = append(addrs, a)
+		}
+
+		// the multisig addresses of the wallet can be spent by addrs
+		var multisigs []wallet.Multisig
+		if id != "" {
+			wlt := Wg.GetWallet(id)
+			if wlt == nil {
+				wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+				return
+			}
+
+			var err error
+			if multisigs, err = wlt.Multisigs(); err != nil {
+				wh.Error500(w, err.Error())
+				return
+			}
+
+			if len(addrs) == 0 {
+				addrs = wlt.GetAddresses()
+			}
+		}
+
+		dst, err := cipher.DecodeBase58Address(r.FormValue("dst"))
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("invalid destination address: %v", err))
+			return
+		}
+
+		var change cipher.Address
+		if s := r.FormValue("change"); s != "" {
+			change, err = cipher.DecodeBase58Address(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid change address: %v", err))
+				return
+			}
+		}
+
+		// the wallet setting decides the change address if it's not set
+		if id != "" && change == (cipher.Address{}) {
+			change, err = Wg.changeAddress(id, []byte(r.FormValue("password")))
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("create change address failed: %v", err))
+				return
+			}
+		}
+
+		coins, err := strconv.ParseUint(r.FormValue("coins"), 10, 64)
+		if err != nil {
+			wh.Error400(w, "invalid \"coins\" value")
+			return
+		}
+
+		sel, err := visor.GetCoinSelector(r.FormValue("selection"))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		ut, err := gateway.CreateUnsignedTransaction(addrs, wallet.NewBalance(coins, 0), dst, change, multisigs, sel)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, ut)
+	}
+}
+
+// combineUnsignedTransactions merges the signatures of the copies of the same unsigned
+// transaction, the body is the json array of them. The signed transaction is returned as
+// rawtx if every input has enough signatures.
+// method: POST
+// url: /combineUnsignedTransactions
+func combineUnsignedTransactions(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		var uts []visor.UnsignedTransaction
+		if err := json.NewDecoder(r.Body).Decode(&uts); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		ut, err := visor.CombineUnsignedTransactions(uts...)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		rlt := struct {
+			Transaction *visor.UnsignedTransaction `json:"transaction"`
+			Complete    bool                       `json:"complete"`
+			RawTx       string                     `json:"rawtx,omitempty"`
+		}{
+			Transaction: ut,
+			Complete:    ut.Complete(),
+		}
+
+		if rlt.Complete {
+			txn, err := ut.Finalize()
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+			rlt.RawTx = hex.EncodeToString(txn.Serialize())
+		}
+
+		wh.SendOr404(w, rlt)
+	}
+}
+
+// injectTransactions injects the chained transactions atomically, either all of them are
+// accepted in the dependency order or none is. Returns the txids in the injected order.
+func injectTransactions(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		v := struct {
+			Rawtxs []string `json:"rawtxs"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			logger.Error("bad request: %v", err)
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		txns := make(coin.Transactions, len(v.Rawtxs))
+		for i, rawtx := range v.Rawtxs {
+			txn, err := visor.TransactionFromHex(rawtx)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("transaction %d is invalid: %v", i, err))
+				return
+			}
+			txns[i] = txn
+		}
+
+		ordered, err := gateway.InjectTransactions(txns)
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("inject txs failed:%v", err))
+			return
+		}
+
+		txids := make([]string, len(ordered))
+		for i := range ordered {
+			txids[i] = ordered[i].Hash().Hex()
+		}
+		wh.SendOr404(w, txids)
+	}
+}
+
+// getFeeEstimate returns the coin hour fee per byte estimated to get a transaction confirmed
+// in the target blocks, with the fee statistics of the recent blocks and unconfirmed txns.
+// target_blocks is 1 by default.
+func getFeeEstimate(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		target := 1
+		if s := r.FormValue("target_blocks"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid target_blocks: %v", err))
+				return
+			}
+			target = n
+		}
+
+		fe, err := gateway.GetFeeEstimate(target)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, fe)
+	}
+}
+
+// verifyTransaction checks the transaction the same way as it's injected, returns the
+// fee and the rejection reason without adding it to the pool or broadcasting it
+func verifyTransaction(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		v := struct {
+			Rawtx string `json:"rawtx"`
+		}{}
+
+		if err := json.NewDecoder(r.Body).Decode(&v); err != nil {
+			logger.Error("bad request: %v", err)
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		txn, err := visor.TransactionFromHex(v.Rawtx)
+		if err != nil {
+			logger.Error("%v", err)
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		pv, err := gateway.VerifyTxnAgainstState(txn)
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, pv)
+	}
+}
+
 func resendUnconfirmedTxns(gate *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
@@ -160,6 +517,48 @@ func resendUnconfirmedTxns(gate *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// getPendingRebroadcasts returns the locally created txns that are rebroadcasted with
+// backoff until they are confirmed or expired
+func getPendingRebroadcasts(gate *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		wh.SendOr404(w, gate.GetPendingRebroadcasts())
+	}
+}
+
+// cancelRebroadcast stops rebroadcasting the txn, the txn is kept in the unconfirmed pool
+func cancelRebroadcast(gate *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		txid := r.FormValue("txid")
+		if txid == "" {
+			wh.Error400(w, "txid is empty")
+			return
+		}
+
+		h, err := cipher.SHA256FromHex(txid)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if !gate.CancelRebroadcast(h) {
+			wh.Error404(w, "not found")
+			return
+		}
+
+		wh.SendOr404(w, txid)
+	}
+}
+
 func getRawTx(gate *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "GET" {
@@ -184,8 +583,76 @@ func getRawTx(gate *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
-		d := tx.Txn.Serialize()
-		wh.SendOr404(w, hex.EncodeToString(d))
+		if tx == nil {
+			wh.Error404(w, "not found")
+			return
+		}
+
+		wh.SendOr404(w, visor.TransactionToHex(tx.Txn))
 		return
 	}
 }
+
+// getTransactionProof returns the block header and merkle path that prove the txn is in the block
+func getTransactionProof(gate *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+		txid := r.FormValue("txid")
+		if txid == "" {
+			wh.Error400(w, "txid is empty")
+			return
+		}
+
+		h, err := cipher.SHA256FromHex(txid)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		p, err := gate.GetTransactionProof(h)
+		if err != nil {
+			logger.Error("get transaction proof failed: %v", err)
+			wh.Error500(w, err.Error())
+			return
+		}
+		if p == nil {
+			wh.Error404(w, "not found")
+			return
+		}
+
+		rp := visor.NewReadableTransactionProof(p)
+		wh.SendOr404(w, &rp)
+	}
+}
+
+// getTransactionHistory returns the status changes of the txn seen since the node started
+func getTransactionHistory(gate *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+		txid := r.FormValue("txid")
+		if txid == "" {
+			wh.Error400(w, "txid is empty")
+			return
+		}
+
+		h, err := cipher.SHA256FromHex(txid)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		chs := gate.GetTransactionHistory(h)
+		if chs == nil {
+			wh.Error404(w, "not found")
+			return
+		}
+
+		wh.SendOr404(w, chs)
+	}
+}
@@ -1,20 +1,34 @@
 package gui
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/daemon"
 	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+	"github.com/skycoin/skycoin/src/visor"
 	"github.com/skycoin/skycoin/src/visor/historydb"
 )
 
 // RegisterUxOutHandlers binds uxout entries.
-func RegisterUxOutHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
-	// get uxout by id.
+func RegisterUxOutHandlers(mux Mux, gateway *daemon.Gateway) {
+	// get uxout by id, set verbose=1 to get the spending metadata in readable format,
+	// or decimal_coins=1 to get the coins as decimal string.
 	mux.HandleFunc("/uxout", getUxOutByID(gateway))
 	// get all the address affected uxouts.
 	mux.HandleFunc("/address_uxouts", getAddrUxOuts(gateway))
+	// get the coin hours of the address or uxout, at the head time and a future time
+	mux.HandleFunc("/coin_hours", getCoinHours(gateway))
+	// list the watched addresses
+	mux.HandleFunc("/watched_addresses", getWatchedAddresses(gateway))
+	// add addresses to the watch list
+	mux.HandleFunc("/watched_addresses/add", watchAddresses(gateway))
+	// remove addresses from the watch list
+	mux.HandleFunc("/watched_addresses/remove", unwatchAddresses(gateway))
 }
 
 func getUxOutByID(gateway *daemon.Gateway) http.HandlerFunc {
@@ -36,6 +50,22 @@ func getUxOutByID(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
+		if r.FormValue("verbose") == "1" {
+			out, err := gateway.GetUxOutVerbose(id)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			if out == nil {
+				wh.Error404(w, "not found")
+				return
+			}
+
+			wh.SendOr404(w, out)
+			return
+		}
+
 		uxout, err := gateway.GetUxOutByID(id)
 		if err != nil {
 			wh.Error400(w, err.Error())
@@ -47,7 +77,7 @@ func getUxOutByID(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
-		wh.SendOr404(w, historydb.NewUxOutJSON(uxout))
+		wh.SendOr404(w, encodeOptions(r).UxOut(historydb.NewUxOutJSON(uxout)))
 	}
 }
 
@@ -69,12 +99,154 @@ func getAddrUxOuts(gateway *daemon.Gateway) http.HandlerFunc {
 			return
 		}
 
+		if r.FormValue("verbose") == "1" {
+			outs, err := gateway.GetAddrUxOutsVerbose(cipherAddr)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			wh.SendOr404(w, outs)
+			return
+		}
+
 		uxs, err := gateway.GetAddrUxOuts(cipherAddr)
 		if err != nil {
 			wh.Error400(w, err.Error())
 			return
 		}
 
-		wh.SendOr404(w, uxs)
+		wh.SendOr404(w, encodeOptions(r).UxOuts(uxs))
+	}
+}
+
+// getCoinHours returns the coin hours accrued by the head time, and projected at the time
+// of param at, of the unspent output of uxid, or the unspent outputs of address
+func getCoinHours(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		var at uint64
+		if v := r.FormValue("at"); v != "" {
+			var err error
+			at, err = strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid at: %v", err))
+				return
+			}
+		}
+
+		addr := r.FormValue("address")
+		uxid := r.FormValue("uxid")
+		switch {
+		case addr == "" && uxid == "":
+			wh.Error400(w, "should specify one filter, address or uxid")
+		case addr != "" && uxid != "":
+			wh.Error400(w, "should only specify one filter, address or uxid")
+		case addr != "":
+			a, err := cipher.DecodeBase58Address(addr)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			hp, err := gateway.GetAddressHours(a, at)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			wh.SendOr404(w, hp)
+		default:
+			id, err := cipher.SHA256FromHex(uxid)
+			if err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+
+			hp, err := gateway.GetUxOutHours(id, at)
+			switch err {
+			case nil:
+				wh.SendOr404(w, hp)
+			case visor.ErrUnspentNotExist:
+				wh.Error404(w, "not found")
+			default:
+				wh.Error400(w, err.Error())
+			}
+		}
+	}
+}
+
+func getWatchedAddresses(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetWatchedAddresses())
+	}
+}
+
+// watchAddresses adds the addresses to the watch list, the outputs received or spent by
+// them are published as events
+func watchAddresses(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		addrs, err := parseAddrs(r.FormValue("addrs"))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if err := gateway.WatchAddresses(addrs); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, gateway.GetWatchedAddresses())
+	}
+}
+
+func unwatchAddresses(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		addrs, err := parseAddrs(r.FormValue("addrs"))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		gateway.UnwatchAddresses(addrs)
+		wh.SendOr404(w, gateway.GetWatchedAddresses())
+	}
+}
+
+// parseAddrs parses the comma separated addresses
+func parseAddrs(s string) ([]cipher.Address, error) {
+	if s == "" {
+		return nil, errors.New("addrs is empty")
+	}
+
+	addrsStr := strings.Split(s, ",")
+	addrs := make([]cipher.Address, 0, len(addrsStr))
+	for _, addr := range addrsStr {
+		a, err := cipher.DecodeBase58Address(addr)
+		if err != nil {
+			return nil, fmt.Errorf("address %s is invalid: %v", addr, err)
+		}
+		addrs = append(addrs, a)
 	}
+	return addrs, nil
 }
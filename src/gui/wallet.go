@@ -2,9 +2,11 @@ package gui
 
 // Wallet-related information for the GUI
 import (
+	"encoding/json"
 	"fmt"
 	"net/http"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -25,6 +27,9 @@ type WalletRPC struct {
 	WalletDirectory string
 	Options         []wallet.Option
 	firstAddrIDMap  map[string]string // key: first address in wallet, value: wallet id
+
+	// the decrypted wallets that are unlocked
+	unlocked *wallet.Unlocked
 }
 
 // NotesRPC note rpc
@@ -65,6 +70,7 @@ func NewNotesRPC(walletDir string) *NotesRPC {
 func NewWalletRPC(walletDir string, options ...wallet.Option) *WalletRPC {
 	rpc := &WalletRPC{
 		firstAddrIDMap: make(map[string]string),
+		unlocked:       wallet.NewUnlocked(),
 	}
 	if err := os.MkdirAll(walletDir, os.FileMode(0700)); err != nil {
 		logger.Panicf("Failed to create wallet directory %s: %v", walletDir, err)
@@ -137,6 +143,8 @@ func (wrpc *WalletRPC) removeDup(wlts wallet.Wallets) wallet.Wallets {
 
 // ReloadWallets reload wallets
 func (wrpc *WalletRPC) ReloadWallets() error {
+	// the reloaded wallets may be changed, they're unlocked again
+	wrpc.unlocked.LockAll()
 	wrpc.firstAddrIDMap = make(map[string]string)
 	wallets, err := wallet.LoadWallets(wrpc.WalletDirectory)
 	if err != nil {
@@ -146,6 +154,95 @@ func (wrpc *WalletRPC) ReloadWallets() error {
 	return nil
 }
 
+// LoadWallet loads the wallet file of the name in the wallet directory, the wallet id is the
+// name. The loaded wallet must not be a duplicate of a loaded wallet.
+func (wrpc *WalletRPC) LoadWallet(wltName string) (wallet.Wallet, error) {
+	if _, ok := wrpc.Wallets[wltName]; ok {
+		return wallet.Wallet{}, fmt.Errorf("wallet %s is already loaded", wltName)
+	}
+
+	w, err := wallet.LoadWallet(wrpc.WalletDirectory, wltName)
+	if err != nil {
+		return wallet.Wallet{}, err
+	}
+
+	if len(w.Entries) > 0 {
+		if id, ok := wrpc.firstAddrIDMap[w.Entries[0].Address.String()]; ok {
+			return wallet.Wallet{}, fmt.Errorf("duplicate wallet with %v", id)
+		}
+		wrpc.firstAddrIDMap[w.Entries[0].Address.String()] = w.GetID()
+	}
+
+	if err := wrpc.Wallets.Add(*w); err != nil {
+		return wallet.Wallet{}, err
+	}
+	return *w, nil
+}
+
+// UnloadWallet removes the wallet from the node, it's locked first. The wallet file is not
+// removed, the wallet can be loaded again.
+func (wrpc *WalletRPC) UnloadWallet(wltID string) error {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return fmt.Errorf("Unknown wallet %s", wltID)
+	}
+
+	wrpc.lock(wltID)
+	if len(w.Entries) > 0 {
+		addr := w.Entries[0].Address.String()
+		if wrpc.firstAddrIDMap[addr] == wltID {
+			delete(wrpc.firstAddrIDMap, addr)
+		}
+	}
+	wrpc.Wallets.Remove(wltID)
+	return nil
+}
+
+// WalletInfo is the summary of a wallet file in the wallet directory, only the id is set
+// if the wallet is not loaded
+type WalletInfo struct {
+	ID        string `json:"id"`
+	Loaded    bool   `json:"loaded"`
+	Label     string `json:"label,omitempty"`
+	Type      string `json:"type,omitempty"`
+	Encrypted bool   `json:"encrypted"`
+	Addresses int    `json:"addresses"`
+}
+
+// ListWallets returns the wallets loaded and the wallet files not loaded, sorted by id
+func (wrpc *WalletRPC) ListWallets() ([]WalletInfo, error) {
+	names, err := wallet.ListWalletFiles(wrpc.WalletDirectory)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make(map[string]struct{}, len(names)+len(wrpc.Wallets))
+	for _, n := range names {
+		ids[n] = struct{}{}
+	}
+	for id := range wrpc.Wallets {
+		ids[id] = struct{}{}
+	}
+
+	infos := make([]WalletInfo, 0, len(ids))
+	for id := range ids {
+		info := WalletInfo{ID: id}
+		if w, ok := wrpc.Wallets[id]; ok {
+			info.Loaded = true
+			info.Label = w.GetLabel()
+			info.Type = w.GetType()
+			info.Encrypted = w.IsEncrypted()
+			info.Addresses = len(w.Entries)
+		}
+		infos = append(infos, info)
+	}
+
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].ID < infos[j].ID
+	})
+	return infos, nil
+}
+
 // SaveWallet saves a wallet
 func (wrpc *WalletRPC) SaveWallet(walletID string) error {
 	if w, ok := wrpc.Wallets.Get(walletID); ok {
@@ -186,10 +283,117 @@ func (wrpc *WalletRPC) CreateWallet(wltName string, options ...wallet.Option) (w
 	return *w, nil
 }
 
-// NewAddresses generate address entries in specific wallet,
-// return nil if wallet does not exist.
-func (wrpc *WalletRPC) NewAddresses(wltID string, num int) ([]cipher.Address, error) {
-	return wrpc.Wallets.NewAddresses(wltID, num)
+// NewAddresses generate address entries in specific wallet and saves it, the password is
+// required if the wallet is encrypted and locked.
+func (wrpc *WalletRPC) NewAddresses(wltID string, num int, password []byte) ([]cipher.Address, error) {
+	return wrpc.updateWallet(wltID, password, func(w *wallet.Wallet) ([]cipher.Address, error) {
+		return w.GenerateAddresses(num), nil
+	})
+}
+
+// ScanAddresses generates the addresses of the wallet until gap consecutive ones are unused
+// in the blockchain, keeps the ones up to the last used and saves it, the password is
+// required if the wallet is encrypted and locked.
+func (wrpc *WalletRPC) ScanAddresses(gateway *daemon.Gateway, wltID string, gap int, password []byte) ([]cipher.Address, error) {
+	return wrpc.updateWallet(wltID, password, func(w *wallet.Wallet) ([]cipher.Address, error) {
+		return w.ScanAddresses(gap, gateway.AddressesUsed)
+	})
+}
+
+// NewChangeAddresses generates change address entries in the HD wallet and saves it, the
+// password is required if the wallet is encrypted and locked.
+func (wrpc *WalletRPC) NewChangeAddresses(wltID string, num int, password []byte) ([]cipher.Address, error) {
+	return wrpc.updateWallet(wltID, password, func(w *wallet.Wallet) ([]cipher.Address, error) {
+		return w.GenerateChangeAddresses(num)
+	})
+}
+
+// NewChangeAddress generates a new change address in the wallet and saves it, it's on the
+// change chain of the HD wallet. The password is required if the wallet is encrypted and
+// locked.
+func (wrpc *WalletRPC) NewChangeAddress(wltID string, password []byte) (cipher.Address, error) {
+	addrs, err := wrpc.updateWallet(wltID, password, func(w *wallet.Wallet) ([]cipher.Address, error) {
+		a, err := w.NewChangeAddress()
+		if err != nil {
+			return nil, err
+		}
+		return []cipher.Address{a}, nil
+	})
+	if err != nil {
+		return cipher.Address{}, err
+	}
+	return addrs[0], nil
+}
+
+// SetFreshChange sets whether the change of the wallet spends is sent to new addresses and
+// saves the wallet
+func (wrpc *WalletRPC) SetFreshChange(wltID string, fresh bool) error {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return fmt.Errorf("Unknown wallet %s", wltID)
+	}
+	w.SetFreshChange(fresh)
+
+	wrpc.unlocked.Update(wltID, func(u *wallet.Wallet) {
+		u.SetFreshChange(fresh)
+	})
+
+	return wrpc.SaveWallet(wltID)
+}
+
+// SetSigner sets the external signer of the wallet spends and saves the wallet, the empty
+// signer removes it
+func (wrpc *WalletRPC) SetSigner(wltID string, signer string) error {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return fmt.Errorf("Unknown wallet %s", wltID)
+	}
+	if err := w.SetSigner(signer); err != nil {
+		return err
+	}
+
+	wrpc.unlocked.Update(wltID, func(u *wallet.Wallet) {
+		u.SetSigner(signer)
+	})
+
+	return wrpc.SaveWallet(wltID)
+}
+
+// SetPolicy sets the spending policy of the wallet and saves it, the empty policy removes it
+func (wrpc *WalletRPC) SetPolicy(wltID string, p wallet.Policy) error {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return fmt.Errorf("Unknown wallet %s", wltID)
+	}
+	if err := w.SetPolicy(p); err != nil {
+		return err
+	}
+
+	wrpc.unlocked.Update(wltID, func(u *wallet.Wallet) {
+		u.SetPolicy(p)
+	})
+
+	return wrpc.SaveWallet(wltID)
+}
+
+// changeAddress returns the change address of a spend from the wallet, a new address is
+// generated if the wallet sends the change to new addresses, otherwise it's empty and the
+// change goes back to the spent address
+func (wrpc *WalletRPC) changeAddress(wltID string, password []byte) (cipher.Address, error) {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return cipher.Address{}, fmt.Errorf("Unknown wallet %s", wltID)
+	}
+
+	if !w.FreshChange() {
+		return cipher.Address{}, nil
+	}
+	return wrpc.NewChangeAddress(wltID, password)
+}
+
+// MigrateWallet converts the deterministic wallet to HD, the wallet file is backed up first
+func (wrpc *WalletRPC) MigrateWallet(wltID string) error {
+	return wrpc.Wallets.MigrateToHD(wltID, wrpc.WalletDirectory)
 }
 
 // GetWalletReadable returns a readable wallet
@@ -236,7 +440,11 @@ func (wrpc *WalletRPC) GetWalletBalance(gateway *daemon.Gateway,
 type SpendResult struct {
 	Balance     wallet.BalancePair        `json:"balance"`
 	Transaction visor.ReadableTransaction `json:"txn"`
-	Error       string                    `json:"error"`
+	// the label, category and note of the transaction, set by the spend params
+	Annotation *wallet.Annotation `json:"annotation,omitempty"`
+	Error      string             `json:"error"`
+	// the rule of the wallet policy violated by the spend
+	PolicyError *wallet.PolicyError `json:"policy_error,omitempty"`
 }
 
 // Spend TODO
@@ -249,14 +457,17 @@ type SpendResult struct {
 func Spend(gateway *daemon.Gateway,
 	wrpc *WalletRPC,
 	walletID string,
+	password []byte,
 	amt wallet.Balance,
 	fee uint64,
-	dest cipher.Address) *SpendResult {
+	dest cipher.Address,
+	change cipher.Address,
+	sel visor.CoinSelector) *SpendResult {
 	var txn coin.Transaction
 	var b wallet.BalancePair
 	var err error
 	for {
-		txn, err = Spend2(gateway, wrpc, walletID, amt, fee, dest)
+		txn, err = Spend2(gateway, wrpc, walletID, password, amt, fee, dest, change, sel)
 		if err != nil {
 			logger.Error("Transaction creation failed: %v", err)
 			break
@@ -279,9 +490,13 @@ func Spend(gateway *daemon.Gateway,
 	}
 
 	if err != nil {
-		return &SpendResult{
+		ret := &SpendResult{
 			Error: err.Error(),
 		}
+		if pe, ok := err.(wallet.PolicyError); ok {
+			ret.PolicyError = &pe
+		}
+		return ret
 	}
 
 	return &SpendResult{
@@ -291,20 +506,79 @@ func Spend(gateway *daemon.Gateway,
 }
 
 // Spend2 Creates a transaction spending amt with additional fee.  Fee is in addition
-// to the base required fee given amt.Hours.
+// to the base required fee given amt.Hours. The password is required if the wallet is
+// encrypted and locked. The outputs are chosen by sel. The change is sent to the change
+// address, if it's not set the wallet setting decides between a new address and the spent
+// address.
+// The inputs are signed by the external signer of the wallet if it's set, otherwise by the
+// keys of the wallet.
 // TODO
 // - pull in outputs from blockchain from wallet
 // - create transaction here
 // - sign transction and return
-func Spend2(gateway *daemon.Gateway, wrpc *WalletRPC, walletID string, amt wallet.Balance,
-	fee uint64, dest cipher.Address) (coin.Transaction, error) {
+func Spend2(gateway *daemon.Gateway, wrpc *WalletRPC, walletID string, password []byte,
+	amt wallet.Balance, fee uint64, dest, change cipher.Address, sel visor.CoinSelector) (coin.Transaction, error) {
+
+	if change == (cipher.Address{}) {
+		var err error
+		if change, err = wrpc.changeAddress(walletID, password); err != nil {
+			return coin.Transaction{}, err
+		}
+	}
+
+	wallet, err := wrpc.signingWallet(walletID, password)
+	if err != nil {
+		return coin.Transaction{}, err
+	}
+
+	signer, err := wallet.Signer()
+	if err != nil {
+		return coin.Transaction{}, err
+	}
 
-	wallet, ok := wrpc.Wallets.Get(walletID)
+	return gateway.CreateSpendingTransaction(*wallet, amt, dest, change, sel, signer)
+}
+
+// Sweep moves all the coins of the secret key to the wallet, e.g. to redeem a paper wallet.
+// The coins are sent to dest, it must be an address of the wallet, or the first address of
+// the wallet if it's not set. The key is not added to the wallet.
+func Sweep(gateway *daemon.Gateway, wrpc *WalletRPC, walletID string,
+	sec cipher.SecKey, dest cipher.Address) *SpendResult {
+	wlt, ok := wrpc.Wallets.Get(walletID)
 	if !ok {
-		return coin.Transaction{}, fmt.Errorf("Unknown wallet %v", walletID)
+		return &SpendResult{Error: fmt.Sprintf("wallet id %s does not exist", walletID)}
+	}
+
+	if dest == (cipher.Address{}) {
+		addrs := wlt.GetAddresses()
+		if len(addrs) == 0 {
+			return &SpendResult{Error: "the wallet has no addresses"}
+		}
+		dest = addrs[0]
+	} else if _, ok := wlt.GetEntry(dest); !ok {
+		return &SpendResult{Error: fmt.Sprintf("address %s is not in the wallet", dest)}
+	}
+
+	txn, err := gateway.CreateSweepTransaction(sec, dest)
+	if err != nil {
+		return &SpendResult{Error: err.Error()}
 	}
 
-	return gateway.CreateSpendingTransaction(wallet, amt, dest)
+	txn, err = gateway.InjectTransaction(txn)
+	if err != nil {
+		logger.Error("Inject sweep transaction failed: %v", err)
+		return &SpendResult{Error: err.Error()}
+	}
+
+	b, err := wrpc.GetWalletBalance(gateway, walletID)
+	if err != nil {
+		logger.Error("Get wallet balance failed: %v", err)
+	}
+
+	return &SpendResult{
+		Balance:     b,
+		Transaction: visor.NewReadableTransaction(&visor.Transaction{Txn: txn}),
+	}
 }
 
 /*
@@ -317,39 +591,94 @@ func walletBalanceHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		id := r.FormValue("id")
 		r.ParseForm()
-		b, err := Wg.GetWalletBalance(gateway, id)
+		if id == "" {
+			wh.Error400(w, "wallet id is empty")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
 
+		b, err := Wg.GetWalletBalance(gateway, id)
 		if err != nil {
-			_ = err
+			logger.Error("Get wallet balance failed: %v", err)
+			wh.Error500(w)
+			return
 		}
-		wh.SendOr404(w, b)
+		wh.SendOr404(w, encodeOptions(r).BalancePair(b))
 	}
 }
 
 func getBalanceHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == "GET" {
-			addrsParam := r.URL.Query().Get("addrs")
-			addrsStr := strings.Split(addrsParam, ",")
-			addrs := make([]cipher.Address, 0, len(addrsStr))
-			for _, addr := range addrsStr {
-				a, err := cipher.DecodeBase58Address(addr)
-				if err != nil {
-					wh.Error400(w, fmt.Sprintf("address %s is invalid: %v", addr, err))
-					return
-				}
-				addrs = append(addrs, a)
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		addrsParam := r.URL.Query().Get("addrs")
+		if addrsParam == "" {
+			wh.Error400(w, "addrs is empty")
+			return
+		}
+
+		addrsStr := strings.Split(addrsParam, ",")
+		addrs := make([]cipher.Address, 0, len(addrsStr))
+		for _, addr := range addrsStr {
+			a, err := cipher.DecodeBase58Address(strings.TrimSpace(addr))
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("address %s is invalid: %v", addr, err))
+				return
 			}
+			addrs = append(addrs, a)
+		}
+
+		bal, err := gateway.AddressesBalance(addrs)
+		if err != nil {
+			logger.Error("getBalanceHandler failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, encodeOptions(r).BalancePair(bal))
+	}
+}
+
+// getBalancesHandler returns the confirmed and predicted balance of each address
+func getBalancesHandler(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		addrsParam := r.FormValue("addrs")
+		if addrsParam == "" {
+			wh.Error400(w, "addrs is empty")
+			return
+		}
 
-			bal, err := gateway.AddressesBalance(addrs)
+		addrsStr := strings.Split(addrsParam, ",")
+		addrs := make([]cipher.Address, 0, len(addrsStr))
+		for _, addr := range addrsStr {
+			a, err := cipher.DecodeBase58Address(addr)
 			if err != nil {
-				logger.Error("getBalanceHandler failed: %v", err)
-				wh.Error500(w)
+				wh.Error400(w, fmt.Sprintf("address %s is invalid: %v", addr, err))
 				return
 			}
+			addrs = append(addrs, a)
+		}
 
-			wh.SendOr404(w, bal)
+		bals, err := gateway.GetBalances(addrs)
+		if err != nil {
+			logger.Error("getBalancesHandler failed: %v", err)
+			wh.Error500(w)
+			return
 		}
+
+		wh.SendOr404(w, visor.NewReadableBalances(bals))
 	}
 }
 
@@ -393,106 +722,312 @@ func walletSpendHandler(gateway *daemon.Gateway) http.HandlerFunc {
 		var hours uint64
 		var fee uint64 //doesnt work/do anything right now
 
+		sel, err := visor.GetCoinSelector(r.FormValue("selection"))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		var change cipher.Address
+		if s := r.FormValue("change"); s != "" {
+			change, err = cipher.DecodeBase58Address(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("Invalid change address: %v", err))
+				return
+			}
+		}
+
 		//MOVE THIS INTO HERE
-		ret := Spend(gateway, Wg, walletID, wallet.NewBalance(coins, hours), fee, dst)
+		password := []byte(r.FormValue("password"))
+		ret := Spend(gateway, Wg, walletID, password, wallet.NewBalance(coins, hours), fee, dst, change, sel)
+
+		// the policy violation is returned in json, so the rule can be told
+		if ret.PolicyError != nil {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusForbidden)
+			if err := json.NewEncoder(w).Encode(ret); err != nil {
+				logger.Error("Send policy error failed: %v", err)
+			}
+			return
+		}
 
 		if ret.Error != "" {
 			wh.Error400(w, fmt.Sprintf("Spend Failed: %s", ret.Error))
 			return
 		}
+
+		if a := annotationForm(r); !a.IsEmpty() {
+			txid, err := cipher.SHA256FromHex(ret.Transaction.Hash)
+			if err == nil {
+				err = Wg.AnnotateTxn(walletID, txid, a)
+			}
+			// the coins are sent already, so it's not an error of the spend
+			if err != nil {
+				logger.Error("Annotate transaction %s failed: %v", ret.Transaction.Hash, err)
+			} else {
+				ret.Annotation = &a
+			}
+		}
 		wh.SendOr404(w, ret)
 	}
 }
 
-// Create a wallet Name is set by creation date
-func notesCreate(gateway *daemon.Gateway) http.HandlerFunc {
+// walletSweep moves all the coins of the secret key to the wallet, the key is not added to
+// the wallet
+// method: POST
+// url: /wallet/sweep
+// params: id, key (hex secret key), dst
+func walletSweep(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		logger.Info("API request made to create a note")
-		note := r.FormValue("note")
-		transactionID := r.FormValue("transaction_id")
-		newNote := wallet.Note{
-			TxID:  transactionID,
-			Value: note,
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
 		}
-		Ng.Notes.SaveNote(Ng.WalletDirectory, newNote)
-		rlt := Ng.GetNotesReadable()
-		wh.SendOr500(w, rlt)
-	}
-}
 
-// Create a wallet Name is set by creation date
-func walletCreate(gateway *daemon.Gateway) http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		logger.Info("API request made to create a wallet")
-		seed := r.FormValue("seed")
-		label := r.FormValue("label")
-		wltName := wallet.NewWalletFilename()
-		var wlt wallet.Wallet
-		var err error
-		// the wallet name may dup, rename it till no conflict.
-		for {
-			wlt, err = Wg.CreateWallet(wltName, wallet.OptSeed(seed), wallet.OptLabel(label))
-			if err != nil {
-				if strings.Contains(err.Error(), "renaming") {
-					wltName = wallet.NewWalletFilename()
-					continue
-				}
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
 
-				wh.Error400(w, err.Error())
+		sec, err := cipher.SecKeyFromHex(r.FormValue("key"))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+		if err := sec.Verify(); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		var dst cipher.Address
+		if s := r.FormValue("dst"); s != "" {
+			dst, err = cipher.DecodeBase58Address(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("Invalid destination address: %v", err))
 				return
 			}
-			break
 		}
 
-		if err := Wg.SaveWallet(wlt.GetID()); err != nil {
-			wh.Error400(w, err.Error())
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
 			return
 		}
 
-		rlt := wallet.NewReadableWallet(wlt)
-		wh.SendOr500(w, rlt)
+		ret := Sweep(gateway, Wg, id, sec, dst)
+		if ret.Error != "" {
+			wh.Error400(w, fmt.Sprintf("Sweep Failed: %s", ret.Error))
+			return
+		}
+		wh.SendOr404(w, ret)
 	}
 }
 
+// walletSpendPreview shows the inputs, the outputs, the hours burned, the change and the
+// balance after the spend, with the same params as the spend. Nothing is signed, injected
+// or saved, no password is needed.
 // method: POST
-// url: /wallet/newAddress
-// params:
-// 		id: wallet id
-// 	   num: number of address need to create, if not set the default value is 1
-func walletNewAddresses(gateway *daemon.Gateway) http.HandlerFunc {
+// url: /wallet/spend/preview
+// params: id, dst, coins, selection, change
+func walletSpendPreview(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method != "POST" {
 			wh.Error405(w, "")
 			return
 		}
 
-		wltID := r.FormValue("id")
-		if wltID == "" {
-			wh.Error400(w, "wallet id not set")
+		walletID := r.FormValue("id")
+		if walletID == "" {
+			wh.Error400(w, "Missing wallet_id")
 			return
 		}
 
-		// the number of address that need to create, default is 1
-		n := 1
-		var err error
-		num := r.FormValue("num")
-		if num != "" {
-			n, err = strconv.Atoi(num)
-			if err != nil {
-				wh.Error400(w, "invalid num value")
-				return
-			}
+		dst, err := cipher.DecodeBase58Address(r.FormValue("dst"))
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("Invalid destination address: %v", err))
+			return
 		}
 
-		addrs, err := Wg.NewAddresses(wltID, n)
+		coins, err := strconv.ParseUint(r.FormValue("coins"), 10, 64)
 		if err != nil {
-			wh.Error400(w, err.Error())
+			wh.Error400(w, "Invalid \"coins\" value")
 			return
 		}
 
-		if err := Wg.SaveWallet(wltID); err != nil {
-			logger.Error("save wallet failed when generate new addresses: %v", err)
-			wh.Error500(w)
+		sel, err := visor.GetCoinSelector(r.FormValue("selection"))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		var change cipher.Address
+		if s := r.FormValue("change"); s != "" {
+			change, err = cipher.DecodeBase58Address(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("Invalid change address: %v", err))
+				return
+			}
+		}
+
+		wlt := Wg.GetWallet(walletID)
+		if wlt == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", walletID))
+			return
+		}
+
+		pv, err := gateway.PreviewSpend(wlt.GetAddresses(), wallet.NewBalance(coins, 0), dst, change, nil, sel)
+		if err != nil {
+			wh.Error400(w, fmt.Sprintf("Preview Failed: %v", err))
+			return
+		}
+
+		if change == (cipher.Address{}) && wlt.FreshChange() {
+			pv.SetNewChangeAddress()
+		}
+
+		wh.SendOr404(w, pv)
+	}
+}
+
+// Create a wallet Name is set by creation date
+func notesCreate(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger.Info("API request made to create a note")
+		note := r.FormValue("note")
+		transactionID := r.FormValue("transaction_id")
+		newNote := wallet.Note{
+			TxID:  transactionID,
+			Value: note,
+		}
+		Ng.Notes.SaveNote(Ng.WalletDirectory, newNote)
+		rlt := Ng.GetNotesReadable()
+		wh.SendOr500(w, rlt)
+	}
+}
+
+// Create a wallet Name is set by creation date
+func walletCreate(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		logger.Info("API request made to create a wallet")
+		seed := r.FormValue("seed")
+		label := r.FormValue("label")
+		opts := []wallet.Option{wallet.OptSeed(seed), wallet.OptLabel(label)}
+
+		switch r.FormValue("type") {
+		case "", wallet.DeterministicWalletType:
+		case wallet.HDWalletType:
+			var account uint64
+			if s := r.FormValue("account"); s != "" {
+				var err error
+				account, err = strconv.ParseUint(s, 10, 31)
+				if err != nil {
+					wh.Error400(w, "invalid account")
+					return
+				}
+			}
+			opts = append(opts, wallet.OptHD(uint32(account)))
+		default:
+			wh.Error400(w, "invalid wallet type")
+			return
+		}
+
+		var scan int
+		if s := r.FormValue("scan"); s != "" {
+			var err error
+			scan, err = strconv.Atoi(s)
+			if err != nil || scan < 0 {
+				wh.Error400(w, "invalid scan")
+				return
+			}
+		}
+
+		wltName := wallet.NewWalletFilename()
+		var wlt wallet.Wallet
+		var err error
+		// the wallet name may dup, rename it till no conflict.
+		for {
+			wlt, err = Wg.CreateWallet(wltName, opts...)
+			if err != nil {
+				if strings.Contains(err.Error(), "renaming") {
+					wltName = wallet.NewWalletFilename()
+					continue
+				}
+
+				wh.Error400(w, err.Error())
+				return
+			}
+			break
+		}
+
+		if err := Wg.SaveWallet(wlt.GetID()); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		// finds the funded addresses of the recovered wallet
+		if scan > 0 {
+			if _, err := Wg.ScanAddresses(gateway, wlt.GetID(), scan, nil); err != nil {
+				wh.Error500(w, err.Error())
+				return
+			}
+			wlt = *Wg.GetWallet(wlt.GetID())
+		}
+
+		rlt := wallet.NewReadableWallet(wlt)
+		wh.SendOr500(w, rlt)
+	}
+}
+
+// method: POST
+// url: /wallet/newAddress
+// params:
+// 		id: wallet id
+// 	   num: number of address need to create, if not set the default value is 1
+// 	change: creates the change addresses of the HD wallet if true
+// password: password of the encrypted wallet, not needed if it's unlocked
+func walletNewAddresses(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		wltID := r.FormValue("id")
+		if wltID == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		// the number of address that need to create, default is 1
+		n := 1
+		var err error
+		num := r.FormValue("num")
+		if num != "" {
+			n, err = strconv.Atoi(num)
+			if err != nil {
+				wh.Error400(w, "invalid num value")
+				return
+			}
+		}
+
+		var change bool
+		if s := r.FormValue("change"); s != "" {
+			change, err = strconv.ParseBool(s)
+			if err != nil {
+				wh.Error400(w, "invalid change value")
+				return
+			}
+		}
+
+		password := []byte(r.FormValue("password"))
+		var addrs []cipher.Address
+		if change {
+			addrs, err = Wg.NewChangeAddresses(wltID, n, password)
+		} else {
+			addrs, err = Wg.NewAddresses(wltID, n, password)
+		}
+		if err != nil {
+			wh.Error400(w, err.Error())
 			return
 		}
 
@@ -509,6 +1044,131 @@ func walletNewAddresses(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// walletScan generates the addresses of the wallet until gap consecutive ones are unused,
+// the ones up to the last used address are kept
+// method: POST
+// url: /wallet/scan
+// params:
+// 		id: wallet id
+// 	   gap: number of consecutive unused addresses to stop at, default is 20
+// password: password of the encrypted wallet, not needed if it's unlocked
+func walletScan(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		wltID := r.FormValue("id")
+		if wltID == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		if Wg.GetWallet(wltID) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", wltID))
+			return
+		}
+
+		gap := wallet.DefaultScanGap
+		if s := r.FormValue("gap"); s != "" {
+			var err error
+			gap, err = strconv.Atoi(s)
+			if err != nil || gap <= 0 {
+				wh.Error400(w, "invalid gap")
+				return
+			}
+		}
+
+		addrs, err := Wg.ScanAddresses(gateway, wltID, gap, []byte(r.FormValue("password")))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		rlt := struct {
+			Addresses []string `json:"addresses"`
+		}{
+			Addresses: []string{},
+		}
+		for _, a := range addrs {
+			rlt.Addresses = append(rlt.Addresses, a.String())
+		}
+
+		wh.SendOr404(w, rlt)
+	}
+}
+
+// walletXPub returns the extended public key of the HD wallet account and its path
+// method: GET
+// url: /wallet/xpub
+// params: id, the wallet id, and the password if the wallet is encrypted and locked
+func walletXPub(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		wlt, _, err := Wg.plainWallet(id, []byte(r.FormValue("password")))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		xpub, path, err := wlt.XPub()
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, map[string]string{"xpub": xpub, "path": path})
+	}
+}
+
+// walletMigrate converts the deterministic wallet to HD, the wallet file is backed up
+// to the backup folder of the wallet directory first
+// method: POST
+// url: /wallet/migrate
+// params: id, the wallet id
+func walletMigrate(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		if err := Wg.MigrateWallet(id); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, Wg.GetWalletReadable(id))
+	}
+}
+
 // Update wallet label
 func walletUpdateHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -543,6 +1203,168 @@ func walletUpdateHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// walletSigner sets the external signer of the wallet spends, e.g. a hardware wallet
+// bridge, the empty signer removes it and the keys of the wallet sign the spends
+// method: POST
+// url: /wallet/signer
+// params: id, signer (unix:<path> or tcp:<host:port>)
+func walletSigner(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id is empty")
+			return
+		}
+
+		signer := r.FormValue("signer")
+		if signer != "" {
+			if _, err := wallet.ParseSocketSigner(signer); err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		if err := Wg.SetSigner(id, signer); err != nil {
+			logger.Error("Failed to save wallet %v: %v", id, err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, struct {
+			Signer string `json:"signer"`
+		}{signer})
+	}
+}
+
+// walletPolicy returns the spending policy of the wallet by GET, or sets it by POST, the
+// spends violating it are rejected before they're signed. The zero limits and the empty
+// allowlist are not enforced.
+// method: GET, POST
+// url: /wallet/policy
+// params: id, max_coins_per_txn, max_coins_per_day (in droplets), allowed_destinations
+// (comma separated addresses)
+func walletPolicy(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id is empty")
+			return
+		}
+
+		wlt := Wg.GetWallet(id)
+		if wlt == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		if r.Method == "GET" {
+			p, err := wlt.Policy()
+			if err != nil {
+				wh.Error500(w, err.Error())
+				return
+			}
+			wh.SendOr404(w, p)
+			return
+		}
+
+		var p wallet.Policy
+		for _, k := range []struct {
+			name string
+			v    *uint64
+		}{
+			{"max_coins_per_txn", &p.MaxCoinsPerTxn},
+			{"max_coins_per_day", &p.MaxCoinsPerDay},
+		} {
+			s := r.FormValue(k.name)
+			if s == "" {
+				continue
+			}
+
+			n, err := strconv.ParseUint(s, 10, 64)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid %s", k.name))
+				return
+			}
+			*k.v = n
+		}
+
+		for _, s := range strings.Split(r.FormValue("allowed_destinations"), ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				p.AllowedDestinations = append(p.AllowedDestinations, s)
+			}
+		}
+
+		if err := p.Validate(); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		if err := Wg.SetPolicy(id, p); err != nil {
+			logger.Error("Failed to save wallet %v: %v", id, err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, p)
+	}
+}
+
+// walletFreshChange sets whether the change of the wallet spends is sent to new addresses,
+// or back to the spent address
+// method: POST
+// url: /wallet/freshChange
+// params: id, fresh
+func walletFreshChange(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id is empty")
+			return
+		}
+
+		fresh, err := strconv.ParseBool(r.FormValue("fresh"))
+		if err != nil {
+			wh.Error400(w, "invalid fresh value")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		if err := Wg.SetFreshChange(id, fresh); err != nil {
+			logger.Error("Failed to save wallet %v: %v", id, err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, struct {
+			Fresh bool `json:"fresh"`
+		}{fresh})
+	}
+}
+
 // Returns a wallet by ID if GET.  Creates or updates a wallet if POST.
 func walletGet(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -566,7 +1388,12 @@ func notesHandler(gateway *daemon.Gateway) http.HandlerFunc {
 func walletTransactionsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == "GET" {
-			wallet := Wg.GetWallet(r.FormValue("id"))
+			id := r.FormValue("id")
+			wallet := Wg.GetWallet(id)
+			if wallet == nil {
+				wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+				return
+			}
 			addresses := wallet.GetAddresses()
 			ret := gateway.GetUnconfirmedTxns(addresses)
 
@@ -575,6 +1402,83 @@ func walletTransactionsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// walletLoad loads the wallet file in the wallet directory
+// method: POST
+// url: /wallet/load
+// params: id, the wallet file name
+func walletLoad(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id is empty")
+			return
+		}
+
+		wlt, err := Wg.LoadWallet(id)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, wallet.NewReadableWallet(wlt))
+	}
+}
+
+// walletUnload removes the wallet from the node, the wallet file is kept
+// method: POST
+// url: /wallet/unload
+// params: id
+func walletUnload(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id is empty")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		if err := Wg.UnloadWallet(id); err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+	}
+}
+
+// walletsList returns the summaries of the loaded wallets and the wallet files not loaded
+// method: GET
+// url: /wallets/list
+func walletsList(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		infos, err := Wg.ListWallets()
+		if err != nil {
+			logger.Error("list wallets failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, infos)
+	}
+}
+
 // Returns all loaded wallets
 func walletsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
@@ -675,6 +1579,29 @@ func getOutputsHandler(gateway *daemon.Gateway) http.HandlerFunc {
 	}
 }
 
+// newPaperWallet generates an address with its seed and keys to print, with the payloads
+// of the QR codes. Nothing is saved, the secret is only in the response.
+// method: POST
+// url: /wallet/paper
+// params: seed, optional, a bip39 mnemonic is generated if not set
+func newPaperWallet(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		pw, err := wallet.NewPaperWallet(r.FormValue("seed"))
+		if err != nil {
+			logger.Error("new paper wallet failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		wh.SendOr404(w, pw.ToReadable())
+	}
+}
+
 func newWalletSeed(gateway *daemon.Gateway) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
 		entropy, err := bip39.NewEntropy(128)
@@ -702,7 +1629,7 @@ func newWalletSeed(gateway *daemon.Gateway) http.HandlerFunc {
 }
 
 // RegisterWalletHandlers registers wallet handlers
-func RegisterWalletHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
+func RegisterWalletHandlers(mux Mux, gateway *daemon.Gateway) {
 	// Returns wallet info
 	// GET Arguments:
 	//      id - Wallet ID.
@@ -712,11 +1639,48 @@ func RegisterWalletHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 
 	// POST/GET Arguments:
 	//		seed [optional]
+	//		type [optional] - deterministic or bip44
+	//		account [optional] - account of the bip44 wallet
+	//		scan [optional] - gap of unused addresses to scan the recovered wallet for
 	//create new wallet
 	mux.HandleFunc("/wallet/create", walletCreate(gateway))
 
 	mux.HandleFunc("/wallet/newAddress", walletNewAddresses(gateway))
 
+	// Generates the addresses till a gap of unused ones, to find the funded addresses
+	// 		POST Arguments:
+	// 			id: wallet id
+	// 			gap: number of consecutive unused addresses, default is 20
+	// 			password: password of the encrypted wallet
+	mux.HandleFunc("/wallet/scan", walletScan(gateway))
+
+	// Returns the extended public key of the HD wallet account
+	mux.HandleFunc("/wallet/xpub", walletXPub(gateway))
+
+	// Converts the deterministic wallet to HD
+	mux.HandleFunc("/wallet/migrate", walletMigrate(gateway))
+
+	// Encrypts the seed and the secret keys of the wallet with the password
+	mux.HandleFunc("/wallet/encrypt", walletEncrypt(gateway))
+	// Removes the encryption of the wallet
+	mux.HandleFunc("/wallet/decrypt", walletDecrypt(gateway))
+	// Keeps the decrypted wallet in memory till the timeout
+	mux.HandleFunc("/wallet/unlock", walletUnlock(gateway))
+	// Removes the decrypted wallet from memory
+	mux.HandleFunc("/wallet/lock", walletLock(gateway))
+	// Returns the seed, the password of the encrypted wallet is required
+	mux.HandleFunc("/wallet/seed", walletSeed(gateway))
+
+	// Lists the multisig addresses of the wallet
+	mux.HandleFunc("/wallet/multisig", walletMultisigs(gateway))
+	// Adds an m-of-n multisig address to the wallet
+	mux.HandleFunc("/wallet/multisig/create", walletCreateMultisig(gateway))
+
+	// Returns the labels, categories and notes of the addresses and the transactions
+	mux.HandleFunc("/wallet/annotations", walletAnnotations(gateway))
+	// Sets the label, category and note of an address or a transaction
+	mux.HandleFunc("/wallet/annotate", walletAnnotate(gateway))
+
 	// Returns the confirmed and predicted balance for a specific wallet.
 	// The predicted balance is the confirmed balance minus any pending
 	// spent amount.
@@ -730,23 +1694,67 @@ func RegisterWalletHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	//  coins: Number of coins to spend
 	//  hours: Number of hours to spends
 	//  fee: Number of hours to use as fee, on top of the default fee.
+	//  password: Password of the encrypted wallet, not needed if it's unlocked
+	//  selection: Coin selection strategy, min_hours (default), min_inputs, oldest or random
+	//  change: Change address, by default a new address if the wallet has fresh change on,
+	//  otherwise the spent address
 	//  Returns total amount spent if successful, otherwise error describing
 	//  failure status.
-	mux.HandleFunc("/wallet/spend", walletSpendHandler(gateway))
+	mux.HandleFunc("/wallet/spend", idempotent(walletSpendHandler(gateway)))
+
+	// Previews the spend with the same arguments, returns the inputs, the outputs, the
+	// hours burned, the change and the balance after the spend. Nothing is signed.
+	mux.HandleFunc("/wallet/spend/preview", walletSpendPreview(gateway))
+
+	// Sends all the coins of a secret key, e.g. of a paper wallet, to the wallet
+	mux.HandleFunc("/wallet/sweep", idempotent(walletSweep(gateway)))
 
 	// GET Arguments:
 	//		id: Wallet ID
 	// Returns all pending transanction for all addresses by selected Wallet
 	mux.HandleFunc("/wallet/transactions", walletTransactionsHandler(gateway))
 
+	// Returns the confirmed and unconfirmed transactions of the wallet with the running balance
+	// 		GET Arguments:
+	// 			id: wallet id
+	// 			format: json or csv, defaults to json
+	mux.HandleFunc("/wallet/history", walletHistory(gateway))
+
 	// Update wallet label
 	// 		GET Arguments:
 	// 			id: wallet id
 	// 			label: wallet label
 	mux.HandleFunc("/wallet/update", walletUpdateHandler(gateway))
 
+	// Sets whether the change of the spends goes to new addresses
+	// 		POST Arguments:
+	// 			id: wallet id
+	// 			fresh: true or false
+	mux.HandleFunc("/wallet/freshChange", walletFreshChange(gateway))
+
+	// Sets the external signer of the spends
+	// 		POST Arguments:
+	// 			id: wallet id
+	// 			signer: unix:<path> or tcp:<host:port>, empty to remove it
+	mux.HandleFunc("/wallet/signer", walletSigner(gateway))
+
+	// Returns or sets the spending policy of the wallet
+	// 		GET/POST Arguments:
+	// 			id: wallet id
+	// 			max_coins_per_txn: the coins sent by a spend, in droplets
+	// 			max_coins_per_day: the coins sent in the past 24 hours, in droplets
+	// 			allowed_destinations: comma separated addresses the coins can be sent to
+	mux.HandleFunc("/wallet/policy", walletPolicy(gateway))
+
+	// Loads the wallet file in the wallet directory
+	mux.HandleFunc("/wallet/load", walletLoad(gateway))
+	// Removes the wallet from the node, the file is kept
+	mux.HandleFunc("/wallet/unload", walletUnload(gateway))
+
 	// Returns all loaded wallets
 	mux.HandleFunc("/wallets", walletsHandler(gateway))
+	// Returns the summaries of the loaded wallets and the wallet files not loaded
+	mux.HandleFunc("/wallets/list", walletsList(gateway))
 	// Saves all wallets to disk. Returns nothing if it works. Otherwise returns
 	// 500 status with error message.
 
@@ -764,9 +1772,15 @@ func RegisterWalletHandlers(mux *http.ServeMux, gateway *daemon.Gateway) {
 	// get balance of addresses
 	mux.HandleFunc("/balance", getBalanceHandler(gateway))
 
+	// get the balance of each address, in one request
+	mux.HandleFunc("/balances", getBalancesHandler(gateway))
+
 	// generate wallet seed
 	mux.Handle("/wallet/newSeed", newWalletSeed(gateway))
 
+	// generate paper wallet, nothing is saved
+	mux.Handle("/wallet/paper", newPaperWallet(gateway))
+
 	// generate wallet seed
 	mux.Handle("/notes", notesHandler(gateway))
 
@@ -0,0 +1,143 @@
+package gui
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// AnnotateAddress sets the label, category and note of the address of the wallet and saves it
+func (wrpc *WalletRPC) AnnotateAddress(wltID string, addr cipher.Address, a wallet.Annotation) error {
+	if err := wrpc.Wallets.SetAddressAnnotation(wltID, addr, a); err != nil {
+		return err
+	}
+	return wrpc.saveAnnotations(wltID)
+}
+
+// AnnotateTxn sets the label, category and note of the transaction of the wallet and saves it
+func (wrpc *WalletRPC) AnnotateTxn(wltID string, txid cipher.SHA256, a wallet.Annotation) error {
+	if err := wrpc.Wallets.SetTxnAnnotation(wltID, txid, a); err != nil {
+		return err
+	}
+	return wrpc.saveAnnotations(wltID)
+}
+
+func (wrpc *WalletRPC) saveAnnotations(wltID string) error {
+	// the unlocked copy is encrypted again when the addresses are generated
+	wrpc.unlocked.Update(wltID, func(u *wallet.Wallet) {
+		for _, k := range []string{"addressAnnotations", "txnAnnotations"} {
+			if v, ok := wrpc.Wallets[wltID].Meta[k]; ok {
+				u.Meta[k] = v
+			} else {
+				delete(u.Meta, k)
+			}
+		}
+	})
+
+	return wrpc.SaveWallet(wltID)
+}
+
+// annotationForm returns the label, category and note params
+func annotationForm(r *http.Request) wallet.Annotation {
+	return wallet.Annotation{
+		Label:    r.FormValue("label"),
+		Category: r.FormValue("category"),
+		Note:     r.FormValue("note"),
+	}
+}
+
+// walletAnnotations returns the labels, categories and notes of the addresses and the
+// transactions of the wallet
+// method: GET
+// url: /wallet/annotations
+// params: id
+func walletAnnotations(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		wlt := Wg.GetWallet(id)
+		if wlt == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		ra, err := wlt.ReadableAnnotations()
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+		wh.SendOr404(w, ra)
+	}
+}
+
+// walletAnnotate sets the label, category and note of an address or a transaction of the
+// wallet, the empty values remove the annotation
+// method: POST
+// url: /wallet/annotate
+// params: id, address or txid, label, category, note
+func walletAnnotate(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		saddr, stxid := r.FormValue("address"), r.FormValue("txid")
+		if (saddr == "") == (stxid == "") {
+			wh.Error400(w, "either address or txid must be set")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		a := annotationForm(r)
+		if saddr != "" {
+			addr, err := cipher.DecodeBase58Address(saddr)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid address: %v", err))
+				return
+			}
+
+			if err := Wg.AnnotateAddress(id, addr, a); err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+		} else {
+			txid, err := cipher.SHA256FromHex(stxid)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid txid: %v", err))
+				return
+			}
+
+			if err := Wg.AnnotateTxn(id, txid, a); err != nil {
+				wh.Error400(w, err.Error())
+				return
+			}
+		}
+
+		wh.SendOr404(w, a)
+	}
+}
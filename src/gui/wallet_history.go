@@ -0,0 +1,77 @@
+package gui
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/visor"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// WalletHistoryEntry is the readable wallet history entry with the annotation of the transaction
+type WalletHistoryEntry struct {
+	visor.ReadableWalletHistoryEntry
+	Annotation *wallet.Annotation `json:"annotation,omitempty"`
+}
+
+// walletHistory returns the transactions affecting the addresses of the wallet, with the
+// direction, counterparties and running balance of each one
+// method: GET
+// url: /wallet/history?id=${id}&format=${format}
+// params: format, json by default, or csv
+func walletHistory(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		format := r.FormValue("format")
+		if format != "" && format != "json" && format != "csv" {
+			wh.Error400(w, "invalid format")
+			return
+		}
+
+		wlt := Wg.GetWallet(id)
+		if wlt == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		entries, err := gateway.GetWalletHistory(*wlt)
+		if err != nil {
+			logger.Error("Get wallet history failed: %v", err)
+			wh.Error500(w)
+			return
+		}
+
+		if format == "csv" {
+			setCSVHeader(w, fmt.Sprintf("%s.csv", id))
+			if err := visor.WriteWalletHistoryCSV(newFlushWriter(w), entries); err != nil {
+				logger.Error("Write wallet history failed: %v", err)
+			}
+			return
+		}
+
+		// the entries are streamed, the long histories aren't encoded in memory at once
+		err = encodeJSONArray(newFlushWriter(w), len(entries), func(i int) interface{} {
+			e := WalletHistoryEntry{ReadableWalletHistoryEntry: visor.NewReadableWalletHistoryEntry(entries[i])}
+			if a := wlt.GetTxnAnnotation(entries[i].TxID); !a.IsEmpty() {
+				e.Annotation = &a
+			}
+			return e
+		})
+		if err != nil {
+			logger.Error("Write wallet history failed: %v", err)
+		}
+	}
+}
@@ -0,0 +1,339 @@
+package gui
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// EncryptWallet encrypts the seed and the secret keys of the wallet with the password
+// and saves it
+func (wrpc *WalletRPC) EncryptWallet(wltID string, password []byte) error {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return fmt.Errorf("Unknown wallet %s", wltID)
+	}
+
+	e := w.Copy()
+	if err := e.Encrypt(password); err != nil {
+		return err
+	}
+
+	if err := e.Save(wrpc.WalletDirectory); err != nil {
+		return err
+	}
+
+	wrpc.Wallets[wltID] = e
+	return nil
+}
+
+// DecryptWallet removes the encryption of the wallet and saves it
+func (wrpc *WalletRPC) DecryptWallet(wltID string, password []byte) error {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return fmt.Errorf("Unknown wallet %s", wltID)
+	}
+
+	d, err := w.Decrypt(password)
+	if err != nil {
+		return err
+	}
+
+	if err := d.Save(wrpc.WalletDirectory); err != nil {
+		return err
+	}
+
+	wrpc.LockWallet(wltID)
+	wrpc.Wallets[wltID] = d
+	return nil
+}
+
+// UnlockWallet keeps the decrypted wallet in memory till the timeout, the wallet signs
+// the transactions and generates the addresses without the password till it's locked
+func (wrpc *WalletRPC) UnlockWallet(wltID string, password []byte, timeout time.Duration) error {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return fmt.Errorf("Unknown wallet %s", wltID)
+	}
+
+	d, err := w.Decrypt(password)
+	if err != nil {
+		return err
+	}
+	return wrpc.unlocked.Unlock(wltID, d, password, timeout)
+}
+
+// LockWallet removes the decrypted wallet from memory
+func (wrpc *WalletRPC) LockWallet(wltID string) {
+	wrpc.unlocked.Lock(wltID)
+}
+
+// IsUnlocked returns true if the decrypted wallet is in memory
+func (wrpc *WalletRPC) IsUnlocked(wltID string) bool {
+	return wrpc.unlocked.IsUnlocked(wltID)
+}
+
+// WalletSeed returns the seed of the wallet, the password of the encrypted wallet is
+// required even if it's unlocked
+func (wrpc *WalletRPC) WalletSeed(wltID string, password []byte) (string, error) {
+	w, ok := wrpc.Wallets.Get(wltID)
+	if !ok {
+		return "", fmt.Errorf("Unknown wallet %s", wltID)
+	}
+
+	if !w.IsEncrypted() {
+		return w.Meta["seed"], nil
+	}
+
+	d, err := w.Decrypt(password)
+	if err != nil {
+		return "", err
+	}
+	return d.Meta["seed"], nil
+}
+
+// plainWallet returns the wallet with the seed and the secret keys, the encrypted wallet
+// is decrypted by the password, or taken from memory if it's unlocked
+func (wrpc *WalletRPC) plainWallet(wltID string, password []byte) (*wallet.Wallet, []byte, error) {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return nil, nil, fmt.Errorf("Unknown wallet %s", wltID)
+	}
+
+	if !w.IsEncrypted() {
+		return w, nil, nil
+	}
+
+	if len(password) == 0 {
+		return wrpc.unlocked.Get(wltID)
+	}
+
+	d, err := w.Decrypt(password)
+	if err != nil {
+		return nil, nil, err
+	}
+	return d, password, nil
+}
+
+// signingWallet returns the wallet signing the spends, the plain wallet, or the wallet
+// as it is if the spends are signed by the external signer
+func (wrpc *WalletRPC) signingWallet(wltID string, password []byte) (*wallet.Wallet, error) {
+	w, ok := wrpc.Wallets[wltID]
+	if !ok {
+		return nil, fmt.Errorf("Unknown wallet %s", wltID)
+	}
+
+	if w.HasExternalSigner() {
+		return w.Copy(), nil
+	}
+
+	d, _, err := wrpc.plainWallet(wltID, password)
+	return d, err
+}
+
+// updateWallet runs f with the plain wallet, the encrypted wallet is encrypted again with
+// the password and saved
+func (wrpc *WalletRPC) updateWallet(wltID string, password []byte, f func(w *wallet.Wallet) ([]cipher.Address, error)) ([]cipher.Address, error) {
+	d, password, err := wrpc.plainWallet(wltID, password)
+	if err != nil {
+		return nil, err
+	}
+
+	addrs, err := f(d)
+	if err != nil {
+		return nil, err
+	}
+
+	if password == nil {
+		return addrs, wrpc.SaveWallet(wltID)
+	}
+
+	e := d.Copy()
+	if err := e.Encrypt(password); err != nil {
+		return nil, err
+	}
+
+	if err := e.Save(wrpc.WalletDirectory); err != nil {
+		return nil, err
+	}
+	wrpc.Wallets[wltID] = e
+
+	wrpc.unlocked.Update(wltID, func(u *wallet.Wallet) {
+		*u = *d.Copy()
+	})
+
+	return addrs, nil
+}
+
+// walletEncrypt encrypts the seed and the secret keys of the wallet
+// method: POST
+// url: /wallet/encrypt
+// params: id, password
+func walletEncrypt(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		if err := Wg.EncryptWallet(id, []byte(r.FormValue("password"))); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, Wg.GetWalletReadable(id))
+	}
+}
+
+// walletDecrypt removes the encryption of the wallet
+// method: POST
+// url: /wallet/decrypt
+// params: id, password
+func walletDecrypt(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		if err := Wg.DecryptWallet(id, []byte(r.FormValue("password"))); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, Wg.GetWalletReadable(id))
+	}
+}
+
+// walletUnlock keeps the decrypted wallet in memory till the timeout
+// method: POST
+// url: /wallet/unlock
+// params: id, password, timeout in seconds (default 300)
+func walletUnlock(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		timeout := wallet.DefaultUnlockTimeout
+		if s := r.FormValue("timeout"); s != "" {
+			n, err := strconv.ParseUint(s, 10, 32)
+			if err != nil {
+				wh.Error400(w, "invalid timeout")
+				return
+			}
+			timeout = time.Duration(n) * time.Second
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		if err := Wg.UnlockWallet(id, []byte(r.FormValue("password")), timeout); err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, struct {
+			Unlocked  bool  `json:"unlocked"`
+			ExpiresAt int64 `json:"expires_at"`
+		}{true, time.Now().Add(timeout).Unix()})
+	}
+}
+
+// walletLock removes the decrypted wallet from memory
+// method: POST
+// url: /wallet/lock
+// params: id
+func walletLock(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		Wg.LockWallet(id)
+		wh.SendOr404(w, map[string]bool{"unlocked": false})
+	}
+}
+
+// walletSeed returns the seed of the wallet, the password of the encrypted wallet is
+// required even if it's unlocked
+// method: POST
+// url: /wallet/seed
+// params: id, password
+func walletSeed(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		seed, err := Wg.WalletSeed(id, []byte(r.FormValue("password")))
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, map[string]string{"seed": seed})
+	}
+}
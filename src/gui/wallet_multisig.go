@@ -0,0 +1,120 @@
+package gui
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+)
+
+// AddMultisig adds the multisig address of required signatures of the keys to the wallet
+// and saves it
+func (wrpc *WalletRPC) AddMultisig(wltID string, required int, pubKeys []cipher.PubKey) (wallet.Multisig, error) {
+	ms, err := wrpc.Wallets.AddMultisig(wltID, required, pubKeys)
+	if err != nil {
+		return wallet.Multisig{}, err
+	}
+
+	// the unlocked copy is encrypted again when the addresses are generated
+	wrpc.unlocked.Update(wltID, func(u *wallet.Wallet) {
+		u.Meta["multisig"] = wrpc.Wallets[wltID].Meta["multisig"]
+	})
+
+	return ms, wrpc.SaveWallet(wltID)
+}
+
+// walletMultisigs returns the multisig addresses of the wallet
+// method: GET
+// url: /wallet/multisig
+// params: id
+func walletMultisigs(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		wlt := Wg.GetWallet(id)
+		if wlt == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		mss, err := wlt.Multisigs()
+		if err != nil {
+			wh.Error500(w, err.Error())
+			return
+		}
+
+		rms := make([]wallet.ReadableMultisig, len(mss))
+		for i, ms := range mss {
+			rms[i] = wallet.NewReadableMultisig(ms)
+		}
+		wh.SendOr404(w, rms)
+	}
+}
+
+// walletCreateMultisig adds the m-of-n multisig address to the wallet, the public keys are
+// shared by the owners of the address
+// method: POST
+// url: /wallet/multisig/create
+// params: id, required, pubkeys
+func walletCreateMultisig(gateway *daemon.Gateway) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" {
+			wh.Error405(w, "")
+			return
+		}
+
+		id := r.FormValue("id")
+		if id == "" {
+			wh.Error400(w, "wallet id not set")
+			return
+		}
+
+		required, err := strconv.Atoi(r.FormValue("required"))
+		if err != nil {
+			wh.Error400(w, "invalid required")
+			return
+		}
+
+		var keys []cipher.PubKey
+		for _, s := range strings.Split(r.FormValue("pubkeys"), ",") {
+			if s = strings.TrimSpace(s); s == "" {
+				continue
+			}
+
+			k, err := cipher.PubKeyFromHex(s)
+			if err != nil {
+				wh.Error400(w, fmt.Sprintf("invalid public key %s: %v", s, err))
+				return
+			}
+			keys = append(keys, k)
+		}
+
+		if Wg.GetWallet(id) == nil {
+			wh.Error404(w, fmt.Sprintf("wallet of id: %v does not exist", id))
+			return
+		}
+
+		ms, err := Wg.AddMultisig(id, required, keys)
+		if err != nil {
+			wh.Error400(w, err.Error())
+			return
+		}
+
+		wh.SendOr404(w, wallet.NewReadableMultisig(ms))
+	}
+}
@@ -0,0 +1,324 @@
+package gui
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// Types of the wallet events
+const (
+	// the payment to the wallet is seen, in the unconfirmed pool or in a block
+	WalletEventIncoming = "incoming_payment"
+	// the payment to the wallet reaches the confirmations
+	WalletEventIncomingConfirmed = "payment_confirmed"
+	// the payment from the wallet reaches the confirmations
+	WalletEventOutgoingConfirmed = "outgoing_confirmed"
+)
+
+const (
+	// walletEventsBufSize is the buffer size of the subscription to the event bus
+	walletEventsBufSize = 1000
+	// DefaultWalletNotifyTimeout is the default time the notify command has to finish
+	DefaultWalletNotifyTimeout = time.Minute
+	// walletResolveBlocks is the number of blocks the confirmed txn is retried to be
+	// resolved in, before it's given up
+	walletResolveBlocks = 10
+)
+
+// WalletEvent is the notification of a payment of the wallet
+type WalletEvent struct {
+	Type     string `json:"type"`
+	WalletID string `json:"wallet"`
+	TxID     string `json:"txid"`
+	// the coins received, or sent to the counterparties
+	Coins          string   `json:"coins"`
+	Counterparties []string `json:"counterparties"`
+	// seq of the block confirming the txn, 0 if unconfirmed
+	BlockSeq      uint64 `json:"block_seq"`
+	Confirmations uint64 `json:"confirmations"`
+	Time          int64  `json:"time"`
+}
+
+// WalletListener receives the wallet events, it's called from the goroutine of the notifier
+// so it shouldn't block
+type WalletListener func(ev WalletEvent)
+
+// WalletNotifierConfig configures the WalletNotifier
+type WalletNotifierConfig struct {
+	// number of confirmations the payment is confirmed at, at least 1
+	Confirmations uint64
+	// the command run for each event, the event json is written to its stdin. %t, %w and %s
+	// in the arguments are replaced by the event type, the wallet id and the txid.
+	Command string
+	// the time the command has to finish before it's killed
+	CommandTimeout time.Duration
+}
+
+// NewWalletNotifierConfig returns a WalletNotifierConfig with defaults set
+func NewWalletNotifierConfig() WalletNotifierConfig {
+	return WalletNotifierConfig{
+		Confirmations:  1,
+		CommandTimeout: DefaultWalletNotifyTimeout,
+	}
+}
+
+// walletTxn is the txn affecting the wallets, being tracked until it's confirmed
+type walletTxn struct {
+	txn coin.Transaction
+	// the entries of the affected wallets, nil if they're not resolved yet
+	entries   map[string]visor.WalletHistoryEntry
+	confirmed bool
+	blockSeq  uint64
+}
+
+// WalletNotifier emits the wallet events of the loaded wallets, built on the events of the
+// visor event bus, to the registered listeners and the notify command
+type WalletNotifier struct {
+	Config    WalletNotifierConfig
+	gateway   *daemon.Gateway
+	listeners []WalletListener
+	// txns seen affecting the wallets or confirmed in the blocks, by txid
+	txns    map[cipher.SHA256]*walletTxn
+	headSeq uint64
+	unsub   func()
+	sync.Mutex
+}
+
+// NewWalletNotifier creates a WalletNotifier, Run starts it
+func NewWalletNotifier(gateway *daemon.Gateway, c WalletNotifierConfig) *WalletNotifier {
+	if c.Confirmations == 0 {
+		c.Confirmations = 1
+	}
+
+	return &WalletNotifier{
+		Config:  c,
+		gateway: gateway,
+		txns:    make(map[cipher.SHA256]*walletTxn),
+	}
+}
+
+// AddListener registers the listener of the wallet events
+func (wn *WalletNotifier) AddListener(l WalletListener) {
+	wn.Lock()
+	defer wn.Unlock()
+	wn.listeners = append(wn.listeners, l)
+}
+
+// Run processes the events of the event bus until Shutdown is called
+func (wn *WalletNotifier) Run() {
+	events, unsub := wn.gateway.SubscribeEvents(walletEventsBufSize,
+		visor.EventNewBlock,
+		visor.EventNewUnconfirmedTxn,
+		visor.EventTxnConfirmed,
+		visor.EventTxnDropped,
+		visor.EventReorg)
+
+	wn.Lock()
+	wn.unsub = unsub
+	wn.Unlock()
+
+	for ev := range events {
+		wn.handle(ev)
+	}
+}
+
+// Shutdown stops the notifier
+func (wn *WalletNotifier) Shutdown() {
+	wn.Lock()
+	unsub := wn.unsub
+	wn.Unlock()
+
+	if unsub != nil {
+		unsub()
+	}
+}
+
+func (wn *WalletNotifier) handle(ev visor.Event) {
+	switch ev.Type {
+	case visor.EventNewBlock:
+		wn.headSeq = ev.Block.Seq()
+		wn.checkConfirmations()
+
+	case visor.EventNewUnconfirmedTxn:
+		if _, ok := wn.txns[ev.TxID]; ok {
+			return
+		}
+
+		tx, err := wn.gateway.GetTransaction(ev.TxID)
+		if err != nil || tx == nil || !tx.Status.Unconfirmed {
+			return
+		}
+
+		wt := &walletTxn{txn: tx.Txn}
+		if err := wn.resolve(wt); err != nil {
+			logger.Error("Resolve wallets of txn %s failed: %v", ev.TxID.Hex(), err)
+			return
+		}
+		if len(wt.entries) > 0 {
+			wn.txns[ev.TxID] = wt
+		}
+
+	case visor.EventTxnConfirmed:
+		wt, ok := wn.txns[ev.TxID]
+		if !ok {
+			// the txn is not seen unconfirmed, it's resolved in checkConfirmations
+			for _, txn := range ev.Block.Body.Transactions {
+				if txn.Hash() == ev.TxID {
+					wt = &walletTxn{txn: txn}
+					wn.txns[ev.TxID] = wt
+					break
+				}
+			}
+		}
+
+		if wt != nil {
+			wt.confirmed = true
+			wt.blockSeq = ev.Block.Seq()
+			wn.checkConfirmations()
+		}
+
+	case visor.EventTxnDropped:
+		delete(wn.txns, ev.TxID)
+
+	case visor.EventReorg:
+		// the txns of the disconnected blocks wait to be confirmed again
+		for _, wt := range wn.txns {
+			if wt.confirmed && wt.blockSeq > ev.Reorg.ForkSeq {
+				wt.confirmed = false
+			}
+		}
+	}
+}
+
+// checkConfirmations emits the confirmed events of the txns reaching the confirmations.
+// The confirmed txns not resolved yet are resolved, the history db may not have indexed
+// the block yet, so they're retried on the next block if failed.
+func (wn *WalletNotifier) checkConfirmations() {
+	for txid, wt := range wn.txns {
+		if !wt.confirmed {
+			continue
+		}
+
+		if wt.entries == nil {
+			if err := wn.resolve(wt); err != nil {
+				if wn.headSeq >= wt.blockSeq+walletResolveBlocks {
+					logger.Error("Resolve wallets of txn %s failed: %v", txid.Hex(), err)
+					delete(wn.txns, txid)
+				}
+				continue
+			}
+		}
+
+		if len(wt.entries) == 0 {
+			delete(wn.txns, txid)
+			continue
+		}
+
+		if wn.headSeq < wt.blockSeq || wn.headSeq-wt.blockSeq+1 < wn.Config.Confirmations {
+			continue
+		}
+
+		for id, e := range wt.entries {
+			switch e.Direction {
+			case visor.DirectionIn:
+				wn.emit(WalletEventIncomingConfirmed, id, wt, e)
+			case visor.DirectionOut:
+				wn.emit(WalletEventOutgoingConfirmed, id, wt, e)
+			}
+		}
+		delete(wn.txns, txid)
+	}
+}
+
+// resolve finds the wallets affected by the txn, and emits the incoming payment events
+func (wn *WalletNotifier) resolve(wt *walletTxn) error {
+	entries := make(map[string]visor.WalletHistoryEntry)
+	for id, w := range Wg.Wallets {
+		e, err := wn.gateway.GetWalletTxn(wt.txn, wt.confirmed, w.GetAddresses())
+		if err != nil {
+			return err
+		}
+
+		if e != nil {
+			entries[id] = *e
+		}
+	}
+
+	wt.entries = entries
+	for id, e := range entries {
+		if e.Direction == visor.DirectionIn {
+			wn.emit(WalletEventIncoming, id, wt, e)
+		}
+	}
+	return nil
+}
+
+func (wn *WalletNotifier) emit(tp, walletID string, wt *walletTxn, e visor.WalletHistoryEntry) {
+	ev := WalletEvent{
+		Type:           tp,
+		WalletID:       walletID,
+		TxID:           e.TxID.Hex(),
+		Coins:          visor.StrBalance(e.Coins),
+		Counterparties: make([]string, len(e.Counterparties)),
+		Time:           time.Now().Unix(),
+	}
+	for i, a := range e.Counterparties {
+		ev.Counterparties[i] = a.String()
+	}
+	if wt.confirmed {
+		ev.BlockSeq = wt.blockSeq
+		if wn.headSeq >= wt.blockSeq {
+			ev.Confirmations = wn.headSeq - wt.blockSeq + 1
+		}
+	}
+
+	wn.Lock()
+	listeners := wn.listeners
+	wn.Unlock()
+
+	for _, l := range listeners {
+		l(ev)
+	}
+
+	if wn.Config.Command != "" {
+		go wn.runCommand(ev)
+	}
+}
+
+// runCommand runs the notify command of the event
+func (wn *WalletNotifier) runCommand(ev WalletEvent) {
+	args := strings.Fields(wn.Config.Command)
+	r := strings.NewReplacer("%t", ev.Type, "%w", ev.WalletID, "%s", ev.TxID)
+	for i := range args {
+		args[i] = r.Replace(args[i])
+	}
+
+	d, err := json.Marshal(ev)
+	if err != nil {
+		logger.Error("Encode wallet event failed: %v", err)
+		return
+	}
+
+	timeout := wn.Config.CommandTimeout
+	if timeout <= 0 {
+		timeout = DefaultWalletNotifyTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = bytes.NewReader(append(d, '\n'))
+	if out, err := cmd.CombinedOutput(); err != nil {
+		logger.Error("Wallet notify command of txn %s failed: %v, %s", ev.TxID, err, out)
+	}
+}
@@ -1,2 +1,78 @@
 package gui
 
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+func TestLoadUnloadWallets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// a default wallet is created in the empty directory
+	wrpc := NewWalletRPC(dir)
+	require.Len(t, wrpc.Wallets, 1)
+	var defaultID string
+	for id := range wrpc.Wallets {
+		defaultID = id
+	}
+
+	w, err := wallet.NewWallet("b.wlt", wallet.OptSeed("b seed"), wallet.OptLabel("exchange"))
+	require.NoError(t, err)
+	w.GenerateAddresses(2)
+	require.NoError(t, w.Save(dir))
+
+	infos, err := wrpc.ListWallets()
+	require.NoError(t, err)
+	require.Len(t, infos, 2)
+	assert.Equal(t, WalletInfo{ID: "b.wlt"}, infos[1])
+	assert.True(t, infos[0].Loaded)
+
+	loaded, err := wrpc.LoadWallet("b.wlt")
+	require.NoError(t, err)
+	assert.Equal(t, w.GetAddresses(), loaded.GetAddresses())
+	require.NotNil(t, wrpc.GetWallet("b.wlt"))
+
+	infos, err = wrpc.ListWallets()
+	require.NoError(t, err)
+	assert.Equal(t, WalletInfo{
+		ID:        "b.wlt",
+		Loaded:    true,
+		Label:     "exchange",
+		Type:      wallet.DeterministicWalletType,
+		Addresses: 2,
+	}, infos[1])
+
+	_, err = wrpc.LoadWallet("b.wlt")
+	assert.Error(t, err)
+	_, err = wrpc.LoadWallet("none.wlt")
+	assert.Error(t, err)
+	_, err = wrpc.LoadWallet("../b.wlt")
+	assert.Error(t, err)
+
+	// the copy of a loaded wallet is a duplicate
+	b, err := ioutil.ReadFile(filepath.Join(dir, "b.wlt"))
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "c.wlt"), b, 0600))
+	_, err = wrpc.LoadWallet("c.wlt")
+	assert.Error(t, err)
+
+	// the file is kept and can be loaded again
+	require.NoError(t, wrpc.UnloadWallet("b.wlt"))
+	assert.Nil(t, wrpc.GetWallet("b.wlt"))
+	assert.Error(t, wrpc.UnloadWallet("b.wlt"))
+	_, err = os.Stat(filepath.Join(dir, "b.wlt"))
+	require.NoError(t, err)
+
+	_, err = wrpc.LoadWallet("c.wlt")
+	require.NoError(t, err)
+	assert.NotNil(t, wrpc.GetWallet(defaultID))
+}
@@ -0,0 +1,593 @@
+package gui
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	"github.com/skycoin/skycoin/src/util/file"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+// Events of the webhooks
+const (
+	// the new block
+	WebhookEventNewBlock = "new_block"
+	// a transaction of a watched address is confirmed
+	WebhookEventTxnConfirmed = "txn_confirmed"
+	// the chain is reorganized
+	WebhookEventReorg = "reorg"
+)
+
+// Status of the webhook deliveries
+const (
+	// the delivery is being attempted or waits for the retry
+	WebhookDeliveryPending = "pending"
+	// the webhook responded 2xx
+	WebhookDeliveryDelivered = "delivered"
+	// all attempts failed
+	WebhookDeliveryFailed = "failed"
+)
+
+const (
+	// webhookEventsBufSize is the buffer size of the subscription to the event bus
+	webhookEventsBufSize = 1000
+	// webhookMaxDeliveries is the number of the latest deliveries kept for each webhook
+	webhookMaxDeliveries = 100
+	// MaxWebhooks is the max number of the webhooks
+	MaxWebhooks = 100
+)
+
+var (
+	// ErrWebhookNotFound is returned if the webhook doesn't exist
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrTooManyWebhooks is returned if MaxWebhooks webhooks exist already
+	ErrTooManyWebhooks = fmt.Errorf("at most %d webhooks can be added", MaxWebhooks)
+)
+
+var webhookEvents = []string{
+	WebhookEventNewBlock,
+	WebhookEventTxnConfirmed,
+	WebhookEventReorg,
+}
+
+func isWebhookEvent(event string) bool {
+	for _, e := range webhookEvents {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Wh global webhook dispatcher
+var Wh *WebhookDispatcher
+
+// InitWebhooks init the webhook dispatcher, Run of it starts the deliveries
+func InitWebhooks(gateway *daemon.Gateway, c WebhookConfig) error {
+	wd, err := NewWebhookDispatcher(gateway, c)
+	if err != nil {
+		return err
+	}
+	Wh = wd
+	return nil
+}
+
+// WebhookConfig configures the WebhookDispatcher
+type WebhookConfig struct {
+	// the json file the webhooks are saved in, they're not saved if empty
+	Path string
+	// number of times a delivery is attempted
+	MaxAttempts int
+	// the interval before the first retry, doubled for each retry up to MaxRetryInterval
+	RetryInterval    time.Duration
+	MaxRetryInterval time.Duration
+	// the timeout of a POST
+	Timeout time.Duration
+}
+
+// NewWebhookConfig returns a WebhookConfig with defaults set
+func NewWebhookConfig() WebhookConfig {
+	return WebhookConfig{
+		MaxAttempts:      6,
+		RetryInterval:    10 * time.Second,
+		MaxRetryInterval: 10 * time.Minute,
+		Timeout:          10 * time.Second,
+	}
+}
+
+// Webhook is the url receiving the POSTs of the events. The body is signed by the
+// HMAC-SHA256 of the secret, in the X-Suncoin-Signature header.
+type Webhook struct {
+	ID     string   `json:"id"`
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	// only returned when the webhook is added
+	Secret    string `json:"secret,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+func (h Webhook) wants(event string) bool {
+	for _, e := range h.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookPayload is the body POSTed to the webhook
+type WebhookPayload struct {
+	// id of the delivery, it's the same for the retries
+	ID    string `json:"id"`
+	Event string `json:"event"`
+	Time  int64  `json:"time"`
+	// the readable block of new_block, the address activity of txn_confirmed and the
+	// reorganization of reorg
+	Data interface{} `json:"data"`
+}
+
+// WebhookDelivery is the status of a delivery
+type WebhookDelivery struct {
+	ID       string `json:"id"`
+	Event    string `json:"event"`
+	Status   string `json:"status"`
+	Attempts int    `json:"attempts"`
+	// status code and error of the last attempt
+	StatusCode int    `json:"status_code"`
+	Error      string `json:"error,omitempty"`
+	CreatedAt  int64  `json:"created_at"`
+	UpdatedAt  int64  `json:"updated_at"`
+	// time of the next attempt, 0 if it's not pending
+	NextAttemptAt int64 `json:"next_attempt_at"`
+}
+
+// WebhookDispatcher delivers the events of the visor event bus to the webhooks, the
+// failed deliveries are retried with backoff
+type WebhookDispatcher struct {
+	Config  WebhookConfig
+	gateway *daemon.Gateway
+	client  *http.Client
+	hooks   []Webhook
+	// the latest deliveries of the webhooks, by webhook id
+	deliveries map[string][]*WebhookDelivery
+	unsub      func()
+	quit       chan struct{}
+	wg         sync.WaitGroup
+	sync.Mutex
+}
+
+// NewWebhookDispatcher creates a WebhookDispatcher, the webhooks saved in Config.Path are
+// loaded
+func NewWebhookDispatcher(gateway *daemon.Gateway, c WebhookConfig) (*WebhookDispatcher, error) {
+	if c.MaxAttempts <= 0 {
+		c.MaxAttempts = 1
+	}
+
+	wd := &WebhookDispatcher{
+		Config:     c,
+		gateway:    gateway,
+		client:     &http.Client{Timeout: c.Timeout},
+		deliveries: make(map[string][]*WebhookDelivery),
+		quit:       make(chan struct{}),
+	}
+
+	if c.Path != "" {
+		if err := file.LoadJSON(c.Path, &wd.hooks); err != nil && !os.IsNotExist(err) {
+			return nil, fmt.Errorf("load webhooks failed: %v", err)
+		}
+	}
+
+	return wd, nil
+}
+
+// Webhooks returns the webhooks, the secrets are not included
+func (wd *WebhookDispatcher) Webhooks() []Webhook {
+	wd.Lock()
+	defer wd.Unlock()
+
+	hooks := make([]Webhook, len(wd.hooks))
+	for i, h := range wd.hooks {
+		h.Secret = ""
+		hooks[i] = h
+	}
+	return hooks
+}
+
+// AddWebhook adds the webhook of the url receiving the events, all events if none is
+// given. A random secret is generated if it's empty.
+func (wd *WebhookDispatcher) AddWebhook(rawURL string, events []string, secret string) (Webhook, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Webhook{}, fmt.Errorf("invalid webhook url \"%s\"", rawURL)
+	}
+
+	if len(events) == 0 {
+		events = webhookEvents
+	}
+	for _, e := range events {
+		if !isWebhookEvent(e) {
+			return Webhook{}, fmt.Errorf("unknown webhook event \"%s\"", e)
+		}
+	}
+
+	if secret == "" {
+		secret = hex.EncodeToString(cipher.RandByte(32))
+	}
+
+	h := Webhook{
+		ID:        hex.EncodeToString(cipher.RandByte(8)),
+		URL:       u.String(),
+		Events:    events,
+		Secret:    secret,
+		CreatedAt: time.Now().Unix(),
+	}
+
+	wd.Lock()
+	defer wd.Unlock()
+
+	if len(wd.hooks) >= MaxWebhooks {
+		return Webhook{}, ErrTooManyWebhooks
+	}
+
+	hooks := append(wd.hooks[:len(wd.hooks):len(wd.hooks)], h)
+	if err := wd.save(hooks); err != nil {
+		return Webhook{}, err
+	}
+	wd.hooks = hooks
+	return h, nil
+}
+
+// RemoveWebhook removes the webhook, its pending deliveries are abandoned
+func (wd *WebhookDispatcher) RemoveWebhook(id string) error {
+	wd.Lock()
+	defer wd.Unlock()
+
+	for i, h := range wd.hooks {
+		if h.ID != id {
+			continue
+		}
+
+		hooks := make([]Webhook, 0, len(wd.hooks)-1)
+		hooks = append(hooks, wd.hooks[:i]...)
+		hooks = append(hooks, wd.hooks[i+1:]...)
+		if err := wd.save(hooks); err != nil {
+			return err
+		}
+		wd.hooks = hooks
+		delete(wd.deliveries, id)
+		return nil
+	}
+	return ErrWebhookNotFound
+}
+
+// Deliveries returns the latest deliveries of the webhook, the latest first
+func (wd *WebhookDispatcher) Deliveries(id string) ([]WebhookDelivery, error) {
+	wd.Lock()
+	defer wd.Unlock()
+
+	if _, ok := wd.webhook(id); !ok {
+		return nil, ErrWebhookNotFound
+	}
+
+	ds := wd.deliveries[id]
+	res := make([]WebhookDelivery, len(ds))
+	for i, d := range ds {
+		res[len(ds)-1-i] = *d
+	}
+	return res, nil
+}
+
+func (wd *WebhookDispatcher) webhook(id string) (Webhook, bool) {
+	for _, h := range wd.hooks {
+		if h.ID == id {
+			return h, true
+		}
+	}
+	return Webhook{}, false
+}
+
+func (wd *WebhookDispatcher) save(hooks []Webhook) error {
+	if wd.Config.Path == "" {
+		return nil
+	}
+	if err := file.SaveJSON(wd.Config.Path, hooks, 0600); err != nil {
+		return fmt.Errorf("save webhooks failed: %v", err)
+	}
+	return nil
+}
+
+// Run delivers the events of the event bus until Shutdown is called
+func (wd *WebhookDispatcher) Run() {
+	events, unsub := wd.gateway.SubscribeEvents(webhookEventsBufSize,
+		visor.EventNewBlock,
+		visor.EventAddressActivity,
+		visor.EventReorg)
+
+	wd.Lock()
+	wd.unsub = unsub
+	wd.Unlock()
+
+	for ev := range events {
+		switch ev.Type {
+		case visor.EventNewBlock:
+			wd.Dispatch(WebhookEventNewBlock, visor.NewReadableBlock(ev.Block))
+		case visor.EventAddressActivity:
+			if ev.Activity.Confirmed {
+				wd.Dispatch(WebhookEventTxnConfirmed, ev.Activity)
+			}
+		case visor.EventReorg:
+			wd.Dispatch(WebhookEventReorg, ev.Reorg)
+		}
+	}
+}
+
+// Shutdown stops the dispatcher, the pending retries are abandoned
+func (wd *WebhookDispatcher) Shutdown() {
+	wd.Lock()
+	unsub := wd.unsub
+	select {
+	case <-wd.quit:
+	default:
+		close(wd.quit)
+	}
+	wd.Unlock()
+
+	if unsub != nil {
+		unsub()
+	}
+	wd.wg.Wait()
+}
+
+// Dispatch POSTs the event to the webhooks subscribing it
+func (wd *WebhookDispatcher) Dispatch(event string, data interface{}) {
+	wd.Lock()
+	defer wd.Unlock()
+
+	select {
+	case <-wd.quit:
+		return
+	default:
+	}
+
+	now := time.Now().Unix()
+	for _, h := range wd.hooks {
+		if !h.wants(event) {
+			continue
+		}
+
+		d := &WebhookDelivery{
+			ID:        hex.EncodeToString(cipher.RandByte(16)),
+			Event:     event,
+			Status:    WebhookDeliveryPending,
+			CreatedAt: now,
+			UpdatedAt: now,
+		}
+
+		body, err := json.Marshal(WebhookPayload{
+			ID:    d.ID,
+			Event: event,
+			Time:  now,
+			Data:  data,
+		})
+		if err != nil {
+			logger.Error("Encode webhook payload failed: %v", err)
+			return
+		}
+
+		ds := append(wd.deliveries[h.ID], d)
+		if len(ds) > webhookMaxDeliveries {
+			ds = ds[len(ds)-webhookMaxDeliveries:]
+		}
+		wd.deliveries[h.ID] = ds
+
+		wd.wg.Add(1)
+		go wd.deliver(h, d, body)
+	}
+}
+
+// deliver POSTs the body until the webhook responds 2xx, or the attempts run out
+func (wd *WebhookDispatcher) deliver(h Webhook, d *WebhookDelivery, body []byte) {
+	defer wd.wg.Done()
+
+	interval := wd.Config.RetryInterval
+	for {
+		code, err := wd.post(h, d, body)
+
+		wd.Lock()
+		if _, ok := wd.webhook(h.ID); !ok {
+			wd.Unlock()
+			return
+		}
+
+		d.Attempts++
+		d.StatusCode = code
+		d.Error = ""
+		d.NextAttemptAt = 0
+		d.UpdatedAt = time.Now().Unix()
+		switch {
+		case err == nil:
+			d.Status = WebhookDeliveryDelivered
+		case d.Attempts >= wd.Config.MaxAttempts:
+			d.Status = WebhookDeliveryFailed
+			d.Error = err.Error()
+		default:
+			d.Error = err.Error()
+			d.NextAttemptAt = time.Now().Add(interval).Unix()
+		}
+		status := d.Status
+		wd.Unlock()
+
+		if status != WebhookDeliveryPending {
+			if status == WebhookDeliveryFailed {
+				logger.Error("Webhook %s delivery %s failed: %v", h.ID, d.ID, err)
+			}
+			return
+		}
+
+		select {
+		case <-time.After(interval):
+		case <-wd.quit:
+			return
+		}
+
+		interval *= 2
+		if wd.Config.MaxRetryInterval > 0 && interval > wd.Config.MaxRetryInterval {
+			interval = wd.Config.MaxRetryInterval
+		}
+	}
+}
+
+// post POSTs the signed body, an error is returned if the webhook doesn't respond 2xx
+func (wd *WebhookDispatcher) post(h Webhook, d *WebhookDelivery, body []byte) (int, error) {
+	req, err := http.NewRequest("POST", h.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Suncoin-Event", d.Event)
+	req.Header.Set("X-Suncoin-Delivery", d.ID)
+	req.Header.Set("X-Suncoin-Signature", "sha256="+WebhookSignature(h.Secret, body))
+
+	resp, err := wd.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// WebhookSignature returns the hex HMAC-SHA256 of the body, keyed by the secret of the webhook
+func WebhookSignature(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// RegisterWebhookHandlers register the webhook handlers
+func RegisterWebhookHandlers(mux Mux, gateway *daemon.Gateway) {
+	// list the webhooks
+	mux.HandleFunc("/webhooks", getWebhooks)
+	// add the webhook receiving the events
+	mux.HandleFunc("/webhooks/add", addWebhook)
+	// remove the webhook
+	mux.HandleFunc("/webhooks/remove", removeWebhook)
+	// get the latest deliveries of the webhook
+	mux.HandleFunc("/webhooks/deliveries", getWebhookDeliveries)
+}
+
+// method: GET
+// url: /webhooks
+func getWebhooks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		wh.Error405(w, "")
+		return
+	}
+
+	if Wh == nil {
+		wh.Error501(w, "webhooks are not enabled")
+		return
+	}
+
+	wh.SendOr404(w, Wh.Webhooks())
+}
+
+// method: POST
+// url: /webhooks/add
+// params: url, events: [optional] comma separated events, all events by default,
+// secret: [optional] the key of the signatures, generated if not set
+func addWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		wh.Error405(w, "")
+		return
+	}
+
+	if Wh == nil {
+		wh.Error501(w, "webhooks are not enabled")
+		return
+	}
+
+	u := r.FormValue("url")
+	if u == "" {
+		wh.Error400(w, "missing url")
+		return
+	}
+
+	var events []string
+	for _, e := range strings.Split(r.FormValue("events"), ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			events = append(events, e)
+		}
+	}
+
+	h, err := Wh.AddWebhook(u, events, r.FormValue("secret"))
+	if err != nil {
+		wh.Error400(w, err.Error())
+		return
+	}
+
+	wh.SendOr404(w, h)
+}
+
+// method: POST
+// url: /webhooks/remove
+// params: id
+func removeWebhook(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		wh.Error405(w, "")
+		return
+	}
+
+	if Wh == nil {
+		wh.Error501(w, "webhooks are not enabled")
+		return
+	}
+
+	switch err := Wh.RemoveWebhook(r.FormValue("id")); err {
+	case nil:
+		wh.SendOr404(w, Wh.Webhooks())
+	case ErrWebhookNotFound:
+		wh.Error404(w, err.Error())
+	default:
+		wh.Error500(w, err.Error())
+	}
+}
+
+// method: GET
+// url: /webhooks/deliveries?id=[:id]
+func getWebhookDeliveries(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		wh.Error405(w, "")
+		return
+	}
+
+	if Wh == nil {
+		wh.Error501(w, "webhooks are not enabled")
+		return
+	}
+
+	ds, err := Wh.Deliveries(r.FormValue("id"))
+	if err != nil {
+		wh.Error404(w, err.Error())
+		return
+	}
+
+	wh.SendOr404(w, ds)
+}
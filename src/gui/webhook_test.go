@@ -0,0 +1,112 @@
+package gui
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookDispatcher(t *testing.T) {
+	dir, err := ioutil.TempDir("", "webhooks")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// fails the first POST, then accepts
+	var mu sync.Mutex
+	var bodies [][]byte
+	received := make(chan struct{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		assert.Equal(t, "sha256="+WebhookSignature("secret", body), r.Header.Get("X-Suncoin-Signature"))
+		assert.Equal(t, WebhookEventReorg, r.Header.Get("X-Suncoin-Event"))
+
+		mu.Lock()
+		bodies = append(bodies, body)
+		n := len(bodies)
+		mu.Unlock()
+
+		if n == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		received <- struct{}{}
+	}))
+	defer srv.Close()
+
+	c := NewWebhookConfig()
+	c.Path = filepath.Join(dir, "webhooks.json")
+	c.RetryInterval = 10 * time.Millisecond
+	wd, err := NewWebhookDispatcher(nil, c)
+	require.NoError(t, err)
+
+	_, err = wd.AddWebhook("ftp://example.com", nil, "")
+	assert.Error(t, err)
+	_, err = wd.AddWebhook(srv.URL, []string{"unknown"}, "")
+	assert.Error(t, err)
+
+	h, err := wd.AddWebhook(srv.URL, []string{WebhookEventReorg}, "secret")
+	require.NoError(t, err)
+	all, err := wd.AddWebhook(srv.URL, nil, "")
+	require.NoError(t, err)
+	assert.Equal(t, webhookEvents, all.Events)
+	assert.Len(t, all.Secret, 64)
+	require.NoError(t, wd.RemoveWebhook(all.ID))
+	assert.Equal(t, ErrWebhookNotFound, wd.RemoveWebhook(all.ID))
+
+	// the secret is not listed
+	hooks := wd.Webhooks()
+	require.Len(t, hooks, 1)
+	assert.Equal(t, h.ID, hooks[0].ID)
+	assert.Empty(t, hooks[0].Secret)
+
+	// not subscribed
+	wd.Dispatch(WebhookEventNewBlock, "block")
+	wd.Dispatch(WebhookEventReorg, map[string]uint64{"fork_seq": 10})
+	<-received
+	<-received
+
+	// the status is updated after the response is read
+	var ds []WebhookDelivery
+	for i := 0; i < 100; i++ {
+		ds, err = wd.Deliveries(h.ID)
+		require.NoError(t, err)
+		require.Len(t, ds, 1)
+		if ds[0].Status != WebhookDeliveryPending {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, WebhookDeliveryDelivered, ds[0].Status)
+	assert.Equal(t, 2, ds[0].Attempts)
+	assert.Equal(t, http.StatusOK, ds[0].StatusCode)
+
+	// the retry posts the same payload
+	mu.Lock()
+	require.Len(t, bodies, 2)
+	assert.Equal(t, bodies[0], bodies[1])
+	var p WebhookPayload
+	require.NoError(t, json.Unmarshal(bodies[0], &p))
+	mu.Unlock()
+	assert.Equal(t, ds[0].ID, p.ID)
+	assert.Equal(t, WebhookEventReorg, p.Event)
+
+	wd.Shutdown()
+
+	// the webhooks are loaded with the secrets
+	wd, err = NewWebhookDispatcher(nil, c)
+	require.NoError(t, err)
+	require.Len(t, wd.hooks, 1)
+	assert.Equal(t, h, wd.hooks[0])
+
+	_, err = wd.Deliveries("unknown")
+	assert.Equal(t, ErrWebhookNotFound, err)
+}
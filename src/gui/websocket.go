@@ -0,0 +1,403 @@
+package gui
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/daemon"
+	wh "github.com/skycoin/skycoin/src/util/http" //http,json helpers
+	"github.com/skycoin/skycoin/src/util/websocket"
+	"github.com/skycoin/skycoin/src/visor"
+)
+
+const (
+	// wsMaxConns is the max number of the websocket connections
+	wsMaxConns = 64
+	// wsMaxAddresses is the max number of the addresses subscribed by a connection
+	wsMaxAddresses = 1000
+	// wsEventsBufSize is the buffer of the events of a connection, the events are dropped
+	// if the client can't keep up
+	wsEventsBufSize = 256
+)
+
+// Topics of the websocket subscriptions, they're named after the event types
+const (
+	wsTopicNewBlock          = string(visor.EventNewBlock)
+	wsTopicNewUnconfirmedTxn = string(visor.EventNewUnconfirmedTxn)
+	wsTopicAddressActivity   = string(visor.EventAddressActivity)
+)
+
+// wsRequest is the message sent by the client, e.g.
+// {"op": "subscribe", "topic": "address_activity", "addresses": ["..."]}
+type wsRequest struct {
+	Op    string `json:"op"`
+	Topic string `json:"topic"`
+	// the addresses of the address_activity topic
+	Addresses []string `json:"addresses,omitempty"`
+}
+
+// wsMessage is the message pushed to the client, the reply of a request echoes its op
+type wsMessage struct {
+	Op        string      `json:"op,omitempty"`
+	Topic     string      `json:"topic,omitempty"`
+	Addresses []string    `json:"addresses,omitempty"`
+	Data      interface{} `json:"data,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// RegisterWebsocketHandlers register the websocket and the server-sent events handlers, they
+// share the watched addresses
+func RegisterWebsocketHandlers(mux Mux, gateway *daemon.Gateway) {
+	watcher := newWSWatcher(gateway)
+	// subscribe the new blocks, the new unconfirmed transactions and the activities of
+	// the addresses, the readable json of them is pushed to the client
+	// 		GET /ws
+	mux.HandleFunc("/ws", wsHandler(gateway, watcher))
+	// the same events streamed as server-sent events, for the clients behind the proxies not
+	// supporting websocket
+	// 		GET /events?topics=[:topics]&addresses=[:addresses]
+	mux.HandleFunc("/events", sseHandler(gateway, watcher))
+}
+
+// wsHandler upgrades the connection to websocket and pushes the subscribed events
+// method: GET
+// url: /ws
+// params: the subscriptions are sent as json messages after the connection is upgraded
+func wsHandler(gateway *daemon.Gateway, watcher *wsWatcher) http.HandlerFunc {
+	var conns int32
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			wh.Error405(w, "")
+			return
+		}
+
+		if atomic.AddInt32(&conns, 1) > wsMaxConns {
+			atomic.AddInt32(&conns, -1)
+			wh.Error503(w, "too many websocket connections")
+			return
+		}
+		defer atomic.AddInt32(&conns, -1)
+
+		conn, err := websocket.Upgrade(w, r)
+		if err != nil {
+			logger.Debug("websocket upgrade failed: %v", err)
+			return
+		}
+
+		s := &wsSession{
+			conn:              conn,
+			eventSubscription: newEventSubscription(gateway, watcher),
+		}
+		s.run()
+	}
+}
+
+// wsSession is the subscriptions of a websocket connection
+type wsSession struct {
+	conn *websocket.Conn
+	*eventSubscription
+}
+
+func (s *wsSession) run() {
+	events, unsubscribe := s.subscribe()
+	defer unsubscribe()
+	defer s.release()
+
+	reqs := make(chan wsRequest)
+	quit := make(chan struct{})
+	readErr := make(chan error, 1)
+	defer close(quit)
+
+	go func() {
+		for {
+			_, msg, err := s.conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+
+			var req wsRequest
+			if err := json.Unmarshal(msg, &req); err != nil {
+				s.conn.WriteJSON(wsMessage{Error: fmt.Sprintf("invalid request: %v", err)})
+				continue
+			}
+
+			select {
+			case reqs <- req:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case req := <-reqs:
+			if err := s.conn.WriteJSON(s.handle(req)); err != nil {
+				s.conn.Close(websocket.CloseGoingAway)
+				return
+			}
+		case ev, ok := <-events:
+			if !ok {
+				s.conn.Close(websocket.CloseGoingAway)
+				return
+			}
+			msg := s.message(ev)
+			if msg == nil {
+				continue
+			}
+			if err := s.conn.WriteJSON(msg); err != nil {
+				s.conn.Close(websocket.CloseGoingAway)
+				return
+			}
+		case err := <-readErr:
+			switch err {
+			case websocket.ErrClosed:
+				s.conn.Close(websocket.CloseNormal)
+			case websocket.ErrMessageTooLarge:
+				s.conn.Close(websocket.CloseMessageTooBig)
+			default:
+				s.conn.Close(websocket.ClosePolicyViolation)
+			}
+			return
+		}
+	}
+}
+
+// eventSubscription is the topics and the addresses subscribed by a client, of the websocket or
+// the server-sent events
+type eventSubscription struct {
+	gateway *daemon.Gateway
+	watcher *wsWatcher
+	topics  map[string]struct{}
+	addrs   map[cipher.Address]struct{}
+}
+
+func newEventSubscription(gateway *daemon.Gateway, watcher *wsWatcher) *eventSubscription {
+	return &eventSubscription{
+		gateway: gateway,
+		watcher: watcher,
+		topics:  make(map[string]struct{}),
+		addrs:   make(map[cipher.Address]struct{}),
+	}
+}
+
+// subscribe subscribes the events of the visor, they're filtered by message
+func (s *eventSubscription) subscribe() (<-chan visor.Event, func()) {
+	return s.gateway.SubscribeEvents(wsEventsBufSize,
+		visor.EventNewBlock, visor.EventNewUnconfirmedTxn, visor.EventAddressActivity)
+}
+
+// handle applies the request, the reply is sent to the client
+func (s *eventSubscription) handle(req wsRequest) wsMessage {
+	reply := wsMessage{Op: req.Op, Topic: req.Topic}
+	if req.Op != "subscribe" && req.Op != "unsubscribe" {
+		reply.Error = fmt.Sprintf("unknown op \"%s\"", req.Op)
+		return reply
+	}
+
+	switch req.Topic {
+	case wsTopicNewBlock, wsTopicNewUnconfirmedTxn:
+		if req.Op == "subscribe" {
+			s.topics[req.Topic] = struct{}{}
+		} else {
+			delete(s.topics, req.Topic)
+		}
+	case wsTopicAddressActivity:
+		addrs, err := s.updateAddresses(req.Op, req.Addresses)
+		if err != nil {
+			reply.Error = err.Error()
+			return reply
+		}
+		reply.Addresses = addrs
+	default:
+		reply.Error = fmt.Sprintf("unknown topic \"%s\"", req.Topic)
+	}
+	return reply
+}
+
+// updateAddresses adds or removes the subscribed addresses, the subscribed addresses
+// are returned
+func (s *eventSubscription) updateAddresses(op string, addrStrs []string) ([]string, error) {
+	if len(addrStrs) == 0 {
+		return nil, fmt.Errorf("addresses is empty")
+	}
+
+	var addrs []cipher.Address
+	for _, as := range addrStrs {
+		a, err := cipher.DecodeBase58Address(as)
+		if err != nil {
+			return nil, fmt.Errorf("address %s is invalid: %v", as, err)
+		}
+		_, ok := s.addrs[a]
+		if (op == "subscribe") != ok {
+			addrs = append(addrs, a)
+		}
+	}
+	addrs = uniqueAddresses(addrs)
+
+	if op == "subscribe" {
+		if len(s.addrs)+len(addrs) > wsMaxAddresses {
+			return nil, fmt.Errorf("at most %d addresses can be subscribed", wsMaxAddresses)
+		}
+		if err := s.watcher.watch(addrs); err != nil {
+			return nil, err
+		}
+		for _, a := range addrs {
+			s.addrs[a] = struct{}{}
+		}
+	} else {
+		s.watcher.unwatch(addrs)
+		for _, a := range addrs {
+			delete(s.addrs, a)
+		}
+	}
+
+	subscribed := make([]string, 0, len(s.addrs))
+	for a := range s.addrs {
+		subscribed = append(subscribed, a.String())
+	}
+	sort.Strings(subscribed)
+	return subscribed, nil
+}
+
+// message returns the message of the event, nil if it's not subscribed
+func (s *eventSubscription) message(ev visor.Event) *wsMessage {
+	topic := string(ev.Type)
+	switch ev.Type {
+	case visor.EventNewBlock:
+		if _, ok := s.topics[topic]; !ok || ev.Block == nil {
+			return nil
+		}
+		return &wsMessage{Topic: topic, Data: visor.NewReadableBlock(ev.Block)}
+	case visor.EventNewUnconfirmedTxn:
+		if _, ok := s.topics[topic]; !ok {
+			return nil
+		}
+		tx, err := s.gateway.GetTransaction(ev.TxID)
+		if err != nil || tx == nil {
+			return nil
+		}
+		return &wsMessage{Topic: topic, Data: visor.TransactionResult{
+			Transaction: visor.NewReadableTransaction(tx),
+			Status:      tx.Status,
+			Time:        tx.Time,
+		}}
+	case visor.EventAddressActivity:
+		if ev.Activity == nil {
+			return nil
+		}
+		a, err := cipher.DecodeBase58Address(ev.Activity.Address)
+		if err != nil {
+			return nil
+		}
+		if _, ok := s.addrs[a]; !ok {
+			return nil
+		}
+		return &wsMessage{Topic: topic, Data: ev.Activity}
+	}
+	return nil
+}
+
+// release unwatches the addresses of the closed connection
+func (s *eventSubscription) release() {
+	addrs := make([]cipher.Address, 0, len(s.addrs))
+	for a := range s.addrs {
+		addrs = append(addrs, a)
+	}
+	s.watcher.unwatch(addrs)
+}
+
+func uniqueAddresses(addrs []cipher.Address) []cipher.Address {
+	seen := make(map[cipher.Address]struct{}, len(addrs))
+	var uniq []cipher.Address
+	for _, a := range addrs {
+		if _, ok := seen[a]; ok {
+			continue
+		}
+		seen[a] = struct{}{}
+		uniq = append(uniq, a)
+	}
+	return uniq
+}
+
+// wsWatcher counts the websocket subscriptions of the addresses, the address is added to
+// the watch list of the visor by the first subscription and removed after the last one.
+// The addresses watched by the watched addresses api are never removed.
+type wsWatcher struct {
+	sync.Mutex
+	gateway *daemon.Gateway
+	refs    map[cipher.Address]int
+	// the addresses added to the watch list by the websocket subscriptions
+	owned map[cipher.Address]struct{}
+}
+
+func newWSWatcher(gateway *daemon.Gateway) *wsWatcher {
+	return &wsWatcher{
+		gateway: gateway,
+		refs:    make(map[cipher.Address]int),
+		owned:   make(map[cipher.Address]struct{}),
+	}
+}
+
+func (ww *wsWatcher) watch(addrs []cipher.Address) error {
+	ww.Lock()
+	defer ww.Unlock()
+
+	watched := make(map[string]struct{})
+	for _, a := range ww.gateway.GetWatchedAddresses() {
+		watched[a] = struct{}{}
+	}
+
+	var add []cipher.Address
+	for _, a := range addrs {
+		if _, ok := watched[a.String()]; ok || ww.refs[a] > 0 {
+			continue
+		}
+		add = append(add, a)
+	}
+
+	if len(add) > 0 {
+		if err := ww.gateway.WatchAddresses(add); err != nil {
+			return err
+		}
+	}
+
+	for _, a := range add {
+		ww.owned[a] = struct{}{}
+	}
+	for _, a := range addrs {
+		ww.refs[a]++
+	}
+	return nil
+}
+
+func (ww *wsWatcher) unwatch(addrs []cipher.Address) {
+	ww.Lock()
+	defer ww.Unlock()
+
+	var rm []cipher.Address
+	for _, a := range addrs {
+		if ww.refs[a] == 0 {
+			continue
+		}
+		ww.refs[a]--
+		if ww.refs[a] > 0 {
+			continue
+		}
+		delete(ww.refs, a)
+		if _, ok := ww.owned[a]; ok {
+			delete(ww.owned, a)
+			rm = append(rm, a)
+		}
+	}
+
+	if len(rm) > 0 {
+		ww.gateway.UnwatchAddresses(rm)
+	}
+}
@@ -0,0 +1,202 @@
+// Package config loads the values of the command line flags from a TOML config file and the
+// environment variables. The precedence is the command line, the environment variables, the
+// config file, and then the defaults of the flags.
+package config
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Source is where the value of a flag comes from
+type Source string
+
+// The sources of the values
+const (
+	SourceDefault Source = "default"
+	SourceFile    Source = "file"
+	SourceEnv     Source = "env"
+	SourceFlag    Source = "flag"
+)
+
+// Loader sets the flags of the FlagSet from the config file and the environment variables. The
+// keys of the config file are the flag names, the names of the tables are the prefixes of the
+// flag names, e.g. port of [web-interface] is -web-interface-port. The environment variables are
+// the prefix followed by the flag names in upper case with - replaced by _, e.g.
+// SUNCOIN_WEB_INTERFACE_PORT.
+type Loader struct {
+	fs        *flag.FlagSet
+	envPrefix string
+	sources   map[string]Source
+}
+
+// NewLoader creates a Loader of the parsed FlagSet, the flags set on the command line are not
+// overridden
+func NewLoader(fs *flag.FlagSet, envPrefix string) *Loader {
+	l := &Loader{
+		fs:        fs,
+		envPrefix: envPrefix,
+		sources:   make(map[string]Source),
+	}
+	fs.Visit(func(f *flag.Flag) {
+		l.sources[f.Name] = SourceFlag
+	})
+	return l
+}
+
+// LoadEnv sets the flags from the environment variables, environ is the list of key=value of
+// os.Environ
+func (l *Loader) LoadEnv(environ []string) error {
+	env := make(map[string]string)
+	for _, kv := range environ {
+		if i := strings.IndexByte(kv, '='); i > 0 {
+			env[kv[:i]] = kv[i+1:]
+		}
+	}
+
+	var err error
+	l.fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || l.sources[f.Name] != "" {
+			return
+		}
+
+		v, ok := env[l.EnvName(f.Name)]
+		if !ok {
+			return
+		}
+		if e := l.fs.Set(f.Name, v); e != nil {
+			err = fmt.Errorf("invalid %s: %v", l.EnvName(f.Name), e)
+			return
+		}
+		l.sources[f.Name] = SourceEnv
+	})
+	return err
+}
+
+// EnvName returns the environment variable of the flag
+func (l *Loader) EnvName(name string) string {
+	return l.envPrefix + strings.ToUpper(strings.Replace(name, "-", "_", -1))
+}
+
+// LoadFile sets the flags from the TOML config file, the unknown keys are errors
+func (l *Loader) LoadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return l.Load(f)
+}
+
+// Load sets the flags from the TOML config
+func (l *Loader) Load(r io.Reader) error {
+	tree, err := ParseTOML(r)
+	if err != nil {
+		return err
+	}
+
+	values := make(map[string]string)
+	if err := flatten(tree, "", values); err != nil {
+		return err
+	}
+
+	names := make([]string, 0, len(values))
+	for name := range values {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if l.fs.Lookup(name) == nil {
+			return fmt.Errorf("unknown config key %s", name)
+		}
+		if l.sources[name] != "" {
+			continue
+		}
+		if err := l.fs.Set(name, values[name]); err != nil {
+			return fmt.Errorf("invalid %s: %v", name, err)
+		}
+		l.sources[name] = SourceFile
+	}
+	return nil
+}
+
+// flatten joins the keys of the nested tables with -, the arrays are joined with ,
+func flatten(tree map[string]interface{}, prefix string, values map[string]string) error {
+	for k, v := range tree {
+		name := k
+		if prefix != "" {
+			name = prefix + "-" + k
+		}
+
+		switch x := v.(type) {
+		case map[string]interface{}:
+			if err := flatten(x, name, values); err != nil {
+				return err
+			}
+		case []interface{}:
+			items := make([]string, len(x))
+			for i, item := range x {
+				if _, ok := item.([]interface{}); ok {
+					return fmt.Errorf("nested array of %s is not supported", name)
+				}
+				items[i] = fmt.Sprint(item)
+			}
+			values[name] = strings.Join(items, ",")
+		default:
+			values[name] = fmt.Sprint(x)
+		}
+	}
+	return nil
+}
+
+// Source returns where the value of the flag comes from
+func (l *Loader) Source(name string) Source {
+	if s := l.sources[name]; s != "" {
+		return s
+	}
+	return SourceDefault
+}
+
+// WriteTOML writes the effective values of all the flags as a TOML config, with the usages and
+// the sources as the comments. The flags in skip are not written.
+func (l *Loader) WriteTOML(w io.Writer, skip ...string) error {
+	skipped := make(map[string]bool, len(skip))
+	for _, s := range skip {
+		skipped[s] = true
+	}
+
+	var err error
+	l.fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || skipped[f.Name] {
+			return
+		}
+		_, err = fmt.Fprintf(w, "# %s (%s)\n%s = %s\n\n",
+			strings.Replace(f.Usage, "\n", " ", -1), l.Source(f.Name), f.Name, tomlValue(f.Value))
+	})
+	return err
+}
+
+// tomlValue formats the value of the flag, the booleans and the numbers are bare
+func tomlValue(v flag.Value) string {
+	if g, ok := v.(flag.Getter); ok {
+		switch x := g.Get().(type) {
+		case bool:
+			return strconv.FormatBool(x)
+		case int, int64, uint, uint64:
+			return fmt.Sprint(x)
+		case float64:
+			return strconv.FormatFloat(x, 'g', -1, 64)
+		case time.Duration:
+			return strconv.Quote(x.String())
+		}
+	}
+	return strconv.Quote(v.String())
+}
@@ -0,0 +1,130 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTOML(t *testing.T) {
+	tree, err := ParseTOML(strings.NewReader(`
+# comment
+port = 6000 # the p2p port
+address = "127.0.0.1"
+"quoted key" = 'C:\data'
+
+[web-interface]
+enabled = true
+max-age = 1_000
+ratio = 0.5
+origins = ["https://a.example.com", "https://b.example.com"]
+escaped = "a\"b\n"
+
+[rate.limit]
+read = "10:20"
+`))
+	require.NoError(t, err)
+	require.Equal(t, map[string]interface{}{
+		"port":       int64(6000),
+		"address":    "127.0.0.1",
+		"quoted key": `C:\data`,
+		"web-interface": map[string]interface{}{
+			"enabled": true,
+			"max-age": int64(1000),
+			"ratio":   0.5,
+			"origins": []interface{}{"https://a.example.com", "https://b.example.com"},
+			"escaped": "a\"b\n",
+		},
+		"rate": map[string]interface{}{
+			"limit": map[string]interface{}{
+				"read": "10:20",
+			},
+		},
+	}, tree)
+}
+
+func TestParseTOMLErrors(t *testing.T) {
+	for _, s := range []string{
+		"port",
+		"port = ",
+		"port = 1\nport = 2",
+		"port = 1 2",
+		`name = "abc`,
+		"arr = [1, 2",
+		"[table",
+		"[[tables]]",
+		"a = 1\n[a]",
+		"port = abc",
+	} {
+		_, err := ParseTOML(strings.NewReader(s))
+		require.Error(t, err, s)
+	}
+}
+
+func newFlagSet(args ...string) (*flag.FlagSet, *int, *string, *bool) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(ioutil.Discard)
+	port := fs.Int("port", 6000, "the p2p port")
+	origins := fs.String("web-interface-origins", "", "the allowed origins")
+	gzip := fs.Bool("web-interface-gzip", true, "compress the responses")
+	fs.Duration("idempotency-window", time.Hour, "how long the results are kept")
+	if err := fs.Parse(args); err != nil {
+		panic(err)
+	}
+	return fs, port, origins, gzip
+}
+
+func TestLoader(t *testing.T) {
+	fs, port, origins, gzip := newFlagSet("-port=7000")
+	l := NewLoader(fs, "SUNCOIN_")
+
+	require.NoError(t, l.LoadEnv([]string{"SUNCOIN_WEB_INTERFACE_GZIP=false", "SUNCOIN_PORT=8000", "PATH=/bin"}))
+	require.NoError(t, l.Load(strings.NewReader(`
+port = 9000
+[web-interface]
+gzip = true
+origins = ["https://a.example.com", "https://b.example.com"]
+`)))
+
+	// the command line overrides the env, the env overrides the file
+	require.Equal(t, 7000, *port)
+	require.False(t, *gzip)
+	require.Equal(t, "https://a.example.com,https://b.example.com", *origins)
+
+	require.Equal(t, SourceFlag, l.Source("port"))
+	require.Equal(t, SourceEnv, l.Source("web-interface-gzip"))
+	require.Equal(t, SourceFile, l.Source("web-interface-origins"))
+	require.Equal(t, SourceDefault, l.Source("idempotency-window"))
+
+	var b bytes.Buffer
+	require.NoError(t, l.WriteTOML(&b, "web-interface-origins"))
+	require.Equal(t, `# how long the results are kept (default)
+idempotency-window = "1h0m0s"
+
+# the p2p port (flag)
+port = 7000
+
+# compress the responses (env)
+web-interface-gzip = false
+
+`, b.String())
+
+	// the written config is loaded back
+	fs2, port2, _, gzip2 := newFlagSet()
+	require.NoError(t, NewLoader(fs2, "SUNCOIN_").Load(&b))
+	require.Equal(t, 7000, *port2)
+	require.False(t, *gzip2)
+}
+
+func TestLoaderErrors(t *testing.T) {
+	fs, _, _, _ := newFlagSet()
+	l := NewLoader(fs, "SUNCOIN_")
+	require.Error(t, l.Load(strings.NewReader("unknown = 1")))
+	require.Error(t, l.Load(strings.NewReader("port = \"abc\"")))
+	require.Error(t, l.LoadEnv([]string{"SUNCOIN_WEB_INTERFACE_GZIP=maybe"}))
+}
@@ -0,0 +1,269 @@
+package config
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ParseTOML parses the subset of TOML used by the config files: the tables, the bare, quoted and
+// dotted keys, and the values of the strings, the integers, the floats, the booleans and the
+// single line arrays of them. The tables are returned as nested maps.
+func ParseTOML(r io.Reader) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	table := root
+
+	sc := bufio.NewScanner(r)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		if line[0] == '[' {
+			if strings.HasPrefix(line, "[[") {
+				return nil, fmt.Errorf("line %d: arrays of tables are not supported", n)
+			}
+
+			end := strings.IndexByte(line, ']')
+			if end < 0 || !isComment(line[end+1:]) {
+				return nil, fmt.Errorf("line %d: invalid table header", n)
+			}
+
+			keys, err := parseKey(line[1:end])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+
+			t, err := subTable(root, keys)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n, err)
+			}
+			table = t
+			continue
+		}
+
+		eq := indexUnquoted(line, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("line %d: expected key = value", n)
+		}
+
+		keys, err := parseKey(line[:eq])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		v, rest, err := parseValue(strings.TrimSpace(line[eq+1:]))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		if !isComment(rest) {
+			return nil, fmt.Errorf("line %d: unexpected %q after value", n, rest)
+		}
+
+		t, err := subTable(table, keys[:len(keys)-1])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		k := keys[len(keys)-1]
+		if _, ok := t[k]; ok {
+			return nil, fmt.Errorf("line %d: duplicate key %s", n, k)
+		}
+		t[k] = v
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+	return root, nil
+}
+
+// subTable returns the table of the keys under t, the tables are created if missing
+func subTable(t map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	for _, k := range keys {
+		v, ok := t[k]
+		if !ok {
+			sub := make(map[string]interface{})
+			t[k] = sub
+			t = sub
+			continue
+		}
+
+		sub, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("key %s is not a table", k)
+		}
+		t = sub
+	}
+	return t, nil
+}
+
+// parseKey parses the dotted key, the parts can be bare or quoted
+func parseKey(s string) ([]string, error) {
+	var keys []string
+	s = strings.TrimSpace(s)
+	for {
+		var k string
+		if s != "" && (s[0] == '"' || s[0] == '\'') {
+			v, rest, err := parseString(s)
+			if err != nil {
+				return nil, err
+			}
+			k, s = v, strings.TrimSpace(rest)
+		} else {
+			i := 0
+			for i < len(s) && isBareKeyChar(s[i]) {
+				i++
+			}
+			if i == 0 {
+				return nil, fmt.Errorf("invalid key %q", s)
+			}
+			k, s = s[:i], strings.TrimSpace(s[i:])
+		}
+		keys = append(keys, k)
+
+		if s == "" {
+			return keys, nil
+		}
+		if s[0] != '.' {
+			return nil, fmt.Errorf("invalid key %q", s)
+		}
+		s = strings.TrimSpace(s[1:])
+	}
+}
+
+func isBareKeyChar(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '_' || c == '-'
+}
+
+// parseValue parses the value at the beginning of s, the rest of s is returned
+func parseValue(s string) (interface{}, string, error) {
+	if s == "" {
+		return nil, "", fmt.Errorf("value is empty")
+	}
+
+	switch s[0] {
+	case '"', '\'':
+		return parseString(s)
+	case '[':
+		return parseArray(s)
+	}
+
+	end := 0
+	for end < len(s) && s[end] != ',' && s[end] != ']' && s[end] != '#' && s[end] != ' ' && s[end] != '\t' {
+		end++
+	}
+	tok, rest := s[:end], s[end:]
+
+	switch tok {
+	case "true":
+		return true, rest, nil
+	case "false":
+		return false, rest, nil
+	}
+
+	num := strings.Replace(tok, "_", "", -1)
+	if i, err := strconv.ParseInt(num, 0, 64); err == nil {
+		return i, rest, nil
+	}
+	if f, err := strconv.ParseFloat(num, 64); err == nil {
+		return f, rest, nil
+	}
+	return nil, "", fmt.Errorf("invalid value %q", tok)
+}
+
+// parseString parses the basic string with the escapes, or the literal string
+func parseString(s string) (string, string, error) {
+	if s[0] == '\'' {
+		end := strings.IndexByte(s[1:], '\'')
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated string %s", s)
+		}
+		return s[1 : end+1], s[end+2:], nil
+	}
+
+	var b bytes.Buffer
+	for i := 1; i < len(s); i++ {
+		c := s[i]
+		switch c {
+		case '"':
+			return b.String(), s[i+1:], nil
+		case '\\':
+			i++
+			if i == len(s) {
+				return "", "", fmt.Errorf("unterminated string %s", s)
+			}
+			switch s[i] {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '"', '\\':
+				b.WriteByte(s[i])
+			default:
+				return "", "", fmt.Errorf("invalid escape \\%c", s[i])
+			}
+		default:
+			b.WriteByte(c)
+		}
+	}
+	return "", "", fmt.Errorf("unterminated string %s", s)
+}
+
+// parseArray parses the single line array
+func parseArray(s string) ([]interface{}, string, error) {
+	arr := []interface{}{}
+	s = strings.TrimSpace(s[1:])
+	for {
+		if s == "" {
+			return nil, "", fmt.Errorf("unterminated array")
+		}
+		if s[0] == ']' {
+			return arr, s[1:], nil
+		}
+
+		v, rest, err := parseValue(s)
+		if err != nil {
+			return nil, "", err
+		}
+		arr = append(arr, v)
+
+		s = strings.TrimSpace(rest)
+		if s != "" && s[0] == ',' {
+			s = strings.TrimSpace(s[1:])
+		} else if s == "" || s[0] != ']' {
+			return nil, "", fmt.Errorf("expected , or ] in array")
+		}
+	}
+}
+
+// indexUnquoted returns the index of c outside the quoted strings
+func indexUnquoted(s string, c byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote != 0:
+			if s[i] == '\\' && quote == '"' {
+				i++
+			} else if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+func isComment(s string) bool {
+	s = strings.TrimSpace(s)
+	return s == "" || s[0] == '#'
+}
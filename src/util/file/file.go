@@ -109,44 +109,160 @@ func SaveJSON(filename string, thing interface{}, mode os.FileMode) error {
 	return err
 }
 
+// SaveJSONNoBackup write value into json file, the previous file is not backed up as
+// SaveJSON does
+func SaveJSONNoBackup(filename string, thing interface{}, mode os.FileMode) error {
+	data, err := json.MarshalIndent(thing, "", "    ")
+	if err != nil {
+		return err
+	}
+	return SaveBinaryNoBackup(filename, data, mode)
+}
+
 // SaveJSONSafe saves json to disk, but refuses if file already exists
 func SaveJSONSafe(filename string, thing interface{}, mode os.FileMode) error {
 	b, err := json.MarshalIndent(thing, "", "    ")
 	if err != nil {
 		return err
 	}
-	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
-	f, err := os.OpenFile(filename, flags, mode)
+
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("%s already exists", filename)
+	}
+
+	// the temporary file has a unique name, the concurrent saves and the temporary files left
+	// by a crash don't conflict
+	dir, base := filepath.Split(filename)
+	if dir == "" {
+		dir = "."
+	}
+	f, err := ioutil.TempFile(dir, base+".tmp")
 	if err != nil {
 		return err
 	}
-	defer f.Close()
-	n, err := f.Write(b)
-	if n != len(b) && err != nil {
-		err = errors.New("Failed to save complete file")
+	tmpname := f.Name()
+
+	if err := f.Chmod(mode); err != nil {
+		f.Close()
+		os.Remove(tmpname)
+		return err
+	}
+
+	if err := writeSync(f, b); err != nil {
+		os.Remove(tmpname)
+		return err
 	}
+
+	// the file is created exclusively, the save fails if it's created meanwhile. It's empty
+	// till the temporary file is renamed to it, it's never partially written.
+	t, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
 	if err != nil {
+		os.Remove(tmpname)
+		if os.IsExist(err) {
+			return fmt.Errorf("%s already exists", filename)
+		}
+		return err
+	}
+	t.Close()
+
+	if err := os.Rename(tmpname, filename); err != nil {
+		os.Remove(tmpname)
 		os.Remove(filename)
+		return err
 	}
-	return err
+	return syncDir(dir)
 }
 
-// SaveBinary persists data into given file in binary
+// SaveBinary persists data into given file in binary. The data is written to a temporary
+// file and synced, then renamed to the file, so that the file holds either the previous
+// or the new data if the write is interrupted. The previous file is copied to filename.bak.
 func SaveBinary(filename string, data []byte, mode os.FileMode) error {
+	return saveBinary(filename, data, mode, true)
+}
+
+// SaveBinaryNoBackup persists data into given file in binary as SaveBinary, but the
+// previous file is not backed up and filename.bak of the earlier saves is removed. It's
+// used if the previous data must not remain on the disk.
+func SaveBinaryNoBackup(filename string, data []byte, mode os.FileMode) error {
+	if err := saveBinary(filename, data, mode, false); err != nil {
+		return err
+	}
+
+	if err := os.Remove(filename + ".bak"); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func saveBinary(filename string, data []byte, mode os.FileMode, backup bool) error {
 	// Write the new file to a temporary
 	tmpname := filename + ".tmp"
-	if err := ioutil.WriteFile(tmpname, data, mode); err != nil {
+	if err := writeFileSync(tmpname, data, mode); err != nil {
+		os.Remove(tmpname)
 		return err
 	}
+
 	// Backup the previous file, if there was one
-	_, err := os.Stat(filename)
-	if !os.IsNotExist(err) {
-		if err := os.Rename(filename, filename+".bak"); err != nil {
+	if fi, err := os.Stat(filename); err == nil && backup {
+		old, err := ioutil.ReadFile(filename)
+		if err != nil {
+			os.Remove(tmpname)
+			return err
+		}
+
+		if err := writeFileSync(filename+".bak", old, fi.Mode().Perm()); err != nil {
+			os.Remove(tmpname)
 			return err
 		}
+	} else if err != nil && !os.IsNotExist(err) {
+		os.Remove(tmpname)
+		return err
+	}
+
+	// Move the temporary to the new file, replacing the previous one atomically
+	if err := os.Rename(tmpname, filename); err != nil {
+		os.Remove(tmpname)
+		return err
+	}
+	return syncDir(filepath.Dir(filename))
+}
+
+// writeFileSync writes the data to the file and syncs it to the disk
+func writeFileSync(filename string, data []byte, mode os.FileMode) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, mode)
+	if err != nil {
+		return err
+	}
+	return writeSync(f, data)
+}
+
+// writeSync writes the data to the open file, syncs it to the disk and closes it
+func writeSync(f *os.File, data []byte) error {
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// syncDir syncs the directory, so that the renamed file persists. It's not supported on
+// windows, where the error is ignored.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	if err := d.Sync(); err != nil && runtime.GOOS != "windows" {
+		return err
 	}
-	// Move the temporary to the new file
-	return os.Rename(tmpname, filename)
+	return nil
 }
 
 //TODO: require file named after application and then hashcode, in static directory
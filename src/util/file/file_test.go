@@ -3,6 +3,8 @@ package file
 import (
 	"bytes"
 	"crypto/rand"
+	"fmt"
+	"io/ioutil"
 	"os"
 	"path/filepath"
 	"strings"
@@ -184,3 +186,75 @@ func TestSaveBinary(t *testing.T) {
 	requireFileMode(t, fn, 0644)
 	requireFileMode(t, fn+".bak", 0644)
 }
+
+func TestSaveBinaryNoBackup(t *testing.T) {
+	fn := "test.bin"
+	defer cleanup(fn)
+	b := make([]byte, 128)
+	rand.Read(b)
+	require.Nil(t, SaveBinary(fn, b, 0600))
+	require.Nil(t, SaveBinary(fn, b, 0600))
+	requireFileExists(t, fn+".bak")
+
+	// the previous file is not backed up and the earlier backup is removed
+	b2 := make([]byte, 128)
+	rand.Read(b2)
+	require.Nil(t, SaveBinaryNoBackup(fn, b2, 0600))
+	requireFileExists(t, fn)
+	requireFileNotExists(t, fn+".bak")
+	requireFileNotExists(t, fn+".tmp")
+	requireFileContentsBinary(t, fn, b2)
+	requireFileMode(t, fn, 0600)
+}
+
+func TestSaveJSONSafeTemp(t *testing.T) {
+	dir, err := ioutil.TempDir("", "savejsonsafe")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	fn := filepath.Join(dir, "test.json")
+	obj := struct {
+		Key string `json:"key"`
+	}{Key: "value"}
+
+	// the temporary file left by a crash doesn't break the save
+	require.Nil(t, ioutil.WriteFile(fn+".tmp", []byte("stale"), 0600))
+	require.Nil(t, SaveJSONSafe(fn, obj, 0600))
+	requireFileMode(t, fn, 0600)
+	loaded := struct {
+		Key string `json:"key"`
+	}{}
+	require.Nil(t, LoadJSON(fn, &loaded))
+	require.Equal(t, "value", loaded.Key)
+
+	// the existing file isn't replaced, no temporary file is left
+	obj.Key = "value2"
+	require.NotNil(t, SaveJSONSafe(fn, obj, 0600))
+	require.Nil(t, LoadJSON(fn, &loaded))
+	require.Equal(t, "value", loaded.Key)
+	tmps, err := filepath.Glob(fn + ".tmp?*")
+	require.Nil(t, err)
+	require.Empty(t, tmps)
+
+	// one of the concurrent saves of the file succeeds, the file is complete
+	fn = filepath.Join(dir, "race.json")
+	errs := make(chan error, 10)
+	for i := 0; i < 10; i++ {
+		go func(i int) {
+			errs <- SaveJSONSafe(fn, struct {
+				Key string `json:"key"`
+			}{Key: fmt.Sprint(i)}, 0600)
+		}(i)
+	}
+	var saved int
+	for i := 0; i < 10; i++ {
+		if <-errs == nil {
+			saved++
+		}
+	}
+	require.Equal(t, 1, saved)
+	require.Nil(t, LoadJSON(fn, &loaded))
+	require.NotEmpty(t, loaded.Key)
+	tmps, err = filepath.Glob(fn + ".tmp?*")
+	require.Nil(t, err)
+	require.Empty(t, tmps)
+}
@@ -0,0 +1,520 @@
+// Package graphql implements the subset of GraphQL needed to query the nested read only data:
+// the queries with the arguments, the variables, the aliases and the fragments. The mutations,
+// the subscriptions, the directives and the introspection aren't supported.
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+)
+
+// The scalar types of the arguments
+const (
+	Int     = "Int"
+	String  = "String"
+	Boolean = "Boolean"
+)
+
+// DefaultMaxDepth is the max depth of the objects selected if the schema doesn't set it
+const DefaultMaxDepth = 10
+
+// Schema is the schema of the queries
+type Schema struct {
+	Query *Object
+	// the max depth of the objects selected, the queries deeper are rejected
+	MaxDepth int
+}
+
+// Object is an object type
+type Object struct {
+	Name   string
+	Fields map[string]*FieldDef
+}
+
+// FieldDef defines a field of an object
+type FieldDef struct {
+	// the object type of the field, nil if the field is a scalar
+	Type *Object
+	// the field is a list of Type
+	List bool
+	Args map[string]Arg
+	// resolves the field from its parent. If nil, the field is resolved as the struct field
+	// of the same json name, or the map value of the same key.
+	Resolve func(p Params) (interface{}, error)
+}
+
+// Arg defines an argument of a field
+type Arg struct {
+	// Int, String or Boolean
+	Type     string
+	Required bool
+}
+
+// Params are the params of the resolvers
+type Params struct {
+	// the value of the parent object
+	Source interface{}
+	// the arguments set, the Int arguments are int
+	Args map[string]interface{}
+}
+
+// Request is a GraphQL request
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+}
+
+// Error is an error of the result, the path is the response path of the field failed
+type Error struct {
+	Message string        `json:"message"`
+	Path    []interface{} `json:"path,omitempty"`
+}
+
+// Result is the result of a request, the data is null if the request is invalid
+type Result struct {
+	Data   interface{} `json:"data"`
+	Errors []Error     `json:"errors,omitempty"`
+}
+
+func errorResult(format string, args ...interface{}) *Result {
+	return &Result{Errors: []Error{{Message: fmt.Sprintf(format, args...)}}}
+}
+
+// Do executes the request, the errors of the query are returned in the result
+func (s *Schema) Do(req Request) *Result {
+	doc, err := Parse(req.Query)
+	if err != nil {
+		return errorResult("%v", err)
+	}
+
+	op, err := doc.operation(req.OperationName)
+	if err != nil {
+		return errorResult("%v", err)
+	}
+
+	if op.Type != "query" {
+		return errorResult("%s is not supported", op.Type)
+	}
+
+	vars, err := coerceVariables(op.Variables, req.Variables)
+	if err != nil {
+		return errorResult("%v", err)
+	}
+
+	maxDepth := s.MaxDepth
+	if maxDepth == 0 {
+		maxDepth = DefaultMaxDepth
+	}
+	v := &validator{doc: doc, op: op, maxDepth: maxDepth}
+	v.validate(s.Query, op.Selections, 1, nil)
+	if len(v.errs) != 0 {
+		return &Result{Errors: v.errs}
+	}
+
+	e := &executor{doc: doc, vars: vars}
+	data := e.execute(s.Query, nil, op.Selections, nil)
+	return &Result{Data: data, Errors: e.errs}
+}
+
+func (d *Document) operation(name string) (*Operation, error) {
+	if name == "" {
+		if len(d.Operations) != 1 {
+			return nil, fmt.Errorf("operationName is required if the query has more than one operation")
+		}
+		return d.Operations[0], nil
+	}
+
+	for _, op := range d.Operations {
+		if op.Name == name {
+			return op, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown operation %q", name)
+}
+
+func coerceVariables(defs []VariableDefinition, values map[string]interface{}) (map[string]interface{}, error) {
+	vars := make(map[string]interface{}, len(defs))
+	for _, d := range defs {
+		v, ok := values[d.Name]
+		if !ok {
+			v = d.Default
+		}
+		if v == nil {
+			if d.Required {
+				return nil, fmt.Errorf("variable $%s of type %s is required", d.Name, d.Type)
+			}
+			continue
+		}
+		vars[d.Name] = v
+	}
+	return vars, nil
+}
+
+// collectFields flattens the fragments of the selections, the fields of the same response key
+// are merged
+func (d *Document) collectFields(sels []Selection, fields []*Field) []*Field {
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *Field:
+			merged := false
+			for i, f := range fields {
+				if f.responseKey() == s.responseKey() {
+					m := *f
+					m.Selections = append(append([]Selection{}, f.Selections...), s.Selections...)
+					fields[i] = &m
+					merged = true
+					break
+				}
+			}
+			if !merged {
+				fields = append(fields, s)
+			}
+		case *FragmentSpread:
+			fields = d.collectFields(d.Fragments[s.Name].Selections, fields)
+		case *InlineFragment:
+			fields = d.collectFields(s.Selections, fields)
+		}
+	}
+	return fields
+}
+
+func (f *Field) responseKey() string {
+	if f.Alias != "" {
+		return f.Alias
+	}
+	return f.Name
+}
+
+// validator checks the query against the schema before it's executed
+type validator struct {
+	doc      *Document
+	op       *Operation
+	maxDepth int
+	errs     []Error
+}
+
+func (v *validator) errorf(format string, args ...interface{}) {
+	v.errs = append(v.errs, Error{Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *validator) validate(obj *Object, sels []Selection, depth int, spreads []string) {
+	if depth > v.maxDepth {
+		v.errorf("query is deeper than %d", v.maxDepth)
+		return
+	}
+
+	for _, sel := range sels {
+		switch s := sel.(type) {
+		case *Field:
+			v.validateField(obj, s, depth, spreads)
+		case *FragmentSpread:
+			f, ok := v.doc.Fragments[s.Name]
+			if !ok {
+				v.errorf("unknown fragment %q", s.Name)
+				continue
+			}
+			if f.TypeCondition != obj.Name {
+				v.errorf("fragment %q on %s can't be spread on %s", s.Name, f.TypeCondition, obj.Name)
+				continue
+			}
+			for _, name := range spreads {
+				if name == s.Name {
+					v.errorf("fragment %q spreads itself", s.Name)
+					return
+				}
+			}
+			v.validate(obj, f.Selections, depth, append(spreads, s.Name))
+		case *InlineFragment:
+			if s.TypeCondition != "" && s.TypeCondition != obj.Name {
+				v.errorf("fragment on %s can't be spread on %s", s.TypeCondition, obj.Name)
+				continue
+			}
+			v.validate(obj, s.Selections, depth, spreads)
+		}
+	}
+}
+
+func (v *validator) validateField(obj *Object, f *Field, depth int, spreads []string) {
+	if f.Name == "__typename" {
+		if len(f.Arguments) != 0 || len(f.Selections) != 0 {
+			v.errorf("field __typename has no arguments and selections")
+		}
+		return
+	}
+
+	def, ok := obj.Fields[f.Name]
+	if !ok {
+		v.errorf("cannot query field %q on type %s", f.Name, obj.Name)
+		return
+	}
+
+	for _, a := range f.Arguments {
+		if _, ok := def.Args[a.Name]; !ok {
+			v.errorf("unknown argument %q of field %s.%s", a.Name, obj.Name, f.Name)
+		}
+		v.validateVariables(a.Value)
+	}
+
+	switch {
+	case def.Type == nil && len(f.Selections) != 0:
+		v.errorf("field %s.%s is a scalar, it has no selections", obj.Name, f.Name)
+	case def.Type != nil && len(f.Selections) == 0:
+		v.errorf("field %s.%s of type %s must have selections", obj.Name, f.Name, def.Type.Name)
+	case def.Type != nil:
+		v.validate(def.Type, f.Selections, depth+1, spreads)
+	}
+}
+
+func (v *validator) validateVariables(value interface{}) {
+	switch x := value.(type) {
+	case Variable:
+		if !v.defined(string(x)) {
+			v.errorf("variable $%s is not defined by operation", x)
+		}
+	case []interface{}:
+		for _, e := range x {
+			v.validateVariables(e)
+		}
+	case map[string]interface{}:
+		for _, e := range x {
+			v.validateVariables(e)
+		}
+	}
+}
+
+func (v *validator) defined(name string) bool {
+	for _, d := range v.op.Variables {
+		if d.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// executor resolves the fields of the query
+type executor struct {
+	doc  *Document
+	vars map[string]interface{}
+	errs []Error
+}
+
+func (e *executor) fieldError(path []interface{}, err error) {
+	e.errs = append(e.errs, Error{
+		Message: err.Error(),
+		Path:    append([]interface{}{}, path...),
+	})
+}
+
+func (e *executor) execute(obj *Object, source interface{}, sels []Selection, path []interface{}) orderedMap {
+	fields := e.doc.collectFields(sels, nil)
+	m := make(orderedMap, 0, len(fields))
+	for _, f := range fields {
+		key := f.responseKey()
+		fpath := append(path[:len(path):len(path)], key)
+
+		if f.Name == "__typename" {
+			m = append(m, orderedField{key, obj.Name})
+			continue
+		}
+
+		def := obj.Fields[f.Name]
+		args, err := e.coerceArgs(def, f)
+		if err != nil {
+			e.fieldError(fpath, err)
+			m = append(m, orderedField{key, nil})
+			continue
+		}
+
+		var v interface{}
+		if def.Resolve != nil {
+			v, err = def.Resolve(Params{Source: source, Args: args})
+		} else {
+			v, err = resolveByName(source, f.Name)
+		}
+		if err != nil {
+			e.fieldError(fpath, err)
+			m = append(m, orderedField{key, nil})
+			continue
+		}
+
+		m = append(m, orderedField{key, e.complete(def, f, v, fpath)})
+	}
+	return m
+}
+
+// complete resolves the selections of the objects of the value
+func (e *executor) complete(def *FieldDef, f *Field, v interface{}, path []interface{}) interface{} {
+	if def.Type == nil || isNil(v) {
+		return v
+	}
+
+	if !def.List {
+		return e.execute(def.Type, v, f.Selections, path)
+	}
+
+	rv := reflect.ValueOf(v)
+	if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+		e.fieldError(path, fmt.Errorf("%s is not a list", f.Name))
+		return nil
+	}
+
+	l := make([]interface{}, rv.Len())
+	for i := range l {
+		ev := rv.Index(i).Interface()
+		if !isNil(ev) {
+			l[i] = e.execute(def.Type, ev, f.Selections, append(path[:len(path):len(path)], i))
+		}
+	}
+	return l
+}
+
+func (e *executor) coerceArgs(def *FieldDef, f *Field) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(def.Args))
+	for _, a := range f.Arguments {
+		v := a.Value
+		if name, ok := v.(Variable); ok {
+			v = e.vars[string(name)]
+		}
+		if v == nil {
+			continue
+		}
+
+		cv, err := coerceArg(def.Args[a.Name].Type, v)
+		if err != nil {
+			return nil, fmt.Errorf("argument %q: %v", a.Name, err)
+		}
+		args[a.Name] = cv
+	}
+
+	for name, a := range def.Args {
+		if _, ok := args[name]; !ok && a.Required {
+			return nil, fmt.Errorf("argument %q is required", name)
+		}
+	}
+	return args, nil
+}
+
+func coerceArg(typ string, v interface{}) (interface{}, error) {
+	switch typ {
+	case Int:
+		switch n := v.(type) {
+		case int64:
+			return int(n), nil
+		case float64:
+			// the numbers of the json variables
+			if n == math.Trunc(n) && math.Abs(n) <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+	case String:
+		if s, ok := v.(string); ok {
+			return s, nil
+		}
+	case Boolean:
+		if b, ok := v.(bool); ok {
+			return b, nil
+		}
+	default:
+		return nil, fmt.Errorf("unknown type %s", typ)
+	}
+	return nil, fmt.Errorf("expected %s, got %v", typ, v)
+}
+
+// resolveByName resolves the field as the struct field of the same json name or the map value
+func resolveByName(source interface{}, name string) (interface{}, error) {
+	rv := reflect.ValueOf(source)
+	for rv.Kind() == reflect.Ptr {
+		if rv.IsNil() {
+			return nil, nil
+		}
+		rv = rv.Elem()
+	}
+
+	switch rv.Kind() {
+	case reflect.Map:
+		if rv.Type().Key().Kind() == reflect.String {
+			v := rv.MapIndex(reflect.ValueOf(name))
+			if !v.IsValid() {
+				return nil, nil
+			}
+			return v.Interface(), nil
+		}
+	case reflect.Struct:
+		if v, ok := structField(rv, name); ok {
+			return v.Interface(), nil
+		}
+	}
+	return nil, fmt.Errorf("can't resolve field %s of %T", name, source)
+}
+
+// structField finds the field of the json name, the fields of the embedded structs are promoted
+// like the json encoding
+func structField(rv reflect.Value, name string) (reflect.Value, bool) {
+	t := rv.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag := strings.Split(sf.Tag.Get("json"), ",")[0]
+		if sf.Anonymous && tag == "" && sf.Type.Kind() == reflect.Struct {
+			if v, ok := structField(rv.Field(i), name); ok {
+				return v, true
+			}
+			continue
+		}
+		if sf.PkgPath != "" || tag == "-" {
+			continue
+		}
+		if tag == name || (tag == "" && sf.Name == name) {
+			return rv.Field(i), true
+		}
+	}
+	return reflect.Value{}, false
+}
+
+func isNil(v interface{}) bool {
+	if v == nil {
+		return true
+	}
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// orderedMap is an object of the result, the fields are encoded in the order of the query
+type orderedMap []orderedField
+
+type orderedField struct {
+	key   string
+	value interface{}
+}
+
+// MarshalJSON encodes the fields in order
+func (m orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, f := range m {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, err := json.Marshal(f.key)
+		if err != nil {
+			return nil, err
+		}
+		v, err := json.Marshal(f.value)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
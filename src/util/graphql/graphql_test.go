@@ -0,0 +1,286 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testBlock struct {
+	Seq  uint64   `json:"seq"`
+	Hash string   `json:"hash"`
+	Txns []string `json:"-"`
+}
+
+type testTxnHeader struct {
+	ID string `json:"txid"`
+}
+
+type testTxn struct {
+	testTxnHeader
+	Inputs []string
+	fee    uint64
+}
+
+func testSchema() *Schema {
+	blocks := []*testBlock{
+		{Seq: 0, Hash: "h0", Txns: []string{"t0"}},
+		{Seq: 1, Hash: "h1", Txns: []string{"t1", "t2"}},
+	}
+	txns := map[string]*testTxn{
+		"t0": {testTxnHeader: testTxnHeader{"t0"}},
+		"t1": {testTxnHeader: testTxnHeader{"t1"}, Inputs: []string{"t0"}},
+		"t2": {testTxnHeader: testTxnHeader{"t2"}, Inputs: []string{"t1", "gone"}},
+	}
+
+	txn := &Object{Name: "Transaction"}
+	txn.Fields = map[string]*FieldDef{
+		"txid": {},
+		"fee":  {},
+		"inputs": {
+			Type: txn,
+			List: true,
+			Resolve: func(p Params) (interface{}, error) {
+				var ins []*testTxn
+				for _, id := range p.Source.(*testTxn).Inputs {
+					ins = append(ins, txns[id])
+				}
+				return ins, nil
+			},
+		},
+		"broken": {
+			Resolve: func(p Params) (interface{}, error) {
+				return nil, errors.New("broken")
+			},
+		},
+	}
+
+	block := &Object{
+		Name: "Block",
+		Fields: map[string]*FieldDef{
+			"seq":  {},
+			"hash": {},
+			"transactions": {
+				Type: txn,
+				List: true,
+				Resolve: func(p Params) (interface{}, error) {
+					var l []*testTxn
+					for _, id := range p.Source.(*testBlock).Txns {
+						l = append(l, txns[id])
+					}
+					return l, nil
+				},
+			},
+		},
+	}
+
+	return &Schema{
+		MaxDepth: 4,
+		Query: &Object{
+			Name: "Query",
+			Fields: map[string]*FieldDef{
+				"block": {
+					Type: block,
+					Args: map[string]Arg{
+						"seq":  {Type: Int, Required: true},
+						"hash": {Type: String},
+					},
+					Resolve: func(p Params) (interface{}, error) {
+						seq := p.Args["seq"].(int)
+						if seq < 0 {
+							return nil, fmt.Errorf("invalid seq %d", seq)
+						}
+						if seq >= len(blocks) {
+							return nil, nil
+						}
+						return blocks[seq], nil
+					},
+				},
+				"echo": {
+					Args: map[string]Arg{
+						"s": {Type: String},
+						"b": {Type: Boolean},
+					},
+					Resolve: func(p Params) (interface{}, error) {
+						return p.Args, nil
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestDo(t *testing.T) {
+	s := testSchema()
+
+	tests := []struct {
+		name   string
+		req    Request
+		result string
+	}{
+		{
+			"nested",
+			Request{Query: `{ block(seq: 1) { seq hash transactions { txid inputs { txid } } } }`},
+			`{"data":{"block":{"seq":1,"hash":"h1","transactions":[` +
+				`{"txid":"t1","inputs":[{"txid":"t0"}]},` +
+				`{"txid":"t2","inputs":[{"txid":"t1"},null]}]}}}`,
+		},
+		{
+			"aliases, fragments and typename",
+			Request{Query: `
+				# the comment
+				query Blocks {
+					genesis: block(seq: 0) { ...Head, transactions { ... on Transaction { txid } } }
+					second: block(seq: 1) { __typename ...Head }
+					missing: block(seq: 9) { seq }
+				}
+				fragment Head on Block { seq hash }`},
+			`{"data":{"genesis":{"seq":0,"hash":"h0","transactions":[{"txid":"t0"}]},` +
+				`"second":{"__typename":"Block","seq":1,"hash":"h1"},"missing":null}}`,
+		},
+		{
+			"merged fields",
+			Request{Query: `{ block(seq: 1) { seq } block(seq: 1) { hash } }`},
+			`{"data":{"block":{"seq":1,"hash":"h1"}}}`,
+		},
+		{
+			"variables",
+			Request{
+				Query:         `query A { echo(s: "a") } query B($seq: Int!, $s: String = "xé\n", $b: Boolean) { block(seq: $seq) { seq } echo(s: $s, b: $b) }`,
+				OperationName: "B",
+				Variables:     map[string]interface{}{"seq": float64(1)},
+			},
+			`{"data":{"block":{"seq":1},"echo":{"s":"xé\n"}}}`,
+		},
+		{
+			"field errors",
+			Request{Query: `{ block(seq: 1) { transactions { broken } } bad: block(seq: -1) { seq } echo(s: 1) }`},
+			`{"data":{"block":{"transactions":[{"broken":null},{"broken":null}]},"bad":null,"echo":null},"errors":[` +
+				`{"message":"broken","path":["block","transactions",0,"broken"]},` +
+				`{"message":"broken","path":["block","transactions",1,"broken"]},` +
+				`{"message":"invalid seq -1","path":["bad"]},` +
+				`{"message":"argument \"s\": expected String, got 1","path":["echo"]}]}`,
+		},
+		{
+			"unexported field",
+			Request{Query: `{ block(seq: 0) { transactions { fee } } }`},
+			`{"data":{"block":{"transactions":[{"fee":null}]}},"errors":[` +
+				`{"message":"can't resolve field fee of *graphql.testTxn","path":["block","transactions",0,"fee"]}]}`,
+		},
+		{
+			"required argument",
+			Request{Query: `{ block { seq } }`},
+			`{"data":{"block":null},"errors":[{"message":"argument \"seq\" is required","path":["block"]}]}`,
+		},
+		{
+			"validation",
+			Request{Query: `{ block(seq: 1, foo: 1) { seq nope } echo { x } }`},
+			`{"data":null,"errors":[` +
+				`{"message":"unknown argument \"foo\" of field Query.block"},` +
+				`{"message":"cannot query field \"nope\" on type Block"},` +
+				`{"message":"field Query.echo is a scalar, it has no selections"}]}`,
+		},
+		{
+			"missing selections",
+			Request{Query: `{ block(seq: 1) }`},
+			`{"data":null,"errors":[{"message":"field Query.block of type Block must have selections"}]}`,
+		},
+		{
+			"too deep",
+			Request{Query: `{ block(seq: 1) { transactions { inputs { inputs { txid } } } } }`},
+			`{"data":null,"errors":[{"message":"query is deeper than 4"}]}`,
+		},
+		{
+			"fragment cycle",
+			Request{Query: `{ block(seq: 1) { ...A } } fragment A on Block { ...B } fragment B on Block { ...A }`},
+			`{"data":null,"errors":[{"message":"fragment \"A\" spreads itself"}]}`,
+		},
+		{
+			"fragment type",
+			Request{Query: `{ block(seq: 1) { ...T } } fragment T on Transaction { txid }`},
+			`{"data":null,"errors":[{"message":"fragment \"T\" on Transaction can't be spread on Block"}]}`,
+		},
+		{
+			"undefined variable",
+			Request{Query: `{ block(seq: $seq) { seq } }`},
+			`{"data":null,"errors":[{"message":"variable $seq is not defined by operation"}]}`,
+		},
+		{
+			"required variable",
+			Request{Query: `query ($seq: Int!) { block(seq: $seq) { seq } }`},
+			`{"data":null,"errors":[{"message":"variable $seq of type Int! is required"}]}`,
+		},
+		{
+			"operation name",
+			Request{Query: `query A { echo } query B { echo }`},
+			`{"data":null,"errors":[{"message":"operationName is required if the query has more than one operation"}]}`,
+		},
+		{
+			"mutation",
+			Request{Query: `mutation { echo }`},
+			`{"data":null,"errors":[{"message":"mutation is not supported"}]}`,
+		},
+		{
+			"syntax",
+			Request{Query: `{ block(seq: 1) { seq }`},
+			`{"data":null,"errors":[{"message":"syntax error at 23: unexpected end of query"}]}`,
+		},
+		{
+			"directives",
+			Request{Query: `{ block(seq: 1) @skip(if: true) { seq } }`},
+			`{"data":null,"errors":[{"message":"syntax error at 16: directives are not supported"}]}`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d, err := json.Marshal(s.Do(tc.req))
+			require.NoError(t, err)
+			require.Equal(t, tc.result, string(d))
+		})
+	}
+}
+
+func TestParse(t *testing.T) {
+	doc, err := Parse(`query Q($ids: [String!]! = ["a"], $n: Int = -1) {
+		a: f(x: 1.5e3, y: [1, "s", ENUM, null, {k: true}], z: $n) { g }
+	}`)
+	require.NoError(t, err)
+	require.Len(t, doc.Operations, 1)
+
+	op := doc.Operations[0]
+	require.Equal(t, "Q", op.Name)
+	require.Equal(t, []VariableDefinition{
+		{Name: "ids", Type: "[String!]!", Required: true, Default: []interface{}{"a"}},
+		{Name: "n", Type: "Int", Default: int64(-1)},
+	}, op.Variables)
+
+	f := op.Selections[0].(*Field)
+	require.Equal(t, "a", f.Alias)
+	require.Equal(t, "f", f.Name)
+	require.Equal(t, []Argument{
+		{"x", 1500.0},
+		{"y", []interface{}{int64(1), "s", Enum("ENUM"), nil, map[string]interface{}{"k": true}}},
+		{"z", Variable("n")},
+	}, f.Arguments)
+	require.Equal(t, []Selection{&Field{Name: "g"}}, f.Selections)
+
+	for _, q := range []string{
+		"",
+		"{}",
+		"{ a(",
+		`{ a(x: "b) }`,
+		`{ a(x: """b""") }`,
+		`{ a(x: "\q") }`,
+		"{ a.b }",
+		"query ($x: Int = $y) { a }",
+		"fragment on on A { a }",
+		"fragment A on B { a } fragment A on B { a } { a }",
+	} {
+		_, err := Parse(q)
+		require.Error(t, err, q)
+	}
+}
@@ -0,0 +1,528 @@
+package graphql
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// MaxQuerySize is the max size of the query parsed
+const MaxQuerySize = 64 * 1024
+
+// Selection is a field, a fragment spread or an inline fragment of a selection set
+type Selection interface {
+	selection()
+}
+
+// Field is a field of a selection set
+type Field struct {
+	Alias      string
+	Name       string
+	Arguments  []Argument
+	Selections []Selection
+}
+
+// Argument is an argument of a field
+type Argument struct {
+	Name  string
+	Value interface{}
+}
+
+// Variable is a variable used as an argument value
+type Variable string
+
+// Enum is an enum value
+type Enum string
+
+// FragmentSpread is the spread of a named fragment, ...Name
+type FragmentSpread struct {
+	Name string
+}
+
+// InlineFragment is the fragment of a selection set, ... on Type { }
+type InlineFragment struct {
+	TypeCondition string
+	Selections    []Selection
+}
+
+func (*Field) selection()          {}
+func (*FragmentSpread) selection() {}
+func (*InlineFragment) selection() {}
+
+// VariableDefinition is a variable of an operation
+type VariableDefinition struct {
+	Name     string
+	Type     string
+	Required bool
+	Default  interface{}
+}
+
+// Operation is an operation of a document
+type Operation struct {
+	// query or mutation
+	Type       string
+	Name       string
+	Variables  []VariableDefinition
+	Selections []Selection
+}
+
+// Fragment is a named fragment of a document
+type Fragment struct {
+	Name          string
+	TypeCondition string
+	Selections    []Selection
+}
+
+// Document is a parsed query
+type Document struct {
+	Operations []*Operation
+	Fragments  map[string]*Fragment
+}
+
+// SyntaxError is the error of the query syntax
+type SyntaxError struct {
+	Msg    string
+	Offset int
+}
+
+func (e SyntaxError) Error() string {
+	return fmt.Sprintf("syntax error at %d: %s", e.Offset, e.Msg)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind   tokenKind
+	value  string
+	offset int
+}
+
+type parser struct {
+	src string
+	pos int
+	tok token
+}
+
+// Parse parses the query document, the directives and the block strings aren't supported
+func Parse(query string) (doc *Document, err error) {
+	if len(query) > MaxQuerySize {
+		return nil, SyntaxError{"query is too large", 0}
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			se, ok := r.(SyntaxError)
+			if !ok {
+				panic(r)
+			}
+			doc = nil
+			err = se
+		}
+	}()
+
+	p := &parser{src: query}
+	p.next()
+
+	doc = &Document{Fragments: make(map[string]*Fragment)}
+	for p.tok.kind != tokenEOF {
+		switch {
+		case p.peek(tokenPunct, "{"):
+			doc.Operations = append(doc.Operations, &Operation{
+				Type:       "query",
+				Selections: p.parseSelectionSet(),
+			})
+		case p.peek(tokenName, "query"), p.peek(tokenName, "mutation"), p.peek(tokenName, "subscription"):
+			doc.Operations = append(doc.Operations, p.parseOperation())
+		case p.peek(tokenName, "fragment"):
+			f := p.parseFragment()
+			if _, ok := doc.Fragments[f.Name]; ok {
+				p.fail(fmt.Sprintf("fragment %s is defined more than once", f.Name))
+			}
+			doc.Fragments[f.Name] = f
+		default:
+			p.unexpected()
+		}
+	}
+
+	if len(doc.Operations) == 0 {
+		return nil, SyntaxError{"no operation", 0}
+	}
+	return doc, nil
+}
+
+func (p *parser) fail(msg string) {
+	panic(SyntaxError{msg, p.tok.offset})
+}
+
+func (p *parser) unexpected() {
+	if p.tok.kind == tokenEOF {
+		p.fail("unexpected end of query")
+	}
+	p.fail(fmt.Sprintf("unexpected %q", p.tok.value))
+}
+
+func (p *parser) peek(kind tokenKind, value string) bool {
+	return p.tok.kind == kind && p.tok.value == value
+}
+
+// skip skips the token if it's the punctuator
+func (p *parser) skip(punct string) bool {
+	if p.peek(tokenPunct, punct) {
+		p.next()
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(punct string) {
+	if !p.skip(punct) {
+		p.unexpected()
+	}
+}
+
+func (p *parser) name() string {
+	if p.tok.kind != tokenName {
+		p.unexpected()
+	}
+	v := p.tok.value
+	p.next()
+	return v
+}
+
+func (p *parser) parseOperation() *Operation {
+	op := &Operation{Type: p.name()}
+	if p.tok.kind == tokenName {
+		op.Name = p.name()
+	}
+
+	if p.skip("(") {
+		for !p.skip(")") {
+			op.Variables = append(op.Variables, p.parseVariableDefinition())
+		}
+	}
+
+	p.parseDirectives()
+	op.Selections = p.parseSelectionSet()
+	return op
+}
+
+func (p *parser) parseVariableDefinition() VariableDefinition {
+	p.expect("$")
+	v := VariableDefinition{Name: p.name()}
+	p.expect(":")
+
+	v.Type = p.parseType()
+	v.Required = strings.HasSuffix(v.Type, "!")
+	if p.skip("=") {
+		v.Default = p.parseValue(true)
+	}
+	return v
+}
+
+// parseType parses the type of a variable, the list types are kept as [Type]
+func (p *parser) parseType() string {
+	var t string
+	if p.skip("[") {
+		t = "[" + p.parseType() + "]"
+		p.expect("]")
+	} else {
+		t = p.name()
+	}
+	if p.skip("!") {
+		t += "!"
+	}
+	return t
+}
+
+func (p *parser) parseFragment() *Fragment {
+	p.next()
+	f := &Fragment{Name: p.name()}
+	if f.Name == "on" {
+		p.fail("fragment can't be named on")
+	}
+	if p.name() != "on" {
+		p.fail("expected on")
+	}
+	f.TypeCondition = p.name()
+	p.parseDirectives()
+	f.Selections = p.parseSelectionSet()
+	return f
+}
+
+func (p *parser) parseDirectives() {
+	if p.peek(tokenPunct, "@") {
+		p.fail("directives are not supported")
+	}
+}
+
+func (p *parser) parseSelectionSet() []Selection {
+	p.expect("{")
+	var sels []Selection
+	for !p.skip("}") {
+		sels = append(sels, p.parseSelection())
+	}
+	if len(sels) == 0 {
+		p.fail("empty selection set")
+	}
+	return sels
+}
+
+func (p *parser) parseSelection() Selection {
+	if p.skip("...") {
+		if p.peek(tokenName, "on") {
+			p.next()
+			f := &InlineFragment{TypeCondition: p.name()}
+			p.parseDirectives()
+			f.Selections = p.parseSelectionSet()
+			return f
+		}
+		if p.peek(tokenPunct, "{") {
+			return &InlineFragment{Selections: p.parseSelectionSet()}
+		}
+		s := &FragmentSpread{Name: p.name()}
+		p.parseDirectives()
+		return s
+	}
+
+	f := &Field{Name: p.name()}
+	if p.skip(":") {
+		f.Alias = f.Name
+		f.Name = p.name()
+	}
+
+	if p.skip("(") {
+		for !p.skip(")") {
+			a := Argument{Name: p.name()}
+			p.expect(":")
+			a.Value = p.parseValue(false)
+			f.Arguments = append(f.Arguments, a)
+		}
+	}
+
+	p.parseDirectives()
+	if p.peek(tokenPunct, "{") {
+		f.Selections = p.parseSelectionSet()
+	}
+	return f
+}
+
+// parseValue parses a value, the variables are Variable and the enums are Enum
+func (p *parser) parseValue(constant bool) interface{} {
+	tok := p.tok
+	switch tok.kind {
+	case tokenInt:
+		p.next()
+		n, err := strconv.ParseInt(tok.value, 10, 64)
+		if err != nil {
+			panic(SyntaxError{fmt.Sprintf("invalid int %s", tok.value), tok.offset})
+		}
+		return n
+	case tokenFloat:
+		p.next()
+		f, err := strconv.ParseFloat(tok.value, 64)
+		if err != nil {
+			panic(SyntaxError{fmt.Sprintf("invalid float %s", tok.value), tok.offset})
+		}
+		return f
+	case tokenString:
+		p.next()
+		return tok.value
+	case tokenName:
+		p.next()
+		switch tok.value {
+		case "true":
+			return true
+		case "false":
+			return false
+		case "null":
+			return nil
+		}
+		return Enum(tok.value)
+	}
+
+	switch {
+	case p.skip("$"):
+		if constant {
+			panic(SyntaxError{"variable in constant value", tok.offset})
+		}
+		return Variable(p.name())
+	case p.skip("["):
+		l := []interface{}{}
+		for !p.skip("]") {
+			l = append(l, p.parseValue(constant))
+		}
+		return l
+	case p.skip("{"):
+		m := map[string]interface{}{}
+		for !p.skip("}") {
+			k := p.name()
+			p.expect(":")
+			m[k] = p.parseValue(constant)
+		}
+		return m
+	}
+
+	p.unexpected()
+	return nil
+}
+
+// next reads the next token, the whitespaces, the commas and the comments are ignored
+func (p *parser) next() {
+	for p.pos < len(p.src) {
+		c := p.src[p.pos]
+		if c == '#' {
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' && p.src[p.pos] != '\r' {
+				p.pos++
+			}
+			continue
+		}
+		if c != ' ' && c != '\t' && c != '\n' && c != '\r' && c != ',' {
+			break
+		}
+		p.pos++
+	}
+
+	start := p.pos
+	if p.pos >= len(p.src) {
+		p.tok = token{kind: tokenEOF, offset: start}
+		return
+	}
+
+	c := p.src[p.pos]
+	switch {
+	case strings.IndexByte("!$():=@[]{}|", c) >= 0:
+		p.pos++
+		p.tok = token{tokenPunct, string(c), start}
+	case c == '.':
+		if !strings.HasPrefix(p.src[p.pos:], "...") {
+			panic(SyntaxError{"unexpected .", start})
+		}
+		p.pos += 3
+		p.tok = token{tokenPunct, "...", start}
+	case c == '_' || isLetter(c):
+		for p.pos < len(p.src) && (p.src[p.pos] == '_' || isLetter(p.src[p.pos]) || isDigit(p.src[p.pos])) {
+			p.pos++
+		}
+		p.tok = token{tokenName, p.src[start:p.pos], start}
+	case c == '-' || isDigit(c):
+		p.lexNumber()
+	case c == '"':
+		p.lexString()
+	default:
+		panic(SyntaxError{fmt.Sprintf("unexpected character %q", c), start})
+	}
+}
+
+func (p *parser) lexNumber() {
+	start := p.pos
+	kind := tokenInt
+	if p.src[p.pos] == '-' {
+		p.pos++
+	}
+	p.digits()
+	if p.pos < len(p.src) && p.src[p.pos] == '.' {
+		kind = tokenFloat
+		p.pos++
+		p.digits()
+	}
+	if p.pos < len(p.src) && (p.src[p.pos] == 'e' || p.src[p.pos] == 'E') {
+		kind = tokenFloat
+		p.pos++
+		if p.pos < len(p.src) && (p.src[p.pos] == '+' || p.src[p.pos] == '-') {
+			p.pos++
+		}
+		p.digits()
+	}
+	p.tok = token{kind, p.src[start:p.pos], start}
+}
+
+func (p *parser) digits() {
+	start := p.pos
+	for p.pos < len(p.src) && isDigit(p.src[p.pos]) {
+		p.pos++
+	}
+	if p.pos == start {
+		panic(SyntaxError{"invalid number", start})
+	}
+}
+
+func (p *parser) lexString() {
+	start := p.pos
+	if strings.HasPrefix(p.src[p.pos:], `"""`) {
+		panic(SyntaxError{"block strings are not supported", start})
+	}
+	p.pos++
+
+	var b bytes.Buffer
+	for {
+		if p.pos >= len(p.src) {
+			panic(SyntaxError{"unterminated string", start})
+		}
+
+		c := p.src[p.pos]
+		switch {
+		case c == '"':
+			p.pos++
+			p.tok = token{tokenString, b.String(), start}
+			return
+		case c == '\n' || c == '\r':
+			panic(SyntaxError{"unterminated string", start})
+		case c != '\\':
+			b.WriteByte(c)
+			p.pos++
+			continue
+		}
+
+		if p.pos+1 >= len(p.src) {
+			panic(SyntaxError{"unterminated string", start})
+		}
+		e := p.src[p.pos+1]
+		p.pos += 2
+		switch e {
+		case '"', '\\', '/':
+			b.WriteByte(e)
+		case 'b':
+			b.WriteByte('\b')
+		case 'f':
+			b.WriteByte('\f')
+		case 'n':
+			b.WriteByte('\n')
+		case 'r':
+			b.WriteByte('\r')
+		case 't':
+			b.WriteByte('\t')
+		case 'u':
+			if p.pos+4 > len(p.src) {
+				panic(SyntaxError{"invalid unicode escape", p.pos - 2})
+			}
+			r, err := strconv.ParseUint(p.src[p.pos:p.pos+4], 16, 32)
+			if err != nil {
+				panic(SyntaxError{"invalid unicode escape", p.pos - 2})
+			}
+			var buf [utf8.UTFMax]byte
+			b.Write(buf[:utf8.EncodeRune(buf[:], rune(r))])
+			p.pos += 4
+		default:
+			panic(SyntaxError{fmt.Sprintf("invalid escape \\%c", e), p.pos - 2})
+		}
+	}
+}
+
+func isLetter(c byte) bool {
+	return (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
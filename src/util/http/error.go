@@ -21,6 +21,16 @@ func Error400(w http.ResponseWriter, messages ...string) {
 	HTTPError(w, http.StatusBadRequest, "Bad request", messages)
 }
 
+// Error401 response 401 error
+func Error401(w http.ResponseWriter, messages ...string) {
+	HTTPError(w, http.StatusUnauthorized, "Unauthorized", messages)
+}
+
+// Error403 response 403 error
+func Error403(w http.ResponseWriter, messages ...string) {
+	HTTPError(w, http.StatusForbidden, "Forbidden", messages)
+}
+
 // Error404 response 404 error
 func Error404(w http.ResponseWriter, messages ...string) {
 	HTTPError(w, http.StatusNotFound, "Not found", messages)
@@ -31,6 +41,11 @@ func Error405(w http.ResponseWriter, messages ...string) {
 	HTTPError(w, http.StatusMethodNotAllowed, "Method not allowed", messages)
 }
 
+// Error429 response 429
+func Error429(w http.ResponseWriter, messages ...string) {
+	HTTPError(w, http.StatusTooManyRequests, "Too many requests", messages)
+}
+
 // Error501 response 501
 func Error501(w http.ResponseWriter, messages ...string) {
 	HTTPError(w, http.StatusNotImplemented, "Not implemented", messages)
@@ -41,3 +56,8 @@ func Error500(w http.ResponseWriter, messages ...string) {
 	HTTPError(w, http.StatusInternalServerError, "Internal server error",
 		messages)
 }
+
+// Error503 response 503
+func Error503(w http.ResponseWriter, messages ...string) {
+	HTTPError(w, http.StatusServiceUnavailable, "Service unavailable", messages)
+}
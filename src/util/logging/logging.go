@@ -1,16 +1,23 @@
 package logging
 
 import (
+	"encoding/json"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
+	"sort"
+	"strings"
+	"sync"
 
 	logging "github.com/op/go-logging"
 )
 
 const (
 	defaultLogFormat = "[%{module}:%{level}] %{message}"
+	// the time of the plain text logs written to the file
+	fileLogFormat = "%{time:2006-01-02T15:04:05.000Z07:00} [%{module}:%{level}] %{message}"
 )
 
 // Level embedes the logging's level
@@ -43,12 +50,22 @@ type LogConfig struct {
 	Level string
 	// list of all modules
 	Modules []string
+	// the levels of the modules overriding Level, e.g. {"daemon": "debug"}
+	ModuleLevels map[string]string
 	// format
 	Format string
 	// enable colors
 	Colors bool
+	// the logs are written as json lines, Format and Colors are ignored
+	JSON bool
 	// output
 	Output io.Writer
+	// the logs are written to the file too if it's set, the file is rotated
+	File string
+	// the max bytes of the log file before rotated, never rotated if 0
+	FileMaxSize int64
+	// the max number of the rotated log files kept, all are kept if 0
+	FileMaxBackups int
 }
 
 // LogLevel parse the log level string
@@ -94,18 +111,72 @@ func (l *LogConfig) initLevel() {
 }
 
 // InitLogger initialize logging using this LogConfig;
-// it panics if l.Format is invalid or l.Level is invalid
-func (l *LogConfig) InitLogger() {
+// it panics if l.Format is invalid or l.Level is invalid.
+// The returned function closes the log file.
+func (l *LogConfig) InitLogger() func() error {
 	l.initLevel()
 
-	format := logging.MustStringFormatter(l.Format)
-	logging.SetFormatter(format)
-	for _, s := range l.Modules {
-		logging.SetLevel(logging.Level(l.level), s)
+	var formatter logging.Formatter = logging.MustStringFormatter(l.Format)
+	if l.JSON {
+		formatter = JSONFormatter{}
 	}
+	logging.SetFormatter(formatter)
+
 	stdout := logging.NewLogBackend(l.Output, "", 0)
-	stdout.Color = l.Colors
-	logging.SetBackend(stdout)
+	stdout.Color = l.Colors && !l.JSON
+	backends := []logging.Backend{logging.NewBackendFormatter(stdout, formatter)}
+
+	closeFile := func() error { return nil }
+	if l.File != "" {
+		f, err := OpenRotatingFile(l.File, l.FileMaxSize, l.FileMaxBackups)
+		if err != nil {
+			log.Panicf("Open log file %s failed: %v", l.File, err)
+		}
+		closeFile = f.Close
+
+		fileFormatter := formatter
+		if !l.JSON {
+			fileFormatter = logging.MustStringFormatter(fileLogFormat)
+		}
+		backends = append(backends, logging.NewBackendFormatter(logging.NewLogBackend(f, "", 0), fileFormatter))
+	}
+
+	levels := newModuleLevels(logging.MultiLogger(backends...), logging.Level(l.level))
+	for _, s := range l.Modules {
+		levels.SetLevel(logging.Level(l.level), s)
+	}
+	for m, lvl := range l.ModuleLevels {
+		level, err := logging.LogLevel(lvl)
+		if err != nil {
+			log.Panicf("Invalid log level %s of module %s: %v", lvl, m, err)
+		}
+		levels.SetLevel(level, m)
+	}
+
+	setModuleLevels(levels)
+	logging.SetBackend(levels)
+	return closeFile
+}
+
+// ParseModuleLevels parses the comma separated levels of the modules, e.g. daemon:debug,gui:info
+func ParseModuleLevels(s string) (map[string]string, error) {
+	levels := make(map[string]string)
+	for _, p := range strings.Split(s, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+
+		pts := strings.Split(p, ":")
+		if len(pts) != 2 || pts[0] == "" {
+			return nil, fmt.Errorf("invalid module level %s, must be <module>:<level>", p)
+		}
+		if _, err := logging.LogLevel(pts[1]); err != nil {
+			return nil, err
+		}
+		levels[pts[0]] = pts[1]
+	}
+	return levels, nil
 }
 
 // MustGetLogger safe initialize global logger
@@ -117,3 +188,188 @@ func MustGetLogger(module string) *logging.Logger {
 func Disable() {
 	logging.SetBackend(logging.NewLogBackend(ioutil.Discard, "", 0))
 }
+
+// JSONFormatter formats the records as json lines of the time, the level, the module and the
+// message
+type JSONFormatter struct{}
+
+// jsonRecord is the json of a record
+type jsonRecord struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Module  string `json:"module"`
+	Message string `json:"msg"`
+}
+
+// Format implements logging.Formatter
+func (JSONFormatter) Format(calldepth int, r *logging.Record, w io.Writer) error {
+	b, err := json.Marshal(jsonRecord{
+		Time:    r.Time.UTC().Format("2006-01-02T15:04:05.000Z07:00"),
+		Level:   strings.ToLower(r.Level.String()),
+		Module:  r.Module,
+		Message: r.Message(),
+	})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(b)
+	return err
+}
+
+// moduleLevels is the levels of the modules, they can be changed while logging. The levels of
+// the modules not set are the default level.
+type moduleLevels struct {
+	backend logging.Backend
+	mu      sync.RWMutex
+	def     logging.Level
+	levels  map[string]logging.Level
+}
+
+func newModuleLevels(backend logging.Backend, def logging.Level) *moduleLevels {
+	return &moduleLevels{
+		backend: backend,
+		def:     def,
+		levels:  make(map[string]logging.Level),
+	}
+}
+
+// GetLevel implements logging.Leveled
+func (ml *moduleLevels) GetLevel(module string) logging.Level {
+	ml.mu.RLock()
+	defer ml.mu.RUnlock()
+	if level, ok := ml.levels[module]; ok {
+		return level
+	}
+	return ml.def
+}
+
+// SetLevel implements logging.Leveled, the default level is set if module is empty
+func (ml *moduleLevels) SetLevel(level logging.Level, module string) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	if module == "" {
+		ml.def = level
+		return
+	}
+	ml.levels[module] = level
+}
+
+// IsEnabledFor implements logging.Leveled
+func (ml *moduleLevels) IsEnabledFor(level logging.Level, module string) bool {
+	return level <= ml.GetLevel(module)
+}
+
+// Log implements logging.Backend
+func (ml *moduleLevels) Log(level logging.Level, calldepth int, r *logging.Record) error {
+	if !ml.IsEnabledFor(level, r.Module) {
+		return nil
+	}
+	return ml.backend.Log(level, calldepth+1, r)
+}
+
+// reset sets the default level and the levels of all the modules to def, and then the levels
+// of the modules in levels
+func (ml *moduleLevels) reset(def logging.Level, levels map[string]logging.Level) {
+	ml.mu.Lock()
+	defer ml.mu.Unlock()
+	ml.def = def
+	for m := range ml.levels {
+		ml.levels[m] = def
+	}
+	for m, l := range levels {
+		ml.levels[m] = l
+	}
+}
+
+// names returns the names of the levels of the modules, "" is the default level
+func (ml *moduleLevels) names() map[string]string {
+	ml.mu.RLock()
+	defer ml.mu.RUnlock()
+	names := map[string]string{"": strings.ToLower(ml.def.String())}
+	for m, l := range ml.levels {
+		names[m] = strings.ToLower(l.String())
+	}
+	return names
+}
+
+var (
+	currentLevelsMu sync.Mutex
+	currentLevels   *moduleLevels
+)
+
+func setModuleLevels(ml *moduleLevels) {
+	currentLevelsMu.Lock()
+	defer currentLevelsMu.Unlock()
+	currentLevels = ml
+}
+
+func getModuleLevels() *moduleLevels {
+	currentLevelsMu.Lock()
+	defer currentLevelsMu.Unlock()
+	return currentLevels
+}
+
+// ModuleLevel is the log level of a module
+type ModuleLevel struct {
+	Module string `json:"module"`
+	Level  string `json:"level"`
+}
+
+// GetModuleLevels returns the levels of the modules sorted by module, the module of the default
+// level is empty. It returns nil if the logger isn't initialized by InitLogger.
+func GetModuleLevels() []ModuleLevel {
+	ml := getModuleLevels()
+	if ml == nil {
+		return nil
+	}
+
+	var levels []ModuleLevel
+	for m, l := range ml.names() {
+		levels = append(levels, ModuleLevel{Module: m, Level: l})
+	}
+	sort.Slice(levels, func(i, j int) bool {
+		return levels[i].Module < levels[j].Module
+	})
+	return levels
+}
+
+// SetModuleLevel changes the log level of the module at runtime, the default level of the modules
+// not set is changed if module is empty
+func SetModuleLevel(module, level string) error {
+	lvl, err := logging.LogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	ml := getModuleLevels()
+	if ml == nil {
+		return fmt.Errorf("logger is not initialized")
+	}
+	ml.SetLevel(lvl, module)
+	return nil
+}
+
+// SetLevels changes the default level and the levels of all the modules to level, and then the
+// levels of the modules in moduleLevels, as InitLogger does with a LogConfig
+func SetLevels(level string, moduleLevels map[string]string) error {
+	def, err := logging.LogLevel(level)
+	if err != nil {
+		return err
+	}
+
+	levels := make(map[string]logging.Level, len(moduleLevels))
+	for m, l := range moduleLevels {
+		lvl, err := logging.LogLevel(l)
+		if err != nil {
+			return fmt.Errorf("invalid log level %s of module %s: %v", l, m, err)
+		}
+		levels[m] = lvl
+	}
+
+	ml := getModuleLevels()
+	if ml == nil {
+		return fmt.Errorf("logger is not initialized")
+	}
+	ml.reset(def, levels)
+	return nil
+}
@@ -0,0 +1,109 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONLogging(t *testing.T) {
+	var b bytes.Buffer
+	cfg := DevLogConfig([]string{"daemon", "gui"})
+	cfg.Level = "info"
+	cfg.ModuleLevels = map[string]string{"gui": "debug"}
+	cfg.JSON = true
+	cfg.Output = &b
+	closeFile := cfg.InitLogger()
+	defer closeFile()
+
+	log := MustGetLogger("daemon")
+	log.Debug("hidden")
+	log.Info("connected to %s", "127.0.0.1:6000")
+	MustGetLogger("gui").Debug("shown")
+
+	lines := bytes.Split(bytes.TrimSpace(b.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+
+	var r jsonRecord
+	require.NoError(t, json.Unmarshal(lines[0], &r))
+	require.Equal(t, "info", r.Level)
+	require.Equal(t, "daemon", r.Module)
+	require.Equal(t, "connected to 127.0.0.1:6000", r.Message)
+	require.NotEmpty(t, r.Time)
+
+	require.Equal(t, []ModuleLevel{
+		{Module: "", Level: "info"},
+		{Module: "daemon", Level: "info"},
+		{Module: "gui", Level: "debug"},
+	}, GetModuleLevels())
+
+	b.Reset()
+	require.NoError(t, SetModuleLevel("daemon", "debug"))
+	log.Debug("shown")
+	require.Contains(t, b.String(), `"msg":"shown"`)
+
+	require.Error(t, SetModuleLevel("daemon", "verbose"))
+}
+
+func TestSetLevels(t *testing.T) {
+	cfg := DevLogConfig([]string{"daemon", "gui"})
+	cfg.Level = "info"
+	cfg.ModuleLevels = map[string]string{"gui": "debug"}
+	cfg.Output = ioutil.Discard
+	closeFile := cfg.InitLogger()
+	defer closeFile()
+
+	require.NoError(t, SetLevels("warning", map[string]string{"daemon": "error"}))
+	require.Equal(t, []ModuleLevel{
+		{Module: "", Level: "warning"},
+		{Module: "daemon", Level: "error"},
+		{Module: "gui", Level: "warning"},
+	}, GetModuleLevels())
+
+	require.Error(t, SetLevels("verbose", nil))
+	require.Error(t, SetLevels("info", map[string]string{"gui": "verbose"}))
+}
+
+func TestParseModuleLevels(t *testing.T) {
+	levels, err := ParseModuleLevels("daemon:debug, gui:info,")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"daemon": "debug", "gui": "info"}, levels)
+
+	_, err = ParseModuleLevels("daemon")
+	require.Error(t, err)
+	_, err = ParseModuleLevels("daemon:verbose")
+	require.Error(t, err)
+}
+
+func TestRotatingFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "logging")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "suncoin.log")
+	f, err := OpenRotatingFile(path, 10, 2)
+	require.NoError(t, err)
+
+	for _, s := range []string{"aaaaaaaa\n", "bbbbbbbb\n", "cccccccc\n", "dddddddd\n"} {
+		_, err := f.Write([]byte(s))
+		require.NoError(t, err)
+	}
+	require.NoError(t, f.Close())
+
+	for name, want := range map[string]string{
+		path:        "dddddddd\n",
+		path + ".1": "cccccccc\n",
+		path + ".2": "bbbbbbbb\n",
+	} {
+		b, err := ioutil.ReadFile(name)
+		require.NoError(t, err)
+		require.Equal(t, want, string(b))
+	}
+	_, err = os.Stat(path + ".3")
+	require.True(t, os.IsNotExist(err))
+}
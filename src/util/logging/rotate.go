@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// RotatingFile is a log file rotated when it exceeds the max size. The rotated files are renamed
+// to <path>.1, <path>.2 ..., <path>.1 is the latest.
+type RotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// OpenRotatingFile opens the log file for appending, it's never rotated if maxSize is 0, and
+// all the rotated files are kept if maxBackups is 0
+func OpenRotatingFile(path string, maxSize int64, maxBackups int) (*RotatingFile, error) {
+	rf := &RotatingFile{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) open() error {
+	f, err := os.OpenFile(rf.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	rf.f = f
+	rf.size = fi.Size()
+	return nil
+}
+
+// Write appends the bytes to the file, the file is rotated first if it would exceed the max size
+func (rf *RotatingFile) Write(b []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.f == nil {
+		return 0, os.ErrClosed
+	}
+
+	if rf.maxSize > 0 && rf.size > 0 && rf.size+int64(len(b)) > rf.maxSize {
+		if err := rf.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := rf.f.Write(b)
+	rf.size += int64(n)
+	return n, err
+}
+
+// rotate renames the file to <path>.1 and opens a new file
+func (rf *RotatingFile) rotate() error {
+	if err := rf.f.Close(); err != nil {
+		return err
+	}
+	rf.f = nil
+
+	n := rf.maxBackups
+	if n == 0 {
+		// shifts all the rotated files
+		for n = 1; ; n++ {
+			if _, err := os.Stat(rf.backupPath(n)); os.IsNotExist(err) {
+				break
+			}
+		}
+	} else if err := os.Remove(rf.backupPath(n)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for i := n - 1; i >= 1; i-- {
+		if err := os.Rename(rf.backupPath(i), rf.backupPath(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := os.Rename(rf.path, rf.backupPath(1)); err != nil {
+		return err
+	}
+	return rf.open()
+}
+
+func (rf *RotatingFile) backupPath(i int) string {
+	return fmt.Sprintf("%s.%d", rf.path, i)
+}
+
+// Close closes the file
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	if rf.f == nil {
+		return nil
+	}
+	err := rf.f.Close()
+	rf.f = nil
+	return err
+}
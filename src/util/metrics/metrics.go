@@ -0,0 +1,418 @@
+// Package metrics implements the counters, the gauges and the histograms exported in the
+// Prometheus text format
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// contentType is the content type of the Prometheus text format
+const contentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// DefaultBuckets are the upper bounds of the histogram buckets in seconds, for the latencies
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// DefaultRegistry is the registry of the metrics created by the package functions
+var DefaultRegistry = NewRegistry()
+
+// metric is a registered metric family
+type metric interface {
+	name() string
+	write(w io.Writer)
+}
+
+// Registry holds the metrics, they're written sorted by name
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// NewRegistry creates an empty Registry
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// register adds the metric, it panics if the name is registered
+func (r *Registry) register(m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.metrics[m.name()]; ok {
+		panic(fmt.Sprintf("metric %s is registered twice", m.name()))
+	}
+	r.metrics[m.name()] = m
+}
+
+// Write writes the metrics in the Prometheus text format
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	ms := make([]metric, 0, len(r.metrics))
+	for _, m := range r.metrics {
+		ms = append(ms, m)
+	}
+	r.mu.Unlock()
+
+	sort.Slice(ms, func(i, j int) bool { return ms[i].name() < ms[j].name() })
+	for _, m := range ms {
+		m.write(w)
+	}
+}
+
+// Handler serves the metrics of the registry
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		r.Write(w)
+	})
+}
+
+// desc is the name and the help of a metric family
+type desc struct {
+	n    string
+	help string
+	typ  string
+}
+
+func (d desc) name() string {
+	return d.n
+}
+
+func (d desc) writeHeader(w io.Writer) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", d.n, escapeHelp(d.help), d.n, d.typ)
+}
+
+// Counter is a value that only goes up
+type Counter struct {
+	desc
+	bits uint64
+}
+
+// NewCounter creates and registers a Counter in DefaultRegistry
+func NewCounter(name, help string) *Counter {
+	return DefaultRegistry.NewCounter(name, help)
+}
+
+// NewCounter creates and registers a Counter
+func (r *Registry) NewCounter(name, help string) *Counter {
+	c := &Counter{desc: desc{n: name, help: help, typ: "counter"}}
+	r.register(c)
+	return c
+}
+
+// Inc adds 1 to the counter
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+// Add adds v to the counter, v must not be negative
+func (c *Counter) Add(v float64) {
+	if v < 0 {
+		panic("counter can't decrease")
+	}
+	addFloat(&c.bits, v)
+}
+
+// Value returns the value of the counter
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&c.bits))
+}
+
+func (c *Counter) write(w io.Writer) {
+	c.writeHeader(w)
+	writeSample(w, c.n, "", c.Value())
+}
+
+// Gauge is a value that goes up and down
+type Gauge struct {
+	desc
+	bits uint64
+}
+
+// NewGauge creates and registers a Gauge in DefaultRegistry
+func NewGauge(name, help string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help)
+}
+
+// NewGauge creates and registers a Gauge
+func (r *Registry) NewGauge(name, help string) *Gauge {
+	g := &Gauge{desc: desc{n: name, help: help, typ: "gauge"}}
+	r.register(g)
+	return g
+}
+
+// Set sets the value of the gauge
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Inc adds 1 to the gauge
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Dec subtracts 1 from the gauge
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+// Add adds v to the gauge, v can be negative
+func (g *Gauge) Add(v float64) {
+	addFloat(&g.bits, v)
+}
+
+// Value returns the value of the gauge
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+func (g *Gauge) write(w io.Writer) {
+	g.writeHeader(w)
+	writeSample(w, g.n, "", g.Value())
+}
+
+// CounterVec is the counters partitioned by the values of the labels
+type CounterVec struct {
+	desc
+	labels []string
+
+	mu       sync.Mutex
+	counters map[string]*Counter
+}
+
+// NewCounterVec creates and registers a CounterVec in DefaultRegistry
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return DefaultRegistry.NewCounterVec(name, help, labels...)
+}
+
+// NewCounterVec creates and registers a CounterVec
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	cv := &CounterVec{
+		desc:     desc{n: name, help: help, typ: "counter"},
+		labels:   labels,
+		counters: make(map[string]*Counter),
+	}
+	r.register(cv)
+	return cv
+}
+
+// With returns the counter of the label values, in the order of the labels
+func (cv *CounterVec) With(values ...string) *Counter {
+	key := labelPairs(cv.labels, values)
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	c, ok := cv.counters[key]
+	if !ok {
+		c = &Counter{}
+		cv.counters[key] = c
+	}
+	return c
+}
+
+func (cv *CounterVec) write(w io.Writer) {
+	cv.writeHeader(w)
+	cv.mu.Lock()
+	defer cv.mu.Unlock()
+	for _, key := range sortedKeys(cv.counters) {
+		writeSample(w, cv.n, key, cv.counters[key].Value())
+	}
+}
+
+// Histogram counts the observed values in the buckets
+type Histogram struct {
+	desc
+	buckets []float64
+
+	mu     sync.Mutex
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// NewHistogram creates and registers a Histogram in DefaultRegistry, the buckets are the
+// sorted upper bounds, DefaultBuckets if nil
+func NewHistogram(name, help string, buckets []float64) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets)
+}
+
+// NewHistogram creates and registers a Histogram
+func (r *Registry) NewHistogram(name, help string, buckets []float64) *Histogram {
+	h := newHistogram(buckets)
+	h.desc = desc{n: name, help: help, typ: "histogram"}
+	r.register(h)
+	return h
+}
+
+func newHistogram(buckets []float64) *Histogram {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &Histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+// Observe adds the value to the histogram
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.buckets, v)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if i < len(h.counts) {
+		h.counts[i]++
+	}
+	h.count++
+	h.sum += v
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.writeHeader(w)
+	h.writeSamples(w, h.n, "")
+}
+
+// writeSamples writes the cumulative buckets, the sum and the count, labels are the labels
+// of the histogram vec
+func (h *Histogram) writeSamples(w io.Writer, name, labels string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	prefix := labels
+	if prefix != "" {
+		prefix += ","
+	}
+
+	var cum uint64
+	for i, b := range h.buckets {
+		cum += h.counts[i]
+		writeSample(w, name+"_bucket", prefix+`le="`+formatFloat(b)+`"`, float64(cum))
+	}
+	writeSample(w, name+"_bucket", prefix+`le="+Inf"`, float64(h.count))
+	writeSample(w, name+"_sum", labels, h.sum)
+	writeSample(w, name+"_count", labels, float64(h.count))
+}
+
+// HistogramVec is the histograms partitioned by the values of the labels
+type HistogramVec struct {
+	desc
+	labels  []string
+	buckets []float64
+
+	mu         sync.Mutex
+	histograms map[string]*Histogram
+}
+
+// NewHistogramVec creates and registers a HistogramVec in DefaultRegistry
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	return DefaultRegistry.NewHistogramVec(name, help, buckets, labels...)
+}
+
+// NewHistogramVec creates and registers a HistogramVec
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	hv := &HistogramVec{
+		desc:       desc{n: name, help: help, typ: "histogram"},
+		labels:     labels,
+		buckets:    buckets,
+		histograms: make(map[string]*Histogram),
+	}
+	r.register(hv)
+	return hv
+}
+
+// With returns the histogram of the label values, in the order of the labels
+func (hv *HistogramVec) With(values ...string) *Histogram {
+	key := labelPairs(hv.labels, values)
+	hv.mu.Lock()
+	defer hv.mu.Unlock()
+	h, ok := hv.histograms[key]
+	if !ok {
+		h = newHistogram(hv.buckets)
+		hv.histograms[key] = h
+	}
+	return h
+}
+
+func (hv *HistogramVec) write(w io.Writer) {
+	hv.writeHeader(w)
+	hv.mu.Lock()
+	defer hv.mu.Unlock()
+	for _, key := range sortedKeys(hv.histograms) {
+		hv.histograms[key].writeSamples(w, hv.n, key)
+	}
+}
+
+// addFloat adds v to the float64 bits atomically
+func addFloat(bits *uint64, v float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		n := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(bits, old, n) {
+			return
+		}
+	}
+}
+
+// labelPairs formats the label pairs, it panics if the number of the values is wrong
+func labelPairs(labels, values []string) string {
+	if len(labels) != len(values) {
+		panic(fmt.Sprintf("expected %d label values, got %d", len(labels), len(values)))
+	}
+	pairs := make([]string, len(labels))
+	for i, l := range labels {
+		pairs[i] = l + `="` + escapeLabel(values[i]) + `"`
+	}
+	return strings.Join(pairs, ",")
+}
+
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch v := m.(type) {
+	case map[string]*Counter:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	case map[string]*Histogram:
+		for k := range v {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeSample(w io.Writer, name, labels string, v float64) {
+	if labels != "" {
+		fmt.Fprintf(w, "%s{%s} %s\n", name, labels, formatFloat(v))
+	} else {
+		fmt.Fprintf(w, "%s %s\n", name, formatFloat(v))
+	}
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+var helpReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+
+func escapeHelp(s string) string {
+	return helpReplacer.Replace(s)
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabel(s string) string {
+	return labelReplacer.Replace(s)
+}
@@ -0,0 +1,80 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	c := r.NewCounter("test_txns_total", "Txns injected")
+	g := r.NewGauge("test_height", "Head block seq")
+	cv := r.NewCounterVec("test_requests_total", "Requests", "path", "code")
+	h := r.NewHistogram("test_latency_seconds", "Latency\nin seconds", []float64{0.1, 1})
+
+	c.Inc()
+	c.Add(2)
+	g.Set(10)
+	g.Add(-3)
+	cv.With("/block", "200").Inc()
+	cv.With(`/a"b`, "404").Add(2)
+	h.Observe(0.05)
+	h.Observe(0.5)
+	h.Observe(3)
+
+	require.Equal(t, float64(3), c.Value())
+	require.Equal(t, float64(7), g.Value())
+
+	var b bytes.Buffer
+	r.Write(&b)
+	require.Equal(t, `# HELP test_height Head block seq
+# TYPE test_height gauge
+test_height 7
+# HELP test_latency_seconds Latency\nin seconds
+# TYPE test_latency_seconds histogram
+test_latency_seconds_bucket{le="0.1"} 1
+test_latency_seconds_bucket{le="1"} 2
+test_latency_seconds_bucket{le="+Inf"} 3
+test_latency_seconds_sum 3.55
+test_latency_seconds_count 3
+# HELP test_requests_total Requests
+# TYPE test_requests_total counter
+test_requests_total{path="/a\"b",code="404"} 2
+test_requests_total{path="/block",code="200"} 1
+# HELP test_txns_total Txns injected
+# TYPE test_txns_total counter
+test_txns_total 3
+`, b.String())
+}
+
+func TestHistogramVec(t *testing.T) {
+	r := NewRegistry()
+	hv := r.NewHistogramVec("test_seconds", "Seconds", []float64{1}, "path")
+	hv.With("/a").Observe(0.5)
+	hv.With("/a").Observe(2)
+
+	var b bytes.Buffer
+	r.Write(&b)
+	require.Equal(t, `# HELP test_seconds Seconds
+# TYPE test_seconds histogram
+test_seconds_bucket{path="/a",le="1"} 1
+test_seconds_bucket{path="/a",le="+Inf"} 2
+test_seconds_sum{path="/a"} 2.5
+test_seconds_count{path="/a"} 2
+`, b.String())
+
+	require.Panics(t, func() { hv.With("/a", "b") })
+}
+
+func TestRegisterTwice(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("test_gauge", "Gauge")
+	require.Panics(t, func() { r.NewCounter("test_gauge", "Counter") })
+}
+
+func TestCounterDecrease(t *testing.T) {
+	c := NewRegistry().NewCounter("test_total", "Total")
+	require.Panics(t, func() { c.Add(-1) })
+}
@@ -0,0 +1,297 @@
+// Package nat maps the listening port on the home router by UPnP or NAT-PMP, so that the peers
+// behind the router accept incoming connections without configuring it. The mapping is renewed
+// before its lease expires and deleted when the node shuts down.
+package nat
+
+import (
+	"bufio"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+var logger = logging.MustGetLogger("nat")
+
+// Mapper maps the ports of a gateway
+type Mapper interface {
+	// ExternalIP returns the external address of the gateway
+	ExternalIP() (net.IP, error)
+	// AddMapping maps the external port to the internal port, and returns the external port
+	// and the lifetime granted by the gateway
+	AddMapping(protocol string, externalPort, internalPort int, desc string,
+		lifetime time.Duration) (int, time.Duration, error)
+	// DeleteMapping deletes the mapping
+	DeleteMapping(protocol string, externalPort, internalPort int) error
+	// String returns the name of the protocol
+	String() string
+}
+
+// Discover finds the gateway and returns its mapper, UPnP is tried first and then NAT-PMP
+func Discover(timeout time.Duration) (Mapper, error) {
+	u, err := discoverUPnP(timeout)
+	if err == nil {
+		return u, nil
+	}
+	logger.Debug("UPnP discovery failed: %v", err)
+
+	gateway, err := defaultGateway()
+	if err != nil {
+		return nil, err
+	}
+
+	n := newNATPMP(gateway)
+	if _, err := n.ExternalIP(); err != nil {
+		logger.Debug("NAT-PMP discovery failed: %v", err)
+		return nil, errors.New("no UPnP or NAT-PMP gateway found")
+	}
+	return n, nil
+}
+
+// defaultGateway returns the gateway of the default route. It's read from /proc/net/route on
+// linux, otherwise the gateway is guessed as the .1 address of the LAN.
+func defaultGateway() (net.IP, error) {
+	if ip, err := linuxDefaultGateway(); err == nil {
+		return ip, nil
+	}
+
+	// the local address of a route to the internet, no packet is sent
+	conn, err := net.Dial("udp4", "8.8.8.8:53")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ip := conn.LocalAddr().(*net.UDPAddr).IP.To4()
+	if ip == nil {
+		return nil, errors.New("no IPv4 default route")
+	}
+	return net.IPv4(ip[0], ip[1], ip[2], 1), nil
+}
+
+// linuxDefaultGateway reads the gateway of the default route from /proc/net/route
+func linuxDefaultGateway() (net.IP, error) {
+	f, err := os.Open("/proc/net/route")
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return parseRouteTable(bufio.NewScanner(f))
+}
+
+// parseRouteTable returns the gateway of the default route of the /proc/net/route table,
+// the addresses are hex in little endian
+func parseRouteTable(s *bufio.Scanner) (net.IP, error) {
+	for s.Scan() {
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		return net.IPv4(b[3], b[2], b[1], b[0]), nil
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("no default route")
+}
+
+// Config port mapping configuration
+type Config struct {
+	// Map the listening port on the gateway
+	Enabled bool
+	// Lease of the mapping, it's renewed at half of the lease
+	Lifetime time.Duration
+	// How long to wait for the gateway to respond
+	DiscoverTimeout time.Duration
+	// How often to retry if no gateway is found or the mapping fails
+	RetryRate time.Duration
+	// Description of the mapping shown by the gateway
+	Description string
+}
+
+// NewConfig returns a Config with defaults set
+func NewConfig() Config {
+	return Config{
+		Enabled:         false,
+		Lifetime:        time.Minute * 20,
+		DiscoverTimeout: time.Second * 3,
+		RetryRate:       time.Minute * 5,
+		Description:     "suncoin",
+	}
+}
+
+// Status is the state of the port mapping
+type Status struct {
+	// upnp or nat-pmp, empty if no gateway is found
+	Method       string `json:"method"`
+	ExternalIP   string `json:"external_ip"`
+	ExternalPort int    `json:"external_port"`
+	InternalPort int    `json:"internal_port"`
+	// unix time the mapping expires, 0 if not mapped
+	Expires int64 `json:"expires"`
+	// the error of the last attempt
+	Error string `json:"error"`
+}
+
+// Mapped returns whether the port is mapped
+func (s Status) Mapped() bool {
+	return s.ExternalPort != 0
+}
+
+// PortMapper maps the TCP port on the gateway and renews the mapping
+type PortMapper struct {
+	Config Config
+	port   int
+	// finds the gateway, it's Discover except in the tests
+	discover func(timeout time.Duration) (Mapper, error)
+
+	mapper Mapper
+	status Status
+	sync.Mutex
+
+	// 1 once Run is called
+	started int32
+	quit    chan struct{}
+	done    chan struct{}
+}
+
+// NewPortMapper creates a PortMapper of the TCP port
+func NewPortMapper(c Config, port int) *PortMapper {
+	return &PortMapper{
+		Config:   c,
+		port:     port,
+		discover: Discover,
+		status:   Status{InternalPort: port},
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Run maps the port and renews it till Shutdown
+func (pm *PortMapper) Run() {
+	if !atomic.CompareAndSwapInt32(&pm.started, 0, 1) {
+		return
+	}
+	defer close(pm.done)
+
+	for {
+		wait := pm.Config.RetryRate
+		if lifetime, err := pm.renew(); err != nil {
+			logger.Warning("Port mapping failed: %v", err)
+			pm.setError(err)
+		} else if lifetime > 0 {
+			wait = lifetime / 2
+		}
+
+		select {
+		case <-pm.quit:
+			pm.unmap()
+			return
+		case <-time.After(wait):
+		}
+	}
+}
+
+// Shutdown deletes the mapping and stops renewing it
+func (pm *PortMapper) Shutdown() {
+	close(pm.quit)
+	if atomic.LoadInt32(&pm.started) == 1 {
+		<-pm.done
+	}
+}
+
+// Status returns the state of the mapping
+func (pm *PortMapper) Status() Status {
+	pm.Lock()
+	defer pm.Unlock()
+	return pm.status
+}
+
+func (pm *PortMapper) setError(err error) {
+	pm.Lock()
+	defer pm.Unlock()
+	pm.status.Error = err.Error()
+	pm.status.Expires = 0
+}
+
+// renew discovers the gateway if not found yet, then maps the port. The lifetime granted is
+// returned, the gateway is discovered again if the mapping fails.
+func (pm *PortMapper) renew() (time.Duration, error) {
+	if pm.mapper == nil {
+		m, err := pm.discover(pm.Config.DiscoverTimeout)
+		if err != nil {
+			return 0, err
+		}
+		pm.mapper = m
+
+		pm.Lock()
+		pm.status.Method = m.String()
+		pm.Unlock()
+	}
+
+	// ask for the same external port as the internal port, or the one mapped before
+	external := pm.Status().ExternalPort
+	if external == 0 {
+		external = pm.port
+	}
+
+	port, lifetime, err := pm.mapper.AddMapping("TCP", external, pm.port, pm.Config.Description,
+		pm.Config.Lifetime)
+	if err != nil {
+		pm.mapper = nil
+		return 0, err
+	}
+
+	ip, err := pm.mapper.ExternalIP()
+	if err != nil {
+		pm.mapper = nil
+		return 0, err
+	}
+
+	pm.Lock()
+	if pm.status.ExternalPort != port || pm.status.ExternalIP != ip.String() {
+		logger.Info("Port %d is mapped to %s:%d by %s", pm.port, ip, port, pm.status.Method)
+	}
+	pm.status.ExternalIP = ip.String()
+	pm.status.ExternalPort = port
+	pm.status.Error = ""
+	pm.status.Expires = time.Now().Add(lifetime).Unix()
+	if lifetime == 0 {
+		// permanent lease
+		pm.status.Expires = 0
+	}
+	pm.Unlock()
+
+	return lifetime, nil
+}
+
+// unmap deletes the mapping
+func (pm *PortMapper) unmap() {
+	s := pm.Status()
+	if pm.mapper == nil || !s.Mapped() {
+		return
+	}
+
+	if err := pm.mapper.DeleteMapping("TCP", s.ExternalPort, pm.port); err != nil {
+		logger.Warning("Delete port mapping failed: %v", err)
+		return
+	}
+	logger.Info("Port mapping of %s:%d is deleted", s.ExternalIP, s.ExternalPort)
+
+	pm.Lock()
+	pm.status.ExternalPort = 0
+	pm.status.Expires = 0
+	pm.Unlock()
+}
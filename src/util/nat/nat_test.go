@@ -0,0 +1,175 @@
+package nat
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRouteTable(t *testing.T) {
+	table := `Iface	Destination	Gateway 	Flags	RefCnt	Use	Metric	Mask		MTU	Window	IRTT
+eth0	0000A8C0	00000000	0001	0	0	100	00FFFFFF	0	0	0
+eth0	00000000	0101A8C0	0003	0	0	100	00000000	0	0	0
+`
+	ip, err := parseRouteTable(bufio.NewScanner(strings.NewReader(table)))
+	require.NoError(t, err)
+	require.Equal(t, "192.168.1.1", ip.String())
+
+	_, err = parseRouteTable(bufio.NewScanner(strings.NewReader(strings.SplitN(table, "\n", 3)[1])))
+	require.Error(t, err)
+}
+
+// fakeNATPMP responds to the NAT-PMP requests, the first request is dropped to test the resend
+func fakeNATPMP(t *testing.T) (*natPMP, func()) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	go func() {
+		buf := make([]byte, 16)
+		dropped := false
+		for {
+			n, addr, err := conn.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if !dropped {
+				dropped = true
+				continue
+			}
+
+			resp := make([]byte, 16)
+			resp[1] = buf[1] | 0x80
+			binary.BigEndian.PutUint32(resp[4:], 1000)
+			switch {
+			case buf[1] == opExternalAddress && n == 2:
+				copy(resp[8:], net.IPv4(1, 2, 3, 4).To4())
+				resp = resp[:12]
+			case buf[1] == opMapTCP && n == 12:
+				copy(resp[8:10], buf[4:6])
+				// the gateway assigns another external port
+				binary.BigEndian.PutUint16(resp[10:], binary.BigEndian.Uint16(buf[6:])+1)
+				copy(resp[12:], buf[8:12])
+			default:
+				binary.BigEndian.PutUint16(resp[2:], 5)
+			}
+			conn.WriteTo(resp, addr)
+		}
+	}()
+
+	return &natPMP{addr: conn.LocalAddr().String()}, func() { conn.Close() }
+}
+
+func TestNATPMP(t *testing.T) {
+	n, stop := fakeNATPMP(t)
+	defer stop()
+
+	ip, err := n.ExternalIP()
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", ip.String())
+
+	port, lifetime, err := n.AddMapping("tcp", 7200, 7200, "suncoin", time.Hour)
+	require.NoError(t, err)
+	require.Equal(t, 7201, port)
+	require.Equal(t, time.Hour, lifetime)
+
+	require.NoError(t, n.DeleteMapping("TCP", 7201, 7200))
+
+	_, _, err = n.AddMapping("sctp", 7200, 7200, "suncoin", time.Hour)
+	require.Error(t, err)
+
+	_, _, err = n.AddMapping("udp", 7200, 7200, "suncoin", time.Hour)
+	require.EqualError(t, err, "nat-pmp: unsupported opcode")
+}
+
+// fakeMapper records the mappings
+type fakeMapper struct {
+	sync.Mutex
+	mapped  map[int]int
+	adds    int
+	failAdd bool
+}
+
+func (m *fakeMapper) ExternalIP() (net.IP, error) {
+	return net.IPv4(1, 2, 3, 4), nil
+}
+
+func (m *fakeMapper) AddMapping(protocol string, externalPort, internalPort int, desc string,
+	lifetime time.Duration) (int, time.Duration, error) {
+	m.Lock()
+	defer m.Unlock()
+	m.adds++
+	if m.failAdd {
+		return 0, 0, errors.New("refused")
+	}
+	m.mapped[externalPort] = internalPort
+	return externalPort, lifetime, nil
+}
+
+func (m *fakeMapper) DeleteMapping(protocol string, externalPort, internalPort int) error {
+	m.Lock()
+	defer m.Unlock()
+	delete(m.mapped, externalPort)
+	return nil
+}
+
+func (m *fakeMapper) String() string {
+	return "fake"
+}
+
+func TestPortMapper(t *testing.T) {
+	m := &fakeMapper{mapped: make(map[int]int)}
+	c := NewConfig()
+	c.Lifetime = time.Millisecond * 40
+	c.RetryRate = time.Millisecond * 10
+
+	pm := NewPortMapper(c, 7200)
+	discovered := 0
+	pm.discover = func(timeout time.Duration) (Mapper, error) {
+		discovered++
+		if discovered == 1 {
+			return nil, errors.New("no gateway")
+		}
+		return m, nil
+	}
+
+	go pm.Run()
+	time.Sleep(time.Millisecond * 100)
+
+	s := pm.Status()
+	require.True(t, s.Mapped())
+	require.Equal(t, "fake", s.Method)
+	require.Equal(t, "1.2.3.4", s.ExternalIP)
+	require.Equal(t, 7200, s.ExternalPort)
+	require.Equal(t, 7200, s.InternalPort)
+	require.Empty(t, s.Error)
+
+	// the mapping is renewed
+	m.Lock()
+	require.True(t, m.adds > 1)
+	require.Equal(t, map[int]int{7200: 7200}, m.mapped)
+	m.failAdd = true
+	m.Unlock()
+	time.Sleep(time.Millisecond * 50)
+
+	s = pm.Status()
+	require.Equal(t, "refused", s.Error)
+	require.Equal(t, int64(0), s.Expires)
+
+	m.Lock()
+	m.failAdd = false
+	m.Unlock()
+	time.Sleep(time.Millisecond * 50)
+	require.Empty(t, pm.Status().Error)
+
+	// the mapping is deleted on shutdown
+	pm.Shutdown()
+	require.False(t, pm.Status().Mapped())
+	require.Empty(t, m.mapped)
+}
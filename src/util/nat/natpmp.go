@@ -0,0 +1,149 @@
+package nat
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// natPMPPort is the port of the NAT-PMP server on the gateway, RFC 6886
+	natPMPPort = 5351
+	// natPMPTries is how many times a request is sent, the timeout doubles each time from 250ms
+	natPMPTries = 4
+
+	opExternalAddress = 0
+	opMapUDP          = 1
+	opMapTCP          = 2
+)
+
+var natPMPResults = map[uint16]string{
+	1: "unsupported version",
+	2: "not authorized or refused",
+	3: "network failure",
+	4: "out of resources",
+	5: "unsupported opcode",
+}
+
+// natPMP maps the ports by the NAT-PMP server of the gateway
+type natPMP struct {
+	// address of the NAT-PMP server, gateway:5351
+	addr string
+}
+
+// newNATPMP returns the NAT-PMP client of the gateway
+func newNATPMP(gateway net.IP) *natPMP {
+	return &natPMP{
+		addr: net.JoinHostPort(gateway.String(), fmt.Sprint(natPMPPort)),
+	}
+}
+
+func (n *natPMP) String() string {
+	return "nat-pmp"
+}
+
+// ExternalIP returns the external address of the gateway
+func (n *natPMP) ExternalIP() (net.IP, error) {
+	resp, err := n.request([]byte{0, opExternalAddress}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IP(resp[8:12]), nil
+}
+
+// AddMapping maps the external port to the internal port, the gateway may assign another
+// external port. The assigned port and lifetime are returned.
+func (n *natPMP) AddMapping(protocol string, externalPort, internalPort int, desc string,
+	lifetime time.Duration) (int, time.Duration, error) {
+	op, err := natPMPOp(protocol)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	req := make([]byte, 12)
+	req[1] = op
+	binary.BigEndian.PutUint16(req[4:], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:], uint32(lifetime/time.Second))
+
+	resp, err := n.request(req, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	port := int(binary.BigEndian.Uint16(resp[10:]))
+	granted := time.Duration(binary.BigEndian.Uint32(resp[12:])) * time.Second
+	return port, granted, nil
+}
+
+// DeleteMapping deletes the mapping of the internal port
+func (n *natPMP) DeleteMapping(protocol string, externalPort, internalPort int) error {
+	op, err := natPMPOp(protocol)
+	if err != nil {
+		return err
+	}
+
+	req := make([]byte, 12)
+	req[1] = op
+	binary.BigEndian.PutUint16(req[4:], uint16(internalPort))
+	_, err = n.request(req, 16)
+	return err
+}
+
+func natPMPOp(protocol string) (byte, error) {
+	switch strings.ToUpper(protocol) {
+	case "TCP":
+		return opMapTCP, nil
+	case "UDP":
+		return opMapUDP, nil
+	default:
+		return 0, fmt.Errorf("invalid protocol %s", protocol)
+	}
+}
+
+// request sends the request and reads the response of size bytes, the request is resent
+// if no response is received
+func (n *natPMP) request(req []byte, size int) ([]byte, error) {
+	conn, err := net.Dial("udp", n.addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	timeout := 250 * time.Millisecond
+	resp := make([]byte, 16)
+	for i := 0; i < natPMPTries; i++ {
+		if _, err := conn.Write(req); err != nil {
+			return nil, err
+		}
+
+		conn.SetReadDeadline(time.Now().Add(timeout))
+		timeout *= 2
+
+		m, err := conn.Read(resp)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				continue
+			}
+			return nil, err
+		}
+
+		// the responses of the earlier tries are skipped
+		if m < size || resp[0] != 0 || resp[1] != req[1]|0x80 {
+			continue
+		}
+
+		if code := binary.BigEndian.Uint16(resp[2:]); code != 0 {
+			if msg, ok := natPMPResults[code]; ok {
+				return nil, fmt.Errorf("nat-pmp: %s", msg)
+			}
+			return nil, fmt.Errorf("nat-pmp: result code %d", code)
+		}
+		return resp[:size], nil
+	}
+
+	return nil, errors.New("nat-pmp: no response from the gateway")
+}
@@ -0,0 +1,336 @@
+package nat
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	ssdpAddr = "239.255.255.250:1900"
+	igdType  = "urn:schemas-upnp-org:device:InternetGatewayDevice:1"
+
+	// errOnlyPermanentLeases is the UPnP error of the gateways that don't support lease durations
+	errOnlyPermanentLeases = "725"
+)
+
+// the services of the IGD that map the ports
+var wanServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// upnp maps the ports by the WAN connection service of the UPnP internet gateway device
+type upnp struct {
+	// URL the SOAP requests are posted to
+	controlURL  string
+	serviceType string
+	// address of this host on the LAN of the gateway
+	internalIP net.IP
+	client     *http.Client
+}
+
+func (u *upnp) String() string {
+	return "upnp"
+}
+
+// discoverUPnP searches the gateway device by SSDP, and gets its WAN connection service
+func discoverUPnP(timeout time.Duration) (*upnp, error) {
+	location, err := searchSSDP(timeout)
+	if err != nil {
+		return nil, err
+	}
+	return newUPnP(location, timeout)
+}
+
+// searchSSDP multicasts the search of the gateway device and returns the location of its
+// description
+func searchSSDP(timeout time.Duration) (string, error) {
+	addr, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return "", err
+	}
+
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	req := "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: " + ssdpAddr + "\r\n" +
+		"ST: " + igdType + "\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n\r\n"
+	if _, err := conn.WriteTo([]byte(req), addr); err != nil {
+		return "", err
+	}
+
+	conn.SetReadDeadline(time.Now().Add(timeout))
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			if e, ok := err.(net.Error); ok && e.Timeout() {
+				return "", errors.New("upnp: no gateway device found")
+			}
+			return "", err
+		}
+
+		if location, ok := parseSSDPResponse(buf[:n]); ok {
+			return location, nil
+		}
+	}
+}
+
+// parseSSDPResponse returns the location of the response of a gateway device
+func parseSSDPResponse(b []byte) (string, bool) {
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(b)), nil)
+	if err != nil {
+		return "", false
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK || !strings.Contains(resp.Header.Get("St"), "InternetGatewayDevice") {
+		return "", false
+	}
+
+	location := resp.Header.Get("Location")
+	return location, location != ""
+}
+
+// device is a device of the UPnP description
+type device struct {
+	DeviceType string    `xml:"deviceType"`
+	Services   []service `xml:"serviceList>service"`
+	Devices    []device  `xml:"deviceList>device"`
+}
+
+type service struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+// findService returns the first service of the types in the device tree
+func (d device) findService(types []string) (service, bool) {
+	for _, t := range types {
+		if s, ok := d.findServiceType(t); ok {
+			return s, true
+		}
+	}
+	return service{}, false
+}
+
+func (d device) findServiceType(t string) (service, bool) {
+	for _, s := range d.Services {
+		if s.ServiceType == t {
+			return s, true
+		}
+	}
+	for _, sub := range d.Devices {
+		if s, ok := sub.findServiceType(t); ok {
+			return s, true
+		}
+	}
+	return service{}, false
+}
+
+// newUPnP gets the description of the gateway device at the location
+func newUPnP(location string, timeout time.Duration) (*upnp, error) {
+	client := &http.Client{Timeout: timeout}
+
+	resp, err := client.Get(location)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("upnp: get device description: %s", resp.Status)
+	}
+
+	var desc struct {
+		URLBase string `xml:"URLBase"`
+		Device  device `xml:"device"`
+	}
+	if err := xml.NewDecoder(resp.Body).Decode(&desc); err != nil {
+		return nil, fmt.Errorf("upnp: invalid device description: %v", err)
+	}
+
+	s, ok := desc.Device.findService(wanServiceTypes)
+	if !ok {
+		return nil, errors.New("upnp: the gateway has no WAN connection service")
+	}
+
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if desc.URLBase != "" {
+		if base, err = url.Parse(desc.URLBase); err != nil {
+			return nil, err
+		}
+	}
+	control, err := base.Parse(s.ControlURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// the address this host reaches the gateway from is the internal client of the mappings
+	conn, err := net.DialTimeout("tcp", base.Host, timeout)
+	if err != nil {
+		return nil, err
+	}
+	internalIP := conn.LocalAddr().(*net.TCPAddr).IP
+	conn.Close()
+
+	return &upnp{
+		controlURL:  control.String(),
+		serviceType: s.ServiceType,
+		internalIP:  internalIP,
+		client:      client,
+	}, nil
+}
+
+// soapArg is an argument of a SOAP action, the order of the arguments matters
+type soapArg struct {
+	name  string
+	value string
+}
+
+// soapError is the UPnP error of a failed action
+type soapError struct {
+	Code        string
+	Description string
+}
+
+func (e soapError) Error() string {
+	return fmt.Sprintf("upnp: error %s %s", e.Code, e.Description)
+}
+
+// call posts the SOAP action and returns the response body
+func (u *upnp) call(action string, args ...soapArg) ([]byte, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" ` +
+		`s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/"><s:Body>`)
+	fmt.Fprintf(&body, `<u:%s xmlns:u="%s">`, action, u.serviceType)
+	for _, a := range args {
+		fmt.Fprintf(&body, "<%s>", a.name)
+		xml.EscapeText(&body, []byte(a.value))
+		fmt.Fprintf(&body, "</%s>", a.name)
+	}
+	fmt.Fprintf(&body, `</u:%s></s:Body></s:Envelope>`, action)
+
+	req, err := http.NewRequest("POST", u.controlURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", fmt.Sprintf(`"%s#%s"`, u.serviceType, action))
+
+	resp, err := u.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	b, err := readAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		code := findElement(b, "errorCode")
+		if code == "" {
+			return nil, fmt.Errorf("upnp: %s %s", action, resp.Status)
+		}
+		return nil, soapError{Code: code, Description: findElement(b, "errorDescription")}
+	}
+	return b, nil
+}
+
+// readAll reads the response body, at most 64KB
+func readAll(r io.Reader) ([]byte, error) {
+	var b bytes.Buffer
+	_, err := b.ReadFrom(io.LimitReader(r, 64*1024))
+	return b.Bytes(), err
+}
+
+// findElement returns the text of the first element of the local name in the XML
+func findElement(b []byte, name string) string {
+	d := xml.NewDecoder(bytes.NewReader(b))
+	for {
+		t, err := d.Token()
+		if err != nil {
+			return ""
+		}
+		if se, ok := t.(xml.StartElement); ok && se.Name.Local == name {
+			var v string
+			if err := d.DecodeElement(&v, &se); err != nil {
+				return ""
+			}
+			return strings.TrimSpace(v)
+		}
+	}
+}
+
+// ExternalIP returns the external address of the gateway
+func (u *upnp) ExternalIP() (net.IP, error) {
+	b, err := u.call("GetExternalIPAddress")
+	if err != nil {
+		return nil, err
+	}
+
+	ip := net.ParseIP(findElement(b, "NewExternalIPAddress"))
+	if ip == nil {
+		return nil, errors.New("upnp: invalid external address")
+	}
+	return ip, nil
+}
+
+// AddMapping maps the external port to the internal port of this host. The lease is
+// permanent if the gateway doesn't support lease durations, the lifetime returned is 0 then.
+func (u *upnp) AddMapping(protocol string, externalPort, internalPort int, desc string,
+	lifetime time.Duration) (int, time.Duration, error) {
+	add := func(lifetime time.Duration) error {
+		_, err := u.call("AddPortMapping",
+			soapArg{"NewRemoteHost", ""},
+			soapArg{"NewExternalPort", fmt.Sprint(externalPort)},
+			soapArg{"NewProtocol", strings.ToUpper(protocol)},
+			soapArg{"NewInternalPort", fmt.Sprint(internalPort)},
+			soapArg{"NewInternalClient", u.internalIP.String()},
+			soapArg{"NewEnabled", "1"},
+			soapArg{"NewPortMappingDescription", desc},
+			soapArg{"NewLeaseDuration", fmt.Sprint(int(lifetime / time.Second))})
+		return err
+	}
+
+	err := add(lifetime)
+	if e, ok := err.(soapError); ok && e.Code == errOnlyPermanentLeases {
+		lifetime = 0
+		err = add(lifetime)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return externalPort, lifetime, nil
+}
+
+// DeleteMapping deletes the mapping of the external port
+func (u *upnp) DeleteMapping(protocol string, externalPort, internalPort int) error {
+	_, err := u.call("DeletePortMapping",
+		soapArg{"NewRemoteHost", ""},
+		soapArg{"NewExternalPort", fmt.Sprint(externalPort)},
+		soapArg{"NewProtocol", strings.ToUpper(protocol)})
+	return err
+}
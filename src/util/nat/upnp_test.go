@@ -0,0 +1,113 @@
+package nat
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testDescription = `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+  <device>
+    <deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+    <deviceList>
+      <device>
+        <deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+        <deviceList>
+          <device>
+            <deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+            <serviceList>
+              <service>
+                <serviceType>urn:schemas-upnp-org:service:WANIPConnection:1</serviceType>
+                <controlURL>/ctl/IPConn</controlURL>
+              </service>
+            </serviceList>
+          </device>
+        </deviceList>
+      </device>
+    </deviceList>
+  </device>
+</root>`
+
+const soapFault = `<?xml version="1.0"?>
+<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault>
+<detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0">
+<errorCode>%s</errorCode><errorDescription>%s</errorDescription>
+</UPnPError></detail></s:Fault></s:Body></s:Envelope>`
+
+func TestParseSSDPResponse(t *testing.T) {
+	location, ok := parseSSDPResponse([]byte("HTTP/1.1 200 OK\r\n" +
+		"CACHE-CONTROL: max-age=120\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+		"LOCATION: http://192.168.1.1:5000/rootDesc.xml\r\n\r\n"))
+	require.True(t, ok)
+	require.Equal(t, "http://192.168.1.1:5000/rootDesc.xml", location)
+
+	_, ok = parseSSDPResponse([]byte("HTTP/1.1 200 OK\r\n" +
+		"ST: urn:schemas-upnp-org:device:MediaServer:1\r\n" +
+		"LOCATION: http://192.168.1.2/desc.xml\r\n\r\n"))
+	require.False(t, ok)
+
+	_, ok = parseSSDPResponse([]byte("NOTIFY * HTTP/1.1\r\n\r\n"))
+	require.False(t, ok)
+}
+
+func TestUPnP(t *testing.T) {
+	var actions []string
+	var leases []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/rootDesc.xml", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testDescription)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		b, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		action := r.Header.Get("SOAPAction")
+		actions = append(actions, action)
+
+		switch action {
+		case `"urn:schemas-upnp-org:service:WANIPConnection:1#GetExternalIPAddress"`:
+			fmt.Fprint(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+				`<u:GetExternalIPAddressResponse xmlns:u="urn:schemas-upnp-org:service:WANIPConnection:1">`+
+				`<NewExternalIPAddress>1.2.3.4</NewExternalIPAddress></u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+		case `"urn:schemas-upnp-org:service:WANIPConnection:1#AddPortMapping"`:
+			require.True(t, strings.Contains(string(b), "<NewInternalClient>127.0.0.1</NewInternalClient>"))
+			lease := findElement(b, "NewLeaseDuration")
+			leases = append(leases, lease)
+			if lease != "0" {
+				w.WriteHeader(http.StatusInternalServerError)
+				fmt.Fprintf(w, soapFault, "725", "OnlyPermanentLeasesSupported")
+			}
+		case `"urn:schemas-upnp-org:service:WANIPConnection:1#DeletePortMapping"`:
+			w.WriteHeader(http.StatusInternalServerError)
+			fmt.Fprintf(w, soapFault, "714", "NoSuchEntryInArray")
+		}
+	})
+	s := httptest.NewServer(mux)
+	defer s.Close()
+
+	u, err := newUPnP(s.URL+"/rootDesc.xml", time.Second)
+	require.NoError(t, err)
+	require.Equal(t, s.URL+"/ctl/IPConn", u.controlURL)
+
+	ip, err := u.ExternalIP()
+	require.NoError(t, err)
+	require.Equal(t, "1.2.3.4", ip.String())
+
+	// the gateway only supports the permanent leases
+	port, lifetime, err := u.AddMapping("tcp", 7200, 7200, "suncoin", time.Minute*20)
+	require.NoError(t, err)
+	require.Equal(t, 7200, port)
+	require.Equal(t, time.Duration(0), lifetime)
+	require.Equal(t, []string{"1200", "0"}, leases)
+
+	err = u.DeleteMapping("tcp", 7200, 7200)
+	require.Equal(t, soapError{Code: "714", Description: "NoSuchEntryInArray"}, err)
+	require.Len(t, actions, 4)
+}
@@ -0,0 +1,496 @@
+// Package noise encrypts TCP connections by the Noise protocol, Noise_XX_25519_ChaChaPoly_SHA256.
+// Both sides authenticate by their static curve25519 keys, the keys are exchanged encrypted, so a
+// passive observer learns neither the data nor the keys of the peers.
+package noise
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	protocolName = "Noise_XX_25519_ChaChaPoly_SHA256"
+	// the prologue is mixed into the handshake, the handshake fails with the other protocols
+	prologue = "suncoin"
+
+	keyLen = 32
+	tagLen = 16
+	// the frames are prefixed by a 2 bytes length
+	maxFrameLen   = 65535
+	maxPayloadLen = maxFrameLen - tagLen
+)
+
+var (
+	// ErrInvalidKey the key isn't 32 bytes of hex
+	ErrInvalidKey = errors.New("noise: invalid key")
+	// ErrHandshakeFailed a handshake message doesn't decrypt or is malformed
+	ErrHandshakeFailed = errors.New("noise: handshake failed")
+	// ErrWeakKey the key of the peer is a low order point
+	ErrWeakKey = errors.New("noise: weak key")
+)
+
+// Key is a curve25519 key
+type Key [keyLen]byte
+
+// String returns the hex of the key
+func (k Key) String() string {
+	return hex.EncodeToString(k[:])
+}
+
+// ParseKey parses the hex of a key
+func ParseKey(s string) (Key, error) {
+	var k Key
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != keyLen {
+		return k, ErrInvalidKey
+	}
+	copy(k[:], b)
+	return k, nil
+}
+
+// Keypair is the static keys of a node
+type Keypair struct {
+	Private Key
+	Public  Key
+}
+
+// GenerateKeypair generates a random keypair
+func GenerateKeypair() (Keypair, error) {
+	var priv Key
+	if _, err := io.ReadFull(rand.Reader, priv[:]); err != nil {
+		return Keypair{}, err
+	}
+	return NewKeypair(priv), nil
+}
+
+// NewKeypair returns the keypair of the private key
+func NewKeypair(priv Key) Keypair {
+	kp := Keypair{Private: priv}
+	curve25519.ScalarBaseMult((*[keyLen]byte)(&kp.Public), (*[keyLen]byte)(&kp.Private))
+	return kp
+}
+
+// LoadKeypair loads the private key from the hex in the file, the key is generated and saved if
+// the file doesn't exist
+func LoadKeypair(filename string) (Keypair, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err == nil {
+		priv, err := ParseKey(string(b))
+		if err != nil {
+			return Keypair{}, fmt.Errorf("%s: %v", filename, err)
+		}
+		return NewKeypair(priv), nil
+	}
+	if !os.IsNotExist(err) {
+		return Keypair{}, err
+	}
+
+	kp, err := GenerateKeypair()
+	if err != nil {
+		return Keypair{}, err
+	}
+	if err := ioutil.WriteFile(filename, []byte(kp.Private.String()+"\n"), 0600); err != nil {
+		return Keypair{}, err
+	}
+	return kp, nil
+}
+
+// dh returns the shared secret of the private key and the public key of the peer
+func dh(priv, pub Key) (Key, error) {
+	var out Key
+	curve25519.ScalarMult((*[keyLen]byte)(&out), (*[keyLen]byte)(&priv), (*[keyLen]byte)(&pub))
+	if out == (Key{}) {
+		return Key{}, ErrWeakKey
+	}
+	return out, nil
+}
+
+// cipherState encrypts by ChaCha20-Poly1305 with a counter nonce
+type cipherState struct {
+	k      Key
+	hasKey bool
+	n      uint64
+}
+
+func (cs *cipherState) nonce() []byte {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.LittleEndian.PutUint64(nonce[4:], cs.n)
+	return nonce
+}
+
+func (cs *cipherState) encrypt(ad, plaintext []byte) ([]byte, error) {
+	if !cs.hasKey {
+		return plaintext, nil
+	}
+	aead, err := chacha20poly1305.New(cs.k[:])
+	if err != nil {
+		return nil, err
+	}
+	ciphertext := aead.Seal(nil, cs.nonce(), plaintext, ad)
+	cs.n++
+	return ciphertext, nil
+}
+
+func (cs *cipherState) decrypt(ad, ciphertext []byte) ([]byte, error) {
+	if !cs.hasKey {
+		return ciphertext, nil
+	}
+	aead, err := chacha20poly1305.New(cs.k[:])
+	if err != nil {
+		return nil, err
+	}
+	plaintext, err := aead.Open(nil, cs.nonce(), ciphertext, ad)
+	if err != nil {
+		return nil, err
+	}
+	cs.n++
+	return plaintext, nil
+}
+
+// symmetricState is the chaining key and the hash of the handshake
+type symmetricState struct {
+	cs cipherState
+	ck Key
+	h  Key
+}
+
+func newSymmetricState() *symmetricState {
+	ss := &symmetricState{}
+	// the name is 32 bytes, it's used as the hash
+	copy(ss.h[:], protocolName)
+	ss.ck = ss.h
+	ss.mixHash([]byte(prologue))
+	return ss
+}
+
+// hkdf2 derives two keys from the chaining key and the input
+func (ss *symmetricState) hkdf2(ikm []byte) (Key, Key) {
+	var k1, k2 Key
+	r := hkdf.New(sha256.New, ikm, ss.ck[:], nil)
+	// reading 64 bytes of the sha256 hkdf never fails
+	io.ReadFull(r, k1[:])
+	io.ReadFull(r, k2[:])
+	return k1, k2
+}
+
+func (ss *symmetricState) mixKey(ikm Key) {
+	ck, k := ss.hkdf2(ikm[:])
+	ss.ck = ck
+	ss.cs = cipherState{k: k, hasKey: true}
+}
+
+func (ss *symmetricState) mixHash(data []byte) {
+	h := sha256.New()
+	h.Write(ss.h[:])
+	h.Write(data)
+	copy(ss.h[:], h.Sum(nil))
+}
+
+func (ss *symmetricState) encryptAndHash(plaintext []byte) ([]byte, error) {
+	ciphertext, err := ss.cs.encrypt(ss.h[:], plaintext)
+	if err != nil {
+		return nil, err
+	}
+	ss.mixHash(ciphertext)
+	return ciphertext, nil
+}
+
+func (ss *symmetricState) decryptAndHash(ciphertext []byte) ([]byte, error) {
+	plaintext, err := ss.cs.decrypt(ss.h[:], ciphertext)
+	if err != nil {
+		return nil, ErrHandshakeFailed
+	}
+	ss.mixHash(ciphertext)
+	return plaintext, nil
+}
+
+// split returns the cipher states of the initiator and the responder
+func (ss *symmetricState) split() (*cipherState, *cipherState) {
+	k1, k2 := ss.hkdf2(nil)
+	return &cipherState{k: k1, hasKey: true}, &cipherState{k: k2, hasKey: true}
+}
+
+// Conn is a connection encrypted by the keys of the handshake
+type Conn struct {
+	net.Conn
+	remote Key
+
+	rmu  sync.Mutex
+	recv *cipherState
+	// decrypted data not read yet
+	rbuf []byte
+
+	wmu  sync.Mutex
+	send *cipherState
+}
+
+// RemoteKey returns the static public key of the peer
+func (c *Conn) RemoteKey() Key {
+	return c.remote
+}
+
+// Read reads the decrypted data
+func (c *Conn) Read(b []byte) (int, error) {
+	c.rmu.Lock()
+	defer c.rmu.Unlock()
+
+	if len(c.rbuf) == 0 {
+		frame, err := readFrame(c.Conn)
+		if err != nil {
+			return 0, err
+		}
+		c.rbuf, err = c.recv.decrypt(nil, frame)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(b, c.rbuf)
+	c.rbuf = c.rbuf[n:]
+	return n, nil
+}
+
+// Write encrypts the data, it's split in frames of at most 64KB
+func (c *Conn) Write(b []byte) (int, error) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+
+	n := 0
+	for n < len(b) {
+		end := n + maxPayloadLen
+		if end > len(b) {
+			end = len(b)
+		}
+		frame, err := c.send.encrypt(nil, b[n:end])
+		if err != nil {
+			return n, err
+		}
+		if err := writeFrame(c.Conn, frame); err != nil {
+			return n, err
+		}
+		n = end
+	}
+	return n, nil
+}
+
+func readFrame(r io.Reader) ([]byte, error) {
+	var l [2]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return nil, err
+	}
+	frame := make([]byte, binary.BigEndian.Uint16(l[:]))
+	if _, err := io.ReadFull(r, frame); err != nil {
+		return nil, err
+	}
+	return frame, nil
+}
+
+func writeFrame(w io.Writer, frame []byte) error {
+	b := make([]byte, 2+len(frame))
+	binary.BigEndian.PutUint16(b, uint16(len(frame)))
+	copy(b[2:], frame)
+	_, err := w.Write(b)
+	return err
+}
+
+// Client runs the handshake as the initiator of the connection, the handshake must complete
+// within the timeout, 0 for no timeout. The connection is closed if the handshake fails.
+func Client(conn net.Conn, kp Keypair, timeout time.Duration) (*Conn, error) {
+	return handshake(conn, kp, timeout, true)
+}
+
+// Server runs the handshake as the responder of the connection, the handshake must complete
+// within the timeout, 0 for no timeout. The connection is closed if the handshake fails.
+func Server(conn net.Conn, kp Keypair, timeout time.Duration) (*Conn, error) {
+	return handshake(conn, kp, timeout, false)
+}
+
+func handshake(conn net.Conn, kp Keypair, timeout time.Duration, initiator bool) (*Conn, error) {
+	if timeout > 0 {
+		conn.SetDeadline(time.Now().Add(timeout))
+	}
+
+	var c *Conn
+	var err error
+	if initiator {
+		c, err = initiate(conn, kp)
+	} else {
+		c, err = respond(conn, kp)
+	}
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	if timeout > 0 {
+		conn.SetDeadline(time.Time{})
+	}
+	return c, nil
+}
+
+// initiate runs the XX pattern as the initiator, the messages are "-> e", "<- e, ee, s, es" and
+// "-> s, se"
+func initiate(conn net.Conn, s Keypair) (*Conn, error) {
+	ss := newSymmetricState()
+	e, err := GenerateKeypair()
+	if err != nil {
+		return nil, err
+	}
+
+	// -> e
+	ss.mixHash(e.Public[:])
+	payload, err := ss.encryptAndHash(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFrame(conn, append(e.Public[:], payload...)); err != nil {
+		return nil, err
+	}
+
+	// <- e, ee, s, es
+	msg, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) != keyLen+keyLen+tagLen+tagLen {
+		return nil, ErrHandshakeFailed
+	}
+	var re, rs Key
+	copy(re[:], msg[:keyLen])
+	ss.mixHash(re[:])
+	if err := mixDH(ss, e.Private, re); err != nil {
+		return nil, err
+	}
+	b, err := ss.decryptAndHash(msg[keyLen : 2*keyLen+tagLen])
+	if err != nil {
+		return nil, err
+	}
+	copy(rs[:], b)
+	if err := mixDH(ss, e.Private, rs); err != nil {
+		return nil, err
+	}
+	if _, err := ss.decryptAndHash(msg[2*keyLen+tagLen:]); err != nil {
+		return nil, err
+	}
+
+	// -> s, se
+	msg, err = ss.encryptAndHash(s.Public[:])
+	if err != nil {
+		return nil, err
+	}
+	if err := mixDH(ss, s.Private, re); err != nil {
+		return nil, err
+	}
+	payload, err = ss.encryptAndHash(nil)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeFrame(conn, append(msg, payload...)); err != nil {
+		return nil, err
+	}
+
+	send, recv := ss.split()
+	return &Conn{
+		Conn:   conn,
+		remote: rs,
+		send:   send,
+		recv:   recv,
+	}, nil
+}
+
+// respond runs the XX pattern as the responder
+func respond(conn net.Conn, s Keypair) (*Conn, error) {
+	ss := newSymmetricState()
+
+	// -> e
+	msg, err := readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) != keyLen {
+		return nil, ErrHandshakeFailed
+	}
+	var re, rs Key
+	copy(re[:], msg)
+	ss.mixHash(re[:])
+	if _, err := ss.decryptAndHash(nil); err != nil {
+		return nil, err
+	}
+
+	// <- e, ee, s, es
+	e, err := GenerateKeypair()
+	if err != nil {
+		return nil, err
+	}
+	ss.mixHash(e.Public[:])
+	if err := mixDH(ss, e.Private, re); err != nil {
+		return nil, err
+	}
+	cs, err := ss.encryptAndHash(s.Public[:])
+	if err != nil {
+		return nil, err
+	}
+	if err := mixDH(ss, s.Private, re); err != nil {
+		return nil, err
+	}
+	payload, err := ss.encryptAndHash(nil)
+	if err != nil {
+		return nil, err
+	}
+	out := append(e.Public[:], cs...)
+	if err := writeFrame(conn, append(out, payload...)); err != nil {
+		return nil, err
+	}
+
+	// -> s, se
+	msg, err = readFrame(conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(msg) != keyLen+tagLen+tagLen {
+		return nil, ErrHandshakeFailed
+	}
+	b, err := ss.decryptAndHash(msg[:keyLen+tagLen])
+	if err != nil {
+		return nil, err
+	}
+	copy(rs[:], b)
+	if err := mixDH(ss, e.Private, rs); err != nil {
+		return nil, err
+	}
+	if _, err := ss.decryptAndHash(msg[keyLen+tagLen:]); err != nil {
+		return nil, err
+	}
+
+	recv, send := ss.split()
+	return &Conn{
+		Conn:   conn,
+		remote: rs,
+		send:   send,
+		recv:   recv,
+	}, nil
+}
+
+func mixDH(ss *symmetricState, priv, pub Key) error {
+	k, err := dh(priv, pub)
+	if err != nil {
+		return err
+	}
+	ss.mixKey(k)
+	return nil
+}
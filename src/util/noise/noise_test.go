@@ -0,0 +1,165 @@
+package noise
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type handshakeResult struct {
+	conn *Conn
+	err  error
+}
+
+// pipe runs the handshake of the client and the server keys over a pipe
+func pipe(t *testing.T, client, server Keypair) (*Conn, *Conn) {
+	c1, c2 := net.Pipe()
+	done := make(chan handshakeResult, 1)
+	go func() {
+		c, err := Server(c2, server, time.Second)
+		done <- handshakeResult{c, err}
+	}()
+
+	c, err := Client(c1, client, time.Second)
+	require.NoError(t, err)
+	r := <-done
+	require.NoError(t, r.err)
+	return c, r.conn
+}
+
+func TestHandshake(t *testing.T) {
+	client, err := GenerateKeypair()
+	require.NoError(t, err)
+	server, err := GenerateKeypair()
+	require.NoError(t, err)
+
+	c, s := pipe(t, client, server)
+	defer c.Close()
+	defer s.Close()
+
+	// the static keys are exchanged
+	require.Equal(t, server.Public, c.RemoteKey())
+	require.Equal(t, client.Public, s.RemoteKey())
+
+	// the data larger than a frame is split and read back in order
+	data := bytes.Repeat([]byte("suncoin"), 20000)
+	go func() {
+		c.Write(data)
+		s.Write([]byte("pong"))
+	}()
+
+	b := make([]byte, len(data))
+	_, err = io.ReadFull(s, b)
+	require.NoError(t, err)
+	require.Equal(t, data, b)
+
+	b = make([]byte, 4)
+	_, err = io.ReadFull(c, b)
+	require.NoError(t, err)
+	require.Equal(t, "pong", string(b))
+}
+
+func TestHandshakeCiphertext(t *testing.T) {
+	client, err := GenerateKeypair()
+	require.NoError(t, err)
+	server, err := GenerateKeypair()
+	require.NoError(t, err)
+
+	c1, c2 := net.Pipe()
+	defer c1.Close()
+	defer c2.Close()
+
+	// the messages of the client are captured, the keys and the data aren't in the clear
+	var captured bytes.Buffer
+	r, w := net.Pipe()
+	go func() {
+		io.Copy(io.MultiWriter(&captured, w), c2)
+		w.Close()
+	}()
+
+	done := make(chan handshakeResult, 1)
+	go func() {
+		c, err := Server(&relayConn{Conn: c2, r: r}, server, time.Second)
+		done <- handshakeResult{c, err}
+	}()
+
+	c, err := Client(c1, client, time.Second)
+	require.NoError(t, err)
+	res := <-done
+	require.NoError(t, res.err)
+
+	go c.Write([]byte("secret txn"))
+	b := make([]byte, 10)
+	_, err = io.ReadFull(res.conn, b)
+	require.NoError(t, err)
+	require.Equal(t, "secret txn", string(b))
+
+	require.False(t, bytes.Contains(captured.Bytes(), client.Public[:]))
+	require.False(t, bytes.Contains(captured.Bytes(), []byte("secret txn")))
+}
+
+// relayConn reads from r and writes to the connection
+type relayConn struct {
+	net.Conn
+	r io.Reader
+}
+
+func (c *relayConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+func TestHandshakeFailed(t *testing.T) {
+	server, err := GenerateKeypair()
+	require.NoError(t, err)
+
+	c1, c2 := net.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		_, err := Server(c2, server, time.Second)
+		done <- err
+	}()
+
+	// a frame of the wrong size
+	require.NoError(t, writeFrame(c1, []byte("not a noise handshake")))
+	require.Equal(t, ErrHandshakeFailed, <-done)
+	c1.Close()
+
+	// the handshake times out
+	c1, c2 = net.Pipe()
+	defer c1.Close()
+	_, err = Server(c2, server, time.Millisecond*10)
+	require.Error(t, err)
+}
+
+func TestLoadKeypair(t *testing.T) {
+	dir, err := ioutil.TempDir("", "noise")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fn := filepath.Join(dir, "peer.key")
+	kp, err := LoadKeypair(fn)
+	require.NoError(t, err)
+
+	// the generated key is saved
+	kp2, err := LoadKeypair(fn)
+	require.NoError(t, err)
+	require.Equal(t, kp, kp2)
+
+	pub, err := ParseKey(kp.Public.String())
+	require.NoError(t, err)
+	require.Equal(t, kp.Public, pub)
+
+	require.NoError(t, ioutil.WriteFile(fn, []byte("abcd"), 0600))
+	_, err = LoadKeypair(fn)
+	require.Error(t, err)
+
+	_, err = ParseKey("zz")
+	require.Equal(t, ErrInvalidKey, err)
+}
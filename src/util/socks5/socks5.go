@@ -0,0 +1,236 @@
+// Package socks5 dials TCP connections through a SOCKS5 proxy (RFC 1928), e.g. Tor. The host
+// names are resolved by the proxy, so .onion addresses can be dialed through Tor.
+package socks5
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
+)
+
+const (
+	version = 5
+
+	methodNoAuth       = 0
+	methodUserPassword = 2
+	methodNoAcceptable = 0xff
+
+	// version of the username/password subnegotiation, RFC 1929
+	userPasswordVersion = 1
+
+	cmdConnect = 1
+
+	atypIPv4   = 1
+	atypDomain = 3
+	atypIPv6   = 4
+)
+
+var (
+	// ErrNoAcceptableMethod the proxy accepts none of the offered authentication methods
+	ErrNoAcceptableMethod = errors.New("socks5: no acceptable authentication method")
+	// ErrAuthFailed the proxy rejects the username and the password
+	ErrAuthFailed = errors.New("socks5: authentication failed")
+
+	replies = map[byte]string{
+		1: "general server failure",
+		2: "connection not allowed by ruleset",
+		3: "network unreachable",
+		4: "host unreachable",
+		5: "connection refused",
+		6: "TTL expired",
+		7: "command not supported",
+		8: "address type not supported",
+	}
+)
+
+// Dialer dials the connections through the proxy
+type Dialer struct {
+	// Address of the proxy, host:port
+	ProxyAddress string
+	// Username and Password for the proxy, the authentication is skipped if Username is empty.
+	// Tor isolates the circuits of the different credentials.
+	Username string
+	Password string
+	// Timeout of dialing the proxy and the handshake, 0 for no timeout
+	Timeout time.Duration
+}
+
+// Addr is the address dialed through the proxy
+type Addr struct {
+	Host string
+	Port int
+}
+
+// Network returns the network of the address
+func (a Addr) Network() string {
+	return "tcp"
+}
+
+// String returns host:port
+func (a Addr) String() string {
+	return net.JoinHostPort(a.Host, strconv.Itoa(a.Port))
+}
+
+// conn reports the dialed address instead of the address of the proxy as the remote address
+type conn struct {
+	net.Conn
+	remote Addr
+}
+
+func (c *conn) RemoteAddr() net.Addr {
+	return c.remote
+}
+
+// Dial connects to the address through the proxy, the RemoteAddr of the connection is the
+// address instead of the proxy
+func (d *Dialer) Dial(address string) (net.Conn, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port in %s", address)
+	}
+	if len(host) > 255 {
+		return nil, fmt.Errorf("host name of %s is too long", address)
+	}
+
+	c, err := net.DialTimeout("tcp", d.ProxyAddress, d.Timeout)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.Timeout > 0 {
+		c.SetDeadline(time.Now().Add(d.Timeout))
+	}
+
+	if err := d.handshake(c, host, uint16(port)); err != nil {
+		c.Close()
+		return nil, err
+	}
+
+	if d.Timeout > 0 {
+		c.SetDeadline(time.Time{})
+	}
+
+	return &conn{
+		Conn:   c,
+		remote: Addr{Host: host, Port: int(port)},
+	}, nil
+}
+
+// handshake authenticates and requests connecting to host:port
+func (d *Dialer) handshake(rw io.ReadWriter, host string, port uint16) error {
+	method := byte(methodNoAuth)
+	if d.Username != "" {
+		method = methodUserPassword
+	}
+
+	if _, err := rw.Write([]byte{version, 1, method}); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(rw, buf); err != nil {
+		return err
+	}
+	if buf[0] != version {
+		return fmt.Errorf("socks5: unexpected version %d", buf[0])
+	}
+	switch buf[1] {
+	case method:
+	case methodNoAcceptable:
+		return ErrNoAcceptableMethod
+	default:
+		return fmt.Errorf("socks5: unexpected method %d", buf[1])
+	}
+
+	if method == methodUserPassword {
+		if err := d.authenticate(rw); err != nil {
+			return err
+		}
+	}
+
+	req := []byte{version, cmdConnect, 0}
+	if ip := net.ParseIP(host); ip == nil {
+		req = append(req, atypDomain, byte(len(host)))
+		req = append(req, host...)
+	} else if ip4 := ip.To4(); ip4 != nil {
+		req = append(req, atypIPv4)
+		req = append(req, ip4...)
+	} else {
+		req = append(req, atypIPv6)
+		req = append(req, ip.To16()...)
+	}
+	req = append(req, byte(port>>8), byte(port))
+
+	if _, err := rw.Write(req); err != nil {
+		return err
+	}
+
+	return readReply(rw)
+}
+
+// authenticate sends the username and the password, RFC 1929
+func (d *Dialer) authenticate(rw io.ReadWriter) error {
+	if len(d.Username) > 255 || len(d.Password) > 255 {
+		return errors.New("socks5: username or password is too long")
+	}
+
+	req := []byte{userPasswordVersion, byte(len(d.Username))}
+	req = append(req, d.Username...)
+	req = append(req, byte(len(d.Password)))
+	req = append(req, d.Password...)
+	if _, err := rw.Write(req); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(rw, buf); err != nil {
+		return err
+	}
+	if buf[1] != 0 {
+		return ErrAuthFailed
+	}
+	return nil
+}
+
+// readReply reads the reply of the connect request, the bound address is discarded
+func readReply(r io.Reader) error {
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return err
+	}
+	if buf[0] != version {
+		return fmt.Errorf("socks5: unexpected version %d", buf[0])
+	}
+	if buf[1] != 0 {
+		if msg, ok := replies[buf[1]]; ok {
+			return fmt.Errorf("socks5: %s", msg)
+		}
+		return fmt.Errorf("socks5: unknown reply %d", buf[1])
+	}
+
+	var n int
+	switch buf[3] {
+	case atypIPv4:
+		n = net.IPv4len
+	case atypIPv6:
+		n = net.IPv6len
+	case atypDomain:
+		if _, err := io.ReadFull(r, buf[:1]); err != nil {
+			return err
+		}
+		n = int(buf[0])
+	default:
+		return fmt.Errorf("socks5: unexpected address type %d", buf[3])
+	}
+
+	// the bound address and port
+	_, err := io.ReadFull(r, make([]byte, n+2))
+	return err
+}
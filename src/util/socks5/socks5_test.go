@@ -0,0 +1,168 @@
+package socks5
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProxy accepts one connection, records the requested address and echoes the data
+type fakeProxy struct {
+	l        net.Listener
+	username string
+	password string
+	reply    byte
+	target   chan string
+}
+
+func newFakeProxy(t *testing.T, username, password string, reply byte) *fakeProxy {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	p := &fakeProxy{
+		l:        l,
+		username: username,
+		password: password,
+		reply:    reply,
+		target:   make(chan string, 1),
+	}
+	go p.serve()
+	return p
+}
+
+func (p *fakeProxy) serve() {
+	c, err := p.l.Accept()
+	if err != nil {
+		return
+	}
+	defer c.Close()
+
+	buf := make([]byte, 512)
+	if _, err := io.ReadFull(c, buf[:2]); err != nil {
+		return
+	}
+	methods := buf[:buf[1]]
+	if _, err := io.ReadFull(c, methods); err != nil {
+		return
+	}
+
+	want := byte(methodNoAuth)
+	if p.username != "" {
+		want = methodUserPassword
+	}
+	accepted := false
+	for _, m := range methods {
+		accepted = accepted || m == want
+	}
+	if !accepted {
+		c.Write([]byte{version, methodNoAcceptable})
+		return
+	}
+	c.Write([]byte{version, want})
+
+	if want == methodUserPassword {
+		io.ReadFull(c, buf[:2])
+		username := make([]byte, buf[1])
+		io.ReadFull(c, username)
+		io.ReadFull(c, buf[:1])
+		password := make([]byte, buf[0])
+		io.ReadFull(c, password)
+		if string(username) != p.username || string(password) != p.password {
+			c.Write([]byte{userPasswordVersion, 1})
+			return
+		}
+		c.Write([]byte{userPasswordVersion, 0})
+	}
+
+	if _, err := io.ReadFull(c, buf[:4]); err != nil {
+		return
+	}
+	var host string
+	switch buf[3] {
+	case atypIPv4:
+		io.ReadFull(c, buf[:4])
+		host = net.IP(buf[:4]).String()
+	case atypIPv6:
+		io.ReadFull(c, buf[:16])
+		host = net.IP(buf[:16]).String()
+	case atypDomain:
+		io.ReadFull(c, buf[:1])
+		name := make([]byte, buf[0])
+		io.ReadFull(c, name)
+		host = string(name)
+	}
+	io.ReadFull(c, buf[:2])
+	port := int(buf[0])<<8 | int(buf[1])
+	p.target <- Addr{Host: host, Port: port}.String()
+
+	c.Write([]byte{version, p.reply, 0, atypIPv4, 127, 0, 0, 1, 0x1c, 0x20})
+	if p.reply != 0 {
+		return
+	}
+	io.Copy(c, c)
+}
+
+func TestDial(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		address  string
+		username string
+		password string
+		target   string
+	}{
+		{"ipv4", "1.2.3.4:7200", "", "", "1.2.3.4:7200"},
+		{"ipv6", "[2001:db8::1]:7200", "", "", "[2001:db8::1]:7200"},
+		{"onion", "expyuzz4wqqyqhjn.onion:7200", "", "", "expyuzz4wqqyqhjn.onion:7200"},
+		{"auth", "1.2.3.4:7200", "user", "pass", "1.2.3.4:7200"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newFakeProxy(t, tc.username, tc.password, 0)
+			defer p.l.Close()
+
+			d := &Dialer{
+				ProxyAddress: p.l.Addr().String(),
+				Username:     tc.username,
+				Password:     tc.password,
+				Timeout:      time.Second,
+			}
+			c, err := d.Dial(tc.address)
+			require.NoError(t, err)
+			defer c.Close()
+
+			require.Equal(t, tc.target, <-p.target)
+			require.Equal(t, tc.target, c.RemoteAddr().String())
+
+			_, err = c.Write([]byte("ping"))
+			require.NoError(t, err)
+			buf := make([]byte, 4)
+			_, err = io.ReadFull(c, buf)
+			require.NoError(t, err)
+			require.Equal(t, "ping", string(buf))
+		})
+	}
+}
+
+func TestDialErrors(t *testing.T) {
+	p := newFakeProxy(t, "user", "pass", 0)
+	d := &Dialer{ProxyAddress: p.l.Addr().String(), Timeout: time.Second}
+	_, err := d.Dial("1.2.3.4:7200")
+	require.Equal(t, ErrNoAcceptableMethod, err)
+	p.l.Close()
+
+	p = newFakeProxy(t, "user", "pass", 0)
+	d = &Dialer{ProxyAddress: p.l.Addr().String(), Username: "user", Password: "wrong", Timeout: time.Second}
+	_, err = d.Dial("1.2.3.4:7200")
+	require.Equal(t, ErrAuthFailed, err)
+	p.l.Close()
+
+	p = newFakeProxy(t, "", "", 5)
+	d = &Dialer{ProxyAddress: p.l.Addr().String(), Timeout: time.Second}
+	_, err = d.Dial("1.2.3.4:7200")
+	require.EqualError(t, err, "socks5: connection refused")
+	p.l.Close()
+
+	_, err = d.Dial("1.2.3.4")
+	require.Error(t, err)
+}
@@ -0,0 +1,314 @@
+// Package websocket implements the server side of the WebSocket protocol (RFC 6455)
+// needed to push the JSON messages to the clients
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Opcodes of the frames
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xa
+)
+
+// Status codes of the close frames
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+)
+
+// MaxMessageSize is the max size of the message read from the client
+const MaxMessageSize = 64 * 1024
+
+// writeTimeout is the timeout of writing a frame to the client
+const writeTimeout = 10 * time.Second
+
+// acceptGUID is appended to the key of the client to compute the accept key
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var (
+	// ErrClosed is returned if the connection is closed by the client
+	ErrClosed = errors.New("websocket connection closed")
+	// ErrMessageTooLarge is returned if the message of the client exceeds MaxMessageSize
+	ErrMessageTooLarge = errors.New("websocket message too large")
+	// ErrProtocol is returned if the client violates the protocol
+	ErrProtocol = errors.New("websocket protocol error")
+)
+
+// Conn is a websocket connection. ReadMessage should be called by one goroutine, the
+// writes are safe for concurrent use.
+type Conn struct {
+	conn net.Conn
+	br   *bufio.Reader
+	wmu  sync.Mutex
+	// whether the close frame is sent
+	closeSent bool
+}
+
+// IsUpgrade returns whether the request asks for a websocket connection
+func IsUpgrade(r *http.Request) bool {
+	return headerContains(r.Header, "Connection", "upgrade") &&
+		headerContains(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade upgrades the http connection to the websocket connection, an http error is
+// responded if the handshake fails
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != "GET" {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket handshake requires GET")
+	}
+
+	if !IsUpgrade(r) {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return nil, errors.New("not a websocket handshake")
+	}
+
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("unsupported websocket version")
+	}
+
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Bad request", http.StatusBadRequest)
+		return nil, errors.New("missing Sec-WebSocket-Key")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return nil, errors.New("http connection can't be hijacked")
+	}
+
+	conn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + AcceptKey(key) + "\r\n\r\n"
+
+	conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := conn.Write([]byte(resp)); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	conn.SetWriteDeadline(time.Time{})
+
+	return &Conn{
+		conn: conn,
+		br:   rw.Reader,
+	}, nil
+}
+
+// AcceptKey returns the Sec-WebSocket-Accept of the Sec-WebSocket-Key
+func AcceptKey(key string) string {
+	h := sha1.New()
+	io.WriteString(h, key+acceptGUID)
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(h http.Header, name, token string) bool {
+	for _, v := range h[http.CanonicalHeaderKey(name)] {
+		for _, s := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(s), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RemoteAddr returns the address of the client
+func (c *Conn) RemoteAddr() net.Addr {
+	return c.conn.RemoteAddr()
+}
+
+// ReadMessage reads a text or binary message of the client. The pings are answered and
+// the fragments are joined, ErrClosed is returned once the client closes the connection.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	var op int
+	var msg []byte
+	for {
+		fin, opcode, data, err := c.readFrame()
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch opcode {
+		case OpPing:
+			if err := c.WriteMessage(OpPong, data); err != nil {
+				return 0, nil, err
+			}
+			continue
+		case OpPong:
+			continue
+		case OpClose:
+			c.writeClose(data)
+			return 0, nil, ErrClosed
+		case OpText, OpBinary:
+			if msg != nil {
+				return 0, nil, ErrProtocol
+			}
+			op = opcode
+			msg = []byte{}
+		case OpContinuation:
+			if msg == nil {
+				return 0, nil, ErrProtocol
+			}
+		default:
+			return 0, nil, ErrProtocol
+		}
+
+		if len(msg)+len(data) > MaxMessageSize {
+			return 0, nil, ErrMessageTooLarge
+		}
+		msg = append(msg, data...)
+
+		if fin {
+			return op, msg, nil
+		}
+	}
+}
+
+// readFrame reads a frame, the frames of the client must be masked
+func (c *Conn) readFrame() (bool, int, []byte, error) {
+	var hdr [2]byte
+	if _, err := io.ReadFull(c.br, hdr[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	fin := hdr[0]&0x80 != 0
+	opcode := int(hdr[0] & 0x0f)
+	if hdr[0]&0x70 != 0 || hdr[1]&0x80 == 0 {
+		return false, 0, nil, ErrProtocol
+	}
+
+	n := uint64(hdr[1] & 0x7f)
+	switch n {
+	case 126:
+		var b [2]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = uint64(binary.BigEndian.Uint16(b[:]))
+	case 127:
+		var b [8]byte
+		if _, err := io.ReadFull(c.br, b[:]); err != nil {
+			return false, 0, nil, err
+		}
+		n = binary.BigEndian.Uint64(b[:])
+	}
+
+	// the control frames are at most 125 bytes and not fragmented
+	if opcode >= OpClose && (n > 125 || !fin) {
+		return false, 0, nil, ErrProtocol
+	}
+
+	if n > MaxMessageSize {
+		return false, 0, nil, ErrMessageTooLarge
+	}
+
+	var mask [4]byte
+	if _, err := io.ReadFull(c.br, mask[:]); err != nil {
+		return false, 0, nil, err
+	}
+
+	data := make([]byte, n)
+	if _, err := io.ReadFull(c.br, data); err != nil {
+		return false, 0, nil, err
+	}
+
+	for i := range data {
+		data[i] ^= mask[i%4]
+	}
+
+	return fin, opcode, data, nil
+}
+
+// WriteMessage writes a message of the opcode in a single frame
+func (c *Conn) WriteMessage(opcode int, data []byte) error {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	return c.writeFrame(opcode, data)
+}
+
+// WriteJSON writes v as a text message
+func (c *Conn) WriteJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(OpText, b)
+}
+
+func (c *Conn) writeFrame(opcode int, data []byte) error {
+	if c.closeSent {
+		return ErrClosed
+	}
+
+	// the frames of the server are not masked
+	hdr := []byte{0x80 | byte(opcode), 0}
+	switch n := len(data); {
+	case n < 126:
+		hdr[1] = byte(n)
+	case n <= 0xffff:
+		hdr[1] = 126
+		hdr = append(hdr, 0, 0)
+		binary.BigEndian.PutUint16(hdr[2:], uint16(n))
+	default:
+		hdr[1] = 127
+		hdr = append(hdr, 0, 0, 0, 0, 0, 0, 0, 0)
+		binary.BigEndian.PutUint64(hdr[2:], uint64(n))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(writeTimeout))
+	if _, err := c.conn.Write(append(hdr, data...)); err != nil {
+		return err
+	}
+
+	if opcode == OpClose {
+		c.closeSent = true
+	}
+	return nil
+}
+
+// writeClose sends the close frame once, the status code of the client is echoed
+func (c *Conn) writeClose(data []byte) {
+	c.wmu.Lock()
+	defer c.wmu.Unlock()
+	if len(data) > 2 {
+		data = data[:2]
+	}
+	c.writeFrame(OpClose, data)
+}
+
+// Close sends the close frame with the status code and closes the connection
+func (c *Conn) Close(code uint16) error {
+	var b [2]byte
+	binary.BigEndian.PutUint16(b[:], code)
+	c.writeClose(b[:])
+	return c.conn.Close()
+}
@@ -0,0 +1,130 @@
+package websocket
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAcceptKey(t *testing.T) {
+	// the example of RFC 6455
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="))
+}
+
+// writeClientFrame writes a masked frame
+func writeClientFrame(t *testing.T, w io.Writer, fin bool, opcode int, data []byte) {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	require.True(t, len(data) < 126)
+	frame := []byte{b0, 0x80 | byte(len(data))}
+	mask := []byte{1, 2, 3, 4}
+	frame = append(frame, mask...)
+	for i, c := range data {
+		frame = append(frame, c^mask[i%4])
+	}
+	_, err := w.Write(frame)
+	require.NoError(t, err)
+}
+
+// readServerFrame reads an unmasked frame
+func readServerFrame(t *testing.T, r *bufio.Reader) (int, []byte) {
+	var hdr [2]byte
+	_, err := io.ReadFull(r, hdr[:])
+	require.NoError(t, err)
+	require.Zero(t, hdr[1]&0x80)
+
+	n := int(hdr[1] & 0x7f)
+	if n == 126 {
+		var b [2]byte
+		_, err := io.ReadFull(r, b[:])
+		require.NoError(t, err)
+		n = int(binary.BigEndian.Uint16(b[:]))
+	}
+
+	data := make([]byte, n)
+	_, err = io.ReadFull(r, data)
+	require.NoError(t, err)
+	return int(hdr[0] & 0x0f), data
+}
+
+func TestConn(t *testing.T) {
+	done := make(chan error, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			done <- err
+			return
+		}
+
+		for {
+			op, msg, err := conn.ReadMessage()
+			if err != nil {
+				conn.Close(CloseNormal)
+				done <- err
+				return
+			}
+			conn.WriteMessage(op, append([]byte("echo "), msg...))
+		}
+	}))
+	defer srv.Close()
+
+	// not a websocket handshake
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+	require.Error(t, <-done)
+
+	c, err := net.Dial("tcp", strings.TrimPrefix(srv.URL, "http://"))
+	require.NoError(t, err)
+	defer c.Close()
+
+	_, err = io.WriteString(c, "GET / HTTP/1.1\r\n"+
+		"Host: localhost\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: keep-alive, Upgrade\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+	require.NoError(t, err)
+
+	br := bufio.NewReader(c)
+	resp, err = http.ReadResponse(br, nil)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusSwitchingProtocols, resp.StatusCode)
+	assert.Equal(t, "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=", resp.Header.Get("Sec-WebSocket-Accept"))
+
+	writeClientFrame(t, c, true, OpText, []byte("hello"))
+	op, data := readServerFrame(t, br)
+	assert.Equal(t, OpText, op)
+	assert.Equal(t, "echo hello", string(data))
+
+	// the ping between the fragments is answered, the fragments are joined
+	writeClientFrame(t, c, false, OpText, []byte("hel"))
+	writeClientFrame(t, c, true, OpPing, []byte("p"))
+	writeClientFrame(t, c, true, OpContinuation, []byte("lo"))
+
+	op, data = readServerFrame(t, br)
+	assert.Equal(t, OpPong, op)
+	assert.Equal(t, "p", string(data))
+
+	op, data = readServerFrame(t, br)
+	assert.Equal(t, OpText, op)
+	assert.Equal(t, "echo hello", string(data))
+
+	// the close frame is echoed
+	writeClientFrame(t, c, true, OpClose, []byte{0x03, 0xe8})
+	op, data = readServerFrame(t, br)
+	assert.Equal(t, OpClose, op)
+	assert.Equal(t, []byte{0x03, 0xe8}, data)
+	assert.Equal(t, ErrClosed, <-done)
+}
@@ -0,0 +1,135 @@
+package visor
+
+import (
+	"errors"
+	"math"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// MaxAddressPageSize is the max number of the transactions or outputs in a page of the address
+const MaxAddressPageSize = 100
+
+// AddressTxnsPage is a page of the transactions of the address, the confirmed ones in the
+// order of the blocks, followed by the unconfirmed ones
+type AddressTxnsPage struct {
+	Txns []TransactionResult `json:"txns"`
+	// Number of the transactions of the address
+	Total uint64 `json:"total"`
+	// Cursor of the next page, only valid when HasMore is true
+	NextCursor uint64 `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// AddressUxOutsPage is a page of the outputs received by the address, in the order of
+// the blocks, the spent ones included
+type AddressUxOutsPage struct {
+	UxOuts []ReadableOutputVerbose `json:"uxouts"`
+	// Number of the outputs of the address
+	Total uint64 `json:"total"`
+	// Cursor of the next page, only valid when HasMore is true
+	NextCursor uint64 `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+// GetAddressTxnsPage returns at most pageSize transactions of the address from the cursor,
+// the cursor of the first page is 0
+func (vs *Visor) GetAddressTxnsPage(addr cipher.Address, cursor uint64, pageSize int) (*AddressTxnsPage, error) {
+	pageSize, err := checkAddressPageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	start := cursorIndex(cursor)
+	txs, n, err := vs.history.GetAddrTxnsPage(addr, start, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	txns, err := vs.confirmedTransactions(txs)
+	if err != nil {
+		return nil, err
+	}
+
+	// the unconfirmed txns follow the confirmed ones
+	uncfm := vs.unconfirmedAddressTxns(addr)
+	if rest := pageSize - len(txns); rest > 0 && start+len(txns) >= n {
+		i := start + len(txns) - n
+		if i < len(uncfm) {
+			end := i + rest
+			if end > len(uncfm) {
+				end = len(uncfm)
+			}
+			txns = append(txns, uncfm[i:end]...)
+		}
+	}
+
+	page := &AddressTxnsPage{
+		Txns:  make([]TransactionResult, len(txns)),
+		Total: uint64(n + len(uncfm)),
+	}
+	for i := range txns {
+		page.Txns[i] = TransactionResult{
+			Transaction: NewReadableTransaction(&txns[i]),
+			Status:      txns[i].Status,
+			Time:        txns[i].Time,
+		}
+	}
+
+	page.NextCursor, page.HasMore = nextCursor(start+len(txns), page.Total)
+	return page, nil
+}
+
+// GetAddressUxOutsPage returns at most pageSize outputs of the address from the cursor,
+// the cursor of the first page is 0
+func (vs *Visor) GetAddressUxOutsPage(addr cipher.Address, cursor uint64, pageSize int) (*AddressUxOutsPage, error) {
+	pageSize, err := checkAddressPageSize(pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	start := cursorIndex(cursor)
+	uxs, n, err := vs.history.GetAddrUxOutsPage(addr, start, pageSize)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &AddressUxOutsPage{
+		UxOuts: make([]ReadableOutputVerbose, len(uxs)),
+		Total:  uint64(n),
+	}
+	for i, ux := range uxs {
+		page.UxOuts[i] = NewReadableOutputVerbose(*ux)
+	}
+
+	page.NextCursor, page.HasMore = nextCursor(start+len(uxs), page.Total)
+	return page, nil
+}
+
+func checkAddressPageSize(pageSize int) (int, error) {
+	if pageSize <= 0 {
+		return 0, errors.New("page size must be positive")
+	}
+
+	if pageSize > MaxAddressPageSize {
+		pageSize = MaxAddressPageSize
+	}
+	return pageSize, nil
+}
+
+// cursorIndex converts the cursor to the index, the cursor beyond the max int is past
+// the end of any page
+func cursorIndex(cursor uint64) int {
+	if cursor > math.MaxInt32 {
+		return math.MaxInt32
+	}
+	return int(cursor)
+}
+
+// nextCursor returns the cursor of the page after the end, and whether there's the page
+func nextCursor(end int, total uint64) (uint64, bool) {
+	if uint64(end) < total {
+		return uint64(end), true
+	}
+	return 0, false
+}
@@ -0,0 +1,119 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+func TestGetAddressPages(t *testing.T) {
+	f, err := ioutil.TempFile("", "address_page")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bc, err := NewBlockchain(db, walker)
+	require.NoError(t, err)
+
+	history, err := historydb.New(db)
+	require.NoError(t, err)
+
+	vs := &Visor{Blockchain: bc, Unconfirmed: NewUnconfirmedTxnPool(db), history: history}
+
+	aPub, aSec := cipher.GenerateKeyPair()
+	a := cipher.AddressFromPubKey(aPub)
+	xPub, _ := cipher.GenerateKeyPair()
+	x := cipher.AddressFromPubKey(xPub)
+
+	gb, err := bc.CreateGenesisBlock(a, 100e6, 1000)
+	require.NoError(t, err)
+	require.NoError(t, history.ProcessBlock(&gb))
+
+	// sends 1 to x, the change goes back to a
+	send := func() coin.Transaction {
+		ux := bc.Unspent().GetUnspentsOfAddr(a)[0]
+		txn := coin.Transaction{}
+		txn.PushInput(ux.Hash())
+		txn.PushOutput(x, 1e6, 0)
+		txn.PushOutput(a, ux.Body.Coins-1e6, ux.Body.Hours/4)
+		txn.SignInputs([]cipher.SecKey{aSec})
+		txn.UpdateHeader()
+		return txn
+	}
+
+	for i := 0; i < 3; i++ {
+		blk, err := bc.NewBlockFromTransactions(coin.Transactions{send()}, gb.Time()+uint64(i+1)*3600)
+		require.NoError(t, err)
+		require.NoError(t, bc.ExecuteBlock(blk))
+		require.NoError(t, history.ProcessBlock(blk))
+	}
+
+	uncfm := send()
+	_, err = vs.Unconfirmed.InjectTxn(bc, uncfm)
+	require.NoError(t, err)
+
+	_, err = vs.GetAddressTxnsPage(a, 0, 0)
+	assert.Error(t, err)
+
+	// the genesis and the 3 confirmed txns, followed by the unconfirmed one
+	var txids []string
+	var cursor uint64
+	for i := 0; i < 3; i++ {
+		page, err := vs.GetAddressTxnsPage(a, cursor, 2)
+		require.NoError(t, err)
+		assert.Equal(t, uint64(5), page.Total)
+		for _, tx := range page.Txns {
+			txids = append(txids, tx.Transaction.Hash)
+		}
+
+		if i < 2 {
+			require.True(t, page.HasMore)
+			cursor = page.NextCursor
+			continue
+		}
+
+		assert.False(t, page.HasMore)
+		require.Len(t, page.Txns, 1)
+		assert.True(t, page.Txns[0].Status.Unconfirmed)
+	}
+	require.Len(t, txids, 5)
+	assert.Equal(t, gb.Body.Transactions[0].Hash().Hex(), txids[0])
+	assert.Equal(t, uncfm.Hash().Hex(), txids[4])
+
+	page, err := vs.GetAddressTxnsPage(a, 100, 2)
+	require.NoError(t, err)
+	assert.Empty(t, page.Txns)
+	assert.False(t, page.HasMore)
+
+	// the genesis output and the 3 change outputs, the spent ones included
+	uxPage, err := vs.GetAddressUxOutsPage(a, 0, 3)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(4), uxPage.Total)
+	require.Len(t, uxPage.UxOuts, 3)
+	assert.True(t, uxPage.UxOuts[0].Spent)
+	assert.True(t, uxPage.HasMore)
+	assert.Equal(t, uint64(3), uxPage.NextCursor)
+
+	uxPage, err = vs.GetAddressUxOutsPage(a, uxPage.NextCursor, 3)
+	require.NoError(t, err)
+	require.Len(t, uxPage.UxOuts, 1)
+	assert.False(t, uxPage.UxOuts[0].Spent)
+	assert.False(t, uxPage.HasMore)
+
+	uxPage, err = vs.GetAddressUxOutsPage(x, 0, 10)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(3), uxPage.Total)
+	assert.Len(t, uxPage.UxOuts, 3)
+}
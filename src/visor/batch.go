@@ -0,0 +1,224 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// MaxTxnBatchSize is the max number of txns that can be injected in a batch
+const MaxTxnBatchSize = 256
+
+// ErrTxnBatchCycle is returned when the txns of a batch spend the outputs of each other
+var ErrTxnBatchCycle = errors.New("Transactions of the batch depend on each other in a cycle")
+
+// TxnBatchError is returned when a txn of the batch is invalid, no txn of the batch
+// is injected
+type TxnBatchError struct {
+	// index of the txn in the batch as it's given
+	Index int
+	TxID  cipher.SHA256
+	Err   error
+}
+
+func (e TxnBatchError) Error() string {
+	return fmt.Sprintf("transaction %d %s of the batch is invalid: %v", e.Index, e.TxID.Hex(), e.Err)
+}
+
+// orderTxnBatch sorts the txns so that a txn comes after the txns whose outputs it spends,
+// the given order is kept for the independent txns. Returns the indexes of the txns in
+// the batch in the new order.
+func orderTxnBatch(txns coin.Transactions, head coin.BlockHeader) ([]int, error) {
+	hashes := make(map[cipher.SHA256]int, len(txns))
+	creators := make(map[cipher.SHA256]int)
+	for i := range txns {
+		h := txns[i].Hash()
+		if j, ok := hashes[h]; ok {
+			return nil, TxnBatchError{Index: i, TxID: h, Err: fmt.Errorf("duplicate of transaction %d", j)}
+		}
+		hashes[h] = i
+
+		for _, ux := range coin.CreateUnspents(head, txns[i]) {
+			creators[ux.Hash()] = i
+		}
+	}
+
+	// number of the unordered txns each txn depends on
+	deps := make([]int, len(txns))
+	children := make([][]int, len(txns))
+	for i := range txns {
+		for _, in := range txns[i].In {
+			if j, ok := creators[in]; ok {
+				deps[i]++
+				children[j] = append(children[j], i)
+			}
+		}
+	}
+
+	order := make([]int, 0, len(txns))
+	done := make([]bool, len(txns))
+	for len(order) < len(txns) {
+		n := len(order)
+		for i := range txns {
+			if done[i] || deps[i] > 0 {
+				continue
+			}
+
+			done[i] = true
+			order = append(order, i)
+			for _, c := range children[i] {
+				deps[c]--
+			}
+		}
+
+		if len(order) == n {
+			return nil, ErrTxnBatchCycle
+		}
+	}
+	return order, nil
+}
+
+// InjectTxns adds the chained txns to the unconfirmed pool atomically, all the txns are
+// added in the dependency order or none is. The inputs of each txn must be in the
+// unspent pool or be created by other txns of the batch. The txns spending the outputs
+// of other txns are added as not yet valid, they turn to valid when the outputs are
+// confirmed. The txns newly added are evicted as a package. Returns the txns in the order
+// they are added, or ErrTxnEvicted if the package is evicted at once.
+func (vs *Visor) InjectTxns(txns coin.Transactions) (coin.Transactions, error) {
+	if vs.Config.ReadOnly {
+		return nil, ErrReadOnly
+	}
+
+	if len(txns) == 0 {
+		return nil, errors.New("No transactions in the batch")
+	}
+
+	if len(txns) > MaxTxnBatchSize {
+		return nil, fmt.Errorf("at most %d transactions can be injected in a batch", MaxTxnBatchSize)
+	}
+
+	head := vs.Blockchain.Head().Head
+	order, err := orderTxnBatch(txns, head)
+	if err != nil {
+		return nil, err
+	}
+
+	// the outputs created by the verified txns of the batch
+	created := make(map[cipher.SHA256]coin.UxOut)
+	spent := make(map[cipher.SHA256]struct{})
+	uxIns := make([]coin.UxArray, len(txns))
+	for _, i := range order {
+		uxIn, err := vs.verifyBatchTxn(txns[i], head, created, spent)
+		if err != nil {
+			return nil, TxnBatchError{Index: i, TxID: txns[i].Hash(), Err: err}
+		}
+		uxIns[i] = uxIn
+
+		for _, ux := range coin.CreateUnspents(head, txns[i]) {
+			created[ux.Hash()] = ux
+		}
+	}
+
+	ordered := make(coin.Transactions, 0, len(txns))
+	var added []int
+	for _, i := range order {
+		known, err := vs.Unconfirmed.InjectTxn(vs.Blockchain, txns[i])
+		if err != nil {
+			// the txns are verified, it should not happen
+			logger.Error("Inject txn %v of the batch failed: %v", txns[i].Hash().Hex(), err)
+			for _, j := range added {
+				vs.Unconfirmed.removeTxn(vs.Blockchain, txns[j].Hash())
+			}
+			return nil, TxnBatchError{Index: i, TxID: txns[i].Hash(), Err: err}
+		}
+
+		if !known {
+			added = append(added, i)
+		}
+		ordered = append(ordered, txns[i])
+	}
+
+	// the txns are evicted as a unit, so that the txns spending the outputs of the others
+	// aren't evicted first for the fees that can't be calculated yet
+	hashes := make([]cipher.SHA256, len(added))
+	for k, i := range added {
+		hashes[k] = txns[i].Hash()
+	}
+	if err := vs.Unconfirmed.AddPackage(vs.Blockchain, hashes); err != nil {
+		logger.Error("Record the package of the batch failed: %v", err)
+	}
+
+	for _, i := range added {
+		vs.addedUnconfirmed(txns[i], uxIns[i])
+	}
+
+	// the pool is only evicted when it's over the limits, the expired txns are evicted
+	// by RefreshUnconfirmed
+	if len(added) > 0 && vs.Unconfirmed.OverLimits(vs.Blockchain) {
+		if _, ok := vs.evictUnconfirmed()[hashes[0]]; ok {
+			return nil, ErrTxnEvicted
+		}
+	}
+	return ordered, nil
+}
+
+// verifyBatchTxn verifies the txn the same way as Blockchain.VerifyTransaction and
+// VerifyTransactionFee, except the inputs can be the outputs created by other txns of
+// the batch. spent is the inputs of the verified txns, the inputs of txn are added to it.
+// Returns the outputs spent by the txn.
+func (vs *Visor) verifyBatchTxn(txn coin.Transaction, head coin.BlockHeader, created map[cipher.SHA256]coin.UxOut, spent map[cipher.SHA256]struct{}) (coin.UxArray, error) {
+	if err := txn.Verify(); err != nil {
+		return nil, err
+	}
+
+	uxIn := make(coin.UxArray, len(txn.In))
+	for i, in := range txn.In {
+		if _, ok := spent[in]; ok {
+			return nil, fmt.Errorf("output %s is spent by another transaction of the batch", in.Hex())
+		}
+
+		if ux, ok := created[in]; ok {
+			uxIn[i] = ux
+			continue
+		}
+
+		ux, ok := vs.Blockchain.Unspent().Get(in)
+		if !ok {
+			return nil, fmt.Errorf("output %s is not in the unspent pool or created by the batch", in.Hex())
+		}
+		uxIn[i] = ux
+	}
+
+	if err := txn.VerifyInput(uxIn); err != nil {
+		return nil, err
+	}
+
+	uxOut := coin.CreateUnspents(head, txn)
+	if uxOut.HasDupes() {
+		return nil, errors.New("Duplicate unspent outputs in transaction")
+	}
+
+	if err := coin.VerifyTransactionSpending(head.Time, uxIn, uxOut); err != nil {
+		return nil, err
+	}
+
+	var inHours uint64
+	for _, ux := range uxIn {
+		inHours += ux.CoinHours(head.Time)
+	}
+	outHours := txn.OutputHours()
+	if inHours < outHours {
+		return nil, errors.New("Insufficient coinhours for transaction outputs")
+	}
+
+	if err := verifyBurnFee(inHours-outHours, outHours); err != nil {
+		return nil, err
+	}
+
+	for _, in := range txn.In {
+		spent[in] = struct{}{}
+	}
+	return uxIn, nil
+}
@@ -0,0 +1,76 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestTxnBatch(t *testing.T) {
+	pub, sec := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pub)
+	head := coin.BlockHeader{BkSeq: 10, Time: 1000}
+
+	// the output given by the batch, as it's in the unspent pool
+	root := coin.UxOut{
+		Head: coin.UxHead{Time: 1000, BkSeq: 10},
+		Body: coin.UxBody{SrcTransaction: randSHA256(), Address: addr, Coins: 10e6, Hours: 1000},
+	}
+
+	spend := func(ux coin.UxOut, hours uint64) coin.Transaction {
+		txn := coin.Transaction{}
+		txn.PushInput(ux.Hash())
+		txn.PushOutput(addr, ux.Body.Coins, hours)
+		txn.SignInputs([]cipher.SecKey{sec})
+		txn.UpdateHeader()
+		return txn
+	}
+
+	parent := spend(root, 500)
+	child := spend(coin.CreateUnspents(head, parent)[0], 200)
+	grandchild := spend(coin.CreateUnspents(head, child)[0], 100)
+
+	t.Run("order", func(t *testing.T) {
+		order, err := orderTxnBatch(coin.Transactions{grandchild, child, parent}, head)
+		require.NoError(t, err)
+		assert.Equal(t, []int{2, 1, 0}, order)
+
+		order, err = orderTxnBatch(coin.Transactions{parent, child, grandchild}, head)
+		require.NoError(t, err)
+		assert.Equal(t, []int{0, 1, 2}, order)
+
+		_, err = orderTxnBatch(coin.Transactions{parent, child, parent}, head)
+		assert.Error(t, err)
+		assert.Equal(t, 2, err.(TxnBatchError).Index)
+	})
+
+	t.Run("verify", func(t *testing.T) {
+		vs := &Visor{}
+		created := map[cipher.SHA256]coin.UxOut{root.Hash(): root}
+		spent := make(map[cipher.SHA256]struct{})
+
+		for _, txn := range []coin.Transaction{parent, child} {
+			uxIn, err := vs.verifyBatchTxn(txn, head, created, spent)
+			require.NoError(t, err)
+			assert.Len(t, uxIn, 1)
+			for _, ux := range coin.CreateUnspents(head, txn) {
+				created[ux.Hash()] = ux
+			}
+		}
+
+		// double spends the output of the parent
+		_, err := vs.verifyBatchTxn(spend(coin.CreateUnspents(head, parent)[0], 10), head, created, spent)
+		assert.Error(t, err)
+
+		// burns less than half of the hours
+		_, err = vs.verifyBatchTxn(spend(coin.CreateUnspents(head, child)[0], 150), head, created, spent)
+		assert.Equal(t, "Transaction coinhour fee minimum not met", err.Error())
+
+		_, err = vs.verifyBatchTxn(grandchild, head, created, spent)
+		assert.NoError(t, err)
+	})
+}
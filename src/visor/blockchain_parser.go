@@ -0,0 +1,303 @@
+package visor
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// rebuildBatchSize is the number of blocks parsed between handling the block events when
+// the indexes are rebuilt
+const rebuildBatchSize = 500
+
+// ParserOption option type which will be used when creating parser instance
+type ParserOption func(*BlockchainParser)
+
+// BlockchainParser parses the blockchain and stores the data into historydb.
+type BlockchainParser struct {
+	historyDB *historydb.HistoryDB
+	blkC      chan parserEvent
+	closing   chan chan struct{}
+	bc        *Blockchain
+	rebuildC  chan struct{}
+
+	// progress of rebuilding the indexes
+	progress     IndexProgress
+	progressLock sync.Mutex
+
+	// the history db is not written, the blocks are not parsed
+	readOnly bool
+
+	isStart bool
+}
+
+// parserEvent is a block appended to, or rolled back from the chain, the events are sent
+// in one channel to keep their order.
+type parserEvent struct {
+	block    coin.Block
+	rollback bool
+}
+
+// NewBlockchainParser create and init the parser instance.
+func NewBlockchainParser(hisDB *historydb.HistoryDB, bc *Blockchain, ops ...ParserOption) *BlockchainParser {
+	bp := &BlockchainParser{
+		bc:        bc,
+		historyDB: hisDB,
+		closing:   make(chan chan struct{}),
+		blkC:      make(chan parserEvent, 10),
+		rebuildC:  make(chan struct{}, 1),
+	}
+
+	for _, op := range ops {
+		op(bp)
+	}
+
+	return bp
+}
+
+// ReadOnlyParser option to serve the history db as it is, without parsing the blocks
+func ReadOnlyParser(enable bool) ParserOption {
+	return func(bcp *BlockchainParser) {
+		bcp.readOnly = enable
+	}
+}
+
+// BlockListener when new block appended to blockchain, this method will b invoked
+func (bcp *BlockchainParser) BlockListener(b coin.Block) {
+	bcp.blkC <- parserEvent{block: b}
+}
+
+// RollbackListener when the head block is rolled back, this method will be invoked
+func (bcp *BlockchainParser) RollbackListener(b coin.Block) {
+	bcp.blkC <- parserEvent{block: b, rollback: true}
+}
+
+// Run starts blockchain parser
+func (bcp *BlockchainParser) Run() error {
+	logger.Info("Blockchain parser start")
+	defer logger.Info("Blockchain parser closed")
+
+	if bcp.readOnly {
+		if head := bcp.bc.Head(); head != nil && bcp.historyDB.ParsedHeight() < int64(head.Seq()) {
+			logger.Warning("The history db is parsed to block %d of %d, the history apis return partial results",
+				bcp.historyDB.ParsedHeight(), head.Seq())
+		}
+
+		cc := <-bcp.closing
+		cc <- struct{}{}
+		return nil
+	}
+
+	if err := bcp.historyDB.ResetIfNeed(); err != nil {
+		return err
+	}
+
+	// parse to the blockchain head
+	headSeq := bcp.bc.Head().Seq()
+	if err := bcp.parseTo(headSeq); err != nil {
+		return err
+	}
+
+	var rebuilding bool
+	for {
+		if rebuilding {
+			// the block events are handled between the batches, the appended blocks are
+			// parsed by the rebuild
+			select {
+			case cc := <-bcp.closing:
+				cc <- struct{}{}
+				return nil
+			case ev := <-bcp.blkC:
+				if ev.rollback {
+					if err := bcp.historyDB.RollbackBlock(&ev.block); err != nil {
+						return bcp.finishRebuild(err)
+					}
+				}
+				continue
+			default:
+			}
+
+			done, err := bcp.rebuildStep(rebuildBatchSize)
+			if err != nil {
+				return bcp.finishRebuild(err)
+			}
+
+			if done {
+				bcp.finishRebuild(nil)
+				rebuilding = false
+			}
+			continue
+		}
+
+		select {
+		case cc := <-bcp.closing:
+			cc <- struct{}{}
+			return nil
+		case <-bcp.rebuildC:
+			if err := bcp.resetIndexes(); err != nil {
+				return bcp.finishRebuild(err)
+			}
+			rebuilding = true
+		case ev := <-bcp.blkC:
+			if ev.rollback {
+				if err := bcp.historyDB.RollbackBlock(&ev.block); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := bcp.parseTo(ev.block.Head.BkSeq); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// StartRebuild requests the running parser to rebuild the indexes, returns false if
+// they're being rebuilt
+func (bcp *BlockchainParser) StartRebuild() bool {
+	bcp.progressLock.Lock()
+	defer bcp.progressLock.Unlock()
+	if bcp.progress.Running {
+		return false
+	}
+
+	bcp.progress = IndexProgress{
+		Running:   true,
+		ParsedSeq: -1,
+		StartedAt: time.Now().Unix(),
+	}
+	bcp.rebuildC <- struct{}{}
+	return true
+}
+
+// Rebuild rebuilds the indexes in the calling goroutine, the parser must not be running.
+// progress is called after each batch of blocks is parsed.
+func (bcp *BlockchainParser) Rebuild(progress func(IndexProgress)) error {
+	bcp.progressLock.Lock()
+	bcp.progress = IndexProgress{
+		Running:   true,
+		ParsedSeq: -1,
+		StartedAt: time.Now().Unix(),
+	}
+	bcp.progressLock.Unlock()
+
+	if err := bcp.resetIndexes(); err != nil {
+		return bcp.finishRebuild(err)
+	}
+
+	for {
+		done, err := bcp.rebuildStep(rebuildBatchSize)
+		if err != nil {
+			return bcp.finishRebuild(err)
+		}
+
+		if progress != nil {
+			progress(bcp.Progress())
+		}
+
+		if done {
+			return bcp.finishRebuild(nil)
+		}
+	}
+}
+
+// Progress returns the progress of rebuilding the indexes
+func (bcp *BlockchainParser) Progress() IndexProgress {
+	bcp.progressLock.Lock()
+	defer bcp.progressLock.Unlock()
+	return bcp.progress
+}
+
+// resetIndexes drops the history db and rebuilds the address index of the unspent outputs,
+// the history db is rebuilt by rebuildStep
+func (bcp *BlockchainParser) resetIndexes() error {
+	logger.Info("Rebuilding the indexes")
+	if err := bcp.bc.Unspent().RebuildAddrIndex(); err != nil {
+		return err
+	}
+	return bcp.historyDB.Reset()
+}
+
+// rebuildStep parses the next n blocks, returns true if the head is parsed
+func (bcp *BlockchainParser) rebuildStep(n uint64) (bool, error) {
+	head := bcp.bc.Head()
+	if head == nil {
+		return true, nil
+	}
+
+	target := uint64(bcp.historyDB.ParsedHeight()+1) + n - 1
+	if target > head.Seq() {
+		target = head.Seq()
+	}
+
+	if err := bcp.parseTo(target); err != nil {
+		return false, err
+	}
+
+	parsed := bcp.historyDB.ParsedHeight()
+	bcp.progressLock.Lock()
+	bcp.progress.ParsedSeq = parsed
+	bcp.progress.HeadSeq = head.Seq()
+	bcp.progressLock.Unlock()
+
+	logger.Info("Rebuilding the indexes, parsed %d/%d blocks", parsed, head.Seq())
+	return parsed >= int64(head.Seq()), nil
+}
+
+// finishRebuild records the end of the rebuild, returns err
+func (bcp *BlockchainParser) finishRebuild(err error) error {
+	bcp.progressLock.Lock()
+	defer bcp.progressLock.Unlock()
+	bcp.progress.Running = false
+	bcp.progress.FinishedAt = time.Now().Unix()
+	if err != nil {
+		logger.Error("Rebuild the indexes failed: %v", err)
+		bcp.progress.Error = err.Error()
+	} else {
+		logger.Info("Rebuilt the indexes")
+	}
+	return err
+}
+
+// Stop close the block parsing process.
+func (bcp *BlockchainParser) Stop() {
+	cc := make(chan struct{}, 1)
+	bcp.closing <- cc
+	<-cc
+}
+
+func (bcp *BlockchainParser) parseTo(bcHeight uint64) error {
+	// the chain may be rolled back after the block was appended
+	if head := bcp.bc.Head(); head != nil && head.Seq() < bcHeight {
+		bcHeight = head.Seq()
+	}
+
+	parsedHeight := bcp.historyDB.ParsedHeight()
+
+	for i := int64(0); i < int64(bcHeight)-parsedHeight; i++ {
+		b := bcp.bc.GetBlockInDepth(uint64(parsedHeight + i + 1))
+		if b == nil {
+			return fmt.Errorf("no block exist in depth:%d", parsedHeight+i+1)
+		}
+
+		// the chain is reorganized, the block is parsed after the rollback events of the
+		// old branch are handled
+		if !bcp.historyDB.Follows(b) {
+			return nil
+		}
+
+		if blockPruned(b) {
+			return fmt.Errorf("block in depth:%d is pruned, the history db can't be rebuilt", b.Seq())
+		}
+
+		if err := bcp.historyDB.ProcessBlock(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -248,3 +248,41 @@ func TestGetBlockInDepth(t *testing.T) {
 
 	assert.Equal(t, *block, blocks[2])
 }
+
+func TestPruneBlocks(t *testing.T) {
+	db, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	bt, err := NewBlockTree(db)
+	assert.Nil(t, err)
+
+	txn := coin.Transaction{}
+	txn.PushOutput(cipher.Address{}, 1e6, 10)
+	body := coin.BlockBody{Transactions: coin.Transactions{txn}}
+	gb := coin.Block{
+		Head: coin.BlockHeader{BkSeq: 0, BodyHash: body.Hash()},
+		Body: body,
+	}
+	b := coin.Block{
+		Head: coin.BlockHeader{BkSeq: 1, BodyHash: body.Hash(), PrevHash: gb.HashHeader()},
+		Body: body,
+	}
+	assert.Nil(t, bt.AddBlock(&gb))
+	assert.Nil(t, bt.AddBlock(&b))
+
+	// the unknown hash is skipped
+	assert.Nil(t, bt.PruneBlocks([]cipher.SHA256{b.HashHeader(), randSHA256(t)}))
+
+	pruned := bt.GetBlock(b.HashHeader())
+	assert.NotNil(t, pruned)
+	assert.Equal(t, b.Head, pruned.Head)
+	assert.Empty(t, pruned.Body.Transactions)
+	assert.Equal(t, gb, *bt.GetBlock(gb.HashHeader()))
+
+	// pruning again changes nothing
+	assert.Nil(t, bt.PruneBlocks([]cipher.SHA256{b.HashHeader()}))
+	assert.Equal(t, pruned, bt.GetBlock(b.HashHeader()))
+}
@@ -1,6 +1,7 @@
 package blockdb
 
 import (
+	"errors"
 	"sync"
 
 	"github.com/boltdb/bolt"
@@ -11,6 +12,10 @@ import (
 var (
 	// blockchain head sequence number
 	headSeqKey = []byte("head_seq")
+	// the seq of the last block whose signature and all the previous ones are verified
+	verifiedSigSeqKey = []byte("verified_sig_seq")
+	// the bodies of the blocks from seq 1 to the pruned seq are discarded
+	prunedSeqKey = []byte("pruned_seq")
 )
 
 type chainMeta struct {
@@ -25,6 +30,18 @@ func (m chainMeta) getHeadSeq() uint64 {
 	return bucket.Btoi(m.Get(headSeqKey))
 }
 
+func (m chainMeta) getVerifiedSigSeq() (uint64, bool) {
+	v := m.Get(verifiedSigSeqKey)
+	if v == nil {
+		return 0, false
+	}
+	return bucket.Btoi(v), true
+}
+
+func (m chainMeta) setVerifiedSigSeq(seq uint64) error {
+	return m.Put(verifiedSigSeqKey, bucket.Itob(seq))
+}
+
 // Blockchain maintain the buckets for blockchain
 type Blockchain struct {
 	db      *bolt.DB
@@ -32,8 +49,7 @@ type Blockchain struct {
 	Unspent *UnspentPool
 
 	cache struct {
-		headSeq        int64  // head block seq
-		verifiedSigSeq uint64 // verified block seq
+		headSeq int64 // head block seq
 	}
 	sync.Mutex // cache lock
 }
@@ -79,14 +95,32 @@ func (bc *Blockchain) getHeadSeqFromDB() int64 {
 // ProcessBlock processes block
 func (bc *Blockchain) ProcessBlock(b *coin.Block) error {
 	if err := bc.dbUpdate(
-		bc.updateHeadSeq(b),
-		bc.Unspent.processBlock(b)); err != nil {
+		bc.updateHeadSeq(b.Seq()),
+		bc.Unspent.processBlock(b),
+		bc.endJournalOp(JournalApply)); err != nil {
 		return err
 	}
 
 	return nil
 }
 
+// RollbackBlock reverts the head block b, spent are the outputs spent by the block,
+// which will be added back to the unspent pool.
+func (bc *Blockchain) RollbackBlock(b *coin.Block, spent coin.UxArray) error {
+	if b.Seq() == 0 {
+		return errors.New("can't roll back the genesis block")
+	}
+
+	if int64(b.Seq()) != bc.HeadSeq() {
+		return errors.New("only the head block can be rolled back")
+	}
+
+	return bc.dbUpdate(
+		bc.updateHeadSeq(b.Seq()-1),
+		bc.unverifySig(b.Seq()),
+		bc.Unspent.rollbackBlock(b, spent))
+}
+
 // dbUpdate will execute all processors in sequence, return error will rollback all
 // updates to the db
 func (bc *Blockchain) dbUpdate(ps ...bucket.TxHandler) error {
@@ -108,7 +142,7 @@ func (bc *Blockchain) dbUpdate(ps ...bucket.TxHandler) error {
 	})
 }
 
-func (bc *Blockchain) updateHeadSeq(b *coin.Block) bucket.TxHandler {
+func (bc *Blockchain) updateHeadSeq(headSeq uint64) bucket.TxHandler {
 	return func(tx *bolt.Tx) (bucket.Rollback, error) {
 		meta := chainMeta{tx.Bucket(bc.meta.Name)}
 
@@ -117,7 +151,7 @@ func (bc *Blockchain) updateHeadSeq(b *coin.Block) bucket.TxHandler {
 		seq := bc.cache.headSeq
 
 		// update the cache head seq
-		bc.cache.headSeq = int64(b.Seq())
+		bc.cache.headSeq = int64(headSeq)
 		bc.Unlock()
 
 		return func() {
@@ -125,8 +159,77 @@ func (bc *Blockchain) updateHeadSeq(b *coin.Block) bucket.TxHandler {
 			bc.Lock()
 			bc.cache.headSeq = int64(seq)
 			bc.Unlock()
-		}, meta.setHeadSeq(b.Seq())
+		}, meta.setHeadSeq(headSeq)
+	}
+}
+
+// unverifySig lowers the verified signature seq below the rolled back block seq
+func (bc *Blockchain) unverifySig(seq uint64) bucket.TxHandler {
+	return func(tx *bolt.Tx) (bucket.Rollback, error) {
+		meta := chainMeta{tx.Bucket(bc.meta.Name)}
+		if v, ok := meta.getVerifiedSigSeq(); !ok || v < seq {
+			return func() {}, nil
+		}
+		return func() {}, meta.setVerifiedSigSeq(seq - 1)
+	}
+}
+
+// VerifiedSigSeq returns the seq of the last block whose signature and all the previous
+// ones are verified, returns false if none is verified.
+func (bc *Blockchain) VerifiedSigSeq() (uint64, bool) {
+	var (
+		seq uint64
+		ok  bool
+	)
+	bc.db.View(func(tx *bolt.Tx) error {
+		seq, ok = chainMeta{tx.Bucket(bc.meta.Name)}.getVerifiedSigSeq()
+		return nil
+	})
+	return seq, ok
+}
+
+// SetVerifiedSigSeq records that the signatures of the blocks till seq are verified, the
+// signatures are not verified again when the node restarts.
+func (bc *Blockchain) SetVerifiedSigSeq(seq uint64) error {
+	return bc.db.Update(func(tx *bolt.Tx) error {
+		meta := chainMeta{tx.Bucket(bc.meta.Name)}
+		if v, ok := meta.getVerifiedSigSeq(); ok && v >= seq {
+			return nil
+		}
+		return meta.setVerifiedSigSeq(seq)
+	})
+}
+
+// AdvanceVerifiedSigSeq records that the signature of the block seq is verified, it's
+// ignored unless all the previous signatures are verified.
+func (bc *Blockchain) AdvanceVerifiedSigSeq(seq uint64) error {
+	return bc.db.Update(func(tx *bolt.Tx) error {
+		meta := chainMeta{tx.Bucket(bc.meta.Name)}
+		if v, ok := meta.getVerifiedSigSeq(); !ok || v+1 != seq {
+			return nil
+		}
+		return meta.setVerifiedSigSeq(seq)
+	})
+}
+
+// PrunedSeq returns the seq of the last block whose body is discarded, the bodies of the
+// blocks from seq 1 to it are discarded, returns 0 if no block is pruned.
+func (bc *Blockchain) PrunedSeq() uint64 {
+	if v := bc.meta.Get(prunedSeqKey); v != nil {
+		return bucket.Btoi(v)
 	}
+	return 0
+}
+
+// SetPrunedSeq records that the bodies of the blocks till seq are discarded
+func (bc *Blockchain) SetPrunedSeq(seq uint64) error {
+	return bc.db.Update(func(tx *bolt.Tx) error {
+		meta := chainMeta{tx.Bucket(bc.meta.Name)}
+		if v := meta.Get(prunedSeqKey); v != nil && bucket.Btoi(v) >= seq {
+			return nil
+		}
+		return meta.Put(prunedSeqKey, bucket.Itob(seq))
+	})
 }
 
 // HeadSeq returns the head block sequence
@@ -35,3 +35,65 @@ func TestNewBlockchain(t *testing.T) {
 		return nil
 	})
 }
+
+func TestVerifiedSigSeq(t *testing.T) {
+	db, td, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer td()
+
+	bc, err := NewBlockchain(db)
+	assert.Nil(t, err)
+
+	_, ok := bc.VerifiedSigSeq()
+	assert.False(t, ok)
+
+	// none is verified, the block can't be advanced to
+	assert.Nil(t, bc.AdvanceVerifiedSigSeq(1))
+	_, ok = bc.VerifiedSigSeq()
+	assert.False(t, ok)
+
+	assert.Nil(t, bc.SetVerifiedSigSeq(0))
+	assert.Nil(t, bc.AdvanceVerifiedSigSeq(1))
+	assert.Nil(t, bc.AdvanceVerifiedSigSeq(3))
+	seq, ok := bc.VerifiedSigSeq()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), seq)
+
+	// the seq never goes backwards
+	assert.Nil(t, bc.SetVerifiedSigSeq(0))
+	seq, _ = bc.VerifiedSigSeq()
+	assert.Equal(t, uint64(1), seq)
+
+	for i := uint64(0); i < 2; i++ {
+		assert.Nil(t, bc.ProcessBlock(&coin.Block{Head: coin.BlockHeader{BkSeq: i}}))
+	}
+
+	// rolls back the verified block
+	assert.Nil(t, bc.RollbackBlock(&coin.Block{Head: coin.BlockHeader{BkSeq: 1}}, nil))
+	seq, ok = bc.VerifiedSigSeq()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0), seq)
+}
+
+func TestPrunedSeq(t *testing.T) {
+	db, td, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer td()
+
+	bc, err := NewBlockchain(db)
+	assert.Nil(t, err)
+
+	assert.Equal(t, uint64(0), bc.PrunedSeq())
+	assert.Nil(t, bc.SetPrunedSeq(10))
+	assert.Equal(t, uint64(10), bc.PrunedSeq())
+
+	// the seq never goes backwards
+	assert.Nil(t, bc.SetPrunedSeq(5))
+	assert.Equal(t, uint64(10), bc.PrunedSeq())
+}
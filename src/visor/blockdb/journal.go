@@ -0,0 +1,80 @@
+package blockdb
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+)
+
+// the block being applied or rolled back, it's cleared when the change is done
+var journalKey = []byte("journal")
+
+// JournalOp is the change to the chain recorded in the journal
+type JournalOp uint8
+
+const (
+	// JournalApply is appending the block to the chain, the block is added to the block
+	// tree then the unspent pool and head are updated
+	JournalApply JournalOp = iota + 1
+	// JournalRollback is removing the head block, the unspent pool and head are reverted
+	// then the block is removed from the block tree
+	JournalRollback
+)
+
+// JournalEntry is the block being applied or rolled back
+type JournalEntry struct {
+	Op   JournalOp
+	Seq  uint64
+	Hash cipher.SHA256
+}
+
+// BeginJournal records that b is being applied or rolled back. The journal is cleared
+// in the same db transaction that updates the unspent pool of an applied block, or by
+// EndJournal after a rolled back block is removed from the block tree. If the node
+// stops in between, the entry is left for the recovery on restart.
+func (bc *Blockchain) BeginJournal(op JournalOp, b *coin.Block) error {
+	e := JournalEntry{Op: op, Seq: b.Seq(), Hash: b.HashHeader()}
+	return bc.meta.Put(journalKey, encoder.Serialize(e))
+}
+
+// EndJournal clears the journal
+func (bc *Blockchain) EndJournal() error {
+	return bc.meta.Delete(journalKey)
+}
+
+// Journal returns the entry of the change that's not done, returns false if there's none
+func (bc *Blockchain) Journal() (JournalEntry, bool, error) {
+	v := bc.meta.Get(journalKey)
+	if v == nil {
+		return JournalEntry{}, false, nil
+	}
+
+	var e JournalEntry
+	if err := encoder.DeserializeRaw(v, &e); err != nil {
+		return JournalEntry{}, false, err
+	}
+	return e, true, nil
+}
+
+// endJournalOp clears the journal of op in the db transaction
+func (bc *Blockchain) endJournalOp(op JournalOp) bucket.TxHandler {
+	return func(tx *bolt.Tx) (bucket.Rollback, error) {
+		meta := chainMeta{tx.Bucket(bc.meta.Name)}
+		v := meta.Get(journalKey)
+		if v == nil {
+			return func() {}, nil
+		}
+
+		var e JournalEntry
+		if err := encoder.DeserializeRaw(v, &e); err != nil {
+			return func() {}, err
+		}
+
+		if e.Op != op {
+			return func() {}, nil
+		}
+		return func() {}, meta.Delete(journalKey)
+	}
+}
@@ -1,27 +1,44 @@
 package blockdb
 
 import (
+	"bytes"
 	"fmt"
+	"math/big"
 	"sync"
 
 	"github.com/boltdb/bolt"
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/cipher/encoder"
 	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/logging"
 	"github.com/skycoin/skycoin/src/visor/bucket"
 )
 
 var (
-	xorhashKey = []byte("xorhash")
+	logger = logging.MustGetLogger("blockdb")
+
+	xorhashKey  = []byte("xorhash")
+	coinsKey    = []byte("coins")
+	hoursKey    = []byte("hours")
+	coinTimeKey = []byte("coin_time")
+
+	unspentPoolBktName = []byte("unspent_pool")
+	unspentMetaBktName = []byte("unspent_meta")
+	unspentAddrBktName = []byte("unspent_addr_index")
+	// the outputs spent by each block, keyed by the block hash, for rolling the block back
+	blockSpentBktName = []byte("block_spent_outputs")
 )
 
-// UnspentPool unspent outputs pool
+// UnspentPool unspent outputs pool, the outputs are read from db on demand,
+// only the xor hash is cached.
 type UnspentPool struct {
 	db    *bolt.DB
 	pool  *bucket.Bucket
 	meta  *bucket.Bucket
-	cache struct {
-		pool   map[string]coin.UxOut
+	addrs *bucket.Bucket
+	// the outputs spent by the blocks
+	spents *bucket.Bucket
+	cache  struct {
 		uxhash cipher.SHA256
 	}
 	sync.Mutex
@@ -45,6 +62,76 @@ func (m *unspentMeta) setXorHash(hash cipher.SHA256) error {
 	return m.Put(xorhashKey, hash[:])
 }
 
+// unspentTotals are the running totals of the unspent outputs, so that the coin supply
+// and the coin hours can be read without loading the whole pool.
+type unspentTotals struct {
+	coins uint64
+	// the initial hours of the outputs
+	hours uint64
+	// the sum of the coins times the creation time of the outputs
+	coinTime *big.Int
+}
+
+func (ut *unspentTotals) add(ux coin.UxOut) {
+	ut.coins += ux.Body.Coins
+	ut.hours += ux.Body.Hours
+	ut.coinTime.Add(ut.coinTime, coinTime(ux))
+}
+
+func (ut *unspentTotals) sub(ux coin.UxOut) {
+	ut.coins -= ux.Body.Coins
+	ut.hours -= ux.Body.Hours
+	ut.coinTime.Sub(ut.coinTime, coinTime(ux))
+}
+
+// hoursAt returns the coin hours of the outputs at time t. The hours earned by the outputs
+// are summed before rounding down, so it can be larger than the sum of UxOut.CoinHours that
+// the balances use, by less than 1 hour per output.
+func (ut unspentTotals) hoursAt(t uint64) uint64 {
+	earned := new(big.Int).SetUint64(ut.coins)
+	earned.Mul(earned, new(big.Int).SetUint64(t))
+	earned.Sub(earned, ut.coinTime)
+	if earned.Sign() <= 0 {
+		return ut.hours
+	}
+
+	earned.Div(earned, big.NewInt(1e6*3600))
+	return ut.hours + earned.Uint64()
+}
+
+func coinTime(ux coin.UxOut) *big.Int {
+	ct := new(big.Int).SetUint64(ux.Body.Coins)
+	return ct.Mul(ct, new(big.Int).SetUint64(ux.Head.Time))
+}
+
+func (m unspentMeta) getTotals() unspentTotals {
+	ut := unspentTotals{coinTime: new(big.Int)}
+	if v := m.Get(coinsKey); v != nil {
+		ut.coins = bucket.Btoi(v)
+	}
+	if v := m.Get(hoursKey); v != nil {
+		ut.hours = bucket.Btoi(v)
+	}
+	if v := m.Get(coinTimeKey); v != nil {
+		ut.coinTime.SetBytes(v)
+	}
+	return ut
+}
+
+func (m *unspentMeta) setTotals(ut unspentTotals) error {
+	if ut.coinTime.Sign() < 0 {
+		return fmt.Errorf("negative coin time %v of the unspent outputs", ut.coinTime)
+	}
+
+	if err := m.Put(coinsKey, bucket.Itob(ut.coins)); err != nil {
+		return err
+	}
+	if err := m.Put(hoursKey, bucket.Itob(ut.hours)); err != nil {
+		return err
+	}
+	return m.Put(coinTimeKey, ut.coinTime.Bytes())
+}
+
 type uxOuts struct {
 	*bolt.Bucket
 }
@@ -69,23 +156,83 @@ func (uo *uxOuts) delete(hash cipher.SHA256) error {
 	return uo.Delete(hash[:])
 }
 
+// getArray returns the outputs of hashes, returns error if any of them doesn't exist
+func (uo uxOuts) getArray(hashes []cipher.SHA256) (coin.UxArray, error) {
+	uxs := make(coin.UxArray, 0, len(hashes))
+	for _, h := range hashes {
+		ux, ok, err := uo.get(h)
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, fmt.Errorf("unspent output of %s does not exist", h.Hex())
+		}
+
+		uxs = append(uxs, *ux)
+	}
+	return uxs, nil
+}
+
+// addrIndex indexes the unspent outputs by address, the key is the address bytes
+// followed by the output hash, the value is empty.
+type addrIndex struct {
+	*bolt.Bucket
+}
+
+func addrIndexKey(addr cipher.Address, hash cipher.SHA256) []byte {
+	return append(addr.Bytes(), hash[:]...)
+}
+
+func (ai addrIndex) add(ux coin.UxOut) error {
+	return ai.Put(addrIndexKey(ux.Body.Address, ux.Hash()), []byte{})
+}
+
+func (ai addrIndex) delete(ux coin.UxOut) error {
+	return ai.Delete(addrIndexKey(ux.Body.Address, ux.Hash()))
+}
+
+// hashes returns the hashes of the unspent outputs of the address
+func (ai addrIndex) hashes(addr cipher.Address) []cipher.SHA256 {
+	prefix := addr.Bytes()
+	var hashes []cipher.SHA256
+	c := ai.Cursor()
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		var h cipher.SHA256
+		copy(h[:], k[len(prefix):])
+		hashes = append(hashes, h)
+	}
+	return hashes
+}
+
 // NewUnspentPool creates new unspent pool instance
 func NewUnspentPool(db *bolt.DB) (*UnspentPool, error) {
 	up := &UnspentPool{db: db}
-	up.cache.pool = make(map[string]coin.UxOut)
 
-	pool, err := bucket.New([]byte("unspent_pool"), db)
+	pool, err := bucket.New(unspentPoolBktName, db)
 	if err != nil {
 		return nil, err
 	}
 	up.pool = pool
 
-	meta, err := bucket.New([]byte("unspent_meta"), db)
+	meta, err := bucket.New(unspentMetaBktName, db)
 	if err != nil {
 		return nil, err
 	}
 	up.meta = meta
 
+	addrs, err := bucket.New(unspentAddrBktName, db)
+	if err != nil {
+		return nil, err
+	}
+	up.addrs = addrs
+
+	spents, err := bucket.New(blockSpentBktName, db)
+	if err != nil {
+		return nil, err
+	}
+	up.spents = spents
+
 	// load from db
 	if err := up.syncCache(); err != nil {
 		return nil, err
@@ -95,49 +242,104 @@ func NewUnspentPool(db *bolt.DB) (*UnspentPool, error) {
 }
 
 func (up *UnspentPool) syncCache() error {
-	// load unspent outputs
-	if err := up.pool.ForEach(func(k, v []byte) error {
-		var hash cipher.SHA256
-		copy(hash[:], k[:])
+	// load uxhash
+	uxhash, err := up.getUxHashFromDB()
+	if err != nil {
+		return err
+	}
+
+	up.cache.uxhash = uxhash
+	return nil
+}
 
+// IndexUnspentsByAddr builds the address index of the unspent outputs, it migrates the
+// db created before the outputs were indexed by address.
+func IndexUnspentsByAddr(tx *bolt.Tx) error {
+	pool := tx.Bucket(unspentPoolBktName)
+	if pool == nil {
+		return nil
+	}
+
+	addrs, err := tx.CreateBucketIfNotExists(unspentAddrBktName)
+	if err != nil {
+		return err
+	}
+
+	ai := addrIndex{addrs}
+	return pool.ForEach(func(k, v []byte) error {
 		var ux coin.UxOut
 		if err := encoder.DeserializeRaw(v, &ux); err != nil {
 			return fmt.Errorf("load unspent outputs from db failed: %v", err)
 		}
+		return ai.add(ux)
+	})
+}
 
-		up.cache.pool[hash.Hex()] = ux
+// TotalUnspents computes the running totals of the coins and hours of the unspent outputs,
+// it migrates the db created before the totals were kept.
+func TotalUnspents(tx *bolt.Tx) error {
+	pool := tx.Bucket(unspentPoolBktName)
+	if pool == nil {
 		return nil
-	}); err != nil {
-		return err
 	}
 
-	// load uxhash
-	uxhash, err := up.getUxHashFromDB()
+	metaBkt, err := tx.CreateBucketIfNotExists(unspentMetaBktName)
 	if err != nil {
 		return err
 	}
 
-	up.cache.uxhash = uxhash
-	return nil
+	ut := unspentTotals{coinTime: new(big.Int)}
+	if err := pool.ForEach(func(k, v []byte) error {
+		var ux coin.UxOut
+		if err := encoder.DeserializeRaw(v, &ux); err != nil {
+			return fmt.Errorf("load unspent outputs from db failed: %v", err)
+		}
+		ut.add(ux)
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	meta := unspentMeta{metaBkt}
+	return meta.setTotals(ut)
+}
+
+// RebuildAddrIndex drops the address index of the unspent outputs and builds it again
+// from the unspent pool
+func (up *UnspentPool) RebuildAddrIndex() error {
+	return up.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(unspentAddrBktName) != nil {
+			if err := tx.DeleteBucket(unspentAddrBktName); err != nil {
+				return err
+			}
+		}
+		return IndexUnspentsByAddr(tx)
+	})
 }
 
 func (up *UnspentPool) processBlock(b *coin.Block) bucket.TxHandler {
 	return func(tx *bolt.Tx) (bucket.Rollback, error) {
 		var (
-			delUxs    []coin.UxOut
-			addUxs    []coin.UxOut
 			uxHash    cipher.SHA256
 			oldUxHash = up.cache.uxhash
+			spent     = coin.UxArray{}
+			err       error
 		)
 
 		for _, txn := range b.Body.Transactions {
-			// get uxouts need to be deleted
-			uxs, err := up.getArray(txn.In)
-			if err != nil {
+			// the spent outputs must exist
+			var uxs coin.UxArray
+			if uxs, err = (uxOuts{tx.Bucket(up.pool.Name)}).getArray(txn.In); err != nil {
 				return func() {}, err
 			}
 
-			delUxs = append(delUxs, uxs...)
+			// the outputs created by the previous txns of the block are not restored
+			// when the block is rolled back
+			for _, ux := range uxs {
+				if ux.Head.BkSeq != b.Seq() {
+					spent = append(spent, ux)
+				}
+			}
 
 			// Remove spent outputs
 			if _, err = up.deleteWithTx(tx, txn.In); err != nil {
@@ -146,7 +348,6 @@ func (up *UnspentPool) processBlock(b *coin.Block) bucket.TxHandler {
 
 			// Create new outputs
 			txUxs := coin.CreateUnspents(b.Head, txn)
-			addUxs = append(addUxs, txUxs...)
 			for i := range txUxs {
 				uxHash, err = up.addWithTx(tx, txUxs[i])
 				if err != nil {
@@ -155,18 +356,62 @@ func (up *UnspentPool) processBlock(b *coin.Block) bucket.TxHandler {
 			}
 		}
 
+		hash := b.HashHeader()
+		if err := tx.Bucket(up.spents.Name).Put(hash[:], encoder.Serialize(spent)); err != nil {
+			return func() {}, err
+		}
+
 		// update caches
 		up.Lock()
-		up.deleteUxFromCache(delUxs)
-		up.addUxToCache(addUxs)
 		up.updateUxHashInCache(uxHash)
 		up.Unlock()
 
 		return func() {
 			up.Lock()
 			// reverse the cache
-			up.deleteUxFromCache(addUxs)
-			up.addUxToCache(delUxs)
+			up.updateUxHashInCache(oldUxHash)
+			up.Unlock()
+		}, nil
+	}
+}
+
+// rollbackBlock reverts the changes of processBlock, the outputs created by the block are
+// removed, and the spent outputs are added back.
+func (up *UnspentPool) rollbackBlock(b *coin.Block, spent coin.UxArray) bucket.TxHandler {
+	return func(tx *bolt.Tx) (bucket.Rollback, error) {
+		oldUxHash := up.cache.uxhash
+
+		for _, txn := range b.Body.Transactions {
+			txUxs := coin.CreateUnspents(b.Head, txn)
+			if _, err := up.deleteWithTx(tx, txUxs.Hashes()); err != nil {
+				return func() {}, err
+			}
+		}
+
+		for i := range spent {
+			if _, err := up.addWithTx(tx, spent[i]); err != nil {
+				return func() {}, err
+			}
+		}
+
+		hash := b.HashHeader()
+		if err := tx.Bucket(up.spents.Name).Delete(hash[:]); err != nil {
+			return func() {}, err
+		}
+
+		// the xor hash is read back from db, as deleteWithTx returns empty
+		// hash if none of the outputs exist.
+		uxHash, err := unspentMeta{tx.Bucket(up.meta.Name)}.getXorHash()
+		if err != nil {
+			return func() {}, err
+		}
+
+		up.Lock()
+		up.updateUxHashInCache(uxHash)
+		up.Unlock()
+
+		return func() {
+			up.Lock()
 			up.updateUxHashInCache(oldUxHash)
 			up.Unlock()
 		}, nil
@@ -184,7 +429,8 @@ func (up *UnspentPool) addWithTx(tx *bolt.Tx, ux coin.UxOut) (uxhash cipher.SHA2
 
 	// check if the uxout does exist in the pool
 	h := ux.Hash()
-	if up.Contains(h) {
+	uxouts := uxOuts{tx.Bucket(up.pool.Name)}
+	if uxouts.Get(h[:]) != nil {
 		return cipher.SHA256{}, fmt.Errorf("attemps to insert uxout:%v twice into the unspent pool", h.Hex())
 	}
 
@@ -199,24 +445,21 @@ func (up *UnspentPool) addWithTx(tx *bolt.Tx, ux coin.UxOut) (uxhash cipher.SHA2
 		return cipher.SHA256{}, err
 	}
 
-	err = uxOuts{tx.Bucket(up.pool.Name)}.set(h, ux)
-	if err != nil {
+	totals := meta.getTotals()
+	totals.add(ux)
+	if err := meta.setTotals(totals); err != nil {
 		return cipher.SHA256{}, err
 	}
 
-	return xorhash, nil
-}
-
-func (up *UnspentPool) deleteUxFromCache(uxs []coin.UxOut) {
-	for _, ux := range uxs {
-		delete(up.cache.pool, ux.Hash().Hex())
+	if err := uxouts.set(h, ux); err != nil {
+		return cipher.SHA256{}, err
 	}
-}
 
-func (up *UnspentPool) addUxToCache(uxs []coin.UxOut) {
-	for i, ux := range uxs {
-		up.cache.pool[ux.Hash().Hex()] = uxs[i]
+	if err := (addrIndex{tx.Bucket(up.addrs.Name)}).add(ux); err != nil {
+		return cipher.SHA256{}, err
 	}
+
+	return xorhash, nil
 }
 
 func (up *UnspentPool) updateUxHashInCache(hash cipher.SHA256) {
@@ -226,41 +469,44 @@ func (up *UnspentPool) updateUxHashInCache(hash cipher.SHA256) {
 // GetArray returns UxOut by given hash array, will return error when
 // if any of the hashes is not exist.
 func (up *UnspentPool) GetArray(hashes []cipher.SHA256) (coin.UxArray, error) {
-	up.Lock()
-	defer up.Unlock()
-	return up.getArray(hashes)
-}
-
-func (up *UnspentPool) getArray(hashes []cipher.SHA256) (coin.UxArray, error) {
-	uxs := make(coin.UxArray, 0, len(hashes))
-	for i := range hashes {
-		ux, ok := up.cache.pool[hashes[i].Hex()]
-		if !ok {
-			return nil, fmt.Errorf("unspent output of %s does not exist", hashes[i].Hex())
-		}
-
-		uxs = append(uxs, ux)
-	}
-	return uxs, nil
+	var uxs coin.UxArray
+	err := up.db.View(func(tx *bolt.Tx) error {
+		var err error
+		uxs, err = uxOuts{tx.Bucket(up.pool.Name)}.getArray(hashes)
+		return err
+	})
+	return uxs, err
 }
 
 // Get returns the uxout value of give hash
 func (up *UnspentPool) Get(h cipher.SHA256) (coin.UxOut, bool) {
-	up.Lock()
-	ux, ok := up.cache.pool[h.Hex()]
-	up.Unlock()
+	var ux *coin.UxOut
+	var ok bool
+	if err := up.db.View(func(tx *bolt.Tx) error {
+		var err error
+		ux, ok, err = uxOuts{tx.Bucket(up.pool.Name)}.get(h)
+		return err
+	}); err != nil || !ok {
+		return coin.UxOut{}, false
+	}
 
-	return ux, ok
+	return *ux, true
 }
 
 // GetAll returns Pool as an array. Note: they are not in any particular order.
 func (up *UnspentPool) GetAll() (coin.UxArray, error) {
-	up.Lock()
-	arr := make(coin.UxArray, 0, len(up.cache.pool))
-	for _, ux := range up.cache.pool {
+	var arr coin.UxArray
+	if err := up.pool.ForEach(func(k, v []byte) error {
+		var ux coin.UxOut
+		if err := encoder.DeserializeRaw(v, &ux); err != nil {
+			return fmt.Errorf("load unspent outputs from db failed: %v", err)
+		}
+
 		arr = append(arr, ux)
+		return nil
+	}); err != nil {
+		return nil, err
 	}
-	up.Unlock()
 
 	return arr, nil
 }
@@ -269,6 +515,7 @@ func (up *UnspentPool) GetAll() (coin.UxArray, error) {
 func (up *UnspentPool) deleteWithTx(tx *bolt.Tx, hashes []cipher.SHA256) (cipher.SHA256, error) {
 	uxouts := uxOuts{tx.Bucket(up.pool.Name)}
 	meta := unspentMeta{tx.Bucket(up.meta.Name)}
+	addrs := addrIndex{tx.Bucket(up.addrs.Name)}
 	var uxHash cipher.SHA256
 	for _, hash := range hashes {
 		ux, ok, err := uxouts.get(hash)
@@ -295,6 +542,16 @@ func (up *UnspentPool) deleteWithTx(tx *bolt.Tx, hashes []cipher.SHA256) (cipher
 		if err := uxouts.delete(hash); err != nil {
 			return cipher.SHA256{}, err
 		}
+
+		if err := addrs.delete(*ux); err != nil {
+			return cipher.SHA256{}, err
+		}
+
+		totals := meta.getTotals()
+		totals.sub(*ux)
+		if err := meta.setTotals(totals); err != nil {
+			return cipher.SHA256{}, err
+		}
 	}
 
 	return uxHash, nil
@@ -302,60 +559,109 @@ func (up *UnspentPool) deleteWithTx(tx *bolt.Tx, hashes []cipher.SHA256) (cipher
 
 // Len returns the unspent outputs num
 func (up *UnspentPool) Len() uint64 {
-	up.Lock()
-	defer up.Unlock()
-	return uint64(len(up.cache.pool))
+	return uint64(up.pool.Len())
+}
+
+// GetSupply returns the coins and the coin hours at time t of the unspent outputs, from
+// the running totals updated as the blocks are applied and rolled back. The hours are
+// rounded down once over all the outputs, not per output as the balances are.
+func (up *UnspentPool) GetSupply(t uint64) (coins, hours uint64, err error) {
+	err = up.db.View(func(tx *bolt.Tx) error {
+		totals := unspentMeta{tx.Bucket(up.meta.Name)}.getTotals()
+		coins = totals.coins
+		hours = totals.hoursAt(t)
+		return nil
+	})
+	return
+}
+
+// GetBlockSpent returns the outputs spent by the block of hash, excluding the ones created
+// by the block itself. Returns false if not recorded, as the block was executed before the
+// spent outputs were recorded.
+func (up *UnspentPool) GetBlockSpent(hash cipher.SHA256) (coin.UxArray, bool, error) {
+	v := up.spents.Get(hash[:])
+	if v == nil {
+		return nil, false, nil
+	}
+
+	var uxs coin.UxArray
+	if err := encoder.DeserializeRaw(v, &uxs); err != nil {
+		return nil, false, err
+	}
+	return uxs, true, nil
+}
+
+// DeleteBlockSpent removes the spent outputs recorded for the blocks of hashes, the blocks
+// that are pruned can't be rolled back.
+func (up *UnspentPool) DeleteBlockSpent(hashes []cipher.SHA256) error {
+	return up.db.Update(func(tx *bolt.Tx) error {
+		bkt := tx.Bucket(up.spents.Name)
+		for _, h := range hashes {
+			if err := bkt.Delete(h[:]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
 // Collides checks for hash collisions with existing hashes
 func (up *UnspentPool) Collides(hashes []cipher.SHA256) bool {
-	up.Lock()
-	for i := range hashes {
-		if _, ok := up.cache.pool[hashes[i].Hex()]; ok {
-			return true
+	var collides bool
+	up.db.View(func(tx *bolt.Tx) error {
+		pool := tx.Bucket(up.pool.Name)
+		for i := range hashes {
+			if pool.Get(hashes[i][:]) != nil {
+				collides = true
+				return nil
+			}
 		}
-	}
-	up.Unlock()
-	return false
+		return nil
+	})
+	return collides
 }
 
 // Contains check if the hash of uxout does exist in the pool
 func (up *UnspentPool) Contains(h cipher.SHA256) bool {
-	up.Lock()
-	_, ok := up.cache.pool[h.Hex()]
-	defer up.Unlock()
-	return ok
+	return up.pool.IsExist(h[:])
 }
 
 // GetUnspentsOfAddr returns all unspent outputs of given address
 func (up *UnspentPool) GetUnspentsOfAddr(addr cipher.Address) coin.UxArray {
-	up.Lock()
-	uxs := make(coin.UxArray, 0, len(up.cache.pool))
-	for _, ux := range up.cache.pool {
-		if ux.Body.Address == addr {
-			uxs = append(uxs, ux)
-		}
+	uxs, ok := up.GetUnspentsOfAddrs([]cipher.Address{addr})[addr]
+	if !ok {
+		return coin.UxArray{}
 	}
-	up.Unlock()
 	return uxs
 }
 
 // GetUnspentsOfAddrs returns unspent outputs map of given addresses,
 // the address as return map key, unspent outputs as value.
 func (up *UnspentPool) GetUnspentsOfAddrs(addrs []cipher.Address) coin.AddressUxOuts {
-	up.Lock()
-	addrm := make(map[cipher.Address]struct{}, len(addrs))
-	for _, a := range addrs {
-		addrm[a] = struct{}{}
-	}
-
 	addrUxs := coin.AddressUxOuts{}
-	for _, ux := range up.cache.pool {
-		if _, ok := addrm[ux.Body.Address]; ok {
-			addrUxs[ux.Body.Address] = append(addrUxs[ux.Body.Address], ux)
+	if err := up.db.View(func(tx *bolt.Tx) error {
+		ai := addrIndex{tx.Bucket(up.addrs.Name)}
+		uxouts := uxOuts{tx.Bucket(up.pool.Name)}
+		for _, addr := range addrs {
+			if _, ok := addrUxs[addr]; ok {
+				continue
+			}
+
+			uxs, err := uxouts.getArray(ai.hashes(addr))
+			if err != nil {
+				return err
+			}
+
+			if len(uxs) > 0 {
+				addrUxs[addr] = uxs
+			}
 		}
+		return nil
+	}); err != nil {
+		logger.Error("Get unspent outputs of addresses failed: %v", err)
+		return coin.AddressUxOuts{}
 	}
-	up.Unlock()
+
 	return addrUxs
 }
 
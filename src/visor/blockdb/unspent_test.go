@@ -73,14 +73,10 @@ func TestNewUnspentPool(t *testing.T) {
 }
 
 func addUxOut(up *UnspentPool, ux coin.UxOut) error {
-	if err := up.db.Update(func(tx *bolt.Tx) error {
+	return up.db.Update(func(tx *bolt.Tx) error {
 		_, err := up.addWithTx(tx, ux)
 		return err
-	}); err != nil {
-		return err
-	}
-	up.addUxToCache([]coin.UxOut{ux})
-	return nil
+	})
 }
 
 func TestUnspentPoolGet(t *testing.T) {
@@ -386,6 +382,91 @@ func TestUnspentPoolDeleteWithTx(t *testing.T) {
 	}
 }
 
+func TestUnspentPoolRollbackBlock(t *testing.T) {
+	db, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	up, err := NewUnspentPool(db)
+	assert.Nil(t, err)
+
+	var uxs coin.UxArray
+	for i := 0; i < 3; i++ {
+		ux := makeUxOut(t)
+		uxs = append(uxs, ux)
+		assert.Nil(t, addUxOut(up, ux))
+	}
+
+	// addUxOut doesn't update the cached xor hash, reads it from db
+	getXorHash := func() cipher.SHA256 {
+		var h cipher.SHA256
+		assert.Nil(t, db.View(func(tx *bolt.Tx) error {
+			var err error
+			h, err = unspentMeta{tx.Bucket(up.meta.Name)}.getXorHash()
+			return err
+		}))
+		return h
+	}
+
+	uxHash := getXorHash()
+
+	txn := coin.Transaction{
+		In: []cipher.SHA256{uxs[0].Hash(), uxs[1].Hash()},
+		Out: []coin.TransactionOutput{
+			{Address: uxs[0].Body.Address, Coins: 2e6, Hours: 100},
+		},
+	}
+	head := coin.BlockHeader{BkSeq: 3, Time: 200}
+	// spends the output created by the previous txn of the block
+	txn2 := coin.Transaction{
+		In: []cipher.SHA256{coin.CreateUnspents(head, txn)[0].Hash()},
+		Out: []coin.TransactionOutput{
+			{Address: uxs[1].Body.Address, Coins: 2e6, Hours: 50},
+		},
+	}
+	b := &coin.Block{
+		Head: head,
+		Body: coin.BlockBody{Transactions: coin.Transactions{txn, txn2}},
+	}
+
+	assert.Nil(t, db.Update(func(tx *bolt.Tx) error {
+		_, err := up.processBlock(b)(tx)
+		return err
+	}))
+	assert.Equal(t, uint64(2), up.Len())
+	assert.NotEqual(t, uxHash, getXorHash())
+
+	// the output created by the block is not recorded as spent
+	spent, ok, err := up.GetBlockSpent(b.HashHeader())
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, uxs[:2], spent)
+
+	assert.Nil(t, db.Update(func(tx *bolt.Tx) error {
+		_, err := up.rollbackBlock(b, spent)(tx)
+		return err
+	}))
+	assert.Equal(t, uint64(3), up.Len())
+	assert.Equal(t, uxHash, getXorHash())
+	assert.Equal(t, uxHash, up.GetUxHash())
+
+	for _, ux := range uxs {
+		assert.True(t, up.Contains(ux.Hash()))
+	}
+
+	for _, t2 := range b.Body.Transactions {
+		for _, ux := range coin.CreateUnspents(b.Head, t2) {
+			assert.False(t, up.Contains(ux.Hash()))
+		}
+	}
+
+	_, ok, err = up.GetBlockSpent(b.HashHeader())
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
 func TestGetUnspentOfAddr(t *testing.T) {
 	var uxs coin.UxArray
 	for i := 0; i < 5; i++ {
@@ -584,3 +665,100 @@ func TestCollides(t *testing.T) {
 		})
 	}
 }
+
+func TestUnspentPoolAddrIndex(t *testing.T) {
+	db, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	up, err := NewUnspentPool(db)
+	assert.Nil(t, err)
+
+	var uxs coin.UxArray
+	for i := 0; i < 3; i++ {
+		ux := makeUxOut(t)
+		uxs = append(uxs, ux)
+		assert.Nil(t, addUxOut(up, ux))
+	}
+
+	// the outputs of the same address
+	ux := makeUxOut(t)
+	ux.Body.Address = uxs[0].Body.Address
+	assert.Nil(t, addUxOut(up, ux))
+	assert.Len(t, up.GetUnspentsOfAddr(ux.Body.Address), 2)
+
+	assert.Nil(t, db.Update(func(tx *bolt.Tx) error {
+		_, err := up.deleteWithTx(tx, []cipher.SHA256{uxs[0].Hash()})
+		return err
+	}))
+	assert.Equal(t, coin.UxArray{ux}, up.GetUnspentsOfAddr(ux.Body.Address))
+	assert.Equal(t, 3, up.addrs.Len())
+
+	// the index is built for the db without it
+	assert.Nil(t, up.addrs.Reset())
+	assert.Nil(t, db.Update(IndexUnspentsByAddr))
+	assert.Equal(t, 3, up.addrs.Len())
+	assert.Equal(t, coin.UxArray{ux}, up.GetUnspentsOfAddr(ux.Body.Address))
+	assert.Equal(t, coin.UxArray{uxs[2]}, up.GetUnspentsOfAddr(uxs[2].Body.Address))
+	assert.Empty(t, up.GetUnspentsOfAddr(makeUxOut(t).Body.Address))
+}
+
+func TestUnspentPoolGetSupply(t *testing.T) {
+	db, teardown, err := setup()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+
+	up, err := NewUnspentPool(db)
+	assert.Nil(t, err)
+
+	coins, hours, err := up.GetSupply(3700)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(0), coins)
+	assert.Equal(t, uint64(0), hours)
+
+	var uxs coin.UxArray
+	for i := 0; i < 3; i++ {
+		ux := makeUxOut(t)
+		uxs = append(uxs, ux)
+		assert.Nil(t, addUxOut(up, ux))
+	}
+
+	// each output has 100 hours and earns 1 hour in 3600 seconds
+	coins, hours, err = up.GetSupply(3700)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(3e6), coins)
+	assert.Equal(t, uint64(303), hours)
+
+	// each output earns half an hour, which UxOut.CoinHours rounds down, the totals round
+	// down the sum of the earned hours
+	var sum uint64
+	for i := range uxs {
+		sum += uxs[i].CoinHours(1900)
+	}
+	assert.Equal(t, uint64(300), sum)
+	_, hours, err = up.GetSupply(1900)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(301), hours)
+	assert.True(t, hours-sum < uint64(len(uxs)))
+
+	assert.Nil(t, db.Update(func(tx *bolt.Tx) error {
+		_, err := up.deleteWithTx(tx, []cipher.SHA256{uxs[0].Hash()})
+		return err
+	}))
+	coins, hours, err = up.GetSupply(3700)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2e6), coins)
+	assert.Equal(t, uint64(202), hours)
+
+	// the totals are computed for the db without them
+	assert.Nil(t, up.meta.Reset())
+	assert.Nil(t, db.Update(TotalUnspents))
+	coins, hours, err = up.GetSupply(3700)
+	assert.Nil(t, err)
+	assert.Equal(t, uint64(2e6), coins)
+	assert.Equal(t, uint64(202), hours)
+}
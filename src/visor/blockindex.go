@@ -0,0 +1,47 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// blockIndex looks up the seqs of the blocks in the main chain by hash. The blocks are
+// read from the block tree in db on demand, no index is kept in memory.
+type blockIndex struct {
+	bc *Blockchain
+}
+
+func newBlockIndex(bc *Blockchain) *blockIndex {
+	return &blockIndex{bc: bc}
+}
+
+// get returns the seq of the block of hash, returns false if the block is not in db or
+// is on a side branch of the tree
+func (bi *blockIndex) get(hash cipher.SHA256) (uint64, bool) {
+	b := bi.bc.GetBlock(hash)
+	if b == nil {
+		return 0, false
+	}
+
+	headSeq := bi.bc.headSeq()
+	if headSeq < 0 || b.Seq() > uint64(headSeq) {
+		return 0, false
+	}
+
+	main := bi.bc.GetBlockInDepth(b.Seq())
+	if main == nil || main.HashHeader() != hash {
+		return 0, false
+	}
+	return b.Seq(), true
+}
+
+// GetReadableBlockByHash returns the readable block of specific hash
+func (vs *Visor) GetReadableBlockByHash(hash cipher.SHA256) (ReadableBlock, error) {
+	b := vs.GetBlockByHash(hash)
+	if b == nil {
+		return ReadableBlock{}, fmt.Errorf("found no block of hash %v", hash.Hex())
+	}
+
+	return NewReadableBlock(b), nil
+}
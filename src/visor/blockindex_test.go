@@ -0,0 +1,49 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestBlockIndex(t *testing.T) {
+	f, err := ioutil.TempFile("", "blockindex")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bc, err := NewBlockchain(db, walker)
+	require.NoError(t, err)
+
+	bi := newBlockIndex(bc)
+	_, ok := bi.get(randSHA256())
+	assert.False(t, ok)
+
+	pub, _ := cipher.GenerateKeyPair()
+	gb, err := bc.CreateGenesisBlock(cipher.AddressFromPubKey(pub), 100e6, 1000)
+	require.NoError(t, err)
+
+	seq, ok := bi.get(gb.HashHeader())
+	assert.True(t, ok)
+	assert.Equal(t, uint64(0), seq)
+
+	// the block is stored in the tree, but it's not executed
+	b := coin.Block{Head: coin.BlockHeader{BkSeq: 1, Time: 1100, PrevHash: gb.HashHeader()}}
+	require.NoError(t, bc.addBlock(&b))
+	_, ok = bi.get(b.HashHeader())
+	assert.False(t, ok)
+
+	_, ok = bi.get(randSHA256())
+	assert.False(t, ok)
+}
@@ -0,0 +1,219 @@
+package visor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// The chain file is the magic, the version, the number of blocks, then each signed block
+// serialized with its length prefixed, and the sha256 checksum of all the data before it.
+// The integers are little endian.
+var chainFileMagic = []byte("SUNCHAIN")
+
+const (
+	chainFileVersion uint32 = 1
+	// maxChainFileRecord is the max size of a serialized signed block in the chain file
+	maxChainFileRecord = 32 * 1024 * 1024
+)
+
+var (
+	// ErrChainFileChecksum is returned when the checksum of the chain file doesn't match
+	ErrChainFileChecksum = errors.New("Chain file checksum mismatch")
+	// ErrNoGenesisBlock is returned when the chain file is imported before the genesis
+	// block is created
+	ErrNoGenesisBlock = errors.New("No genesis block in the chain")
+)
+
+// ExportChain writes the signed blocks from genesis to upToSeq to w in the chain file
+// format. The blocks must not be pruned.
+func (vs *Visor) ExportChain(w io.Writer, upToSeq uint64) error {
+	head := vs.Blockchain.Head()
+	if head == nil {
+		return ErrNoGenesisBlock
+	}
+
+	if upToSeq > head.Seq() {
+		return fmt.Errorf("seq %d is beyond the head block %d", upToSeq, head.Seq())
+	}
+
+	h := sha256.New()
+	cw := io.MultiWriter(w, h)
+
+	hdr := make([]byte, 0, len(chainFileMagic)+12)
+	hdr = append(hdr, chainFileMagic...)
+	hdr = appendUint32(hdr, chainFileVersion)
+	hdr = appendUint64(hdr, upToSeq+1)
+	if _, err := cw.Write(hdr); err != nil {
+		return err
+	}
+
+	for seq := uint64(0); seq <= upToSeq; seq++ {
+		b := vs.Blockchain.GetBlockInDepth(seq)
+		if b == nil {
+			return fmt.Errorf("found no block in seq %v", seq)
+		}
+
+		if blockPruned(b) {
+			return fmt.Errorf("block %d is pruned", seq)
+		}
+
+		sig, err := vs.blockSigs.Get(b.HashHeader())
+		if err != nil {
+			return err
+		}
+
+		d := encoder.Serialize(coin.SignedBlock{Block: *b, Sig: sig})
+		if _, err := cw.Write(appendUint32(nil, uint32(len(d)))); err != nil {
+			return err
+		}
+
+		if _, err := cw.Write(d); err != nil {
+			return err
+		}
+	}
+
+	_, err := w.Write(h.Sum(nil))
+	return err
+}
+
+// ReadChain reads the signed blocks of the chain file from r, f is called with each block
+// in order. The file is read twice, the checksum is verified before any block is passed
+// to f, ErrChainFileChecksum is returned if the file is corrupted.
+func ReadChain(r io.ReadSeeker, f func(coin.SignedBlock) error) error {
+	if err := readChain(r, nil); err != nil {
+		return err
+	}
+
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return readChain(r, f)
+}
+
+// readChain reads the chain file from r and verifies the checksum, f is called with each
+// block as it's read if it's not nil
+func readChain(r io.Reader, f func(coin.SignedBlock) error) error {
+	h := sha256.New()
+	cr := io.TeeReader(r, h)
+
+	hdr := make([]byte, len(chainFileMagic)+12)
+	if _, err := io.ReadFull(cr, hdr); err != nil {
+		return fmt.Errorf("Read chain file header failed: %v", err)
+	}
+
+	if !bytes.Equal(hdr[:len(chainFileMagic)], chainFileMagic) {
+		return errors.New("Not a chain file")
+	}
+
+	hdr = hdr[len(chainFileMagic):]
+	if v := binary.LittleEndian.Uint32(hdr); v != chainFileVersion {
+		return fmt.Errorf("Unsupported chain file version %d", v)
+	}
+
+	n := binary.LittleEndian.Uint64(hdr[4:])
+	for i := uint64(0); i < n; i++ {
+		sb, err := readChainRecord(cr)
+		if err != nil {
+			return fmt.Errorf("Read block %d of chain file failed: %v", i, err)
+		}
+
+		if sb.Block.Seq() != i {
+			return fmt.Errorf("Block %d of chain file has seq %d", i, sb.Block.Seq())
+		}
+
+		if f == nil {
+			continue
+		}
+
+		if err := f(sb); err != nil {
+			return err
+		}
+	}
+
+	return verifyChainChecksum(r, h)
+}
+
+func readChainRecord(r io.Reader) (coin.SignedBlock, error) {
+	var sb coin.SignedBlock
+	var l [4]byte
+	if _, err := io.ReadFull(r, l[:]); err != nil {
+		return sb, err
+	}
+
+	size := binary.LittleEndian.Uint32(l[:])
+	if size > maxChainFileRecord {
+		return sb, fmt.Errorf("block size %d exceeds the limit", size)
+	}
+
+	d := make([]byte, size)
+	if _, err := io.ReadFull(r, d); err != nil {
+		return sb, err
+	}
+
+	err := encoder.DeserializeRaw(d, &sb)
+	return sb, err
+}
+
+func verifyChainChecksum(r io.Reader, h hash.Hash) error {
+	sum := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(r, sum); err != nil {
+		return fmt.Errorf("Read chain file checksum failed: %v", err)
+	}
+
+	if !bytes.Equal(sum, h.Sum(nil)) {
+		return ErrChainFileChecksum
+	}
+	return nil
+}
+
+// ImportChain executes the blocks of the chain file read from r, the blocks already in
+// the chain are skipped. The block signatures are verified as the blocks received from
+// peers. The visor must be running, the blocks are parsed by the history db.
+func (vs *Visor) ImportChain(r io.ReadSeeker) error {
+	return ReadChain(r, vs.ImportSignedBlock)
+}
+
+// ImportSignedBlock executes the block of the chain file, it's skipped if it's already
+// in the chain. Returns error if the block conflicts with the chain or is not the next
+// block of the head.
+func (vs *Visor) ImportSignedBlock(sb coin.SignedBlock) error {
+	head := vs.Blockchain.Head()
+	if head == nil {
+		return ErrNoGenesisBlock
+	}
+
+	seq := sb.Block.Seq()
+	if seq <= head.Seq() {
+		b := vs.GetBlockBySeq(seq)
+		if b == nil || b.HashHeader() != sb.Block.HashHeader() {
+			return fmt.Errorf("block %d of chain file conflicts with the chain", seq)
+		}
+		return nil
+	}
+
+	if seq != head.Seq()+1 {
+		return fmt.Errorf("block %d of chain file is not the next block of the head %d", seq, head.Seq())
+	}
+
+	return vs.ExecuteSignedBlock(sb)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	var d [4]byte
+	binary.LittleEndian.PutUint32(d[:], v)
+	return append(b, d[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var d [8]byte
+	binary.LittleEndian.PutUint64(d[:], v)
+	return append(b, d[:]...)
+}
@@ -0,0 +1,114 @@
+package visor
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+func TestChainFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "bootstrap")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bc, err := NewBlockchain(db, walker)
+	require.NoError(t, err)
+
+	sigs, err := blockdb.NewBlockSigs(db)
+	require.NoError(t, err)
+
+	vs := &Visor{Blockchain: bc, blockSigs: sigs}
+
+	pub, sec := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pub)
+	gb, err := bc.CreateGenesisBlock(addr, 100e6, 1000)
+	require.NoError(t, err)
+
+	var sbs []coin.SignedBlock
+	addSigned := func(b coin.Block) {
+		sb := coin.SignedBlock{Block: b, Sig: cipher.SignHash(b.HashHeader(), sec)}
+		require.NoError(t, sigs.Add(&sb))
+		sbs = append(sbs, sb)
+	}
+	addSigned(gb)
+
+	for i := uint64(1); i <= 3; i++ {
+		txn := coin.Transaction{}
+		txn.PushOutput(addr, i*1e6, 10)
+		txn.UpdateHeader()
+		body := coin.BlockBody{Transactions: coin.Transactions{txn}}
+		b := coin.Block{
+			Head: coin.BlockHeader{
+				BkSeq:    i,
+				Time:     1000 + i,
+				BodyHash: body.Hash(),
+				PrevHash: sbs[i-1].Block.HashHeader(),
+			},
+			Body: body,
+		}
+		require.NoError(t, bc.addBlock(&b))
+		require.NoError(t, bc.processBlock(&b))
+		addSigned(b)
+	}
+
+	var buf bytes.Buffer
+	require.Error(t, vs.ExportChain(&buf, 4))
+
+	buf.Reset()
+	require.NoError(t, vs.ExportChain(&buf, 2))
+	data := buf.Bytes()
+
+	var read []coin.SignedBlock
+	require.NoError(t, ReadChain(bytes.NewReader(data), func(sb coin.SignedBlock) error {
+		read = append(read, sb)
+		return nil
+	}))
+	assert.Equal(t, sbs[:3], read)
+
+	// the blocks in the chain are skipped
+	require.NoError(t, vs.ImportChain(bytes.NewReader(data)))
+
+	// corrupts the signature of the last block
+	corrupted := append([]byte{}, data...)
+	corrupted[len(corrupted)-sha256.Size-1] ^= 1
+	var calls int
+	err = ReadChain(bytes.NewReader(corrupted), func(coin.SignedBlock) error {
+		calls++
+		return nil
+	})
+	assert.Equal(t, ErrChainFileChecksum, err)
+	// no block is applied from the corrupted file
+	assert.Equal(t, 0, calls)
+
+	// truncated
+	err = ReadChain(bytes.NewReader(data[:len(data)-1]), func(coin.SignedBlock) error { return nil })
+	assert.Error(t, err)
+
+	err = ReadChain(bytes.NewReader([]byte("NOTCHAIN0000000000000")), func(coin.SignedBlock) error { return nil })
+	assert.Error(t, err)
+
+	// the block conflicting with the chain
+	conflict := sbs[2]
+	conflict.Block.Head.Time++
+	assert.Error(t, vs.ImportSignedBlock(conflict))
+
+	// the block not following the head
+	gap := sbs[3]
+	gap.Block.Head.BkSeq = 5
+	assert.Error(t, vs.ImportSignedBlock(gap))
+}
@@ -0,0 +1,113 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// ErrCheckpointMismatch is returned when a block conflicts with the checkpoints, the peer
+// offering the block is on a history other than the known-good one.
+var ErrCheckpointMismatch = errors.New("Block conflicts with the checkpoints")
+
+// Checkpoint is the hash of a known-good block, the chain must have the block at Seq
+type Checkpoint struct {
+	Seq  uint64
+	Hash cipher.SHA256
+}
+
+// ParseCheckpoints parses the checkpoints in the format of "seq:hash,seq:hash"
+func ParseCheckpoints(s string) ([]Checkpoint, error) {
+	var cps []Checkpoint
+	for _, v := range strings.Split(s, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+
+		pair := strings.Split(v, ":")
+		if len(pair) != 2 {
+			return nil, fmt.Errorf("invalid checkpoint %q, must be seq:hash", v)
+		}
+
+		seq, err := strconv.ParseUint(pair[0], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkpoint seq %q: %v", pair[0], err)
+		}
+
+		hash, err := cipher.SHA256FromHex(pair[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid checkpoint hash %q: %v", pair[1], err)
+		}
+
+		cps = append(cps, Checkpoint{Seq: seq, Hash: hash})
+	}
+	return cps, nil
+}
+
+// checkpoints maps the seqs of the checkpoints to the block hashes
+type checkpoints map[uint64]cipher.SHA256
+
+func newCheckpoints(cps []Checkpoint) checkpoints {
+	m := make(checkpoints, len(cps))
+	for _, cp := range cps {
+		m[cp.Seq] = cp.Hash
+	}
+	return m
+}
+
+// check returns ErrCheckpointMismatch if there's a checkpoint at the seq of b with other hash
+func (cps checkpoints) check(b coin.Block) error {
+	if h, ok := cps[b.Seq()]; ok && h != b.HashHeader() {
+		return ErrCheckpointMismatch
+	}
+	return nil
+}
+
+// highest returns the highest seq of the checkpoints that are not above seq, returns
+// false if there's none.
+func (cps checkpoints) highest(seq uint64) (uint64, bool) {
+	var (
+		max   uint64
+		found bool
+	)
+	for s := range cps {
+		if s <= seq && (!found || s > max) {
+			max = s
+			found = true
+		}
+	}
+	return max, found
+}
+
+// verifyCheckpoints checks that the blocks in the chain match the checkpoints, returns the
+// highest seq of the checkpoints reached by the chain, the signatures of the blocks up to
+// it don't need to be verified, as they are linked by the hashes.
+func (vs *Visor) verifyCheckpoints() (uint64, error) {
+	head := vs.Blockchain.Head()
+	if head == nil {
+		return 0, nil
+	}
+
+	for seq, h := range vs.checkpoints {
+		if seq > head.Seq() {
+			continue
+		}
+
+		b := vs.GetBlockBySeq(seq)
+		if b == nil {
+			return 0, fmt.Errorf("found no block in seq %v", seq)
+		}
+
+		if b.HashHeader() != h {
+			return 0, fmt.Errorf("block %v conflicts with the checkpoint %v", seq, h.Hex())
+		}
+	}
+
+	seq, _ := vs.checkpoints.highest(head.Seq())
+	return seq, nil
+}
@@ -0,0 +1,74 @@
+package visor
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestParseCheckpoints(t *testing.T) {
+	h1, h2 := randSHA256(), randSHA256()
+
+	testCases := []struct {
+		name string
+		s    string
+		cps  []Checkpoint
+		err  bool
+	}{
+		{"empty", "", nil, false},
+		{"one", fmt.Sprintf("10:%s", h1.Hex()), []Checkpoint{{10, h1}}, false},
+		{
+			"multiple",
+			fmt.Sprintf("10:%s, 2000:%s,", h1.Hex(), h2.Hex()),
+			[]Checkpoint{{10, h1}, {2000, h2}},
+			false,
+		},
+		{"no hash", "10", nil, true},
+		{"invalid seq", fmt.Sprintf("-1:%s", h1.Hex()), nil, true},
+		{"invalid hash", "10:abc", nil, true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			cps, err := ParseCheckpoints(tc.s)
+			if tc.err {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.cps, cps)
+		})
+	}
+}
+
+func TestCheckpoints(t *testing.T) {
+	newBlock := func(seq uint64) coin.Block {
+		return coin.Block{Head: coin.BlockHeader{BkSeq: seq, BodyHash: randSHA256()}}
+	}
+
+	b10, b20 := newBlock(10), newBlock(20)
+	cps := newCheckpoints([]Checkpoint{
+		{10, b10.HashHeader()},
+		{20, b20.HashHeader()},
+	})
+
+	assert.NoError(t, cps.check(b10))
+	assert.Equal(t, ErrCheckpointMismatch, cps.check(newBlock(10)))
+
+	// no checkpoint at the seq
+	assert.NoError(t, cps.check(newBlock(15)))
+
+	_, ok := cps.highest(9)
+	assert.False(t, ok)
+
+	seq, ok := cps.highest(19)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10), seq)
+
+	seq, ok = cps.highest(100)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(20), seq)
+}
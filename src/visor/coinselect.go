@@ -0,0 +1,171 @@
+package visor
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+// Names of the coin selection strategies
+const (
+	// SelectMinHours spends the outputs with the fewest coin hours, the hours of the
+	// inputs are burned or moved to the outputs, so it burns the fewest hours
+	SelectMinHours = "min_hours"
+	// SelectMinInputs spends the outputs with the most coins, so the transaction has the
+	// fewest inputs
+	SelectMinInputs = "min_inputs"
+	// SelectOldest spends the oldest outputs first
+	SelectOldest = "oldest"
+	// SelectRandom spends random outputs, preferring the outputs of a single address, so
+	// the transaction links as few addresses as possible
+	SelectRandom = "random"
+)
+
+// DefaultCoinSelection is the strategy used if none is selected
+const DefaultCoinSelection = SelectMinHours
+
+// ErrNotEnoughCoins is returned if the outputs don't have enough coins to spend
+var ErrNotEnoughCoins = errors.New("Not enough confirmed coins")
+
+// CoinSelector chooses the unspent outputs spent by a transaction sending amt
+type CoinSelector interface {
+	Select(headTime uint64, uxs coin.UxArray, amt wallet.Balance) (coin.UxArray, error)
+}
+
+// CoinSelectorFunc is a function implementing CoinSelector
+type CoinSelectorFunc func(headTime uint64, uxs coin.UxArray, amt wallet.Balance) (coin.UxArray, error)
+
+// Select calls f
+func (f CoinSelectorFunc) Select(headTime uint64, uxs coin.UxArray, amt wallet.Balance) (coin.UxArray, error) {
+	return f(headTime, uxs, amt)
+}
+
+var coinSelectors = map[string]CoinSelector{
+	SelectMinHours:  CoinSelectorFunc(selectMinHours),
+	SelectMinInputs: CoinSelectorFunc(selectMinInputs),
+	SelectOldest:    CoinSelectorFunc(selectOldest),
+	SelectRandom:    CoinSelectorFunc(selectRandom),
+}
+
+// GetCoinSelector returns the coin selection strategy of the name, the default is returned
+// if the name is empty
+func GetCoinSelector(name string) (CoinSelector, error) {
+	if name == "" {
+		name = DefaultCoinSelection
+	}
+
+	s, ok := coinSelectors[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown coin selection %q", name)
+	}
+	return s, nil
+}
+
+func selectMinHours(headTime uint64, uxs coin.UxArray, amt wallet.Balance) (coin.UxArray, error) {
+	uxa := spendableUxOuts(uxs)
+	sort.Slice(uxa, func(i, j int) bool {
+		hi, hj := uxa[i].CoinHours(headTime), uxa[j].CoinHours(headTime)
+		if hi != hj {
+			return hi < hj
+		}
+		// fewer inputs for the same hours
+		if uxa[i].Body.Coins != uxa[j].Body.Coins {
+			return uxa[i].Body.Coins > uxa[j].Body.Coins
+		}
+		return lessUxHash(uxa[i], uxa[j])
+	})
+	return takeUntil(uxa, amt)
+}
+
+func selectMinInputs(headTime uint64, uxs coin.UxArray, amt wallet.Balance) (coin.UxArray, error) {
+	uxa := spendableUxOuts(uxs)
+	sort.Slice(uxa, func(i, j int) bool {
+		if uxa[i].Body.Coins != uxa[j].Body.Coins {
+			return uxa[i].Body.Coins > uxa[j].Body.Coins
+		}
+		return OldestUxOut(uxa).Less(i, j)
+	})
+	return takeUntil(uxa, amt)
+}
+
+func selectOldest(headTime uint64, uxs coin.UxArray, amt wallet.Balance) (coin.UxArray, error) {
+	uxa := spendableUxOuts(uxs)
+	sort.Sort(OldestUxOut(uxa))
+	return takeUntil(uxa, amt)
+}
+
+func selectRandom(headTime uint64, uxs coin.UxArray, amt wallet.Balance) (coin.UxArray, error) {
+	uxa := spendableUxOuts(uxs)
+	if err := shuffleUxOuts(uxa); err != nil {
+		return nil, err
+	}
+
+	// the first address in the random order having enough coins by itself
+	var addrs []cipher.Address
+	byAddr := make(map[cipher.Address]coin.UxArray)
+	for _, ux := range uxa {
+		a := ux.Body.Address
+		if _, ok := byAddr[a]; !ok {
+			addrs = append(addrs, a)
+		}
+		byAddr[a] = append(byAddr[a], ux)
+	}
+
+	for _, a := range addrs {
+		if spends, err := takeUntil(byAddr[a], amt); err == nil {
+			return spends, nil
+		}
+	}
+
+	return takeUntil(uxa, amt)
+}
+
+// spendableUxOuts returns a copy of the outputs that can be spent, the coins must be a
+// multiple of 1e6
+func spendableUxOuts(uxs coin.UxArray) coin.UxArray {
+	uxa := make(coin.UxArray, 0, len(uxs))
+	for _, ux := range uxs {
+		if ux.Body.Coins == 0 || ux.Body.Coins%1e6 != 0 {
+			logger.Error("UxOut coins are 0 or 1e6, can't spend")
+			continue
+		}
+		uxa = append(uxa, ux)
+	}
+	return uxa
+}
+
+// takeUntil returns the outputs from the start of uxs until they have amt coins
+func takeUntil(uxs coin.UxArray, amt wallet.Balance) (coin.UxArray, error) {
+	var coins uint64
+	for i, ux := range uxs {
+		coins += ux.Body.Coins
+		if coins >= amt.Coins {
+			return uxs[:i+1], nil
+		}
+	}
+	return nil, ErrNotEnoughCoins
+}
+
+func shuffleUxOuts(uxs coin.UxArray) error {
+	for i := len(uxs) - 1; i > 0; i-- {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(i+1)))
+		if err != nil {
+			return err
+		}
+		j := int(n.Int64())
+		uxs[i], uxs[j] = uxs[j], uxs[i]
+	}
+	return nil
+}
+
+func lessUxHash(a, b coin.UxOut) bool {
+	ha, hb := a.Hash(), b.Hash()
+	return bytes.Compare(ha[:], hb[:]) < 0
+}
@@ -0,0 +1,100 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+func TestCoinSelectors(t *testing.T) {
+	headTime := uint64(1500000000)
+	p1, _ := cipher.GenerateKeyPair()
+	p2, _ := cipher.GenerateKeyPair()
+	a1, a2 := cipher.AddressFromPubKey(p1), cipher.AddressFromPubKey(p2)
+
+	ux := func(addr cipher.Address, seq, coins, hours uint64) coin.UxOut {
+		return coin.UxOut{
+			Head: coin.UxHead{Time: headTime, BkSeq: seq},
+			Body: coin.UxBody{SrcTransaction: randSHA256(), Address: addr, Coins: coins, Hours: hours},
+		}
+	}
+	uxs := coin.UxArray{
+		ux(a1, 3, 1e6, 500),
+		ux(a2, 1, 2e6, 300),
+		ux(a1, 2, 5e6, 900),
+		ux(a2, 4, 1e6, 10),
+		ux(a1, 5, 1234, 0), // can't be spent
+	}
+	hashes := func(uxa coin.UxArray) []cipher.SHA256 {
+		var hs []cipher.SHA256
+		for _, u := range uxa {
+			hs = append(hs, u.Hash())
+		}
+		return hs
+	}
+	amt := wallet.Balance{Coins: 3e6}
+
+	tt := []struct {
+		name   string
+		spends coin.UxArray
+	}{
+		{SelectMinHours, coin.UxArray{uxs[3], uxs[1]}},
+		{SelectMinInputs, coin.UxArray{uxs[2]}},
+		{SelectOldest, coin.UxArray{uxs[1], uxs[2]}},
+	}
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			sel, err := GetCoinSelector(tc.name)
+			require.NoError(t, err)
+			spends, err := createSpends(headTime, uxs, amt, sel)
+			require.NoError(t, err)
+			assert.Equal(t, hashes(tc.spends), hashes(spends))
+		})
+	}
+
+	// the default selection burns the fewest hours
+	spends, err := createSpends(headTime, uxs, amt, nil)
+	require.NoError(t, err)
+	assert.Equal(t, hashes(coin.UxArray{uxs[3], uxs[1]}), hashes(spends))
+
+	// random spends the outputs of a single address if it has enough coins
+	sel, err := GetCoinSelector(SelectRandom)
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		spends, err := createSpends(headTime, uxs, amt, sel)
+		require.NoError(t, err)
+		var coins uint64
+		for _, u := range spends {
+			assert.Equal(t, spends[0].Body.Address, u.Body.Address)
+			coins += u.Body.Coins
+		}
+		assert.True(t, coins >= amt.Coins)
+	}
+
+	// more than a single address has, the outputs are taken till there are enough coins
+	spends, err = createSpends(headTime, uxs, wallet.Balance{Coins: 8e6}, sel)
+	require.NoError(t, err)
+	var coins uint64
+	for _, u := range spends {
+		coins += u.Body.Coins
+	}
+	assert.True(t, coins >= 8e6)
+	assert.True(t, coins-spends[len(spends)-1].Body.Coins < 8e6)
+
+	for name := range coinSelectors {
+		sel, err := GetCoinSelector(name)
+		require.NoError(t, err)
+		_, err = createSpends(headTime, uxs, wallet.Balance{Coins: 10e6}, sel)
+		assert.Equal(t, ErrNotEnoughCoins, err)
+	}
+
+	_, err = GetCoinSelector("largest")
+	assert.Error(t, err)
+	_, err = createSpends(headTime, uxs, wallet.Balance{Coins: 1234}, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,310 @@
+package visor
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/visor/bucket"
+)
+
+var lastCompactionKey = []byte("last_compaction")
+
+// compactRequestSuffix is the suffix of the file next to the db requesting the compaction when
+// the node starts next
+const compactRequestSuffix = ".compact-requested"
+
+// compactTxSize is the max size of the data copied in a db transaction when compacting
+const compactTxSize = 64 * 1024 * 1024
+
+// BucketStats is the number of keys and the size of a bucket of the db
+type BucketStats struct {
+	Name string `json:"name"`
+	Keys int    `json:"keys"`
+	// bytes of the pages used by the bucket, and of the keys and values in them
+	Bytes     int `json:"bytes"`
+	DataBytes int `json:"data_bytes"`
+}
+
+// DBStats is the statistics of the db file
+type DBStats struct {
+	Path string `json:"path"`
+	// size of the db file in bytes
+	Size int64 `json:"size"`
+	// number of the free pages, which are reused before the file grows, and their size
+	FreePages int `json:"free_pages"`
+	FreeBytes int `json:"free_bytes"`
+	// unix time of the last compaction, or the first start if the db is never compacted,
+	// 0 if compaction is never enabled
+	LastCompaction int64 `json:"last_compaction"`
+	// unix time the db will be compacted when the node starts after, 0 if compaction is
+	// disabled
+	NextCompaction int64 `json:"next_compaction"`
+	// the db is compacted when the node starts next, regardless of the interval
+	CompactionRequested bool          `json:"compaction_requested"`
+	Buckets             []BucketStats `json:"buckets"`
+}
+
+// lastCompaction returns the time the db is compacted, zero time if it's never compacted
+func lastCompaction(tx *bolt.Tx) time.Time {
+	bkt := tx.Bucket(dbMetaBktName)
+	if bkt == nil {
+		return time.Time{}
+	}
+
+	v := bkt.Get(lastCompactionKey)
+	if v == nil {
+		return time.Time{}
+	}
+	return time.Unix(int64(bucket.Btoi(v)), 0)
+}
+
+func setLastCompaction(tx *bolt.Tx, t time.Time) error {
+	bkt, err := tx.CreateBucketIfNotExists(dbMetaBktName)
+	if err != nil {
+		return err
+	}
+	return bkt.Put(lastCompactionKey, bucket.Itob(uint64(t.Unix())))
+}
+
+// compactDBIfDue compacts the db file if it's not compacted in the interval, or if the
+// compaction is requested by RequestCompaction. The db is copied to a new file, which
+// replaces the db file, the space freed by the deleted data is returned to the file system.
+// It must be called before the db is opened.
+func compactDBIfDue(path string, interval time.Duration) error {
+	requested := compactionRequested(path)
+	if interval <= 0 && !requested {
+		return nil
+	}
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		os.Remove(path + compactRequestSuffix)
+		return nil
+	}
+
+	src, closeSrc, err := openDB(path, false)
+	if err != nil {
+		return err
+	}
+
+	due := requested
+	if err := src.View(func(tx *bolt.Tx) error {
+		if due {
+			return nil
+		}
+		last := lastCompaction(tx)
+		// the new db is not compacted until the interval passes
+		if last.IsZero() {
+			return nil
+		}
+		due = time.Since(last) >= interval
+		return nil
+	}); err != nil {
+		closeSrc()
+		return err
+	}
+
+	if !due {
+		// starts counting the interval from the first start
+		err := src.Update(func(tx *bolt.Tx) error {
+			if !lastCompaction(tx).IsZero() {
+				return nil
+			}
+			return setLastCompaction(tx, time.Now())
+		})
+		closeSrc()
+		return err
+	}
+
+	tmpPath := path + ".compact"
+	os.Remove(tmpPath)
+
+	before := src.Stats()
+	logger.Info("Compacting db %s...", path)
+	if err := copyDB(src, tmpPath); err != nil {
+		closeSrc()
+		os.Remove(tmpPath)
+		return err
+	}
+	closeSrc()
+
+	var oldSize, newSize int64
+	if fi, err := os.Stat(path); err == nil {
+		oldSize = fi.Size()
+	}
+	if fi, err := os.Stat(tmpPath); err == nil {
+		newSize = fi.Size()
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	os.Remove(path + compactRequestSuffix)
+
+	logger.Info("Compacted db from %d to %d bytes, %d free pages released", oldSize, newSize, before.FreePageN)
+	return nil
+}
+
+// compactionRequested returns whether RequestCompaction is called for the db of path
+func compactionRequested(path string) bool {
+	_, err := os.Stat(path + compactRequestSuffix)
+	return err == nil
+}
+
+// RequestCompaction requests compacting the db when the node starts next, regardless of
+// DBCompactInterval. The db can't be compacted while it's open.
+func (vs *Visor) RequestCompaction() error {
+	if vs.Config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	f, err := os.Create(vs.Config.DBPath + compactRequestSuffix)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// copyDB copies all the buckets of src to a new db file at dstPath, and records the
+// compaction time in it
+func copyDB(src *bolt.DB, dstPath string) error {
+	dst, err := bolt.Open(dstPath, 0600, &bolt.Options{Timeout: 500 * time.Millisecond})
+	if err != nil {
+		return err
+	}
+
+	if err := src.View(func(stx *bolt.Tx) error {
+		// the data is written in batches, a transaction holds it in memory until committed
+		dtx, err := dst.Begin(true)
+		if err != nil {
+			return err
+		}
+
+		var size int
+		commit := func() error {
+			if size < compactTxSize {
+				return nil
+			}
+
+			if err := dtx.Commit(); err != nil {
+				return err
+			}
+			size = 0
+			dtx, err = dst.Begin(true)
+			return err
+		}
+
+		err = stx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			return copyBucket(b, [][]byte{name}, func(path [][]byte, k, v []byte) error {
+				if err := putInBucket(dtx, path, k, v); err != nil {
+					return err
+				}
+				size += len(k) + len(v)
+				return commit()
+			})
+		})
+		if err != nil {
+			dtx.Rollback()
+			return err
+		}
+
+		if err := setLastCompaction(dtx, time.Now()); err != nil {
+			dtx.Rollback()
+			return err
+		}
+		return dtx.Commit()
+	}); err != nil {
+		dst.Close()
+		return err
+	}
+
+	return dst.Close()
+}
+
+// copyBucket calls f with the path of the bucket and each of its keys, v is nil for a
+// nested bucket, whose keys follow
+func copyBucket(b *bolt.Bucket, path [][]byte, f func(path [][]byte, k, v []byte) error) error {
+	if err := f(path, nil, nil); err != nil {
+		return err
+	}
+
+	return b.ForEach(func(k, v []byte) error {
+		if v != nil {
+			return f(path, k, v)
+		}
+
+		sub := make([][]byte, len(path), len(path)+1)
+		copy(sub, path)
+		return copyBucket(b.Bucket(k), append(sub, k), f)
+	})
+}
+
+// putInBucket puts the key value in the bucket of path, the buckets are created if not
+// exist. Only the buckets are created if k is nil.
+func putInBucket(tx *bolt.Tx, path [][]byte, k, v []byte) error {
+	b, err := tx.CreateBucketIfNotExists(path[0])
+	if err != nil {
+		return err
+	}
+
+	for _, name := range path[1:] {
+		if b, err = b.CreateBucketIfNotExists(name); err != nil {
+			return err
+		}
+	}
+
+	if k == nil {
+		return nil
+	}
+	return b.Put(k, v)
+}
+
+// GetDBStats returns the statistics of the db, the buckets are sorted by name. The db is
+// safe for concurrent reads, it can be called outside of the daemon strand.
+func (vs *Visor) GetDBStats() (*DBStats, error) {
+	s := &DBStats{
+		Path:                vs.Config.DBPath,
+		CompactionRequested: compactionRequested(vs.Config.DBPath),
+	}
+	if fi, err := os.Stat(vs.Config.DBPath); err == nil {
+		s.Size = fi.Size()
+	}
+
+	if err := vs.db.View(func(tx *bolt.Tx) error {
+		if last := lastCompaction(tx); !last.IsZero() {
+			s.LastCompaction = last.Unix()
+			if vs.Config.DBCompactInterval > 0 {
+				s.NextCompaction = last.Add(vs.Config.DBCompactInterval).Unix()
+			}
+		}
+
+		return tx.ForEach(func(name []byte, b *bolt.Bucket) error {
+			bs := b.Stats()
+			s.Buckets = append(s.Buckets, BucketStats{
+				Name:      string(name),
+				Keys:      bs.KeyN,
+				Bytes:     bs.BranchAlloc + bs.LeafAlloc,
+				DataBytes: bs.BranchInuse + bs.LeafInuse,
+			})
+			return nil
+		})
+	}); err != nil {
+		return nil, err
+	}
+
+	st := vs.db.Stats()
+	s.FreePages = st.FreePageN
+	s.FreeBytes = st.FreeAlloc
+	return s, nil
+}
+
+// WriteDB writes a consistent copy of the db to w, the db can be updated in the meantime
+func (vs *Visor) WriteDB(w io.Writer) error {
+	return vs.db.View(func(tx *bolt.Tx) error {
+		_, err := tx.WriteTo(w)
+		return err
+	})
+}
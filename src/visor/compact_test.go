@@ -0,0 +1,136 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/visor/bucket"
+)
+
+func TestCompactDB(t *testing.T) {
+	f, err := ioutil.TempFile("", "compact")
+	require.NoError(t, err)
+	f.Close()
+	path := f.Name()
+	defer os.Remove(path)
+	defer os.Remove(path + ".compact")
+	defer os.Remove(path + compactRequestSuffix)
+
+	db, err := bolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+
+	value := make([]byte, 1024)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		a, err := tx.CreateBucket([]byte("a"))
+		if err != nil {
+			return err
+		}
+
+		nested, err := a.CreateBucket([]byte("nested"))
+		if err != nil {
+			return err
+		}
+
+		if err := nested.Put([]byte("k"), []byte("v")); err != nil {
+			return err
+		}
+
+		b, err := tx.CreateBucket([]byte("b"))
+		if err != nil {
+			return err
+		}
+
+		for i := uint64(0); i < 5000; i++ {
+			if err := b.Put(bucket.Itob(i), value); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+
+	// deletes most of the data, the pages are freed but the file doesn't shrink
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket([]byte("b"))
+		for i := uint64(10); i < 5000; i++ {
+			if err := b.Delete(bucket.Itob(i)); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	require.NoError(t, db.Close())
+
+	size := func() int64 {
+		fi, err := os.Stat(path)
+		require.NoError(t, err)
+		return fi.Size()
+	}
+	before := size()
+
+	// the interval starts from the first start
+	require.NoError(t, compactDBIfDue(path, time.Hour))
+	assert.Equal(t, before, size())
+
+	db, err = bolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		assert.False(t, lastCompaction(tx).IsZero())
+		return setLastCompaction(tx, time.Now().Add(-2*time.Hour))
+	}))
+	require.NoError(t, db.Close())
+
+	require.NoError(t, compactDBIfDue(path, time.Hour))
+	assert.True(t, size() < before)
+
+	db, err = bolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		assert.True(t, time.Since(lastCompaction(tx)) < time.Minute)
+		assert.Equal(t, []byte("v"), tx.Bucket([]byte("a")).Bucket([]byte("nested")).Get([]byte("k")))
+		assert.Equal(t, 10, tx.Bucket([]byte("b")).Stats().KeyN)
+		return nil
+	}))
+
+	vs := &Visor{db: db}
+	vs.Config.DBPath = path
+	vs.Config.DBCompactInterval = time.Hour
+	stats, err := vs.GetDBStats()
+	require.NoError(t, err)
+	assert.Equal(t, size(), stats.Size)
+	assert.Equal(t, stats.LastCompaction+3600, stats.NextCompaction)
+
+	var names []string
+	for _, b := range stats.Buckets {
+		names = append(names, b.Name)
+	}
+	assert.Equal(t, []string{"a", "b", "db_meta"}, names)
+	assert.Equal(t, 10, stats.Buckets[1].Keys)
+	assert.False(t, stats.CompactionRequested)
+
+	// the requested compaction runs regardless of the interval
+	require.NoError(t, vs.RequestCompaction())
+	stats, err = vs.GetDBStats()
+	require.NoError(t, err)
+	assert.True(t, stats.CompactionRequested)
+	require.NoError(t, db.Close())
+
+	require.NoError(t, compactDBIfDue(path, 0))
+	assert.False(t, compactionRequested(path))
+
+	db, err = bolt.Open(path, 0600, nil)
+	require.NoError(t, err)
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		assert.True(t, time.Since(lastCompaction(tx)) < time.Minute)
+		assert.Equal(t, 10, tx.Bucket([]byte("b")).Stats().KeyN)
+		return nil
+	}))
+	require.NoError(t, db.Close())
+}
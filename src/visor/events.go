@@ -0,0 +1,141 @@
+package visor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/utc"
+)
+
+// EventType is the type of the events published on the event bus
+type EventType string
+
+// Event types
+const (
+	EventNewBlock          EventType = "new_block"
+	EventNewUnconfirmedTxn EventType = "new_unconfirmed_txn"
+	EventTxnConfirmed      EventType = "txn_confirmed"
+	// the txn is removed from the unconfirmed pool without being confirmed
+	EventTxnDropped EventType = "txn_dropped"
+	EventReorg      EventType = "reorg"
+	// a watched address receives or spends an output
+	EventAddressActivity EventType = "address_activity"
+)
+
+// Reasons of EventTxnDropped
+const (
+	// the txn is held in the unconfirmed pool longer than the max age
+	DropExpired = "expired"
+	// the txn is evicted to keep the unconfirmed pool in the max txns and bytes
+	DropOverflowed = "overflowed"
+)
+
+// Event is published to the subscribers of the event bus
+type Event struct {
+	Type EventType
+	Time time.Time
+	// the new block, or the block that confirms the txn
+	Block *coin.Block
+	// the txn of the txn events
+	TxID cipher.SHA256
+	// why the txn is dropped, DropExpired or DropOverflowed
+	Reason string
+	// the reorganization of EventReorg
+	Reorg *ReorgEvent
+	// the output movement of EventAddressActivity
+	Activity *AddressActivity
+}
+
+// subscription receives the events of types, or all events if types is empty
+type subscription struct {
+	types map[EventType]struct{}
+	c     chan Event
+}
+
+func (s subscription) wants(tp EventType) bool {
+	if len(s.types) == 0 {
+		return true
+	}
+	_, ok := s.types[tp]
+	return ok
+}
+
+// EventBus publishes the blockchain events to the in-process subscribers. The events are
+// sent without blocking, an event is dropped for the subscriber whose channel is full.
+type EventBus struct {
+	sync.Mutex
+	subs    map[int]subscription
+	nextID  int
+	dropped uint64
+}
+
+// NewEventBus creates an event bus
+func NewEventBus() *EventBus {
+	return &EventBus{
+		subs: make(map[int]subscription),
+	}
+}
+
+// Subscribe returns the channel receiving the events of the types, all events are received
+// if no type is given. bufSize is the buffer size of the channel. The returned function
+// unsubscribes and closes the channel.
+func (eb *EventBus) Subscribe(bufSize int, types ...EventType) (<-chan Event, func()) {
+	s := subscription{
+		types: make(map[EventType]struct{}, len(types)),
+		c:     make(chan Event, bufSize),
+	}
+	for _, tp := range types {
+		s.types[tp] = struct{}{}
+	}
+
+	eb.Lock()
+	id := eb.nextID
+	eb.nextID++
+	eb.subs[id] = s
+	eb.Unlock()
+
+	var once sync.Once
+	return s.c, func() {
+		once.Do(func() {
+			eb.Lock()
+			delete(eb.subs, id)
+			eb.Unlock()
+			close(s.c)
+		})
+	}
+}
+
+// Publish sends the event to the subscribers of its type, sets the time if it's zero
+func (eb *EventBus) Publish(ev Event) {
+	if ev.Time.IsZero() {
+		ev.Time = utc.Now()
+	}
+
+	eb.Lock()
+	defer eb.Unlock()
+	for _, s := range eb.subs {
+		if !s.wants(ev.Type) {
+			continue
+		}
+
+		select {
+		case s.c <- ev:
+		default:
+			eb.dropped++
+		}
+	}
+}
+
+// Dropped returns the number of the events dropped as the subscribers are not keeping up
+func (eb *EventBus) Dropped() uint64 {
+	eb.Lock()
+	defer eb.Unlock()
+	return eb.dropped
+}
+
+// SubscribeEvents subscribes the blockchain events of the types, see EventBus.Subscribe
+func (vs *Visor) SubscribeEvents(bufSize int, types ...EventType) (<-chan Event, func()) {
+	return vs.events.Subscribe(bufSize, types...)
+}
@@ -0,0 +1,46 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEventBus(t *testing.T) {
+	eb := NewEventBus()
+
+	all, unsubAll := eb.Subscribe(10)
+	txns, unsubTxns := eb.Subscribe(1, EventTxnConfirmed, EventTxnDropped)
+
+	h := randSHA256()
+	eb.Publish(Event{Type: EventNewBlock})
+	eb.Publish(Event{Type: EventTxnConfirmed, TxID: h})
+
+	ev := <-all
+	assert.Equal(t, EventNewBlock, ev.Type)
+	assert.False(t, ev.Time.IsZero())
+	assert.Equal(t, EventTxnConfirmed, (<-all).Type)
+
+	ev = <-txns
+	assert.Equal(t, EventTxnConfirmed, ev.Type)
+	assert.Equal(t, h, ev.TxID)
+
+	// the events are dropped when the channel is full
+	eb.Publish(Event{Type: EventTxnDropped})
+	eb.Publish(Event{Type: EventTxnDropped})
+	assert.Equal(t, uint64(1), eb.Dropped())
+	assert.Equal(t, EventTxnDropped, (<-txns).Type)
+	assert.Len(t, all, 2)
+
+	unsubTxns()
+	unsubTxns()
+	_, ok := <-txns
+	assert.False(t, ok)
+
+	eb.Publish(Event{Type: EventTxnDropped})
+	assert.Len(t, all, 3)
+	assert.Equal(t, uint64(1), eb.Dropped())
+
+	unsubAll()
+	assert.Empty(t, eb.subs)
+}
@@ -0,0 +1,213 @@
+package visor
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+// MaxExportBlocks is the maximum number of blocks that can be exported in one request
+const MaxExportBlocks = 1000
+
+// Types of the output movement in CSV export
+const (
+	MovementReceive = "receive"
+	MovementSpend   = "spend"
+)
+
+// csvHeader is the header row of the exported CSV, each row is an output movement
+var csvHeader = []string{
+	"block_seq",
+	"time",
+	"txid",
+	"type",
+	"uxid",
+	"address",
+	"coins",
+	"hours",
+}
+
+// outputMovement represents an output being received or spent in a block
+type outputMovement struct {
+	BlockSeq uint64
+	Time     uint64
+	TxID     cipher.SHA256
+	Type     string
+	UxID     cipher.SHA256
+	Address  cipher.Address
+	Coins    uint64
+	Hours    uint64
+}
+
+func (om outputMovement) record() []string {
+	return []string{
+		strconv.FormatUint(om.BlockSeq, 10),
+		strconv.FormatUint(om.Time, 10),
+		om.TxID.Hex(),
+		om.Type,
+		om.UxID.Hex(),
+		om.Address.String(),
+		StrBalance(om.Coins),
+		strconv.FormatUint(om.Hours, 10),
+	}
+}
+
+// BlocksCSVRecords returns the CSV records of the output movements of the blocks whose seq
+// are in the range of start and end, the spent inputs are resolved from the history db.
+func (vs *Visor) BlocksCSVRecords(start, end uint64) ([][]string, error) {
+	if start > end {
+		return nil, errors.New("start must not be greater than end")
+	}
+
+	if end-start >= MaxExportBlocks {
+		return nil, fmt.Errorf("at most %d blocks can be exported at once", MaxExportBlocks)
+	}
+
+	headSeq := vs.HeadBkSeq()
+	if end > headSeq {
+		end = headSeq
+	}
+
+	var records [][]string
+	for seq := start; seq <= end; seq++ {
+		b := vs.GetBlockBySeq(seq)
+		if b == nil {
+			return nil, fmt.Errorf("found no block in seq %v", seq)
+		}
+
+		mvs, err := vs.blockMovements(b)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, mv := range mvs {
+			records = append(records, mv.record())
+		}
+	}
+
+	return records, nil
+}
+
+// AddressCSVRecords returns the CSV records of the history of the address, each output
+// owned by the address has a receive row, and a spend row if it was spent.
+func (vs *Visor) AddressCSVRecords(addr cipher.Address) ([][]string, error) {
+	uxs, err := vs.history.GetAddrUxOuts(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mvs := make([]outputMovement, 0, len(uxs)*2)
+	for _, ux := range uxs {
+		mv := newOutputMovement(ux, MovementReceive)
+		mv.BlockSeq = ux.Out.Head.BkSeq
+		mv.Time = ux.Out.Head.Time
+		mv.TxID = ux.Out.Body.SrcTransaction
+		mvs = append(mvs, mv)
+
+		if ux.SpentTxID == (cipher.SHA256{}) {
+			continue
+		}
+
+		b := vs.GetBlockBySeq(ux.SpentBlockSeq)
+		if b == nil {
+			return nil, fmt.Errorf("found no block in seq %v", ux.SpentBlockSeq)
+		}
+
+		mv = newOutputMovement(ux, MovementSpend)
+		mv.BlockSeq = ux.SpentBlockSeq
+		mv.Time = b.Time()
+		mv.TxID = ux.SpentTxID
+		mvs = append(mvs, mv)
+	}
+
+	sort.Stable(byBlockSeq(mvs))
+
+	records := make([][]string, len(mvs))
+	for i, mv := range mvs {
+		records[i] = mv.record()
+	}
+	return records, nil
+}
+
+// WriteCSV writes the header and the records to w as CSV
+func WriteCSV(w io.Writer, records [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(csvHeader); err != nil {
+		return err
+	}
+
+	if err := cw.WriteAll(records); err != nil {
+		return err
+	}
+
+	return cw.Error()
+}
+
+// blockMovements returns the spend movements of the inputs, and the receive movements of
+// the outputs of each transaction in the block.
+func (vs *Visor) blockMovements(b *coin.Block) ([]outputMovement, error) {
+	var mvs []outputMovement
+	for _, txn := range b.Body.Transactions {
+		txid := txn.Hash()
+		for _, in := range txn.In {
+			ux, err := vs.history.GetUxout(in)
+			if err != nil {
+				return nil, err
+			}
+
+			if ux == nil {
+				return nil, fmt.Errorf("found no uxout of id %v", in.Hex())
+			}
+
+			mv := newOutputMovement(ux, MovementSpend)
+			mv.BlockSeq = b.Seq()
+			mv.Time = b.Time()
+			mv.TxID = txid
+			mvs = append(mvs, mv)
+		}
+
+		// the outputs of genesis transaction have empty source transaction
+		srcTx := txid
+		if b.Seq() == 0 {
+			srcTx = cipher.SHA256{}
+		}
+
+		for _, out := range txn.Out {
+			mvs = append(mvs, outputMovement{
+				BlockSeq: b.Seq(),
+				Time:     b.Time(),
+				TxID:     txid,
+				Type:     MovementReceive,
+				UxID:     out.UxID(srcTx),
+				Address:  out.Address,
+				Coins:    out.Coins,
+				Hours:    out.Hours,
+			})
+		}
+	}
+
+	return mvs, nil
+}
+
+func newOutputMovement(ux *historydb.UxOut, tp string) outputMovement {
+	return outputMovement{
+		Type:    tp,
+		UxID:    ux.Hash(),
+		Address: ux.Out.Body.Address,
+		Coins:   ux.Out.Body.Coins,
+		Hours:   ux.Out.Body.Hours,
+	}
+}
+
+type byBlockSeq []outputMovement
+
+func (bs byBlockSeq) Len() int           { return len(bs) }
+func (bs byBlockSeq) Swap(i, j int)      { bs[i], bs[j] = bs[j], bs[i] }
+func (bs byBlockSeq) Less(i, j int) bool { return bs[i].BlockSeq < bs[j].BlockSeq }
@@ -0,0 +1,36 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestOutputMovementRecord(t *testing.T) {
+	p, _ := cipher.GenerateKeyPair()
+	mv := outputMovement{
+		BlockSeq: 10,
+		Time:     1502870712,
+		TxID:     randSHA256(),
+		Type:     MovementSpend,
+		UxID:     randSHA256(),
+		Address:  cipher.AddressFromPubKey(p),
+		Coins:    1500000,
+		Hours:    20,
+	}
+
+	rec := mv.record()
+	assert.Len(t, rec, len(csvHeader))
+	assert.Equal(t, []string{
+		"10",
+		"1502870712",
+		mv.TxID.Hex(),
+		"spend",
+		mv.UxID.Hex(),
+		mv.Address.String(),
+		"1.5",
+		"20",
+	}, rec)
+}
@@ -0,0 +1,180 @@
+package visor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+const (
+	// FeeStatsBlocks is the number of recent blocks the rolling fee statistics are
+	// computed from
+	FeeStatsBlocks = 50
+	// MaxFeeTargetBlocks is the max number of blocks a fee can be estimated for
+	MaxFeeTargetBlocks = 100
+)
+
+// FeeStats is the statistics of the coin hour fee per byte of a set of txns
+type FeeStats struct {
+	Txns  int `json:"txns"`
+	Bytes int `json:"bytes"`
+	// the median and the mean of the fee per byte of the txns
+	Median float64 `json:"median"`
+	Mean   float64 `json:"mean"`
+	Min    float64 `json:"min"`
+	Max    float64 `json:"max"`
+}
+
+// BlockFeeStats is the fee statistics of the txns in a block
+type BlockFeeStats struct {
+	Seq  uint64 `json:"seq"`
+	Time uint64 `json:"time"`
+	FeeStats
+}
+
+// FeeEstimate is the coin hour fee per byte estimated to get a txn confirmed in the target
+// number of blocks, with the statistics it's estimated from. The fee required by the
+// BurnFactor must still be paid, the fee per byte decides the priority of the txn.
+type FeeEstimate struct {
+	TargetBlocks int     `json:"target_blocks"`
+	FeePerByte   float64 `json:"fee_per_byte"`
+	// whether the unconfirmed txns are more than the target blocks can hold, the estimate
+	// is from the unconfirmed txns if it's true, or from the recent blocks otherwise
+	Congested   bool     `json:"congested"`
+	Unconfirmed FeeStats `json:"unconfirmed"`
+	Recent      FeeStats `json:"recent"`
+	// the recent blocks, the newest first
+	Blocks []BlockFeeStats `json:"blocks"`
+}
+
+// txnFeeRate is the fee and the size of a txn
+type txnFeeRate struct {
+	fee  uint64
+	size int
+}
+
+func (r txnFeeRate) perByte() float64 {
+	return float64(r.fee) / float64(r.size)
+}
+
+// byFeeRate sorts the txns by fee per byte, the highest first
+type byFeeRate []txnFeeRate
+
+func (fr byFeeRate) Len() int           { return len(fr) }
+func (fr byFeeRate) Swap(i, j int)      { fr[i], fr[j] = fr[j], fr[i] }
+func (fr byFeeRate) Less(i, j int) bool { return fr[i].perByte() > fr[j].perByte() }
+
+// newFeeStats computes the statistics of the fee rates, rates are sorted in place
+func newFeeStats(rates []txnFeeRate) FeeStats {
+	var fs FeeStats
+	if len(rates) == 0 {
+		return fs
+	}
+
+	sort.Sort(byFeeRate(rates))
+
+	var sum float64
+	for _, r := range rates {
+		fs.Bytes += r.size
+		sum += r.perByte()
+	}
+
+	n := len(rates)
+	fs.Txns = n
+	fs.Mean = sum / float64(n)
+	fs.Max = rates[0].perByte()
+	fs.Min = rates[n-1].perByte()
+	if n%2 == 1 {
+		fs.Median = rates[n/2].perByte()
+	} else {
+		fs.Median = (rates[n/2-1].perByte() + rates[n/2].perByte()) / 2
+	}
+	return fs
+}
+
+// congestionFeeRate returns the fee per byte of the txn that fills up the bytes when the
+// txns are packed in the order of fee per byte, rates must be sorted by byFeeRate. Returns
+// false if the txns don't fill up the bytes.
+func congestionFeeRate(rates []txnFeeRate, bytes int) (float64, bool) {
+	var total int
+	for _, r := range rates {
+		total += r.size
+		if total >= bytes {
+			return r.perByte(), true
+		}
+	}
+	return 0, false
+}
+
+// blockFeeRates returns the fee rates of the txns in the block, the txns whose inputs are
+// not yet indexed by the history db are skipped
+func (vs *Visor) blockFeeRates(b *coin.Block) []txnFeeRate {
+	// genesis transaction has no fee
+	if b.Seq() == 0 {
+		return nil
+	}
+
+	rates := make([]txnFeeRate, 0, len(b.Body.Transactions))
+	for i := range b.Body.Transactions {
+		txn := &b.Body.Transactions[i]
+		fee, err := vs.confirmedTxnFee(txn, b.Seq())
+		if err != nil {
+			logger.Debug("Compute fee of txn %s failed: %v", txn.Hash().Hex(), err)
+			continue
+		}
+		rates = append(rates, txnFeeRate{fee: fee, size: txn.Size()})
+	}
+	return rates
+}
+
+// unconfirmedFeeRates returns the fee rates of the unconfirmed txns whose fee can be computed
+func (vs *Visor) unconfirmedFeeRates() []txnFeeRate {
+	txns := vs.Unconfirmed.RawTxns()
+	rates := make([]txnFeeRate, 0, len(txns))
+	for i := range txns {
+		fee, err := vs.Blockchain.TransactionFee(&txns[i])
+		if err != nil {
+			continue
+		}
+		rates = append(rates, txnFeeRate{fee: fee, size: txns[i].Size()})
+	}
+	return rates
+}
+
+// GetFeeEstimate estimates the fee per byte to get a txn confirmed in targetBlocks blocks,
+// from the unconfirmed txns and the txns of the recent FeeStatsBlocks blocks.
+func (vs *Visor) GetFeeEstimate(targetBlocks int) (*FeeEstimate, error) {
+	if targetBlocks < 1 || targetBlocks > MaxFeeTargetBlocks {
+		return nil, fmt.Errorf("target blocks must be in [1, %d]", MaxFeeTargetBlocks)
+	}
+
+	fe := &FeeEstimate{TargetBlocks: targetBlocks}
+
+	var recent []txnFeeRate
+	head := vs.HeadBkSeq()
+	for i := uint64(0); i < FeeStatsBlocks && i <= head; i++ {
+		b := vs.GetBlockBySeq(head - i)
+		if b == nil {
+			return nil, fmt.Errorf("found no block in seq %v", head-i)
+		}
+
+		rates := vs.blockFeeRates(b)
+		recent = append(recent, rates...)
+		fe.Blocks = append(fe.Blocks, BlockFeeStats{
+			Seq:      b.Seq(),
+			Time:     b.Time(),
+			FeeStats: newFeeStats(rates),
+		})
+	}
+	fe.Recent = newFeeStats(recent)
+
+	unconfirmed := vs.unconfirmedFeeRates()
+	fe.Unconfirmed = newFeeStats(unconfirmed)
+
+	fe.FeePerByte, fe.Congested = congestionFeeRate(unconfirmed, targetBlocks*vs.Config.MaxBlockSize)
+	if !fe.Congested {
+		fe.FeePerByte = fe.Recent.Median
+	}
+	return fe, nil
+}
@@ -0,0 +1,49 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFeeStats(t *testing.T) {
+	assert.Equal(t, FeeStats{}, newFeeStats(nil))
+
+	rates := []txnFeeRate{
+		{fee: 100, size: 100},
+		{fee: 400, size: 100},
+		{fee: 200, size: 200},
+		{fee: 900, size: 300},
+	}
+	fs := newFeeStats(rates)
+	assert.Equal(t, FeeStats{
+		Txns:   4,
+		Bytes:  700,
+		Median: 2,
+		Mean:   2.25,
+		Min:    1,
+		Max:    4,
+	}, fs)
+
+	// sorted by fee per byte, the highest first
+	assert.Equal(t, []txnFeeRate{
+		{fee: 400, size: 100},
+		{fee: 900, size: 300},
+		{fee: 100, size: 100},
+		{fee: 200, size: 200},
+	}, rates)
+
+	fs = newFeeStats(rates[:3])
+	assert.Equal(t, 3.0, fs.Median)
+
+	rate, ok := congestionFeeRate(rates, 400)
+	assert.True(t, ok)
+	assert.Equal(t, 3.0, rate)
+
+	rate, ok = congestionFeeRate(rates, 401)
+	assert.True(t, ok)
+	assert.Equal(t, 1.0, rate)
+
+	_, ok = congestionFeeRate(rates, 701)
+	assert.False(t, ok)
+}
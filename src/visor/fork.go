@@ -0,0 +1,371 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/utc"
+)
+
+// maxCompetingBlocks is the max number of competing blocks kept, the earliest stored
+// block will be removed when the limit is reached.
+const maxCompetingBlocks = 1000
+
+// maxReorgEvents is the max number of the recent reorg events kept
+const maxReorgEvents = 100
+
+// ErrBlockNotConnected is returned when the signed block doesn't extend the head, the block
+// is kept, and the chain is reorganized once the block is in a longer branch.
+var ErrBlockNotConnected = errors.New("Block does not extend the head, kept as competing block")
+
+// ReorgEvent records a reorganization of the chain
+type ReorgEvent struct {
+	Time time.Time `json:"time"`
+	// seq of the last block shared by the old and new chain
+	ForkSeq uint64 `json:"fork_seq"`
+	OldHead string `json:"old_head"`
+	NewHead string `json:"new_head"`
+	// blocks removed from the chain, in the order of being rolled back
+	Disconnected []ReadableBlock `json:"disconnected"`
+}
+
+// competingBlocks holds the signed blocks that don't extend the head of the chain
+type competingBlocks struct {
+	blocks map[cipher.SHA256]coin.SignedBlock
+	// hashes in the order of being added, for removing the earliest ones
+	order []cipher.SHA256
+	max   int
+}
+
+func newCompetingBlocks(max int) *competingBlocks {
+	return &competingBlocks{
+		blocks: make(map[cipher.SHA256]coin.SignedBlock),
+		max:    max,
+	}
+}
+
+// add stores the block, returns false if it's already stored
+func (cb *competingBlocks) add(b coin.SignedBlock) bool {
+	h := b.Block.HashHeader()
+	if _, ok := cb.blocks[h]; ok {
+		return false
+	}
+
+	for len(cb.order) > 0 && len(cb.order) >= cb.max {
+		delete(cb.blocks, cb.order[0])
+		cb.order = cb.order[1:]
+	}
+
+	cb.blocks[h] = b
+	cb.order = append(cb.order, h)
+	return true
+}
+
+// remove removes the block of hash if it's stored
+func (cb *competingBlocks) remove(hash cipher.SHA256) {
+	if _, ok := cb.blocks[hash]; !ok {
+		return
+	}
+
+	delete(cb.blocks, hash)
+	for i, h := range cb.order {
+		if h == hash {
+			cb.order = append(cb.order[:i], cb.order[i+1:]...)
+			break
+		}
+	}
+}
+
+// longestBranch returns the branch of the stored blocks that reaches the highest seq, the
+// parent of the first block must be in the chain, seqOf returns the seq of the block in
+// the chain. Returns the seq of the parent, and the blocks in the order of execution.
+func (cb *competingBlocks) longestBranch(seqOf func(cipher.SHA256) (uint64, bool)) (uint64, []coin.SignedBlock) {
+	children := make(map[cipher.SHA256][]cipher.SHA256)
+	for h, b := range cb.blocks {
+		children[b.Block.Head.PrevHash] = append(children[b.Block.Head.PrevHash], h)
+	}
+
+	// returns the longest path of blocks starting from h
+	var walk func(h cipher.SHA256) []cipher.SHA256
+	walk = func(h cipher.SHA256) []cipher.SHA256 {
+		var longest []cipher.SHA256
+		for _, c := range children[h] {
+			if cb.blocks[c].Block.Seq() != cb.blocks[h].Block.Seq()+1 {
+				continue
+			}
+
+			if p := walk(c); len(p) > len(longest) {
+				longest = p
+			}
+		}
+		return append([]cipher.SHA256{h}, longest...)
+	}
+
+	var (
+		forkSeq uint64
+		path    []cipher.SHA256
+	)
+	for h, b := range cb.blocks {
+		seq, ok := seqOf(b.Block.Head.PrevHash)
+		if !ok || b.Block.Seq() != seq+1 {
+			continue
+		}
+
+		if p := walk(h); path == nil || seq+uint64(len(p)) > forkSeq+uint64(len(path)) {
+			forkSeq = seq
+			path = p
+		}
+	}
+
+	branch := make([]coin.SignedBlock, len(path))
+	for i, h := range path {
+		branch[i] = cb.blocks[h]
+	}
+	return forkSeq, branch
+}
+
+// isCompeting returns whether the block is at a seq that the chain has reached, but doesn't
+// extend the head. The blocks beyond the head are not competing, they are out of order.
+func (vs *Visor) isCompeting(b coin.Block) bool {
+	head := vs.Blockchain.Head()
+	if head == nil || b.Seq() == 0 || b.Seq() > head.Seq()+1 {
+		return false
+	}
+
+	return b.Seq() <= head.Seq() || b.Head.PrevHash != head.HashHeader()
+}
+
+// addCompetingBlock keeps the block, and reorganizes the chain if the block makes a longer
+// branch than the chain.
+func (vs *Visor) addCompetingBlock(b coin.SignedBlock) error {
+	if _, ok := vs.blockIndex.get(b.Block.HashHeader()); ok {
+		return errors.New("Block is already in the chain")
+	}
+
+	// the history below the highest checkpoint reached can't be changed
+	cpSeq, hasCp := vs.checkpoints.highest(vs.HeadBkSeq())
+	if hasCp && b.Block.Seq() <= cpSeq {
+		return ErrCheckpointMismatch
+	}
+
+	vs.competing.add(b)
+
+	forkSeq, branch := vs.competing.longestBranch(vs.blockIndex.get)
+	if len(branch) == 0 || forkSeq+uint64(len(branch)) <= vs.HeadBkSeq() {
+		return ErrBlockNotConnected
+	}
+
+	if hasCp && forkSeq < cpSeq {
+		return ErrCheckpointMismatch
+	}
+
+	return vs.reorg(forkSeq, branch)
+}
+
+// reorg rolls the chain back to forkSeq and executes the branch, the rolled back blocks are
+// restored if any block of the branch fails.
+func (vs *Visor) reorg(forkSeq uint64, branch []coin.SignedBlock) error {
+	oldHead := vs.Blockchain.Head()
+
+	// resolves the spent outputs before rolling back, so that the chain is not changed
+	// if any of them is missing
+	var (
+		disconnected []coin.Block
+		spents       []coin.UxArray
+	)
+	for seq := oldHead.Seq(); seq > forkSeq; seq-- {
+		b := vs.GetBlockBySeq(seq)
+		if b == nil {
+			return fmt.Errorf("found no block in seq %v", seq)
+		}
+
+		spent, err := vs.spentOutputs(b)
+		if err != nil {
+			return err
+		}
+
+		disconnected = append(disconnected, *b)
+		spents = append(spents, spent)
+	}
+
+	for i := range disconnected {
+		if _, err := vs.Blockchain.RollbackHead(spents[i]); err != nil {
+			return restoreFailed(err, vs.restoreChain(nil, nil, disconnected[:i]))
+		}
+	}
+
+	var (
+		connected      []coin.Block
+		connectedSpent []coin.UxArray
+	)
+	for i, sb := range branch {
+		// the spent outputs are kept for rolling back the branch
+		spent, err := vs.Blockchain.Unspent().GetArray(spentInputs(&sb.Block))
+		if err == nil {
+			err = vs.executeSignedBlock(sb)
+		}
+
+		if err != nil {
+			logger.Error("Execute block %v of the branch failed: %v", sb.Block.Seq(), err)
+			for _, bad := range branch[i:] {
+				vs.competing.remove(bad.Block.HashHeader())
+			}
+			return restoreFailed(err, vs.restoreChain(connected, connectedSpent, disconnected))
+		}
+
+		vs.competing.remove(sb.Block.HashHeader())
+		connected = append(connected, sb.Block)
+		connectedSpent = append(connectedSpent, spent)
+	}
+
+	vs.afterReorg(disconnected, connected)
+
+	newHead := vs.Blockchain.Head()
+	ev := ReorgEvent{
+		Time:         utc.Now(),
+		ForkSeq:      forkSeq,
+		OldHead:      oldHead.HashHeader().Hex(),
+		NewHead:      newHead.HashHeader().Hex(),
+		Disconnected: make([]ReadableBlock, len(disconnected)),
+	}
+	for i := range disconnected {
+		ev.Disconnected[i] = NewReadableBlock(&disconnected[i])
+	}
+
+	if len(vs.reorgs) >= maxReorgEvents {
+		vs.reorgs = vs.reorgs[1:]
+	}
+	vs.reorgs = append(vs.reorgs, ev)
+	reorgsCounter.Inc()
+	vs.events.Publish(Event{Type: EventReorg, Reorg: &ev})
+
+	logger.Info("Chain reorganized at seq %v, %v blocks disconnected, new head %v",
+		forkSeq, len(disconnected), newHead.Seq())
+	return nil
+}
+
+// afterReorg keeps the disconnected blocks as competing blocks, so that the chain can switch
+// back, and returns their txns that are not in the new branch to the unconfirmed pool.
+func (vs *Visor) afterReorg(disconnected, connected []coin.Block) {
+	confirmed := make(map[cipher.SHA256]struct{})
+	for _, b := range connected {
+		for _, txn := range b.Body.Transactions {
+			confirmed[txn.Hash()] = struct{}{}
+		}
+	}
+
+	for i := range disconnected {
+		b := disconnected[i]
+		if sig, err := vs.blockSigs.Get(b.HashHeader()); err == nil {
+			vs.competing.add(coin.SignedBlock{Block: b, Sig: sig})
+		}
+
+		for _, txn := range b.Body.Transactions {
+			if _, ok := confirmed[txn.Hash()]; ok {
+				continue
+			}
+
+			if _, err := vs.InjectTxn(txn); err != nil {
+				logger.Info("Drop txn %v of the disconnected block: %v", txn.Hash().Hex(), err)
+			}
+		}
+	}
+}
+
+// restoreChain rolls back the connected blocks of the branch, and executes the disconnected
+// blocks again, the blocks are in the order of the chain being rolled back. Returns error if
+// the chain can't be restored, the chain is left in between the two branches then.
+func (vs *Visor) restoreChain(connected []coin.Block, connectedSpent []coin.UxArray, disconnected []coin.Block) error {
+	for i := len(connected) - 1; i >= 0; i-- {
+		if _, err := vs.Blockchain.RollbackHead(connectedSpent[i]); err != nil {
+			logger.Critical("Roll back block %v failed: %v", connected[i].Seq(), err)
+			return fmt.Errorf("roll back block %v failed: %v", connected[i].Seq(), err)
+		}
+	}
+
+	for i := len(disconnected) - 1; i >= 0; i-- {
+		if err := vs.Blockchain.ExecuteBlock(&disconnected[i]); err != nil {
+			logger.Critical("Restore block %v failed: %v", disconnected[i].Seq(), err)
+			return fmt.Errorf("restore block %v failed: %v", disconnected[i].Seq(), err)
+		}
+	}
+	return nil
+}
+
+// restoreFailed returns the error of the reorg, with the error of restoring the chain if any
+func restoreFailed(err, restoreErr error) error {
+	if restoreErr == nil {
+		return err
+	}
+	return fmt.Errorf("%v, and restoring the chain failed: %v", err, restoreErr)
+}
+
+// spentOutputs returns the outputs spent by the block, excluding the ones created by the
+// block itself. They're recorded by the unspent pool when the block is executed, the blocks
+// executed before that are resolved from the history db, which must have parsed the block.
+func (vs *Visor) spentOutputs(b *coin.Block) (coin.UxArray, error) {
+	ins := blockInputs(b)
+	if len(ins) == 0 {
+		return nil, nil
+	}
+
+	uxs, ok, err := vs.Blockchain.Unspent().GetBlockSpent(b.HashHeader())
+	if err != nil || ok {
+		return uxs, err
+	}
+
+	if vs.history.ParsedHeight() < int64(b.Seq()) {
+		return nil, fmt.Errorf("the spent outputs of block %v are not recorded or parsed yet", b.Seq())
+	}
+
+	uxs = nil
+	for _, in := range ins {
+		ux, err := vs.history.GetUxout(in)
+		if err != nil {
+			return nil, err
+		}
+
+		if ux == nil {
+			return nil, fmt.Errorf("found no uxout of id %v", in.Hex())
+		}
+
+		if ux.Out.Head.BkSeq != b.Seq() {
+			uxs = append(uxs, ux.Out)
+		}
+	}
+	return uxs, nil
+}
+
+// spentInputs returns the inputs of the block, excluding the outputs created by the block
+func spentInputs(b *coin.Block) []cipher.SHA256 {
+	created := make(map[cipher.SHA256]struct{})
+	for _, txn := range b.Body.Transactions {
+		for _, ux := range coin.CreateUnspents(b.Head, txn) {
+			created[ux.Hash()] = struct{}{}
+		}
+	}
+
+	var ins []cipher.SHA256
+	for _, in := range blockInputs(b) {
+		if _, ok := created[in]; !ok {
+			ins = append(ins, in)
+		}
+	}
+	return ins
+}
+
+func blockInputs(b *coin.Block) []cipher.SHA256 {
+	var ins []cipher.SHA256
+	for _, txn := range b.Body.Transactions {
+		ins = append(ins, txn.In...)
+	}
+	return ins
+}
+
+// GetReorgEvents returns the recent reorganizations of the chain since the node started
+func (vs *Visor) GetReorgEvents() []ReorgEvent {
+	return append([]ReorgEvent{}, vs.reorgs...)
+}
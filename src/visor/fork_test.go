@@ -0,0 +1,92 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestCompetingBlocks(t *testing.T) {
+	newBlock := func(prev cipher.SHA256, seq uint64) coin.SignedBlock {
+		return coin.SignedBlock{
+			Block: coin.Block{
+				Head: coin.BlockHeader{
+					BkSeq:    seq,
+					PrevHash: prev,
+					BodyHash: randSHA256(),
+				},
+			},
+		}
+	}
+	hash := func(b coin.SignedBlock) cipher.SHA256 {
+		return b.Block.HashHeader()
+	}
+
+	// the chain has blocks of seq 0 to 3
+	chain := map[cipher.SHA256]uint64{}
+	var chainHashes []cipher.SHA256
+	for i := uint64(0); i < 4; i++ {
+		h := randSHA256()
+		chain[h] = i
+		chainHashes = append(chainHashes, h)
+	}
+	seqOf := func(h cipher.SHA256) (uint64, bool) {
+		seq, ok := chain[h]
+		return seq, ok
+	}
+
+	cb := newCompetingBlocks(10)
+	forkSeq, branch := cb.longestBranch(seqOf)
+	assert.Equal(t, uint64(0), forkSeq)
+	assert.Empty(t, branch)
+
+	// branch a forks at seq 1, reaches seq 3
+	a2 := newBlock(chainHashes[1], 2)
+	a3 := newBlock(hash(a2), 3)
+	assert.True(t, cb.add(a2))
+	assert.False(t, cb.add(a2))
+	assert.True(t, cb.add(a3))
+
+	// branch b forks at seq 2, reaches seq 4
+	b3 := newBlock(chainHashes[2], 3)
+	b4 := newBlock(hash(b3), 4)
+	assert.True(t, cb.add(b3))
+	assert.True(t, cb.add(b4))
+
+	// a block whose parent is unknown
+	assert.True(t, cb.add(newBlock(randSHA256(), 5)))
+
+	// a block with the wrong seq is not in the branch
+	assert.True(t, cb.add(newBlock(hash(a3), 6)))
+
+	forkSeq, branch = cb.longestBranch(seqOf)
+	assert.Equal(t, uint64(2), forkSeq)
+	assert.Equal(t, []coin.SignedBlock{b3, b4}, branch)
+
+	// branch a becomes the longest
+	a4 := newBlock(hash(a3), 4)
+	a5 := newBlock(hash(a4), 5)
+	assert.True(t, cb.add(a4))
+	assert.True(t, cb.add(a5))
+
+	forkSeq, branch = cb.longestBranch(seqOf)
+	assert.Equal(t, uint64(1), forkSeq)
+	assert.Equal(t, []coin.SignedBlock{a2, a3, a4, a5}, branch)
+
+	cb.remove(hash(a4))
+	assert.Len(t, cb.order, 7)
+	forkSeq, branch = cb.longestBranch(seqOf)
+	assert.Equal(t, uint64(2), forkSeq)
+	assert.Equal(t, []coin.SignedBlock{b3, b4}, branch)
+
+	// the earliest added blocks are removed when the limit is reached
+	cb = newCompetingBlocks(2)
+	assert.True(t, cb.add(a2))
+	assert.True(t, cb.add(a3))
+	assert.True(t, cb.add(b3))
+	assert.Len(t, cb.blocks, 2)
+	assert.Equal(t, []cipher.SHA256{hash(a3), hash(b3)}, cb.order)
+}
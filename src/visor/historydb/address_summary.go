@@ -0,0 +1,185 @@
+package historydb
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+)
+
+var (
+	addressSummaryBktName     = []byte("address_summary")
+	addressSummaryUndoBktName = []byte("address_summary_undo")
+)
+
+// summaryUndoDepth is the number of recent blocks whose address summary changes can be
+// undone when the chain is reorganized
+const summaryUndoDepth = 1000
+
+// AddressSummary is the aggregated activity of an address in the parsed blocks
+type AddressSummary struct {
+	// seq of the block the address first appears in
+	FirstSeen uint64
+	// seq of the last block the address receives or spends outputs in
+	LastActive uint64
+	// coins received and sent in droplets, the change is counted as both
+	Received uint64
+	Sent     uint64
+	// number of the txns the address appears in
+	TxnCount uint64
+}
+
+// summaryUndo is the summary of an address before a block is parsed, Existed is false if
+// the address hadn't appeared
+type summaryUndo struct {
+	Address cipher.Address
+	Existed bool
+	Summary AddressSummary
+}
+
+// addressSummaries buckets for storing the address summaries, address as key, and the
+// summaries before each of the recent blocks is parsed, block seq as key
+type addressSummaries struct {
+	bkt  *bucket.Bucket
+	undo *bucket.Bucket
+}
+
+func newAddressSummariesBkt(db *bolt.DB) (*addressSummaries, error) {
+	bkt, err := bucket.New(addressSummaryBktName, db)
+	if err != nil {
+		return nil, err
+	}
+
+	undo, err := bucket.New(addressSummaryUndoBktName, db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &addressSummaries{bkt: bkt, undo: undo}, nil
+}
+
+// Get returns the summary of the address, returns nil if the address never appears
+func (as *addressSummaries) Get(addr cipher.Address) (*AddressSummary, error) {
+	v := as.bkt.Get(addr.Bytes())
+	if v == nil {
+		return nil, nil
+	}
+
+	var s AddressSummary
+	if err := encoder.DeserializeRaw(v, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// IsEmpty checks if address summary bucket is empty
+func (as *addressSummaries) IsEmpty() bool {
+	return as.bkt.IsEmpty()
+}
+
+// Reset resets the buckets
+func (as *addressSummaries) Reset() error {
+	if err := as.bkt.Reset(); err != nil {
+		return err
+	}
+	return as.undo.Reset()
+}
+
+// restoreAddressSummaries sets the summaries back to the ones before the block seq was
+// parsed, and removes the undo records of the blocks from seq. The records of the blocks
+// older than summaryUndoDepth are pruned, the blocks can't be restored.
+func restoreAddressSummaries(bkt, undoBkt *bolt.Bucket, seq uint64) error {
+	// the undo records are keyed by big endian seq, restore the latest first
+	c := undoBkt.Cursor()
+	for k, v := c.Last(); k != nil && bucket.Btoi(k) >= seq; k, v = c.Last() {
+		var undos []summaryUndo
+		if err := encoder.DeserializeRaw(v, &undos); err != nil {
+			return err
+		}
+
+		for _, u := range undos {
+			if !u.Existed {
+				if err := bkt.Delete(u.Address.Bytes()); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := bkt.Put(u.Address.Bytes(), encoder.Serialize(u.Summary)); err != nil {
+				return err
+			}
+		}
+
+		if err := undoBkt.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addressActivity is the coins an address receives and sends in a txn
+type addressActivity struct {
+	received uint64
+	sent     uint64
+}
+
+// updateAddressSummaries applies the activities of the addresses in a txn of the block seq,
+// the summaries before the block are saved in the undo record of the block
+func updateAddressSummaries(bkt, undoBkt *bolt.Bucket, seq uint64, acts map[cipher.Address]*addressActivity) error {
+	undoKey := bucket.Itob(seq)
+	var undos []summaryUndo
+	if v := undoBkt.Get(undoKey); v != nil {
+		if err := encoder.DeserializeRaw(v, &undos); err != nil {
+			return err
+		}
+	}
+
+	saved := make(map[cipher.Address]struct{}, len(undos))
+	for _, u := range undos {
+		saved[u.Address] = struct{}{}
+	}
+
+	for addr, act := range acts {
+		var s AddressSummary
+		v := bkt.Get(addr.Bytes())
+		if v != nil {
+			if err := encoder.DeserializeRaw(v, &s); err != nil {
+				return err
+			}
+		}
+
+		if _, ok := saved[addr]; !ok {
+			undos = append(undos, summaryUndo{Address: addr, Existed: v != nil, Summary: s})
+			saved[addr] = struct{}{}
+		}
+
+		if v == nil {
+			s.FirstSeen = seq
+		}
+		s.LastActive = seq
+		s.Received += act.received
+		s.Sent += act.sent
+		s.TxnCount++
+
+		if err := bkt.Put(addr.Bytes(), encoder.Serialize(s)); err != nil {
+			return err
+		}
+	}
+
+	if err := undoBkt.Put(undoKey, encoder.Serialize(undos)); err != nil {
+		return err
+	}
+
+	// prunes the undo records that are too old
+	if seq < summaryUndoDepth {
+		return nil
+	}
+
+	c := undoBkt.Cursor()
+	for k, _ := c.First(); k != nil && bucket.Btoi(k) <= seq-summaryUndoDepth; k, _ = c.First() {
+		if err := undoBkt.Delete(k); err != nil {
+			return err
+		}
+	}
+	return nil
+}
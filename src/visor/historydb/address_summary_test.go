@@ -0,0 +1,71 @@
+package historydb
+
+import (
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAddressSummaries(t *testing.T) {
+	db, td, err := setup(t)
+	require.Nil(t, err)
+
+	defer td()
+
+	as, err := newAddressSummariesBkt(db)
+	require.Nil(t, err)
+
+	a, b := makeAddress(), makeAddress()
+	update := func(seq uint64, acts map[cipher.Address]*addressActivity) {
+		require.Nil(t, db.Update(func(tx *bolt.Tx) error {
+			return updateAddressSummaries(tx.Bucket(as.bkt.Name), tx.Bucket(as.undo.Name), seq, acts)
+		}))
+	}
+
+	restore := func(seq uint64) {
+		require.Nil(t, db.Update(func(tx *bolt.Tx) error {
+			return restoreAddressSummaries(tx.Bucket(as.bkt.Name), tx.Bucket(as.undo.Name), seq)
+		}))
+	}
+
+	get := func(addr cipher.Address) *AddressSummary {
+		s, err := as.Get(addr)
+		require.Nil(t, err)
+		return s
+	}
+
+	require.True(t, as.IsEmpty())
+	require.Nil(t, get(a))
+
+	update(1, map[cipher.Address]*addressActivity{a: {received: 100}})
+	// two txns in block 2
+	update(2, map[cipher.Address]*addressActivity{a: {sent: 100, received: 40}, b: {received: 60}})
+	update(2, map[cipher.Address]*addressActivity{b: {sent: 60}})
+
+	require.Equal(t, &AddressSummary{FirstSeen: 1, LastActive: 2, Received: 140, Sent: 100, TxnCount: 2}, get(a))
+	require.Equal(t, &AddressSummary{FirstSeen: 2, LastActive: 2, Received: 60, Sent: 60, TxnCount: 2}, get(b))
+
+	// rolls back block 2
+	restore(2)
+	require.Equal(t, &AddressSummary{FirstSeen: 1, LastActive: 1, Received: 100, TxnCount: 1}, get(a))
+	require.Nil(t, get(b))
+	require.Equal(t, 1, as.undo.Len())
+
+	// restoring the blocks that are not parsed changes nothing
+	restore(2)
+	require.Equal(t, 1, as.undo.Len())
+
+	// the old undo records are pruned
+	update(summaryUndoDepth+1, map[cipher.Address]*addressActivity{b: {received: 10}})
+	require.Equal(t, 1, as.undo.Len())
+	require.Equal(t, &AddressSummary{FirstSeen: summaryUndoDepth + 1, LastActive: summaryUndoDepth + 1, Received: 10, TxnCount: 1}, get(b))
+
+	restore(0)
+	require.Nil(t, get(b))
+	require.Equal(t, &AddressSummary{FirstSeen: 1, LastActive: 1, Received: 100, TxnCount: 1}, get(a))
+
+	require.Nil(t, as.Reset())
+	require.True(t, as.IsEmpty())
+}
@@ -74,3 +74,23 @@ func setAddressTxns(bkt *bolt.Bucket, addr cipher.Address, hash cipher.SHA256) e
 	bin := encoder.Serialize(hashes)
 	return bkt.Put(addrBytes, bin)
 }
+
+// removeAddressTxns removes the transaction hash from the transactions of the address
+func removeAddressTxns(bkt *bolt.Bucket, addr cipher.Address, hash cipher.SHA256) error {
+	addrBytes := addr.Bytes()
+	v := bkt.Get(addrBytes)
+	if v == nil {
+		return nil
+	}
+
+	var hashes []cipher.SHA256
+	if err := encoder.DeserializeRaw(v, &hashes); err != nil {
+		return err
+	}
+
+	hashes = removeHash(hashes, hash)
+	if len(hashes) == 0 {
+		return bkt.Delete(addrBytes)
+	}
+	return bkt.Put(addrBytes, encoder.Serialize(hashes))
+}
@@ -89,3 +89,33 @@ func setAddressUx(bkt *bolt.Bucket, addr cipher.Address, uxHash cipher.SHA256) e
 	uxHashes = append(uxHashes, uxHash)
 	return bkt.Put(addr.Bytes(), encoder.Serialize(uxHashes))
 }
+
+// removeAddressUx removes the uxout hash from the uxouts of the address
+func removeAddressUx(bkt *bolt.Bucket, addr cipher.Address, uxHash cipher.SHA256) error {
+	bin := bkt.Get(addr.Bytes())
+	if bin == nil {
+		return nil
+	}
+
+	uxHashes := []cipher.SHA256{}
+	if err := encoder.DeserializeRaw(bin, &uxHashes); err != nil {
+		return err
+	}
+
+	hashes := removeHash(uxHashes, uxHash)
+	if len(hashes) == 0 {
+		return bkt.Delete(addr.Bytes())
+	}
+	return bkt.Put(addr.Bytes(), encoder.Serialize(hashes))
+}
+
+// removeHash returns the hashes without h
+func removeHash(hashes []cipher.SHA256, h cipher.SHA256) []cipher.SHA256 {
+	kept := hashes[:0]
+	for _, u := range hashes {
+		if u != h {
+			kept = append(kept, u)
+		}
+	}
+	return kept
+}
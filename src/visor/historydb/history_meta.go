@@ -2,11 +2,13 @@ package historydb
 
 import (
 	"github.com/boltdb/bolt"
+	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/visor/bucket"
 )
 
 var (
 	parsedHeightKey = []byte("parsed_height")
+	parsedHashKey   = []byte("parsed_hash")
 )
 
 // historyMeta bucket for storing block history meta info
@@ -30,11 +32,32 @@ func (hm *historyMeta) ParsedHeight() int64 {
 	return -1
 }
 
+// ParsedHash returns the hash of the last parsed block, returns false if it's not
+// recorded, as the db was parsed before the hash was kept. The hash is stored with
+// its height, so that a hash left from an interrupted update isn't taken.
+func (hm *historyMeta) ParsedHash() (cipher.SHA256, bool) {
+	var h cipher.SHA256
+	v := hm.v.Get(parsedHashKey)
+	if len(v) != 8+len(h) || int64(bucket.Btoi(v[:8])) != hm.ParsedHeight() {
+		return h, false
+	}
+	copy(h[:], v[8:])
+	return h, true
+}
+
 // SetParsedHeight updates history parsed height
 func (hm *historyMeta) setParsedHeight(h uint64) error {
 	return hm.v.Put(parsedHeightKey, bucket.Itob(h))
 }
 
+// setParsed updates history parsed height and the hash of the block at the height
+func (hm *historyMeta) setParsed(h uint64, hash cipher.SHA256) error {
+	if err := hm.setParsedHeight(h); err != nil {
+		return err
+	}
+	return hm.v.Put(parsedHashKey, append(bucket.Itob(h), hash[:]...))
+}
+
 // IsEmpty checks if history meta bucket is empty
 func (hm *historyMeta) IsEmpty() bool {
 	return hm.v.IsEmpty()
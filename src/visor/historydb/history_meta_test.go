@@ -4,6 +4,7 @@ import (
 	"testing"
 
 	"github.com/boltdb/bolt"
+	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/visor/bucket"
 	"github.com/stretchr/testify/assert"
 )
@@ -61,3 +62,29 @@ func TestHistoryMetaSetParsedHeight(t *testing.T) {
 	hm.setParsedHeight(10)
 	assert.Equal(t, uint64(10), bucket.Btoi(hm.v.Get(parsedHeightKey)))
 }
+
+func TestHistoryMetaParsedHash(t *testing.T) {
+	db, td, err := setup(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer td()
+
+	hm, err := newHistoryMeta(db)
+	assert.Nil(t, err)
+
+	_, ok := hm.ParsedHash()
+	assert.False(t, ok)
+
+	h := cipher.SumSHA256([]byte("block"))
+	assert.Nil(t, hm.setParsed(10, h))
+	ph, ok := hm.ParsedHash()
+	assert.True(t, ok)
+	assert.Equal(t, h, ph)
+	assert.Equal(t, int64(10), hm.ParsedHeight())
+
+	// the hash of another height isn't taken
+	assert.Nil(t, hm.setParsedHeight(11))
+	_, ok = hm.ParsedHash()
+	assert.False(t, ok)
+}
@@ -0,0 +1,449 @@
+// Package historydb is in charge of parsing the consuses blokchain, and providing
+// apis for blockchain explorer.
+package historydb
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+var logger = logging.MustGetLogger("historydb")
+
+// Blockchainer interface for isolating the detail of blockchain.
+type Blockchainer interface {
+	Head() *coin.Block
+	GetBlockInDepth(dep uint64) *coin.Block
+	ExecuteBlock(b *coin.Block) (coin.UxArray, error)
+	CreateGenesisBlock(genAddress cipher.Address, genCoins, timestamp uint64) coin.Block
+	VerifyTransaction(tx coin.Transaction) error
+	GetBlock(hash cipher.SHA256) *coin.Block
+}
+
+// HistoryDB provides apis for blockchain explorer.
+type HistoryDB struct {
+	db           *bolt.DB          // bolt db instance.
+	txns         *transactions     // transactions bucket.
+	outputs      *UxOuts           // outputs bucket.
+	addrUx       *addressUx        // bucket which stores all UxOuts that address recved.
+	addrTxns     *addressTxns      //  address related transaction bucket
+	addrSums     *addressSummaries // aggregated activity of each address
+	*historyMeta                   // stores history meta info
+}
+
+// New create historydb instance and create corresponding buckets if does not exist.
+func New(db *bolt.DB) (*HistoryDB, error) {
+	hd := HistoryDB{db: db}
+	var err error
+
+	hd.txns, err = newTransactionsBkt(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// create the output instance
+	hd.outputs, err = newOutputsBkt(db)
+	if err != nil {
+		return nil, err
+	}
+
+	// create the toAddressTx instance.
+	hd.addrUx, err = newAddressUxBkt(db)
+	if err != nil {
+		return nil, err
+	}
+
+	hd.historyMeta, err = newHistoryMeta(db)
+	if err != nil {
+		return nil, err
+	}
+
+	hd.addrTxns, err = newAddressTxnsBkt(db)
+	if err != nil {
+		return nil, err
+	}
+
+	hd.addrSums, err = newAddressSummariesBkt(db)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hd, nil
+}
+
+// ResetIfNeed checks if need to reset the parsed block history,
+// If we have a new added bucket, we need to reset to parse
+// blockchain again to get the new bucket filled.
+func (hd *HistoryDB) ResetIfNeed() error {
+	if hd.historyMeta.ParsedHeight() == 0 {
+		return nil
+	}
+
+	// if any of the following buckets are empty, need to reset
+	if hd.addrTxns.IsEmpty() ||
+		hd.addrUx.IsEmpty() ||
+		hd.txns.IsEmpty() ||
+		hd.outputs.IsEmpty() ||
+		hd.addrSums.IsEmpty() {
+		return hd.Reset()
+	}
+
+	return nil
+}
+
+// Reset drops the parsed history, the blocks are parsed again from genesis
+func (hd *HistoryDB) Reset() error {
+	logger.Info("History db reset")
+	if err := hd.addrTxns.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.addrUx.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.outputs.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.historyMeta.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.txns.Reset(); err != nil {
+		return err
+	}
+
+	if err := hd.addrSums.Reset(); err != nil {
+		return err
+	}
+	return nil
+}
+
+// GetUxout get UxOut of specific uxID.
+func (hd *HistoryDB) GetUxout(uxID cipher.SHA256) (*UxOut, error) {
+	return hd.outputs.Get(uxID)
+}
+
+// ProcessBlock will index the transaction, outputs,etc.
+func (hd *HistoryDB) ProcessBlock(b *coin.Block) error {
+	if b == nil {
+		return errors.New("process nil block")
+	}
+
+	// undoes the address summaries of the block if it was partially processed
+	if err := hd.restoreAddrSummaries(b.Seq()); err != nil {
+		return err
+	}
+
+	// index the transactions
+	for _, t := range b.Body.Transactions {
+		txn := Transaction{
+			Tx:       t,
+			BlockSeq: b.Seq(),
+		}
+
+		if err := hd.db.Update(func(tx *bolt.Tx) error {
+			// all updates will rollback if return error is not nil
+
+			txnsBkt := tx.Bucket(hd.txns.bkt.Name)
+			outputsBkt := tx.Bucket(hd.outputs.bkt.Name)
+			addrUxBkt := tx.Bucket(hd.addrUx.bkt.Name)
+			addrTxnsBkt := tx.Bucket(hd.addrTxns.bkt.Name)
+			acts := make(map[cipher.Address]*addressActivity)
+			activity := func(addr cipher.Address) *addressActivity {
+				if acts[addr] == nil {
+					acts[addr] = &addressActivity{}
+				}
+				return acts[addr]
+			}
+
+			if err := addTrandaction(txnsBkt, &txn); err != nil {
+				return err
+			}
+
+			// handle tx in, genesis transaction's vin is empty, so should be ignored.
+			if b.Seq() > 0 {
+				for _, in := range t.In {
+					o, err := getOutput(outputsBkt, in)
+					if err != nil {
+						return err
+					}
+					// update output's spent block seq and txid.
+					o.SpentBlockSeq = b.Seq()
+					o.SpentTxID = t.Hash()
+					if err := setOutput(outputsBkt, *o); err != nil {
+						return err
+					}
+
+					// store the IN address with txid
+					if err := setAddressTxns(addrTxnsBkt, o.Out.Body.Address, t.Hash()); err != nil {
+						return err
+					}
+					activity(o.Out.Body.Address).sent += o.Out.Body.Coins
+				}
+			}
+
+			// handle the tx out
+			uxArray := coin.CreateUnspents(b.Head, t)
+			for _, ux := range uxArray {
+				uxOut := UxOut{
+					Out: ux,
+				}
+				if err := setOutput(outputsBkt, uxOut); err != nil {
+					return err
+				}
+
+				if err := setAddressUx(addrUxBkt, ux.Body.Address, ux.Hash()); err != nil {
+					return err
+				}
+
+				if err := setAddressTxns(addrTxnsBkt, ux.Body.Address, t.Hash()); err != nil {
+					return err
+				}
+				activity(ux.Body.Address).received += ux.Body.Coins
+			}
+
+			return updateAddressSummaries(tx.Bucket(hd.addrSums.bkt.Name),
+				tx.Bucket(hd.addrSums.undo.Name), b.Seq(), acts)
+		}); err != nil {
+			return err
+		}
+
+		// update the last tx hash in transaction
+		hd.txns.updateLastTxs(t.Hash())
+	}
+
+	return hd.setParsed(b.Seq(), b.HashHeader())
+}
+
+// Follows returns whether b extends the last parsed block. The parser reads the blocks from the
+// chain by seq, so a block of a new branch is not parsed until the blocks of the old branch
+// are rolled back. Returns true if the hash of the last parsed block is not recorded.
+func (hd *HistoryDB) Follows(b *coin.Block) bool {
+	if b.Seq() == 0 || hd.ParsedHeight() != int64(b.Seq())-1 {
+		return true
+	}
+
+	h, ok := hd.ParsedHash()
+	return !ok || h == b.Head.PrevHash
+}
+
+// RollbackBlock reverts the records of b when it's rolled back from the chain, the txns and
+// outputs of b are removed, and the outputs spent by b are unspent again. Nothing is changed
+// if b isn't the last parsed block, which is either not parsed yet, or was parsed from the
+// new branch.
+func (hd *HistoryDB) RollbackBlock(b *coin.Block) error {
+	if b.Seq() == 0 {
+		return errors.New("can't roll back the genesis block")
+	}
+
+	if hd.ParsedHeight() != int64(b.Seq()) {
+		return nil
+	}
+
+	if h, ok := hd.ParsedHash(); ok && h != b.HashHeader() {
+		return nil
+	}
+
+	if err := hd.restoreAddrSummaries(b.Seq()); err != nil {
+		return err
+	}
+
+	removed := make(map[cipher.SHA256]struct{}, len(b.Body.Transactions))
+	if err := hd.db.Update(func(tx *bolt.Tx) error {
+		txnsBkt := tx.Bucket(hd.txns.bkt.Name)
+		outputsBkt := tx.Bucket(hd.outputs.bkt.Name)
+		addrUxBkt := tx.Bucket(hd.addrUx.bkt.Name)
+		addrTxnsBkt := tx.Bucket(hd.addrTxns.bkt.Name)
+
+		// in the reverse order of ProcessBlock, as a txn can spend the outputs of the
+		// previous txns of the block
+		for i := len(b.Body.Transactions) - 1; i >= 0; i-- {
+			t := b.Body.Transactions[i]
+			txid := t.Hash()
+
+			for _, ux := range coin.CreateUnspents(b.Head, t) {
+				h := ux.Hash()
+				if err := outputsBkt.Delete(h[:]); err != nil {
+					return err
+				}
+
+				if err := removeAddressUx(addrUxBkt, ux.Body.Address, h); err != nil {
+					return err
+				}
+
+				if err := removeAddressTxns(addrTxnsBkt, ux.Body.Address, txid); err != nil {
+					return err
+				}
+			}
+
+			for _, in := range t.In {
+				o, err := getOutput(outputsBkt, in)
+				if err != nil {
+					return err
+				}
+
+				if o == nil {
+					return fmt.Errorf("found no uxout of id %v", in.Hex())
+				}
+
+				o.SpentBlockSeq = 0
+				o.SpentTxID = cipher.SHA256{}
+				if err := setOutput(outputsBkt, *o); err != nil {
+					return err
+				}
+
+				if err := removeAddressTxns(addrTxnsBkt, o.Out.Body.Address, txid); err != nil {
+					return err
+				}
+			}
+
+			if err := txnsBkt.Delete(txid[:]); err != nil {
+				return err
+			}
+			removed[txid] = struct{}{}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+
+	var lastTxs []cipher.SHA256
+	for _, h := range hd.txns.lastTxs {
+		if _, ok := removed[h]; !ok {
+			lastTxs = append(lastTxs, h)
+		}
+	}
+	hd.txns.lastTxs = lastTxs
+
+	return hd.setParsed(b.Seq()-1, b.Head.PrevHash)
+}
+
+// restoreAddrSummaries sets the address summaries back to the ones before block seq
+func (hd *HistoryDB) restoreAddrSummaries(seq uint64) error {
+	return hd.db.Update(func(tx *bolt.Tx) error {
+		return restoreAddressSummaries(tx.Bucket(hd.addrSums.bkt.Name),
+			tx.Bucket(hd.addrSums.undo.Name), seq)
+	})
+}
+
+// GetAddressSummary returns the aggregated activity of the address in the parsed blocks,
+// returns nil if the address never appears
+func (hd HistoryDB) GetAddressSummary(addr cipher.Address) (*AddressSummary, error) {
+	return hd.addrSums.Get(addr)
+}
+
+// GetTransaction get transaction by hash.
+func (hd HistoryDB) GetTransaction(hash cipher.SHA256) (*Transaction, error) {
+	return hd.txns.Get(hash)
+}
+
+// GetLastTxs gets the latest N transactions.
+func (hd HistoryDB) GetLastTxs() ([]*Transaction, error) {
+	txHashes := hd.txns.GetLastTxs()
+	txs := make([]*Transaction, len(txHashes))
+	for i, h := range txHashes {
+		tx, err := hd.txns.Get(h)
+		if err != nil {
+			return []*Transaction{}, err
+		}
+		txs[i] = tx
+	}
+	return txs, nil
+}
+
+// GetAddrUxOuts get all uxout that the address affected.
+func (hd HistoryDB) GetAddrUxOuts(address cipher.Address) ([]*UxOut, error) {
+	hashes, err := hd.addrUx.Get(address)
+	if err != nil {
+		return []*UxOut{}, err
+	}
+	uxOuts := make([]*UxOut, len(hashes))
+	for i, hash := range hashes {
+		ux, err := hd.outputs.Get(hash)
+		if err != nil {
+			return []*UxOut{}, err
+		}
+		uxOuts[i] = ux
+	}
+	return uxOuts, nil
+}
+
+// GetAddrTxns returns all the address related transactions
+func (hd HistoryDB) GetAddrTxns(address cipher.Address) ([]Transaction, error) {
+	hashes, err := hd.addrTxns.Get(address)
+	if err != nil {
+		return []Transaction{}, err
+	}
+
+	return hd.txns.GetSlice(hashes)
+}
+
+// GetAddrTxnsPage returns at most num of the address related transactions from the index
+// start, in the order they're indexed, and the total number of the transactions
+func (hd HistoryDB) GetAddrTxnsPage(address cipher.Address, start, num int) ([]Transaction, int, error) {
+	hashes, err := hd.addrTxns.Get(address)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(hashes)
+	hashes = pageHashes(hashes, start, num)
+	if len(hashes) == 0 {
+		return []Transaction{}, total, nil
+	}
+
+	txns, err := hd.txns.GetSlice(hashes)
+	if err != nil {
+		return nil, 0, err
+	}
+	return txns, total, nil
+}
+
+// GetAddrUxOutsPage returns at most num of the address affected uxouts from the index
+// start, in the order they're indexed, and the total number of the uxouts
+func (hd HistoryDB) GetAddrUxOutsPage(address cipher.Address, start, num int) ([]*UxOut, int, error) {
+	hashes, err := hd.addrUx.Get(address)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	total := len(hashes)
+	hashes = pageHashes(hashes, start, num)
+	uxOuts := make([]*UxOut, len(hashes))
+	for i, hash := range hashes {
+		ux, err := hd.outputs.Get(hash)
+		if err != nil {
+			return nil, 0, err
+		}
+		uxOuts[i] = ux
+	}
+	return uxOuts, total, nil
+}
+
+// pageHashes returns the hashes in the range of start and start+num
+func pageHashes(hashes []cipher.SHA256, start, num int) []cipher.SHA256 {
+	if start >= len(hashes) {
+		return nil
+	}
+
+	end := start + num
+	if end > len(hashes) {
+		end = len(hashes)
+	}
+	return hashes[start:end]
+}
+
+// AddressUsed returns true if the address appears in any of the parsed transactions
+func (hd HistoryDB) AddressUsed(address cipher.Address) (bool, error) {
+	hashes, err := hd.addrTxns.Get(address)
+	if err != nil {
+		return false, err
+	}
+	return len(hashes) > 0, nil
+}
@@ -363,6 +363,107 @@ func TestProcessBlock(t *testing.T) {
 	testEngine(t, testData, bc, hisDB, db)
 }
 
+func TestRollbackBlock(t *testing.T) {
+	db, teardown, err := setup(t)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer teardown()
+	bc := newBlockchain(db)
+	gb := bc.CreateGenesisBlock(genAddress, _genCoins, _genTime)
+
+	hisDB, err := New(db)
+	require.NoError(t, err)
+	require.NoError(t, hisDB.ProcessBlock(&gb))
+
+	td := testData{
+		PreBlockHash: gb.HashHeader(),
+		Vin: txIn{
+			SigKey:   genSecret.Hex(),
+			Addr:     genAddress.String(),
+			TxID:     gb.Body.Transactions[0].Hash(),
+			BlockSeq: 0,
+		},
+		Vouts: []txOut{
+			{
+				ToAddr: "2RxP5N26GhDqHrP6SK45ZzEMSmSpeUeWxsS",
+				Coins:  10e6,
+				Hours:  100,
+			},
+			{
+				ToAddr: "222uMeCeL1PbkJGZJDgAz5sib2uisv9hYUm",
+				Coins:  _genCoins - 10e6,
+				Hours:  400,
+			},
+		},
+	}
+
+	b, tx, err := addBlock(bc, td, _incTime)
+	require.NoError(t, err)
+
+	// the block of another branch isn't parsed after the genesis block
+	other := *b
+	other.Head.PrevHash = cipher.SumSHA256([]byte("other"))
+	require.False(t, hisDB.Follows(&other))
+	require.True(t, hisDB.Follows(b))
+
+	require.NoError(t, hisDB.ProcessBlock(b))
+
+	// the block of another branch at the parsed height isn't rolled back
+	require.NoError(t, hisDB.RollbackBlock(&other))
+	require.Equal(t, int64(1), hisDB.ParsedHeight())
+
+	require.NoError(t, hisDB.RollbackBlock(b))
+	require.Equal(t, int64(0), hisDB.ParsedHeight())
+	h, ok := hisDB.ParsedHash()
+	require.True(t, ok)
+	require.Equal(t, gb.HashHeader(), h)
+
+	txn, err := hisDB.GetTransaction(tx.Hash())
+	require.NoError(t, err)
+	require.Nil(t, txn)
+
+	txns, err := hisDB.GetLastTxs()
+	require.NoError(t, err)
+	require.Len(t, txns, 1)
+	require.Equal(t, gb.Body.Transactions[0].Hash(), txns[0].Tx.Hash())
+
+	// the genesis output is unspent
+	genUx := coin.CreateUnspents(gb.Head, gb.Body.Transactions[0])[0]
+	ux, err := hisDB.GetUxout(genUx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, uint64(0), ux.SpentBlockSeq)
+	require.Equal(t, cipher.SHA256{}, ux.SpentTxID)
+
+	genTxns, err := hisDB.GetAddrTxns(genAddress)
+	require.NoError(t, err)
+	require.Len(t, genTxns, 1)
+
+	for _, o := range coin.CreateUnspents(b.Head, *tx) {
+		ux, err := hisDB.GetUxout(o.Hash())
+		require.NoError(t, err)
+		require.Nil(t, ux)
+
+		uxs, err := hisDB.GetAddrUxOuts(o.Body.Address)
+		require.NoError(t, err)
+		require.Empty(t, uxs)
+
+		txns, err := hisDB.GetAddrTxns(o.Body.Address)
+		require.NoError(t, err)
+		require.Empty(t, txns)
+	}
+
+	// only the last parsed block is rolled back
+	require.NoError(t, hisDB.RollbackBlock(b))
+	require.Equal(t, int64(0), hisDB.ParsedHeight())
+
+	// the block is parsed again
+	require.NoError(t, hisDB.ProcessBlock(b))
+	txn, err = hisDB.GetTransaction(tx.Hash())
+	require.NoError(t, err)
+	require.Equal(t, *tx, txn.Tx)
+}
+
 func testEngine(t *testing.T, tds []testData, bc *fakeBlockchain, hdb *HistoryDB, db *bolt.DB) {
 	for i, td := range tds {
 		b, tx, err := addBlock(bc, td, _incTime*(uint64(i)+1))
@@ -0,0 +1,81 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// OutputHours is the coin hours of an unspent output at the head time and a future time
+type OutputHours struct {
+	UxID    string `json:"uxid"`
+	Address string `json:"address"`
+	Coins   string `json:"coins"`
+	// the hours when the output was created
+	InitialHours   uint64 `json:"initial_hours"`
+	CurrentHours   uint64 `json:"current_hours"`
+	ProjectedHours uint64 `json:"projected_hours"`
+}
+
+// HoursProjection is the coin hours of the unspent outputs accrued by the head time, and
+// projected at a future time. The coin hours are calculated with the block time instead
+// of the local clock, as it's what the transactions are verified against.
+type HoursProjection struct {
+	HeadTime       uint64        `json:"head_time"`
+	ProjectedTime  uint64        `json:"projected_time"`
+	CurrentHours   uint64        `json:"current_hours"`
+	ProjectedHours uint64        `json:"projected_hours"`
+	Outputs        []OutputHours `json:"outputs"`
+}
+
+// newHoursProjection calculates the hours of uxs at headTime and at, the hours at headTime
+// are used if at is 0.
+func newHoursProjection(uxs coin.UxArray, headTime, at uint64) (*HoursProjection, error) {
+	if at == 0 {
+		at = headTime
+	}
+
+	if at < headTime {
+		return nil, fmt.Errorf("projected time %d is earlier than the head time %d", at, headTime)
+	}
+
+	hp := &HoursProjection{
+		HeadTime:      headTime,
+		ProjectedTime: at,
+		Outputs:       make([]OutputHours, len(uxs)),
+	}
+	for i := range uxs {
+		ux := &uxs[i]
+		oh := OutputHours{
+			UxID:           ux.Hash().Hex(),
+			Address:        ux.Body.Address.String(),
+			Coins:          StrBalance(ux.Body.Coins),
+			InitialHours:   ux.Body.Hours,
+			CurrentHours:   ux.CoinHours(headTime),
+			ProjectedHours: ux.CoinHours(at),
+		}
+		hp.CurrentHours += oh.CurrentHours
+		hp.ProjectedHours += oh.ProjectedHours
+		hp.Outputs[i] = oh
+	}
+	return hp, nil
+}
+
+// GetAddressHours returns the coin hours of the unspent outputs of the address, at the head
+// time and projected at the unix time at.
+func (vs *Visor) GetAddressHours(addr cipher.Address, at uint64) (*HoursProjection, error) {
+	uxs := vs.Blockchain.Unspent().GetUnspentsOfAddr(addr)
+	return newHoursProjection(uxs, vs.Blockchain.Time(), at)
+}
+
+// GetUxOutHours returns the coin hours of the unspent output, at the head time and projected
+// at the unix time at. Returns error if the output doesn't exist or is spent.
+func (vs *Visor) GetUxOutHours(uxid cipher.SHA256, at uint64) (*HoursProjection, error) {
+	ux, ok := vs.Blockchain.Unspent().Get(uxid)
+	if !ok {
+		return nil, ErrUnspentNotExist
+	}
+
+	return newHoursProjection(coin.UxArray{ux}, vs.Blockchain.Time(), at)
+}
@@ -0,0 +1,53 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestNewHoursProjection(t *testing.T) {
+	uxs := coin.UxArray{
+		{
+			Head: coin.UxHead{Time: 1000},
+			Body: coin.UxBody{SrcTransaction: randSHA256(), Coins: 30e6, Hours: 100},
+		},
+		{
+			Head: coin.UxHead{Time: 4600},
+			Body: coin.UxBody{SrcTransaction: randSHA256(), Coins: 1e6, Hours: 0},
+		},
+	}
+
+	// one hour after the first output is created
+	hp, err := newHoursProjection(uxs, 4600, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(4600), hp.ProjectedTime)
+	assert.Equal(t, uint64(130), hp.CurrentHours)
+	assert.Equal(t, uint64(130), hp.ProjectedHours)
+
+	// two more hours
+	hp, err = newHoursProjection(uxs, 4600, 4600+7200)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(130), hp.CurrentHours)
+	assert.Equal(t, uint64(130+60+2), hp.ProjectedHours)
+	assert.Len(t, hp.Outputs, 2)
+	assert.Equal(t, OutputHours{
+		UxID:           uxs[0].Hash().Hex(),
+		Address:        uxs[0].Body.Address.String(),
+		Coins:          "30",
+		InitialHours:   100,
+		CurrentHours:   130,
+		ProjectedHours: 190,
+	}, hp.Outputs[0])
+	assert.Equal(t, uint64(2), hp.Outputs[1].ProjectedHours)
+
+	_, err = newHoursProjection(uxs, 4600, 4599)
+	assert.Error(t, err)
+
+	hp, err = newHoursProjection(nil, 4600, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(0), hp.CurrentHours)
+	assert.Empty(t, hp.Outputs)
+}
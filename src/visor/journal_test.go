@@ -0,0 +1,100 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+)
+
+func TestRecoverJournal(t *testing.T) {
+	f, err := ioutil.TempFile("", "journal")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bc, err := NewBlockchain(db, walker)
+	require.NoError(t, err)
+
+	pub, sec := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pub)
+	gb, err := bc.CreateGenesisBlock(addr, 100e6, 1000)
+	require.NoError(t, err)
+
+	// spends the output of the previous block
+	makeBlock := func(prev coin.Block) coin.Block {
+		ux := bc.Unspent().GetUnspentsOfAddr(addr)[0]
+		txn := coin.Transaction{}
+		txn.PushInput(ux.Hash())
+		txn.PushOutput(addr, ux.Body.Coins, ux.Body.Hours/4)
+		txn.SignInputs([]cipher.SecKey{sec})
+		txn.UpdateHeader()
+
+		b, err := bc.NewBlockFromTransactions(coin.Transactions{txn}, prev.Time()+100)
+		require.NoError(t, err)
+		return *b
+	}
+
+	genOuts := bc.Unspent().GetUnspentsOfAddr(addr)
+	b1 := makeBlock(gb)
+	require.NoError(t, bc.ExecuteBlock(&b1))
+	b1Outs := bc.Unspent().GetUnspentsOfAddr(addr)
+	_, ok, err := bc.chain.Journal()
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	restart := func() {
+		bc, err = NewBlockchain(db, walker)
+		require.NoError(t, err)
+		_, ok, err := bc.chain.Journal()
+		require.NoError(t, err)
+		assert.False(t, ok)
+	}
+
+	// stopped before the block is added to the tree
+	b2 := makeBlock(b1)
+	require.NoError(t, bc.chain.BeginJournal(blockdb.JournalApply, &b2))
+	restart()
+	assert.Equal(t, int64(1), bc.headSeq())
+	assert.Nil(t, bc.GetBlock(b2.HashHeader()))
+
+	// stopped after the block is added to the tree, the block is rolled forward
+	require.NoError(t, bc.chain.BeginJournal(blockdb.JournalApply, &b2))
+	require.NoError(t, bc.addBlock(&b2))
+	restart()
+	assert.Equal(t, int64(2), bc.headSeq())
+	assert.Equal(t, b2.HashHeader(), bc.Head().HashHeader())
+	assert.Equal(t, uint64(1), bc.Unspent().Len())
+
+	// stopped before the unspent pool is reverted, the block stays
+	require.NoError(t, bc.chain.BeginJournal(blockdb.JournalRollback, &b2))
+	restart()
+	assert.Equal(t, int64(2), bc.headSeq())
+
+	// stopped after the unspent pool is reverted, the block is removed from the tree
+	require.NoError(t, bc.chain.BeginJournal(blockdb.JournalRollback, &b2))
+	require.NoError(t, bc.chain.RollbackBlock(&b2, b1Outs))
+	restart()
+	assert.Equal(t, int64(1), bc.headSeq())
+	assert.Nil(t, bc.GetBlock(b2.HashHeader()))
+	assert.Equal(t, b1Outs, bc.Unspent().GetUnspentsOfAddr(addr))
+
+	_, err = bc.RollbackHead(genOuts)
+	require.NoError(t, err)
+	_, ok, err = bc.chain.Journal()
+	require.NoError(t, err)
+	assert.False(t, ok)
+	assert.Equal(t, int64(0), bc.headSeq())
+	assert.Equal(t, genOuts, bc.Unspent().GetUnspentsOfAddr(addr))
+}
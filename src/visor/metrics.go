@@ -0,0 +1,35 @@
+package visor
+
+import (
+	"os"
+
+	"github.com/skycoin/skycoin/src/util/metrics"
+)
+
+var (
+	headSeqGauge = metrics.NewGauge("suncoin_block_height",
+		"Seq of the head block")
+	unconfirmedTxnsGauge = metrics.NewGauge("suncoin_unconfirmed_txns",
+		"Number of the txns in the unconfirmed pool")
+	dbSizeGauge = metrics.NewGauge("suncoin_db_size_bytes",
+		"Size of the db file")
+	blocksExecutedCounter = metrics.NewCounter("suncoin_blocks_executed_total",
+		"Number of the blocks appended to the chain")
+	txnsInjectedCounter = metrics.NewCounter("suncoin_txns_injected_total",
+		"Number of the txns added to the unconfirmed pool")
+	txnsDroppedCounter = metrics.NewCounterVec("suncoin_txns_dropped_total",
+		"Number of the txns evicted from the unconfirmed pool", "reason")
+	reorgsCounter = metrics.NewCounter("suncoin_reorgs_total",
+		"Number of the reorganizations of the chain")
+)
+
+// updateMetrics sets the gauges of the chain and the unconfirmed pool
+func (vs *Visor) updateMetrics() {
+	if seq := vs.Blockchain.headSeq(); seq >= 0 {
+		headSeqGauge.Set(float64(seq))
+	}
+	unconfirmedTxnsGauge.Set(float64(vs.Unconfirmed.Len()))
+	if fi, err := os.Stat(vs.Config.DBPath); err == nil {
+		dbSizeGauge.Set(float64(fi.Size()))
+	}
+}
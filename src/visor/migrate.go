@@ -0,0 +1,171 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/boltdb/bolt"
+
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+)
+
+var (
+	dbMetaBktName    = []byte("db_meta")
+	schemaVersionKey = []byte("schema_version")
+	// the db is created by a version of the node that runs no migrations if the blocks
+	// bucket exists but the schema version doesn't
+	blocksBktName = []byte("blocks")
+
+	errMigrationDryRun = errors.New("migration dry run")
+)
+
+// Migration upgrades the db to the schema Version, the migrations run in the order of
+// the versions, each in a db transaction.
+type Migration struct {
+	Version uint64
+	Name    string
+	migrate func(tx *bolt.Tx) error
+}
+
+// migrations of the db schema, a new migration is appended with the next version
+var migrations = []Migration{
+	{Version: 1, Name: "Index the unspent outputs by address", migrate: blockdb.IndexUnspentsByAddr},
+	{Version: 2, Name: "Total the coins and hours of the unspent outputs", migrate: blockdb.TotalUnspents},
+}
+
+// schemaVersion returns the schema version of the db, returns false if the db is new
+func schemaVersion(tx *bolt.Tx) (uint64, bool) {
+	if bkt := tx.Bucket(dbMetaBktName); bkt != nil {
+		if v := bkt.Get(schemaVersionKey); v != nil {
+			return bucket.Btoi(v), true
+		}
+	}
+
+	// the db created before the schema is versioned
+	if tx.Bucket(blocksBktName) != nil {
+		return 0, true
+	}
+
+	return 0, false
+}
+
+func setSchemaVersion(tx *bolt.Tx, version uint64) error {
+	bkt, err := tx.CreateBucketIfNotExists(dbMetaBktName)
+	if err != nil {
+		return err
+	}
+	return bkt.Put(schemaVersionKey, bucket.Itob(version))
+}
+
+// pendingMigrations returns the migrations newer than the schema version of the db, a new
+// db needs no migration.
+func pendingMigrations(tx *bolt.Tx, ms []Migration) []Migration {
+	version, ok := schemaVersion(tx)
+	if !ok {
+		return nil
+	}
+
+	var pending []Migration
+	for _, m := range ms {
+		if m.Version > version {
+			pending = append(pending, m)
+		}
+	}
+	return pending
+}
+
+// migrateDB runs the pending migrations of ms and sets the schema version to the latest one.
+// If dryRun is true, the migrations run in a transaction that's rolled back, nothing is
+// changed. Returns the migrations run.
+func migrateDB(db *bolt.DB, ms []Migration, dryRun bool) ([]Migration, error) {
+	var latest uint64
+	if len(ms) > 0 {
+		latest = ms[len(ms)-1].Version
+	}
+
+	var pending []Migration
+	if err := db.View(func(tx *bolt.Tx) error {
+		if v, _ := schemaVersion(tx); v > latest {
+			return fmt.Errorf("db schema version %d is newer than the supported version %d", v, latest)
+		}
+
+		pending = pendingMigrations(tx, ms)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	if dryRun {
+		err := db.Update(func(tx *bolt.Tx) error {
+			for _, m := range pending {
+				if err := m.migrate(tx); err != nil {
+					return fmt.Errorf("migration %d %q failed: %v", m.Version, m.Name, err)
+				}
+			}
+			return errMigrationDryRun
+		})
+		if err != errMigrationDryRun {
+			return nil, err
+		}
+		return pending, nil
+	}
+
+	for _, m := range pending {
+		logger.Info("Migrating db to schema version %d: %s", m.Version, m.Name)
+		if err := db.Update(func(tx *bolt.Tx) error {
+			if err := m.migrate(tx); err != nil {
+				return err
+			}
+			return setSchemaVersion(tx, m.Version)
+		}); err != nil {
+			return nil, fmt.Errorf("migration %d %q failed: %v", m.Version, m.Name, err)
+		}
+	}
+
+	// the new db is created in the latest schema
+	if err := db.Update(func(tx *bolt.Tx) error {
+		if v, ok := schemaVersion(tx); ok && v >= latest {
+			return nil
+		}
+		return setSchemaVersion(tx, latest)
+	}); err != nil {
+		return nil, err
+	}
+
+	return pending, nil
+}
+
+// checkSchema returns error if the db isn't in the latest schema of ms, the read-only db
+// can't be created or migrated.
+func checkSchema(db *bolt.DB, ms []Migration) error {
+	var latest uint64
+	if len(ms) > 0 {
+		latest = ms[len(ms)-1].Version
+	}
+
+	return db.View(func(tx *bolt.Tx) error {
+		v, ok := schemaVersion(tx)
+		switch {
+		case !ok:
+			return errors.New("the db has no blockchain")
+		case v > latest:
+			return fmt.Errorf("db schema version %d is newer than the supported version %d", v, latest)
+		case v < latest:
+			return fmt.Errorf("db schema version %d needs migrating to %d, start the node without read-only mode first", v, latest)
+		}
+		return nil
+	})
+}
+
+// DryRunMigrations runs the pending migrations of the db without changing it, returns the
+// migrations that would run when the node starts.
+func DryRunMigrations(dbPath string) ([]Migration, error) {
+	db, closeDB, err := openDB(dbPath, false)
+	if err != nil {
+		return nil, err
+	}
+	defer closeDB()
+
+	return migrateDB(db, migrations, true)
+}
@@ -0,0 +1,118 @@
+package visor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateDB(t *testing.T) {
+	f, err := ioutil.TempFile("", "migrate")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	version := func() (uint64, bool) {
+		var v uint64
+		var ok bool
+		require.NoError(t, db.View(func(tx *bolt.Tx) error {
+			v, ok = schemaVersion(tx)
+			return nil
+		}))
+		return v, ok
+	}
+
+	var run []string
+	testBkt := []byte("test")
+	mig := func(version uint64, name string) Migration {
+		return Migration{Version: version, Name: name, migrate: func(tx *bolt.Tx) error {
+			run = append(run, name)
+			bkt, err := tx.CreateBucketIfNotExists(testBkt)
+			if err != nil {
+				return err
+			}
+			return bkt.Put([]byte(name), []byte{1})
+		}}
+	}
+	ms := []Migration{mig(1, "a"), mig(2, "b")}
+
+	// the new db is created in the latest schema
+	done, err := migrateDB(db, ms[:1], false)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+	assert.Empty(t, run)
+	v, ok := version()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(1), v)
+
+	// dry run changes nothing
+	done, err = migrateDB(db, ms, true)
+	require.NoError(t, err)
+	require.Len(t, done, 1)
+	assert.Equal(t, "b", done[0].Name)
+	assert.Equal(t, []string{"b"}, run)
+	v, _ = version()
+	assert.Equal(t, uint64(1), v)
+	require.NoError(t, db.View(func(tx *bolt.Tx) error {
+		assert.Nil(t, tx.Bucket(testBkt))
+		return nil
+	}))
+
+	run = nil
+	done, err = migrateDB(db, ms, false)
+	require.NoError(t, err)
+	assert.Len(t, done, 1)
+	assert.Equal(t, []string{"b"}, run)
+	v, _ = version()
+	assert.Equal(t, uint64(2), v)
+
+	// the failed migration is rolled back, the version stays at the last one succeeded
+	ms = append(ms, mig(3, "c"), Migration{Version: 4, Name: "d", migrate: func(tx *bolt.Tx) error {
+		return errors.New("failed")
+	}})
+	_, err = migrateDB(db, ms, false)
+	assert.Error(t, err)
+	v, _ = version()
+	assert.Equal(t, uint64(3), v)
+
+	// the db of a newer version is rejected
+	_, err = migrateDB(db, ms[:2], false)
+	assert.Error(t, err)
+}
+
+func TestMigrateUnversionedDB(t *testing.T) {
+	f, err := ioutil.TempFile("", "migrate")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	// the db created before the schema is versioned has the blocks bucket
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucket(blocksBktName)
+		return err
+	}))
+
+	done, err := migrateDB(db, migrations, false)
+	require.NoError(t, err)
+	require.Len(t, done, len(migrations))
+	for i, m := range done {
+		assert.Equal(t, migrations[i].Version, m.Version)
+	}
+
+	done, err = migrateDB(db, migrations, false)
+	require.NoError(t, err)
+	assert.Empty(t, done)
+}
@@ -0,0 +1,117 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// ErrOrphanTxn is returned when the txn spends unknown outputs, the txn is kept in the
+// orphan pool until the parent txns are confirmed.
+var ErrOrphanTxn = errors.New("Transaction spends unknown outputs, added to the orphan pool")
+
+// orphanPool holds the txns whose inputs are not in the unspent pool yet, the earliest
+// added txn will be removed when the limit is reached.
+type orphanPool struct {
+	txns map[cipher.SHA256]coin.Transaction
+	// hashes in the order of being added, for removing the earliest ones
+	order []cipher.SHA256
+	max   int
+}
+
+func newOrphanPool(max int) *orphanPool {
+	return &orphanPool{
+		txns: make(map[cipher.SHA256]coin.Transaction),
+		max:  max,
+	}
+}
+
+// add adds the txn to the pool, returns false if it's already in the pool
+func (op *orphanPool) add(txn coin.Transaction) bool {
+	h := txn.Hash()
+	if _, ok := op.txns[h]; ok {
+		return false
+	}
+
+	for len(op.order) > 0 && len(op.order) >= op.max {
+		delete(op.txns, op.order[0])
+		op.order = op.order[1:]
+	}
+
+	op.txns[h] = txn
+	op.order = append(op.order, h)
+	return true
+}
+
+// take removes and returns the txns that can pass the filter, in the order of being added
+func (op *orphanPool) take(filter func(txn coin.Transaction) bool) coin.Transactions {
+	var (
+		txns  coin.Transactions
+		order = op.order[:0]
+	)
+	for _, h := range op.order {
+		txn := op.txns[h]
+		if !filter(txn) {
+			order = append(order, h)
+			continue
+		}
+
+		delete(op.txns, h)
+		txns = append(txns, txn)
+	}
+	op.order = order
+	return txns
+}
+
+func (op *orphanPool) len() int {
+	return len(op.order)
+}
+
+// checkInputs returns whether all the inputs of txn are in the unspent pool, returns error
+// if any input is already spent.
+func (vs *Visor) checkInputs(txn coin.Transaction) (bool, error) {
+	unspent := vs.Blockchain.Unspent()
+	allKnown := true
+	for _, in := range txn.In {
+		if _, ok := unspent.Get(in); ok {
+			continue
+		}
+
+		ux, err := vs.history.GetUxout(in)
+		if err != nil {
+			return false, err
+		}
+
+		if ux != nil && ux.SpentTxID != (cipher.SHA256{}) {
+			return false, fmt.Errorf("output %s is already spent", in.Hex())
+		}
+		allKnown = false
+	}
+	return allKnown, nil
+}
+
+// processOrphans moves the orphan txns whose inputs are all known to the unconfirmed pool
+func (vs *Visor) processOrphans() {
+	unspent := vs.Blockchain.Unspent()
+	txns := vs.orphans.take(func(txn coin.Transaction) bool {
+		for _, in := range txn.In {
+			if _, ok := unspent.Get(in); !ok {
+				return false
+			}
+		}
+		return true
+	})
+
+	for _, txn := range txns {
+		if _, err := vs.InjectTxn(txn); err != nil {
+			logger.Debug("Inject orphan txn %s failed: %v", txn.Hash().Hex(), err)
+		}
+	}
+}
+
+// OrphanTxnsLen returns the number of txns in the orphan pool
+func (vs *Visor) OrphanTxnsLen() int {
+	return vs.orphans.len()
+}
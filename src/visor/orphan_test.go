@@ -0,0 +1,47 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestOrphanPool(t *testing.T) {
+	newTxn := func() coin.Transaction {
+		txn := coin.Transaction{In: []cipher.SHA256{randSHA256()}}
+		txn.UpdateHeader()
+		return txn
+	}
+
+	op := newOrphanPool(3)
+	a, b, c, d := newTxn(), newTxn(), newTxn(), newTxn()
+
+	assert.True(t, op.add(a))
+	assert.False(t, op.add(a))
+	assert.True(t, op.add(b))
+	assert.True(t, op.add(c))
+	assert.Equal(t, 3, op.len())
+
+	// the earliest added txn is removed when the limit is reached
+	assert.True(t, op.add(d))
+	assert.Equal(t, 3, op.len())
+
+	known := map[cipher.SHA256]bool{
+		a.In[0]: true,
+		b.In[0]: true,
+		d.In[0]: true,
+	}
+	txns := op.take(func(txn coin.Transaction) bool {
+		return known[txn.In[0]]
+	})
+	assert.Equal(t, coin.Transactions{b, d}, txns)
+	assert.Equal(t, 1, op.len())
+	assert.Equal(t, []cipher.SHA256{c.Hash()}, op.order)
+
+	assert.Empty(t, op.take(func(coin.Transaction) bool { return false }))
+	assert.Equal(t, coin.Transactions{c}, op.take(func(coin.Transaction) bool { return true }))
+	assert.Equal(t, 0, op.len())
+}
@@ -0,0 +1,28 @@
+package visor
+
+import (
+	"time"
+
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+// CheckWalletPolicy checks that the txn spending the coins of the wallet is allowed by
+// the spending policy of the wallet, the coins sent in the past 24 hours are counted from
+// the wallet history
+func (vs *Visor) CheckWalletPolicy(wlt wallet.Wallet, txn coin.Transaction) error {
+	p, err := wlt.Policy()
+	if err != nil {
+		return err
+	}
+
+	var sent uint64
+	if p.MaxCoinsPerDay != 0 {
+		since := time.Now().Add(-24 * time.Hour).Unix()
+		if sent, err = vs.SentCoinsSince(wlt.GetAddresses(), uint64(since)); err != nil {
+			return err
+		}
+	}
+
+	return wlt.CheckPolicy(txn, sent)
+}
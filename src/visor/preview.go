@@ -0,0 +1,264 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+// ErrTxnConfirmed is the rejection reason of a txn that is already in the blockchain
+var ErrTxnConfirmed = errors.New("Transaction is already confirmed")
+
+// TxnPreview is the result of checking a txn against the current unspent pool and
+// unconfirmed pool without injecting it
+type TxnPreview struct {
+	TxID  string `json:"txid"`
+	Valid bool   `json:"valid"`
+	// the reason the txn would be rejected, empty if it's valid
+	Reason string `json:"reason,omitempty"`
+	// whether the txn is already in the unconfirmed pool
+	Known bool `json:"known"`
+
+	CoinsIn  string `json:"coins_in"`
+	CoinsOut string `json:"coins_out"`
+	// the input hours are calculated with the head block time
+	HoursIn  uint64 `json:"hours_in"`
+	HoursOut uint64 `json:"hours_out"`
+	Fee      uint64 `json:"fee"`
+	// the fee is burned, there's no block reward for the fee
+	HoursBurned uint64 `json:"hours_burned"`
+	// the minimum fee required by BurnFactor
+	MinFee uint64 `json:"min_fee"`
+
+	// Hashes of the unconfirmed txns that spend the same outputs, only one of them can
+	// be confirmed
+	ConflictsWith []string `json:"conflicts_with,omitempty"`
+}
+
+// SpendPreview shows what a spend does before it's signed, the hours of the inputs that
+// are not sent to the outputs are burned
+type SpendPreview struct {
+	Inputs  []UnsignedInput  `json:"inputs"`
+	Outputs []UnsignedOutput `json:"outputs"`
+	// the input hours are calculated with the head block time
+	HoursIn     uint64 `json:"hours_in"`
+	HoursOut    uint64 `json:"hours_out"`
+	HoursBurned uint64 `json:"hours_burned"`
+
+	// the change address is empty if a new address is created for the change when spending
+	ChangeAddress    string          `json:"change_address"`
+	NewChangeAddress bool            `json:"new_change_address"`
+	Change           ReadableBalance `json:"change"`
+
+	// the predicted balance of the addresses before and after the spend
+	BalanceBefore ReadableBalance `json:"balance_before"`
+	BalanceAfter  ReadableBalance `json:"balance_after"`
+
+	// index of the change output, -1 if there's no change
+	changeIndex int
+}
+
+// PreviewSpend previews sending amt to dest from the addresses, the outputs are chosen by
+// sel and the hours are split the same way as the spends. The change is sent to the change
+// address, or the address of the first input if it's not set. Nothing is signed or
+// injected, the random selection may choose other outputs when spending.
+func (vs *Visor) PreviewSpend(addrs []cipher.Address, amt wallet.Balance, dest, change cipher.Address,
+	multisigs []wallet.Multisig, sel CoinSelector) (*SpendPreview, error) {
+	ut, err := vs.CreateUnsignedTransaction(addrs, amt, dest, change, multisigs, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	txn, err := ut.Transaction()
+	if err != nil {
+		return nil, err
+	}
+
+	unspent := vs.Blockchain.Unspent()
+	auxs := unspent.GetUnspentsOfAddrs(addrs)
+	puxs, err := vs.Unconfirmed.SpendsForAddresses(unspent, addrs)
+	if err != nil {
+		return nil, err
+	}
+	coins, hours := vs.AddressBalance(auxs.Sub(puxs))
+
+	pv := &SpendPreview{
+		Inputs:      ut.Inputs,
+		Outputs:     ut.Outputs,
+		HoursOut:    txn.OutputHours(),
+		HoursBurned: ut.Fee,
+		BalanceBefore: ReadableBalance{
+			Coins: StrBalance(coins),
+			Hours: hours,
+		},
+		changeIndex: -1,
+	}
+	for _, in := range ut.Inputs {
+		pv.HoursIn += in.Hours
+	}
+
+	// the change is the first output if the inputs have more coins than amt
+	if len(txn.Out) > 1 {
+		pv.changeIndex = 0
+		pv.ChangeAddress = txn.Out[0].Address.String()
+		pv.Change = ReadableBalance{
+			Coins: StrBalance(txn.Out[0].Coins),
+			Hours: txn.Out[0].Hours,
+		}
+	}
+
+	// the outputs sent back to the addresses stay in the balance
+	own := make(map[cipher.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		own[a] = struct{}{}
+	}
+
+	coins -= totalCoinsOut(txn)
+	hours -= pv.HoursIn
+	for _, o := range txn.Out {
+		if _, ok := own[o.Address]; ok {
+			coins += o.Coins
+			hours += o.Hours
+		}
+	}
+	pv.BalanceAfter = ReadableBalance{
+		Coins: StrBalance(coins),
+		Hours: hours,
+	}
+
+	return pv, nil
+}
+
+// SetNewChangeAddress marks the change as sent to a new address of the wallet, the address
+// is created when spending, so it's not shown
+func (pv *SpendPreview) SetNewChangeAddress() {
+	pv.NewChangeAddress = true
+	if pv.changeIndex < 0 {
+		return
+	}
+
+	pv.ChangeAddress = ""
+	pv.Outputs = append([]UnsignedOutput(nil), pv.Outputs...)
+	pv.Outputs[pv.changeIndex].Address = ""
+}
+
+// VerifyTxnAgainstState checks the txn the same way as InjectTxn does, without adding it
+// to the unconfirmed pool or the orphan pool. An invalid txn is not an error, the reason
+// is returned in the preview instead. Returns error if the db can't be read.
+func (vs *Visor) VerifyTxnAgainstState(txn coin.Transaction) (TxnPreview, error) {
+	h := txn.Hash()
+	pv := TxnPreview{
+		TxID:     h.Hex(),
+		Known:    vs.Unconfirmed.Txns.isExist(h),
+		CoinsOut: StrBalance(totalCoinsOut(txn)),
+		HoursOut: txn.OutputHours(),
+	}
+
+	reject := func(err error) (TxnPreview, error) {
+		pv.Reason = err.Error()
+		return pv, nil
+	}
+
+	if err := txn.Verify(); err != nil {
+		return reject(err)
+	}
+
+	if !pv.Known {
+		confirmed, err := vs.history.GetTransaction(h)
+		if err != nil {
+			return TxnPreview{}, err
+		}
+
+		if confirmed != nil {
+			return reject(ErrTxnConfirmed)
+		}
+	}
+
+	// same as checkInputs, but the db error is separated from the spent inputs
+	unspent := vs.Blockchain.Unspent()
+	for _, in := range txn.In {
+		if _, ok := unspent.Get(in); ok {
+			continue
+		}
+
+		ux, err := vs.history.GetUxout(in)
+		if err != nil {
+			return TxnPreview{}, err
+		}
+
+		if ux != nil && ux.SpentTxID != (cipher.SHA256{}) {
+			return reject(fmt.Errorf("output %s is already spent", in.Hex()))
+		}
+		return reject(errors.New("Transaction spends unknown outputs"))
+	}
+
+	uxIn, err := unspent.GetArray(txn.In)
+	if err != nil {
+		return reject(err)
+	}
+
+	headTime := vs.Blockchain.Time()
+	var coinsIn uint64
+	for _, ux := range uxIn {
+		coinsIn += ux.Body.Coins
+		pv.HoursIn += ux.CoinHours(headTime)
+	}
+	pv.CoinsIn = StrBalance(coinsIn)
+
+	if pv.HoursIn >= pv.HoursOut {
+		pv.Fee = pv.HoursIn - pv.HoursOut
+		pv.HoursBurned = pv.Fee
+	}
+	pv.MinFee = pv.HoursIn / BurnFactor
+	pv.ConflictsWith = vs.unconfirmedConflicts(txn)
+
+	if err := VerifyTransactionFee(vs.Blockchain, &txn); err != nil {
+		return reject(err)
+	}
+
+	if err := vs.Blockchain.VerifyTransaction(txn); err != nil {
+		return reject(err)
+	}
+
+	pv.Valid = true
+	return pv, nil
+}
+
+// unconfirmedConflicts returns the hashes of the unconfirmed txns other than txn that
+// spend any input of txn, sorted
+func (vs *Visor) unconfirmedConflicts(txn coin.Transaction) []string {
+	ins := make(map[cipher.SHA256]struct{}, len(txn.In))
+	for _, in := range txn.In {
+		ins[in] = struct{}{}
+	}
+
+	h := txn.Hash()
+	var hashes []string
+	for _, tx := range vs.Unconfirmed.GetTxns(All) {
+		txid := tx.Hash()
+		if txid == h {
+			continue
+		}
+
+		for _, in := range tx.Txn.In {
+			if _, ok := ins[in]; ok {
+				hashes = append(hashes, txid.Hex())
+				break
+			}
+		}
+	}
+	sort.Strings(hashes)
+	return hashes
+}
+
+func totalCoinsOut(txn coin.Transaction) uint64 {
+	var coins uint64
+	for _, o := range txn.Out {
+		coins += o.Coins
+	}
+	return coins
+}
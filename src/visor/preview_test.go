@@ -0,0 +1,136 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+func TestUnconfirmedConflicts(t *testing.T) {
+	f, err := ioutil.TempFile("", "preview")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	vs := &Visor{Unconfirmed: NewUnconfirmedTxnPool(db)}
+
+	in1, in2 := randSHA256(), randSHA256()
+	txn := coin.Transaction{InnerHash: randSHA256()}
+	txn.PushInput(in1)
+	txn.PushInput(in2)
+	assert.Empty(t, vs.unconfirmedConflicts(txn))
+
+	// the txn itself is not a conflict
+	self := createUnconfirmedTxn()
+	self.Txn = txn
+	vs.Unconfirmed.Txns.put(&self)
+	assert.Empty(t, vs.unconfirmedConflicts(txn))
+
+	a := createUnconfirmedTxn()
+	a.Txn.In = append(a.Txn.In, in2)
+	vs.Unconfirmed.Txns.put(&a)
+
+	b := createUnconfirmedTxn()
+	b.Txn.In = append(b.Txn.In, randSHA256(), in1)
+	vs.Unconfirmed.Txns.put(&b)
+
+	// spends other outputs
+	c := createUnconfirmedTxn()
+	c.Txn.In = append(c.Txn.In, randSHA256())
+	vs.Unconfirmed.Txns.put(&c)
+
+	expect := []string{a.Hash().Hex(), b.Hash().Hex()}
+	if expect[0] > expect[1] {
+		expect[0], expect[1] = expect[1], expect[0]
+	}
+	assert.Equal(t, expect, vs.unconfirmedConflicts(txn))
+}
+
+func TestPreviewSpend(t *testing.T) {
+	f, err := ioutil.TempFile("", "preview")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bc, err := NewBlockchain(db, walker)
+	require.NoError(t, err)
+	vs := &Visor{Blockchain: bc, Unconfirmed: NewUnconfirmedTxnPool(db)}
+
+	pub, sec := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pub)
+	gb, err := bc.CreateGenesisBlock(addr, 100e6, 1000)
+	require.NoError(t, err)
+
+	// split the genesis output
+	ux := bc.Unspent().GetUnspentsOfAddr(addr)[0]
+	txn := coin.Transaction{}
+	txn.PushInput(ux.Hash())
+	txn.PushOutput(addr, 30e6, 100)
+	txn.PushOutput(addr, 70e6, 100)
+	txn.SignInputs([]cipher.SecKey{sec})
+	txn.UpdateHeader()
+	b, err := bc.NewBlockFromTransactions(coin.Transactions{txn}, gb.Time()+3600)
+	require.NoError(t, err)
+	require.NoError(t, bc.ExecuteBlock(b))
+
+	p2, _ := cipher.GenerateKeyPair()
+	dest := cipher.AddressFromPubKey(p2)
+	sel, err := GetCoinSelector(SelectMinInputs)
+	require.NoError(t, err)
+
+	pv, err := vs.PreviewSpend([]cipher.Address{addr}, wallet.NewBalance(20e6, 0), dest, cipher.Address{}, nil, sel)
+	require.NoError(t, err)
+	require.Len(t, pv.Inputs, 1)
+	assert.Equal(t, "70", pv.Inputs[0].Coins)
+	require.Len(t, pv.Outputs, 2)
+	assert.Equal(t, dest.String(), pv.Outputs[1].Address)
+	assert.Equal(t, "20", pv.Outputs[1].Coins)
+
+	assert.Equal(t, pv.Inputs[0].Hours, pv.HoursIn)
+	assert.Equal(t, pv.HoursIn-pv.HoursOut, pv.HoursBurned)
+	assert.True(t, pv.HoursBurned >= pv.HoursIn/BurnFactor)
+
+	assert.Equal(t, addr.String(), pv.ChangeAddress)
+	assert.Equal(t, "50", pv.Change.Coins)
+	assert.Equal(t, pv.Outputs[0].Hours, pv.Change.Hours)
+
+	assert.Equal(t, "100", pv.BalanceBefore.Coins)
+	assert.Equal(t, "80", pv.BalanceAfter.Coins)
+	assert.Equal(t, pv.BalanceBefore.Hours-pv.HoursIn+pv.Change.Hours, pv.BalanceAfter.Hours)
+
+	// nothing is spent
+	assert.Len(t, bc.Unspent().GetUnspentsOfAddr(addr), 2)
+	assert.Equal(t, 0, vs.Unconfirmed.Len())
+
+	pv.SetNewChangeAddress()
+	assert.True(t, pv.NewChangeAddress)
+	assert.Empty(t, pv.ChangeAddress)
+	assert.Empty(t, pv.Outputs[0].Address)
+	assert.Equal(t, dest.String(), pv.Outputs[1].Address)
+
+	// no change if the inputs have exactly the coins
+	pv, err = vs.PreviewSpend([]cipher.Address{addr}, wallet.NewBalance(70e6, 0), dest, cipher.Address{}, nil, sel)
+	require.NoError(t, err)
+	require.Len(t, pv.Outputs, 1)
+	assert.Empty(t, pv.ChangeAddress)
+	assert.Equal(t, "30", pv.BalanceAfter.Coins)
+
+	_, err = vs.PreviewSpend([]cipher.Address{addr}, wallet.NewBalance(200e6, 0), dest, cipher.Address{}, nil, nil)
+	assert.Equal(t, ErrNotEnoughCoins, err)
+}
@@ -0,0 +1,131 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// TransactionProof proves that a transaction is included in a block, the merkle root
+// computed from the transaction hash and the path must equal to the block body hash.
+type TransactionProof struct {
+	Header coin.BlockHeader
+	TxHash cipher.SHA256
+	// Index of the transaction in the block body
+	Index int
+	// Sibling hashes from the leaf to the root
+	Path []cipher.SHA256
+}
+
+// ReadableTransactionProof represents readable transaction proof
+type ReadableTransactionProof struct {
+	Header ReadableBlockHeader `json:"header"`
+	TxHash string              `json:"txid"`
+	Index  int                 `json:"index"`
+	Path   []string            `json:"path"`
+}
+
+// NewReadableTransactionProof creates readable transaction proof
+func NewReadableTransactionProof(p *TransactionProof) ReadableTransactionProof {
+	path := make([]string, len(p.Path))
+	for i := range p.Path {
+		path[i] = p.Path[i].Hex()
+	}
+
+	return ReadableTransactionProof{
+		Header: NewReadableBlockHeader(&p.Header),
+		TxHash: p.TxHash.Hex(),
+		Index:  p.Index,
+		Path:   path,
+	}
+}
+
+// Verify checks whether the proof path leads to the block body hash
+func (p TransactionProof) Verify() bool {
+	return merkleRootFromPath(p.TxHash, p.Index, p.Path) == p.Header.BodyHash
+}
+
+// GetTransactionProof returns the proof of the confirmed transaction, returns nil if
+// the transaction does not exist or is not confirmed yet.
+func (vs *Visor) GetTransactionProof(txHash cipher.SHA256) (*TransactionProof, error) {
+	txn, err := vs.history.GetTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if txn == nil {
+		return nil, nil
+	}
+
+	b := vs.GetBlockBySeq(txn.BlockSeq)
+	if b == nil {
+		return nil, fmt.Errorf("found no block in seq %v", txn.BlockSeq)
+	}
+
+	hashes := make([]cipher.SHA256, len(b.Body.Transactions))
+	index := -1
+	for i := range b.Body.Transactions {
+		hashes[i] = b.Body.Transactions[i].Hash()
+		if hashes[i] == txHash {
+			index = i
+		}
+	}
+
+	if index < 0 {
+		return nil, fmt.Errorf("transaction %s not found in block %v", txHash.Hex(), txn.BlockSeq)
+	}
+
+	p := &TransactionProof{
+		Header: b.Head,
+		TxHash: txHash,
+		Index:  index,
+		Path:   merklePath(hashes, index),
+	}
+
+	if !p.Verify() {
+		return nil, errors.New("transaction proof does not match block body hash")
+	}
+
+	return p, nil
+}
+
+// merklePath returns the sibling hashes of the leaf at index, the tree is built the same
+// way as cipher.Merkle, which pads the leaves with zero hashes to the next power of 2.
+func merklePath(leaves []cipher.SHA256, index int) []cipher.SHA256 {
+	n := 1
+	for n < len(leaves) {
+		n *= 2
+	}
+
+	level := make([]cipher.SHA256, n)
+	copy(level, leaves)
+
+	var path []cipher.SHA256
+	for len(level) > 1 {
+		path = append(path, level[index^1])
+		next := make([]cipher.SHA256, len(level)/2)
+		for i := range next {
+			next[i] = cipher.AddSHA256(level[2*i], level[2*i+1])
+		}
+		level = next
+		index /= 2
+	}
+
+	return path
+}
+
+// merkleRootFromPath computes the merkle root from the leaf and its sibling hashes
+func merkleRootFromPath(leaf cipher.SHA256, index int, path []cipher.SHA256) cipher.SHA256 {
+	h := leaf
+	for _, sibling := range path {
+		if index%2 == 0 {
+			h = cipher.AddSHA256(h, sibling)
+		} else {
+			h = cipher.AddSHA256(sibling, h)
+		}
+		index /= 2
+	}
+	return h
+}
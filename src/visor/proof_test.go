@@ -0,0 +1,27 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestMerklePath(t *testing.T) {
+	for n := 1; n <= 9; n++ {
+		hashes := make([]cipher.SHA256, n)
+		for i := range hashes {
+			hashes[i] = randSHA256()
+		}
+		root := cipher.Merkle(append([]cipher.SHA256{}, hashes...))
+
+		for i := range hashes {
+			path := merklePath(hashes, i)
+			assert.Equal(t, root, merkleRootFromPath(hashes[i], i, path))
+			if n > 1 {
+				assert.NotEqual(t, root, merkleRootFromPath(randSHA256(), i, path))
+			}
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package visor
+
+import (
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+const (
+	// MinPruneDepth is the min number of the recent blocks whose bodies are kept, the
+	// chain can't be reorganized or the history db rewound deeper than it
+	MinPruneDepth = 1000
+	// pruneBatchSize is the max number of blocks pruned in a db transaction
+	pruneBatchSize = 1000
+)
+
+var emptyBodyHash = coin.BlockBody{}.Hash()
+
+// blockPruned checks if the body of the block is discarded, only the header is kept
+func blockPruned(b *coin.Block) bool {
+	return len(b.Body.Transactions) == 0 && b.Head.BodyHash != emptyBodyHash
+}
+
+// PrunedSeq returns the seq of the last block whose body is discarded, the bodies of the
+// blocks from seq 1 to it are unavailable. Returns 0 if no block is pruned.
+func (vs *Visor) PrunedSeq() uint64 {
+	return vs.Blockchain.chain.PrunedSeq()
+}
+
+// pruneBlocks discards the bodies of the blocks older than the PruneDepth recent blocks.
+// The blocks not yet parsed by the history db are kept, and the genesis block is never
+// pruned.
+func (vs *Visor) pruneBlocks() error {
+	if vs.Config.PruneDepth == 0 {
+		return nil
+	}
+
+	head := vs.Blockchain.Head()
+	if head == nil || head.Seq() <= vs.Config.PruneDepth {
+		return nil
+	}
+
+	target := head.Seq() - vs.Config.PruneDepth
+	if parsed := vs.history.ParsedHeight(); parsed < int64(target) {
+		if parsed < 1 {
+			return nil
+		}
+		target = uint64(parsed)
+	}
+
+	for seq := vs.PrunedSeq() + 1; seq <= target; {
+		hashes := make([]cipher.SHA256, 0, pruneBatchSize)
+		for ; seq <= target && len(hashes) < pruneBatchSize; seq++ {
+			b := vs.Blockchain.GetBlockInDepth(seq)
+			if b == nil {
+				return fmt.Errorf("found no block in seq %v", seq)
+			}
+			hashes = append(hashes, b.HashHeader())
+		}
+
+		if err := vs.Blockchain.tree.PruneBlocks(hashes); err != nil {
+			return err
+		}
+
+		if err := vs.Blockchain.Unspent().DeleteBlockSpent(hashes); err != nil {
+			return err
+		}
+
+		if err := vs.Blockchain.chain.SetPrunedSeq(seq - 1); err != nil {
+			return err
+		}
+		logger.Debug("Pruned the blocks till %d", seq-1)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+func TestPruneBlocks(t *testing.T) {
+	f, err := ioutil.TempFile("", "prune")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bc, err := NewBlockchain(db, walker)
+	require.NoError(t, err)
+
+	history, err := historydb.New(db)
+	require.NoError(t, err)
+
+	vs := &Visor{Blockchain: bc, history: history}
+	vs.Config.PruneDepth = 2
+
+	pub, _ := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pub)
+	gb, err := bc.CreateGenesisBlock(addr, 100e6, 1000)
+	require.NoError(t, err)
+	require.NoError(t, history.ProcessBlock(&gb))
+
+	blocks := []coin.Block{gb}
+	for i := uint64(1); i <= 5; i++ {
+		txn := coin.Transaction{}
+		txn.PushOutput(addr, i*1e6, 10)
+		txn.UpdateHeader()
+		body := coin.BlockBody{Transactions: coin.Transactions{txn}}
+		b := coin.Block{
+			Head: coin.BlockHeader{
+				BkSeq:    i,
+				Time:     1000 + i,
+				BodyHash: body.Hash(),
+				PrevHash: blocks[i-1].HashHeader(),
+			},
+			Body: body,
+		}
+		require.NoError(t, bc.addBlock(&b))
+		require.NoError(t, bc.processBlock(&b))
+		blocks = append(blocks, b)
+	}
+
+	// only the blocks parsed by the history db are pruned
+	require.NoError(t, history.ProcessBlock(&blocks[1]))
+	require.NoError(t, vs.pruneBlocks())
+	assert.Equal(t, uint64(1), vs.PrunedSeq())
+
+	for i := uint64(2); i <= 5; i++ {
+		require.NoError(t, history.ProcessBlock(&blocks[i]))
+	}
+	require.NoError(t, vs.pruneBlocks())
+	assert.Equal(t, uint64(3), vs.PrunedSeq())
+
+	for i, b := range blocks {
+		got := vs.GetBlockBySeq(uint64(i))
+		require.NotNil(t, got)
+		assert.Equal(t, b.Head, got.Head)
+
+		pruned := i >= 1 && i <= 3
+		assert.Equal(t, pruned, blockPruned(got))
+		assert.Equal(t, pruned, NewReadableBlock(got).Pruned)
+	}
+
+	// the pruned blocks are not served to the peers
+	assert.Empty(t, vs.GetSignedBlocksSince(0, 10))
+	assert.Empty(t, vs.GetSignedBlocksSince(2, 10))
+
+	// the pruned block is still found by hash
+	seq, ok := newBlockIndex(bc).get(blocks[2].HashHeader())
+	assert.True(t, ok)
+	assert.Equal(t, uint64(2), seq)
+}
@@ -0,0 +1,85 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestReadOnlyVisor(t *testing.T) {
+	f, err := ioutil.TempFile("", "readonly")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	c := NewVisorConfig()
+	c.DBPath = f.Name()
+
+	// writes the chain by a normal node
+	vs, _, err := NewVisor(c)
+	require.NoError(t, err)
+
+	pub, sec := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pub)
+	gb, err := vs.Blockchain.CreateGenesisBlock(addr, 100e6, 1000)
+	require.NoError(t, err)
+
+	ux := vs.Blockchain.Unspent().GetUnspentsOfAddr(addr)[0]
+	txn := coin.Transaction{}
+	txn.PushInput(ux.Hash())
+	txn.PushOutput(addr, ux.Body.Coins, ux.Body.Hours/4)
+	txn.SignInputs([]cipher.SecKey{sec})
+	txn.UpdateHeader()
+
+	b, err := vs.Blockchain.NewBlockFromTransactions(coin.Transactions{txn}, gb.Time()+100)
+	require.NoError(t, err)
+	require.NoError(t, vs.Blockchain.ExecuteBlock(b))
+	require.NoError(t, vs.db.Close())
+
+	c.ReadOnly = true
+	mc := c
+	mc.IsMaster = true
+	mc.BlockchainPubkey = pub
+	mc.BlockchainSeckey = sec
+	_, _, err = NewVisor(mc)
+	assert.Error(t, err)
+
+	rc := c
+	rc.DBPath = f.Name() + ".missing"
+	_, _, err = NewVisor(rc)
+	assert.Error(t, err)
+	_, err = os.Stat(rc.DBPath)
+	assert.True(t, os.IsNotExist(err))
+
+	vs, closeVs, err := NewVisor(c)
+	require.NoError(t, err)
+
+	errC := make(chan error, 1)
+	go func() {
+		errC <- vs.bcParser.Run()
+	}()
+
+	// the queries are served
+	assert.Equal(t, uint64(1), vs.HeadBkSeq())
+	uxs := vs.Blockchain.Unspent().GetUnspentsOfAddr(addr)
+	require.Len(t, uxs, 1)
+	assert.Equal(t, txn.Hash(), uxs[0].Body.SrcTransaction)
+
+	// the writes are rejected
+	_, err = vs.InjectTxn(txn)
+	assert.Equal(t, ErrReadOnly, err)
+	_, err = vs.InjectTxns(coin.Transactions{txn})
+	assert.Equal(t, ErrReadOnly, err)
+	assert.Equal(t, ErrReadOnly, vs.ExecuteSignedBlock(coin.SignedBlock{Block: *b}))
+	assert.Equal(t, ErrReadOnly, vs.StartRebuildIndexes())
+	assert.Nil(t, vs.RefreshUnconfirmed())
+
+	closeVs()
+	require.NoError(t, <-errC)
+}
@@ -0,0 +1,1053 @@
+package visor
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+// BlockchainMetadata encapsulates useful information from the coin.Blockchain
+type BlockchainMetadata struct {
+	// Most recent block's header
+	Head ReadableBlockHeader `json:"head"`
+	// Number of unspent outputs in the coin.Blockchain
+	Unspents uint64 `json:"unspents"`
+	// Number of known unconfirmed txns
+	Unconfirmed uint64 `json:"unconfirmed"`
+	// Total coins in the unspent outputs
+	CurrentSupply string `json:"current_supply"`
+	// Total coin hours of the unspent outputs at head time, rounded down once over all the
+	// outputs, so it can exceed the sum of the balances by less than 1 hour per output
+	TotalCoinHours uint64 `json:"total_coin_hours"`
+	// Seconds elapsed since the head block was created
+	TimeSinceLastBlock uint64 `json:"time_since_last_block"`
+	// Size of the blockchain database file in bytes
+	DBSize int64 `json:"db_size"`
+	// Hash of the current unspent output set, nodes with the same head should have
+	// the same ux hash, otherwise the unspent pools have diverged.
+	UxHash string `json:"ux_hash"`
+	// Number of unconfirmed txns evicted since started
+	UnconfirmedEvictions EvictionMetrics `json:"unconfirmed_evictions"`
+	// The bodies of the blocks from seq 1 to it are discarded, 0 if no block is pruned
+	PrunedSeq uint64 `json:"pruned_seq"`
+}
+
+// NewBlockchainMetadata creates blockchain meta data
+func NewBlockchainMetadata(v *Visor) BlockchainMetadata {
+	head := v.Blockchain.Head().Head
+	bm := BlockchainMetadata{
+		Head:        NewReadableBlockHeader(&head),
+		Unspents:    v.Blockchain.Unspent().Len(),
+		Unconfirmed: uint64(v.Unconfirmed.Txns.len()),
+		UxHash:      v.Blockchain.Unspent().GetUxHash().Hex(),
+		PrunedSeq:   v.PrunedSeq(),
+
+		UnconfirmedEvictions: v.Unconfirmed.Evictions(),
+	}
+
+	if now := uint64(utc.UnixNow()); now > head.Time {
+		bm.TimeSinceLastBlock = now - head.Time
+	}
+
+	coins, hours, err := v.Blockchain.Unspent().GetSupply(head.Time)
+	if err != nil {
+		logger.Error("Get the supply of the unspent outputs failed: %v", err)
+	}
+	bm.CurrentSupply = StrBalance(coins)
+	bm.TotalCoinHours = hours
+
+	if fi, err := os.Stat(v.Config.DBPath); err == nil {
+		bm.DBSize = fi.Size()
+	}
+
+	return bm
+}
+
+// Transaction wraps around coin.Transaction, tagged with its status.  This allows us
+// to include unconfirmed txns
+type Transaction struct {
+	Txn    coin.Transaction  //`json:"txn"`
+	Status TransactionStatus //`json:"status"`
+	Time   uint64            //`json:"time"`
+	Fee    uint64            //`json:"fee"`
+}
+
+// TransactionStatus represents the transaction status
+type TransactionStatus struct {
+	Confirmed bool `json:"confirmed"`
+	// This txn is in the unconfirmed pool
+	Unconfirmed bool `json:"unconfirmed"`
+	// If confirmed, how many blocks deep in the chain it is. Will be at least
+	// 1 if confirmed.
+	Height uint64 `json:"height"`
+	// Execute block seq
+	BlockSeq uint64 `json:"block_seq"`
+	// Number of blocks confirming this txn, the executing block included.
+	Confirmations uint64 `json:"confirmations"`
+	// Hash of the executing block
+	BlockHash string `json:"block_hash,omitempty"`
+	// We can't find anything about this txn.  Be aware that the txn may be
+	// in someone else's unconfirmed pool, and if valid, it may become a
+	// confirmed txn in the future
+	Unknown bool `json:"unknown"`
+}
+
+// NewUnconfirmedTransactionStatus creates unconfirmed transaction status
+func NewUnconfirmedTransactionStatus() TransactionStatus {
+	return TransactionStatus{
+		Unconfirmed: true,
+		Unknown:     false,
+		Confirmed:   false,
+		Height:      0,
+	}
+}
+
+// NewUnknownTransactionStatus creates unknow transaction status
+func NewUnknownTransactionStatus() TransactionStatus {
+	return TransactionStatus{
+		Unconfirmed: false,
+		Unknown:     true,
+		Confirmed:   false,
+		Height:      0,
+		BlockSeq:    0,
+	}
+}
+
+// NewConfirmedTransactionStatus creates confirmed transaction status
+func NewConfirmedTransactionStatus(height uint64, blockSeq uint64) TransactionStatus {
+	if height == 0 {
+		logger.Panic("Invalid confirmed transaction height")
+	}
+	return TransactionStatus{
+		Unconfirmed: false,
+		Unknown:     false,
+		Confirmed:   true,
+		Height:      height,
+		BlockSeq:    blockSeq,
+	}
+}
+
+// NewConfirmedTransactionStatusFromBlock creates confirmed transaction status of txn
+// executed in block b, the confirmations are computed against the head seq.
+// Returns error if the block is above the head, which happens if the chain is rolled back
+// while the txn is looked up.
+func NewConfirmedTransactionStatusFromBlock(headSeq uint64, b *coin.Block) (TransactionStatus, error) {
+	if b.Seq() > headSeq {
+		return TransactionStatus{}, fmt.Errorf("transaction block seq %d is greater than head seq %d",
+			b.Seq(), headSeq)
+	}
+	confirms := headSeq - b.Seq() + 1
+	st := NewConfirmedTransactionStatus(confirms, b.Seq())
+	st.Confirmations = confirms
+	st.BlockHash = b.HashHeader().Hex()
+	return st, nil
+}
+
+/*
+type ReadableTransactionHeader struct {
+	Hash string   `json:"hash"`
+	Sigs []string `json:"sigs"`
+}
+
+func NewReadableTransactionHeader(t *coin.TransactionHeader) ReadableTransactionHeader {
+	sigs := make([]string, len(t.Sigs))
+	for i, _ := range t.Sigs {
+		sigs[i] = t.Sigs[i].Hex()
+	}
+	return ReadableTransactionHeader{
+		Hash: t.Hash.Hex(),
+		Sigs: sigs,
+	}
+}
+*/
+
+// ReadableTransactionOutput readable transaction output
+type ReadableTransactionOutput struct {
+	Hash    string `json:"uxid"`
+	Address string `json:"dst"`
+	Coins   string `json:"coins"`
+	Hours   uint64 `json:"hours"`
+}
+
+// ReadableTransactionInput readable transaction input
+type ReadableTransactionInput struct {
+	Hash    string `json:"uxid"`
+	Address string `json:"owner"`
+}
+
+// StrBalance converts balance to string
+// each 1,000,000 units is 1 coin
+// skyoin has up to 6 decimal places but no more
+func StrBalance(amt uint64) string {
+	a := amt / 1000000 //whole part
+	b := amt % 1000000 //fractional part
+
+	//func strconv.FormatUint(i int64, base int) string
+
+	as := strconv.FormatUint(a, 10)
+
+	if b == 0 { //no fractional part
+		return as
+	}
+
+	// pads the fractional part to 6 digits, and trims the trailing zeros
+	bs := strings.TrimRight(fmt.Sprintf("%06d", b), "0")
+	return fmt.Sprintf("%s.%s", as, bs)
+}
+
+//StrBalance2 convert back
+func StrBalance2(amt string) uint64 {
+	b, err := strconv.ParseUint(amt, 10, 64)
+	if err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// ParseStrBalance converts the coins string generated by StrBalance back to droplets
+func ParseStrBalance(amt string) (uint64, error) {
+	pts := strings.Split(amt, ".")
+	if len(pts) > 2 || pts[0] == "" {
+		return 0, fmt.Errorf("invalid coins value %q", amt)
+	}
+
+	whole, err := strconv.ParseUint(pts[0], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid coins value %q", amt)
+	}
+
+	var frac uint64
+	if len(pts) == 2 {
+		if len(pts[1]) == 0 || len(pts[1]) > 6 {
+			return 0, fmt.Errorf("invalid coins value %q", amt)
+		}
+
+		// right pads the fractional part to 6 digits
+		frac, err = strconv.ParseUint(pts[1]+strings.Repeat("0", 6-len(pts[1])), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid coins value %q", amt)
+		}
+	}
+
+	if whole > (math.MaxUint64-frac)/1000000 {
+		return 0, fmt.Errorf("coins value %q overflows", amt)
+	}
+
+	return whole*1000000 + frac, nil
+}
+
+// NewReadableTransactionOutput creates readable transaction outputs
+func NewReadableTransactionOutput(t *coin.TransactionOutput, txid cipher.SHA256) ReadableTransactionOutput {
+	return ReadableTransactionOutput{
+		Hash:    t.UxID(txid).Hex(),
+		Address: t.Address.String(), //Destination Address
+		Coins:   StrBalance(t.Coins),
+		Hours:   t.Hours,
+	}
+}
+
+// NewReadableTransactionInput creates readable transaction input
+func NewReadableTransactionInput(uxID string, ownerAddress string) ReadableTransactionInput {
+	return ReadableTransactionInput{
+		Hash:    uxID,
+		Address: ownerAddress, //Destination Address
+	}
+}
+
+// ReadableBalance represents readable balance
+type ReadableBalance struct {
+	Coins string `json:"coins"`
+	Hours uint64 `json:"hours"`
+}
+
+// ReadableBalancePair represents readable confirmed and predicted balance
+type ReadableBalancePair struct {
+	Confirmed ReadableBalance `json:"confirmed"`
+	Predicted ReadableBalance `json:"predicted"`
+}
+
+// NewReadableBalancePair creates readable balance pair
+func NewReadableBalancePair(bp wallet.BalancePair) ReadableBalancePair {
+	return ReadableBalancePair{
+		Confirmed: ReadableBalance{
+			Coins: StrBalance(bp.Confirmed.Coins),
+			Hours: bp.Confirmed.Hours,
+		},
+		Predicted: ReadableBalance{
+			Coins: StrBalance(bp.Predicted.Coins),
+			Hours: bp.Predicted.Hours,
+		},
+	}
+}
+
+// NewReadableBalances creates readable balances of addresses
+func NewReadableBalances(bals map[string]wallet.BalancePair) map[string]ReadableBalancePair {
+	rbs := make(map[string]ReadableBalancePair, len(bals))
+	for addr, bp := range bals {
+		rbs[addr] = NewReadableBalancePair(bp)
+	}
+	return rbs
+}
+
+// ReadableOutput represents readable output
+type ReadableOutput struct {
+	Hash              string `json:"hash"`
+	SourceTransaction string `json:"src_tx"`
+	Address           string `json:"address"`
+	Coins             string `json:"coins"`
+	Hours             uint64 `json:"hours"`
+	// the coin hours at the head block time, they're burned or moved to the outputs
+	// when the output is spent
+	CalculatedHours uint64 `json:"calculated_hours"`
+}
+
+// ReadableOutputSet records unspent outputs in different status.
+type ReadableOutputSet struct {
+	HeadOutputs      []ReadableOutput `json:"head_outputs"`
+	OutgoingOutputs  []ReadableOutput `json:"outgoing_outputs"`
+	IncommingOutputs []ReadableOutput `json:"incoming_outputs"`
+}
+
+// SpendableOutputs caculates the spendable unspent outputs
+func (os ReadableOutputSet) SpendableOutputs() []ReadableOutput {
+	if len(os.OutgoingOutputs) == 0 {
+		return os.HeadOutputs
+	}
+
+	spending := make(map[string]bool)
+	for _, u := range os.OutgoingOutputs {
+		spending[u.Hash] = true
+	}
+
+	var outs []ReadableOutput
+	for i := range os.HeadOutputs {
+		if _, ok := spending[os.HeadOutputs[i].Hash]; !ok {
+			outs = append(outs, os.HeadOutputs[i])
+		}
+	}
+	return outs
+}
+
+// NewReadableOutput creates readable output
+func NewReadableOutput(headTime uint64, t coin.UxOut) ReadableOutput {
+	return ReadableOutput{
+		Hash:              t.Hash().Hex(),
+		SourceTransaction: t.Body.SrcTransaction.Hex(),
+		Address:           t.Body.Address.String(),
+		Coins:             StrBalance(t.Body.Coins),
+		Hours:             t.Body.Hours,
+		CalculatedHours:   t.CoinHours(headTime),
+	}
+}
+
+// ReadableOutputVerbose represents readable output with the creation and spending metadata
+type ReadableOutputVerbose struct {
+	Hash              string `json:"hash"`
+	SourceTransaction string `json:"src_tx"`
+	SrcBlockSeq       uint64 `json:"src_block_seq"`
+	Time              uint64 `json:"time"`
+	Address           string `json:"address"`
+	Coins             string `json:"coins"`
+	Hours             uint64 `json:"hours"`
+	Spent             bool   `json:"spent"`
+	SpentTxID         string `json:"spent_tx,omitempty"`
+	SpentBlockSeq     uint64 `json:"spent_block_seq,omitempty"`
+}
+
+// NewReadableOutputVerbose creates verbose readable output from historical UxOut
+func NewReadableOutputVerbose(ux historydb.UxOut) ReadableOutputVerbose {
+	ro := ReadableOutputVerbose{
+		Hash:              ux.Hash().Hex(),
+		SourceTransaction: ux.Out.Body.SrcTransaction.Hex(),
+		SrcBlockSeq:       ux.Out.Head.BkSeq,
+		Time:              ux.Out.Head.Time,
+		Address:           ux.Out.Body.Address.String(),
+		Coins:             StrBalance(ux.Out.Body.Coins),
+		Hours:             ux.Out.Body.Hours,
+	}
+
+	// the spent tx id is left empty until the output is spent.
+	if ux.SpentTxID != (cipher.SHA256{}) {
+		ro.Spent = true
+		ro.SpentTxID = ux.SpentTxID.Hex()
+		ro.SpentBlockSeq = ux.SpentBlockSeq
+	}
+	return ro
+}
+
+// AddressSummary represents the aggregated activity of an address in the parsed blocks
+type AddressSummary struct {
+	Address        string `json:"address"`
+	FirstSeenSeq   uint64 `json:"first_seen_block_seq"`
+	FirstSeenTime  uint64 `json:"first_seen_time"`
+	LastActiveSeq  uint64 `json:"last_active_block_seq"`
+	LastActiveTime uint64 `json:"last_active_time"`
+	// the change sent back to the address is counted in both
+	TotalReceived string `json:"total_received"`
+	TotalSent     string `json:"total_sent"`
+	TxnCount      uint64 `json:"txn_count"`
+}
+
+// NewAddressSummary creates readable address summary, the block times are given as they
+// are not stored in the summary
+func NewAddressSummary(addr cipher.Address, s historydb.AddressSummary, firstSeenTime, lastActiveTime uint64) AddressSummary {
+	return AddressSummary{
+		Address:        addr.String(),
+		FirstSeenSeq:   s.FirstSeen,
+		FirstSeenTime:  firstSeenTime,
+		LastActiveSeq:  s.LastActive,
+		LastActiveTime: lastActiveTime,
+		TotalReceived:  StrBalance(s.Received),
+		TotalSent:      StrBalance(s.Sent),
+		TxnCount:       s.TxnCount,
+	}
+}
+
+// ReadableUxOut is the same as historydb.UxOutJSON, except that the coins are decimal string
+type ReadableUxOut struct {
+	Uxid          string `json:"uxid"`
+	Time          uint64 `json:"time"`
+	SrcBkSeq      uint64 `json:"src_block_seq"`
+	SrcTx         string `json:"src_tx"`
+	OwnerAddress  string `json:"owner_address"`
+	Coins         string `json:"coins"`
+	Hours         uint64 `json:"hours"`
+	SpentBlockSeq uint64 `json:"spent_block_seq"`
+	SpentTxID     string `json:"spent_tx"`
+}
+
+// NewReadableUxOut creates readable uxout
+func NewReadableUxOut(ux *historydb.UxOutJSON) ReadableUxOut {
+	return ReadableUxOut{
+		Uxid:          ux.Uxid,
+		Time:          ux.Time,
+		SrcBkSeq:      ux.SrcBkSeq,
+		SrcTx:         ux.SrcTx,
+		OwnerAddress:  ux.OwnerAddress,
+		Coins:         StrBalance(ux.Coins),
+		Hours:         ux.Hours,
+		SpentBlockSeq: ux.SpentBlockSeq,
+		SpentTxID:     ux.SpentTxID,
+	}
+}
+
+// ReadableEncodeOptions controls the optional fields of the readable types
+type ReadableEncodeOptions struct {
+	// Emit RFC3339 timestamps in UTC alongside the unix times
+	HumanTime bool
+	// Render the coins that are encoded as droplets as decimal strings
+	DecimalCoins bool
+}
+
+// BalancePair returns the balance pair, or the readable balance pair whose coins are
+// decimal strings if the DecimalCoins option is enabled.
+func (opts ReadableEncodeOptions) BalancePair(bp wallet.BalancePair) interface{} {
+	if !opts.DecimalCoins {
+		return bp
+	}
+	return NewReadableBalancePair(bp)
+}
+
+// UxOut returns the uxout, or the readable uxout whose coins are decimal strings
+// if the DecimalCoins option is enabled.
+func (opts ReadableEncodeOptions) UxOut(ux *historydb.UxOutJSON) interface{} {
+	if !opts.DecimalCoins {
+		return ux
+	}
+	return NewReadableUxOut(ux)
+}
+
+// UxOuts is the same as UxOut, but for a list of uxouts.
+func (opts ReadableEncodeOptions) UxOuts(uxs []*historydb.UxOutJSON) interface{} {
+	if !opts.DecimalCoins {
+		return uxs
+	}
+
+	ruxs := make([]ReadableUxOut, len(uxs))
+	for i := range uxs {
+		ruxs[i] = NewReadableUxOut(uxs[i])
+	}
+	return ruxs
+}
+
+// humanTime formats the unix time as RFC3339 in UTC, returns empty string if
+// the option is not enabled, so that the field will be omitted.
+func (opts ReadableEncodeOptions) humanTime(t uint64) string {
+	if !opts.HumanTime {
+		return ""
+	}
+	return time.Unix(int64(t), 0).UTC().Format(time.RFC3339)
+}
+
+// ReadableTransaction represents readable transaction
+type ReadableTransaction struct {
+	Length    uint32 `json:"length"`
+	Type      uint8  `json:"type"`
+	Hash      string `json:"txid"`
+	InnerHash string `json:"inner_hash"`
+	Timestamp uint64 `json:"timestamp,omitempty"`
+	// RFC3339 format of the timestamp, only set when HumanTime option is enabled
+	TimestampISO string `json:"timestamp_iso,omitempty"`
+	Fee          uint64 `json:"fee"`
+	// Execute block seq, nil if the txn is not confirmed
+	BlockSeq *uint64 `json:"block_seq,omitempty"`
+
+	Sigs []string                    `json:"sigs"`
+	In   []string                    `json:"inputs"`
+	Out  []ReadableTransactionOutput `json:"outputs"`
+}
+
+// ReadableUnconfirmedTxn  represents readable unconfirmed transaction
+type ReadableUnconfirmedTxn struct {
+	Txn       ReadableTransaction `json:"transaction"`
+	Received  time.Time           `json:"received"`
+	Checked   time.Time           `json:"checked"`
+	Announced time.Time           `json:"announced"`
+	IsValid   bool                `json:"is_valid"`
+	Fee       uint64              `json:"fee"`
+	// Whether other unconfirmed txns spend the same outputs, only one of them can be confirmed
+	Conflicting bool `json:"conflicting"`
+	// Hashes of the unconfirmed txns that spend the same outputs
+	ConflictsWith []string `json:"conflicts_with,omitempty"`
+	// Position of the txn when being packed into blocks by fee per kB, starts from 1,
+	// 0 if the fee can't be calculated and the txn won't be packed
+	Priority int `json:"priority"`
+	// Whether the txn is predicted to be packed into the next block
+	InNextBlock bool `json:"in_next_block"`
+	// Seconds left before the txn is dropped from the pool, the time is reset when the
+	// txn is received again. -1 if the txns never expire.
+	TTL int64 `json:"ttl"`
+}
+
+// NewReadableUnconfirmedTxn creates readable unconfirmed transaction
+func NewReadableUnconfirmedTxn(unconfirmed *UnconfirmedTxn) ReadableUnconfirmedTxn {
+	return ReadableUnconfirmedTxn{
+		Txn:       NewReadableTransaction(&Transaction{Txn: unconfirmed.Txn}),
+		Received:  nanoToTime(unconfirmed.Received),
+		Checked:   nanoToTime(unconfirmed.Checked),
+		Announced: nanoToTime(unconfirmed.Announced),
+		IsValid:   unconfirmed.IsValid == 1,
+	}
+}
+
+// NewGenesisReadableTransaction creates genesis readable transaction
+func NewGenesisReadableTransaction(t *Transaction) ReadableTransaction {
+	txid := cipher.SHA256{}
+	sigs := make([]string, len(t.Txn.Sigs))
+	for i := range t.Txn.Sigs {
+		sigs[i] = t.Txn.Sigs[i].Hex()
+	}
+
+	in := make([]string, len(t.Txn.In))
+	for i := range t.Txn.In {
+		in[i] = t.Txn.In[i].Hex()
+	}
+	out := make([]ReadableTransactionOutput, len(t.Txn.Out))
+	for i := range t.Txn.Out {
+		out[i] = NewReadableTransactionOutput(&t.Txn.Out[i], txid)
+	}
+	return ReadableTransaction{
+		Length:    t.Txn.Length,
+		Type:      t.Txn.Type,
+		Hash:      t.Txn.Hash().Hex(),
+		InnerHash: t.Txn.InnerHash.Hex(),
+		Timestamp: t.Time,
+		Fee:       t.Fee,
+		BlockSeq:  readableBlockSeq(t.Status),
+
+		Sigs: sigs,
+		In:   in,
+		Out:  out,
+	}
+}
+
+// readableBlockSeq returns the execute block seq of the confirmed txn, nil otherwise
+func readableBlockSeq(st TransactionStatus) *uint64 {
+	if !st.Confirmed {
+		return nil
+	}
+	seq := st.BlockSeq
+	return &seq
+}
+
+// NewReadableTransaction creates readable transaction
+func NewReadableTransaction(t *Transaction) ReadableTransaction {
+	return NewReadableTransactionWithOptions(t, ReadableEncodeOptions{})
+}
+
+// NewReadableTransactionWithOptions creates readable transaction with encode options
+func NewReadableTransactionWithOptions(t *Transaction, opts ReadableEncodeOptions) ReadableTransaction {
+	txid := t.Txn.Hash()
+	sigs := make([]string, len(t.Txn.Sigs))
+	for i := range t.Txn.Sigs {
+		sigs[i] = t.Txn.Sigs[i].Hex()
+	}
+
+	in := make([]string, len(t.Txn.In))
+	for i := range t.Txn.In {
+		in[i] = t.Txn.In[i].Hex()
+	}
+	out := make([]ReadableTransactionOutput, len(t.Txn.Out))
+	for i := range t.Txn.Out {
+		out[i] = NewReadableTransactionOutput(&t.Txn.Out[i], txid)
+	}
+	rt := ReadableTransaction{
+		Length:    t.Txn.Length,
+		Type:      t.Txn.Type,
+		Hash:      t.Txn.Hash().Hex(),
+		InnerHash: t.Txn.InnerHash.Hex(),
+		Timestamp: t.Time,
+		Fee:       t.Fee,
+		BlockSeq:  readableBlockSeq(t.Status),
+
+		Sigs: sigs,
+		In:   in,
+		Out:  out,
+	}
+
+	// the transactions in block body have no timestamp
+	if t.Time != 0 {
+		rt.TimestampISO = opts.humanTime(t.Time)
+	}
+	return rt
+}
+
+// ReadableBlockHeader represents the readable block header
+type ReadableBlockHeader struct {
+	BkSeq             uint64 `json:"seq"`
+	BlockHash         string `json:"block_hash"`
+	PreviousBlockHash string `json:"previous_block_hash"`
+	Time              uint64 `json:"timestamp"`
+	// RFC3339 format of the timestamp, only set when HumanTime option is enabled
+	TimeISO  string `json:"timestamp_iso,omitempty"`
+	Fee      uint64 `json:"fee"`
+	Version  uint32 `json:"version"`
+	BodyHash string `json:"tx_body_hash"`
+	// Hash of the unspent output set that the block was created on
+	UxHash string `json:"ux_hash"`
+}
+
+// NewReadableBlockHeader creates readable block header
+func NewReadableBlockHeader(b *coin.BlockHeader) ReadableBlockHeader {
+	return NewReadableBlockHeaderWithOptions(b, ReadableEncodeOptions{})
+}
+
+// NewReadableBlockHeaderWithOptions creates readable block header with encode options
+func NewReadableBlockHeaderWithOptions(b *coin.BlockHeader, opts ReadableEncodeOptions) ReadableBlockHeader {
+	return ReadableBlockHeader{
+		BkSeq:             b.BkSeq,
+		BlockHash:         b.Hash().Hex(),
+		PreviousBlockHash: b.PrevHash.Hex(),
+		Time:              b.Time,
+		TimeISO:           opts.humanTime(b.Time),
+		Fee:               b.Fee,
+		Version:           b.Version,
+		BodyHash:          b.BodyHash.Hex(),
+		UxHash:            b.UxHash.Hex(),
+	}
+}
+
+// ReadableBlockBody  represents readable block body
+type ReadableBlockBody struct {
+	Transactions []ReadableTransaction `json:"txns"`
+}
+
+// NewReadableBlockBody creates readable block body
+func NewReadableBlockBody(b *coin.Block) ReadableBlockBody {
+	txns := make([]ReadableTransaction, len(b.Body.Transactions))
+	for i := range b.Body.Transactions {
+		if b.Seq() == uint64(0) {
+			// genesis block
+			txns[i] = NewGenesisReadableTransaction(&Transaction{Txn: b.Body.Transactions[i]})
+		} else {
+			txns[i] = NewReadableTransaction(&Transaction{Txn: b.Body.Transactions[i]})
+		}
+	}
+	return ReadableBlockBody{
+		Transactions: txns,
+	}
+}
+
+// ReadableBlock  represents readable block
+type ReadableBlock struct {
+	Head ReadableBlockHeader `json:"header"`
+	Body ReadableBlockBody   `json:"body"`
+	// whether the body of the block is discarded by the pruned node
+	Pruned bool `json:"pruned,omitempty"`
+}
+
+// NewReadableBlock creates readable blockj
+func NewReadableBlock(b *coin.Block) ReadableBlock {
+	return NewReadableBlockWithOptions(b, ReadableEncodeOptions{})
+}
+
+// NewReadableBlockWithOptions creates readable block with encode options
+func NewReadableBlockWithOptions(b *coin.Block, opts ReadableEncodeOptions) ReadableBlock {
+	return ReadableBlock{
+		Head:   NewReadableBlockHeaderWithOptions(&b.Head, opts),
+		Body:   NewReadableBlockBody(b),
+		Pruned: blockPruned(b),
+	}
+}
+
+// ReadableTransactionInputVerbose represents the readable transaction input, which is
+// resolved from the spent UxOut
+type ReadableTransactionInputVerbose struct {
+	Hash    string `json:"uxid"`
+	Address string `json:"owner"`
+	Coins   string `json:"coins"`
+	Hours   uint64 `json:"hours"`
+	// Coin hours of the input at the time of the block
+	CalculatedHours uint64 `json:"calculated_hours"`
+}
+
+// NewReadableTransactionInputVerbose creates readable transaction input, headTime is
+// the time at which the coin hours of the UxOut are calculated
+func NewReadableTransactionInputVerbose(ux *historydb.UxOut, headTime uint64) ReadableTransactionInputVerbose {
+	return ReadableTransactionInputVerbose{
+		Hash:            ux.Hash().Hex(),
+		Address:         ux.Out.Body.Address.String(),
+		Coins:           StrBalance(ux.Out.Body.Coins),
+		Hours:           ux.Out.Body.Hours,
+		CalculatedHours: ux.Out.CoinHours(headTime),
+	}
+}
+
+// ReadableTransactionVerbose represents readable transaction with resolved inputs
+type ReadableTransactionVerbose struct {
+	Length    uint32 `json:"length"`
+	Type      uint8  `json:"type"`
+	Hash      string `json:"txid"`
+	InnerHash string `json:"inner_hash"`
+	Fee       uint64 `json:"fee"`
+
+	Sigs []string                          `json:"sigs"`
+	In   []ReadableTransactionInputVerbose `json:"inputs"`
+	Out  []ReadableTransactionOutput       `json:"outputs"`
+}
+
+// NewReadableTransactionVerbose creates readable transaction with resolved inputs,
+// the inputs must be in the same order as txn.In.
+func NewReadableTransactionVerbose(txn *coin.Transaction, inputs []*historydb.UxOut, headTime uint64) (ReadableTransactionVerbose, error) {
+	if len(inputs) != len(txn.In) {
+		return ReadableTransactionVerbose{}, errors.New("inputs length does not match the transaction")
+	}
+
+	txid := txn.Hash()
+	sigs := make([]string, len(txn.Sigs))
+	for i := range txn.Sigs {
+		sigs[i] = txn.Sigs[i].Hex()
+	}
+
+	var inHours uint64
+	in := make([]ReadableTransactionInputVerbose, len(txn.In))
+	for i := range inputs {
+		if inputs[i].Hash() != txn.In[i] {
+			return ReadableTransactionVerbose{}, fmt.Errorf("input %s does not match the transaction", txn.In[i].Hex())
+		}
+		in[i] = NewReadableTransactionInputVerbose(inputs[i], headTime)
+		inHours += in[i].CalculatedHours
+	}
+
+	out := make([]ReadableTransactionOutput, len(txn.Out))
+	for i := range txn.Out {
+		out[i] = NewReadableTransactionOutput(&txn.Out[i], txid)
+	}
+
+	var fee uint64
+	if outHours := txn.OutputHours(); inHours > outHours {
+		fee = inHours - outHours
+	}
+
+	return ReadableTransactionVerbose{
+		Length:    txn.Length,
+		Type:      txn.Type,
+		Hash:      txid.Hex(),
+		InnerHash: txn.InnerHash.Hex(),
+		Fee:       fee,
+
+		Sigs: sigs,
+		In:   in,
+		Out:  out,
+	}, nil
+}
+
+// ReadableBlockBodyVerbose represents readable block body with resolved inputs
+type ReadableBlockBodyVerbose struct {
+	Transactions []ReadableTransactionVerbose `json:"txns"`
+}
+
+// ReadableBlockVerbose represents readable block with resolved inputs
+type ReadableBlockVerbose struct {
+	Head ReadableBlockHeader      `json:"header"`
+	Body ReadableBlockBodyVerbose `json:"body"`
+}
+
+// NewReadableBlockVerbose creates readable block with resolved inputs, inputs[i] are
+// the UxOuts spent by the i-th transaction of the block. The coin hours of the inputs
+// are computed at the time of the previous block, which is how the fee is charged.
+func NewReadableBlockVerbose(b *coin.Block, prevTime uint64, inputs [][]*historydb.UxOut) (ReadableBlockVerbose, error) {
+	if len(inputs) != len(b.Body.Transactions) {
+		return ReadableBlockVerbose{}, errors.New("inputs length does not match the block transactions")
+	}
+
+	txns := make([]ReadableTransactionVerbose, len(b.Body.Transactions))
+	for i := range b.Body.Transactions {
+		txn, err := NewReadableTransactionVerbose(&b.Body.Transactions[i], inputs[i], prevTime)
+		if err != nil {
+			return ReadableBlockVerbose{}, err
+		}
+		txns[i] = txn
+	}
+
+	return ReadableBlockVerbose{
+		Head: NewReadableBlockHeader(&b.Head),
+		Body: ReadableBlockBodyVerbose{
+			Transactions: txns,
+		},
+	}, nil
+}
+
+// NewReadableBlocks creates readable blocks
+func NewReadableBlocks(blocks []coin.Block) []ReadableBlock {
+	rbs := make([]ReadableBlock, 0, len(blocks))
+	for i := range blocks {
+		rbs = append(rbs, NewReadableBlock(&blocks[i]))
+	}
+	return rbs
+}
+
+// ReadableBlocksPage represents a page of readable blocks in a seq range
+type ReadableBlocksPage struct {
+	Blocks []ReadableBlock `json:"blocks"`
+	// Number of blocks in the whole range
+	Total uint64 `json:"total"`
+	// Start seq of the next page, only valid when HasMore is true
+	NextCursor uint64 `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+/*
+	Transactions to and from JSON
+*/
+
+// TransactionOutputJSON  represents the transaction output json
+type TransactionOutputJSON struct {
+	Hash              string `json:"hash"`
+	SourceTransaction string `json:"src_tx"`
+	Address           string `json:"address"` // Address of receiver
+	Coins             string `json:"coins"`   // Number of coins
+	Hours             uint64 `json:"hours"`   // Coin hours
+}
+
+// NewTransactionOutputJSON creates transaction output json
+func NewTransactionOutputJSON(ux coin.TransactionOutput, srcTx cipher.SHA256) TransactionOutputJSON {
+	tmp := coin.UxOut{
+		Body: coin.UxBody{
+			SrcTransaction: srcTx,
+			Address:        ux.Address,
+			Coins:          ux.Coins,
+			Hours:          ux.Hours,
+		},
+	}
+
+	var o TransactionOutputJSON
+	o.Hash = tmp.Hash().Hex()
+	o.SourceTransaction = srcTx.Hex()
+
+	o.Address = ux.Address.String()
+	o.Coins = StrBalance(ux.Coins)
+	o.Hours = ux.Hours
+	return o
+}
+
+// TransactionOutputFromJSON load transaction output from json
+func TransactionOutputFromJSON(in TransactionOutputJSON) (coin.TransactionOutput, error) {
+	addr, err := cipher.DecodeBase58Address(in.Address)
+	if err != nil {
+		return coin.TransactionOutput{}, errors.New("Address decode fail")
+	}
+
+	coins, err := ParseStrBalance(in.Coins)
+	if err != nil {
+		return coin.TransactionOutput{}, err
+	}
+
+	return coin.TransactionOutput{
+		Address: addr,
+		Coins:   coins,
+		Hours:   in.Hours,
+	}, nil
+}
+
+// TransactionJSON represents transaction in json
+type TransactionJSON struct {
+	Hash      string `json:"hash"`
+	InnerHash string `json:"inner_hash"`
+
+	Sigs []string                `json:"sigs"`
+	In   []string                `json:"in"`
+	Out  []TransactionOutputJSON `json:"out"`
+}
+
+// TransactionToJSON convert transaction to json string
+func TransactionToJSON(tx coin.Transaction) string {
+
+	var o TransactionJSON
+
+	if err := tx.Verify(); err != nil {
+		logger.Panic("Input Transaction Invalid: Cannot serialize to JSON, fails verify")
+	}
+
+	o.Hash = tx.Hash().Hex()
+	o.InnerHash = tx.InnerHash.Hex()
+
+	if tx.InnerHash != tx.HashInner() {
+		logger.Panic("TransactionToJSON called with invalid transaction, inner hash mising")
+	}
+
+	o.Sigs = make([]string, len(tx.Sigs))
+	o.In = make([]string, len(tx.In))
+	o.Out = make([]TransactionOutputJSON, len(tx.Out))
+
+	for i, sig := range tx.Sigs {
+		o.Sigs[i] = sig.Hex()
+	}
+	for i, x := range tx.In {
+		o.In[i] = x.Hex() //hash to hex
+	}
+	txid := tx.Hash()
+	for i, y := range tx.Out {
+		o.Out[i] = NewTransactionOutputJSON(y, txid)
+	}
+
+	b, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		logger.Panic("Cannot serialize transaction as JSON")
+	}
+
+	return string(b)
+}
+
+// TransactionFromJSON load transaction from json string, the string should be
+// the format that TransactionToJSON generates.
+func TransactionFromJSON(str string) (coin.Transaction, error) {
+	return transactionFromJSON(str, false)
+}
+
+// TransactionFromJSONStrict is the same as TransactionFromJSON, except that
+// unknown json fields will be rejected.
+func TransactionFromJSONStrict(str string) (coin.Transaction, error) {
+	return transactionFromJSON(str, true)
+}
+
+func transactionFromJSON(str string, strict bool) (coin.Transaction, error) {
+	var txIn TransactionJSON
+	d := json.NewDecoder(strings.NewReader(str))
+	if strict {
+		d.DisallowUnknownFields()
+	}
+
+	if err := d.Decode(&txIn); err != nil {
+		return coin.Transaction{}, fmt.Errorf("cannot deserialize: %v", err)
+	}
+
+	tx := coin.Transaction{
+		Sigs: make([]cipher.Sig, len(txIn.Sigs)),
+		In:   make([]cipher.SHA256, len(txIn.In)),
+		Out:  make([]coin.TransactionOutput, len(txIn.Out)),
+	}
+
+	for i := range txIn.Sigs {
+		sig, err := cipher.SigFromHex(txIn.Sigs[i])
+		if err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid signature: %v", err)
+		}
+		tx.Sigs[i] = sig
+	}
+
+	for i := range txIn.In {
+		hash, err := cipher.SHA256FromHex(txIn.In[i])
+		if err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid input: %v", err)
+		}
+		tx.In[i] = hash
+	}
+
+	for i := range txIn.Out {
+		out, err := TransactionOutputFromJSON(txIn.Out[i])
+		if err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid output: %v", err)
+		}
+		tx.Out[i] = out
+	}
+
+	// recomputes the length, type and inner hash
+	tx.UpdateHeader()
+
+	if txIn.InnerHash != "" {
+		innerHash, err := cipher.SHA256FromHex(txIn.InnerHash)
+		if err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid inner hash: %v", err)
+		}
+
+		if innerHash != tx.InnerHash {
+			return coin.Transaction{}, errors.New("inner hash does not match")
+		}
+	}
+
+	if txIn.Hash != "" {
+		hash, err := cipher.SHA256FromHex(txIn.Hash)
+		if err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid hash: %v", err)
+		}
+
+		if hash != tx.Hash() {
+			return coin.Transaction{}, errors.New("transaction hash does not match")
+		}
+	}
+
+	if err := tx.Verify(); err != nil {
+		return coin.Transaction{}, fmt.Errorf("transaction failed verification: %v", err)
+	}
+
+	return tx, nil
+}
+
+// TransactionToHex serializes the transaction to hex string
+func TransactionToHex(tx coin.Transaction) string {
+	return hex.EncodeToString(tx.Serialize())
+}
+
+// TransactionFromHex decodes the hex string generated by TransactionToHex,
+// the transaction will be verified before returning.
+func TransactionFromHex(str string) (coin.Transaction, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(str))
+	if err != nil {
+		return coin.Transaction{}, fmt.Errorf("invalid hex: %v", err)
+	}
+
+	var tx coin.Transaction
+	if err := encoder.DeserializeRaw(b, &tx); err != nil {
+		return coin.Transaction{}, fmt.Errorf("cannot deserialize: %v", err)
+	}
+
+	if err := tx.Verify(); err != nil {
+		return coin.Transaction{}, fmt.Errorf("transaction failed verification: %v", err)
+	}
+
+	return tx, nil
+}
@@ -0,0 +1,418 @@
+package visor
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+)
+
+// Protobuf encoding of the readable types. Each type is a proto3 message whose fields are
+// its json keys numbered in order, the field numbers are those used by ToProto and must
+// not change. No protobuf library is vendored, so the wire format is implemented here,
+// JSON is still the default encoding of the readable types.
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+var errProtoTruncated = errors.New("proto: truncated message")
+
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
+}
+
+func appendProtoKey(b []byte, field int, wireType int) []byte {
+	return appendUvarint(b, uint64(field)<<3|uint64(wireType))
+}
+
+// appendProtoVarint appends the varint field, zero value is omitted as proto3 does
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	if v == 0 {
+		return b
+	}
+	b = appendProtoKey(b, field, wireVarint)
+	return appendUvarint(b, v)
+}
+
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendProtoKey(b, field, wireBytes)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// appendProtoString appends the string field, empty string is omitted as proto3 does
+func appendProtoString(b []byte, field int, s string) []byte {
+	if s == "" {
+		return b
+	}
+	return appendProtoBytes(b, field, []byte(s))
+}
+
+// appendProtoStrings appends the repeated string field, empty strings are kept
+func appendProtoStrings(b []byte, field int, ss []string) []byte {
+	for _, s := range ss {
+		b = appendProtoBytes(b, field, []byte(s))
+	}
+	return b
+}
+
+// protoReader iterates the fields of a protobuf message
+type protoReader struct {
+	b []byte
+}
+
+func (pr *protoReader) done() bool {
+	return len(pr.b) == 0
+}
+
+func (pr *protoReader) varint() (uint64, error) {
+	v, n := binary.Uvarint(pr.b)
+	if n <= 0 {
+		return 0, errProtoTruncated
+	}
+	pr.b = pr.b[n:]
+	return v, nil
+}
+
+// next reads the key of next field
+func (pr *protoReader) next() (int, int, error) {
+	k, err := pr.varint()
+	if err != nil {
+		return 0, 0, err
+	}
+	return int(k >> 3), int(k & 7), nil
+}
+
+func (pr *protoReader) bytes() ([]byte, error) {
+	n, err := pr.varint()
+	if err != nil {
+		return nil, err
+	}
+	if n > uint64(len(pr.b)) {
+		return nil, errProtoTruncated
+	}
+	v := pr.b[:n]
+	pr.b = pr.b[n:]
+	return v, nil
+}
+
+func (pr *protoReader) string() (string, error) {
+	v, err := pr.bytes()
+	return string(v), err
+}
+
+func (pr *protoReader) uint32() (uint32, error) {
+	v, err := pr.varint()
+	if err != nil {
+		return 0, err
+	}
+	if v > math.MaxUint32 {
+		return 0, errors.New("proto: uint32 overflow")
+	}
+	return uint32(v), nil
+}
+
+// skip skips the value of unknown field, so that newer messages can still be decoded
+func (pr *protoReader) skip(wireType int) error {
+	var n int
+	switch wireType {
+	case wireVarint:
+		_, err := pr.varint()
+		return err
+	case wireBytes:
+		_, err := pr.bytes()
+		return err
+	case wireFixed64:
+		n = 8
+	case wireFixed32:
+		n = 4
+	default:
+		return fmt.Errorf("proto: unsupported wire type %d", wireType)
+	}
+
+	if len(pr.b) < n {
+		return errProtoTruncated
+	}
+	pr.b = pr.b[n:]
+	return nil
+}
+
+// decodeProto calls fn for each field of the message, fn returns false if the
+// field is unknown, which will be skipped.
+func decodeProto(b []byte, fn func(pr *protoReader, field, wireType int) (bool, error)) error {
+	pr := &protoReader{b: b}
+	for !pr.done() {
+		field, wireType, err := pr.next()
+		if err != nil {
+			return err
+		}
+
+		ok, err := fn(pr, field, wireType)
+		if err != nil {
+			return fmt.Errorf("proto: decode field %d failed: %v", field, err)
+		}
+
+		if !ok {
+			if err := pr.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ToProto encodes the readable output as protobuf message
+func (ro ReadableOutput) ToProto() []byte {
+	var b []byte
+	b = appendProtoString(b, 1, ro.Hash)
+	b = appendProtoString(b, 2, ro.SourceTransaction)
+	b = appendProtoString(b, 3, ro.Address)
+	b = appendProtoString(b, 4, ro.Coins)
+	b = appendProtoVarint(b, 5, ro.Hours)
+	b = appendProtoVarint(b, 6, ro.CalculatedHours)
+	return b
+}
+
+// FromProto decodes the readable output from protobuf message
+func (ro *ReadableOutput) FromProto(b []byte) error {
+	*ro = ReadableOutput{}
+	return decodeProto(b, func(pr *protoReader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			ro.Hash, err = pr.string()
+		case field == 2 && wireType == wireBytes:
+			ro.SourceTransaction, err = pr.string()
+		case field == 3 && wireType == wireBytes:
+			ro.Address, err = pr.string()
+		case field == 4 && wireType == wireBytes:
+			ro.Coins, err = pr.string()
+		case field == 5 && wireType == wireVarint:
+			ro.Hours, err = pr.varint()
+		case field == 6 && wireType == wireVarint:
+			ro.CalculatedHours, err = pr.varint()
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+// ToProto encodes the readable transaction output as protobuf message
+func (ro ReadableTransactionOutput) ToProto() []byte {
+	var b []byte
+	b = appendProtoString(b, 1, ro.Hash)
+	b = appendProtoString(b, 2, ro.Address)
+	b = appendProtoString(b, 3, ro.Coins)
+	b = appendProtoVarint(b, 4, ro.Hours)
+	return b
+}
+
+// FromProto decodes the readable transaction output from protobuf message
+func (ro *ReadableTransactionOutput) FromProto(b []byte) error {
+	*ro = ReadableTransactionOutput{}
+	return decodeProto(b, func(pr *protoReader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireBytes:
+			ro.Hash, err = pr.string()
+		case field == 2 && wireType == wireBytes:
+			ro.Address, err = pr.string()
+		case field == 3 && wireType == wireBytes:
+			ro.Coins, err = pr.string()
+		case field == 4 && wireType == wireVarint:
+			ro.Hours, err = pr.varint()
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+// ToProto encodes the readable transaction as protobuf message
+func (rt ReadableTransaction) ToProto() []byte {
+	var b []byte
+	b = appendProtoVarint(b, 1, uint64(rt.Length))
+	b = appendProtoVarint(b, 2, uint64(rt.Type))
+	b = appendProtoString(b, 3, rt.Hash)
+	b = appendProtoString(b, 4, rt.InnerHash)
+	b = appendProtoVarint(b, 5, rt.Timestamp)
+	b = appendProtoString(b, 6, rt.TimestampISO)
+	b = appendProtoVarint(b, 7, rt.Fee)
+	if rt.BlockSeq != nil {
+		// the genesis block seq 0 is encoded too
+		b = appendProtoKey(b, 8, wireVarint)
+		b = appendUvarint(b, *rt.BlockSeq)
+	}
+	b = appendProtoStrings(b, 9, rt.Sigs)
+	b = appendProtoStrings(b, 10, rt.In)
+	for _, o := range rt.Out {
+		b = appendProtoBytes(b, 11, o.ToProto())
+	}
+	return b
+}
+
+// FromProto decodes the readable transaction from protobuf message
+func (rt *ReadableTransaction) FromProto(b []byte) error {
+	*rt = ReadableTransaction{
+		Sigs: []string{},
+		In:   []string{},
+		Out:  []ReadableTransactionOutput{},
+	}
+	return decodeProto(b, func(pr *protoReader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireVarint:
+			rt.Length, err = pr.uint32()
+		case field == 2 && wireType == wireVarint:
+			var v uint64
+			if v, err = pr.varint(); err != nil {
+				return true, err
+			}
+			if v > math.MaxUint8 {
+				return true, errors.New("type overflows uint8")
+			}
+			rt.Type = uint8(v)
+		case field == 3 && wireType == wireBytes:
+			rt.Hash, err = pr.string()
+		case field == 4 && wireType == wireBytes:
+			rt.InnerHash, err = pr.string()
+		case field == 5 && wireType == wireVarint:
+			rt.Timestamp, err = pr.varint()
+		case field == 6 && wireType == wireBytes:
+			rt.TimestampISO, err = pr.string()
+		case field == 7 && wireType == wireVarint:
+			rt.Fee, err = pr.varint()
+		case field == 8 && wireType == wireVarint:
+			var v uint64
+			if v, err = pr.varint(); err != nil {
+				return true, err
+			}
+			rt.BlockSeq = &v
+		case field == 9 && wireType == wireBytes:
+			var s string
+			s, err = pr.string()
+			rt.Sigs = append(rt.Sigs, s)
+		case field == 10 && wireType == wireBytes:
+			var s string
+			s, err = pr.string()
+			rt.In = append(rt.In, s)
+		case field == 11 && wireType == wireBytes:
+			var v []byte
+			if v, err = pr.bytes(); err != nil {
+				return true, err
+			}
+			var o ReadableTransactionOutput
+			err = o.FromProto(v)
+			rt.Out = append(rt.Out, o)
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+// ToProto encodes the readable block header as protobuf message
+func (rh ReadableBlockHeader) ToProto() []byte {
+	var b []byte
+	b = appendProtoVarint(b, 1, rh.BkSeq)
+	b = appendProtoString(b, 2, rh.BlockHash)
+	b = appendProtoString(b, 3, rh.PreviousBlockHash)
+	b = appendProtoVarint(b, 4, rh.Time)
+	b = appendProtoString(b, 5, rh.TimeISO)
+	b = appendProtoVarint(b, 6, rh.Fee)
+	b = appendProtoVarint(b, 7, uint64(rh.Version))
+	b = appendProtoString(b, 8, rh.BodyHash)
+	b = appendProtoString(b, 9, rh.UxHash)
+	return b
+}
+
+// FromProto decodes the readable block header from protobuf message
+func (rh *ReadableBlockHeader) FromProto(b []byte) error {
+	*rh = ReadableBlockHeader{}
+	return decodeProto(b, func(pr *protoReader, field, wireType int) (bool, error) {
+		var err error
+		switch {
+		case field == 1 && wireType == wireVarint:
+			rh.BkSeq, err = pr.varint()
+		case field == 2 && wireType == wireBytes:
+			rh.BlockHash, err = pr.string()
+		case field == 3 && wireType == wireBytes:
+			rh.PreviousBlockHash, err = pr.string()
+		case field == 4 && wireType == wireVarint:
+			rh.Time, err = pr.varint()
+		case field == 5 && wireType == wireBytes:
+			rh.TimeISO, err = pr.string()
+		case field == 6 && wireType == wireVarint:
+			rh.Fee, err = pr.varint()
+		case field == 7 && wireType == wireVarint:
+			rh.Version, err = pr.uint32()
+		case field == 8 && wireType == wireBytes:
+			rh.BodyHash, err = pr.string()
+		case field == 9 && wireType == wireBytes:
+			rh.UxHash, err = pr.string()
+		default:
+			return false, nil
+		}
+		return true, err
+	})
+}
+
+// ToProto encodes the readable block as protobuf message, the body is a nested message
+// whose field 1 is the repeated transactions
+func (rb ReadableBlock) ToProto() []byte {
+	var body []byte
+	for _, txn := range rb.Body.Transactions {
+		body = appendProtoBytes(body, 1, txn.ToProto())
+	}
+
+	var b []byte
+	b = appendProtoBytes(b, 1, rb.Head.ToProto())
+	b = appendProtoBytes(b, 2, body)
+	return b
+}
+
+// FromProto decodes the readable block from protobuf message
+func (rb *ReadableBlock) FromProto(b []byte) error {
+	*rb = ReadableBlock{
+		Body: ReadableBlockBody{
+			Transactions: []ReadableTransaction{},
+		},
+	}
+	return decodeProto(b, func(pr *protoReader, field, wireType int) (bool, error) {
+		if wireType != wireBytes || (field != 1 && field != 2) {
+			return false, nil
+		}
+
+		v, err := pr.bytes()
+		if err != nil {
+			return true, err
+		}
+
+		if field == 1 {
+			return true, rb.Head.FromProto(v)
+		}
+
+		return true, decodeProto(v, func(pr *protoReader, field, wireType int) (bool, error) {
+			if field != 1 || wireType != wireBytes {
+				return false, nil
+			}
+
+			v, err := pr.bytes()
+			if err != nil {
+				return true, err
+			}
+
+			var txn ReadableTransaction
+			err = txn.FromProto(v)
+			rb.Body.Transactions = append(rb.Body.Transactions, txn)
+			return true, err
+		})
+	})
+}
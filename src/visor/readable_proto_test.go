@@ -0,0 +1,64 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestReadableBlockProto(t *testing.T) {
+	p, s := cipher.GenerateKeyPair()
+	tx := coin.Transaction{}
+	tx.PushInput(randSHA256())
+	tx.PushOutput(cipher.AddressFromPubKey(p), 10e6, 100)
+	tx.PushOutput(cipher.AddressFromPubKey(p), 1e6, 0)
+	tx.SignInputs([]cipher.SecKey{s})
+	tx.UpdateHeader()
+
+	b := coin.Block{
+		Head: coin.BlockHeader{BkSeq: 2, Time: 1502870712, Fee: 10, UxHash: randSHA256()},
+		Body: coin.BlockBody{Transactions: coin.Transactions{tx}},
+	}
+
+	rb := NewReadableBlockWithOptions(&b, ReadableEncodeOptions{HumanTime: true})
+	var rb2 ReadableBlock
+	assert.Nil(t, rb2.FromProto(rb.ToProto()))
+	assert.Equal(t, rb, rb2)
+
+	rt := NewReadableTransaction(&Transaction{Txn: tx, Time: 100, Fee: 5})
+	var rt2 ReadableTransaction
+	assert.Nil(t, rt2.FromProto(rt.ToProto()))
+	assert.Equal(t, rt, rt2)
+	assert.Nil(t, rt2.BlockSeq)
+
+	// the block seq 0 of genesis txn is kept
+	rt = NewReadableTransaction(&Transaction{Txn: tx, Status: NewConfirmedTransactionStatus(1, 0)})
+	assert.Nil(t, rt2.FromProto(rt.ToProto()))
+	assert.Equal(t, rt, rt2)
+	assert.Equal(t, uint64(0), *rt2.BlockSeq)
+
+	ro := ReadableOutput{
+		Hash:              randSHA256().Hex(),
+		SourceTransaction: randSHA256().Hex(),
+		Address:           cipher.AddressFromPubKey(p).String(),
+		Coins:             "1.5",
+		Hours:             20,
+		CalculatedHours:   35,
+	}
+	var ro2 ReadableOutput
+	assert.Nil(t, ro2.FromProto(ro.ToProto()))
+	assert.Equal(t, ro, ro2)
+
+	// unknown fields are skipped
+	ext := appendProtoString(ro.ToProto(), 100, "unknown")
+	ext = appendProtoVarint(ext, 101, 1)
+	assert.Nil(t, ro2.FromProto(ext))
+	assert.Equal(t, ro, ro2)
+
+	// truncated message
+	pb := rb.ToProto()
+	assert.NotNil(t, rb2.FromProto(pb[:len(pb)-1]))
+}
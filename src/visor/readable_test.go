@@ -2,11 +2,17 @@ package visor
 
 import (
 	"crypto/rand"
+	"encoding/json"
+	"testing"
 	"time"
 
+	"github.com/stretchr/testify/assert"
+
 	"github.com/skycoin/skycoin/src/cipher"
 	"github.com/skycoin/skycoin/src/coin"
 	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+	"github.com/skycoin/skycoin/src/wallet"
 )
 
 const (
@@ -354,3 +360,238 @@ func addUnconfirmedTxnToPool(utp *UnconfirmedTxnPool) UnconfirmedTxn {
 // 	rb := NewReadableBlock(&b)
 // 	assertReadableBlock(t, rb, b)
 // }
+
+func TestNewReadableOutputVerbose(t *testing.T) {
+	p, _ := cipher.GenerateKeyPair()
+	ux := historydb.UxOut{
+		Out: coin.UxOut{
+			Head: coin.UxHead{Time: 1000, BkSeq: 2},
+			Body: coin.UxBody{
+				SrcTransaction: randSHA256(),
+				Address:        cipher.AddressFromPubKey(p),
+				Coins:          10e6,
+				Hours:          100,
+			},
+		},
+	}
+
+	ro := NewReadableOutputVerbose(ux)
+	assert.Equal(t, ux.Hash().Hex(), ro.Hash)
+	assert.Equal(t, ux.Out.Body.SrcTransaction.Hex(), ro.SourceTransaction)
+	assert.Equal(t, uint64(2), ro.SrcBlockSeq)
+	assert.Equal(t, uint64(1000), ro.Time)
+	assert.Equal(t, "10", ro.Coins)
+	assert.False(t, ro.Spent)
+	assert.Empty(t, ro.SpentTxID)
+
+	ux.SpentTxID = randSHA256()
+	ux.SpentBlockSeq = 5
+	ro = NewReadableOutputVerbose(ux)
+	assert.True(t, ro.Spent)
+	assert.Equal(t, ux.SpentTxID.Hex(), ro.SpentTxID)
+	assert.Equal(t, uint64(5), ro.SpentBlockSeq)
+}
+
+func TestTransactionJSONRoundTrip(t *testing.T) {
+	p, s := cipher.GenerateKeyPair()
+	tx := coin.Transaction{}
+	tx.PushInput(randSHA256())
+	tx.PushOutput(cipher.AddressFromPubKey(p), 10e6, 100)
+	tx.PushOutput(cipher.AddressFromPubKey(p), 5e6, 50)
+	tx.SignInputs([]cipher.SecKey{s})
+	tx.UpdateHeader()
+
+	str := TransactionToJSON(tx)
+
+	tx2, err := TransactionFromJSON(str)
+	assert.Nil(t, err)
+	assert.Equal(t, tx, tx2)
+
+	tx2, err = TransactionFromJSONStrict(str)
+	assert.Nil(t, err)
+	assert.Equal(t, tx, tx2)
+
+	// unknown fields are only rejected in strict mode
+	extra := `{"extra":1,` + str[1:]
+	_, err = TransactionFromJSON(extra)
+	assert.Nil(t, err)
+	_, err = TransactionFromJSONStrict(extra)
+	assert.NotNil(t, err)
+}
+
+func TestParseStrBalance(t *testing.T) {
+	for _, n := range []uint64{0, 1, 10, 1000000, 1000010, 1234567, 100e6} {
+		v, err := ParseStrBalance(StrBalance(n))
+		assert.Nil(t, err)
+		assert.Equal(t, n, v)
+	}
+
+	for _, s := range []string{"", ".1", "1.", "1.0000001", "a", "1.2.3", "-1"} {
+		_, err := ParseStrBalance(s)
+		assert.NotNil(t, err, s)
+	}
+}
+
+func TestTransactionHexRoundTrip(t *testing.T) {
+	p, s := cipher.GenerateKeyPair()
+	tx := coin.Transaction{}
+	tx.PushInput(randSHA256())
+	tx.PushOutput(cipher.AddressFromPubKey(p), 10e6, 100)
+	tx.SignInputs([]cipher.SecKey{s})
+	tx.UpdateHeader()
+
+	tx2, err := TransactionFromHex(TransactionToHex(tx))
+	assert.Nil(t, err)
+	assert.Equal(t, tx, tx2)
+
+	_, err = TransactionFromHex("zz")
+	assert.NotNil(t, err)
+	_, err = TransactionFromHex("dc000000")
+	assert.NotNil(t, err)
+}
+
+func TestNewReadableBlockVerbose(t *testing.T) {
+	p, s := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(p)
+	ux := &historydb.UxOut{
+		Out: coin.UxOut{
+			Head: coin.UxHead{Time: 1000, BkSeq: 1},
+			Body: coin.UxBody{
+				SrcTransaction: randSHA256(),
+				Address:        addr,
+				Coins:          10e6,
+				Hours:          100,
+			},
+		},
+	}
+
+	tx := coin.Transaction{}
+	tx.PushInput(ux.Hash())
+	tx.PushOutput(addr, 10e6, 50)
+	tx.SignInputs([]cipher.SecKey{s})
+	tx.UpdateHeader()
+
+	b := coin.Block{
+		Head: coin.BlockHeader{BkSeq: 2, Time: 2000},
+		Body: coin.BlockBody{Transactions: coin.Transactions{tx}},
+	}
+
+	rb, err := NewReadableBlockVerbose(&b, 1000+3600, [][]*historydb.UxOut{{ux}})
+	assert.Nil(t, err)
+	assert.Len(t, rb.Body.Transactions, 1)
+	rt := rb.Body.Transactions[0]
+	assert.Equal(t, tx.Hash().Hex(), rt.Hash)
+	assert.Len(t, rt.In, 1)
+	assert.Equal(t, ux.Hash().Hex(), rt.In[0].Hash)
+	assert.Equal(t, addr.String(), rt.In[0].Address)
+	assert.Equal(t, "10", rt.In[0].Coins)
+	assert.Equal(t, uint64(100), rt.In[0].Hours)
+	assert.Equal(t, ux.Out.CoinHours(1000+3600), rt.In[0].CalculatedHours)
+	assert.Equal(t, rt.In[0].CalculatedHours-50, rt.Fee)
+
+	// the inputs must match the transaction
+	_, err = NewReadableBlockVerbose(&b, 1000, [][]*historydb.UxOut{})
+	assert.NotNil(t, err)
+	other := *ux
+	other.Out.Body.Coins = 20e6
+	_, err = NewReadableBlockVerbose(&b, 1000, [][]*historydb.UxOut{{&other}})
+	assert.NotNil(t, err)
+}
+
+func TestNewReadableBalances(t *testing.T) {
+	bals := map[string]wallet.BalancePair{
+		"a": {
+			Confirmed: wallet.NewBalance(1500000, 10),
+			Predicted: wallet.NewBalance(1000000, 5),
+		},
+		"b": {},
+	}
+
+	rbs := NewReadableBalances(bals)
+	assert.Len(t, rbs, 2)
+	assert.Equal(t, ReadableBalance{Coins: "1.5", Hours: 10}, rbs["a"].Confirmed)
+	assert.Equal(t, ReadableBalance{Coins: "1", Hours: 5}, rbs["a"].Predicted)
+	assert.Equal(t, ReadableBalance{Coins: "0", Hours: 0}, rbs["b"].Confirmed)
+}
+
+func TestReadableEncodeOptions(t *testing.T) {
+	bh := coin.BlockHeader{BkSeq: 1, Time: 1502870712}
+	rh := NewReadableBlockHeader(&bh)
+	assert.Equal(t, uint64(1502870712), rh.Time)
+	assert.Empty(t, rh.TimeISO)
+
+	rh = NewReadableBlockHeaderWithOptions(&bh, ReadableEncodeOptions{HumanTime: true})
+	assert.Equal(t, uint64(1502870712), rh.Time)
+	assert.Equal(t, "2017-08-16T08:05:12Z", rh.TimeISO)
+
+	tx := &Transaction{Time: 1502870712}
+	assert.Empty(t, NewReadableTransaction(tx).TimestampISO)
+	rt := NewReadableTransactionWithOptions(tx, ReadableEncodeOptions{HumanTime: true})
+	assert.Equal(t, "2017-08-16T08:05:12Z", rt.TimestampISO)
+
+	// transactions without timestamp don't emit the human time
+	rt = NewReadableTransactionWithOptions(&Transaction{}, ReadableEncodeOptions{HumanTime: true})
+	assert.Empty(t, rt.TimestampISO)
+}
+
+func TestReadableEncodeOptionsDecimalCoins(t *testing.T) {
+	bp := wallet.BalancePair{
+		Confirmed: wallet.Balance{Coins: 12345678, Hours: 10},
+		Predicted: wallet.Balance{Coins: 2000000, Hours: 5},
+	}
+	assert.Equal(t, bp, ReadableEncodeOptions{}.BalancePair(bp))
+	assert.Equal(t, ReadableBalancePair{
+		Confirmed: ReadableBalance{Coins: "12.345678", Hours: 10},
+		Predicted: ReadableBalance{Coins: "2", Hours: 5},
+	}, ReadableEncodeOptions{DecimalCoins: true}.BalancePair(bp))
+
+	ux := &historydb.UxOutJSON{
+		Uxid:         randSHA256().Hex(),
+		Time:         1502870712,
+		SrcBkSeq:     10,
+		OwnerAddress: "2fGC7kwAM9yZyEF1QqBqp8uo9RUsF6ENGJF",
+		Coins:        1500000,
+		Hours:        20,
+	}
+	assert.Equal(t, ux, ReadableEncodeOptions{}.UxOut(ux))
+
+	rux := ReadableEncodeOptions{DecimalCoins: true}.UxOut(ux).(ReadableUxOut)
+	assert.Equal(t, "1.5", rux.Coins)
+	assert.Equal(t, ux.Uxid, rux.Uxid)
+	assert.Equal(t, ux.Hours, rux.Hours)
+
+	uxs := []*historydb.UxOutJSON{ux}
+	assert.Equal(t, uxs, ReadableEncodeOptions{}.UxOuts(uxs))
+	assert.Equal(t, []ReadableUxOut{rux}, ReadableEncodeOptions{DecimalCoins: true}.UxOuts(uxs))
+}
+
+func TestReadableTransactionBlockSeq(t *testing.T) {
+	tx := &Transaction{Status: NewUnconfirmedTransactionStatus()}
+	rt := NewReadableTransaction(tx)
+	assert.Nil(t, rt.BlockSeq)
+	d, err := json.Marshal(rt)
+	assert.NoError(t, err)
+	assert.NotContains(t, string(d), "block_seq")
+
+	// the genesis txn is executed in block 0
+	tx.Status = NewConfirmedTransactionStatus(1, 0)
+	rt = NewGenesisReadableTransaction(tx)
+	assert.Equal(t, uint64(0), *rt.BlockSeq)
+	d, err = json.Marshal(rt)
+	assert.NoError(t, err)
+	assert.Contains(t, string(d), `"block_seq":0`)
+}
+
+func TestNewConfirmedTransactionStatusFromBlock(t *testing.T) {
+	b := coin.Block{Head: coin.BlockHeader{BkSeq: 3}}
+
+	st, err := NewConfirmedTransactionStatusFromBlock(5, &b)
+	assert.NoError(t, err)
+	assert.True(t, st.Confirmed)
+	assert.Equal(t, uint64(3), st.Confirmations)
+	assert.Equal(t, uint64(3), st.BlockSeq)
+
+	// the chain is rolled back below the block
+	_, err = NewConfirmedTransactionStatusFromBlock(2, &b)
+	assert.Error(t, err)
+}
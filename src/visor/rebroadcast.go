@@ -0,0 +1,181 @@
+package visor
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/utc"
+)
+
+// maxRebroadcastDelay caps the backoff between the rebroadcasts of a txn
+const maxRebroadcastDelay = time.Hour
+
+// PendingRebroadcast represents a locally created txn that will be rebroadcasted until
+// it's confirmed or expired
+type PendingRebroadcast struct {
+	Txid string `json:"txid"`
+	// Number of rebroadcasts so far
+	Attempts      int       `json:"attempts"`
+	Added         time.Time `json:"added"`
+	LastBroadcast time.Time `json:"last_broadcast"`
+	NextBroadcast time.Time `json:"next_broadcast"`
+	Expires       time.Time `json:"expires"`
+}
+
+type rebroadcastEntry struct {
+	attempts int
+	added    time.Time
+	last     time.Time
+	next     time.Time
+}
+
+// rebroadcaster schedules the rebroadcasts of txns with exponential backoff, the delay
+// starts from initialDelay and is doubled after each rebroadcast.
+type rebroadcaster struct {
+	sync.Mutex
+	txns         map[cipher.SHA256]*rebroadcastEntry
+	initialDelay time.Duration
+	maxDelay     time.Duration
+	maxAge       time.Duration
+}
+
+func newRebroadcaster(initialDelay, maxDelay, maxAge time.Duration) *rebroadcaster {
+	return &rebroadcaster{
+		txns:         make(map[cipher.SHA256]*rebroadcastEntry),
+		initialDelay: initialDelay,
+		maxDelay:     maxDelay,
+		maxAge:       maxAge,
+	}
+}
+
+// add schedules the txn that was just broadcasted, does nothing if it's already scheduled
+func (rb *rebroadcaster) add(hash cipher.SHA256, now time.Time) {
+	rb.Lock()
+	defer rb.Unlock()
+
+	if _, ok := rb.txns[hash]; ok {
+		return
+	}
+
+	rb.txns[hash] = &rebroadcastEntry{
+		added: now,
+		last:  now,
+		next:  now.Add(rb.initialDelay),
+	}
+}
+
+// remove cancels the rebroadcasts of txn, returns false if it's not scheduled
+func (rb *rebroadcaster) remove(hash cipher.SHA256) bool {
+	rb.Lock()
+	defer rb.Unlock()
+
+	_, ok := rb.txns[hash]
+	delete(rb.txns, hash)
+	return ok
+}
+
+// due returns the txns that should be rebroadcasted now and reschedules them, the
+// expired txns are removed.
+func (rb *rebroadcaster) due(now time.Time) []cipher.SHA256 {
+	rb.Lock()
+	defer rb.Unlock()
+
+	var hashes []cipher.SHA256
+	for h, e := range rb.txns {
+		if rb.maxAge > 0 && now.Sub(e.added) > rb.maxAge {
+			delete(rb.txns, h)
+			continue
+		}
+
+		if now.Before(e.next) {
+			continue
+		}
+
+		e.attempts++
+		e.last = now
+		e.next = now.Add(rb.delay(e.attempts))
+		hashes = append(hashes, h)
+	}
+
+	return hashes
+}
+
+// delay returns the backoff after the number of attempts
+func (rb *rebroadcaster) delay(attempts int) time.Duration {
+	d := rb.initialDelay
+	for i := 0; i < attempts; i++ {
+		d *= 2
+		if d >= rb.maxDelay {
+			return rb.maxDelay
+		}
+	}
+	return d
+}
+
+// list returns the pending rebroadcasts sorted by the next broadcast time
+func (rb *rebroadcaster) list() []PendingRebroadcast {
+	rb.Lock()
+	defer rb.Unlock()
+
+	prs := make([]PendingRebroadcast, 0, len(rb.txns))
+	for h, e := range rb.txns {
+		pr := PendingRebroadcast{
+			Txid:          h.Hex(),
+			Attempts:      e.attempts,
+			Added:         e.added,
+			LastBroadcast: e.last,
+			NextBroadcast: e.next,
+		}
+		if rb.maxAge > 0 {
+			pr.Expires = e.added.Add(rb.maxAge)
+		}
+		prs = append(prs, pr)
+	}
+
+	sort.Sort(byNextBroadcast(prs))
+	return prs
+}
+
+type byNextBroadcast []PendingRebroadcast
+
+func (bn byNextBroadcast) Len() int      { return len(bn) }
+func (bn byNextBroadcast) Swap(i, j int) { bn[i], bn[j] = bn[j], bn[i] }
+func (bn byNextBroadcast) Less(i, j int) bool {
+	if bn[i].NextBroadcast.Equal(bn[j].NextBroadcast) {
+		return bn[i].Txid < bn[j].Txid
+	}
+	return bn[i].NextBroadcast.Before(bn[j].NextBroadcast)
+}
+
+// TrackRebroadcast schedules the rebroadcasts of the locally created txn
+func (vs *Visor) TrackRebroadcast(hash cipher.SHA256) {
+	vs.rebroadcaster.add(hash, utc.Now())
+}
+
+// DueRebroadcasts returns the locally created txns that should be rebroadcasted now,
+// the txns that are no longer in the unconfirmed pool are not rebroadcasted any more.
+func (vs *Visor) DueRebroadcasts() coin.Transactions {
+	var txns coin.Transactions
+	for _, h := range vs.rebroadcaster.due(utc.Now()) {
+		ut, ok := vs.Unconfirmed.Get(h)
+		if !ok {
+			vs.rebroadcaster.remove(h)
+			continue
+		}
+		txns = append(txns, ut.Txn)
+	}
+	return txns
+}
+
+// GetPendingRebroadcasts returns the locally created txns that are waiting for confirmation
+func (vs *Visor) GetPendingRebroadcasts() []PendingRebroadcast {
+	return vs.rebroadcaster.list()
+}
+
+// CancelRebroadcast stops rebroadcasting the txn, returns false if it's not pending
+func (vs *Visor) CancelRebroadcast(hash cipher.SHA256) bool {
+	return vs.rebroadcaster.remove(hash)
+}
@@ -0,0 +1,54 @@
+package visor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestRebroadcaster(t *testing.T) {
+	now := time.Unix(1502870712, 0)
+	rb := newRebroadcaster(time.Minute, time.Minute*5, time.Hour)
+	h1, h2 := randSHA256(), randSHA256()
+
+	rb.add(h1, now)
+	// adding again doesn't reset the schedule
+	rb.add(h1, now.Add(time.Second*40))
+
+	assert.Empty(t, rb.due(now.Add(time.Second*59)))
+	assert.Equal(t, []cipher.SHA256{h1}, rb.due(now.Add(time.Minute)))
+
+	// the delay is doubled after each rebroadcast, and capped by the max delay
+	expect := []time.Duration{time.Minute * 2, time.Minute * 4, time.Minute * 5, time.Minute * 5}
+	at := now.Add(time.Minute)
+	for i, d := range expect {
+		prs := rb.list()
+		assert.Len(t, prs, 1)
+		assert.Equal(t, h1.Hex(), prs[0].Txid)
+		assert.Equal(t, i+1, prs[0].Attempts)
+		assert.Equal(t, at, prs[0].LastBroadcast)
+		assert.Equal(t, at.Add(d), prs[0].NextBroadcast)
+		assert.Equal(t, now.Add(time.Hour), prs[0].Expires)
+
+		assert.Empty(t, rb.due(at.Add(d-time.Second)))
+		at = at.Add(d)
+		assert.Equal(t, []cipher.SHA256{h1}, rb.due(at))
+	}
+
+	// sorted by the next broadcast time
+	rb.add(h2, now)
+	prs := rb.list()
+	assert.Len(t, prs, 2)
+	assert.Equal(t, h2.Hex(), prs[0].Txid)
+	assert.Equal(t, h1.Hex(), prs[1].Txid)
+
+	assert.True(t, rb.remove(h2))
+	assert.False(t, rb.remove(h2))
+
+	// expired txns are removed
+	assert.Empty(t, rb.due(now.Add(time.Hour+time.Second)))
+	assert.Empty(t, rb.list())
+}
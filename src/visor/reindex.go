@@ -0,0 +1,61 @@
+package visor
+
+import "errors"
+
+var (
+	// ErrRebuildRunning is returned if the indexes are being rebuilt
+	ErrRebuildRunning = errors.New("the indexes are being rebuilt")
+	// ErrRebuildPruned is returned if the node is pruned, the history can't be parsed
+	// from the pruned blocks
+	ErrRebuildPruned = errors.New("the indexes can't be rebuilt from the pruned blocks")
+)
+
+// IndexProgress is the progress of rebuilding the indexes
+type IndexProgress struct {
+	Running bool `json:"running"`
+	// seq of the last block parsed into the history db, -1 if none is parsed
+	ParsedSeq int64  `json:"parsed_seq"`
+	HeadSeq   uint64 `json:"head_seq"`
+	// unix times the rebuild started and finished
+	StartedAt  int64  `json:"started_at"`
+	FinishedAt int64  `json:"finished_at"`
+	Error      string `json:"error,omitempty"`
+}
+
+// StartRebuildIndexes drops the address index of the unspent outputs and the history db,
+// which indexes the transactions, outputs and addresses, and rebuilds them from the
+// blocks. The history is rebuilt in the background while the node runs, the history
+// apis return partial results until it's done.
+func (vs *Visor) StartRebuildIndexes() error {
+	if vs.Config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if vs.PrunedSeq() > 0 {
+		return ErrRebuildPruned
+	}
+
+	if !vs.bcParser.StartRebuild() {
+		return ErrRebuildRunning
+	}
+	return nil
+}
+
+// RebuildIndexes rebuilds the indexes like StartRebuildIndexes and waits till it's done,
+// the visor must not be running. progress is called as the blocks are parsed.
+func (vs *Visor) RebuildIndexes(progress func(IndexProgress)) error {
+	if vs.Config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if vs.PrunedSeq() > 0 {
+		return ErrRebuildPruned
+	}
+
+	return vs.bcParser.Rebuild(progress)
+}
+
+// GetIndexProgress returns the progress of rebuilding the indexes
+func (vs *Visor) GetIndexProgress() IndexProgress {
+	return vs.bcParser.Progress()
+}
@@ -0,0 +1,110 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+func TestRebuildIndexes(t *testing.T) {
+	f, err := ioutil.TempFile("", "reindex")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bc, err := NewBlockchain(db, walker)
+	require.NoError(t, err)
+
+	history, err := historydb.New(db)
+	require.NoError(t, err)
+
+	bp := NewBlockchainParser(history, bc)
+	vs := &Visor{Blockchain: bc, history: history, bcParser: bp}
+
+	pub, sec := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pub)
+	gb, err := bc.CreateGenesisBlock(addr, 100e6, 1000)
+	require.NoError(t, err)
+
+	var txns []coin.Transaction
+	prev := gb
+	for i := 0; i < 3; i++ {
+		ux := bc.Unspent().GetUnspentsOfAddr(addr)[0]
+		txn := coin.Transaction{}
+		txn.PushInput(ux.Hash())
+		txn.PushOutput(addr, ux.Body.Coins, ux.Body.Hours/4)
+		txn.SignInputs([]cipher.SecKey{sec})
+		txn.UpdateHeader()
+		txns = append(txns, txn)
+
+		b, err := bc.NewBlockFromTransactions(coin.Transactions{txn}, prev.Time()+100)
+		require.NoError(t, err)
+		require.NoError(t, bc.ExecuteBlock(b))
+		prev = *b
+	}
+
+	// the indexes are lost
+	require.NoError(t, history.Reset())
+	require.NoError(t, db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket([]byte("unspent_addr_index")); err != nil {
+			return err
+		}
+		_, err := tx.CreateBucket([]byte("unspent_addr_index"))
+		return err
+	}))
+	assert.Empty(t, bc.Unspent().GetUnspentsOfAddr(addr))
+
+	var progress []IndexProgress
+	require.NoError(t, vs.RebuildIndexes(func(p IndexProgress) {
+		progress = append(progress, p)
+	}))
+	require.Len(t, progress, 1)
+	assert.Equal(t, int64(3), progress[0].ParsedSeq)
+	assert.Equal(t, uint64(3), progress[0].HeadSeq)
+
+	p := vs.GetIndexProgress()
+	assert.False(t, p.Running)
+	assert.Empty(t, p.Error)
+	assert.NotZero(t, p.FinishedAt)
+
+	assert.Len(t, bc.Unspent().GetUnspentsOfAddr(addr), 1)
+	assert.Equal(t, int64(3), history.ParsedHeight())
+	for _, txn := range txns {
+		ht, err := history.GetTransaction(txn.Hash())
+		require.NoError(t, err)
+		require.NotNil(t, ht)
+	}
+
+	// rebuilds in the running parser
+	errC := make(chan error, 1)
+	go func() {
+		errC <- bp.Run()
+	}()
+
+	require.NoError(t, vs.StartRebuildIndexes())
+	for i := 0; vs.GetIndexProgress().Running; i++ {
+		require.True(t, i < 100, "rebuild timeout")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	p = vs.GetIndexProgress()
+	assert.Empty(t, p.Error)
+	assert.Equal(t, int64(3), p.ParsedSeq)
+	assert.Equal(t, int64(3), history.ParsedHeight())
+
+	bp.Stop()
+	require.NoError(t, <-errC)
+}
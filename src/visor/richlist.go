@@ -0,0 +1,127 @@
+package visor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// RichListEntry represents the balance of an address in the rich list
+type RichListEntry struct {
+	Address string `json:"address"`
+	Coins   string `json:"coins"`
+	Hours   uint64 `json:"hours"`
+	// Percentage of the total coins held by this address, only set when distribution is included
+	Percent string `json:"percent,omitempty"`
+
+	coins uint64
+}
+
+// RichListDistribution summarizes how the coins are held by the top addresses
+type RichListDistribution struct {
+	TotalCoins string `json:"total_coins"`
+	TotalHours uint64 `json:"total_hours"`
+	TopCoins   string `json:"top_coins"`
+	TopPercent string `json:"top_percent"`
+}
+
+// RichList represents the addresses ranking ordered by coins
+type RichList struct {
+	Entries        []RichListEntry       `json:"richlist"`
+	TotalAddresses int                   `json:"total_addresses"`
+	Distribution   *RichListDistribution `json:"distribution,omitempty"`
+}
+
+type byCoins []RichListEntry
+
+func (bc byCoins) Len() int      { return len(bc) }
+func (bc byCoins) Swap(i, j int) { bc[i], bc[j] = bc[j], bc[i] }
+func (bc byCoins) Less(i, j int) bool {
+	if bc[i].coins == bc[j].coins {
+		// use address to break ties, so that the ranking is stable
+		return bc[i].Address < bc[j].Address
+	}
+	return bc[i].coins > bc[j].coins
+}
+
+// RichList aggregates the unspent pool by address and returns the topN addresses ordered
+// by coins, all addresses will be returned if topN <= 0.
+func (vs *Visor) RichList(topN int, includeDistribution bool) (*RichList, error) {
+	uxs, err := vs.Blockchain.Unspent().GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	balances, totalCoins, totalHours := addressBalances(uxs, vs.Blockchain.Time())
+
+	entries := make([]RichListEntry, 0, len(balances))
+	for addr, b := range balances {
+		entries = append(entries, RichListEntry{
+			Address: addr.String(),
+			Coins:   StrBalance(b.coins),
+			Hours:   b.hours,
+			coins:   b.coins,
+		})
+	}
+	sort.Sort(byCoins(entries))
+
+	if topN > 0 && topN < len(entries) {
+		entries = entries[:topN]
+	}
+
+	rl := &RichList{
+		Entries:        entries,
+		TotalAddresses: len(balances),
+	}
+
+	if !includeDistribution {
+		return rl, nil
+	}
+
+	var topCoins uint64
+	for i := range rl.Entries {
+		topCoins += rl.Entries[i].coins
+		rl.Entries[i].Percent = percent(rl.Entries[i].coins, totalCoins)
+	}
+
+	rl.Distribution = &RichListDistribution{
+		TotalCoins: StrBalance(totalCoins),
+		TotalHours: totalHours,
+		TopCoins:   StrBalance(topCoins),
+		TopPercent: percent(topCoins, totalCoins),
+	}
+	return rl, nil
+}
+
+type addressBalance struct {
+	coins uint64
+	hours uint64
+}
+
+// addressBalances aggregates the outputs by address, returns the balances and the totals
+func addressBalances(uxs coin.UxArray, headTime uint64) (map[cipher.Address]*addressBalance, uint64, uint64) {
+	balances := make(map[cipher.Address]*addressBalance)
+	var totalCoins, totalHours uint64
+	for _, ux := range uxs {
+		b, ok := balances[ux.Body.Address]
+		if !ok {
+			b = &addressBalance{}
+			balances[ux.Body.Address] = b
+		}
+		hours := ux.CoinHours(headTime)
+		b.coins += ux.Body.Coins
+		b.hours += hours
+		totalCoins += ux.Body.Coins
+		totalHours += hours
+	}
+	return balances, totalCoins, totalHours
+}
+
+func percent(n, total uint64) string {
+	if total == 0 {
+		return "0"
+	}
+	return fmt.Sprintf("%.4f", float64(n)*100/float64(total))
+}
@@ -0,0 +1,56 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+type signerFunc func(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error)
+
+func (f signerFunc) SignHash(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error) {
+	return f(addr, hash)
+}
+
+func TestSignSpends(t *testing.T) {
+	w, err := wallet.NewWallet("a.wlt", wallet.OptSeed("sign seed"))
+	require.NoError(t, err)
+	addrs := w.GenerateAddresses(2)
+
+	spends := coin.UxArray{
+		{Body: coin.UxBody{SrcTransaction: randSHA256(), Address: addrs[0], Coins: 1e6}},
+		{Body: coin.UxBody{SrcTransaction: randSHA256(), Address: addrs[1], Coins: 2e6}},
+	}
+	txn := coin.Transaction{}
+	for _, ux := range spends {
+		txn.PushInput(ux.Hash())
+	}
+	txn.PushOutput(addrs[0], 3e6, 0)
+	txn.UpdateHeader()
+
+	signed, err := SignSpends(txn, spends, wallet.NewKeySigner(w))
+	require.NoError(t, err)
+	require.NoError(t, signed.Verify())
+	assert.Equal(t, uint32(signed.Size()), signed.Length)
+
+	// signs the same hashes as signing by the secret keys
+	expect := txn
+	e0, _ := w.GetEntry(addrs[0])
+	e1, _ := w.GetEntry(addrs[1])
+	expect.SignInputs([]cipher.SecKey{e0.Secret, e1.Secret})
+	expect.UpdateHeader()
+	assert.Equal(t, expect.InnerHash, signed.InnerHash)
+	assert.Equal(t, expect.Length, signed.Length)
+
+	// the signature of another key is rejected
+	_, other := cipher.GenerateKeyPair()
+	_, err = SignSpends(txn, spends, signerFunc(func(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error) {
+		return cipher.SignHash(hash, other), nil
+	}))
+	assert.Error(t, err)
+}
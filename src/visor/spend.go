@@ -0,0 +1,161 @@
+package visor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+// Deprecate dependency on wallet
+// DEPRECATED. CAN BE DELETED
+
+/*
+
+The unspents are chosen by a CoinSelector, see coinselect.go
+
+*/
+
+// OldestUxOut sorts a UxArray oldest to newest.
+type OldestUxOut coin.UxArray
+
+func (ouo OldestUxOut) Len() int      { return len(ouo) }
+func (ouo OldestUxOut) Swap(i, j int) { ouo[i], ouo[j] = ouo[j], ouo[i] }
+func (ouo OldestUxOut) Less(i, j int) bool {
+	a := ouo[i].Head.BkSeq
+	b := ouo[j].Head.BkSeq
+	// Use hash to break ties
+	if a == b {
+		ih := ouo[i].Hash()
+		jh := ouo[j].Hash()
+		cmp := bytes.Compare(ih[:], jh[:])
+		if cmp == 0 {
+			logger.Panic("Duplicate UxOut when sorting")
+		}
+		return cmp < 0
+	}
+	return a < b
+}
+
+// createSpends chooses the outputs spending amt by the selector, the default selector is
+// used if it's nil
+func createSpends(headTime uint64, uxa coin.UxArray,
+	amt wallet.Balance, sel CoinSelector) (coin.UxArray, error) {
+	if amt.Coins == 0 {
+		return nil, errors.New("Zero spend amount")
+	}
+	if amt.Coins%1e6 != 0 {
+		return nil, errors.New("Coins must be multiple of 1e6")
+	}
+
+	if sel == nil {
+		sel = coinSelectors[DefaultCoinSelection]
+	}
+	return sel.Select(headTime, uxa, amt)
+}
+
+// CreateSpendingTransaction DEPRECATE
+// deprecate dependency on wallet
+// Creates a Transaction spending coins and hours from our coins, the change is sent to
+// the change address, or the first spent address if it's not set. The inputs are signed
+// by signer, the keys of the wallet are used if it's nil.
+// MOVE SOMEWHERE ELSE
+// Move to wallet or move to ???
+func CreateSpendingTransaction(wlt wallet.Wallet,
+	unconfirmed *UnconfirmedTxnPool, unspent *blockdb.UnspentPool,
+	headTime uint64, amt wallet.Balance,
+	dest, change cipher.Address, sel CoinSelector, signer wallet.TxnSigner) (coin.Transaction, error) {
+	txn, spends, err := BuildSpendingTransaction(wlt, unconfirmed, unspent, headTime, amt, dest, change, sel)
+	if err != nil {
+		return coin.Transaction{}, err
+	}
+
+	if signer == nil {
+		signer = wallet.NewKeySigner(&wlt)
+	}
+	return SignSpends(txn, spends, signer)
+}
+
+// BuildSpendingTransaction creates the transaction of CreateSpendingTransaction without
+// signing it, the inputs spend the returned outputs in order
+func BuildSpendingTransaction(wlt wallet.Wallet,
+	unconfirmed *UnconfirmedTxnPool, unspent *blockdb.UnspentPool,
+	headTime uint64, amt wallet.Balance,
+	dest, change cipher.Address, sel CoinSelector) (coin.Transaction, coin.UxArray, error) {
+	txn := coin.Transaction{}
+	auxs := unspent.GetUnspentsOfAddrs(wlt.GetAddresses())
+
+	// Subtract pending spends from available
+	puxs, err := unconfirmed.SpendsForAddresses(unspent, wlt.GetAddresses())
+	if err != nil {
+		return coin.Transaction{}, nil, err
+	}
+
+	auxs = auxs.Sub(puxs)
+
+	// Determine which unspents to spend
+	spends, err := createSpends(headTime, auxs.Flatten(), amt, sel)
+	if err != nil {
+		return txn, nil, err
+	}
+
+	// Add these unspents as tx inputs
+	spending := wallet.Balance{Coins: 0, Hours: 0}
+	for _, au := range spends {
+		if _, exists := wlt.GetEntry(au.Body.Address); !exists {
+			logger.Panic("On second thought, the wallet entry does not exist")
+		}
+		txn.PushInput(au.Hash())
+		spending.Coins += au.Body.Coins
+		spending.Hours += au.CoinHours(headTime)
+	}
+
+	//keep 1/4th of hours as change
+	//send half to each address
+	var changeHours = uint64(spending.Hours / 4)
+
+	if amt.Coins == spending.Coins {
+		txn.PushOutput(dest, amt.Coins, changeHours/2)
+		txn.UpdateHeader()
+		return txn, spends, nil
+	}
+
+	changeAmt := wallet.NewBalance(spending.Coins-amt.Coins, changeHours/2)
+	// the change goes back to the first spent address if no change address is set
+	if change == (cipher.Address{}) {
+		change = spends[0].Body.Address
+	}
+
+	//create transaction
+	txn.PushOutput(change, changeAmt.Coins, changeAmt.Hours)
+	txn.PushOutput(dest, amt.Coins, changeHours/2)
+	txn.UpdateHeader()
+	return txn, spends, nil
+}
+
+// SignSpends signs the inputs spending the outputs by signer, the signatures are checked
+// since they may come from an external signer
+func SignSpends(txn coin.Transaction, spends coin.UxArray, signer wallet.TxnSigner) (coin.Transaction, error) {
+	txn.InnerHash = txn.HashInner()
+	sigs := make([]cipher.Sig, len(spends))
+	for i, ux := range spends {
+		hash := txn.SigHash(i)
+		sig, err := signer.SignHash(ux.Body.Address, hash)
+		if err != nil {
+			return coin.Transaction{}, err
+		}
+
+		if err := cipher.ChkSig(ux.Body.Address, hash, sig); err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid signature of input %s: %v", ux.Hash().Hex(), err)
+		}
+		sigs[i] = sig
+	}
+
+	txn.Sigs = sigs
+	txn.UpdateHeader()
+	return txn, nil
+}
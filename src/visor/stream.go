@@ -0,0 +1,78 @@
+package visor
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// jsonIndent is the indent used by the http json helpers
+const jsonIndent = "    "
+
+// MaxStreamBlocks is the maximum number of blocks that can be streamed in one request
+const MaxStreamBlocks = 10000
+
+// streamBatchSize is the number of blocks fetched at once while the blocks are streamed
+const streamBatchSize = 100
+
+// EncodeBlocks writes the readable blocks whose seq are in the range of start and end to w,
+// the output is the same as the indented json of ReadableBlocks. The blocks are fetched by
+// getBlocks in batches and encoded one by one, so that the caller only holds the visor while
+// a batch is fetched, and large range of blocks won't be loaded into memory. Stops at the
+// first missing block.
+func EncodeBlocks(w io.Writer, start, end uint64, getBlocks func(start, end uint64) []coin.Block) error {
+	if _, err := io.WriteString(w, "{\n"+jsonIndent+`"blocks": [`); err != nil {
+		return err
+	}
+
+	var n int
+	for seq := start; seq <= end; {
+		batchEnd := end
+		if end-seq >= streamBatchSize {
+			batchEnd = seq + streamBatchSize - 1
+		}
+
+		blocks := getBlocks(seq, batchEnd)
+		for i := range blocks {
+			if err := encodeBlock(w, &blocks[i], n == 0); err != nil {
+				return err
+			}
+			n++
+		}
+
+		// avoids overflow when end is the max uint64
+		if uint64(len(blocks)) < batchEnd-seq+1 || batchEnd == end {
+			break
+		}
+		seq = batchEnd + 1
+	}
+
+	tail := "]\n}"
+	if n > 0 {
+		tail = "\n" + jsonIndent + tail
+	}
+	_, err := io.WriteString(w, tail)
+	return err
+}
+
+// encodeBlock writes the indented json of the readable block as an element of the blocks array
+func encodeBlock(w io.Writer, b *coin.Block, first bool) error {
+	sep := ",\n"
+	if first {
+		sep = "\n"
+	}
+
+	prefix := jsonIndent + jsonIndent
+	d, err := json.MarshalIndent(NewReadableBlock(b), prefix, jsonIndent)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(w, sep+prefix); err != nil {
+		return err
+	}
+
+	_, err = w.Write(d)
+	return err
+}
@@ -0,0 +1,79 @@
+package visor
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestEncodeBlocks(t *testing.T) {
+	blocks := make([]coin.Block, 3)
+	for i := range blocks {
+		blocks[i].Head = coin.BlockHeader{BkSeq: uint64(i), Time: uint64(1000 + i)}
+	}
+
+	getBlocks := func(start, end uint64) []coin.Block {
+		if start >= uint64(len(blocks)) {
+			return nil
+		}
+		if end >= uint64(len(blocks)) {
+			end = uint64(len(blocks)) - 1
+		}
+		return blocks[start : end+1]
+	}
+
+	cases := []struct {
+		start, end uint64
+		blocks     []coin.Block
+	}{
+		{0, 2, blocks},
+		{1, 1, blocks[1:2]},
+		{1, 10, blocks[1:]},
+		{5, 10, []coin.Block{}},
+		{2, 1, []coin.Block{}},
+	}
+
+	for _, tc := range cases {
+		var buf bytes.Buffer
+		assert.Nil(t, EncodeBlocks(&buf, tc.start, tc.end, getBlocks))
+
+		// should be the same as the indented json sent by http helpers
+		expect, err := json.MarshalIndent(ReadableBlocks{NewReadableBlocks(tc.blocks)}, "", "    ")
+		assert.Nil(t, err)
+		assert.Equal(t, string(expect), buf.String())
+	}
+}
+
+func TestEncodeBlocksBatches(t *testing.T) {
+	blocks := make([]coin.Block, streamBatchSize*2+1)
+	for i := range blocks {
+		blocks[i].Head = coin.BlockHeader{BkSeq: uint64(i)}
+	}
+
+	var batches [][2]uint64
+	getBlocks := func(start, end uint64) []coin.Block {
+		batches = append(batches, [2]uint64{start, end})
+		if end >= uint64(len(blocks)) {
+			end = uint64(len(blocks)) - 1
+		}
+		return blocks[start : end+1]
+	}
+
+	var buf bytes.Buffer
+	assert.Nil(t, EncodeBlocks(&buf, 0, 1<<64-1, getBlocks))
+
+	// the blocks are fetched in batches until a batch is short
+	assert.Equal(t, [][2]uint64{
+		{0, streamBatchSize - 1},
+		{streamBatchSize, streamBatchSize*2 - 1},
+		{streamBatchSize * 2, streamBatchSize*3 - 1},
+	}, batches)
+
+	var rbs ReadableBlocks
+	assert.Nil(t, json.Unmarshal(buf.Bytes(), &rbs))
+	assert.Len(t, rbs.Blocks, len(blocks))
+}
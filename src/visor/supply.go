@@ -0,0 +1,139 @@
+package visor
+
+import (
+	"fmt"
+	"sort"
+)
+
+// SupplyVelocityBlocks is the number of latest blocks used to calculate the hour velocity
+const SupplyVelocityBlocks = 1000
+
+// supplyBucketBounds are the upper bounds in whole coins of the distribution buckets,
+// the last bucket holds the addresses with no less coins than the last bound.
+var supplyBucketBounds = []uint64{1, 10, 100, 1000, 10000, 100000, 1000000, 10000000}
+
+// SupplyBucket represents the addresses whose balance are in the range of [min, max) coins
+type SupplyBucket struct {
+	Min string `json:"min"`
+	// Empty if the bucket has no upper bound
+	Max       string `json:"max"`
+	Addresses int    `json:"addresses"`
+	Coins     string `json:"coins"`
+	Percent   string `json:"percent"`
+}
+
+// SupplyStats summarizes how the coins in the unspent pool are distributed
+type SupplyStats struct {
+	TotalAddresses int            `json:"total_addresses"`
+	TotalCoins     string         `json:"total_coins"`
+	TotalHours     uint64         `json:"total_hours"`
+	Buckets        []SupplyBucket `json:"buckets"`
+	Gini           string         `json:"gini"`
+	// Coin hours burned as fees in the latest blocks, as a percentage of the held coin hours
+	HourVelocity       string `json:"hour_velocity"`
+	HourVelocityBlocks uint64 `json:"hour_velocity_blocks"`
+}
+
+// GetSupplyStats aggregates the unspent pool by address, and returns the distribution
+// buckets, the gini coefficient and the hour velocity of the supply.
+func (vs *Visor) GetSupplyStats() (*SupplyStats, error) {
+	uxs, err := vs.Blockchain.Unspent().GetAll()
+	if err != nil {
+		return nil, err
+	}
+
+	balances, totalCoins, totalHours := addressBalances(uxs, vs.Blockchain.Time())
+	coins := make([]uint64, 0, len(balances))
+	for _, b := range balances {
+		coins = append(coins, b.coins)
+	}
+
+	headSeq := vs.HeadBkSeq()
+	var start uint64
+	if headSeq >= SupplyVelocityBlocks {
+		start = headSeq - SupplyVelocityBlocks + 1
+	}
+
+	var burnedHours uint64
+	for seq := start; seq <= headSeq; seq++ {
+		b := vs.GetBlockBySeq(seq)
+		if b == nil {
+			return nil, fmt.Errorf("found no block in seq %v", seq)
+		}
+		burnedHours += b.Head.Fee
+	}
+
+	return &SupplyStats{
+		TotalAddresses:     len(balances),
+		TotalCoins:         StrBalance(totalCoins),
+		TotalHours:         totalHours,
+		Buckets:            supplyBuckets(coins, totalCoins),
+		Gini:               fmt.Sprintf("%.4f", gini(coins)),
+		HourVelocity:       percent(burnedHours, totalHours),
+		HourVelocityBlocks: headSeq - start + 1,
+	}, nil
+}
+
+// supplyBuckets counts the addresses and coins of each bucket
+func supplyBuckets(coins []uint64, totalCoins uint64) []SupplyBucket {
+	var (
+		counts = make([]int, len(supplyBucketBounds)+1)
+		sums   = make([]uint64, len(supplyBucketBounds)+1)
+	)
+
+	for _, c := range coins {
+		i := sort.Search(len(supplyBucketBounds), func(i int) bool {
+			return c < supplyBucketBounds[i]*1e6
+		})
+		counts[i]++
+		sums[i] += c
+	}
+
+	buckets := make([]SupplyBucket, len(counts))
+	var min uint64
+	for i := range buckets {
+		buckets[i] = SupplyBucket{
+			Min:       StrBalance(min * 1e6),
+			Addresses: counts[i],
+			Coins:     StrBalance(sums[i]),
+			Percent:   percent(sums[i], totalCoins),
+		}
+
+		if i < len(supplyBucketBounds) {
+			min = supplyBucketBounds[i]
+			buckets[i].Max = StrBalance(min * 1e6)
+		}
+	}
+
+	return buckets
+}
+
+// gini returns the gini coefficient of the balances, 0 means the coins are equally
+// distributed, and it approaches 1 as the coins are held by fewer addresses.
+func gini(coins []uint64) float64 {
+	if len(coins) == 0 {
+		return 0
+	}
+
+	sorted := append([]uint64{}, coins...)
+	sort.Sort(uint64s(sorted))
+
+	var sum, weighted float64
+	for i, c := range sorted {
+		sum += float64(c)
+		weighted += float64(i+1) * float64(c)
+	}
+
+	if sum == 0 {
+		return 0
+	}
+
+	n := float64(len(sorted))
+	return 2*weighted/(n*sum) - (n+1)/n
+}
+
+type uint64s []uint64
+
+func (us uint64s) Len() int           { return len(us) }
+func (us uint64s) Swap(i, j int)      { us[i], us[j] = us[j], us[i] }
+func (us uint64s) Less(i, j int) bool { return us[i] < us[j] }
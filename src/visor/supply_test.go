@@ -0,0 +1,41 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSupplyBuckets(t *testing.T) {
+	coins := []uint64{0, 500000, 1e6, 9999999, 150e6, 20000000e6}
+	bs := supplyBuckets(coins, 20000160500000)
+	assert.Len(t, bs, len(supplyBucketBounds)+1)
+
+	assert.Equal(t, SupplyBucket{
+		Min:       "0",
+		Max:       "1",
+		Addresses: 2,
+		Coins:     "0.5",
+		Percent:   "0.0000",
+	}, bs[0])
+	assert.Equal(t, 2, bs[1].Addresses)
+	assert.Equal(t, "10.999999", bs[1].Coins)
+	assert.Equal(t, 0, bs[2].Addresses)
+	assert.Equal(t, "100", bs[3].Min)
+	assert.Equal(t, "1000", bs[3].Max)
+	assert.Equal(t, 1, bs[3].Addresses)
+
+	last := bs[len(bs)-1]
+	assert.Equal(t, "10000000", last.Min)
+	assert.Empty(t, last.Max)
+	assert.Equal(t, 1, last.Addresses)
+	assert.Equal(t, "99.9992", last.Percent)
+}
+
+func TestGini(t *testing.T) {
+	assert.Equal(t, float64(0), gini(nil))
+	assert.Equal(t, float64(0), gini([]uint64{0, 0}))
+	assert.InDelta(t, 0, gini([]uint64{5e6, 5e6, 5e6}), 1e-9)
+	assert.InDelta(t, 0.75, gini([]uint64{0, 0, 0, 8e6}), 1e-9)
+	assert.InDelta(t, 0.25, gini([]uint64{1e6, 3e6}), 1e-9)
+}
@@ -0,0 +1,53 @@
+package visor
+
+import (
+	"errors"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// ErrNothingToSweep is returned if the address of the swept key has no outputs to spend
+var ErrNothingToSweep = errors.New("No unspent outputs to sweep")
+
+// CreateSweepTransaction creates the transaction sending all the unspent outputs of the
+// secret key to dest, e.g. to redeem a paper wallet. The outputs spent by the unconfirmed
+// txns are skipped, the hours are kept the same way as the spends. The txn is signed but
+// not injected.
+func (vs *Visor) CreateSweepTransaction(sec cipher.SecKey, dest cipher.Address) (coin.Transaction, error) {
+	if err := sec.Verify(); err != nil {
+		return coin.Transaction{}, err
+	}
+	addr := cipher.AddressFromSecKey(sec)
+
+	unspent := vs.Blockchain.Unspent()
+	headTime := vs.Blockchain.Time()
+
+	addrs := []cipher.Address{addr}
+	auxs := unspent.GetUnspentsOfAddrs(addrs)
+	puxs, err := vs.Unconfirmed.SpendsForAddresses(unspent, addrs)
+	if err != nil {
+		return coin.Transaction{}, err
+	}
+
+	spends := spendableUxOuts(auxs.Sub(puxs).Flatten())
+	if len(spends) == 0 {
+		return coin.Transaction{}, ErrNothingToSweep
+	}
+
+	txn := coin.Transaction{}
+	toSign := make([]cipher.SecKey, len(spends))
+	var coins, hours uint64
+	for i, ux := range spends {
+		txn.PushInput(ux.Hash())
+		toSign[i] = sec
+		coins += ux.Body.Coins
+		hours += ux.CoinHours(headTime)
+	}
+
+	// keep 1/4th of hours, there's no change
+	txn.PushOutput(dest, coins, hours/4)
+	txn.SignInputs(toSign)
+	txn.UpdateHeader()
+	return txn, nil
+}
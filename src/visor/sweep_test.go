@@ -0,0 +1,81 @@
+package visor
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestCreateSweepTransaction(t *testing.T) {
+	f, err := ioutil.TempFile("", "sweep")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bc, err := NewBlockchain(db, walker)
+	require.NoError(t, err)
+	vs := &Visor{Blockchain: bc, Unconfirmed: NewUnconfirmedTxnPool(db)}
+
+	pub, sec := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pub)
+	gb, err := bc.CreateGenesisBlock(addr, 100e6, 1000)
+	require.NoError(t, err)
+
+	// the paper wallet receives 3 outputs
+	pPub, pSec := cipher.GenerateKeyPair()
+	paper := cipher.AddressFromPubKey(pPub)
+	ux := bc.Unspent().GetUnspentsOfAddr(addr)[0]
+	txn := coin.Transaction{}
+	txn.PushInput(ux.Hash())
+	txn.PushOutput(addr, 70e6, 100)
+	txn.PushOutput(paper, 10e6, 100)
+	txn.PushOutput(paper, 15e6, 100)
+	txn.PushOutput(paper, 5e6, 100)
+	txn.SignInputs([]cipher.SecKey{sec})
+	txn.UpdateHeader()
+	b, err := bc.NewBlockFromTransactions(coin.Transactions{txn}, gb.Time()+3600)
+	require.NoError(t, err)
+	require.NoError(t, bc.ExecuteBlock(b))
+
+	wPub, _ := cipher.GenerateKeyPair()
+	dest := cipher.AddressFromPubKey(wPub)
+
+	sweep, err := vs.CreateSweepTransaction(pSec, dest)
+	require.NoError(t, err)
+	require.NoError(t, sweep.Verify())
+	assert.Len(t, sweep.In, 3)
+	require.Len(t, sweep.Out, 1)
+	assert.Equal(t, dest, sweep.Out[0].Address)
+	assert.Equal(t, uint64(30e6), sweep.Out[0].Coins)
+
+	uxIn, err := bc.Unspent().GetArray(sweep.In)
+	require.NoError(t, err)
+	var hoursIn uint64
+	for _, ux := range uxIn {
+		assert.Equal(t, paper, ux.Body.Address)
+		hoursIn += ux.CoinHours(bc.Time())
+	}
+	assert.Equal(t, hoursIn/4, sweep.Out[0].Hours)
+	require.NoError(t, verifyBurnFee(hoursIn-sweep.OutputHours(), sweep.OutputHours()))
+
+	// the outputs spent by the unconfirmed txn are not swept again
+	known, err := vs.Unconfirmed.InjectTxn(bc, sweep)
+	require.NoError(t, err)
+	assert.False(t, known)
+	_, err = vs.CreateSweepTransaction(pSec, dest)
+	assert.Equal(t, ErrNothingToSweep, err)
+
+	_, err = vs.CreateSweepTransaction(cipher.SecKey{}, dest)
+	assert.Error(t, err)
+}
@@ -0,0 +1,95 @@
+package visor
+
+import (
+	"sync"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/util/utc"
+)
+
+// Transaction status in the status change history
+const (
+	TxnStatusUnknown     = "unknown"
+	TxnStatusUnconfirmed = "unconfirmed"
+	TxnStatusConfirmed   = "confirmed"
+	// The txn is removed from the unconfirmed pool without being confirmed
+	TxnStatusDropped = "dropped"
+	// The txn spends unknown outputs, and is waiting for the parent txns to be confirmed
+	TxnStatusOrphan = "orphan"
+)
+
+// maxTxnHistory is the max number of txns whose status changes are recorded,
+// the earliest recorded txn will be removed when the limit is reached.
+const maxTxnHistory = 100000
+
+// TransactionStatusChange records the transition of transaction status
+type TransactionStatusChange struct {
+	From string    `json:"from"`
+	To   string    `json:"to"`
+	Time time.Time `json:"time"`
+}
+
+// txnHistory records the status changes of transactions since the node started
+type txnHistory struct {
+	sync.Mutex
+	changes map[cipher.SHA256][]TransactionStatusChange
+	// hashes in the order of being recorded, for removing the earliest ones
+	order []cipher.SHA256
+	max   int
+}
+
+func newTxnHistory(max int) *txnHistory {
+	return &txnHistory{
+		changes: make(map[cipher.SHA256][]TransactionStatusChange),
+		max:     max,
+	}
+}
+
+// add records the txn changes to status, does nothing if the status is not changed
+func (th *txnHistory) add(hash cipher.SHA256, status string) {
+	th.Lock()
+	defer th.Unlock()
+
+	chs, ok := th.changes[hash]
+	from := TxnStatusUnknown
+	if ok {
+		from = chs[len(chs)-1].To
+	}
+
+	if from == status {
+		return
+	}
+
+	if !ok {
+		if len(th.order) >= th.max {
+			delete(th.changes, th.order[0])
+			th.order = th.order[1:]
+		}
+		th.order = append(th.order, hash)
+	}
+
+	th.changes[hash] = append(chs, TransactionStatusChange{
+		From: from,
+		To:   status,
+		Time: utc.Now(),
+	})
+}
+
+// get returns a copy of the status changes of txn, returns nil if not recorded
+func (th *txnHistory) get(hash cipher.SHA256) []TransactionStatusChange {
+	th.Lock()
+	defer th.Unlock()
+
+	chs, ok := th.changes[hash]
+	if !ok {
+		return nil
+	}
+	return append([]TransactionStatusChange{}, chs...)
+}
+
+// GetTransactionHistory returns the status changes of the transaction that are seen
+// since the node started, returns nil if the transaction is not seen.
+func (vs *Visor) GetTransactionHistory(hash cipher.SHA256) []TransactionStatusChange {
+	return vs.txnHistory.get(hash)
+}
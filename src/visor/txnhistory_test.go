@@ -0,0 +1,35 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxnHistory(t *testing.T) {
+	th := newTxnHistory(2)
+	h1, h2, h3 := randSHA256(), randSHA256(), randSHA256()
+
+	assert.Nil(t, th.get(h1))
+
+	th.add(h1, TxnStatusUnconfirmed)
+	th.add(h1, TxnStatusUnconfirmed)
+	th.add(h1, TxnStatusConfirmed)
+	chs := th.get(h1)
+	assert.Len(t, chs, 2)
+	assert.Equal(t, TxnStatusUnknown, chs[0].From)
+	assert.Equal(t, TxnStatusUnconfirmed, chs[0].To)
+	assert.Equal(t, TxnStatusUnconfirmed, chs[1].From)
+	assert.Equal(t, TxnStatusConfirmed, chs[1].To)
+	assert.False(t, chs[1].Time.Before(chs[0].Time))
+
+	// confirmed without being seen in the unconfirmed pool
+	th.add(h2, TxnStatusConfirmed)
+	assert.Equal(t, TxnStatusUnknown, th.get(h2)[0].From)
+
+	// the earliest recorded txn is removed when the limit is reached
+	th.add(h3, TxnStatusUnconfirmed)
+	assert.Nil(t, th.get(h1))
+	assert.Len(t, th.get(h2), 1)
+	assert.Len(t, th.get(h3), 1)
+}
@@ -0,0 +1,1001 @@
+package visor
+
+import (
+	"errors"
+	"sort"
+
+	"time"
+
+	"fmt"
+
+	"github.com/boltdb/bolt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+	"github.com/skycoin/skycoin/src/visor/bucket"
+)
+
+// BurnFactor half of coinhours must be burnt
+var BurnFactor uint64 = 2
+
+// VerifyTransactionFee performs additional transaction verification at the unconfirmed pool level.
+// This checks tunable parameters that should prevent the transaction from
+// entering the blockchain, but cannot be done at the blockchain level because
+// they may be changed.
+func VerifyTransactionFee(bc *Blockchain, t *coin.Transaction) error {
+	fee, err := bc.TransactionFee(t)
+	if err != nil {
+		return err
+	}
+
+	return verifyBurnFee(fee, t.OutputHours())
+}
+
+// verifyBurnFee checks that the fee burns enough of the input hours
+func verifyBurnFee(fee, outHours uint64) error {
+	//calculate total number of coinhours
+	var total = outHours + fee
+	//make sure at least half the coin hours are destroyed
+	if fee < total/BurnFactor {
+		return errors.New("Transaction coinhour fee minimum not met")
+	}
+	return nil
+}
+
+// TxnUnspents maps from coin.Transaction hash to its expected unspents.  The unspents'
+// Head can be different at execution time, but the Unspent's hash is fixed.
+type TxnUnspents map[cipher.SHA256]coin.UxArray
+
+// AllForAddress returns all Unspents for a single address
+func (tus TxnUnspents) AllForAddress(a cipher.Address) coin.UxArray {
+	uxo := make(coin.UxArray, 0)
+	for _, uxa := range tus {
+		for i := range uxa {
+			if uxa[i].Body.Address == a {
+				uxo = append(uxo, uxa[i])
+			}
+		}
+	}
+	return uxo
+}
+
+// UnconfirmedTxn unconfirmed transaction
+type UnconfirmedTxn struct {
+	Txn coin.Transaction
+	// Time the txn was last received
+	Received int64
+	// Time the txn was last checked against the blockchain
+	Checked int64
+	// Last time we announced this txn
+	Announced int64
+	// If this txn is valid
+	IsValid int8
+}
+
+// Hash returns the coin.Transaction's hash
+func (ut *UnconfirmedTxn) Hash() cipher.SHA256 {
+	return ut.Txn.Hash()
+}
+
+// unconfirmed transactions bucket
+type uncfmTxnBkt struct {
+	txns *bucket.Bucket
+	// idx       *bucket.Bucket
+	// indexName []byte
+}
+
+func newUncfmTxBkt(db *bolt.DB) *uncfmTxnBkt {
+	bkt, err := bucket.New([]byte("unconfirmed_txns"), db)
+	if err != nil {
+		panic(err)
+	}
+
+	return &uncfmTxnBkt{txns: bkt}
+}
+
+func (utb *uncfmTxnBkt) get(hash cipher.SHA256) (*UnconfirmedTxn, bool) {
+	v := utb.txns.Get([]byte(hash.Hex()))
+	if v == nil {
+		return nil, false
+	}
+	var tx UnconfirmedTxn
+	if err := encoder.DeserializeRaw(v, &tx); err != nil {
+		return nil, false
+	}
+	return &tx, true
+}
+
+func (utb *uncfmTxnBkt) put(v *UnconfirmedTxn) error {
+	key := []byte(v.Hash().Hex())
+	d := encoder.Serialize(v)
+	return utb.txns.Put(key, d)
+}
+
+func (utb *uncfmTxnBkt) update(key cipher.SHA256, f func(v *UnconfirmedTxn)) error {
+	updateFun := func(v []byte) ([]byte, error) {
+		if v == nil {
+			return nil, fmt.Errorf("%s does not exist in bucket %s", key.Hex(), utb.txns.Name)
+		}
+
+		var tx UnconfirmedTxn
+		if err := encoder.DeserializeRaw(v, &tx); err != nil {
+			return nil, err
+		}
+
+		f(&tx)
+		return encoder.Serialize(tx), nil
+	}
+
+	return utb.txns.Update([]byte(key.Hex()), updateFun)
+}
+
+func (utb *uncfmTxnBkt) delete(key cipher.SHA256) error {
+	return utb.txns.Delete([]byte(key.Hex()))
+}
+
+func (utb *uncfmTxnBkt) getAll() ([]UnconfirmedTxn, error) {
+	vs := utb.txns.GetAll()
+	txns := make([]UnconfirmedTxn, 0, len(vs))
+	for _, u := range vs {
+		var tx UnconfirmedTxn
+		if err := encoder.DeserializeRaw(u, &tx); err != nil {
+			return nil, err
+		}
+		txns = append(txns, tx)
+	}
+
+	return txns, nil
+}
+
+func (utb *uncfmTxnBkt) rangeUpdate(f func(key cipher.SHA256, tx *UnconfirmedTxn)) error {
+	return utb.txns.RangeUpdate(func(k, v []byte) ([]byte, error) {
+		key, err := cipher.SHA256FromHex(string(k))
+		if err != nil {
+			return nil, err
+		}
+
+		var tx UnconfirmedTxn
+		if err := encoder.DeserializeRaw(v, &tx); err != nil {
+			return nil, err
+		}
+		f(key, &tx)
+		// encode the tx
+		d := encoder.Serialize(tx)
+		return d, nil
+	})
+}
+
+func (utb *uncfmTxnBkt) isExist(key cipher.SHA256) bool {
+	return utb.txns.IsExist([]byte(key.Hex()))
+}
+
+func (utb *uncfmTxnBkt) forEach(f func(key cipher.SHA256, tx *UnconfirmedTxn) error) error {
+	return utb.txns.ForEach(func(k, v []byte) error {
+		key, err := cipher.SHA256FromHex(string(k))
+		if err != nil {
+			return err
+		}
+		var tx UnconfirmedTxn
+		if err := encoder.DeserializeRaw(v, &tx); err != nil {
+			return err
+		}
+
+		return f(key, &tx)
+	})
+}
+
+func (utb *uncfmTxnBkt) len() int {
+	// exclude the index
+	return utb.txns.Len()
+}
+
+type txUnspents struct {
+	bkt *bucket.Bucket
+}
+
+func newTxUnspents(db *bolt.DB) *txUnspents {
+	bkt, err := bucket.New([]byte("unconfirmed_unspents"), db)
+	if err != nil {
+		panic(err)
+	}
+
+	return &txUnspents{bkt: bkt}
+}
+
+func (txus *txUnspents) put(key cipher.SHA256, uxs coin.UxArray) error {
+	v := encoder.Serialize(uxs)
+	return txus.bkt.Put([]byte(key.Hex()), v)
+}
+
+func (txus *txUnspents) get(key cipher.SHA256) (coin.UxArray, error) {
+	v := txus.bkt.Get([]byte(key.Hex()))
+	var uxs coin.UxArray
+	if err := encoder.DeserializeRaw(v, &uxs); err != nil {
+		return coin.UxArray{}, err
+	}
+	return uxs, nil
+}
+
+func (txus *txUnspents) len() int {
+	return txus.bkt.Len()
+}
+
+func (txus *txUnspents) delete(key cipher.SHA256) error {
+	return txus.bkt.Delete([]byte(key.Hex()))
+}
+
+func (txus *txUnspents) getAllForAddress(a cipher.Address) (uxo coin.UxArray) {
+	txus.bkt.ForEach(func(k, v []byte) error {
+		var uxa coin.UxArray
+		if err := encoder.DeserializeRaw(v, &uxa); err != nil {
+			panic(err)
+		}
+
+		for i := range uxa {
+			if uxa[i].Body.Address == a {
+				uxo = append(uxo, uxa[i])
+			}
+		}
+		return nil
+	})
+	return
+}
+
+func (txus *txUnspents) forEach(f func(cipher.SHA256, coin.UxArray)) error {
+	return txus.bkt.ForEach(func(k, v []byte) error {
+		hash, err := cipher.SHA256FromHex(string(k))
+		if err != nil {
+			return err
+		}
+
+		var uxa coin.UxArray
+		if err := encoder.DeserializeRaw(v, &uxa); err != nil {
+			return err
+		}
+
+		f(hash, uxa)
+		return nil
+	})
+}
+
+// txnPackages maps the hash of each txn of a package to the hashes of all the txns of the
+// package, the txns of a package are injected as a batch and evicted together
+type txnPackages struct {
+	bkt *bucket.Bucket
+}
+
+func newTxnPackages(db *bolt.DB) *txnPackages {
+	bkt, err := bucket.New([]byte("unconfirmed_packages"), db)
+	if err != nil {
+		panic(err)
+	}
+
+	return &txnPackages{bkt: bkt}
+}
+
+// put records the txns of hashes as a package
+func (tp *txnPackages) put(hashes []cipher.SHA256) error {
+	v := encoder.Serialize(hashes)
+	for _, h := range hashes {
+		if err := tp.bkt.Put(h[:], v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// get returns the hashes of the txns of the package of the txn, returns false if the txn
+// isn't in a package
+func (tp *txnPackages) get(key cipher.SHA256) ([]cipher.SHA256, bool) {
+	v := tp.bkt.Get(key[:])
+	if v == nil {
+		return nil, false
+	}
+
+	var hashes []cipher.SHA256
+	if err := encoder.DeserializeRaw(v, &hashes); err != nil {
+		logger.Error("Decode the package of unconfirmed txn %s failed: %v", key.Hex(), err)
+		return nil, false
+	}
+	return hashes, true
+}
+
+func (tp *txnPackages) delete(key cipher.SHA256) error {
+	return tp.bkt.Delete(key[:])
+}
+
+// UnconfirmedLimits limits the size of the unconfirmed pool, zero value means no limit
+type UnconfirmedLimits struct {
+	// Maximum number of txns in the pool
+	MaxTxns int
+	// Maximum total size of the txns in the pool, in bytes
+	MaxBytes int
+	// How long a txn can be held in the pool since it was last received
+	MaxAge time.Duration
+}
+
+// EvictionMetrics counts the txns evicted from the unconfirmed pool since started
+type EvictionMetrics struct {
+	// Txns that are older than the max age
+	Expired uint64 `json:"expired"`
+	// Txns that are evicted to keep the pool in the max txns and bytes
+	Overflowed uint64 `json:"overflowed"`
+	// Total size of the evicted txns in bytes
+	Bytes uint64 `json:"bytes"`
+}
+
+// UnconfirmedTxnPool manages unconfirmed transactions
+type UnconfirmedTxnPool struct {
+	// Txns map[cipher.SHA256]UnconfirmedTxn
+	Txns *uncfmTxnBkt
+	// Predicted unspents, assuming txns are valid.  Needed to predict
+	// our future balance and avoid double spending our own coins
+	// Maps from Transaction.Hash() to UxArray.
+	Unspent *txUnspents
+	// the txns injected as a batch
+	Packages *txnPackages
+
+	limits    UnconfirmedLimits
+	evictions EvictionMetrics
+	// the txns in the eviction order, built from the db when first used
+	evicts *evictIndex
+}
+
+// UnconfirmedOption represents the option when creating the unconfirmed pool
+type UnconfirmedOption func(*UnconfirmedTxnPool)
+
+// PoolLimits sets the limits of the unconfirmed pool
+func PoolLimits(limits UnconfirmedLimits) UnconfirmedOption {
+	return func(utp *UnconfirmedTxnPool) {
+		utp.limits = limits
+	}
+}
+
+// NewUnconfirmedTxnPool creates an UnconfirmedTxnPool instance
+func NewUnconfirmedTxnPool(db *bolt.DB, ops ...UnconfirmedOption) *UnconfirmedTxnPool {
+	utp := &UnconfirmedTxnPool{
+		Txns:     newUncfmTxBkt(db),
+		Unspent:  newTxUnspents(db),
+		Packages: newTxnPackages(db),
+	}
+
+	for _, op := range ops {
+		op(utp)
+	}
+
+	return utp
+}
+
+// SetAnnounced updates announced time of specific tx
+func (utp *UnconfirmedTxnPool) SetAnnounced(h cipher.SHA256, t time.Time) {
+	utp.Txns.update(h, func(tx *UnconfirmedTxn) {
+		tx.Announced = t.UnixNano()
+	})
+}
+
+// Creates an unconfirmed transaction
+func (utp *UnconfirmedTxnPool) createUnconfirmedTxn(t coin.Transaction) UnconfirmedTxn {
+	now := utc.Now()
+	return UnconfirmedTxn{
+		Txn:       t,
+		Received:  now.UnixNano(),
+		Checked:   now.UnixNano(),
+		Announced: time.Time{}.UnixNano(),
+	}
+}
+
+// InjectTxn adds a coin.Transaction to the pool, or updates an existing one's timestamps
+// Returns an error if txn is invalid, and whether the transaction already
+// existed in the pool.
+func (utp *UnconfirmedTxnPool) InjectTxn(bc *Blockchain, t coin.Transaction) (know bool, err error) {
+	var valid int8
+	for {
+		if err = VerifyTransactionFee(bc, &t); err != nil {
+			if err == ErrUnspentNotExist {
+				break
+			}
+			return false, err
+		}
+
+		if err := bc.VerifyTransaction(t); err != nil {
+			return false, err
+		}
+
+		valid = 1
+		break
+	}
+
+	// Update if we already have this txn
+	h := t.Hash()
+	// update the time if exist
+	utp.Txns.update(h, func(tx *UnconfirmedTxn) {
+		know = true
+		now := utc.Now()
+		tx.Received = now.UnixNano()
+		tx.Checked = now.UnixNano()
+		tx.IsValid = valid
+	})
+
+	if know {
+		if tx, ok := utp.Txns.get(h); ok {
+			utp.indexEviction(bc, tx)
+		}
+		return
+	}
+
+	// Add txn to index
+	utx := utp.createUnconfirmedTxn(t)
+	utx.IsValid = valid
+	utp.Txns.put(&utx)
+	utp.Unspent.put(h, coin.CreateUnspents(bc.Head().Head, t))
+	utp.indexEviction(bc, &utx)
+	return
+}
+
+// RawTxns returns underlying coin.Transactions
+func (utp *UnconfirmedTxnPool) RawTxns() coin.Transactions {
+	utxns, err := utp.Txns.getAll()
+	if err != nil {
+		return coin.Transactions{}
+	}
+
+	txns := make(coin.Transactions, len(utxns))
+	for i := range utxns {
+		txns[i] = utxns[i].Txn
+	}
+	return txns
+}
+
+// Remove a single txn by hash
+func (utp *UnconfirmedTxnPool) removeTxn(bc *Blockchain, txHash cipher.SHA256) {
+	utp.removeTxns([]cipher.SHA256{txHash})
+}
+
+// Removes multiple txns at once. Slightly more efficient than a series of
+// single RemoveTxns.  Hashes is an array of Transaction hashes.
+func (utp *UnconfirmedTxnPool) removeTxns(hashes []cipher.SHA256) {
+	for i := range hashes {
+		utp.Txns.delete(hashes[i])
+		utp.Unspent.delete(hashes[i])
+		if utp.evicts != nil {
+			utp.evicts.remove(hashes[i])
+		}
+	}
+	utp.removePackages(hashes)
+}
+
+// AddPackage records the txns of hashes in the pool as a package, they're evicted together
+// by the fee per byte of the package. The txns spending the outputs of each other are
+// not valid until the outputs are confirmed, and can't be evicted for their own fees.
+func (utp *UnconfirmedTxnPool) AddPackage(bc *Blockchain, hashes []cipher.SHA256) error {
+	if len(hashes) < 2 {
+		return nil
+	}
+
+	if err := utp.Packages.put(hashes); err != nil {
+		return err
+	}
+
+	if utp.evicts != nil {
+		for _, h := range hashes {
+			utp.evicts.remove(h)
+		}
+		utp.evicts.add(utp.packageCandidate(bc, hashes))
+	}
+	return nil
+}
+
+// removePackages removes the txns from their packages, the eviction index is built again
+// if any package still has txns in the pool, as its fee changes.
+func (utp *UnconfirmedTxnPool) removePackages(hashes []cipher.SHA256) {
+	var changed bool
+	for _, h := range hashes {
+		pkg, ok := utp.Packages.get(h)
+		if !ok {
+			continue
+		}
+		utp.Packages.delete(h)
+
+		var rest []cipher.SHA256
+		for _, m := range pkg {
+			if m != h && utp.Txns.isExist(m) {
+				rest = append(rest, m)
+			}
+		}
+
+		if len(rest) > 1 {
+			utp.Packages.put(rest)
+		} else {
+			for _, m := range rest {
+				utp.Packages.delete(m)
+			}
+		}
+
+		if len(rest) > 0 {
+			changed = true
+		}
+	}
+
+	if changed {
+		utp.evicts = nil
+	}
+}
+
+// RemoveTransactions removes confirmed txns from the pool
+func (utp *UnconfirmedTxnPool) RemoveTransactions(txns coin.Transactions) {
+	toRemove := make([]cipher.SHA256, len(txns))
+	for i := range txns {
+		toRemove[i] = txns[i].Hash()
+	}
+	utp.removeTxns(toRemove)
+}
+
+// Refresh checks all unconfirmed txns against the blockchain.
+// verify the transaction and returns all those txns that turn to valid.
+func (utp *UnconfirmedTxnPool) Refresh(bc *Blockchain) (hashes []cipher.SHA256) {
+	now := utc.Now()
+	utp.Txns.rangeUpdate(func(key cipher.SHA256, tx *UnconfirmedTxn) {
+		tx.Checked = now.UnixNano()
+		if tx.IsValid == 0 {
+			if bc.VerifyTransaction(tx.Txn) == nil {
+				tx.IsValid = 1
+				hashes = append(hashes, tx.Hash())
+			}
+		}
+	})
+
+	// the fees of the txns turning valid can be calculated now
+	for _, h := range hashes {
+		if tx, ok := utp.Txns.get(h); ok {
+			utp.indexEviction(bc, tx)
+		}
+	}
+
+	return
+}
+
+// evictCandidate is the unconfirmed txn or package of txns that may be evicted, the
+// package is keyed by the hash of its first txn
+type evictCandidate struct {
+	hash cipher.SHA256
+	// the hashes of the txns of the package, nil if it's a single txn
+	pkg      []cipher.SHA256
+	size     int
+	fee      uint64
+	received int64
+}
+
+// txns returns the hashes of the txns of the candidate
+func (c evictCandidate) txns() []cipher.SHA256 {
+	if len(c.pkg) == 0 {
+		return []cipher.SHA256{c.hash}
+	}
+	return c.pkg
+}
+
+// evictsBefore returns whether a is evicted before b, the candidates are ordered by fee per
+// byte, the ones with the same fee per byte by received time.
+func evictsBefore(a, b evictCandidate) bool {
+	fa := float64(a.fee) / float64(a.size)
+	fb := float64(b.fee) / float64(b.size)
+	if fa == fb {
+		return a.received < b.received
+	}
+	return fa < fb
+}
+
+// byEvictionOrder sorts the candidates in the eviction order, the first one will be
+// evicted first.
+type byEvictionOrder []evictCandidate
+
+func (eo byEvictionOrder) Len() int           { return len(eo) }
+func (eo byEvictionOrder) Swap(i, j int)      { eo[i], eo[j] = eo[j], eo[i] }
+func (eo byEvictionOrder) Less(i, j int) bool { return evictsBefore(eo[i], eo[j]) }
+
+// evictIndex keeps the unconfirmed txns in the eviction order, the fee of a txn is calculated
+// when it's added, so that the pool needn't calculate the fees and sort all the txns whenever
+// a txn is injected.
+type evictIndex struct {
+	cands  map[cipher.SHA256]evictCandidate
+	sorted []evictCandidate
+	bytes  int
+	// number of the txns of the candidates
+	txns int
+}
+
+func newEvictIndex(cands []evictCandidate) *evictIndex {
+	ei := &evictIndex{
+		cands:  make(map[cipher.SHA256]evictCandidate, len(cands)),
+		sorted: cands,
+	}
+	for _, c := range cands {
+		ei.cands[c.hash] = c
+		ei.bytes += c.size
+		ei.txns += len(c.txns())
+	}
+	sort.Sort(byEvictionOrder(ei.sorted))
+	return ei
+}
+
+// add inserts the candidate in order, the candidate of the same hash is replaced
+func (ei *evictIndex) add(c evictCandidate) {
+	ei.remove(c.hash)
+
+	i := sort.Search(len(ei.sorted), func(i int) bool {
+		return evictsBefore(c, ei.sorted[i])
+	})
+	ei.sorted = append(ei.sorted, evictCandidate{})
+	copy(ei.sorted[i+1:], ei.sorted[i:])
+	ei.sorted[i] = c
+
+	ei.cands[c.hash] = c
+	ei.bytes += c.size
+	ei.txns += len(c.txns())
+}
+
+func (ei *evictIndex) remove(hash cipher.SHA256) {
+	c, ok := ei.cands[hash]
+	if !ok {
+		return
+	}
+
+	// the candidates of the same order as c follow the first one not evicted before it
+	i := sort.Search(len(ei.sorted), func(i int) bool {
+		return !evictsBefore(ei.sorted[i], c)
+	})
+	for ; i < len(ei.sorted); i++ {
+		if ei.sorted[i].hash == hash {
+			ei.sorted = append(ei.sorted[:i], ei.sorted[i+1:]...)
+			break
+		}
+	}
+
+	delete(ei.cands, hash)
+	ei.bytes -= c.size
+	ei.txns -= len(c.txns())
+}
+
+// evictCandidate creates the eviction candidate of the txn
+func (utp *UnconfirmedTxnPool) evictCandidate(bc *Blockchain, tx *UnconfirmedTxn) evictCandidate {
+	// the fee of txn whose inputs are unknown can't be calculated, evicts them first
+	fee, err := bc.TransactionFee(&tx.Txn)
+	if err != nil {
+		fee = 0
+	}
+
+	return evictCandidate{
+		hash:     tx.Hash(),
+		size:     tx.Txn.Size(),
+		fee:      fee,
+		received: tx.Received,
+	}
+}
+
+// packageCandidate creates the eviction candidate of the package, the fee of a txn spending
+// the outputs of other txns of the package is calculated with their predicted outputs.
+func (utp *UnconfirmedTxnPool) packageCandidate(bc *Blockchain, pkg []cipher.SHA256) evictCandidate {
+	created := make(map[cipher.SHA256]coin.UxOut)
+	for _, h := range pkg {
+		uxs, err := utp.Unspent.get(h)
+		if err != nil {
+			continue
+		}
+		for _, ux := range uxs {
+			created[ux.Hash()] = ux
+		}
+	}
+
+	c := evictCandidate{
+		hash: pkg[0],
+		pkg:  pkg,
+	}
+	headTime := bc.Time()
+	for _, h := range pkg {
+		tx, ok := utp.Txns.get(h)
+		if !ok {
+			continue
+		}
+
+		c.size += tx.Txn.Size()
+		if tx.Received > c.received {
+			c.received = tx.Received
+		}
+
+		var inHours uint64
+		known := true
+		for _, in := range tx.Txn.In {
+			ux, ok := bc.Unspent().Get(in)
+			if !ok {
+				ux, ok = created[in]
+			}
+
+			if !ok {
+				known = false
+				break
+			}
+			inHours += ux.CoinHours(headTime)
+		}
+
+		// the fee of txn whose inputs are unknown can't be calculated
+		if outHours := tx.Txn.OutputHours(); known && inHours > outHours {
+			c.fee += inHours - outHours
+		}
+	}
+	return c
+}
+
+// evictionIndex returns the eviction index, it's built from the txns in the db when first used
+func (utp *UnconfirmedTxnPool) evictionIndex(bc *Blockchain) *evictIndex {
+	if utp.evicts != nil {
+		return utp.evicts
+	}
+
+	var cands []evictCandidate
+	indexed := make(map[cipher.SHA256]struct{})
+	if err := utp.Txns.forEach(func(hash cipher.SHA256, tx *UnconfirmedTxn) error {
+		pkg, ok := utp.Packages.get(hash)
+		if !ok {
+			cands = append(cands, utp.evictCandidate(bc, tx))
+			return nil
+		}
+
+		if _, ok := indexed[pkg[0]]; !ok {
+			indexed[pkg[0]] = struct{}{}
+			cands = append(cands, utp.packageCandidate(bc, pkg))
+		}
+		return nil
+	}); err != nil {
+		logger.Error("Index unconfirmed txns for eviction failed: %v", err)
+	}
+
+	utp.evicts = newEvictIndex(cands)
+	return utp.evicts
+}
+
+// indexEviction adds or updates the txn or its package in the eviction index if it's built
+func (utp *UnconfirmedTxnPool) indexEviction(bc *Blockchain, tx *UnconfirmedTxn) {
+	if utp.evicts == nil {
+		return
+	}
+
+	if pkg, ok := utp.Packages.get(tx.Hash()); ok {
+		utp.evicts.add(utp.packageCandidate(bc, pkg))
+		return
+	}
+	utp.evicts.add(utp.evictCandidate(bc, tx))
+}
+
+// expired returns whether the txn received at the unix nano time is older than the max age
+func (utp *UnconfirmedTxnPool) expired(received int64, now time.Time) bool {
+	return utp.limits.MaxAge > 0 && now.Sub(nanoToTime(received)) > utp.limits.MaxAge
+}
+
+// TTL returns how long the txn received at the unix nano time can still be held in the
+// pool, it's never negative. Returns false if the txns never expire.
+func (utp *UnconfirmedTxnPool) TTL(received int64, now time.Time) (time.Duration, bool) {
+	if utp.limits.MaxAge <= 0 {
+		return 0, false
+	}
+
+	ttl := utp.limits.MaxAge - now.Sub(nanoToTime(received))
+	if ttl < 0 {
+		ttl = 0
+	}
+	return ttl, true
+}
+
+// OverLimits returns whether the pool holds more txns or bytes than the limits
+func (utp *UnconfirmedTxnPool) OverLimits(bc *Blockchain) bool {
+	ei := utp.evictionIndex(bc)
+	return (utp.limits.MaxTxns > 0 && ei.txns > utp.limits.MaxTxns) ||
+		(utp.limits.MaxBytes > 0 && ei.bytes > utp.limits.MaxBytes)
+}
+
+// Evict removes the txns that are older than the max age, then removes the txns of the
+// lowest fee per byte until the pool is in the limits. Returns the hashes of the expired
+// txns and of the txns evicted for the limits.
+func (utp *UnconfirmedTxnPool) Evict(bc *Blockchain) (expired, overflowed []cipher.SHA256) {
+	expired, overflowed = utp.selectEvictions(utp.evictionIndex(bc).sorted, utc.Now())
+	utp.removeTxns(expired)
+	utp.removeTxns(overflowed)
+	return
+}
+
+// selectEvictions returns the hashes of the txns of the expired candidates and of the
+// candidates that should be evicted for the limits, and updates the eviction metrics. The
+// candidates are in the eviction order, the txns of a package are evicted together.
+func (utp *UnconfirmedTxnPool) selectEvictions(cands []evictCandidate, now time.Time) (expired, overflowed []cipher.SHA256) {
+	var (
+		kept       = make([]evictCandidate, 0, len(cands))
+		totalTxns  int
+		totalBytes int
+	)
+
+	for _, c := range cands {
+		if utp.expired(c.received, now) {
+			expired = append(expired, c.txns()...)
+			utp.evictions.Expired += uint64(len(c.txns()))
+			utp.evictions.Bytes += uint64(c.size)
+			continue
+		}
+
+		kept = append(kept, c)
+		totalTxns += len(c.txns())
+		totalBytes += c.size
+	}
+
+	for _, c := range kept {
+		overTxns := utp.limits.MaxTxns > 0 && totalTxns > utp.limits.MaxTxns
+		overBytes := utp.limits.MaxBytes > 0 && totalBytes > utp.limits.MaxBytes
+		if !overTxns && !overBytes {
+			break
+		}
+
+		overflowed = append(overflowed, c.txns()...)
+		totalTxns -= len(c.txns())
+		totalBytes -= c.size
+		utp.evictions.Overflowed += uint64(len(c.txns()))
+		utp.evictions.Bytes += uint64(c.size)
+	}
+
+	return
+}
+
+// Evictions returns the metrics of the evicted txns
+func (utp *UnconfirmedTxnPool) Evictions() EvictionMetrics {
+	return utp.evictions
+}
+
+// FilterKnown returns txn hashes with known ones removed
+func (utp *UnconfirmedTxnPool) FilterKnown(txns []cipher.SHA256) []cipher.SHA256 {
+	var unknown []cipher.SHA256
+	for _, h := range txns {
+		if !utp.Txns.isExist(h) {
+			unknown = append(unknown, h)
+		}
+	}
+	return unknown
+}
+
+// GetKnown returns all known coin.Transactions from the pool, given hashes to select
+func (utp *UnconfirmedTxnPool) GetKnown(txns []cipher.SHA256) coin.Transactions {
+	var known coin.Transactions
+	for _, h := range txns {
+		if tx, ok := utp.Txns.get(h); ok {
+			known = append(known, tx.Txn)
+		}
+	}
+	return known
+}
+
+// SpendsForAddresses returns all unconfirmed coin.UxOut spends for addresses
+// Looks at all inputs for unconfirmed txns, gets their source UxOut from the
+// blockchain's unspent pool, and returns as coin.AddressUxOuts
+func (utp *UnconfirmedTxnPool) SpendsForAddresses(unspent *blockdb.UnspentPool,
+	addrs []cipher.Address) (coin.AddressUxOuts, error) {
+	addrm := make(map[cipher.Address]struct{}, len(addrs))
+	for _, addr := range addrs {
+		addrm[addr] = struct{}{}
+	}
+
+	auxs := make(coin.AddressUxOuts, len(addrs))
+	if err := utp.Txns.forEach(func(_ cipher.SHA256, tx *UnconfirmedTxn) error {
+		for _, h := range tx.Txn.In {
+			ux, ok := unspent.Get(h)
+			if !ok {
+				// unconfirm transaction's IN is not in the unspent pool, this should not happen
+				return fmt.Errorf("Unconfirmed transaction's IN: %s is not in unspent pool", h.Hex())
+			}
+
+			if _, ok := addrm[ux.Body.Address]; ok {
+				auxs[ux.Body.Address] = append(auxs[ux.Body.Address], ux)
+			}
+		}
+		return nil
+	}); err != nil {
+		return coin.AddressUxOuts{}, fmt.Errorf("SpendsForAddresses error:%v", err)
+	}
+	return auxs, nil
+}
+
+// SpendsForAddress spends for address
+func (utp *UnconfirmedTxnPool) SpendsForAddress(unspent *blockdb.UnspentPool,
+	a cipher.Address) (coin.UxArray, error) {
+	auxs, err := utp.SpendsForAddresses(unspent, []cipher.Address{a})
+	if err != nil {
+		return coin.UxArray{}, err
+	}
+
+	return auxs[a], nil
+}
+
+// AllSpendsOutputs returns all spending outputs in unconfirmed tx pool, the hours are
+// calculated at the head time.
+func (utp *UnconfirmedTxnPool) AllSpendsOutputs(bcUnspent *blockdb.UnspentPool, headTime uint64) ([]ReadableOutput, error) {
+	outs := []ReadableOutput{}
+	if err := utp.Txns.forEach(func(_ cipher.SHA256, tx *UnconfirmedTxn) error {
+		for _, in := range tx.Txn.In {
+			ux, ok := bcUnspent.Get(in)
+
+			if ok {
+				outs = append(outs, NewReadableOutput(headTime, ux))
+			}
+		}
+		return nil
+	}); err != nil {
+		return []ReadableOutput{}, fmt.Errorf("AllSpendsOutputs error:%v", err)
+	}
+	return outs, nil
+}
+
+// AllIncomingOutputs returns all predicted incomming outputs.
+func (utp *UnconfirmedTxnPool) AllIncomingOutputs(bh coin.BlockHeader) ([]ReadableOutput, error) {
+	outs := []ReadableOutput{}
+	if err := utp.Txns.forEach(func(_ cipher.SHA256, tx *UnconfirmedTxn) error {
+		uxOuts := coin.CreateUnspents(bh, tx.Txn)
+		for _, ux := range uxOuts {
+			outs = append(outs, NewReadableOutput(bh.Time, ux))
+		}
+		return nil
+	}); err != nil {
+		return []ReadableOutput{}, fmt.Errorf("AllIncommingOutputs error:%v", err)
+	}
+	return outs, nil
+}
+
+// Get returns the unconfirmed transaction of given tx hash.
+func (utp *UnconfirmedTxnPool) Get(key cipher.SHA256) (*UnconfirmedTxn, bool) {
+	return utp.Txns.get(key)
+}
+
+// GetTxns returns all transactions that can pass the filter
+func (utp *UnconfirmedTxnPool) GetTxns(filter func(tx UnconfirmedTxn) bool) (txns []UnconfirmedTxn) {
+	if err := utp.Txns.forEach(func(hash cipher.SHA256, tx *UnconfirmedTxn) error {
+		if filter(*tx) {
+			txns = append(txns, *tx)
+		}
+		return nil
+	}); err != nil {
+		logger.Debug("GetTxns error:%v", err)
+	}
+	return
+}
+
+// GetTxHashes returns transaction hashes that can pass the filter
+func (utp *UnconfirmedTxnPool) GetTxHashes(filter func(tx UnconfirmedTxn) bool) (hashes []cipher.SHA256) {
+	if err := utp.Txns.forEach(func(hash cipher.SHA256, tx *UnconfirmedTxn) error {
+		if filter(*tx) {
+			hashes = append(hashes, hash)
+		}
+		return nil
+	}); err != nil {
+		logger.Debug("GetTxHashes error:%v", err)
+	}
+	return
+}
+
+// IsValid can be used as filter function
+func IsValid(tx UnconfirmedTxn) bool {
+	return tx.IsValid == 1
+}
+
+// All use as return all filter
+func All(tx UnconfirmedTxn) bool {
+	return true
+}
+
+// Len returns the number of unconfirmed transactions
+func (utp *UnconfirmedTxnPool) Len() int {
+	return utp.Txns.len()
+}
+
+func nanoToTime(n int64) time.Time {
+	zeroTime := time.Time{}
+	if n == zeroTime.UnixNano() {
+		// maximum time
+		return zeroTime
+	}
+	return time.Unix(n/int64(time.Second), n%int64(time.Second))
+}
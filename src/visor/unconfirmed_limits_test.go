@@ -0,0 +1,154 @@
+package visor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestSelectEvictions(t *testing.T) {
+	now := time.Unix(1502870712, 0)
+	received := func(age time.Duration) int64 {
+		return now.Add(-age).UnixNano()
+	}
+
+	var (
+		expired = evictCandidate{hash: randSHA256(), size: 100, fee: 1000, received: received(time.Hour * 3)}
+		cheap   = evictCandidate{hash: randSHA256(), size: 200, fee: 200, received: received(time.Minute)}
+		oldest  = evictCandidate{hash: randSHA256(), size: 100, fee: 300, received: received(time.Hour)}
+		newer   = evictCandidate{hash: randSHA256(), size: 100, fee: 300, received: received(time.Minute)}
+		rich    = evictCandidate{hash: randSHA256(), size: 300, fee: 9000, received: received(time.Minute)}
+	)
+	// in the eviction order
+	cands := []evictCandidate{cheap, oldest, newer, expired, rich}
+
+	tt := []struct {
+		name      string
+		limits    UnconfirmedLimits
+		evicted   []cipher.SHA256
+		evictions EvictionMetrics
+	}{
+		{
+			"no limits",
+			UnconfirmedLimits{},
+			nil,
+			EvictionMetrics{},
+		},
+		{
+			"max age",
+			UnconfirmedLimits{MaxAge: time.Hour * 2},
+			[]cipher.SHA256{expired.hash},
+			EvictionMetrics{Expired: 1, Bytes: 100},
+		},
+		{
+			"max txns",
+			UnconfirmedLimits{MaxTxns: 2, MaxAge: time.Hour * 2},
+			[]cipher.SHA256{expired.hash, cheap.hash, oldest.hash},
+			EvictionMetrics{Expired: 1, Overflowed: 2, Bytes: 400},
+		},
+		{
+			"max bytes",
+			UnconfirmedLimits{MaxBytes: 500},
+			[]cipher.SHA256{cheap.hash, oldest.hash},
+			EvictionMetrics{Overflowed: 2, Bytes: 300},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			utp := &UnconfirmedTxnPool{limits: tc.limits}
+			expired, overflowed := utp.selectEvictions(cands, now)
+			assert.Equal(t, tc.evicted, append(expired, overflowed...))
+			assert.Equal(t, tc.evictions, utp.Evictions())
+		})
+	}
+}
+
+func TestSelectEvictionsPackage(t *testing.T) {
+	now := time.Unix(1502870712, 0)
+	received := now.Add(-time.Minute).UnixNano()
+
+	// the txns of the package are evicted together by the fee per byte of the package
+	pkg := []cipher.SHA256{randSHA256(), randSHA256(), randSHA256()}
+	var (
+		batch = evictCandidate{hash: pkg[0], pkg: pkg, size: 300, fee: 600, received: received}
+		cheap = evictCandidate{hash: randSHA256(), size: 100, fee: 100, received: received}
+		rich  = evictCandidate{hash: randSHA256(), size: 100, fee: 900, received: received}
+	)
+	cands := []evictCandidate{cheap, batch, rich}
+
+	utp := &UnconfirmedTxnPool{limits: UnconfirmedLimits{MaxTxns: 2}}
+	expired, overflowed := utp.selectEvictions(cands, now)
+	assert.Empty(t, expired)
+	assert.Equal(t, append([]cipher.SHA256{cheap.hash}, pkg...), overflowed)
+	assert.Equal(t, EvictionMetrics{Overflowed: 4, Bytes: 400}, utp.Evictions())
+
+	utp = &UnconfirmedTxnPool{limits: UnconfirmedLimits{MaxTxns: 4}}
+	expired, overflowed = utp.selectEvictions(cands, now)
+	assert.Empty(t, expired)
+	assert.Equal(t, []cipher.SHA256{cheap.hash}, overflowed)
+}
+
+func TestUnconfirmedTTL(t *testing.T) {
+	now := time.Unix(1502870712, 0)
+	received := now.Add(-time.Hour).UnixNano()
+
+	utp := &UnconfirmedTxnPool{}
+	_, ok := utp.TTL(received, now)
+	assert.False(t, ok)
+	assert.False(t, utp.expired(received, now))
+
+	utp.limits.MaxAge = time.Hour * 3
+	ttl, ok := utp.TTL(received, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Hour*2, ttl)
+	assert.False(t, utp.expired(received, now))
+
+	utp.limits.MaxAge = time.Minute
+	ttl, ok = utp.TTL(received, now)
+	assert.True(t, ok)
+	assert.Equal(t, time.Duration(0), ttl)
+	assert.True(t, utp.expired(received, now))
+}
+
+func TestEvictIndex(t *testing.T) {
+	var (
+		cheap  = evictCandidate{hash: randSHA256(), size: 200, fee: 200, received: 2}
+		oldest = evictCandidate{hash: randSHA256(), size: 100, fee: 300, received: 1}
+		newer  = evictCandidate{hash: randSHA256(), size: 100, fee: 300, received: 2}
+		rich   = evictCandidate{hash: randSHA256(), size: 300, fee: 9000, received: 2}
+	)
+
+	ei := newEvictIndex([]evictCandidate{rich, newer, cheap})
+	assert.Equal(t, []evictCandidate{cheap, newer, rich}, ei.sorted)
+	assert.Equal(t, 600, ei.bytes)
+
+	ei.add(oldest)
+	assert.Equal(t, []evictCandidate{cheap, oldest, newer, rich}, ei.sorted)
+	assert.Equal(t, 700, ei.bytes)
+
+	// the candidate is moved when it's received again
+	oldest.received = 3
+	ei.add(oldest)
+	assert.Equal(t, []evictCandidate{cheap, newer, oldest, rich}, ei.sorted)
+	assert.Equal(t, 700, ei.bytes)
+
+	ei.remove(newer.hash)
+	ei.remove(newer.hash)
+	assert.Equal(t, []evictCandidate{cheap, oldest, rich}, ei.sorted)
+	assert.Equal(t, 600, ei.bytes)
+	assert.Len(t, ei.cands, 3)
+	assert.Equal(t, 3, ei.txns)
+
+	// the txns of the package are counted
+	pkg := []cipher.SHA256{randSHA256(), randSHA256()}
+	ei.add(evictCandidate{hash: pkg[0], pkg: pkg, size: 200, fee: 1000, received: 2})
+	assert.Equal(t, 5, ei.txns)
+	assert.Equal(t, 800, ei.bytes)
+
+	ei.remove(pkg[0])
+	assert.Equal(t, 3, ei.txns)
+}
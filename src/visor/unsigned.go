@@ -0,0 +1,628 @@
+package visor
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/encoder"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+// UnsignedInput is an output spent by the unsigned transaction, the signer finds the
+// secret key of the input by the address, or by the public keys of the multisig address
+type UnsignedInput struct {
+	Hash    string `json:"hash"`
+	Address string `json:"address"`
+	Coins   string `json:"coins"`
+	// the hours are calculated with the head block time when the txn is created
+	Hours uint64 `json:"hours"`
+	// the output spent, the signer checks the coins of the input against the hash with it
+	UxOut *UnsignedUxOut `json:"uxout,omitempty"`
+
+	// the signature of the single key address, empty till it's signed
+	Sig      string            `json:"sig,omitempty"`
+	Multisig *UnsignedMultisig `json:"multisig,omitempty"`
+}
+
+// UnsignedUxOut is the output spent by the input, the address is the input address. Coins
+// are in droplets and Hours are the initial hours of the output.
+type UnsignedUxOut struct {
+	Time           uint64 `json:"time"`
+	BkSeq          uint64 `json:"block_seq"`
+	SrcTransaction string `json:"src_txn"`
+	Coins          uint64 `json:"coins"`
+	Hours          uint64 `json:"hours"`
+}
+
+// UnsignedMultisig is the multisig address of the input, the signatures are collected from
+// the owners of the public keys till there are enough of them
+type UnsignedMultisig struct {
+	Required int      `json:"required"`
+	PubKeys  []string `json:"pubkeys"`
+	// signatures by public key
+	Sigs map[string]string `json:"sigs"`
+}
+
+// UnsignedOutput is an output created by the unsigned transaction
+type UnsignedOutput struct {
+	Address string `json:"address"`
+	Coins   string `json:"coins"`
+	Hours   uint64 `json:"hours"`
+}
+
+// UnsignedTransaction is a transaction without the signatures, it's created by a node
+// that has no secret keys and signed offline by the wallet that owns the inputs. Hex is
+// the serialized transaction, the other fields are for reviewing it before signing.
+//
+// It's a partially signed transaction as well, the signatures are accumulated in the
+// inputs as the cosigners, the wallets or the external signers sign it in turn, or the
+// copies signed in parallel are merged by CombineUnsignedTransactions. Finalize creates
+// the signed txn once every input has enough signatures.
+type UnsignedTransaction struct {
+	Hex       string           `json:"hex"`
+	InnerHash string           `json:"inner_hash"`
+	Inputs    []UnsignedInput  `json:"inputs"`
+	Outputs   []UnsignedOutput `json:"outputs"`
+	Fee       uint64           `json:"fee"`
+}
+
+// NewUnsignedTransaction creates the UnsignedTransaction of the txn spending the uxs,
+// the uxs must be in the order of the txn inputs. The multisig addresses of the inputs
+// must be in multisigs.
+func NewUnsignedTransaction(txn coin.Transaction, uxs coin.UxArray, headTime uint64,
+	multisigs ...wallet.Multisig) (*UnsignedTransaction, error) {
+	if len(txn.Sigs) != 0 {
+		return nil, errors.New("the transaction is signed")
+	}
+
+	if len(uxs) != len(txn.In) {
+		return nil, errors.New("the outputs don't match the transaction inputs")
+	}
+
+	ut := &UnsignedTransaction{
+		Hex:       hex.EncodeToString(txn.Serialize()),
+		InnerHash: txn.InnerHash.Hex(),
+		Inputs:    make([]UnsignedInput, len(uxs)),
+		Outputs:   make([]UnsignedOutput, len(txn.Out)),
+	}
+
+	var hoursIn uint64
+	for i, ux := range uxs {
+		if ux.Hash() != txn.In[i] {
+			return nil, errors.New("the outputs don't match the transaction inputs")
+		}
+
+		hours := ux.CoinHours(headTime)
+		hoursIn += hours
+		ut.Inputs[i] = UnsignedInput{
+			Hash:    txn.In[i].Hex(),
+			Address: ux.Body.Address.String(),
+			Coins:   StrBalance(ux.Body.Coins),
+			Hours:   hours,
+			UxOut: &UnsignedUxOut{
+				Time:           ux.Head.Time,
+				BkSeq:          ux.Head.BkSeq,
+				SrcTransaction: ux.Body.SrcTransaction.Hex(),
+				Coins:          ux.Body.Coins,
+				Hours:          ux.Body.Hours,
+			},
+		}
+
+		if !ux.Body.Address.IsMultisig() {
+			continue
+		}
+
+		ms, ok := findMultisig(multisigs, ux.Body.Address)
+		if !ok {
+			return nil, fmt.Errorf("the public keys of multisig address %s are unknown", ux.Body.Address)
+		}
+
+		um := &UnsignedMultisig{
+			Required: ms.Required,
+			PubKeys:  make([]string, len(ms.PubKeys)),
+			Sigs:     make(map[string]string),
+		}
+		for j, k := range ms.PubKeys {
+			um.PubKeys[j] = k.Hex()
+		}
+		ut.Inputs[i].Multisig = um
+	}
+
+	for i, out := range txn.Out {
+		ut.Outputs[i] = UnsignedOutput{
+			Address: out.Address.String(),
+			Coins:   StrBalance(out.Coins),
+			Hours:   out.Hours,
+		}
+	}
+
+	hoursOut := txn.OutputHours()
+	if hoursIn < hoursOut {
+		return nil, errors.New("the output hours exceed the input hours")
+	}
+	ut.Fee = hoursIn - hoursOut
+	return ut, nil
+}
+
+// Transaction decodes the unsigned txn from the hex, and checks that it matches the
+// inputs and the outputs listed for review. The signatures are not checked.
+func (ut UnsignedTransaction) Transaction() (coin.Transaction, error) {
+	b, err := hex.DecodeString(ut.Hex)
+	if err != nil {
+		return coin.Transaction{}, fmt.Errorf("invalid hex: %v", err)
+	}
+
+	var txn coin.Transaction
+	if err := encoder.DeserializeRaw(b, &txn); err != nil {
+		return coin.Transaction{}, fmt.Errorf("cannot deserialize: %v", err)
+	}
+
+	if len(txn.Sigs) != 0 {
+		return coin.Transaction{}, errors.New("the transaction is signed")
+	}
+
+	if txn.InnerHash != txn.HashInner() || txn.InnerHash.Hex() != ut.InnerHash {
+		return coin.Transaction{}, errors.New("inner hash does not match")
+	}
+
+	if len(txn.In) != len(ut.Inputs) {
+		return coin.Transaction{}, errors.New("the inputs don't match the transaction")
+	}
+	for i, in := range txn.In {
+		if in.Hex() != ut.Inputs[i].Hash {
+			return coin.Transaction{}, errors.New("the inputs don't match the transaction")
+		}
+
+		if _, err := ut.Inputs[i].multisig(); err != nil {
+			return coin.Transaction{}, err
+		}
+
+		if err := ut.Inputs[i].checkUxOut(); err != nil {
+			return coin.Transaction{}, err
+		}
+	}
+
+	if len(txn.Out) != len(ut.Outputs) {
+		return coin.Transaction{}, errors.New("the outputs don't match the transaction")
+	}
+	for i, out := range txn.Out {
+		o := ut.Outputs[i]
+		if out.Address.String() != o.Address || StrBalance(out.Coins) != o.Coins || out.Hours != o.Hours {
+			return coin.Transaction{}, errors.New("the outputs don't match the transaction")
+		}
+	}
+
+	return txn, nil
+}
+
+// Sign adds the signatures of the inputs that the wallet has the secret keys of, the
+// single key addresses or the public keys of the multisig addresses. Returns the number of
+// signatures added.
+func (ut *UnsignedTransaction) Sign(wlt wallet.Wallet) (int, error) {
+	if wlt.IsEncrypted() {
+		return 0, wallet.ErrWalletEncrypted
+	}
+	return ut.SignWithSigner(wallet.NewKeySigner(&wlt), wlt.GetAddresses())
+}
+
+// SignWithSigner adds the signatures of the inputs by the signer, e.g. a hardware signer,
+// addrs are the addresses the signer has the keys of. The key of a multisig public key is
+// found by its single key address. Each signature is checked before it's added. Returns
+// the number of signatures added.
+func (ut *UnsignedTransaction) SignWithSigner(signer wallet.TxnSigner, addrs []cipher.Address) (int, error) {
+	txn, err := ut.Transaction()
+	if err != nil {
+		return 0, err
+	}
+
+	own := make(map[cipher.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		own[a] = struct{}{}
+	}
+
+	sign := func(addr cipher.Address, hash cipher.SHA256) (string, error) {
+		sig, err := signer.SignHash(addr, hash)
+		if err != nil {
+			return "", err
+		}
+
+		if err := cipher.ChkSig(addr, hash, sig); err != nil {
+			return "", fmt.Errorf("invalid signature of address %s: %v", addr, err)
+		}
+		return sig.Hex(), nil
+	}
+
+	var n int
+	for i := range ut.Inputs {
+		in := &ut.Inputs[i]
+		hash := txn.SigHash(i)
+
+		ms, err := in.multisig()
+		if err != nil {
+			return n, err
+		}
+
+		if ms == nil {
+			addr, err := cipher.DecodeBase58Address(in.Address)
+			if err != nil {
+				return n, fmt.Errorf("invalid address of input %s: %v", in.Hash, err)
+			}
+
+			if _, ok := own[addr]; ok && in.Sig == "" {
+				if in.Sig, err = sign(addr, hash); err != nil {
+					return n, err
+				}
+				n++
+			}
+			continue
+		}
+
+		if in.Multisig.Sigs == nil {
+			in.Multisig.Sigs = make(map[string]string)
+		}
+		for _, k := range ms.PubKeys {
+			if _, ok := in.Multisig.Sigs[k.Hex()]; ok {
+				continue
+			}
+
+			addr := cipher.AddressFromPubKey(k)
+			if _, ok := own[addr]; !ok {
+				continue
+			}
+
+			sig, err := sign(addr, hash)
+			if err != nil {
+				return n, err
+			}
+			in.Multisig.Sigs[k.Hex()] = sig
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+// CombineUnsignedTransactions merges the signatures of the copies of the same unsigned
+// txn, e.g. signed by the cosigners in parallel. The signatures are checked before they're
+// merged, the first valid one of a single key input is kept.
+func CombineUnsignedTransactions(uts ...UnsignedTransaction) (*UnsignedTransaction, error) {
+	if len(uts) == 0 {
+		return nil, errors.New("no transaction to combine")
+	}
+
+	c := uts[0].copy()
+	txn, err := c.Transaction()
+	if err != nil {
+		return nil, err
+	}
+
+	// the signatures of the first one are checked as the others
+	for i := range c.Inputs {
+		c.Inputs[i].Sig = ""
+		if c.Inputs[i].Multisig != nil {
+			c.Inputs[i].Multisig.Sigs = make(map[string]string)
+		}
+	}
+
+	for _, ut := range uts {
+		if ut.Hex != c.Hex {
+			return nil, errors.New("the transactions to combine are different")
+		}
+
+		if _, err := ut.Transaction(); err != nil {
+			return nil, err
+		}
+
+		for i, in := range ut.Inputs {
+			if err := c.Inputs[i].merge(in, txn.SigHash(i)); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &c, nil
+}
+
+// merge adds the signatures of other to the input, other is the same input of a copy
+func (in *UnsignedInput) merge(other UnsignedInput, hash cipher.SHA256) error {
+	if in.Address != other.Address || (in.Multisig == nil) != (other.Multisig == nil) {
+		return fmt.Errorf("input %s is different in the transactions", in.Hash)
+	}
+
+	if in.Multisig == nil {
+		if in.Sig != "" || other.Sig == "" {
+			return nil
+		}
+
+		addr, err := cipher.DecodeBase58Address(in.Address)
+		if err != nil {
+			return fmt.Errorf("invalid address of input %s: %v", in.Hash, err)
+		}
+
+		if err := checkSigHex(addr, hash, other.Sig); err != nil {
+			return fmt.Errorf("invalid signature of input %s: %v", in.Hash, err)
+		}
+		in.Sig = other.Sig
+		return nil
+	}
+
+	ms, err := in.multisig()
+	if err != nil {
+		return err
+	}
+
+	oms, err := other.multisig()
+	if err != nil {
+		return err
+	}
+
+	if ms.Required != oms.Required {
+		return fmt.Errorf("input %s is different in the transactions", in.Hash)
+	}
+
+	for _, k := range ms.PubKeys {
+		s, ok := other.Multisig.Sigs[k.Hex()]
+		if !ok {
+			continue
+		}
+
+		if _, ok := in.Multisig.Sigs[k.Hex()]; ok {
+			continue
+		}
+
+		if err := checkSigHex(cipher.AddressFromPubKey(k), hash, s); err != nil {
+			return fmt.Errorf("invalid signature of input %s: %v", in.Hash, err)
+		}
+		in.Multisig.Sigs[k.Hex()] = s
+	}
+	return nil
+}
+
+func checkSigHex(addr cipher.Address, hash cipher.SHA256, s string) error {
+	sig, err := cipher.SigFromHex(s)
+	if err != nil {
+		return err
+	}
+	return cipher.ChkSig(addr, hash, sig)
+}
+
+// Complete returns true if every input has enough signatures
+func (ut UnsignedTransaction) Complete() bool {
+	for _, in := range ut.Inputs {
+		if in.Multisig == nil {
+			if in.Sig == "" {
+				return false
+			}
+			continue
+		}
+
+		if len(in.Multisig.Sigs) < in.Multisig.Required {
+			return false
+		}
+	}
+	return true
+}
+
+// Finalize creates the signed txn from the collected signatures, the multisig inputs take
+// the required number of signatures in the order of the public keys
+func (ut UnsignedTransaction) Finalize() (coin.Transaction, error) {
+	txn, err := ut.Transaction()
+	if err != nil {
+		return coin.Transaction{}, err
+	}
+
+	ws := make([]coin.InputWitness, len(ut.Inputs))
+	for i, in := range ut.Inputs {
+		addr, err := cipher.DecodeBase58Address(in.Address)
+		if err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid address of input %s: %v", in.Hash, err)
+		}
+
+		ms, err := in.multisig()
+		if err != nil {
+			return coin.Transaction{}, err
+		}
+
+		if ms == nil {
+			if in.Sig == "" {
+				return coin.Transaction{}, fmt.Errorf("input %s of address %s is not signed", in.Hash, in.Address)
+			}
+
+			sig, err := cipher.SigFromHex(in.Sig)
+			if err != nil {
+				return coin.Transaction{}, fmt.Errorf("invalid signature of input %s: %v", in.Hash, err)
+			}
+			ws[i].Sig = sig
+		} else {
+			w := &coin.MultisigWitness{
+				Required: ms.Required,
+				PubKeys:  ms.PubKeys,
+			}
+			for _, k := range ms.PubKeys {
+				s, ok := in.Multisig.Sigs[k.Hex()]
+				if !ok || len(w.Sigs) == w.Required {
+					continue
+				}
+
+				sig, err := cipher.SigFromHex(s)
+				if err != nil {
+					return coin.Transaction{}, fmt.Errorf("invalid signature of input %s: %v", in.Hash, err)
+				}
+				w.Sigs = append(w.Sigs, sig)
+			}
+
+			if len(w.Sigs) < w.Required {
+				return coin.Transaction{}, fmt.Errorf("multisig input %s has %d of %d signatures", in.Hash, len(w.Sigs), w.Required)
+			}
+			ws[i].Multisig = w
+		}
+
+		if err := ws[i].VerifyAddress(addr, txn.SigHash(i)); err != nil {
+			return coin.Transaction{}, fmt.Errorf("invalid signature of input %s: %v", in.Hash, err)
+		}
+	}
+
+	if err := txn.SetWitnesses(ws); err != nil {
+		return coin.Transaction{}, err
+	}
+	txn.UpdateHeader()
+
+	if err := txn.Verify(); err != nil {
+		return coin.Transaction{}, err
+	}
+	return txn, nil
+}
+
+// SignUnsignedTransaction signs the unsigned txn with the secret keys of the wallet, the
+// wallet must have all the keys needed, ut is not changed
+func SignUnsignedTransaction(ut UnsignedTransaction, wlt wallet.Wallet) (coin.Transaction, error) {
+	c := ut.copy()
+	if _, err := c.Sign(wlt); err != nil {
+		return coin.Transaction{}, err
+	}
+	return c.Finalize()
+}
+
+// copy copies the inputs and the collected signatures
+func (ut UnsignedTransaction) copy() UnsignedTransaction {
+	ut.Inputs = append([]UnsignedInput(nil), ut.Inputs...)
+	for i, in := range ut.Inputs {
+		if in.Multisig == nil {
+			continue
+		}
+
+		um := *in.Multisig
+		um.Sigs = make(map[string]string, len(in.Multisig.Sigs))
+		for k, v := range in.Multisig.Sigs {
+			um.Sigs[k] = v
+		}
+		ut.Inputs[i].Multisig = &um
+	}
+	return ut
+}
+
+// multisig returns the multisig address of the input, nil if the address is a single key
+// address
+func (in UnsignedInput) multisig() (*wallet.Multisig, error) {
+	addr, err := cipher.DecodeBase58Address(in.Address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address of input %s: %v", in.Hash, err)
+	}
+
+	if !addr.IsMultisig() {
+		if in.Multisig != nil {
+			return nil, fmt.Errorf("address %s of input %s is not multisig", in.Address, in.Hash)
+		}
+		return nil, nil
+	}
+
+	if in.Multisig == nil {
+		return nil, fmt.Errorf("the public keys of multisig address %s are not set", in.Address)
+	}
+
+	ms, err := wallet.ReadableMultisig{
+		Address:  in.Address,
+		Required: in.Multisig.Required,
+		PubKeys:  in.Multisig.PubKeys,
+	}.ToMultisig()
+	if err != nil {
+		return nil, err
+	}
+	return &ms, nil
+}
+
+// checkUxOut checks that the output spent matches the input hash, and the address and
+// the coins reviewed
+func (in UnsignedInput) checkUxOut() error {
+	if in.UxOut == nil {
+		return nil
+	}
+
+	addr, err := cipher.DecodeBase58Address(in.Address)
+	if err != nil {
+		return fmt.Errorf("invalid address of input %s: %v", in.Hash, err)
+	}
+
+	src, err := cipher.SHA256FromHex(in.UxOut.SrcTransaction)
+	if err != nil {
+		return fmt.Errorf("invalid src_txn of input %s: %v", in.Hash, err)
+	}
+
+	ux := coin.UxOut{
+		Head: coin.UxHead{Time: in.UxOut.Time, BkSeq: in.UxOut.BkSeq},
+		Body: coin.UxBody{
+			SrcTransaction: src,
+			Address:        addr,
+			Coins:          in.UxOut.Coins,
+			Hours:          in.UxOut.Hours,
+		},
+	}
+	if ux.Hash().Hex() != in.Hash || StrBalance(ux.Body.Coins) != in.Coins {
+		return fmt.Errorf("the uxout doesn't match input %s", in.Hash)
+	}
+	return nil
+}
+
+func findMultisig(mss []wallet.Multisig, addr cipher.Address) (wallet.Multisig, bool) {
+	for _, ms := range mss {
+		if ms.Address == addr {
+			return ms, true
+		}
+	}
+	return wallet.Multisig{}, false
+}
+
+// CreateUnsignedTransaction creates the txn sending amt to dest from the addresses, the
+// outputs are chosen by sel and the hours are split the same way as the wallet spends. The
+// change is sent to the change address, or the address of the first input if it's not
+// set. The multisig addresses of the addresses must be in multisigs.
+func (vs *Visor) CreateUnsignedTransaction(addrs []cipher.Address, amt wallet.Balance,
+	dest, change cipher.Address, multisigs []wallet.Multisig, sel CoinSelector) (*UnsignedTransaction, error) {
+	unspent := vs.Blockchain.Unspent()
+	headTime := vs.Blockchain.Time()
+
+	auxs := unspent.GetUnspentsOfAddrs(addrs)
+	puxs, err := vs.Unconfirmed.SpendsForAddresses(unspent, addrs)
+	if err != nil {
+		return nil, err
+	}
+	auxs = auxs.Sub(puxs)
+
+	spends, err := createSpends(headTime, auxs.Flatten(), amt, sel)
+	if err != nil {
+		return nil, err
+	}
+
+	txn := coin.Transaction{}
+	var spending wallet.Balance
+	for _, ux := range spends {
+		txn.PushInput(ux.Hash())
+		spending.Coins += ux.Body.Coins
+		spending.Hours += ux.CoinHours(headTime)
+	}
+
+	// keep 1/4th of hours as change, send half to each address
+	changeHours := spending.Hours / 4
+	if spending.Coins > amt.Coins {
+		if change == (cipher.Address{}) {
+			change = spends[0].Body.Address
+		}
+		txn.PushOutput(change, spending.Coins-amt.Coins, changeHours/2)
+	}
+	txn.PushOutput(dest, amt.Coins, changeHours/2)
+	txn.UpdateHeader()
+
+	ut, err := NewUnsignedTransaction(txn, spends, headTime, multisigs...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifyBurnFee(ut.Fee, txn.OutputHours()); err != nil {
+		return nil, err
+	}
+
+	return ut, nil
+}
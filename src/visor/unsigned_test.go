@@ -0,0 +1,252 @@
+package visor
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/wallet"
+)
+
+func TestSignUnsignedTransaction(t *testing.T) {
+	wlt, err := wallet.NewWallet("a.wlt", wallet.OptSeed("offline"))
+	require.NoError(t, err)
+	addrs := wlt.GenerateAddresses(2)
+
+	headTime := uint64(1500000000)
+	uxs := coin.UxArray{
+		{
+			Head: coin.UxHead{Time: headTime, BkSeq: 1},
+			Body: coin.UxBody{SrcTransaction: randSHA256(), Address: addrs[0], Coins: 2e6, Hours: 100},
+		},
+		{
+			Head: coin.UxHead{Time: headTime, BkSeq: 2},
+			Body: coin.UxBody{SrcTransaction: randSHA256(), Address: addrs[1], Coins: 3e6, Hours: 60},
+		},
+	}
+
+	pub, _ := cipher.GenerateKeyPair()
+	dest := cipher.AddressFromPubKey(pub)
+	txn := coin.Transaction{}
+	for _, ux := range uxs {
+		txn.PushInput(ux.Hash())
+	}
+	txn.PushOutput(addrs[0], 1e6, 20)
+	txn.PushOutput(dest, 4e6, 20)
+	txn.UpdateHeader()
+
+	ut, err := NewUnsignedTransaction(txn, uxs, headTime)
+	require.NoError(t, err)
+	require.Len(t, ut.Inputs, 2)
+	assert.Equal(t, addrs[1].String(), ut.Inputs[1].Address)
+	assert.Equal(t, "3", ut.Inputs[1].Coins)
+	assert.Equal(t, dest.String(), ut.Outputs[1].Address)
+	assert.Equal(t, uint64(160-40), ut.Fee)
+
+	// exported as json and signed offline
+	b, err := json.Marshal(ut)
+	require.NoError(t, err)
+	var imported UnsignedTransaction
+	require.NoError(t, json.Unmarshal(b, &imported))
+
+	signed, err := SignUnsignedTransaction(imported, *wlt)
+	require.NoError(t, err)
+	require.NoError(t, signed.Verify())
+	require.NoError(t, signed.VerifyInput(uxs))
+	assert.Equal(t, txn.InnerHash, signed.InnerHash)
+
+	// the signed json is imported for broadcast
+	txn2, err := TransactionFromJSONStrict(TransactionToJSON(signed))
+	require.NoError(t, err)
+	assert.Equal(t, signed, txn2)
+
+	// the reviewed outputs must match the hex
+	tampered := imported
+	tampered.Outputs = append([]UnsignedOutput(nil), imported.Outputs...)
+	tampered.Outputs[1].Address = addrs[1].String()
+	_, err = SignUnsignedTransaction(tampered, *wlt)
+	assert.Error(t, err)
+
+	// the inputs must be owned by the wallet
+	other, err := wallet.NewWallet("b.wlt", wallet.OptSeed("other"))
+	require.NoError(t, err)
+	other.GenerateAddresses(1)
+	_, err = SignUnsignedTransaction(imported, *other)
+	assert.Error(t, err)
+
+	// the wallet must be decrypted
+	require.NoError(t, wlt.Encrypt([]byte("pwd")))
+	_, err = SignUnsignedTransaction(imported, *wlt)
+	assert.Equal(t, wallet.ErrWalletEncrypted, err)
+
+	// the signed txn can't be exported as unsigned
+	_, err = NewUnsignedTransaction(signed, uxs, headTime)
+	assert.Error(t, err)
+}
+
+func TestSignUnsignedMultisigTransaction(t *testing.T) {
+	var wlts []*wallet.Wallet
+	var keys []cipher.PubKey
+	for _, seed := range []string{"alice", "bob", "carol"} {
+		wlt, err := wallet.NewWallet(seed+".wlt", wallet.OptSeed(seed))
+		require.NoError(t, err)
+		e, _ := wlt.GetEntry(wlt.GenerateAddresses(1)[0])
+		wlts = append(wlts, wlt)
+		keys = append(keys, e.Public)
+	}
+
+	ms, err := wlts[0].AddMultisig(2, keys)
+	require.NoError(t, err)
+
+	headTime := uint64(1500000000)
+	uxs := coin.UxArray{
+		{
+			Head: coin.UxHead{Time: headTime, BkSeq: 1},
+			Body: coin.UxBody{SrcTransaction: randSHA256(), Address: ms.Address, Coins: 5e6, Hours: 100},
+		},
+	}
+
+	pub, _ := cipher.GenerateKeyPair()
+	txn := coin.Transaction{}
+	txn.PushInput(uxs[0].Hash())
+	txn.PushOutput(ms.Address, 1e6, 20)
+	txn.PushOutput(cipher.AddressFromPubKey(pub), 4e6, 20)
+	txn.UpdateHeader()
+
+	// the keys of the multisig input must be known
+	_, err = NewUnsignedTransaction(txn, uxs, headTime)
+	assert.Error(t, err)
+
+	ut, err := NewUnsignedTransaction(txn, uxs, headTime, ms)
+	require.NoError(t, err)
+	require.NotNil(t, ut.Inputs[0].Multisig)
+	assert.Equal(t, 2, ut.Inputs[0].Multisig.Required)
+	assert.False(t, ut.Complete())
+
+	// the first owner signs and passes on the json
+	n, err := ut.Sign(*wlts[2])
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.False(t, ut.Complete())
+	_, err = ut.Finalize()
+	assert.Error(t, err)
+	_, err = SignUnsignedTransaction(*ut, *wlts[1])
+	require.NoError(t, err)
+
+	b, err := json.Marshal(ut)
+	require.NoError(t, err)
+	var imported UnsignedTransaction
+	require.NoError(t, json.Unmarshal(b, &imported))
+
+	// signing again adds nothing
+	n, err = imported.Sign(*wlts[2])
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+
+	n, err = imported.Sign(*wlts[0])
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.True(t, imported.Complete())
+
+	signed, err := imported.Finalize()
+	require.NoError(t, err)
+	require.NoError(t, signed.Verify())
+	require.NoError(t, signed.VerifyInput(uxs))
+	assert.Equal(t, txn.InnerHash, signed.InnerHash)
+
+	txn2, err := TransactionFromJSONStrict(TransactionToJSON(signed))
+	require.NoError(t, err)
+	assert.Equal(t, signed, txn2)
+
+	// the keys of the input must match the address
+	tampered := imported.copy()
+	tampered.Inputs[0].Multisig.Required = 1
+	_, err = tampered.Finalize()
+	assert.Error(t, err)
+}
+
+func TestCombineUnsignedTransactions(t *testing.T) {
+	var wlts []*wallet.Wallet
+	var keys []cipher.PubKey
+	for _, seed := range []string{"alice", "bob", "carol"} {
+		wlt, err := wallet.NewWallet(seed+".wlt", wallet.OptSeed(seed))
+		require.NoError(t, err)
+		e, _ := wlt.GetEntry(wlt.GenerateAddresses(1)[0])
+		wlts = append(wlts, wlt)
+		keys = append(keys, e.Public)
+	}
+
+	ms, err := wlts[0].AddMultisig(2, keys)
+	require.NoError(t, err)
+
+	headTime := uint64(1500000000)
+	uxs := coin.UxArray{
+		{
+			Head: coin.UxHead{Time: headTime, BkSeq: 1},
+			Body: coin.UxBody{SrcTransaction: randSHA256(), Address: ms.Address, Coins: 5e6, Hours: 100},
+		},
+		{
+			Head: coin.UxHead{Time: headTime, BkSeq: 2},
+			Body: coin.UxBody{SrcTransaction: randSHA256(), Address: wlts[1].Entries[0].Address, Coins: 1e6, Hours: 10},
+		},
+	}
+
+	pub, _ := cipher.GenerateKeyPair()
+	txn := coin.Transaction{}
+	for _, ux := range uxs {
+		txn.PushInput(ux.Hash())
+	}
+	txn.PushOutput(cipher.AddressFromPubKey(pub), 6e6, 20)
+	txn.UpdateHeader()
+
+	ut, err := NewUnsignedTransaction(txn, uxs, headTime, ms)
+	require.NoError(t, err)
+	require.NotNil(t, ut.Inputs[0].UxOut)
+	assert.Equal(t, uint64(5e6), ut.Inputs[0].UxOut.Coins)
+
+	// the cosigners sign the copies in parallel, bob by the external signer
+	alice := ut.copy()
+	_, err = alice.Sign(*wlts[0])
+	require.NoError(t, err)
+
+	bob := ut.copy()
+	n, err := bob.SignWithSigner(wallet.NewKeySigner(wlts[1]), wlts[1].GetAddresses())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.False(t, alice.Complete())
+	assert.False(t, bob.Complete())
+
+	combined, err := CombineUnsignedTransactions(*ut, alice, bob)
+	require.NoError(t, err)
+	assert.True(t, combined.Complete())
+	assert.False(t, ut.Complete())
+
+	signed, err := combined.Finalize()
+	require.NoError(t, err)
+	require.NoError(t, signed.Verify())
+	require.NoError(t, signed.VerifyInput(uxs))
+
+	// the signature of a wrong key is rejected
+	forged := bob.copy()
+	forged.Inputs[1].Sig = alice.Inputs[0].Multisig.Sigs[keys[0].Hex()]
+	_, err = CombineUnsignedTransactions(alice, forged)
+	assert.Error(t, err)
+
+	// the copies must be of the same txn
+	other := ut.copy()
+	other.Hex = ut.Hex[:len(ut.Hex)-2] + "ff"
+	_, err = CombineUnsignedTransactions(alice, other)
+	assert.Error(t, err)
+
+	// the uxout must match the input hash
+	tampered := ut.copy()
+	tampered.Inputs[1].UxOut = &UnsignedUxOut{}
+	*tampered.Inputs[1].UxOut = *ut.Inputs[1].UxOut
+	tampered.Inputs[1].UxOut.Hours = 1000
+	_, err = tampered.Transaction()
+	assert.Error(t, err)
+}
@@ -0,0 +1,287 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// maxVerifyErrors is the max number of problems recorded in the verify report, the
+// verification goes on after the limit is reached, but the problems are only counted.
+const maxVerifyErrors = 100
+
+// BlockVerifyError is a problem found in a block when verifying the blockchain
+type BlockVerifyError struct {
+	Seq   uint64 `json:"seq"`
+	Hash  string `json:"hash"`
+	TxID  string `json:"txid,omitempty"`
+	Error string `json:"error"`
+}
+
+// VerifyReport is the result of verifying the blockchain from genesis
+type VerifyReport struct {
+	HeadSeq uint64 `json:"head_seq"`
+	// the bodies of the blocks till the pruned seq are discarded, only their headers and
+	// signatures are verified, and the unspent outputs can't be replayed
+	PrunedSeq uint64 `json:"pruned_seq"`
+	Blocks    uint64 `json:"blocks"`
+	Txns      uint64 `json:"txns"`
+	// number of the unspent outputs after replaying the blocks
+	Unspents uint64 `json:"unspents"`
+	// unspent outputs created by replaying but not in the db, and the ones only in the db
+	MissingUnspents []string `json:"missing_unspents"`
+	ExtraUnspents   []string `json:"extra_unspents"`
+	UxHashMatch     bool     `json:"ux_hash_match"`
+	// total number of problems, only the first maxVerifyErrors are kept in Errors
+	ErrorCount int                `json:"error_count"`
+	Errors     []BlockVerifyError `json:"errors"`
+	Elapsed    string             `json:"elapsed"`
+}
+
+// OK returns whether no problem is found
+func (vr VerifyReport) OK() bool {
+	return vr.ErrorCount == 0 &&
+		len(vr.MissingUnspents) == 0 &&
+		len(vr.ExtraUnspents) == 0 &&
+		(vr.UxHashMatch || vr.PrunedSeq > 0)
+}
+
+func (vr *VerifyReport) addError(b *coin.Block, txid string, err error) {
+	vr.ErrorCount++
+	if len(vr.Errors) >= maxVerifyErrors {
+		return
+	}
+
+	vr.Errors = append(vr.Errors, BlockVerifyError{
+		Seq:   b.Seq(),
+		Hash:  b.HashHeader().Hex(),
+		TxID:  txid,
+		Error: err.Error(),
+	})
+}
+
+// VerifyBlockchain re-validates the signature, header and transactions of every block from
+// genesis, and replays the blocks to check the unspent outputs in the db. progress is called
+// after each block is verified, can be nil. The blocks are verified even if the earlier
+// ones are invalid, the returned error is only for the failures of reading the db. The
+// unspent outputs are not checked if any block is pruned.
+func (vs *Visor) VerifyBlockchain(progress func(seq uint64)) (*VerifyReport, error) {
+	start := time.Now()
+	report := &VerifyReport{}
+
+	head := vs.Blockchain.Head()
+	if head == nil {
+		return nil, errors.New("No block in the chain")
+	}
+	report.HeadSeq = head.Seq()
+	report.PrunedSeq = vs.PrunedSeq()
+
+	cr := newChainReplay()
+	for seq := uint64(0); seq <= head.Seq(); seq++ {
+		b := vs.GetBlockBySeq(seq)
+		if b == nil {
+			return nil, fmt.Errorf("found no block in seq %v", seq)
+		}
+
+		sig, err := vs.blockSigs.Get(b.HashHeader())
+		if err == nil {
+			err = cipher.VerifySignature(vs.Config.BlockchainPubkey, sig, b.HashHeader())
+		}
+		if err != nil {
+			report.addError(b, "", fmt.Errorf("invalid block signature: %v", err))
+		}
+
+		cr.verifyBlock(b, report)
+		report.Blocks++
+		report.Txns += uint64(len(b.Body.Transactions))
+
+		if progress != nil {
+			progress(seq)
+		}
+	}
+
+	if !cr.pruned {
+		uxs, err := vs.Blockchain.Unspent().GetAll()
+		if err != nil {
+			return nil, err
+		}
+
+		report.Unspents = uint64(len(cr.unspents))
+		report.MissingUnspents, report.ExtraUnspents = cr.diffUnspents(uxs)
+		report.UxHashMatch = cr.uxHash == vs.Blockchain.Unspent().GetUxHash()
+	}
+	report.Elapsed = time.Since(start).String()
+	return report, nil
+}
+
+// chainReplay applies the blocks from genesis in memory, keeps the unspent outputs and
+// their xor hash in the same way as the unspent pool.
+type chainReplay struct {
+	unspents map[cipher.SHA256]coin.UxOut
+	uxHash   cipher.SHA256
+	prev     *coin.Block
+	// a pruned block is replayed, the unspent outputs are unknown from then on
+	pruned bool
+}
+
+func newChainReplay() *chainReplay {
+	return &chainReplay{
+		unspents: make(map[cipher.SHA256]coin.UxOut),
+	}
+}
+
+// verifyBlock checks the block against the replayed state and applies it, the problems are
+// added to the report. The block is applied even if it's invalid, so that the following
+// blocks can still be checked. Only the header link of a pruned block is checked, the
+// txns of the blocks after it are checked without their inputs.
+func (cr *chainReplay) verifyBlock(b *coin.Block, report *VerifyReport) {
+	if blockPruned(b) {
+		cr.pruned = true
+	} else if b.HashBody() != b.Head.BodyHash {
+		report.addError(b, "", errors.New("Computed body hash does not match"))
+	}
+
+	if !cr.pruned && b.Head.UxHash != cr.uxHash {
+		report.addError(b, "", errors.New("UxHash does not match"))
+	}
+
+	if cr.prev != nil {
+		if err := verifyHeaderLink(cr.prev, b); err != nil {
+			report.addError(b, "", err)
+		}
+	}
+
+	if cr.pruned {
+		for _, txn := range b.Body.Transactions {
+			if err := txn.Verify(); err != nil {
+				report.addError(b, txn.Hash().Hex(), err)
+			}
+		}
+		cr.prev = b
+		return
+	}
+
+	var fee uint64
+	for _, txn := range b.Body.Transactions {
+		// the genesis transaction has no input
+		if cr.prev != nil {
+			txnFee, err := cr.verifyTxn(b, txn)
+			if err != nil {
+				report.addError(b, txn.Hash().Hex(), err)
+			}
+			fee += txnFee
+		}
+
+		cr.applyTxn(b, txn)
+	}
+
+	if cr.prev != nil && fee != b.Head.Fee {
+		report.addError(b, "", fmt.Errorf("block fee %v does not match the txn fees %v", b.Head.Fee, fee))
+	}
+
+	cr.prev = b
+}
+
+// verifyHeaderLink checks that b follows prev
+func verifyHeaderLink(prev, b *coin.Block) error {
+	if b.Seq() != prev.Seq()+1 {
+		return errors.New("BkSeq invalid")
+	}
+
+	if b.Time() <= prev.Time() {
+		return errors.New("Block time must be > previous block time")
+	}
+
+	if b.Head.PrevHash != prev.HashHeader() {
+		return errors.New("PrevHash does not match previous block")
+	}
+	return nil
+}
+
+// verifyTxn checks the txn as Blockchain.VerifyTransaction does, against the replayed
+// unspent outputs. Returns the fee of the txn.
+func (cr *chainReplay) verifyTxn(b *coin.Block, txn coin.Transaction) (uint64, error) {
+	if err := txn.Verify(); err != nil {
+		return 0, err
+	}
+
+	uxIn := make(coin.UxArray, 0, len(txn.In))
+	for _, in := range txn.In {
+		ux, ok := cr.unspents[in]
+		if !ok {
+			return 0, fmt.Errorf("input %v does not exist or is spent", in.Hex())
+		}
+		uxIn = append(uxIn, ux)
+	}
+
+	if err := txn.VerifyInput(uxIn); err != nil {
+		return 0, err
+	}
+
+	uxOut := coin.CreateUnspents(b.Head, txn)
+	if uxOut.HasDupes() {
+		return 0, errors.New("Duplicate unspent outputs in transaction")
+	}
+
+	for i := range uxOut {
+		if _, ok := cr.unspents[uxOut[i].Hash()]; ok {
+			return 0, errors.New("New unspent collides with existing unspent")
+		}
+	}
+
+	headTime := cr.prev.Time()
+	if err := coin.VerifyTransactionSpending(headTime, uxIn, uxOut); err != nil {
+		return 0, err
+	}
+
+	var inHours uint64
+	for _, ux := range uxIn {
+		inHours += ux.CoinHours(headTime)
+	}
+	return inHours - txn.OutputHours(), nil
+}
+
+// applyTxn removes the spent outputs of txn, and adds the created ones
+func (cr *chainReplay) applyTxn(b *coin.Block, txn coin.Transaction) {
+	for _, in := range txn.In {
+		ux, ok := cr.unspents[in]
+		if !ok {
+			continue
+		}
+		delete(cr.unspents, in)
+		cr.uxHash = cr.uxHash.Xor(ux.SnapshotHash())
+	}
+
+	for _, ux := range coin.CreateUnspents(b.Head, txn) {
+		h := ux.Hash()
+		if _, ok := cr.unspents[h]; ok {
+			continue
+		}
+		cr.unspents[h] = ux
+		cr.uxHash = cr.uxHash.Xor(ux.SnapshotHash())
+	}
+}
+
+// diffUnspents compares the replayed unspent outputs with uxs, returns the hashes of the
+// ones missing in uxs, and the ones only in uxs.
+func (cr *chainReplay) diffUnspents(uxs coin.UxArray) ([]string, []string) {
+	var missing, extra []string
+	seen := make(map[cipher.SHA256]struct{}, len(uxs))
+	for i := range uxs {
+		h := uxs[i].Hash()
+		seen[h] = struct{}{}
+		if _, ok := cr.unspents[h]; !ok {
+			extra = append(extra, h.Hex())
+		}
+	}
+
+	for h := range cr.unspents {
+		if _, ok := seen[h]; !ok {
+			missing = append(missing, h.Hex())
+		}
+	}
+	return missing, extra
+}
@@ -0,0 +1,116 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestChainReplay(t *testing.T) {
+	pub, sec := cipher.GenerateKeyPair()
+	addr := cipher.AddressFromPubKey(pub)
+
+	gtxn := coin.Transaction{}
+	gtxn.PushOutput(addr, 100e6, 1000)
+	gb := coin.Block{
+		Head: coin.BlockHeader{Time: 100},
+		Body: coin.BlockBody{Transactions: coin.Transactions{gtxn}},
+	}
+	gb.Head.BodyHash = gb.HashBody()
+	genUx := coin.CreateUnspents(gb.Head, gtxn)[0]
+
+	// spends the genesis output, 100 coin hours are burned as fee
+	txn := coin.Transaction{}
+	txn.PushInput(genUx.Hash())
+	txn.PushOutput(addr, 60e6, 500)
+	txn.PushOutput(addr, 40e6, 400)
+	txn.SignInputs([]cipher.SecKey{sec})
+	txn.UpdateHeader()
+
+	newBlock := func(prev *coin.Block, uxHash cipher.SHA256, fee uint64) coin.Block {
+		b := coin.Block{
+			Head: coin.BlockHeader{
+				BkSeq:    prev.Seq() + 1,
+				Time:     prev.Time() + 100,
+				PrevHash: prev.HashHeader(),
+				UxHash:   uxHash,
+				Fee:      fee,
+			},
+			Body: coin.BlockBody{Transactions: coin.Transactions{txn}},
+		}
+		b.Head.BodyHash = b.HashBody()
+		return b
+	}
+
+	replayGenesis := func() (*chainReplay, *VerifyReport) {
+		cr := newChainReplay()
+		report := &VerifyReport{}
+		cr.verifyBlock(&gb, report)
+		assert.Equal(t, 0, report.ErrorCount)
+		assert.Len(t, cr.unspents, 1)
+		return cr, report
+	}
+
+	t.Run("valid", func(t *testing.T) {
+		cr, report := replayGenesis()
+		b := newBlock(&gb, cr.uxHash, 100)
+		cr.verifyBlock(&b, report)
+		assert.Equal(t, 0, report.ErrorCount)
+		assert.Len(t, cr.unspents, 2)
+
+		uxs := coin.CreateUnspents(b.Head, txn)
+		missing, extra := cr.diffUnspents(uxs)
+		assert.Empty(t, missing)
+		assert.Empty(t, extra)
+
+		missing, extra = cr.diffUnspents(coin.UxArray{uxs[0], genUx})
+		assert.Equal(t, []string{uxs[1].Hash().Hex()}, missing)
+		assert.Equal(t, []string{genUx.Hash().Hex()}, extra)
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		cr, report := replayGenesis()
+		b := newBlock(&gb, randSHA256(), 99)
+		cr.verifyBlock(&b, report)
+		assert.Equal(t, 2, report.ErrorCount)
+		assert.Equal(t, "UxHash does not match", report.Errors[0].Error)
+		assert.Equal(t, "block fee 99 does not match the txn fees 100", report.Errors[1].Error)
+
+		// the block is still applied
+		assert.Len(t, cr.unspents, 2)
+	})
+
+	t.Run("pruned", func(t *testing.T) {
+		cr, report := replayGenesis()
+		b1 := newBlock(&gb, cr.uxHash, 100)
+		pruned := coin.Block{Head: b1.Head}
+		cr.verifyBlock(&pruned, report)
+
+		// the inputs of the txns after the pruned block are unknown, only the header
+		// link and the body hash are checked
+		b2 := newBlock(&pruned, randSHA256(), 0)
+		cr.verifyBlock(&b2, report)
+		assert.Equal(t, 0, report.ErrorCount)
+
+		b3 := newBlock(&b2, randSHA256(), 0)
+		b3.Head.PrevHash = randSHA256()
+		cr.verifyBlock(&b3, report)
+		assert.Equal(t, 1, report.ErrorCount)
+		assert.Equal(t, "PrevHash does not match previous block", report.Errors[0].Error)
+	})
+
+	t.Run("double spend", func(t *testing.T) {
+		cr, report := replayGenesis()
+		b1 := newBlock(&gb, cr.uxHash, 100)
+		cr.verifyBlock(&b1, report)
+
+		b2 := newBlock(&b1, cr.uxHash, 0)
+		cr.verifyBlock(&b2, report)
+		assert.Equal(t, 1, report.ErrorCount)
+		assert.Equal(t, uint64(2), report.Errors[0].Seq)
+		assert.Equal(t, txn.Hash().Hex(), report.Errors[0].TxID)
+	})
+}
@@ -0,0 +1,1352 @@
+package visor
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/util/utc"
+	"github.com/skycoin/skycoin/src/visor/blockdb"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+	"github.com/skycoin/skycoin/src/wallet"
+
+	"github.com/skycoin/skycoin/src/util/logging"
+)
+
+var (
+	logger = logging.MustGetLogger("visor")
+
+	// ErrReadOnly is returned if the visor is in read-only mode and is asked to write the db
+	ErrReadOnly = errors.New("the node is in read-only mode")
+
+	// ErrTxnEvicted is returned if the injected txn is evicted at once, as its fee per byte is
+	// the lowest of the full unconfirmed pool
+	ErrTxnEvicted = errors.New("transaction fee is too low for the full unconfirmed pool, evicted")
+)
+
+// MaxBlocksPageSize is the maximum number of blocks returned in one page
+const MaxBlocksPageSize = 100
+
+// Config configuration parameters for the Visor
+type Config struct {
+	// Is this the master blockchain
+	IsMaster bool
+
+	//WalletDirectory string //move out
+
+	//Public key of blockchain authority
+	BlockchainPubkey cipher.PubKey
+
+	//Secret key of blockchain authority (if master)
+	BlockchainSeckey cipher.SecKey
+
+	// How often new blocks are created by the master, in seconds
+	BlockCreationInterval uint64
+	// How often an unconfirmed txn is checked against the blockchain
+	UnconfirmedCheckInterval time.Duration
+	// How long we'll hold onto an unconfirmed txn
+	UnconfirmedMaxAge time.Duration
+	// Maximum number of unconfirmed txns, the ones of lowest fee per byte are evicted when exceeded
+	UnconfirmedMaxTxns int
+	// Maximum total size of the unconfirmed txns, in bytes
+	UnconfirmedMaxBytes int
+	// Maximum number of txns that spend unknown outputs, held until their parents are confirmed
+	OrphanMaxTxns int
+	// How often to refresh the unconfirmed pool
+	UnconfirmedRefreshRate time.Duration
+	// How often to rebroadcast unconfirmed transactions
+	UnconfirmedResendPeriod time.Duration
+	// Maximum size of a block, in bytes.
+	MaxBlockSize int
+	// Divisor of coin hours required as fee. E.g. with hours=100 and factor=4,
+	// 25 additional hours are required as a fee.  A value of 0 disables
+	// the fee requirement.
+	//CoinHourBurnFactor uint64
+
+	// Where the blockchain is saved
+	BlockchainFile string
+	// Where the block signatures are saved
+	BlockSigsFile string
+
+	//address for genesis
+	GenesisAddress cipher.Address
+	// Genesis block sig
+	GenesisSignature cipher.Sig
+	// Genesis block timestamp
+	GenesisTimestamp uint64
+	// Number of coins in genesis block
+	GenesisCoinVolume uint64
+	// Known-good block hashes, the blocks conflicting with them are rejected
+	Checkpoints []Checkpoint
+	// Number of the recent blocks whose bodies are kept, the older blocks keep only the
+	// headers. 0 disables pruning, otherwise it must be at least MinPruneDepth.
+	PruneDepth uint64
+	// How often the db is compacted when the node starts, 0 disables compaction
+	DBCompactInterval time.Duration
+	// Serve the existing db without writing it, the txns and blocks are rejected
+	ReadOnly bool
+	// Function that creates a new Wallet
+	//WalletConstructor wallet.WalletConstructor
+	// Default type of wallet to create
+	//WalletTypeDefault wallet.WalletType
+	DBPath      string
+	Arbitrating bool // enable arbitrating
+}
+
+// NewVisorConfig put cap on block size, not on transactions/block
+//Skycoin transactions are smaller than Bitcoin transactions so skycoin has
+//a higher transactions per second for the same block size
+func NewVisorConfig() Config {
+	c := Config{
+		IsMaster: false,
+
+		//move wallet management out
+		//WalletDirectory: "",
+
+		//WalletConstructor: wallet.NewSimpleWallet,
+		//WalletTypeDefault: wallet.SimpleWalletType,
+
+		BlockchainPubkey: cipher.PubKey{},
+		BlockchainSeckey: cipher.SecKey{},
+
+		BlockCreationInterval: 10,
+		//BlockCreationForceInterval: 120, //create block if no block within this many seconds
+
+		UnconfirmedCheckInterval: time.Hour * 2,
+		UnconfirmedMaxAge:        time.Hour * 48,
+		UnconfirmedMaxTxns:       10000,
+		UnconfirmedMaxBytes:      1024 * 1024 * 32,
+		OrphanMaxTxns:            1000,
+		UnconfirmedRefreshRate:   time.Minute,
+		// UnconfirmedRefreshRate:   time.Minute * 30,
+		UnconfirmedResendPeriod: time.Minute,
+		MaxBlockSize:            1024 * 32,
+
+		GenesisAddress:    cipher.Address{},
+		GenesisSignature:  cipher.Sig{},
+		GenesisTimestamp:  0,
+		GenesisCoinVolume: 0, //100e12, 100e6 * 10e6
+	}
+
+	return c
+}
+
+// Visor manages the Blockchain as both a Master and a Normal
+type Visor struct {
+	Config Config
+	db     *bolt.DB
+	// Unconfirmed transactions, held for relay until we get block confirmation
+	Unconfirmed *UnconfirmedTxnPool
+	Blockchain  *Blockchain
+	blockSigs   *blockdb.BlockSigs
+	history     *historydb.HistoryDB
+	bcParser    *BlockchainParser
+	// status changes of the transactions seen since started
+	txnHistory *txnHistory
+	// seqs of the blocks in the chain by hash
+	blockIndex *blockIndex
+	// schedules the rebroadcasts of the locally created txns
+	rebroadcaster *rebroadcaster
+	// txns that spend unknown outputs
+	orphans *orphanPool
+	// signed blocks that don't extend the head
+	competing *competingBlocks
+	// recent reorganizations of the chain
+	reorgs []ReorgEvent
+	// known-good block hashes by seq
+	checkpoints checkpoints
+	// publishes the blockchain events to the subscribers
+	events *EventBus
+	// addresses whose activities are published
+	watched watchList
+}
+
+func walker(hps []coin.HashPair) cipher.SHA256 {
+	return hps[0].Hash
+}
+
+// open the blockdb.
+func openDB(dbFile string, readOnly bool) (*bolt.DB, func(), error) {
+	db, err := bolt.Open(dbFile, 0600, &bolt.Options{
+		Timeout:  500 * time.Millisecond,
+		ReadOnly: readOnly,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("Open boltdb failed, %v", err)
+	}
+
+	return db, func() {
+		db.Close()
+		logger.Info("DB closed")
+	}, nil
+}
+
+// openReadOnlyDB opens the existing db read-only, it must be in the latest schema
+func openReadOnlyDB(c Config) (*bolt.DB, func(), error) {
+	if c.IsMaster {
+		return nil, nil, errors.New("Cannot run in master: the node is in read-only mode")
+	}
+
+	if _, err := os.Stat(c.DBPath); err != nil {
+		return nil, nil, fmt.Errorf("Open read-only db failed, %v", err)
+	}
+
+	db, closeDB, err := openDB(c.DBPath, true)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := checkSchema(db, migrations); err != nil {
+		closeDB()
+		return nil, nil, err
+	}
+
+	logger.Info("Serving the db %s read-only", c.DBPath)
+	return db, closeDB, nil
+}
+
+// VsClose visor close function
+type VsClose func()
+
+// NewVisor Creates a normal Visor given a master's public key
+func NewVisor(c Config) (*Visor, VsClose, error) {
+	logger.Debug("Creating new visor")
+	// Make sure inputs are correct
+	if c.IsMaster {
+		logger.Debug("Visor is master")
+		if c.BlockchainPubkey != cipher.PubKeyFromSecKey(c.BlockchainSeckey) {
+			// logger.Panicf("Cannot run in master: invalid seckey for pubkey")
+			return nil, nil, errors.New("Cannot run in master: invalid seckey for pubkey")
+		}
+	}
+
+	if c.PruneDepth != 0 && c.PruneDepth < MinPruneDepth {
+		return nil, nil, fmt.Errorf("prune depth must be 0 or at least %d", MinPruneDepth)
+	}
+
+	var db *bolt.DB
+	var closeDB func()
+	var err error
+	if c.ReadOnly {
+		if db, closeDB, err = openReadOnlyDB(c); err != nil {
+			return nil, nil, err
+		}
+	} else {
+		if err := compactDBIfDue(c.DBPath, c.DBCompactInterval); err != nil {
+			return nil, nil, fmt.Errorf("Compact db failed: %v", err)
+		}
+
+		if db, closeDB, err = openDB(c.DBPath, false); err != nil {
+			return nil, nil, err
+		}
+
+		// upgrades the db created by the older versions
+		if _, err := migrateDB(db, migrations, false); err != nil {
+			closeDB()
+			return nil, nil, err
+		}
+	}
+
+	history, err := historydb.New(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// creates block signature bucket
+	sigs, err := blockdb.NewBlockSigs(db)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// creates blockchain instance
+	bc, err := NewBlockchain(db, walker, Arbitrating(c.Arbitrating))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// creates blockchain parser instance
+	// var verifyOnce sync.Once
+	bp := NewBlockchainParser(history, bc, ReadOnlyParser(c.ReadOnly))
+
+	bc.BindListener(bp.BlockListener)
+	bc.BindRollbackListener(bp.RollbackListener)
+
+	bi := newBlockIndex(bc)
+
+	events := NewEventBus()
+	bc.BindListener(func(b coin.Block) {
+		events.Publish(Event{Type: EventNewBlock, Block: &b})
+	})
+
+	// creates unconfirmed pool, the txns are evicted when the limits are exceeded
+	uncfm := NewUnconfirmedTxnPool(db, PoolLimits(UnconfirmedLimits{
+		MaxTxns:  c.UnconfirmedMaxTxns,
+		MaxBytes: c.UnconfirmedMaxBytes,
+		MaxAge:   c.UnconfirmedMaxAge,
+	}))
+
+	v := &Visor{
+		Config:      c,
+		db:          db,
+		Blockchain:  bc,
+		blockSigs:   sigs,
+		Unconfirmed: uncfm,
+		history:     history,
+		bcParser:    bp,
+		txnHistory:  newTxnHistory(maxTxnHistory),
+		blockIndex:  bi,
+		// the rebroadcast backoff starts from the resend period
+		rebroadcaster: newRebroadcaster(c.UnconfirmedResendPeriod, maxRebroadcastDelay, c.UnconfirmedMaxAge),
+		orphans:       newOrphanPool(c.OrphanMaxTxns),
+		competing:     newCompetingBlocks(maxCompetingBlocks),
+		checkpoints:   newCheckpoints(c.Checkpoints),
+		events:        events,
+		watched:       make(watchList),
+	}
+
+	return v, func() {
+		v.bcParser.Stop()
+		closeDB()
+	}, nil
+}
+
+// Run starts the visor process
+func (vs *Visor) Run() error {
+	if vs.Blockchain.GetGenesisBlock() == nil {
+		if vs.Config.ReadOnly {
+			return ErrNoGenesisBlock
+		}
+
+		vs.GenesisPreconditions()
+		b, err := vs.Blockchain.CreateGenesisBlock(
+			vs.Config.GenesisAddress,
+			vs.Config.GenesisCoinVolume,
+			vs.Config.GenesisTimestamp)
+		if err != nil {
+			return err
+		}
+
+		logger.Debug("Create genesis block")
+
+		// record the signature of genesis block
+		if vs.Config.IsMaster {
+			sb := vs.SignBlock(b)
+			if err := vs.blockSigs.Add(&sb); err != nil {
+				return err
+			}
+
+			logger.Info("Genesis block signature=%s", sb.Sig.Hex())
+		} else {
+			if err := vs.blockSigs.Add(&coin.SignedBlock{
+				Block: b,
+				Sig:   vs.Config.GenesisSignature,
+			}); err != nil {
+				return err
+			}
+		}
+	}
+
+	cpSeq, err := vs.verifyCheckpoints()
+	if err != nil {
+		return err
+	}
+
+	if !vs.Config.ReadOnly {
+		if err := vs.pruneBlocks(); err != nil {
+			return err
+		}
+	}
+
+	// the signatures verified before the node restarts are not verified again
+	start := cpSeq
+	if seq, ok := vs.Blockchain.chain.VerifiedSigSeq(); ok && seq+1 > start {
+		start = seq + 1
+	}
+
+	errC := make(chan error, 1)
+	go func() {
+		logger.Info("Verify signature from block %d...", start)
+		headSeq := vs.Blockchain.headSeq()
+		if err := vs.Blockchain.VerifySigsFrom(vs.Config.BlockchainPubkey, vs.blockSigs, start); err != nil {
+			errC <- fmt.Errorf("Invalid block signatures: %v", err)
+			return
+		}
+		logger.Info("Signature verify success")
+
+		if headSeq >= 0 && !vs.Config.ReadOnly {
+			if err := vs.Blockchain.chain.SetVerifiedSigSeq(uint64(headSeq)); err != nil {
+				logger.Error("Save verified signature seq failed: %v", err)
+			}
+		}
+	}()
+
+	go func() {
+		errC <- vs.bcParser.Run()
+	}()
+
+	vs.updateMetrics()
+
+	return <-errC
+}
+
+// GenesisPreconditions panics if conditions for genesis block are not met
+func (vs *Visor) GenesisPreconditions() {
+	//if seckey is set
+	if vs.Config.BlockchainSeckey != (cipher.SecKey{}) {
+		if vs.Config.BlockchainPubkey != cipher.PubKeyFromSecKey(vs.Config.BlockchainSeckey) {
+			logger.Panicf("Cannot create genesis block. Invalid secret key for pubkey")
+		}
+	}
+}
+
+// RefreshUnconfirmed checks unconfirmed txns against the blockchain and returns
+// all transaction that turn to valid, the expired txns are evicted.
+func (vs *Visor) RefreshUnconfirmed() []cipher.SHA256 {
+	if vs.Config.ReadOnly {
+		return nil
+	}
+
+	vs.evictUnconfirmed()
+	return vs.Unconfirmed.Refresh(vs.Blockchain)
+}
+
+// evictUnconfirmed evicts the unconfirmed txns that are expired or exceed the pool limits,
+// returns the hashes of the evicted txns
+func (vs *Visor) evictUnconfirmed() map[cipher.SHA256]struct{} {
+	expired, overflowed := vs.Unconfirmed.Evict(vs.Blockchain)
+	evicted := make(map[cipher.SHA256]struct{}, len(expired)+len(overflowed))
+	for _, h := range expired {
+		vs.droppedUnconfirmed(h, DropExpired)
+		evicted[h] = struct{}{}
+	}
+	for _, h := range overflowed {
+		vs.droppedUnconfirmed(h, DropOverflowed)
+		evicted[h] = struct{}{}
+	}
+	return evicted
+}
+
+// droppedUnconfirmed records the txn evicted from the unconfirmed pool for the reason
+func (vs *Visor) droppedUnconfirmed(h cipher.SHA256, reason string) {
+	logger.Debug("Evicted unconfirmed txn %s, %s", h.Hex(), reason)
+	vs.txnHistory.add(h, TxnStatusDropped)
+	vs.rebroadcaster.remove(h)
+	txnsDroppedCounter.With(reason).Inc()
+	unconfirmedTxnsGauge.Set(float64(vs.Unconfirmed.Len()))
+	vs.events.Publish(Event{Type: EventTxnDropped, TxID: h, Reason: reason})
+}
+
+// CreateBlock creates a SignedBlock from pending transactions
+func (vs *Visor) CreateBlock(when uint64) (coin.SignedBlock, error) {
+	var sb coin.SignedBlock
+	if !vs.Config.IsMaster {
+		logger.Panic("Only master chain can create blocks")
+	}
+	if vs.Unconfirmed.Txns.len() == 0 {
+		return sb, errors.New("No transactions")
+	}
+	txns, _ := vs.prioritizeTxns()
+	b, err := vs.Blockchain.NewBlockFromTransactions(txns, when)
+	if err != nil {
+		return sb, err
+	}
+	return vs.SignBlock(*b), nil
+}
+
+// prioritizeTxns sorts the unconfirmed txns by fee per kB, returns the txns that will be
+// packed into the next block and all the sorted txns. The txns whose fee can't be calculated
+// are excluded.
+func (vs *Visor) prioritizeTxns() (coin.Transactions, coin.Transactions) {
+	sorted := coin.SortTransactions(vs.Unconfirmed.RawTxns(), vs.Blockchain.TransactionFee)
+	return fillBlock(sorted, vs.Config.MaxBlockSize), sorted
+}
+
+// fillBlock picks the txns in order until the block is full, the txns that don't fit in
+// the remaining space are skipped, so that the smaller ones after them can still be packed.
+func fillBlock(txns coin.Transactions, maxSize int) coin.Transactions {
+	var (
+		picked coin.Transactions
+		total  int
+	)
+	for i := range txns {
+		size := txns[i].Size()
+		if total+size > maxSize {
+			continue
+		}
+		picked = append(picked, txns[i])
+		total += size
+	}
+	return picked
+}
+
+// CreateAndExecuteBlock creates a SignedBlock from pending transactions and executes it
+func (vs *Visor) CreateAndExecuteBlock() (coin.SignedBlock, error) {
+	sb, err := vs.CreateBlock(uint64(utc.UnixNow()))
+	if err == nil {
+		return sb, vs.ExecuteSignedBlock(sb)
+	}
+
+	return sb, err
+}
+
+// ExecuteSignedBlock adds a block to the blockchain, or returns error.
+// Blocks must be executed in sequence, and be signed by the master server.
+// The block that doesn't extend the head is kept as competing block, and the chain is
+// reorganized when the competing blocks make a longer branch.
+func (vs *Visor) ExecuteSignedBlock(b coin.SignedBlock) error {
+	if vs.Config.ReadOnly {
+		return ErrReadOnly
+	}
+
+	if err := vs.checkpoints.check(b.Block); err != nil {
+		return err
+	}
+
+	// the signature is verified even if the block matches a checkpoint, as it's stored
+	// and served to the peers
+	if err := vs.verifySignedBlock(&b); err != nil {
+		return err
+	}
+
+	var err error
+	if vs.isCompeting(b.Block) {
+		err = vs.addCompetingBlock(b)
+	} else {
+		err = vs.executeSignedBlock(b)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// the outputs of the block may be the inputs of the orphan txns
+	vs.processOrphans()
+	return nil
+}
+
+// executeSignedBlock appends the block to the chain, and removes its txns from the
+// unconfirmed pool
+func (vs *Visor) executeSignedBlock(b coin.SignedBlock) error {
+	// TODO -- save them even if out of order, and execute later
+	// But make sure all prechecking as possible is done
+	// TODO -- check if bitcoin allows blocks to be receiving out of order
+	if err := vs.blockSigs.Add(&b); err != nil {
+		return err
+	}
+
+	// the spent outputs are resolved before being removed by the block
+	uxIns := vs.watchedInputs(b.Block.Body.Transactions)
+
+	if err := vs.Blockchain.ExecuteBlock(&b.Block); err != nil {
+		return err
+	}
+
+	// the signature is verified before the block is executed
+	if err := vs.Blockchain.chain.AdvanceVerifiedSigSeq(b.Block.Seq()); err != nil {
+		logger.Error("Save verified signature seq failed: %v", err)
+	}
+
+	if err := vs.pruneBlocks(); err != nil {
+		logger.Error("Prune blocks failed: %v", err)
+	}
+
+	// Remove the transactions in the Block from the unconfirmed pool
+	vs.Unconfirmed.RemoveTransactions(b.Block.Body.Transactions)
+	for i, txn := range b.Block.Body.Transactions {
+		h := txn.Hash()
+		vs.txnHistory.add(h, TxnStatusConfirmed)
+		vs.rebroadcaster.remove(h)
+		vs.events.Publish(Event{Type: EventTxnConfirmed, Block: &b.Block, TxID: h})
+		if uxIns != nil {
+			vs.publishActivities(txn, uxIns[i], b.Block.Head, true)
+		}
+	}
+
+	blocksExecutedCounter.Inc()
+	vs.updateMetrics()
+	return nil
+}
+
+// Returns an error if the cipher.Sig is not valid for the coin.Block
+func (vs *Visor) verifySignedBlock(b *coin.SignedBlock) error {
+	return cipher.VerifySignature(vs.Config.BlockchainPubkey, b.Sig, b.Block.HashHeader())
+}
+
+// SignBlock signs a block for master.  Will panic if anything is invalid
+func (vs *Visor) SignBlock(b coin.Block) coin.SignedBlock {
+	if !vs.Config.IsMaster {
+		logger.Panic("Only master chain can sign blocks")
+	}
+	sig := cipher.SignHash(b.HashHeader(), vs.Config.BlockchainSeckey)
+	sb := coin.SignedBlock{
+		Block: b,
+		Sig:   sig,
+	}
+	return sb
+}
+
+/*
+	Return Data
+*/
+
+// GetUnspentOutputs makes local copy and update when block header changes
+// update should lock
+// isolate effect of threading
+// call .Array() to get []UxOut array
+func (vs *Visor) GetUnspentOutputs() ([]coin.UxOut, error) {
+	return vs.Blockchain.Unspent().GetAll()
+}
+
+// GetUnspentOutputReadables returns readable unspent outputs
+func (vs *Visor) GetUnspentOutputReadables() ([]ReadableOutput, error) {
+	uxs, err := vs.GetUnspentOutputs()
+	if err != nil {
+		return []ReadableOutput{}, err
+	}
+
+	headTime := vs.Blockchain.Time()
+	rxReadables := make([]ReadableOutput, len(uxs))
+	for i, ux := range uxs {
+		rxReadables[i] = NewReadableOutput(headTime, ux)
+	}
+
+	return rxReadables, nil
+}
+
+// AllSpendsOutputs returns all spending outputs in unconfirmed tx pool
+func (vs *Visor) AllSpendsOutputs() ([]ReadableOutput, error) {
+	return vs.Unconfirmed.AllSpendsOutputs(vs.Blockchain.Unspent(), vs.Blockchain.Time())
+}
+
+// AllIncomingOutputs returns all predicted outputs that are in pending tx pool
+func (vs *Visor) AllIncomingOutputs() ([]ReadableOutput, error) {
+	return vs.Unconfirmed.AllIncomingOutputs(vs.Blockchain.Head().Head)
+}
+
+// GetSignedBlocksSince returns N signed blocks more recent than Seq. Does not return nil.
+// The bodies of the pruned blocks are discarded, no block is returned if the blocks after
+// seq are pruned, so that the peers don't take the headers or the blocks from the node.
+func (vs *Visor) GetSignedBlocksSince(seq, ct uint64) []coin.SignedBlock {
+	if seq < vs.PrunedSeq() {
+		return []coin.SignedBlock{}
+	}
+
+	avail := uint64(0)
+	headSeq := vs.Blockchain.Head().Seq()
+	if headSeq > seq {
+		avail = headSeq - seq
+	}
+	if avail < ct {
+		ct = avail
+	}
+	if ct == 0 {
+		return []coin.SignedBlock{}
+	}
+	blocks := make([]coin.SignedBlock, 0, ct)
+	for j := uint64(0); j < ct; j++ {
+		i := seq + 1 + j
+		b := vs.Blockchain.GetBlockInDepth(i)
+		if b == nil {
+			return []coin.SignedBlock{}
+		}
+		sig, err := vs.blockSigs.Get(b.HashHeader())
+		if err != nil {
+			return []coin.SignedBlock{}
+		}
+
+		blocks = append(blocks, coin.SignedBlock{
+			Block: *b,
+			Sig:   sig,
+		})
+	}
+	return blocks
+}
+
+// GetGenesisBlock returns the signed genesis block. Panics if signature or block not found
+func (vs *Visor) GetGenesisBlock() coin.SignedBlock {
+	b := vs.Blockchain.GetGenesisBlock()
+	if b == nil {
+		logger.Panic("No genesis signature")
+	}
+
+	sig, err := vs.blockSigs.Get(b.HashHeader())
+	if err != nil {
+		logger.Panic(err)
+	}
+
+	return coin.SignedBlock{
+		Sig:   sig,
+		Block: *b,
+	}
+}
+
+// HeadBkSeq returns the highest BkSeq we know
+func (vs *Visor) HeadBkSeq() uint64 {
+	return vs.Blockchain.Head().Seq()
+}
+
+// GetBlockchainMetadata returns descriptive Blockchain information
+func (vs *Visor) GetBlockchainMetadata() BlockchainMetadata {
+	return NewBlockchainMetadata(vs)
+}
+
+// GetReadableBlock returns a readable copy of the block at seq. Returns error if seq out of range
+func (vs *Visor) GetReadableBlock(seq uint64) (ReadableBlock, error) {
+	b, err := vs.GetBlock(seq)
+	if err != nil {
+		return ReadableBlock{}, err
+	}
+
+	return NewReadableBlock(&b), nil
+}
+
+// GetReadableBlocks returns multiple blocks between start and end (not including end). Returns
+// empty slice if unable to fulfill request, it does not return nil.
+func (vs *Visor) GetReadableBlocks(start, end uint64) []ReadableBlock {
+	return NewReadableBlocks(vs.GetBlocks(start, end))
+}
+
+// GetReadableBlocksInRange returns a page of readable blocks whose seq are in the range
+// of start and end, at most pageSize blocks will be returned, the rest can be fetched by
+// using the returned cursor as the next start.
+func (vs *Visor) GetReadableBlocksInRange(start, end uint64, pageSize int) (*ReadableBlocksPage, error) {
+	if pageSize <= 0 {
+		return nil, errors.New("page size must be positive")
+	}
+
+	if pageSize > MaxBlocksPageSize {
+		pageSize = MaxBlocksPageSize
+	}
+
+	if start > end {
+		return nil, errors.New("start must not be greater than end")
+	}
+
+	headSeq := vs.HeadBkSeq()
+	if end > headSeq {
+		end = headSeq
+	}
+
+	page := &ReadableBlocksPage{Blocks: []ReadableBlock{}}
+	if start > end {
+		return page, nil
+	}
+
+	page.Total = end - start + 1
+	pageEnd := end
+	if page.Total > uint64(pageSize) {
+		pageEnd = start + uint64(pageSize) - 1
+		page.HasMore = true
+		page.NextCursor = pageEnd + 1
+	}
+
+	page.Blocks = NewReadableBlocks(vs.GetBlocks(start, pageEnd))
+	return page, nil
+}
+
+// GetReadableBlockVerbose returns readable block whose transaction inputs are resolved
+// from the history db.
+func (vs *Visor) GetReadableBlockVerbose(b *coin.Block) (ReadableBlockVerbose, error) {
+	var prevTime uint64
+	if b.Seq() > 0 {
+		pb := vs.GetBlockBySeq(b.Seq() - 1)
+		if pb == nil {
+			return ReadableBlockVerbose{}, fmt.Errorf("found no block in seq %v", b.Seq()-1)
+		}
+		prevTime = pb.Time()
+	}
+
+	inputs := make([][]*historydb.UxOut, len(b.Body.Transactions))
+	for i, txn := range b.Body.Transactions {
+		uxs := make([]*historydb.UxOut, len(txn.In))
+		for j, in := range txn.In {
+			ux, err := vs.history.GetUxout(in)
+			if err != nil {
+				return ReadableBlockVerbose{}, err
+			}
+
+			if ux == nil {
+				return ReadableBlockVerbose{}, fmt.Errorf("found no uxout of id %v", in.Hex())
+			}
+			uxs[j] = ux
+		}
+		inputs[i] = uxs
+	}
+
+	return NewReadableBlockVerbose(b, prevTime, inputs)
+}
+
+// GetBlock returns a copy of the block at seq. Returns error if seq out of range
+// Move to blockdb
+func (vs *Visor) GetBlock(seq uint64) (coin.Block, error) {
+	var b coin.Block
+	if seq > vs.Blockchain.Head().Head.BkSeq {
+		return b, errors.New("Block seq out of range")
+	}
+
+	return *vs.Blockchain.GetBlockInDepth(seq), nil
+}
+
+// GetBlocks returns multiple blocks between start and end (not including end). Returns
+// empty slice if unable to fulfill request, it does not return nil.
+// move to blockdb
+func (vs *Visor) GetBlocks(start, end uint64) []coin.Block {
+	return vs.Blockchain.GetBlocks(start, end)
+}
+
+// InjectTxn records a coin.Transaction to the UnconfirmedTxnPool if the txn is not
+// already in the blockchain
+// TODO
+// - rename InjectTransaction
+// Refactor
+// Why do does this return both error and bool
+func (vs *Visor) InjectTxn(txn coin.Transaction) (bool, error) {
+	if vs.Config.ReadOnly {
+		return false, ErrReadOnly
+	}
+
+	//addrs := self.Wallets.GetAddressSet()
+	if !vs.Unconfirmed.Txns.isExist(txn.Hash()) {
+		ok, err := vs.checkInputs(txn)
+		if err != nil {
+			return false, err
+		}
+
+		if !ok {
+			if vs.orphans.add(txn) {
+				vs.txnHistory.add(txn.Hash(), TxnStatusOrphan)
+			}
+			return false, ErrOrphanTxn
+		}
+	}
+
+	known, err := vs.Unconfirmed.InjectTxn(vs.Blockchain, txn)
+	if err == nil && !known {
+		var uxIn coin.UxArray
+		if uxIns := vs.watchedInputs(coin.Transactions{txn}); uxIns != nil {
+			uxIn = uxIns[0]
+		}
+		vs.addedUnconfirmed(txn, uxIn)
+
+		// the pool is only evicted when it's over the limits, the expired txns are evicted
+		// by RefreshUnconfirmed
+		if vs.Unconfirmed.OverLimits(vs.Blockchain) {
+			if _, ok := vs.evictUnconfirmed()[txn.Hash()]; ok {
+				return false, ErrTxnEvicted
+			}
+		}
+	}
+	return known, err
+}
+
+// addedUnconfirmed records the txn newly added to the unconfirmed pool, uxIn are the
+// outputs spent by the txn, which are only needed when any address is watched.
+func (vs *Visor) addedUnconfirmed(txn coin.Transaction, uxIn coin.UxArray) {
+	vs.txnHistory.add(txn.Hash(), TxnStatusUnconfirmed)
+	txnsInjectedCounter.Inc()
+	vs.updateMetrics()
+	vs.events.Publish(Event{Type: EventNewUnconfirmedTxn, TxID: txn.Hash()})
+	vs.publishActivities(txn, uxIn, vs.Blockchain.Head().Head, false)
+}
+
+// AddressesUsed checks whether each of the addresses appears in the blockchain or receives
+// coins in the unconfirmed txns, it's used to find the funded addresses of a recovered wallet.
+func (vs *Visor) AddressesUsed(addrs []cipher.Address) ([]bool, error) {
+	pending := make(map[cipher.Address]struct{})
+	for _, tx := range vs.Unconfirmed.GetTxns(All) {
+		for _, o := range tx.Txn.Out {
+			pending[o.Address] = struct{}{}
+		}
+	}
+
+	used := make([]bool, len(addrs))
+	for i, a := range addrs {
+		if _, ok := pending[a]; ok {
+			used[i] = true
+			continue
+		}
+
+		ok, err := vs.history.AddressUsed(a)
+		if err != nil {
+			return nil, err
+		}
+		used[i] = ok
+	}
+	return used, nil
+}
+
+// GetAddressTxns returns the Transactions whose unspents give coins to a cipher.Address.
+// This includes unconfirmed txns' predicted unspents.
+func (vs *Visor) GetAddressTxns(a cipher.Address) ([]Transaction, error) {
+	txs, err := vs.history.GetAddrTxns(a)
+	if err != nil {
+		return []Transaction{}, err
+	}
+
+	txns, err := vs.confirmedTransactions(txs)
+	if err != nil {
+		return []Transaction{}, err
+	}
+
+	// Look in the unconfirmed pool
+	return append(txns, vs.unconfirmedAddressTxns(a)...), nil
+}
+
+// confirmedTransactions returns the Transaction of the txns in the history db
+func (vs *Visor) confirmedTransactions(txs []historydb.Transaction) ([]Transaction, error) {
+	var txns []Transaction
+	mxSeq := vs.HeadBkSeq()
+	for _, tx := range txs {
+		bk := vs.GetBlockBySeq(tx.BlockSeq)
+		if bk == nil {
+			return nil, fmt.Errorf("No block exsit in depth:%d", tx.BlockSeq)
+		}
+
+		fee := vs.confirmedTxnFeeOrZero(&tx.Tx, tx.BlockSeq)
+
+		status, err := NewConfirmedTransactionStatusFromBlock(mxSeq, bk)
+		if err != nil {
+			return nil, err
+		}
+
+		txns = append(txns, Transaction{
+			Txn:    tx.Tx,
+			Status: status,
+			Time:   bk.Time(),
+			Fee:    fee,
+		})
+	}
+	return txns, nil
+}
+
+// unconfirmedAddressTxns returns the unconfirmed txns creating the outputs of the address
+func (vs *Visor) unconfirmedAddressTxns(a cipher.Address) []Transaction {
+	var txns []Transaction
+	uxs := vs.Unconfirmed.Unspent.getAllForAddress(a)
+	for _, ux := range uxs {
+		tx, ok := vs.Unconfirmed.Txns.get(ux.Body.SrcTransaction)
+		if !ok {
+			logger.Critical("Unconfirmed unspent missing unconfirmed txn")
+			continue
+		}
+		fee, _ := vs.txnFee(&tx.Txn, vs.Blockchain.Time())
+		txns = append(txns, Transaction{
+			Txn:    tx.Txn,
+			Status: NewUnconfirmedTransactionStatus(),
+			Time:   uint64(nanoToTime(tx.Received).Unix()),
+			Fee:    fee,
+		})
+	}
+	return txns
+}
+
+// GetTransaction returns a Transaction by hash.
+func (vs *Visor) GetTransaction(txHash cipher.SHA256) (*Transaction, error) {
+	// Look in the unconfirmed pool
+	tx, ok := vs.Unconfirmed.Txns.get(txHash)
+	if ok {
+		// the fee of invalid unconfirmed txn can't be computed, leave it zero.
+		fee, _ := vs.txnFee(&tx.Txn, vs.Blockchain.Time())
+		return &Transaction{
+			Txn:    tx.Txn,
+			Status: NewUnconfirmedTransactionStatus(),
+			Time:   uint64(nanoToTime(tx.Received).Unix()),
+			Fee:    fee,
+		}, nil
+	}
+
+	txn, err := vs.history.GetTransaction(txHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if txn == nil {
+		return nil, nil
+	}
+
+	headSeq := vs.GetHeadBlock().Seq()
+	b := vs.GetBlockBySeq(txn.BlockSeq)
+	if b == nil {
+		return nil, fmt.Errorf("found no block in seq %v", txn.BlockSeq)
+	}
+
+	fee := vs.confirmedTxnFeeOrZero(&txn.Tx, txn.BlockSeq)
+
+	status, err := NewConfirmedTransactionStatusFromBlock(headSeq, b)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Transaction{
+		Txn:    txn.Tx,
+		Status: status,
+		Time:   b.Time(),
+		Fee:    fee,
+	}, nil
+}
+
+// confirmedTxnFee computes the fee of transaction executed in block of given seq,
+// the input hours are calculated base on the previous block time, the same as
+// when the block was created.
+func (vs *Visor) confirmedTxnFee(txn *coin.Transaction, blockSeq uint64) (uint64, error) {
+	// genesis transaction has no inputs
+	if blockSeq == 0 {
+		return 0, nil
+	}
+
+	pb := vs.GetBlockBySeq(blockSeq - 1)
+	if pb == nil {
+		return 0, fmt.Errorf("found no block in seq %v", blockSeq-1)
+	}
+
+	return vs.txnFee(txn, pb.Time())
+}
+
+// confirmedTxnFeeOrZero returns the fee of the confirmed txn, it's 0 if the inputs can't
+// be found in history db, e.g. while the history is rebuilding, so the listings go on.
+func (vs *Visor) confirmedTxnFeeOrZero(txn *coin.Transaction, blockSeq uint64) uint64 {
+	fee, err := vs.confirmedTxnFee(txn, blockSeq)
+	if err != nil {
+		logger.Debug("Compute fee of txn %s failed: %v", txn.Hash().Hex(), err)
+		return 0
+	}
+	return fee
+}
+
+// txnFee computes the coin hour fee of transaction at given head time, the inputs
+// are resolved from history db, so that spent outputs can be found as well.
+func (vs *Visor) txnFee(txn *coin.Transaction, headTime uint64) (uint64, error) {
+	var inHours uint64
+	for _, in := range txn.In {
+		ux, err := vs.history.GetUxout(in)
+		if err != nil {
+			return 0, err
+		}
+
+		if ux == nil {
+			return 0, fmt.Errorf("found no uxout of id %v", in.Hex())
+		}
+
+		inHours += ux.Out.CoinHours(headTime)
+	}
+
+	outHours := txn.OutputHours()
+	if inHours < outHours {
+		return 0, errors.New("Insufficient coinhours for transaction outputs")
+	}
+	return inHours - outHours, nil
+}
+
+// AddressBalance computes the total balance for cipher.Addresses and their coin.UxOuts
+func (vs *Visor) AddressBalance(auxs coin.AddressUxOuts) (uint64, uint64) {
+	prevTime := vs.Blockchain.Time()
+	//b := wallet.NewBalance(0, 0)
+	var coins uint64
+	var hours uint64
+	for _, uxs := range auxs {
+		for _, ux := range uxs {
+			coins += ux.Body.Coins
+			hours += ux.CoinHours(prevTime)
+			// FIXME
+			//b = b.Add(wallet.NewBalance(ux.Body.Coins, ux.CoinHours(prevTime)))
+		}
+	}
+	return coins, hours
+}
+
+// GetBalances returns the confirmed and predicted balance of each address, the predicted
+// balance excludes the outputs that are spent by unconfirmed transactions. The result is
+// keyed by the base58 address.
+func (vs *Visor) GetBalances(addrs []cipher.Address) (map[string]wallet.BalancePair, error) {
+	unspent := vs.Blockchain.Unspent()
+	auxs := unspent.GetUnspentsOfAddrs(addrs)
+	puxs, err := vs.Unconfirmed.SpendsForAddresses(unspent, addrs)
+	if err != nil {
+		return nil, fmt.Errorf("get unconfirmed spends failed: %v", err)
+	}
+
+	predicted := auxs.Sub(puxs)
+	bals := make(map[string]wallet.BalancePair, len(addrs))
+	for _, a := range addrs {
+		coins1, hours1 := vs.AddressBalance(coin.AddressUxOuts{a: auxs[a]})
+		coins2, hours2 := vs.AddressBalance(coin.AddressUxOuts{a: predicted[a]})
+		bals[a.String()] = wallet.BalancePair{
+			Confirmed: wallet.NewBalance(coins1, hours1),
+			Predicted: wallet.NewBalance(coins2, hours2),
+		}
+	}
+
+	return bals, nil
+}
+
+// GetUnconfirmedTxns gets all confirmed transactions of specific addresses
+func (vs *Visor) GetUnconfirmedTxns(filter func(UnconfirmedTxn) bool) []UnconfirmedTxn {
+	return vs.Unconfirmed.GetTxns(filter)
+}
+
+// ToAddresses represents a filter that check if tx has output to the given addresses
+func ToAddresses(addresses []cipher.Address) func(UnconfirmedTxn) bool {
+	return func(tx UnconfirmedTxn) (isRelated bool) {
+		for _, out := range tx.Txn.Out {
+			for _, address := range addresses {
+				if out.Address == address {
+					isRelated = true
+					return
+				}
+			}
+		}
+		return
+	}
+}
+
+// GetAllUnconfirmedTxns returns all unconfirmed transactions
+func (vs *Visor) GetAllUnconfirmedTxns() []UnconfirmedTxn {
+	return vs.Unconfirmed.GetTxns(All)
+}
+
+// GetReadableUnconfirmedTxns returns all readable unconfirmed transactions with computed fee,
+// sorted by the received time, the earliest first.
+func (vs *Visor) GetReadableUnconfirmedTxns() []ReadableUnconfirmedTxn {
+	return vs.newReadableUnconfirmedTxns(vs.Unconfirmed.GetTxns(All))
+}
+
+// GetUnconfirmedTxnsForAddress returns the readable unconfirmed transactions that spend
+// outputs of the address or send coins to it, sorted by the received time.
+func (vs *Visor) GetUnconfirmedTxnsForAddress(addr cipher.Address) []ReadableUnconfirmedTxn {
+	txns := vs.Unconfirmed.GetTxns(RelatedToAddress(vs.Blockchain.Unspent(), addr))
+	return vs.newReadableUnconfirmedTxns(txns)
+}
+
+// RelatedToAddress represents a filter that check if tx spends the outputs of the address
+// or has output to the address, the inputs are resolved from the unspent pool.
+func RelatedToAddress(unspent *blockdb.UnspentPool, addr cipher.Address) func(UnconfirmedTxn) bool {
+	return func(tx UnconfirmedTxn) bool {
+		for _, out := range tx.Txn.Out {
+			if out.Address == addr {
+				return true
+			}
+		}
+
+		for _, in := range tx.Txn.In {
+			if ux, ok := unspent.Get(in); ok && ux.Body.Address == addr {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+func (vs *Visor) newReadableUnconfirmedTxns(txns []UnconfirmedTxn) []ReadableUnconfirmedTxn {
+	sort.Sort(byReceived(txns))
+
+	conflicts := conflictingTxns(vs.Unconfirmed.GetTxns(All))
+
+	next, sorted := vs.prioritizeTxns()
+	priorities := make(map[cipher.SHA256]int, len(sorted))
+	for i := range sorted {
+		priorities[sorted[i].Hash()] = i + 1
+	}
+
+	inNextBlock := make(map[cipher.SHA256]bool, len(next))
+	for i := range next {
+		inNextBlock[next[i].Hash()] = true
+	}
+
+	headTime := vs.Blockchain.Time()
+	now := utc.Now()
+	rtxns := make([]ReadableUnconfirmedTxn, len(txns))
+	for i := range txns {
+		h := txns[i].Hash()
+		rtxns[i] = NewReadableUnconfirmedTxn(&txns[i])
+		rtxns[i].Priority = priorities[h]
+		rtxns[i].InNextBlock = inNextBlock[h]
+		rtxns[i].TTL = -1
+		if ttl, ok := vs.Unconfirmed.TTL(txns[i].Received, now); ok {
+			rtxns[i].TTL = int64(ttl / time.Second)
+		}
+		if hashes, ok := conflicts[h]; ok {
+			rtxns[i].Conflicting = true
+			rtxns[i].ConflictsWith = hashes
+		}
+		// the fee of invalid txn can't be computed, leave it zero.
+		fee, err := vs.txnFee(&txns[i].Txn, headTime)
+		if err != nil {
+			logger.Debug("Compute fee of unconfirmed txn %s failed: %v", h.Hex(), err)
+			continue
+		}
+		rtxns[i].Fee = fee
+		rtxns[i].Txn.Fee = fee
+	}
+	return rtxns
+}
+
+// conflictingTxns finds the txns that spend the same outputs, returns the hashes of
+// the competing txns keyed by txn hash.
+func conflictingTxns(txns []UnconfirmedTxn) map[cipher.SHA256][]string {
+	spends := make(map[cipher.SHA256][]cipher.SHA256)
+	for _, tx := range txns {
+		txid := tx.Hash()
+		for _, in := range tx.Txn.In {
+			spends[in] = append(spends[in], txid)
+		}
+	}
+
+	conflicts := make(map[cipher.SHA256]map[cipher.SHA256]struct{})
+	for _, hashes := range spends {
+		if len(hashes) < 2 {
+			continue
+		}
+
+		for _, h := range hashes {
+			for _, other := range hashes {
+				if h == other {
+					continue
+				}
+				if conflicts[h] == nil {
+					conflicts[h] = make(map[cipher.SHA256]struct{})
+				}
+				conflicts[h][other] = struct{}{}
+			}
+		}
+	}
+
+	ret := make(map[cipher.SHA256][]string, len(conflicts))
+	for h, others := range conflicts {
+		hashes := make([]string, 0, len(others))
+		for other := range others {
+			hashes = append(hashes, other.Hex())
+		}
+		sort.Strings(hashes)
+		ret[h] = hashes
+	}
+	return ret
+}
+
+type byReceived []UnconfirmedTxn
+
+func (txs byReceived) Len() int           { return len(txs) }
+func (txs byReceived) Swap(i, j int)      { txs[i], txs[j] = txs[j], txs[i] }
+func (txs byReceived) Less(i, j int) bool { return txs[i].Received < txs[j].Received }
+
+// GetAllValidUnconfirmedTxHashes returns all valid unconfirmed transaction hashes
+func (vs *Visor) GetAllValidUnconfirmedTxHashes() []cipher.SHA256 {
+	return vs.Unconfirmed.GetTxHashes(IsValid)
+}
+
+// GetBlockByHash get block of specific hash header, return nil on not found.
+func (vs *Visor) GetBlockByHash(hash cipher.SHA256) *coin.Block {
+	seq, ok := vs.blockIndex.get(hash)
+	if !ok {
+		return nil
+	}
+	return vs.GetBlockBySeq(seq)
+}
+
+// GetBlockBySeq get block of speicific seq, return nil on not found.
+func (vs *Visor) GetBlockBySeq(seq uint64) *coin.Block {
+	return vs.Blockchain.GetBlockInDepth(seq)
+}
+
+// GetLastTxs returns last confirmed transactions, return nil if empty
+func (vs *Visor) GetLastTxs() ([]*Transaction, error) {
+	ltxs, err := vs.history.GetLastTxs()
+	if err != nil {
+		return nil, err
+	}
+
+	txs := make([]*Transaction, len(ltxs))
+	bh := vs.GetHeadBlock().Seq()
+	var b *coin.Block
+	for i, tx := range ltxs {
+		if b = vs.GetBlockBySeq(tx.BlockSeq); b == nil {
+			return nil, fmt.Errorf("found no block in seq %v", tx.BlockSeq)
+		}
+
+		fee := vs.confirmedTxnFeeOrZero(&tx.Tx, tx.BlockSeq)
+
+		status, err := NewConfirmedTransactionStatusFromBlock(bh, b)
+		if err != nil {
+			return nil, err
+		}
+
+		txs[i] = &Transaction{
+			Txn:    tx.Tx,
+			Status: status,
+			Time:   b.Time(),
+			Fee:    fee,
+		}
+	}
+	return txs, nil
+}
+
+// GetHeadBlock gets head block.
+func (vs Visor) GetHeadBlock() *coin.Block {
+	return vs.Blockchain.Head()
+}
+
+// GetUxOutByID gets UxOut by hash id.
+func (vs Visor) GetUxOutByID(id cipher.SHA256) (*historydb.UxOut, error) {
+	return vs.history.GetUxout(id)
+}
+
+// GetAddrUxOuts gets all the address affected UxOuts.
+func (vs Visor) GetAddrUxOuts(address cipher.Address) ([]*historydb.UxOut, error) {
+	return vs.history.GetAddrUxOuts(address)
+}
+
+// GetAddressSummary returns the aggregated activity of the address, it's updated when the
+// blocks are parsed by the history db. Returns nil if the address never appears.
+func (vs Visor) GetAddressSummary(addr cipher.Address) (*AddressSummary, error) {
+	s, err := vs.history.GetAddressSummary(addr)
+	if err != nil || s == nil {
+		return nil, err
+	}
+
+	first := vs.GetBlockBySeq(s.FirstSeen)
+	if first == nil {
+		return nil, fmt.Errorf("found no block in seq %v", s.FirstSeen)
+	}
+
+	last := vs.GetBlockBySeq(s.LastActive)
+	if last == nil {
+		return nil, fmt.Errorf("found no block in seq %v", s.LastActive)
+	}
+
+	as := NewAddressSummary(addr, *s, first.Time(), last.Time())
+	return &as, nil
+}
+
+// GetReadableOutputVerbose gets the verbose readable output of given uxid, returns nil if not found.
+func (vs Visor) GetReadableOutputVerbose(id cipher.SHA256) (*ReadableOutputVerbose, error) {
+	ux, err := vs.history.GetUxout(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if ux == nil {
+		return nil, nil
+	}
+
+	ro := NewReadableOutputVerbose(*ux)
+	return &ro, nil
+}
+
+// GetAddrReadableOutputsVerbose gets all the address affected outputs in verbose readable format.
+func (vs Visor) GetAddrReadableOutputsVerbose(address cipher.Address) ([]ReadableOutputVerbose, error) {
+	uxs, err := vs.history.GetAddrUxOuts(address)
+	if err != nil {
+		return nil, err
+	}
+
+	outs := make([]ReadableOutputVerbose, len(uxs))
+	for i, ux := range uxs {
+		outs[i] = NewReadableOutputVerbose(*ux)
+	}
+	return outs, nil
+}
@@ -1,5 +1,15 @@
 package visor
 
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
 // import (
 // 	"os"
 // 	"path/filepath"
@@ -1053,3 +1063,48 @@ package visor
 // 	we.Public = cipher.PubKey{}
 // 	assert.Panics(t, func() { CreateMasterWallet(we) })
 // }
+
+func TestConflictingTxns(t *testing.T) {
+	ux1, ux2, ux3 := randSHA256(), randSHA256(), randSHA256()
+	newTxn := func(ins ...cipher.SHA256) UnconfirmedTxn {
+		txn := coin.Transaction{In: ins}
+		txn.UpdateHeader()
+		return UnconfirmedTxn{Txn: txn}
+	}
+
+	a := newTxn(ux1)
+	b := newTxn(ux1, ux2)
+	c := newTxn(ux2)
+	d := newTxn(ux3)
+
+	conflicts := conflictingTxns([]UnconfirmedTxn{a, b, c, d})
+	assert.Len(t, conflicts, 3)
+	assert.Equal(t, []string{b.Hash().Hex()}, conflicts[a.Hash()])
+	expect := []string{a.Hash().Hex(), c.Hash().Hex()}
+	sort.Strings(expect)
+	assert.Equal(t, expect, conflicts[b.Hash()])
+	assert.Equal(t, []string{b.Hash().Hex()}, conflicts[c.Hash()])
+	_, ok := conflicts[d.Hash()]
+	assert.False(t, ok)
+}
+
+func TestFillBlock(t *testing.T) {
+	newTxn := func(n int) coin.Transaction {
+		ins := make([]cipher.SHA256, n)
+		for i := range ins {
+			ins[i] = randSHA256()
+		}
+		txn := coin.Transaction{In: ins}
+		txn.UpdateHeader()
+		return txn
+	}
+
+	small, large, medium := newTxn(1), newTxn(10), newTxn(3)
+	txns := coin.Transactions{small, large, medium}
+
+	// the large txn doesn't fit, the medium one after it is still packed
+	maxSize := small.Size() + medium.Size()
+	assert.Equal(t, coin.Transactions{small, medium}, fillBlock(txns, maxSize))
+	assert.Equal(t, txns, fillBlock(txns, maxSize+large.Size()))
+	assert.Empty(t, fillBlock(txns, small.Size()-1))
+}
@@ -0,0 +1,372 @@
+package visor
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// Directions of the wallet history entry
+const (
+	DirectionIn   = "in"
+	DirectionOut  = "out"
+	DirectionSelf = "self"
+)
+
+// walletHistoryCSVHeader is the header row of the wallet history CSV, each row is a transaction
+var walletHistoryCSVHeader = []string{
+	"block_seq",
+	"time",
+	"txid",
+	"confirmed",
+	"direction",
+	"coins",
+	"fee",
+	"balance",
+	"counterparties",
+}
+
+// WalletHistoryEntry is a transaction affecting the addresses of the wallet. Coins is the net
+// amount received if the direction is in, or the amount sent to the counterparties if out.
+// Balance is the running balance of the wallet after the transaction.
+type WalletHistoryEntry struct {
+	TxID           cipher.SHA256
+	Confirmed      bool
+	BlockSeq       uint64
+	Time           uint64
+	Direction      string
+	Coins          uint64
+	Fee            uint64
+	Balance        uint64
+	Counterparties []cipher.Address
+}
+
+// ReadableWalletHistoryEntry is the readable WalletHistoryEntry
+type ReadableWalletHistoryEntry struct {
+	TxID           string   `json:"txid"`
+	Confirmed      bool     `json:"confirmed"`
+	BlockSeq       *uint64  `json:"block_seq,omitempty"`
+	Time           uint64   `json:"time"`
+	Direction      string   `json:"direction"`
+	Coins          string   `json:"coins"`
+	Fee            uint64   `json:"fee"`
+	Balance        string   `json:"balance"`
+	Counterparties []string `json:"counterparties"`
+}
+
+// NewReadableWalletHistoryEntry creates the readable WalletHistoryEntry
+func NewReadableWalletHistoryEntry(e WalletHistoryEntry) ReadableWalletHistoryEntry {
+	re := ReadableWalletHistoryEntry{
+		TxID:           e.TxID.Hex(),
+		Confirmed:      e.Confirmed,
+		Time:           e.Time,
+		Direction:      e.Direction,
+		Coins:          StrBalance(e.Coins),
+		Fee:            e.Fee,
+		Balance:        StrBalance(e.Balance),
+		Counterparties: addressStrings(e.Counterparties),
+	}
+	// the block seq of unconfirmed txn is omitted, 0 is the genesis block
+	if e.Confirmed {
+		seq := e.BlockSeq
+		re.BlockSeq = &seq
+	}
+	return re
+}
+
+func (e WalletHistoryEntry) record() []string {
+	var seq string
+	if e.Confirmed {
+		seq = strconv.FormatUint(e.BlockSeq, 10)
+	}
+	return []string{
+		seq,
+		strconv.FormatUint(e.Time, 10),
+		e.TxID.Hex(),
+		strconv.FormatBool(e.Confirmed),
+		e.Direction,
+		StrBalance(e.Coins),
+		strconv.FormatUint(e.Fee, 10),
+		StrBalance(e.Balance),
+		strings.Join(addressStrings(e.Counterparties), " "),
+	}
+}
+
+// WriteWalletHistoryCSV writes the wallet history entries to w as CSV
+func WriteWalletHistoryCSV(w io.Writer, entries []WalletHistoryEntry) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(walletHistoryCSVHeader); err != nil {
+		return err
+	}
+
+	for _, e := range entries {
+		if err := cw.Write(e.record()); err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// GetWalletHistory returns the transactions affecting the addresses, the confirmed ones
+// ordered as in the blockchain, followed by the unconfirmed ones ordered by the received
+// time. The running balance of the unconfirmed entries is the predicted balance.
+func (vs *Visor) GetWalletHistory(addrs []cipher.Address) ([]WalletHistoryEntry, error) {
+	own := make(map[cipher.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		own[a] = struct{}{}
+	}
+
+	// the history db indexes the transactions by both input and output addresses,
+	// the transactions between the addresses of the wallet show up more than once
+	seqs := make(map[uint64]map[cipher.SHA256]struct{})
+	for _, a := range addrs {
+		txns, err := vs.history.GetAddrTxns(a)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, tx := range txns {
+			if _, ok := seqs[tx.BlockSeq]; !ok {
+				seqs[tx.BlockSeq] = make(map[cipher.SHA256]struct{})
+			}
+			seqs[tx.BlockSeq][tx.Tx.Hash()] = struct{}{}
+		}
+	}
+
+	blockSeqs := make([]uint64, 0, len(seqs))
+	for seq := range seqs {
+		blockSeqs = append(blockSeqs, seq)
+	}
+	sort.Slice(blockSeqs, func(i, j int) bool {
+		return blockSeqs[i] < blockSeqs[j]
+	})
+
+	var entries []WalletHistoryEntry
+	var balance uint64
+	for _, seq := range blockSeqs {
+		b := vs.GetBlockBySeq(seq)
+		if b == nil {
+			return nil, fmt.Errorf("found no block in seq %v", seq)
+		}
+
+		for i := range b.Body.Transactions {
+			txn := &b.Body.Transactions[i]
+			if _, ok := seqs[seq][txn.Hash()]; !ok {
+				continue
+			}
+
+			inputs, err := vs.historyInputs(txn)
+			if err != nil {
+				return nil, err
+			}
+
+			e, err := newWalletHistoryEntry(txn, inputs, own, &balance)
+			if err != nil {
+				return nil, err
+			}
+			e.Confirmed = true
+			e.BlockSeq = seq
+			e.Time = b.Time()
+			e.Fee = vs.confirmedTxnFeeOrZero(txn, seq)
+			entries = append(entries, e)
+		}
+	}
+
+	// the unconfirmed txns spend the outputs in the unspent pool
+	unspent := vs.Blockchain.Unspent()
+	headTime := vs.Blockchain.Time()
+	uncfmTxns := vs.Unconfirmed.GetTxns(func(tx UnconfirmedTxn) bool {
+		for _, a := range addrs {
+			if RelatedToAddress(unspent, a)(tx) {
+				return true
+			}
+		}
+		return false
+	})
+	sort.Sort(byReceived(uncfmTxns))
+
+	for i := range uncfmTxns {
+		txn := &uncfmTxns[i].Txn
+		inputs := make([]coin.UxOut, len(txn.In))
+		for j, in := range txn.In {
+			ux, ok := unspent.Get(in)
+			if !ok {
+				return nil, fmt.Errorf("found no unspent of id %v", in.Hex())
+			}
+			inputs[j] = ux
+		}
+
+		e, err := newWalletHistoryEntry(txn, inputs, own, &balance)
+		if err != nil {
+			return nil, err
+		}
+		// the fee is left zero if the inputs are no longer available
+		e.Fee, _ = vs.txnFee(txn, headTime)
+		e.Time = uint64(nanoToTime(uncfmTxns[i].Received).Unix())
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// SentCoinsSince returns the coins sent to the other addresses by the txns of the addresses
+// since the time, the unconfirmed txns included
+func (vs *Visor) SentCoinsSince(addrs []cipher.Address, since uint64) (uint64, error) {
+	entries, err := vs.GetWalletHistory(addrs)
+	if err != nil {
+		return 0, err
+	}
+
+	var coins uint64
+	for _, e := range entries {
+		if e.Direction == DirectionOut && e.Time >= since {
+			coins += e.Coins
+		}
+	}
+	return coins, nil
+}
+
+// GetWalletTxn returns the wallet history entry of the txn for the addresses, or nil if the
+// txn doesn't affect the addresses. The inputs of the confirmed txn are resolved from the
+// history db, the ones of the unconfirmed txn from the unspent pool. The balance is not set.
+func (vs *Visor) GetWalletTxn(txn *coin.Transaction, confirmed bool, addrs []cipher.Address) (*WalletHistoryEntry, error) {
+	var inputs []coin.UxOut
+	if confirmed {
+		var err error
+		inputs, err = vs.historyInputs(txn)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		unspent := vs.Blockchain.Unspent()
+		inputs = make([]coin.UxOut, len(txn.In))
+		for i, in := range txn.In {
+			ux, ok := unspent.Get(in)
+			if !ok {
+				return nil, fmt.Errorf("found no unspent of id %v", in.Hex())
+			}
+			inputs[i] = ux
+		}
+	}
+
+	own := make(map[cipher.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		own[a] = struct{}{}
+	}
+
+	var related bool
+	// the spent coins are counted in the balance, so that it's enough for the txn
+	var balance uint64
+	for _, ux := range inputs {
+		if _, ok := own[ux.Body.Address]; ok {
+			related = true
+			balance += ux.Body.Coins
+		}
+	}
+	for _, o := range txn.Out {
+		if _, ok := own[o.Address]; ok {
+			related = true
+		}
+	}
+
+	if !related {
+		return nil, nil
+	}
+
+	e, err := newWalletHistoryEntry(txn, inputs, own, &balance)
+	if err != nil {
+		return nil, err
+	}
+	e.Balance = 0
+	e.Confirmed = confirmed
+	return &e, nil
+}
+
+// historyInputs resolves the inputs of the confirmed transaction from the history db
+func (vs *Visor) historyInputs(txn *coin.Transaction) ([]coin.UxOut, error) {
+	inputs := make([]coin.UxOut, len(txn.In))
+	for i, in := range txn.In {
+		ux, err := vs.history.GetUxout(in)
+		if err != nil {
+			return nil, err
+		}
+
+		if ux == nil {
+			return nil, fmt.Errorf("found no uxout of id %v", in.Hex())
+		}
+		inputs[i] = ux.Out
+	}
+	return inputs, nil
+}
+
+// newWalletHistoryEntry computes the direction, coins and counterparties of the txn
+// for the own addresses, and applies it to the running balance
+func newWalletHistoryEntry(txn *coin.Transaction, inputs []coin.UxOut, own map[cipher.Address]struct{}, balance *uint64) (WalletHistoryEntry, error) {
+	var inCoins, outCoins uint64
+	var inAddrs, outAddrs []cipher.Address
+	for _, ux := range inputs {
+		if _, ok := own[ux.Body.Address]; ok {
+			inCoins += ux.Body.Coins
+		} else {
+			inAddrs = appendAddress(inAddrs, ux.Body.Address)
+		}
+	}
+
+	for _, o := range txn.Out {
+		if _, ok := own[o.Address]; ok {
+			outCoins += o.Coins
+		} else {
+			outAddrs = appendAddress(outAddrs, o.Address)
+		}
+	}
+
+	if *balance+outCoins < inCoins {
+		return WalletHistoryEntry{}, fmt.Errorf("transaction %s spends more than the balance", txn.Hash().Hex())
+	}
+	*balance = *balance + outCoins - inCoins
+
+	e := WalletHistoryEntry{
+		TxID:    txn.Hash(),
+		Balance: *balance,
+	}
+
+	switch {
+	case outCoins > inCoins:
+		e.Direction = DirectionIn
+		e.Coins = outCoins - inCoins
+		e.Counterparties = inAddrs
+	case len(outAddrs) == 0:
+		e.Direction = DirectionSelf
+	default:
+		e.Direction = DirectionOut
+		e.Coins = inCoins - outCoins
+		e.Counterparties = outAddrs
+	}
+
+	return e, nil
+}
+
+func appendAddress(addrs []cipher.Address, addr cipher.Address) []cipher.Address {
+	for _, a := range addrs {
+		if a == addr {
+			return addrs
+		}
+	}
+	return append(addrs, addr)
+}
+
+func addressStrings(addrs []cipher.Address) []string {
+	ss := make([]string, len(addrs))
+	for i, a := range addrs {
+		ss[i] = a.String()
+	}
+	return ss
+}
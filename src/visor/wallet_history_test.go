@@ -0,0 +1,163 @@
+package visor
+
+import (
+	"bytes"
+	"encoding/csv"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+	"github.com/skycoin/skycoin/src/visor/historydb"
+)
+
+func TestGetWalletHistory(t *testing.T) {
+	f, err := ioutil.TempFile("", "wallet_history")
+	require.NoError(t, err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	db, err := bolt.Open(f.Name(), 0600, nil)
+	require.NoError(t, err)
+	defer db.Close()
+
+	bc, err := NewBlockchain(db, walker)
+	require.NoError(t, err)
+
+	history, err := historydb.New(db)
+	require.NoError(t, err)
+
+	vs := &Visor{Blockchain: bc, Unconfirmed: NewUnconfirmedTxnPool(db), history: history}
+
+	// the wallet has the addresses a and b, x is the counterparty
+	aPub, aSec := cipher.GenerateKeyPair()
+	a := cipher.AddressFromPubKey(aPub)
+	bPub, bSec := cipher.GenerateKeyPair()
+	b := cipher.AddressFromPubKey(bPub)
+	xPub, xSec := cipher.GenerateKeyPair()
+	x := cipher.AddressFromPubKey(xPub)
+
+	gb, err := bc.CreateGenesisBlock(a, 100e6, 1000)
+	require.NoError(t, err)
+	require.NoError(t, history.ProcessBlock(&gb))
+
+	execute := func(txn coin.Transaction, tm uint64) {
+		blk, err := bc.NewBlockFromTransactions(coin.Transactions{txn}, tm)
+		require.NoError(t, err)
+		require.NoError(t, bc.ExecuteBlock(blk))
+		require.NoError(t, history.ProcessBlock(blk))
+	}
+
+	// sends 20 to x, the change goes to b
+	ux := bc.Unspent().GetUnspentsOfAddr(a)[0]
+	txn1 := coin.Transaction{}
+	txn1.PushInput(ux.Hash())
+	txn1.PushOutput(x, 20e6, 100)
+	txn1.PushOutput(b, 80e6, 100)
+	txn1.SignInputs([]cipher.SecKey{aSec})
+	txn1.UpdateHeader()
+	execute(txn1, gb.Time()+3600)
+
+	// receives 5 from x
+	ux = bc.Unspent().GetUnspentsOfAddr(x)[0]
+	txn2 := coin.Transaction{}
+	txn2.PushInput(ux.Hash())
+	txn2.PushOutput(b, 5e6, 10)
+	txn2.PushOutput(x, 15e6, 10)
+	txn2.SignInputs([]cipher.SecKey{xSec})
+	txn2.UpdateHeader()
+	execute(txn2, gb.Time()+7200)
+
+	// moves the 80 of b to a, unconfirmed
+	var bux coin.UxOut
+	for _, u := range bc.Unspent().GetUnspentsOfAddr(b) {
+		if u.Body.Coins == 80e6 {
+			bux = u
+		}
+	}
+	txn3 := coin.Transaction{}
+	txn3.PushInput(bux.Hash())
+	txn3.PushOutput(a, 80e6, 10)
+	txn3.SignInputs([]cipher.SecKey{bSec})
+	txn3.UpdateHeader()
+	known, err := vs.Unconfirmed.InjectTxn(bc, txn3)
+	require.NoError(t, err)
+	require.False(t, known)
+
+	entries, err := vs.GetWalletHistory([]cipher.Address{a, b})
+	require.NoError(t, err)
+	require.Len(t, entries, 4)
+
+	assert.Equal(t, gb.Body.Transactions[0].Hash(), entries[0].TxID)
+	assert.Equal(t, DirectionIn, entries[0].Direction)
+	assert.Equal(t, uint64(100e6), entries[0].Coins)
+	assert.Equal(t, uint64(100e6), entries[0].Balance)
+	assert.Empty(t, entries[0].Counterparties)
+
+	assert.Equal(t, txn1.Hash(), entries[1].TxID)
+	assert.True(t, entries[1].Confirmed)
+	assert.Equal(t, uint64(1), entries[1].BlockSeq)
+	assert.Equal(t, DirectionOut, entries[1].Direction)
+	assert.Equal(t, uint64(20e6), entries[1].Coins)
+	assert.Equal(t, uint64(80e6), entries[1].Balance)
+	assert.Equal(t, []cipher.Address{x}, entries[1].Counterparties)
+
+	assert.Equal(t, txn2.Hash(), entries[2].TxID)
+	assert.Equal(t, DirectionIn, entries[2].Direction)
+	assert.Equal(t, uint64(5e6), entries[2].Coins)
+	assert.Equal(t, uint64(85e6), entries[2].Balance)
+	assert.Equal(t, []cipher.Address{x}, entries[2].Counterparties)
+
+	assert.Equal(t, txn3.Hash(), entries[3].TxID)
+	assert.False(t, entries[3].Confirmed)
+	assert.Equal(t, DirectionSelf, entries[3].Direction)
+	assert.Equal(t, uint64(0), entries[3].Coins)
+	assert.Equal(t, uint64(85e6), entries[3].Balance)
+	assert.Empty(t, entries[3].Counterparties)
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteWalletHistoryCSV(&buf, entries))
+	rows, err := csv.NewReader(&buf).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, rows, 5)
+	assert.Equal(t, walletHistoryCSVHeader, rows[0])
+	assert.Equal(t, entries[1].record(), rows[2])
+	assert.Equal(t, x.String(), rows[2][8])
+	// the genesis block seq is 0, the block seq of unconfirmed txn is empty
+	assert.Equal(t, "0", rows[1][0])
+	assert.Equal(t, "", rows[4][0])
+
+	assert.Equal(t, uint64(0), *NewReadableWalletHistoryEntry(entries[0]).BlockSeq)
+	assert.Nil(t, NewReadableWalletHistoryEntry(entries[3]).BlockSeq)
+
+	e, err := vs.GetWalletTxn(&txn1, true, []cipher.Address{a, b})
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.True(t, e.Confirmed)
+	assert.Equal(t, DirectionOut, e.Direction)
+	assert.Equal(t, uint64(20e6), e.Coins)
+	assert.Equal(t, []cipher.Address{x}, e.Counterparties)
+
+	// x receives the 20
+	e, err = vs.GetWalletTxn(&txn1, true, []cipher.Address{x})
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.Equal(t, DirectionIn, e.Direction)
+	assert.Equal(t, uint64(20e6), e.Coins)
+
+	e, err = vs.GetWalletTxn(&txn3, false, []cipher.Address{a})
+	require.NoError(t, err)
+	require.NotNil(t, e)
+	assert.False(t, e.Confirmed)
+	assert.Equal(t, DirectionIn, e.Direction)
+	assert.Equal(t, uint64(80e6), e.Coins)
+
+	e, err = vs.GetWalletTxn(&txn3, false, []cipher.Address{x})
+	require.NoError(t, err)
+	assert.Nil(t, e)
+}
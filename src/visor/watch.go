@@ -0,0 +1,132 @@
+package visor
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// MaxWatchedAddresses is the max number of addresses that can be watched
+const MaxWatchedAddresses = 10000
+
+// AddressActivity is an output of a watched address being received or spent, Type is
+// either MovementReceive or MovementSpend.
+type AddressActivity struct {
+	Address   string `json:"address"`
+	Type      string `json:"type"`
+	UxID      string `json:"uxid"`
+	TxID      string `json:"txid"`
+	Coins     string `json:"coins"`
+	Hours     uint64 `json:"hours"`
+	Confirmed bool   `json:"confirmed"`
+	// seq of the block confirming the txn, 0 if unconfirmed
+	BlockSeq uint64 `json:"block_seq"`
+}
+
+// watchList is the set of the watched addresses
+type watchList map[cipher.Address]struct{}
+
+// WatchAddresses adds the addresses to the watch list, the activities of the addresses
+// are published as EventAddressActivity events.
+func (vs *Visor) WatchAddresses(addrs []cipher.Address) error {
+	var n int
+	for _, a := range addrs {
+		if _, ok := vs.watched[a]; !ok {
+			n++
+		}
+	}
+
+	if len(vs.watched)+n > MaxWatchedAddresses {
+		return fmt.Errorf("at most %d addresses can be watched", MaxWatchedAddresses)
+	}
+
+	for _, a := range addrs {
+		vs.watched[a] = struct{}{}
+	}
+	return nil
+}
+
+// UnwatchAddresses removes the addresses from the watch list
+func (vs *Visor) UnwatchAddresses(addrs []cipher.Address) {
+	for _, a := range addrs {
+		delete(vs.watched, a)
+	}
+}
+
+// GetWatchedAddresses returns the watched addresses, sorted by the base58 string
+func (vs *Visor) GetWatchedAddresses() []string {
+	addrs := make([]string, 0, len(vs.watched))
+	for a := range vs.watched {
+		addrs = append(addrs, a.String())
+	}
+	sort.Strings(addrs)
+	return addrs
+}
+
+// activities returns the activities of the watched addresses in txn, uxIn are the
+// outputs spent by the txn. head is the header of the block confirming the txn, or the
+// current head if the txn is unconfirmed.
+func (wl watchList) activities(txn coin.Transaction, uxIn coin.UxArray, head coin.BlockHeader, confirmed bool) []AddressActivity {
+	if len(wl) == 0 {
+		return nil
+	}
+
+	txid := txn.Hash()
+	newActivity := func(ux coin.UxOut, tp string) AddressActivity {
+		ac := AddressActivity{
+			Address:   ux.Body.Address.String(),
+			Type:      tp,
+			UxID:      ux.Hash().Hex(),
+			TxID:      txid.Hex(),
+			Coins:     StrBalance(ux.Body.Coins),
+			Hours:     ux.Body.Hours,
+			Confirmed: confirmed,
+		}
+		if confirmed {
+			ac.BlockSeq = head.BkSeq
+		}
+		return ac
+	}
+
+	var acs []AddressActivity
+	for _, ux := range uxIn {
+		if _, ok := wl[ux.Body.Address]; ok {
+			acs = append(acs, newActivity(ux, MovementSpend))
+		}
+	}
+
+	for _, ux := range coin.CreateUnspents(head, txn) {
+		if _, ok := wl[ux.Body.Address]; ok {
+			acs = append(acs, newActivity(ux, MovementReceive))
+		}
+	}
+	return acs
+}
+
+// watchedInputs returns the outputs spent by each txn, returns nil if no address is watched
+func (vs *Visor) watchedInputs(txns coin.Transactions) []coin.UxArray {
+	if len(vs.watched) == 0 {
+		return nil
+	}
+
+	uxIns := make([]coin.UxArray, len(txns))
+	for i := range txns {
+		uxIn, err := vs.Blockchain.Unspent().GetArray(txns[i].In)
+		if err != nil {
+			logger.Error("Get inputs of txn %v failed: %v", txns[i].Hash().Hex(), err)
+			continue
+		}
+		uxIns[i] = uxIn
+	}
+	return uxIns
+}
+
+// publishActivities publishes the activities of the watched addresses in txn
+func (vs *Visor) publishActivities(txn coin.Transaction, uxIn coin.UxArray, head coin.BlockHeader, confirmed bool) {
+	for _, ac := range vs.watched.activities(txn, uxIn, head, confirmed) {
+		ac := ac
+		vs.events.Publish(Event{Type: EventAddressActivity, TxID: txn.Hash(), Activity: &ac})
+	}
+}
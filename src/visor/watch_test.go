@@ -0,0 +1,65 @@
+package visor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestWatchListActivities(t *testing.T) {
+	newAddr := func() cipher.Address {
+		p, _ := cipher.GenerateKeyPair()
+		return cipher.AddressFromPubKey(p)
+	}
+	a, b, c := newAddr(), newAddr(), newAddr()
+
+	uxIn := coin.UxArray{
+		{Body: coin.UxBody{SrcTransaction: randSHA256(), Address: a, Coins: 3e6, Hours: 10}},
+		{Body: coin.UxBody{SrcTransaction: randSHA256(), Address: c, Coins: 1e6, Hours: 10}},
+	}
+
+	txn := coin.Transaction{}
+	txn.PushInput(uxIn[0].Hash())
+	txn.PushInput(uxIn[1].Hash())
+	txn.PushOutput(b, 2e6, 5)
+	txn.PushOutput(c, 2e6, 5)
+	txn.UpdateHeader()
+
+	head := coin.BlockHeader{BkSeq: 10, Time: 100}
+
+	wl := watchList{}
+	assert.Empty(t, wl.activities(txn, uxIn, head, true))
+
+	wl[a] = struct{}{}
+	wl[b] = struct{}{}
+	acs := wl.activities(txn, uxIn, head, false)
+	outs := coin.CreateUnspents(head, txn)
+	assert.Equal(t, []AddressActivity{
+		{
+			Address: a.String(),
+			Type:    MovementSpend,
+			UxID:    uxIn[0].Hash().Hex(),
+			TxID:    txn.Hash().Hex(),
+			Coins:   "3",
+			Hours:   10,
+		},
+		{
+			Address: b.String(),
+			Type:    MovementReceive,
+			UxID:    outs[0].Hash().Hex(),
+			TxID:    txn.Hash().Hex(),
+			Coins:   "2",
+			Hours:   5,
+		},
+	}, acs)
+
+	acs = wl.activities(txn, uxIn, head, true)
+	assert.Len(t, acs, 2)
+	for _, ac := range acs {
+		assert.True(t, ac.Confirmed)
+		assert.Equal(t, uint64(10), ac.BlockSeq)
+	}
+}
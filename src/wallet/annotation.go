@@ -0,0 +1,131 @@
+package wallet
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// Annotation is the bookkeeping data attached to an address or a transaction of the wallet
+type Annotation struct {
+	Label    string `json:"label,omitempty"`
+	Category string `json:"category,omitempty"`
+	Note     string `json:"note,omitempty"`
+}
+
+// IsEmpty returns true if nothing is set
+func (a Annotation) IsEmpty() bool {
+	return a == Annotation{}
+}
+
+// ReadableAnnotations are the annotations of the wallet keyed by the address and by the
+// transaction id
+type ReadableAnnotations struct {
+	Addresses    map[string]Annotation `json:"addresses"`
+	Transactions map[string]Annotation `json:"transactions"`
+}
+
+// AddressAnnotations returns the annotations of the addresses keyed by the address, they're
+// kept in the meta
+func (wlt Wallet) AddressAnnotations() (map[string]Annotation, error) {
+	return wlt.annotations("addressAnnotations")
+}
+
+// TxnAnnotations returns the annotations of the transactions keyed by the transaction id,
+// they're kept in the meta
+func (wlt Wallet) TxnAnnotations() (map[string]Annotation, error) {
+	return wlt.annotations("txnAnnotations")
+}
+
+// GetAddressAnnotation returns the annotation of the address, it's empty if not set
+func (wlt Wallet) GetAddressAnnotation(addr cipher.Address) Annotation {
+	as, err := wlt.AddressAnnotations()
+	if err != nil {
+		return Annotation{}
+	}
+	return as[addr.String()]
+}
+
+// GetTxnAnnotation returns the annotation of the transaction, it's empty if not set
+func (wlt Wallet) GetTxnAnnotation(txid cipher.SHA256) Annotation {
+	as, err := wlt.TxnAnnotations()
+	if err != nil {
+		return Annotation{}
+	}
+	return as[txid.Hex()]
+}
+
+// SetAddressAnnotation annotates the address of the wallet, it must be an entry or a
+// multisig address of the wallet. The empty annotation removes it.
+func (wlt *Wallet) SetAddressAnnotation(addr cipher.Address, a Annotation) error {
+	if _, ok := wlt.GetEntry(addr); !ok {
+		if _, ok := wlt.GetMultisig(addr); !ok {
+			return fmt.Errorf("address %s is not in the wallet", addr)
+		}
+	}
+	return wlt.setAnnotation("addressAnnotations", addr.String(), a)
+}
+
+// SetTxnAnnotation annotates the transaction sent or received by the wallet, the empty
+// annotation removes it
+func (wlt *Wallet) SetTxnAnnotation(txid cipher.SHA256, a Annotation) error {
+	return wlt.setAnnotation("txnAnnotations", txid.Hex(), a)
+}
+
+// ReadableAnnotations returns all the annotations of the wallet
+func (wlt Wallet) ReadableAnnotations() (ReadableAnnotations, error) {
+	addrs, err := wlt.AddressAnnotations()
+	if err != nil {
+		return ReadableAnnotations{}, err
+	}
+
+	txns, err := wlt.TxnAnnotations()
+	if err != nil {
+		return ReadableAnnotations{}, err
+	}
+
+	return ReadableAnnotations{
+		Addresses:    addrs,
+		Transactions: txns,
+	}, nil
+}
+
+func (wlt Wallet) annotations(key string) (map[string]Annotation, error) {
+	as := make(map[string]Annotation)
+	s := wlt.Meta[key]
+	if s == "" {
+		return as, nil
+	}
+
+	if err := json.Unmarshal([]byte(s), &as); err != nil {
+		return nil, fmt.Errorf("decode %s failed: %v", key, err)
+	}
+	return as, nil
+}
+
+func (wlt *Wallet) setAnnotation(key, id string, a Annotation) error {
+	as, err := wlt.annotations(key)
+	if err != nil {
+		return err
+	}
+
+	if a.IsEmpty() {
+		delete(as, id)
+	} else {
+		as[id] = a
+	}
+
+	if len(as) == 0 {
+		delete(wlt.Meta, key)
+		return nil
+	}
+
+	b, err := json.Marshal(as)
+	if err != nil {
+		return err
+	}
+
+	wlt.Meta[key] = string(b)
+	return nil
+}
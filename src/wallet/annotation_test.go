@@ -0,0 +1,73 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestWalletAnnotations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := NewWallet("a.wlt", OptSeed("annotation seed"))
+	require.NoError(t, err)
+	addrs := w.GenerateAddresses(2)
+
+	p1, _ := cipher.GenerateKeyPair()
+	e, ok := w.GetEntry(addrs[0])
+	require.True(t, ok)
+	ms, err := w.AddMultisig(1, []cipher.PubKey{p1, e.Public})
+	require.NoError(t, err)
+
+	rent := Annotation{Label: "rent", Category: "expenses"}
+	savings := Annotation{Label: "savings", Note: "don't spend"}
+	shared := Annotation{Label: "shared"}
+	require.NoError(t, w.SetAddressAnnotation(addrs[0], rent))
+	require.NoError(t, w.SetAddressAnnotation(addrs[1], savings))
+	require.NoError(t, w.SetAddressAnnotation(ms.Address, shared))
+
+	// the address must be in the wallet
+	err = w.SetAddressAnnotation(cipher.AddressFromPubKey(p1), rent)
+	assert.Error(t, err)
+
+	txid := cipher.SumSHA256([]byte("txn"))
+	paid := Annotation{Label: "paid rent", Category: "expenses", Note: "march"}
+	require.NoError(t, w.SetTxnAnnotation(txid, paid))
+
+	// the empty annotation removes it
+	require.NoError(t, w.SetAddressAnnotation(addrs[1], Annotation{}))
+	assert.True(t, w.GetAddressAnnotation(addrs[1]).IsEmpty())
+
+	require.NoError(t, w.Save(dir))
+	loaded, err := Load(filepath.Join(dir, "a.wlt"))
+	require.NoError(t, err)
+
+	assert.Equal(t, rent, loaded.GetAddressAnnotation(addrs[0]))
+	assert.Equal(t, shared, loaded.GetAddressAnnotation(ms.Address))
+	assert.Equal(t, paid, loaded.GetTxnAnnotation(txid))
+	assert.True(t, loaded.GetTxnAnnotation(cipher.SumSHA256([]byte("other"))).IsEmpty())
+
+	ra, err := loaded.ReadableAnnotations()
+	require.NoError(t, err)
+	assert.Equal(t, ReadableAnnotations{
+		Addresses: map[string]Annotation{
+			addrs[0].String():   rent,
+			ms.Address.String(): shared,
+		},
+		Transactions: map[string]Annotation{
+			txid.Hex(): paid,
+		},
+	}, ra)
+
+	require.NoError(t, loaded.SetTxnAnnotation(txid, Annotation{}))
+	_, ok = loaded.Meta["txnAnnotations"]
+	assert.False(t, ok)
+}
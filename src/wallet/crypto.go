@@ -0,0 +1,231 @@
+package wallet
+
+import (
+	"crypto/aes"
+	gocipher "crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/scrypt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// CryptoType is the encryption of the wallet secrets, the key is derived from the
+// password by scrypt and the secrets are sealed by AES-256-GCM
+const CryptoType = "scrypt-aes256gcm"
+
+const (
+	// scrypt cost parameters, log2 of N is stored with the encrypted data
+	scryptLogN = 15
+	scryptR    = 8
+	scryptP    = 1
+
+	// the bounds of the scrypt parameters of the encrypted data, scrypt uses 128*r*N bytes
+	// of memory and its time grows with p too. The parameters written by encrypt are
+	// within the bounds, the larger ones would make decrypt exhaust the memory.
+	maxScryptLogN   = 20
+	maxScryptP      = 4
+	maxScryptMemory = 256 << 20
+
+	cryptoVersion = 1
+	saltLen       = 32
+	keyLen        = 32
+)
+
+var (
+	// ErrWalletEncrypted is returned if the wallet secrets are needed but the wallet is
+	// encrypted
+	ErrWalletEncrypted = errors.New("the wallet is encrypted")
+	// ErrWalletNotEncrypted is returned if the wallet is expected to be encrypted
+	ErrWalletNotEncrypted = errors.New("the wallet is not encrypted")
+	// ErrMissingPassword is returned if the password is empty
+	ErrMissingPassword = errors.New("missing password")
+	// ErrInvalidPassword is returned if the secrets can't be decrypted by the password
+	ErrInvalidPassword = errors.New("invalid password")
+)
+
+// walletSecrets are the data encrypted in the wallet file
+type walletSecrets struct {
+	Seed     string `json:"seed"`
+	LastSeed string `json:"lastSeed"`
+	// hex secret keys by address
+	Keys map[string]string `json:"keys"`
+}
+
+// IsEncrypted returns true if the seed and the secret keys of the wallet are encrypted
+func (wlt Wallet) IsEncrypted() bool {
+	return wlt.Meta["encrypted"] == "true"
+}
+
+// Encrypt encrypts the seed and the secret keys with the password, they're removed from
+// the wallet. The addresses and the public keys are kept in plaintext.
+func (wlt *Wallet) Encrypt(password []byte) error {
+	if wlt.IsEncrypted() {
+		return errors.New("the wallet is already encrypted")
+	}
+
+	if len(password) == 0 {
+		return ErrMissingPassword
+	}
+
+	s := walletSecrets{
+		Seed:     wlt.Meta["seed"],
+		LastSeed: wlt.Meta["lastSeed"],
+		Keys:     make(map[string]string, len(wlt.Entries)),
+	}
+
+	entries := make([]Entry, len(wlt.Entries))
+	for i, e := range wlt.Entries {
+		s.Keys[e.Address.String()] = e.Secret.Hex()
+		e.Secret = cipher.SecKey{}
+		entries[i] = e
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	sealed, err := encrypt(data, password)
+	if err != nil {
+		return err
+	}
+
+	wlt.Meta["encrypted"] = "true"
+	wlt.Meta["cryptoType"] = CryptoType
+	wlt.Meta["secrets"] = base64.StdEncoding.EncodeToString(sealed)
+	wlt.Meta["seed"] = ""
+	wlt.Meta["lastSeed"] = ""
+	wlt.Entries = entries
+	return nil
+}
+
+// Decrypt returns a copy of the wallet whose seed and secret keys are decrypted by the
+// password, the wallet is not changed
+func (wlt Wallet) Decrypt(password []byte) (*Wallet, error) {
+	if !wlt.IsEncrypted() {
+		return nil, ErrWalletNotEncrypted
+	}
+
+	if len(password) == 0 {
+		return nil, ErrMissingPassword
+	}
+
+	if ct := wlt.Meta["cryptoType"]; ct != CryptoType {
+		return nil, fmt.Errorf("unsupported crypto type %q", ct)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(wlt.Meta["secrets"])
+	if err != nil {
+		return nil, fmt.Errorf("decode the wallet secrets failed: %v", err)
+	}
+
+	data, err := decrypt(sealed, password)
+	if err != nil {
+		return nil, err
+	}
+
+	var s walletSecrets
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("decode the wallet secrets failed: %v", err)
+	}
+
+	w := &Wallet{
+		Meta:    make(map[string]string, len(wlt.Meta)),
+		Entries: make([]Entry, len(wlt.Entries)),
+	}
+	for k, v := range wlt.Meta {
+		w.Meta[k] = v
+	}
+	delete(w.Meta, "encrypted")
+	delete(w.Meta, "cryptoType")
+	delete(w.Meta, "secrets")
+	w.Meta["seed"] = s.Seed
+	w.Meta["lastSeed"] = s.LastSeed
+
+	for i, e := range wlt.Entries {
+		sec, err := cipher.SecKeyFromHex(s.Keys[e.Address.String()])
+		if err != nil {
+			return nil, fmt.Errorf("secret key of address %s: %v", e.Address, err)
+		}
+		e.Secret = sec
+		if err := e.Verify(); err != nil {
+			return nil, fmt.Errorf("secret key of address %s: %v", e.Address, err)
+		}
+		w.Entries[i] = e
+	}
+
+	return w, nil
+}
+
+// encrypt seals data with the key derived from the password, the result is
+// version | log2(N) | r | p | salt | nonce | ciphertext
+func encrypt(data, password []byte) ([]byte, error) {
+	salt := make([]byte, saltLen)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, err
+	}
+
+	gcm, err := newGCM(password, salt, scryptLogN, scryptR, scryptP)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+
+	out := []byte{cryptoVersion, scryptLogN, scryptR, scryptP}
+	out = append(out, salt...)
+	out = append(out, nonce...)
+	return gcm.Seal(out, nonce, data, nil), nil
+}
+
+// decrypt opens the data sealed by encrypt
+func decrypt(sealed, password []byte) ([]byte, error) {
+	if len(sealed) < 4+saltLen || sealed[0] != cryptoVersion {
+		return nil, errors.New("invalid encrypted data")
+	}
+
+	logN, r, p := sealed[1], int(sealed[2]), int(sealed[3])
+	if logN < 1 || logN > maxScryptLogN || r < 1 || p < 1 || p > maxScryptP ||
+		128*uint64(r)<<logN > maxScryptMemory {
+		return nil, errors.New("invalid encrypted data")
+	}
+
+	salt := sealed[4 : 4+saltLen]
+	gcm, err := newGCM(password, salt, logN, r, p)
+	if err != nil {
+		return nil, err
+	}
+
+	rest := sealed[4+saltLen:]
+	if len(rest) < gcm.NonceSize() {
+		return nil, errors.New("invalid encrypted data")
+	}
+
+	data, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+	if err != nil {
+		return nil, ErrInvalidPassword
+	}
+	return data, nil
+}
+
+func newGCM(password, salt []byte, logN byte, r, p int) (gocipher.AEAD, error) {
+	key, err := scrypt.Key(password, salt, 1<<logN, r, p, keyLen)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return gocipher.NewGCM(block)
+}
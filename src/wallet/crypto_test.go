@@ -0,0 +1,114 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptWallet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := NewWallet("a.wlt", OptSeed("secret seed"))
+	require.NoError(t, err)
+	w.GenerateAddresses(2)
+	plain := *w
+	plain.Entries = append([]Entry(nil), w.Entries...)
+	seed, lastSeed := w.Meta["seed"], w.Meta["lastSeed"]
+
+	// the plaintext wallet is saved twice, the second save backs up the first file
+	require.NoError(t, w.Save(dir))
+	require.NoError(t, w.Save(dir))
+	_, err = os.Stat(filepath.Join(dir, "a.wlt.bak"))
+	require.NoError(t, err)
+
+	assert.Equal(t, ErrMissingPassword, w.Encrypt(nil))
+	require.NoError(t, w.Encrypt([]byte("pwd")))
+	assert.True(t, w.IsEncrypted())
+	assert.Error(t, w.Encrypt([]byte("pwd")))
+	require.NoError(t, w.Validate())
+	assert.Equal(t, plain.GetAddresses(), w.GetAddresses())
+	for i, e := range w.Entries {
+		assert.Equal(t, plain.Entries[i].Public, e.Public)
+		assert.Equal(t, Entry{}.Secret, e.Secret)
+	}
+
+	// no file in the wallet dir has the seed or the secret keys, the plaintext backup is
+	// removed
+	require.NoError(t, w.Save(dir))
+	require.NoError(t, w.Save(dir))
+	files, err := ioutil.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, files, 1)
+	for _, f := range files {
+		data, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		require.NoError(t, err)
+		assert.False(t, strings.Contains(string(data), seed), f.Name())
+		assert.False(t, strings.Contains(string(data), lastSeed), f.Name())
+		for _, e := range plain.Entries {
+			assert.False(t, strings.Contains(string(data), e.Secret.Hex()), f.Name())
+		}
+	}
+
+	loaded, err := Load(filepath.Join(dir, "a.wlt"))
+	require.NoError(t, err)
+	require.NoError(t, loaded.Validate())
+	assert.True(t, loaded.IsEncrypted())
+
+	_, err = loaded.Decrypt([]byte("wrong"))
+	assert.Equal(t, ErrInvalidPassword, err)
+	_, err = loaded.Decrypt(nil)
+	assert.Equal(t, ErrMissingPassword, err)
+
+	d, err := loaded.Decrypt([]byte("pwd"))
+	require.NoError(t, err)
+	require.NoError(t, d.Validate())
+	assert.False(t, d.IsEncrypted())
+	assert.Equal(t, seed, d.Meta["seed"])
+	assert.Equal(t, lastSeed, d.Meta["lastSeed"])
+	assert.Equal(t, plain.Entries, d.Entries)
+	assert.True(t, loaded.IsEncrypted())
+
+	_, err = d.Decrypt([]byte("pwd"))
+	assert.Equal(t, ErrWalletNotEncrypted, err)
+
+	// the encrypted wallet can't generate the addresses
+	wlts := Wallets{}
+	require.NoError(t, wlts.Add(*loaded))
+	_, err = wlts.NewAddresses("a.wlt", 1)
+	assert.Equal(t, ErrWalletEncrypted, err)
+}
+
+func TestDecryptScryptParams(t *testing.T) {
+	sealed, err := encrypt([]byte("secrets"), []byte("pwd"))
+	require.NoError(t, err)
+
+	data, err := decrypt(sealed, []byte("pwd"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("secrets"), data)
+
+	// the parameters out of the bounds are rejected before scrypt runs
+	for _, params := range [][3]byte{
+		{0, scryptR, scryptP},
+		{maxScryptLogN + 1, scryptR, scryptP},
+		{scryptLogN, 0, scryptP},
+		{scryptLogN, scryptR, 0},
+		{scryptLogN, scryptR, maxScryptP + 1},
+		{scryptLogN, 255, 255},
+		// 4 GiB of scrypt memory
+		{20, 32, 1},
+		{maxScryptLogN, scryptR * 2, scryptP},
+	} {
+		d := append([]byte{}, sealed...)
+		copy(d[1:4], params[:])
+		_, err := decrypt(d, []byte("pwd"))
+		assert.EqualError(t, err, "invalid encrypted data")
+	}
+}
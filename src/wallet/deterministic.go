@@ -0,0 +1,563 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	bip39 "github.com/skycoin/skycoin/src/cipher/go-bip39"
+	"github.com/skycoin/skycoin/src/util/file"
+)
+
+// Wallet contains meta data and address entries.
+// Meta:
+// 		Filename
+// 		Seed
+//		Version - the version of the wallet file
+//		CreatedAt - the time the wallet is created, RFC3339
+//		Type - wallet type
+//		Derivation - sha256_chain or the BIP44 account path of the HD wallet
+//		Coin - coin type
+//		FreshChange - send the change of the spends to new addresses
+//		AddressAnnotations, TxnAnnotations - the labels, categories and notes of the
+//		addresses and the transactions
+//		Signer - the external signer of the spends, see SocketSigner
+//		Policy - the spending limits of the wallet, see Policy
+type Wallet struct {
+	Meta    map[string]string
+	Entries []Entry
+}
+
+var version = "0.2"
+
+const (
+	// DeterministicWalletType is the type of the wallets whose keys are derived by
+	// hashing the seed repeatedly
+	DeterministicWalletType = "deterministic"
+	// HDWalletType is the type of the wallets whose keys are derived by BIP32 in the BIP44
+	// paths m/44'/coinType'/account'/change/index, the seed must be a BIP39 mnemonic
+	HDWalletType = "bip44"
+)
+
+// Option NewWallet optional arguments type
+type Option func(w *Wallet)
+
+// NewWallet generates Deterministic Wallet
+// generates a random seed if seed is ""
+func NewWallet(wltName string, opts ...Option) (*Wallet, error) {
+	// generaten bip39 as default seed
+	entropy, err := bip39.NewEntropy(128)
+	if err != nil {
+		return nil, fmt.Errorf("generate bip39 entropy failed, err:%v", err)
+	}
+
+	seed, err := bip39.NewMnemonic(entropy)
+	if err != nil {
+		return nil, fmt.Errorf("generate bip39 seed failed, err:%v", err)
+	}
+
+	w := &Wallet{
+		Meta: map[string]string{
+			"filename":     wltName,
+			"version":      version,
+			"label":        "",
+			"walletFolder": file.UserHome() + "/.skycoin/wallets",
+			"seed":         seed,
+			"lastSeed":     seed,
+			"tm":           fmt.Sprintf("%v", time.Now().Unix()),
+			"type":         DeterministicWalletType,
+			"coin":         "sky",
+			"freshChange":  "true"},
+	}
+
+	for _, opt := range opts {
+		opt(w)
+	}
+
+	if w.IsHD() && !bip39.IsMnemonicValid(w.Meta["seed"]) {
+		return nil, errors.New("the seed of the HD wallet must be a bip39 mnemonic")
+	}
+
+	w.setFileMeta(time.Now())
+	return w, nil
+}
+
+// OptCoin NewWallet function's optional argument
+func OptCoin(coin string) Option {
+	return func(w *Wallet) {
+		w.Meta["coin"] = coin
+	}
+}
+
+// OptLabel NewWallet function's optional argument
+func OptLabel(label string) Option {
+	return func(w *Wallet) {
+		w.Meta["label"] = label
+	}
+}
+
+// OptSeed NewWallet function's optional argument
+func OptSeed(sd string) Option {
+	return func(w *Wallet) {
+		if sd != "" {
+			w.Meta["seed"] = sd
+			w.Meta["lastSeed"] = sd
+		}
+	}
+}
+
+// OptFreshChange NewWallet function's optional argument, sends the change of the spends to
+// new addresses if fresh is true, otherwise back to the spent address
+func OptFreshChange(fresh bool) Option {
+	return func(w *Wallet) {
+		w.SetFreshChange(fresh)
+	}
+}
+
+// OptHD NewWallet function's optional argument, the keys are derived by BIP44 in the
+// account
+func OptHD(account uint32) Option {
+	return func(w *Wallet) {
+		w.Meta["type"] = HDWalletType
+		w.Meta["coinType"] = strconv.FormatUint(uint64(DefaultCoinType), 10)
+		w.Meta["account"] = strconv.FormatUint(uint64(account), 10)
+	}
+}
+
+// Load loads wallet from given file
+func Load(wltFile string) (*Wallet, error) {
+	// check file's existence
+	if _, err := os.Stat(wltFile); os.IsNotExist(err) {
+		return nil, fmt.Errorf("load wallet file failed, %v", err)
+	}
+	wlt := Wallet{
+		Meta: make(map[string]string),
+	}
+	wlt.SetFilename(filepath.Base(wltFile))
+	dir, err := filepath.Abs(filepath.Dir(wltFile))
+	if err != nil {
+		return nil, err
+	}
+	if err := wlt.Load(dir); err != nil {
+		return nil, fmt.Errorf("load wallet file failed, %v", err)
+	}
+	return &wlt, nil
+}
+
+// NewWalletFromReadable creates wallet from readable wallet
+func NewWalletFromReadable(r *ReadableWallet) Wallet {
+	w := Wallet{
+		Meta:    r.Meta,
+		Entries: r.Entries.ToWalletEntries(),
+	}
+
+	err := w.Validate()
+	if err != nil {
+		logger.Panicf("Wallet %s invalid: %v", w.GetFilename(), err)
+	}
+	return w
+}
+
+// Validate validates the wallet
+func (wlt Wallet) Validate() error {
+	if _, ok := wlt.Meta["filename"]; !ok {
+		return errors.New("filename not set")
+	}
+	if _, ok := wlt.Meta["seed"]; !ok {
+		return errors.New("seed not set")
+	}
+
+	// if _, ok := wlt.Meta["lastSeed"]; !ok {
+	// 	return errors.New("lastSeed not set")
+	// }
+
+	walletType, ok := wlt.Meta["type"]
+	if !ok {
+		return errors.New("type not set")
+	}
+	if wlt.IsEncrypted() {
+		if wlt.Meta["secrets"] == "" {
+			return errors.New("secrets of the encrypted wallet not set")
+		}
+	} else {
+		for _, e := range wlt.Entries {
+			if e.Secret == (cipher.SecKey{}) {
+				return fmt.Errorf("secret key of address %s not set", e.Address)
+			}
+		}
+	}
+
+	switch walletType {
+	case DeterministicWalletType:
+	case HDWalletType:
+		if !wlt.IsEncrypted() && !bip39.IsMnemonicValid(wlt.Meta["seed"]) {
+			return errors.New("seed is not a bip39 mnemonic")
+		}
+		if _, err := wlt.metaUint32("coinType"); err != nil {
+			return err
+		}
+		if _, err := wlt.metaUint32("account"); err != nil {
+			return err
+		}
+	default:
+		return errors.New("wallet type invalid")
+	}
+
+	if _, err := wlt.Multisigs(); err != nil {
+		return err
+	}
+
+	// coinType, ok := wlt.Meta["coin"]
+	if _, ok := wlt.Meta["coin"]; !ok {
+		return errors.New("coin field not set")
+	}
+	// if coinType != "sky" {
+	// 	return errors.New("coin type invalid")
+	// }
+
+	return nil
+
+}
+
+// GetType gets the wallet type
+func (wlt Wallet) GetType() string {
+	return wlt.Meta["type"]
+}
+
+// IsHD returns true if the keys of the wallet are derived by BIP32
+func (wlt Wallet) IsHD() bool {
+	return wlt.GetType() == HDWalletType
+}
+
+// GetFilename gets the wallet filename
+func (wlt Wallet) GetFilename() string {
+	return wlt.Meta["filename"]
+}
+
+// SetFilename sets the wallet filename
+func (wlt *Wallet) SetFilename(fn string) {
+	wlt.Meta["filename"] = fn
+}
+
+// GetID gets the wallet id
+func (wlt Wallet) GetID() string {
+	return wlt.Meta["filename"]
+}
+
+// GetLabel gets the wallet label
+func (wlt Wallet) GetLabel() string {
+	return wlt.Meta["label"]
+}
+
+// SetLabel sets the wallet label
+func (wlt *Wallet) SetLabel(label string) {
+	wlt.Meta["label"] = label
+}
+
+// FreshChange returns true if the change of the spends is sent to new addresses, the
+// wallets created before the setting reuse the spent address
+func (wlt Wallet) FreshChange() bool {
+	return wlt.Meta["freshChange"] == "true"
+}
+
+// SetFreshChange sets whether the change of the spends is sent to new addresses
+func (wlt *Wallet) SetFreshChange(fresh bool) {
+	wlt.Meta["freshChange"] = strconv.FormatBool(fresh)
+}
+
+func (wlt Wallet) getLastSeed() string {
+	return wlt.Meta["lastSeed"]
+}
+
+func (wlt *Wallet) setLastSeed(lseed string) {
+	wlt.Meta["lastSeed"] = lseed
+}
+
+// GetVersion gets the wallet version
+func (wlt *Wallet) GetVersion() string {
+	return wlt.Meta["version"]
+}
+
+// NumEntries returns the number of entries
+func (wlt Wallet) NumEntries() int {
+	return len(wlt.Entries)
+}
+
+// GenerateAddresses generate addresses of given number
+func (wlt *Wallet) GenerateAddresses(num int) []cipher.Address {
+	if wlt.IsEncrypted() {
+		logger.Panic("generate addresses of the encrypted wallet")
+	}
+
+	if wlt.IsHD() {
+		addrs, err := wlt.generateHDAddresses(ExternalChain, num)
+		if err != nil {
+			logger.Panicf("derive hd addresses failed, %v", err)
+		}
+		return addrs
+	}
+
+	var seckeys []cipher.SecKey
+	var sd []byte
+	var err error
+	if len(wlt.Entries) == 0 {
+		sd, seckeys = cipher.GenerateDeterministicKeyPairsSeed([]byte(wlt.getLastSeed()), num)
+	} else {
+		sd, err = hex.DecodeString(wlt.getLastSeed())
+		if err != nil {
+			logger.Panicf("decode hex seed failed,%v", err)
+		}
+		sd, seckeys = cipher.GenerateDeterministicKeyPairsSeed(sd, num)
+	}
+	wlt.setLastSeed(hex.EncodeToString(sd))
+	addrs := make([]cipher.Address, len(seckeys))
+	for i, s := range seckeys {
+		p := cipher.PubKeyFromSecKey(s)
+		a := cipher.AddressFromPubKey(p)
+		addrs[i] = a
+		wlt.Entries = append(wlt.Entries, Entry{
+			Address: a,
+			Secret:  s,
+			Public:  p,
+		})
+	}
+	return addrs
+}
+
+// GenerateChangeAddresses generates the addresses of the change chain of the HD wallet
+func (wlt *Wallet) GenerateChangeAddresses(num int) ([]cipher.Address, error) {
+	if !wlt.IsHD() {
+		return nil, errors.New("only the HD wallet has change addresses")
+	}
+	if wlt.IsEncrypted() {
+		return nil, ErrWalletEncrypted
+	}
+	return wlt.generateHDAddresses(ChangeChain, num)
+}
+
+// NewChangeAddress generates a new address receiving the change of a spend, it's on the
+// change chain of the HD wallet
+func (wlt *Wallet) NewChangeAddress() (cipher.Address, error) {
+	if wlt.IsEncrypted() {
+		return cipher.Address{}, ErrWalletEncrypted
+	}
+
+	if !wlt.IsHD() {
+		return wlt.GenerateAddresses(1)[0], nil
+	}
+
+	addrs, err := wlt.GenerateChangeAddresses(1)
+	if err != nil {
+		return cipher.Address{}, err
+	}
+	return addrs[0], nil
+}
+
+// XPub returns the extended public key of the HD wallet account and the account path, the
+// addresses of the account can be derived from it without the secret keys
+func (wlt Wallet) XPub() (string, string, error) {
+	if !wlt.IsHD() {
+		return "", "", errors.New("only the HD wallet has the extended public key")
+	}
+	if wlt.IsEncrypted() {
+		return "", "", ErrWalletEncrypted
+	}
+
+	key, path, err := wlt.accountKey()
+	if err != nil {
+		return "", "", err
+	}
+	return key.XPub(), FormatPath(path), nil
+}
+
+// MigrateToHD converts the deterministic wallet to HD, the keys of the new addresses are
+// derived by BIP44 from the mnemonic seed, so they can be recovered by other tools. The
+// existing addresses are kept, their keys can't be derived by BIP44, so the coins should
+// be moved to the new addresses. As many new addresses as the existing ones are generated.
+func (wlt *Wallet) MigrateToHD() error {
+	if wlt.IsHD() {
+		return errors.New("the wallet is already a HD wallet")
+	}
+
+	if wlt.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+
+	if !bip39.IsMnemonicValid(wlt.Meta["seed"]) {
+		return errors.New("the seed is not a bip39 mnemonic, the wallet can't be migrated")
+	}
+
+	m := Wallet{
+		Meta:    make(map[string]string, len(wlt.Meta)),
+		Entries: wlt.Entries,
+	}
+	for k, v := range wlt.Meta {
+		m.Meta[k] = v
+	}
+	OptHD(0)(&m)
+
+	n := len(m.Entries)
+	if n == 0 {
+		n = 1
+	}
+	if _, err := m.generateHDAddresses(ExternalChain, n); err != nil {
+		return err
+	}
+
+	*wlt = m
+	return nil
+}
+
+// accountKey derives the BIP44 account key from the mnemonic seed, returns the key and
+// its path
+func (wlt Wallet) accountKey() (*ExtendedKey, []uint32, error) {
+	coinType, err := wlt.metaUint32("coinType")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	account, err := wlt.metaUint32("account")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	master, err := NewMasterKey(bip39.NewSeed(wlt.Meta["seed"], ""))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	path := BIP44AccountPath(coinType, account)
+	key, err := master.Derive(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, path, nil
+}
+
+// generateHDAddresses derives num addresses of the chain after the last one derived
+func (wlt *Wallet) generateHDAddresses(chain uint32, num int) ([]cipher.Address, error) {
+	acct, path, err := wlt.accountKey()
+	if err != nil {
+		return nil, err
+	}
+
+	chainKey, err := acct.Child(chain)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := FormatPath(append(path, chain)) + "/"
+	var index uint32
+	for _, e := range wlt.Entries {
+		if !strings.HasPrefix(e.Path, prefix) {
+			continue
+		}
+		i, err := strconv.ParseUint(strings.TrimPrefix(e.Path, prefix), 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid path %s of address %s", e.Path, e.Address)
+		}
+		if uint32(i) >= index {
+			index = uint32(i) + 1
+		}
+	}
+
+	addrs := make([]cipher.Address, 0, num)
+	entries := make([]Entry, 0, num)
+	for ; len(addrs) < num; index++ {
+		if index >= HardenedOffset {
+			return nil, errors.New("no more addresses can be derived in the chain")
+		}
+
+		key, err := chainKey.Child(index)
+		if err == ErrInvalidChild {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		e := NewEntryFromKeypair(key.PubKey(), key.Secret)
+		e.Path = prefix + strconv.FormatUint(uint64(index), 10)
+		entries = append(entries, e)
+		addrs = append(addrs, e.Address)
+	}
+
+	wlt.Entries = append(wlt.Entries, entries...)
+	return addrs, nil
+}
+
+func (wlt Wallet) metaUint32(key string) (uint32, error) {
+	v, err := strconv.ParseUint(wlt.Meta[key], 10, 32)
+	if err != nil || uint32(v) >= HardenedOffset {
+		return 0, fmt.Errorf("invalid %s %q", key, wlt.Meta[key])
+	}
+	return uint32(v), nil
+}
+
+// GetAddresses returns all addresses in wallet
+func (wlt *Wallet) GetAddresses() []cipher.Address {
+	addrs := make([]cipher.Address, len(wlt.Entries))
+	for i, e := range wlt.Entries {
+		addrs[i] = e.Address
+	}
+	return addrs
+}
+
+// GetAddressSet returns address in map
+func (wlt *Wallet) GetAddressSet() map[cipher.Address]byte {
+	set := make(map[cipher.Address]byte)
+	for _, e := range wlt.Entries {
+		set[e.Address] = byte(1)
+	}
+	return set
+}
+
+// GetEntry returns entry of given address
+func (wlt *Wallet) GetEntry(a cipher.Address) (Entry, bool) {
+	for _, e := range wlt.Entries {
+		if e.Address == a {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
+
+// AddEntry adds new entry
+func (wlt *Wallet) AddEntry(entry Entry) error {
+	// dup check
+	for _, e := range wlt.Entries {
+		if e.Address == entry.Address {
+			return errors.New("duplicate address entry")
+		}
+	}
+
+	wlt.Entries = append(wlt.Entries, entry)
+	return nil
+}
+
+// Save persists wallet to disk
+func (wlt *Wallet) Save(dir string) error {
+	r := NewReadableWallet(*wlt)
+	return r.Save(filepath.Join(dir, wlt.GetFilename()))
+}
+
+// Load loads wallets from given dir
+func (wlt *Wallet) Load(dir string) error {
+	r := &ReadableWallet{}
+	if err := r.Load(filepath.Join(dir, wlt.GetFilename())); err != nil {
+		return err
+	}
+	r.Meta["filename"] = wlt.GetFilename()
+	*wlt = NewWalletFromReadable(r)
+	// the legacy wallet file is upgraded when it's saved
+	if wlt.GetVersion() == legacyVersion {
+		wlt.setFileMeta(wlt.createdTime())
+	}
+	return nil
+}
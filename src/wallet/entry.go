@@ -11,6 +11,8 @@ type Entry struct {
 	Address cipher.Address
 	Public  cipher.PubKey
 	Secret  cipher.SecKey
+	// BIP32 path the key is derived in, empty if the key isn't derived by BIP32
+	Path string
 }
 
 // NewEntryFromKeypair creates wallet entry base on key pairs
@@ -60,6 +62,7 @@ func NewEntryFromReadable(w *ReadableEntry) Entry {
 		Address: cipher.MustDecodeBase58Address(w.Address),
 		Public:  cipher.MustPubKeyFromHex(w.Public),
 		Secret:  s,
+		Path:    w.Path,
 	}
 }
 
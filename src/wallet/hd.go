@@ -0,0 +1,208 @@
+package wallet
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/cipher/base58"
+)
+
+const (
+	// HardenedOffset is the first child number of the hardened keys
+	HardenedOffset uint32 = 0x80000000
+
+	// DefaultCoinType is the BIP44 coin type of the derivation path, the one registered
+	// for Skycoin, so the keys match the ones derived by the Skycoin tools
+	DefaultCoinType uint32 = 8000
+
+	// ExternalChain is the BIP44 chain of the receiving addresses
+	ExternalChain uint32 = 0
+	// ChangeChain is the BIP44 chain of the change addresses
+	ChangeChain uint32 = 1
+
+	bip44Purpose uint32 = 44
+)
+
+var (
+	// ErrInvalidChild is returned if the derived key is invalid, the next child number
+	// should be used, which happens with a probability lower than 1 in 2^127
+	ErrInvalidChild = errors.New("the derived key is invalid")
+
+	// order of the secp256k1 curve
+	curveOrder, _ = new(big.Int).SetString("FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFEBAAEDCE6AF48A03BBFD25E8CD0364141", 16)
+	// hmac key of the master key
+	masterHMACKey = []byte("Bitcoin seed")
+	// version bytes of the serialized mainnet public key
+	xpubVersion = []byte{0x04, 0x88, 0xB2, 0x1E}
+)
+
+// ExtendedKey is a BIP32 private key extended with the chain code
+type ExtendedKey struct {
+	Secret    cipher.SecKey
+	ChainCode [32]byte
+	Depth     byte
+	// first 4 bytes of the hash160 of the parent public key, zero for the master key
+	ParentFP [4]byte
+	ChildNum uint32
+}
+
+// NewMasterKey creates the master key from the seed, which is the BIP39 seed of the
+// mnemonic
+func NewMasterKey(seed []byte) (*ExtendedKey, error) {
+	if len(seed) < 16 || len(seed) > 64 {
+		return nil, fmt.Errorf("invalid seed length %d, must be between 16 and 64 bytes", len(seed))
+	}
+
+	mac := hmac.New(sha512.New, masterHMACKey)
+	mac.Write(seed)
+	I := mac.Sum(nil)
+
+	k := new(big.Int).SetBytes(I[:32])
+	if k.Sign() == 0 || k.Cmp(curveOrder) >= 0 {
+		return nil, errors.New("the seed derives an invalid master key")
+	}
+
+	key := &ExtendedKey{Secret: cipher.NewSecKey(I[:32])}
+	copy(key.ChainCode[:], I[32:])
+	return key, nil
+}
+
+// Child derives the child key of number i, the key is hardened if i >= HardenedOffset
+func (k *ExtendedKey) Child(i uint32) (*ExtendedKey, error) {
+	pub := k.PubKey()
+
+	data := make([]byte, 0, 37)
+	if i >= HardenedOffset {
+		data = append(data, 0)
+		data = append(data, k.Secret[:]...)
+	} else {
+		data = append(data, pub[:]...)
+	}
+	data = binary.BigEndian.AppendUint32(data, i)
+
+	mac := hmac.New(sha512.New, k.ChainCode[:])
+	mac.Write(data)
+	I := mac.Sum(nil)
+
+	il := new(big.Int).SetBytes(I[:32])
+	if il.Cmp(curveOrder) >= 0 {
+		return nil, ErrInvalidChild
+	}
+
+	il.Add(il, new(big.Int).SetBytes(k.Secret[:]))
+	il.Mod(il, curveOrder)
+	if il.Sign() == 0 {
+		return nil, ErrInvalidChild
+	}
+
+	child := &ExtendedKey{
+		Depth:    k.Depth + 1,
+		ChildNum: i,
+	}
+	il.FillBytes(child.Secret[:])
+	copy(child.ChainCode[:], I[32:])
+	copy(child.ParentFP[:], fingerprint(pub))
+	return child, nil
+}
+
+// Derive derives the key of the path relative to k
+func (k *ExtendedKey) Derive(path []uint32) (*ExtendedKey, error) {
+	key := k
+	for _, i := range path {
+		var err error
+		if key, err = key.Child(i); err != nil {
+			return nil, err
+		}
+	}
+	return key, nil
+}
+
+// PubKey returns the compressed public key
+func (k *ExtendedKey) PubKey() cipher.PubKey {
+	return cipher.PubKeyFromSecKey(k.Secret)
+}
+
+// XPub returns the BIP32 serialized public key, from which the non-hardened children can
+// be derived without the secret keys
+func (k *ExtendedKey) XPub() string {
+	pub := k.PubKey()
+
+	b := make([]byte, 0, 82)
+	b = append(b, xpubVersion...)
+	b = append(b, k.Depth)
+	b = append(b, k.ParentFP[:]...)
+	b = binary.BigEndian.AppendUint32(b, k.ChildNum)
+	b = append(b, k.ChainCode[:]...)
+	b = append(b, pub[:]...)
+
+	checksum := cipher.DoubleSHA256(b)
+	b = append(b, checksum[:4]...)
+	return base58.Hex2Base58String(b)
+}
+
+// BIP44Path returns the path m/44'/coinType'/account'/change/index
+func BIP44Path(coinType, account, change, index uint32) []uint32 {
+	return append(BIP44AccountPath(coinType, account), change, index)
+}
+
+// BIP44AccountPath returns the path of the account m/44'/coinType'/account'
+func BIP44AccountPath(coinType, account uint32) []uint32 {
+	return []uint32{
+		bip44Purpose + HardenedOffset,
+		coinType + HardenedOffset,
+		account + HardenedOffset,
+	}
+}
+
+// FormatPath formats the path as m/44'/8000'/0'/0/1
+func FormatPath(path []uint32) string {
+	s := "m"
+	for _, i := range path {
+		if i >= HardenedOffset {
+			s += fmt.Sprintf("/%d'", i-HardenedOffset)
+		} else {
+			s += fmt.Sprintf("/%d", i)
+		}
+	}
+	return s
+}
+
+// ParsePath parses the path formatted by FormatPath, the hardened child numbers are
+// marked by ' or h
+func ParsePath(s string) ([]uint32, error) {
+	parts := strings.Split(s, "/")
+	if parts[0] != "m" {
+		return nil, fmt.Errorf("invalid path %q, must start with m", s)
+	}
+
+	path := make([]uint32, 0, len(parts)-1)
+	for _, p := range parts[1:] {
+		var offset uint32
+		if strings.HasSuffix(p, "'") || strings.HasSuffix(p, "h") {
+			offset = HardenedOffset
+			p = p[:len(p)-1]
+		}
+
+		n, err := strconv.ParseUint(p, 10, 32)
+		if err != nil || uint32(n) >= HardenedOffset {
+			return nil, fmt.Errorf("invalid path %q", s)
+		}
+		path = append(path, uint32(n)+offset)
+	}
+	return path, nil
+}
+
+// fingerprint returns the first 4 bytes of the hash160 of the public key
+func fingerprint(pub cipher.PubKey) []byte {
+	h := sha256.Sum256(pub[:])
+	r := cipher.HashRipemd160(h[:])
+	return r[:4]
+}
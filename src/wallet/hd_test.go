@@ -0,0 +1,181 @@
+package wallet
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	bip39 "github.com/skycoin/skycoin/src/cipher/go-bip39"
+)
+
+func TestExtendedKeyDerive(t *testing.T) {
+	// test vector 1 of BIP32
+	seed, err := hex.DecodeString("000102030405060708090a0b0c0d0e0f")
+	require.NoError(t, err)
+
+	master, err := NewMasterKey(seed)
+	require.NoError(t, err)
+
+	tt := []struct {
+		path string
+		xpub string
+	}{
+		{"m", "xpub661MyMwAqRbcFtXgS5sYJABqqG9YLmC4Q1Rdap9gSE8NqtwybGhePY2gZ29ESFjqJoCu1Rupje8YtGqsefD265TMg7usUDFdp6W1EGMcet8"},
+		{"m/0'", "xpub68Gmy5EdvgibQVfPdqkBBCHxA5htiqg55crXYuXoQRKfDBFA1WEjWgP6LHhwBZeNK1VTsfTFUHCdrfp1bgwQ9xv5ski8PX9rL2dZXvgGDnw"},
+		{"m/0'/1", "xpub6ASuArnXKPbfEwhqN6e3mwBcDTgzisQN1wXN9BJcM47sSikHjJf3UFHKkNAWbWMiGj7Wf5uMash7SyYq527Hqck2AxYysAA7xmALppuCkwQ"},
+		{"m/0h/1/2h", "xpub6D4BDPcP2GT577Vvch3R8wDkScZWzQzMMUm3PWbmWvVJrZwQY4VUNgqFJPMM3No2dFDFGTsxxpG5uJh7n7epu4trkrX7x7DogT5Uv6fcLW5"},
+		{"m/0'/1/2'/2", "xpub6FHa3pjLCk84BayeJxFW2SP4XRrFd1JYnxeLeU8EqN3vDfZmbqBqaGJAyiLjTAwm6ZLRQUMv1ZACTj37sR62cfN7fe5JnJ7dh8zL4fiyLHV"},
+		{"m/0'/1/2'/2/1000000000", "xpub6H1LXWLaKsWFhvm6RVpEL9P4KfRZSW7abD2ttkWP3SSQvnyA8FSVqNTEcYFgJS2UaFcxupHiYkro49S8yGasTvXEYBVPamhGW6cFJodrTHy"},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.path, func(t *testing.T) {
+			path, err := ParsePath(tc.path)
+			require.NoError(t, err)
+
+			key, err := master.Derive(path)
+			require.NoError(t, err)
+			assert.Equal(t, tc.xpub, key.XPub())
+		})
+	}
+
+	for _, s := range []string{"", "0/1", "m/", "m/x", "m/2147483648"} {
+		_, err := ParsePath(s)
+		assert.Error(t, err, s)
+	}
+
+	assert.Equal(t, "m/44'/8000'/0'/1/5", FormatPath(BIP44Path(DefaultCoinType, 0, ChangeChain, 5)))
+}
+
+func TestHDWallet(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	_, err := NewWallet("a.wlt", OptSeed("not a mnemonic"), OptHD(0))
+	assert.Error(t, err)
+
+	w, err := NewWallet("a.wlt", OptSeed(mnemonic), OptHD(0))
+	require.NoError(t, err)
+	require.NoError(t, w.Validate())
+	assert.True(t, w.IsHD())
+
+	addrs := w.GenerateAddresses(2)
+	require.Len(t, addrs, 2)
+	change, err := w.GenerateChangeAddresses(1)
+	require.NoError(t, err)
+	require.Len(t, change, 1)
+	addrs = append(addrs, w.GenerateAddresses(1)...)
+
+	master, err := NewMasterKey(bip39.NewSeed(mnemonic, ""))
+	require.NoError(t, err)
+	paths := []string{"m/44'/8000'/0'/0/0", "m/44'/8000'/0'/0/1", "m/44'/8000'/0'/1/0", "m/44'/8000'/0'/0/2"}
+	require.Len(t, w.Entries, len(paths))
+	for i, e := range w.Entries {
+		assert.Equal(t, paths[i], e.Path)
+		require.NoError(t, e.Verify())
+
+		path, err := ParsePath(e.Path)
+		require.NoError(t, err)
+		key, err := master.Derive(path)
+		require.NoError(t, err)
+		assert.Equal(t, key.Secret, e.Secret)
+	}
+	assert.Equal(t, []cipher.Address{w.Entries[0].Address, w.Entries[1].Address, w.Entries[3].Address}, addrs)
+
+	xpub, path, err := w.XPub()
+	require.NoError(t, err)
+	assert.Equal(t, "m/44'/8000'/0'", path)
+	acct, err := master.Derive(BIP44AccountPath(DefaultCoinType, 0))
+	require.NoError(t, err)
+	assert.Equal(t, acct.XPub(), xpub)
+
+	// the paths are kept in the wallet file
+	r := NewReadableWallet(*w)
+	w2 := NewWalletFromReadable(r)
+	assert.Equal(t, w.Entries, w2.Entries)
+	assert.NotEqual(t, addrs[2], w2.GenerateAddresses(1)[0])
+	assert.Equal(t, "m/44'/8000'/0'/0/3", w2.Entries[4].Path)
+}
+
+func TestMigrateToHD(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := NewWallet("legacy.wlt", OptSeed("not a mnemonic"))
+	require.NoError(t, err)
+	w.GenerateAddresses(1)
+	wlts := Wallets{}
+	require.NoError(t, wlts.Add(*w))
+	require.NoError(t, w.Save(dir))
+	assert.Error(t, wlts.MigrateToHD("legacy.wlt", dir))
+
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	w, err = NewWallet("a.wlt", OptSeed(mnemonic))
+	require.NoError(t, err)
+	legacy := w.GenerateAddresses(2)
+	require.NoError(t, wlts.Add(*w))
+	require.NoError(t, w.Save(dir))
+
+	require.NoError(t, wlts.MigrateToHD("a.wlt", dir))
+	assert.Error(t, wlts.MigrateToHD("a.wlt", dir))
+
+	loaded, err := Load(filepath.Join(dir, "a.wlt"))
+	require.NoError(t, err)
+	assert.True(t, loaded.IsHD())
+	require.Len(t, loaded.Entries, 4)
+	assert.Equal(t, legacy, loaded.GetAddresses()[:2])
+	assert.Empty(t, loaded.Entries[0].Path)
+	assert.Equal(t, "m/44'/8000'/0'/0/0", loaded.Entries[2].Path)
+	assert.Equal(t, "m/44'/8000'/0'/0/1", loaded.Entries[3].Path)
+
+	// the file before the migration is backed up
+	bks, err := ioutil.ReadDir(filepath.Join(dir, "backup"))
+	require.NoError(t, err)
+	require.Len(t, bks, 1)
+	old, err := LoadReadableWallet(filepath.Join(dir, "backup", bks[0].Name()))
+	require.NoError(t, err)
+	assert.Equal(t, DeterministicWalletType, old.Meta["type"])
+	assert.Len(t, old.Entries, 2)
+}
+
+func TestNewChangeAddress(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+
+	w, err := NewWallet("a.wlt", OptSeed(mnemonic), OptHD(0))
+	require.NoError(t, err)
+	assert.True(t, w.FreshChange())
+	w.GenerateAddresses(1)
+
+	a, err := w.NewChangeAddress()
+	require.NoError(t, err)
+	e, ok := w.GetEntry(a)
+	require.True(t, ok)
+	assert.Equal(t, "m/44'/8000'/0'/1/0", e.Path)
+
+	// the deterministic wallet has no change chain
+	d, err := NewWallet("b.wlt", OptSeed("seed"), OptFreshChange(false))
+	require.NoError(t, err)
+	assert.False(t, d.FreshChange())
+	d.GenerateAddresses(1)
+	a, err = d.NewChangeAddress()
+	require.NoError(t, err)
+	require.Len(t, d.Entries, 2)
+	assert.Equal(t, d.Entries[1].Address, a)
+
+	d.SetFreshChange(true)
+	assert.True(t, d.FreshChange())
+
+	// the wallets created before the setting reuse the spent address
+	delete(d.Meta, "freshChange")
+	assert.False(t, d.FreshChange())
+
+	require.NoError(t, d.Encrypt([]byte("pwd")))
+	_, err = d.NewChangeAddress()
+	assert.Equal(t, ErrWalletEncrypted, err)
+}
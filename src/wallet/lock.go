@@ -0,0 +1,142 @@
+package wallet
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultUnlockTimeout is how long the wallet stays unlocked if the timeout is not set
+	DefaultUnlockTimeout = 5 * time.Minute
+	// MaxUnlockTimeout is the longest time the wallet can stay unlocked
+	MaxUnlockTimeout = 24 * time.Hour
+)
+
+// ErrWalletLocked is returned if the password of the encrypted wallet is not set and the
+// wallet is not unlocked
+var ErrWalletLocked = errors.New("the wallet is encrypted and locked, the password is required")
+
+// unlockedWallet is the decrypted wallet kept in memory till the timeout
+type unlockedWallet struct {
+	wallet   *Wallet
+	password []byte
+	timer    *time.Timer
+}
+
+// Unlocked keeps the decrypted wallets in memory till the timeouts, the unlocked wallets
+// sign the transactions and generate the addresses without the password
+type Unlocked struct {
+	mu      sync.Mutex
+	wallets map[string]*unlockedWallet
+}
+
+// NewUnlocked creates the store of the unlocked wallets
+func NewUnlocked() *Unlocked {
+	return &Unlocked{
+		wallets: make(map[string]*unlockedWallet),
+	}
+}
+
+// Unlock keeps the wallet decrypted by the password in memory till the timeout, the
+// wallet unlocked before is locked first
+func (ul *Unlocked) Unlock(wltID string, w *Wallet, password []byte, timeout time.Duration) error {
+	if timeout <= 0 || timeout > MaxUnlockTimeout {
+		return fmt.Errorf("timeout must be between 0 and %v", MaxUnlockTimeout)
+	}
+
+	if w.IsEncrypted() {
+		return ErrWalletEncrypted
+	}
+
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	ul.lock(wltID)
+
+	u := &unlockedWallet{
+		wallet:   w.Copy(),
+		password: append([]byte(nil), password...),
+	}
+	u.timer = time.AfterFunc(timeout, func() {
+		ul.mu.Lock()
+		defer ul.mu.Unlock()
+		if ul.wallets[wltID] == u {
+			ul.lock(wltID)
+			logger.Info("Wallet %s locked after the timeout", wltID)
+		}
+	})
+	ul.wallets[wltID] = u
+	return nil
+}
+
+// Lock removes the decrypted wallet from memory
+func (ul *Unlocked) Lock(wltID string) {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	ul.lock(wltID)
+}
+
+// LockAll locks all the unlocked wallets
+func (ul *Unlocked) LockAll() {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	for id := range ul.wallets {
+		ul.lock(id)
+	}
+}
+
+func (ul *Unlocked) lock(wltID string) {
+	u, ok := ul.wallets[wltID]
+	if !ok {
+		return
+	}
+
+	u.timer.Stop()
+	for i := range u.password {
+		u.password[i] = 0
+	}
+	delete(ul.wallets, wltID)
+}
+
+// IsUnlocked returns true if the decrypted wallet is in memory
+func (ul *Unlocked) IsUnlocked(wltID string) bool {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	_, ok := ul.wallets[wltID]
+	return ok
+}
+
+// Get returns a copy of the unlocked wallet and its password, ErrWalletLocked is returned
+// if the wallet is not unlocked
+func (ul *Unlocked) Get(wltID string) (*Wallet, []byte, error) {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	u, ok := ul.wallets[wltID]
+	if !ok {
+		return nil, nil, ErrWalletLocked
+	}
+	return u.wallet.Copy(), append([]byte(nil), u.password...), nil
+}
+
+// Update runs f with the unlocked wallet, it's not run if the wallet is locked. The
+// changes of the encrypted wallet are applied to the unlocked copy by f.
+func (ul *Unlocked) Update(wltID string, f func(w *Wallet)) {
+	ul.mu.Lock()
+	defer ul.mu.Unlock()
+	if u, ok := ul.wallets[wltID]; ok {
+		f(u.wallet)
+	}
+}
+
+// Copy copies the meta data and the entries of the wallet
+func (wlt Wallet) Copy() *Wallet {
+	c := &Wallet{
+		Meta:    make(map[string]string, len(wlt.Meta)),
+		Entries: append([]Entry(nil), wlt.Entries...),
+	}
+	for k, v := range wlt.Meta {
+		c.Meta[k] = v
+	}
+	return c
+}
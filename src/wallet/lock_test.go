@@ -0,0 +1,60 @@
+package wallet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnlocked(t *testing.T) {
+	ul := NewUnlocked()
+	w := &Wallet{Meta: map[string]string{"seed": "secret seed"}}
+	password := []byte("pwd")
+
+	_, _, err := ul.Get("a.wlt")
+	assert.Equal(t, ErrWalletLocked, err)
+
+	assert.Error(t, ul.Unlock("a.wlt", w, password, 0))
+	assert.Error(t, ul.Unlock("a.wlt", w, password, MaxUnlockTimeout+time.Second))
+	assert.Equal(t, ErrWalletEncrypted, ul.Unlock("a.wlt", &Wallet{Meta: map[string]string{"encrypted": "true"}}, password, time.Minute))
+	assert.False(t, ul.IsUnlocked("a.wlt"))
+
+	require.NoError(t, ul.Unlock("a.wlt", w, password, time.Minute))
+	assert.True(t, ul.IsUnlocked("a.wlt"))
+
+	// the unlocked wallet and the password are copies
+	w.Meta["seed"] = "changed"
+	password[0] = 'x'
+	u, pwd, err := ul.Get("a.wlt")
+	require.NoError(t, err)
+	assert.Equal(t, "secret seed", u.Meta["seed"])
+	assert.Equal(t, []byte("pwd"), pwd)
+
+	u.Meta["seed"] = "changed"
+	ul.Update("a.wlt", func(w *Wallet) {
+		w.Meta["label"] = "a"
+	})
+	u, _, err = ul.Get("a.wlt")
+	require.NoError(t, err)
+	assert.Equal(t, "secret seed", u.Meta["seed"])
+	assert.Equal(t, "a", u.Meta["label"])
+
+	ul.Lock("a.wlt")
+	assert.False(t, ul.IsUnlocked("a.wlt"))
+	ul.Update("a.wlt", func(w *Wallet) {
+		t.Fatal("the locked wallet is updated")
+	})
+
+	require.NoError(t, ul.Unlock("a.wlt", w, password, time.Minute))
+	require.NoError(t, ul.Unlock("b.wlt", w, password, time.Minute))
+	ul.LockAll()
+	assert.False(t, ul.IsUnlocked("a.wlt"))
+	assert.False(t, ul.IsUnlocked("b.wlt"))
+
+	// the wallet is locked after the timeout
+	require.NoError(t, ul.Unlock("a.wlt", w, password, 10*time.Millisecond))
+	time.Sleep(100 * time.Millisecond)
+	assert.False(t, ul.IsUnlocked("a.wlt"))
+}
@@ -0,0 +1,157 @@
+package wallet
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// Multisig is an m-of-n multisig address kept by the wallet, the wallet may have some or
+// none of the secret keys of the public keys
+type Multisig struct {
+	Address  cipher.Address
+	Required int
+	// sorted by cipher.SortPubKeys
+	PubKeys []cipher.PubKey
+}
+
+// ReadableMultisig is the json of Multisig
+type ReadableMultisig struct {
+	Address  string   `json:"address"`
+	Required int      `json:"required"`
+	PubKeys  []string `json:"pubkeys"`
+}
+
+// NewMultisig creates the multisig address spendable by required signatures of the keys
+func NewMultisig(required int, pubKeys []cipher.PubKey) (Multisig, error) {
+	keys := cipher.SortPubKeys(pubKeys)
+	addr, err := cipher.MultisigAddress(required, keys)
+	if err != nil {
+		return Multisig{}, err
+	}
+
+	return Multisig{
+		Address:  addr,
+		Required: required,
+		PubKeys:  keys,
+	}, nil
+}
+
+// NewReadableMultisig creates the readable multisig
+func NewReadableMultisig(ms Multisig) ReadableMultisig {
+	rm := ReadableMultisig{
+		Address:  ms.Address.String(),
+		Required: ms.Required,
+		PubKeys:  make([]string, len(ms.PubKeys)),
+	}
+	for i, k := range ms.PubKeys {
+		rm.PubKeys[i] = k.Hex()
+	}
+	return rm
+}
+
+// ToMultisig converts the readable multisig, the address must match the public keys
+func (rm ReadableMultisig) ToMultisig() (Multisig, error) {
+	keys := make([]cipher.PubKey, len(rm.PubKeys))
+	for i, s := range rm.PubKeys {
+		k, err := cipher.PubKeyFromHex(s)
+		if err != nil {
+			return Multisig{}, fmt.Errorf("invalid public key %s: %v", s, err)
+		}
+		keys[i] = k
+	}
+
+	addr, err := cipher.DecodeBase58Address(rm.Address)
+	if err != nil {
+		return Multisig{}, fmt.Errorf("invalid multisig address %s: %v", rm.Address, err)
+	}
+
+	if err := addr.VerifyMultisig(rm.Required, keys); err != nil {
+		return Multisig{}, fmt.Errorf("invalid multisig address %s: %v", rm.Address, err)
+	}
+
+	return Multisig{
+		Address:  addr,
+		Required: rm.Required,
+		PubKeys:  keys,
+	}, nil
+}
+
+// Multisigs returns the multisig addresses of the wallet, they're kept in the meta
+func (wlt Wallet) Multisigs() ([]Multisig, error) {
+	s := wlt.Meta["multisig"]
+	if s == "" {
+		return nil, nil
+	}
+
+	var rms []ReadableMultisig
+	if err := json.Unmarshal([]byte(s), &rms); err != nil {
+		return nil, fmt.Errorf("decode multisig addresses failed: %v", err)
+	}
+
+	mss := make([]Multisig, len(rms))
+	for i, rm := range rms {
+		ms, err := rm.ToMultisig()
+		if err != nil {
+			return nil, err
+		}
+		mss[i] = ms
+	}
+	return mss, nil
+}
+
+// GetMultisig returns the multisig address of the wallet
+func (wlt Wallet) GetMultisig(addr cipher.Address) (Multisig, bool) {
+	mss, err := wlt.Multisigs()
+	if err != nil {
+		return Multisig{}, false
+	}
+
+	for _, ms := range mss {
+		if ms.Address == addr {
+			return ms, true
+		}
+	}
+	return Multisig{}, false
+}
+
+// AddMultisig adds the multisig address spendable by required signatures of the keys
+func (wlt *Wallet) AddMultisig(required int, pubKeys []cipher.PubKey) (Multisig, error) {
+	ms, err := NewMultisig(required, pubKeys)
+	if err != nil {
+		return Multisig{}, err
+	}
+
+	mss, err := wlt.Multisigs()
+	if err != nil {
+		return Multisig{}, err
+	}
+
+	rms := make([]ReadableMultisig, 0, len(mss)+1)
+	for _, m := range mss {
+		if m.Address == ms.Address {
+			return Multisig{}, fmt.Errorf("multisig address %s already exists", ms.Address)
+		}
+		rms = append(rms, NewReadableMultisig(m))
+	}
+	rms = append(rms, NewReadableMultisig(ms))
+
+	b, err := json.Marshal(rms)
+	if err != nil {
+		return Multisig{}, err
+	}
+
+	wlt.Meta["multisig"] = string(b)
+	return ms, nil
+}
+
+// GetEntryByPubKey returns the entry of the public key
+func (wlt *Wallet) GetEntryByPubKey(pub cipher.PubKey) (Entry, bool) {
+	for _, e := range wlt.Entries {
+		if e.Public == pub {
+			return e, true
+		}
+	}
+	return Entry{}, false
+}
@@ -0,0 +1,72 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestWalletAddMultisig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := NewWallet("a.wlt", OptSeed("multisig seed"))
+	require.NoError(t, err)
+	own := w.GenerateAddresses(1)
+	e, ok := w.GetEntry(own[0])
+	require.True(t, ok)
+
+	p1, _ := cipher.GenerateKeyPair()
+	p2, _ := cipher.GenerateKeyPair()
+	keys := []cipher.PubKey{p1, e.Public, p2}
+
+	ms, err := w.AddMultisig(2, keys)
+	require.NoError(t, err)
+	assert.True(t, ms.Address.IsMultisig())
+	assert.Equal(t, cipher.SortPubKeys(keys), ms.PubKeys)
+
+	_, err = w.AddMultisig(2, []cipher.PubKey{p2, p1, e.Public})
+	assert.Error(t, err)
+	_, err = w.AddMultisig(4, keys)
+	assert.Error(t, err)
+
+	// the multisig address is not a wallet entry
+	assert.Len(t, w.Entries, 1)
+
+	entry, ok := w.GetEntryByPubKey(e.Public)
+	require.True(t, ok)
+	assert.Equal(t, e, entry)
+	_, ok = w.GetEntryByPubKey(p1)
+	assert.False(t, ok)
+
+	require.NoError(t, w.Save(dir))
+	loaded, err := Load(filepath.Join(dir, "a.wlt"))
+	require.NoError(t, err)
+	require.NoError(t, loaded.Validate())
+
+	mss, err := loaded.Multisigs()
+	require.NoError(t, err)
+	assert.Equal(t, []Multisig{ms}, mss)
+	got, ok := loaded.GetMultisig(ms.Address)
+	require.True(t, ok)
+	assert.Equal(t, ms, got)
+
+	// the multisig addresses are kept by the encrypted wallet
+	require.NoError(t, loaded.Encrypt([]byte("pwd")))
+	mss, err = loaded.Multisigs()
+	require.NoError(t, err)
+	assert.Equal(t, []Multisig{ms}, mss)
+
+	// the address must match the keys
+	rm := NewReadableMultisig(ms)
+	rm.Required = 1
+	_, err = rm.ToMultisig()
+	assert.Error(t, err)
+}
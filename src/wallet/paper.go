@@ -0,0 +1,101 @@
+package wallet
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	bip39 "github.com/skycoin/skycoin/src/cipher/go-bip39"
+)
+
+// PaperWallet is a single address with its seed and keys, to be printed and kept offline.
+// It's only kept in memory, the seed restores the address as the first address of a
+// deterministic wallet, and the secret key can be swept into a wallet.
+type PaperWallet struct {
+	Seed    string
+	Address cipher.Address
+	Public  cipher.PubKey
+	Secret  cipher.SecKey
+}
+
+// ReadablePaperWallet is the json of PaperWallet, the QR payloads are the texts encoded in
+// the printed QR codes
+type ReadablePaperWallet struct {
+	Seed      string `json:"seed"`
+	Address   string `json:"address"`
+	PublicKey string `json:"public_key"`
+	SecretKey string `json:"secret_key"`
+	AddressQR string `json:"address_qr"`
+	SecretQR  string `json:"secret_qr"`
+}
+
+// NewPaperWallet creates the paper wallet of the seed, a bip39 mnemonic is generated if
+// the seed is empty
+func NewPaperWallet(seed string) (PaperWallet, error) {
+	if seed == "" {
+		entropy, err := bip39.NewEntropy(128)
+		if err != nil {
+			return PaperWallet{}, fmt.Errorf("generate bip39 entropy failed, err:%v", err)
+		}
+
+		seed, err = bip39.NewMnemonic(entropy)
+		if err != nil {
+			return PaperWallet{}, fmt.Errorf("generate bip39 seed failed, err:%v", err)
+		}
+	}
+
+	// the same key as the first address of the deterministic wallet
+	_, seckeys := cipher.GenerateDeterministicKeyPairsSeed([]byte(seed), 1)
+	if len(seckeys) != 1 {
+		return PaperWallet{}, errors.New("generate paper wallet key failed")
+	}
+
+	sec := seckeys[0]
+	pub := cipher.PubKeyFromSecKey(sec)
+	return PaperWallet{
+		Seed:    seed,
+		Address: cipher.AddressFromPubKey(pub),
+		Public:  pub,
+		Secret:  sec,
+	}, nil
+}
+
+// AddressQR returns the payload of the QR code of the address, it's shared to receive coins
+func (pw PaperWallet) AddressQR() string {
+	return pw.Address.String()
+}
+
+// SecretQR returns the payload of the QR code of the secret key, it's scanned to sweep the
+// coins into a wallet
+func (pw PaperWallet) SecretQR() string {
+	return pw.Secret.Hex()
+}
+
+// ToReadable returns the readable paper wallet
+func (pw PaperWallet) ToReadable() ReadablePaperWallet {
+	return ReadablePaperWallet{
+		Seed:      pw.Seed,
+		Address:   pw.Address.String(),
+		PublicKey: pw.Public.Hex(),
+		SecretKey: pw.Secret.Hex(),
+		AddressQR: pw.AddressQR(),
+		SecretQR:  pw.SecretQR(),
+	}
+}
+
+// Printable returns the text of the paper wallet to print, the public half is printed apart
+// from the secret half so it can be cut off and shared
+func (pw PaperWallet) Printable() string {
+	var b bytes.Buffer
+	fmt.Fprintln(&b, "==================== PUBLIC: SHARE TO RECEIVE ====================")
+	fmt.Fprintf(&b, "Address:     %s\n", pw.Address)
+	fmt.Fprintf(&b, "QR payload:  %s\n", pw.AddressQR())
+	fmt.Fprintln(&b, "-------------------------- cut here ------------------------------")
+	fmt.Fprintln(&b, "=================== SECRET: NEVER SHARE OR SCAN ONLINE ===========")
+	fmt.Fprintf(&b, "Seed:        %s\n", pw.Seed)
+	fmt.Fprintf(&b, "Secret key:  %s\n", pw.Secret.Hex())
+	fmt.Fprintf(&b, "QR payload:  %s\n", pw.SecretQR())
+	fmt.Fprintln(&b, "==================================================================")
+	return b.String()
+}
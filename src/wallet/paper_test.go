@@ -0,0 +1,42 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	bip39 "github.com/skycoin/skycoin/src/cipher/go-bip39"
+)
+
+func TestNewPaperWallet(t *testing.T) {
+	pw, err := NewPaperWallet("")
+	require.NoError(t, err)
+	assert.True(t, bip39.IsMnemonicValid(pw.Seed))
+	require.NoError(t, pw.Secret.Verify())
+	assert.Equal(t, pw.Public, cipher.PubKeyFromSecKey(pw.Secret))
+	assert.Equal(t, pw.Address, cipher.AddressFromPubKey(pw.Public))
+
+	other, err := NewPaperWallet("")
+	require.NoError(t, err)
+	assert.NotEqual(t, pw.Seed, other.Seed)
+
+	// the seed restores the address in a deterministic wallet
+	pw, err = NewPaperWallet("paper seed")
+	require.NoError(t, err)
+	w, err := NewWallet("a.wlt", OptSeed("paper seed"))
+	require.NoError(t, err)
+	assert.Equal(t, w.GenerateAddresses(1)[0], pw.Address)
+
+	rpw := pw.ToReadable()
+	assert.Equal(t, pw.Address.String(), rpw.AddressQR)
+	sec, err := cipher.SecKeyFromHex(rpw.SecretQR)
+	require.NoError(t, err)
+	assert.Equal(t, pw.Secret, sec)
+
+	text := pw.Printable()
+	assert.Contains(t, text, pw.Address.String())
+	assert.Contains(t, text, pw.Seed)
+	assert.Contains(t, text, pw.Secret.Hex())
+}
@@ -0,0 +1,168 @@
+package wallet
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+// Rules of the spending policy, set in PolicyError
+const (
+	PolicyMaxCoinsPerTxn      = "max_coins_per_txn"
+	PolicyMaxCoinsPerDay      = "max_coins_per_day"
+	PolicyAllowedDestinations = "allowed_destinations"
+)
+
+// Policy limits the spends signed by the node for the wallet, e.g. a hot wallet. The zero
+// limits and the empty allowlist are not enforced. The coins sent are the coins of the
+// outputs to the addresses not in the wallet, the change is not counted.
+type Policy struct {
+	// in droplets
+	MaxCoinsPerTxn uint64 `json:"max_coins_per_txn,omitempty"`
+	// in droplets, the coins sent in the past 24 hours
+	MaxCoinsPerDay uint64 `json:"max_coins_per_day,omitempty"`
+	// the addresses the coins can be sent to
+	AllowedDestinations []string `json:"allowed_destinations,omitempty"`
+}
+
+// PolicyError is returned if the spend violates the rule of the policy, Amount is the coins
+// that would be sent, including the coins sent in the past day for the daily limit
+type PolicyError struct {
+	Rule    string `json:"rule"`
+	Limit   uint64 `json:"limit,omitempty"`
+	Amount  uint64 `json:"amount,omitempty"`
+	Address string `json:"address,omitempty"`
+}
+
+func (e PolicyError) Error() string {
+	if e.Rule == PolicyAllowedDestinations {
+		return fmt.Sprintf("policy violation: %s is not an allowed destination", e.Address)
+	}
+	return fmt.Sprintf("policy violation: %s is %d, sending %d", e.Rule, e.Limit, e.Amount)
+}
+
+// IsEmpty returns true if no rule is set
+func (p Policy) IsEmpty() bool {
+	return p.MaxCoinsPerTxn == 0 && p.MaxCoinsPerDay == 0 && len(p.AllowedDestinations) == 0
+}
+
+// Validate checks the addresses of the allowlist
+func (p Policy) Validate() error {
+	for _, s := range p.AllowedDestinations {
+		if _, err := cipher.DecodeBase58Address(s); err != nil {
+			return fmt.Errorf("invalid allowed destination %s: %v", s, err)
+		}
+	}
+	return nil
+}
+
+// Policy returns the spending policy of the wallet, it's kept in the meta
+func (wlt Wallet) Policy() (Policy, error) {
+	var p Policy
+	s := wlt.Meta["policy"]
+	if s == "" {
+		return p, nil
+	}
+
+	if err := json.Unmarshal([]byte(s), &p); err != nil {
+		return Policy{}, fmt.Errorf("decode policy failed: %v", err)
+	}
+	return p, nil
+}
+
+// SetPolicy sets the spending policy of the wallet, the empty policy removes it
+func (wlt *Wallet) SetPolicy(p Policy) error {
+	if p.IsEmpty() {
+		delete(wlt.Meta, "policy")
+		return nil
+	}
+
+	if err := p.Validate(); err != nil {
+		return err
+	}
+
+	b, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	wlt.Meta["policy"] = string(b)
+	return nil
+}
+
+// SentCoins returns the coins of the txn outputs to the addresses not in the wallet
+func (wlt Wallet) SentCoins(txn coin.Transaction) uint64 {
+	var coins uint64
+	for _, o := range txn.Out {
+		if !wlt.ownsAddress(o.Address) {
+			coins += o.Coins
+		}
+	}
+	return coins
+}
+
+// CheckPolicy checks that the txn is allowed by the spending policy of the wallet,
+// sentToday is the coins sent by the wallet in the past 24 hours. Returns PolicyError if
+// a rule is violated.
+func (wlt Wallet) CheckPolicy(txn coin.Transaction, sentToday uint64) error {
+	p, err := wlt.Policy()
+	if err != nil {
+		return err
+	}
+
+	if p.IsEmpty() {
+		return nil
+	}
+
+	allowed := make(map[string]struct{}, len(p.AllowedDestinations))
+	for _, s := range p.AllowedDestinations {
+		allowed[s] = struct{}{}
+	}
+
+	for _, o := range txn.Out {
+		if len(allowed) == 0 || wlt.ownsAddress(o.Address) {
+			continue
+		}
+
+		if _, ok := allowed[o.Address.String()]; !ok {
+			return PolicyError{
+				Rule:    PolicyAllowedDestinations,
+				Address: o.Address.String(),
+			}
+		}
+	}
+
+	sent := wlt.SentCoins(txn)
+	if p.MaxCoinsPerTxn != 0 && sent > p.MaxCoinsPerTxn {
+		return PolicyError{
+			Rule:   PolicyMaxCoinsPerTxn,
+			Limit:  p.MaxCoinsPerTxn,
+			Amount: sent,
+		}
+	}
+
+	if p.MaxCoinsPerDay != 0 && sentToday+sent > p.MaxCoinsPerDay {
+		return PolicyError{
+			Rule:   PolicyMaxCoinsPerDay,
+			Limit:  p.MaxCoinsPerDay,
+			Amount: sentToday + sent,
+		}
+	}
+
+	return nil
+}
+
+// IsPolicyError returns true if the err is the violation of a spending policy
+func IsPolicyError(err error) bool {
+	_, ok := err.(PolicyError)
+	return ok
+}
+
+func (wlt Wallet) ownsAddress(addr cipher.Address) bool {
+	if _, ok := wlt.GetEntry(addr); ok {
+		return true
+	}
+	_, ok := wlt.GetMultisig(addr)
+	return ok
+}
@@ -0,0 +1,59 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/coin"
+)
+
+func TestCheckPolicy(t *testing.T) {
+	w, err := NewWallet("a.wlt", OptSeed("policy seed"))
+	require.NoError(t, err)
+	own := w.GenerateAddresses(2)
+
+	xPub, _ := cipher.GenerateKeyPair()
+	x := cipher.AddressFromPubKey(xPub)
+	yPub, _ := cipher.GenerateKeyPair()
+	y := cipher.AddressFromPubKey(yPub)
+
+	// sends 10 to x, the change goes to the wallet
+	txn := coin.Transaction{}
+	txn.PushOutput(x, 10e6, 10)
+	txn.PushOutput(own[1], 90e6, 10)
+	assert.Equal(t, uint64(10e6), w.SentCoins(txn))
+
+	// no policy
+	require.NoError(t, w.CheckPolicy(txn, 0))
+
+	require.Error(t, w.SetPolicy(Policy{AllowedDestinations: []string{"bad"}}))
+
+	require.NoError(t, w.SetPolicy(Policy{MaxCoinsPerTxn: 5e6}))
+	err = w.CheckPolicy(txn, 0)
+	require.True(t, IsPolicyError(err))
+	assert.Equal(t, PolicyError{Rule: PolicyMaxCoinsPerTxn, Limit: 5e6, Amount: 10e6}, err)
+
+	require.NoError(t, w.SetPolicy(Policy{MaxCoinsPerDay: 25e6}))
+	require.NoError(t, w.CheckPolicy(txn, 15e6))
+	err = w.CheckPolicy(txn, 16e6)
+	assert.Equal(t, PolicyError{Rule: PolicyMaxCoinsPerDay, Limit: 25e6, Amount: 26e6}, err)
+
+	require.NoError(t, w.SetPolicy(Policy{AllowedDestinations: []string{y.String()}}))
+	err = w.CheckPolicy(txn, 0)
+	assert.Equal(t, PolicyError{Rule: PolicyAllowedDestinations, Address: x.String()}, err)
+
+	require.NoError(t, w.SetPolicy(Policy{AllowedDestinations: []string{x.String()}}))
+	require.NoError(t, w.CheckPolicy(txn, 0))
+
+	p, err := w.Policy()
+	require.NoError(t, err)
+	assert.Equal(t, []string{x.String()}, p.AllowedDestinations)
+
+	// the empty policy removes it
+	require.NoError(t, w.SetPolicy(Policy{}))
+	_, ok := w.Meta["policy"]
+	assert.False(t, ok)
+}
@@ -12,6 +12,7 @@ type ReadableEntry struct {
 	Address string `json:"address"`
 	Public  string `json:"public_key"`
 	Secret  string `json:"secret_key"`
+	Path    string `json:"path,omitempty"`
 }
 
 // CoinSupply records the coin supply info
@@ -24,11 +25,16 @@ type CoinSupply struct {
 
 // NewReadableEntry creates readable wallet entry
 func NewReadableEntry(w *Entry) ReadableEntry {
-	return ReadableEntry{
+	re := ReadableEntry{
 		Address: w.Address.String(),
 		Public:  w.Public.Hex(),
-		Secret:  w.Secret.Hex(),
+		Path:    w.Path,
+	}
+	// the secret key is removed from the entry of the encrypted wallet
+	if w.Secret != (cipher.SecKey{}) {
+		re.Secret = w.Secret.Hex()
 	}
+	return re
 }
 
 // LoadReadableEntry load readable wallet entry from given file
@@ -62,7 +68,12 @@ func (res ReadableEntries) ToWalletEntries() []Entry {
 	entries := make([]Entry, len(res))
 	for i, re := range res {
 		we := NewEntryFromReadable(&re)
-		if err := we.Verify(); err != nil {
+		verify := we.Verify
+		// the secret keys of the encrypted wallet are not in the entries
+		if re.Secret == "" {
+			verify = we.VerifyPublic
+		}
+		if err := verify(); err != nil {
 			logger.Panicf("Invalid wallet entry loaded. Address: %s", re.Address)
 		}
 		entries[i] = we
@@ -74,6 +85,8 @@ func (res ReadableEntries) ToWalletEntries() []Entry {
 type ReadableWallet struct {
 	Meta    map[string]string `json:"meta"`
 	Entries ReadableEntries   `json:"entries"`
+	// hex SHA256 of the wallet json without the checksum, set when it's saved
+	Checksum string `json:"checksum,omitempty"`
 }
 
 // ByTm for sort ReadableWallets
@@ -125,15 +138,30 @@ func (rw *ReadableWallet) ToWallet() (Wallet, error) {
 func (rw *ReadableWallet) Save(filename string) error {
 	// logger.Info("Saving readable wallet to %s with filename %s", filename,
 	// 	self.Meta["filename"])
+	if err := rw.setChecksum(); err != nil {
+		return err
+	}
+
+	// the previous file of the encrypted wallet may hold the plaintext secrets, it's not
+	// backed up and the backup of the earlier saves is removed
+	if rw.Meta["encrypted"] == "true" {
+		return file.SaveJSONNoBackup(filename, rw, 0600)
+	}
 	return file.SaveJSON(filename, rw, 0600)
 }
 
 // SaveSafe saves to filename, but won't overwrite existing
 func (rw *ReadableWallet) SaveSafe(filename string) error {
+	if err := rw.setChecksum(); err != nil {
+		return err
+	}
 	return file.SaveJSONSafe(filename, rw, 0600)
 }
 
-// Load loads from filename
+// Load loads from filename, returns ErrWalletChecksum if the wallet file is corrupted
 func (rw *ReadableWallet) Load(filename string) error {
-	return file.LoadJSON(filename, rw)
+	if err := file.LoadJSON(filename, rw); err != nil {
+		return err
+	}
+	return rw.verifyChecksum()
 }
@@ -0,0 +1,93 @@
+package wallet
+
+import (
+	"errors"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// DefaultScanGap is the default number of consecutive unused addresses after which the
+// scanning stops
+const DefaultScanGap = 20
+
+// AddressesUsedFunc checks whether each of the addresses has been used
+type AddressesUsedFunc func(addrs []cipher.Address) ([]bool, error)
+
+// ScanAddresses generates the addresses after the existing ones until gap consecutive
+// addresses are unused, and keeps the ones up to the last used address, e.g. when the
+// wallet is recovered from the seed. The change chain of the HD wallet is scanned as well.
+// Returns the addresses added.
+func (wlt *Wallet) ScanAddresses(gap int, used AddressesUsedFunc) ([]cipher.Address, error) {
+	if gap <= 0 {
+		return nil, errors.New("gap must be positive")
+	}
+
+	if wlt.IsEncrypted() {
+		return nil, ErrWalletEncrypted
+	}
+
+	gens := []func(w *Wallet, num int) ([]cipher.Address, error){
+		func(w *Wallet, num int) ([]cipher.Address, error) {
+			return w.GenerateAddresses(num), nil
+		},
+	}
+	if wlt.IsHD() {
+		gens = append(gens, (*Wallet).GenerateChangeAddresses)
+	}
+
+	var addrs []cipher.Address
+	for _, gen := range gens {
+		n, err := wlt.scanChain(gap, gen, used)
+		if err != nil {
+			return nil, err
+		}
+
+		if n == 0 {
+			continue
+		}
+
+		// the addresses are derived again, the same as the scanned ones
+		as, err := gen(wlt, n)
+		if err != nil {
+			return nil, err
+		}
+		addrs = append(addrs, as...)
+	}
+
+	return addrs, nil
+}
+
+// scanChain generates the addresses of the chain on a copy of the wallet in batches of gap,
+// returns the number of addresses up to the last used one
+func (wlt Wallet) scanChain(gap int, gen func(w *Wallet, num int) ([]cipher.Address, error), used AddressesUsedFunc) (int, error) {
+	c := Wallet{
+		Meta:    make(map[string]string, len(wlt.Meta)),
+		Entries: append([]Entry(nil), wlt.Entries...),
+	}
+	for k, v := range wlt.Meta {
+		c.Meta[k] = v
+	}
+
+	var scanned int
+	last := -1
+	for scanned-last-1 < gap {
+		addrs, err := gen(&c, gap)
+		if err != nil {
+			return 0, err
+		}
+
+		flags, err := used(addrs)
+		if err != nil {
+			return 0, err
+		}
+
+		for i, u := range flags {
+			if u {
+				last = scanned + i
+			}
+		}
+		scanned += len(addrs)
+	}
+
+	return last + 1, nil
+}
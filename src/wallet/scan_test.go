@@ -0,0 +1,71 @@
+package wallet
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func usedAddresses(addrs ...cipher.Address) AddressesUsedFunc {
+	set := make(map[cipher.Address]struct{}, len(addrs))
+	for _, a := range addrs {
+		set[a] = struct{}{}
+	}
+
+	return func(as []cipher.Address) ([]bool, error) {
+		used := make([]bool, len(as))
+		for i, a := range as {
+			_, used[i] = set[a]
+		}
+		return used, nil
+	}
+}
+
+func TestScanAddresses(t *testing.T) {
+	ref, err := NewWallet("ref.wlt", OptSeed("scan seed"))
+	require.NoError(t, err)
+	refAddrs := ref.GenerateAddresses(30)
+
+	w, err := NewWallet("a.wlt", OptSeed("scan seed"))
+	require.NoError(t, err)
+	w.GenerateAddresses(1)
+
+	_, err = w.ScanAddresses(0, usedAddresses())
+	assert.Error(t, err)
+
+	// nothing is used after the first address
+	addrs, err := w.ScanAddresses(5, usedAddresses(refAddrs[0]))
+	require.NoError(t, err)
+	assert.Empty(t, addrs)
+	assert.Equal(t, 1, w.NumEntries())
+
+	// the gap between the used addresses is less than 5
+	addrs, err = w.ScanAddresses(5, usedAddresses(refAddrs[2], refAddrs[9], refAddrs[20]))
+	require.NoError(t, err)
+	assert.Equal(t, refAddrs[1:10], addrs)
+	assert.Equal(t, refAddrs[:10], w.GetAddresses())
+
+	// the next address derived follows the scanned ones
+	assert.Equal(t, refAddrs[10], w.GenerateAddresses(1)[0])
+}
+
+func TestScanAddressesHD(t *testing.T) {
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	ref, err := NewWallet("ref.wlt", OptSeed(mnemonic), OptHD(0))
+	require.NoError(t, err)
+	external := ref.GenerateAddresses(10)
+	change, err := ref.GenerateChangeAddresses(10)
+	require.NoError(t, err)
+
+	w, err := NewWallet("a.wlt", OptSeed(mnemonic), OptHD(0))
+	require.NoError(t, err)
+	w.GenerateAddresses(1)
+
+	addrs, err := w.ScanAddresses(3, usedAddresses(external[2], change[1]))
+	require.NoError(t, err)
+	assert.Equal(t, []cipher.Address{external[1], external[2], change[0], change[1]}, addrs)
+	assert.Equal(t, 5, w.NumEntries())
+}
@@ -0,0 +1,233 @@
+package wallet
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// TxnSigner signs the inputs of the transactions spent from the wallet. The keys may be in
+// memory or in an external device or process, e.g. a hardware wallet bridge or a remote
+// HSM. The signatures are checked by the caller.
+type TxnSigner interface {
+	// SignHash signs the hash by the secret key of the address
+	SignHash(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error)
+}
+
+// KeySigner signs with the secret keys of the wallet in memory
+type KeySigner struct {
+	wlt *Wallet
+}
+
+// NewKeySigner creates the signer of the wallet keys, the wallet must not be encrypted
+func NewKeySigner(wlt *Wallet) KeySigner {
+	return KeySigner{wlt: wlt}
+}
+
+// SignHash signs the hash by the secret key of the address
+func (s KeySigner) SignHash(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error) {
+	e, ok := s.wlt.GetEntry(addr)
+	if !ok {
+		return cipher.Sig{}, fmt.Errorf("address %s is not in the wallet", addr)
+	}
+
+	if e.Secret == (cipher.SecKey{}) {
+		return cipher.Sig{}, ErrWalletEncrypted
+	}
+	return cipher.SignHash(hash, e.Secret), nil
+}
+
+// DefaultSignerTimeout is the time the external signer has to sign a hash, the device may
+// wait for the user to confirm
+const DefaultSignerTimeout = 2 * time.Minute
+
+// SocketSigner gets the signatures from an external signer listening on a local socket.
+//
+// The protocol is a json object per line, the request is
+//
+//	{"address": "<base58 address>", "hash": "<hex hash>"}
+//
+// and the response is
+//
+//	{"sig": "<hex signature>"} or {"error": "<reason>"}
+//
+// The requests of a connection are answered in order, see ServeSigner.
+type SocketSigner struct {
+	// "unix" or "tcp"
+	Network string
+	Address string
+	Timeout time.Duration
+}
+
+type signRequest struct {
+	Address string `json:"address"`
+	Hash    string `json:"hash"`
+}
+
+type signResponse struct {
+	Sig   string `json:"sig,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// ParseSocketSigner parses the signer of the form unix:<path> or tcp:<host:port>
+func ParseSocketSigner(s string) (*SocketSigner, error) {
+	i := strings.Index(s, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid signer %q, must be unix:<path> or tcp:<host:port>", s)
+	}
+
+	network, addr := s[:i], s[i+1:]
+	switch network {
+	case "unix", "tcp":
+	default:
+		return nil, fmt.Errorf("invalid signer network %q, must be unix or tcp", network)
+	}
+
+	if addr == "" {
+		return nil, fmt.Errorf("invalid signer %q, the address is empty", s)
+	}
+
+	return &SocketSigner{
+		Network: network,
+		Address: addr,
+		Timeout: DefaultSignerTimeout,
+	}, nil
+}
+
+// String returns the signer in the form parsed by ParseSocketSigner
+func (s SocketSigner) String() string {
+	return s.Network + ":" + s.Address
+}
+
+// SignHash asks the external signer to sign the hash by the secret key of the address
+func (s SocketSigner) SignHash(addr cipher.Address, hash cipher.SHA256) (cipher.Sig, error) {
+	timeout := s.Timeout
+	if timeout == 0 {
+		timeout = DefaultSignerTimeout
+	}
+
+	conn, err := net.DialTimeout(s.Network, s.Address, timeout)
+	if err != nil {
+		return cipher.Sig{}, fmt.Errorf("connect signer %s failed: %v", s, err)
+	}
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Now().Add(timeout)); err != nil {
+		return cipher.Sig{}, err
+	}
+
+	if err := json.NewEncoder(conn).Encode(signRequest{
+		Address: addr.String(),
+		Hash:    hash.Hex(),
+	}); err != nil {
+		return cipher.Sig{}, fmt.Errorf("send request to signer %s failed: %v", s, err)
+	}
+
+	var resp signResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return cipher.Sig{}, fmt.Errorf("read response of signer %s failed: %v", s, err)
+	}
+
+	if resp.Error != "" {
+		return cipher.Sig{}, fmt.Errorf("signer %s: %s", s, resp.Error)
+	}
+
+	sig, err := cipher.SigFromHex(resp.Sig)
+	if err != nil {
+		return cipher.Sig{}, fmt.Errorf("invalid signature from signer %s: %v", s, err)
+	}
+	return sig, nil
+}
+
+// ServeSigner answers the requests of SocketSigner by the signer till l is closed, it's
+// the reference implementation of the external side of the protocol
+func ServeSigner(l net.Listener, signer TxnSigner) error {
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+
+		go serveSignerConn(conn, signer)
+	}
+}
+
+func serveSignerConn(conn net.Conn, signer TxnSigner) {
+	defer conn.Close()
+
+	enc := json.NewEncoder(conn)
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		sig, err := signRequestLine(scanner.Bytes(), signer)
+		resp := signResponse{}
+		if err != nil {
+			resp.Error = err.Error()
+		} else {
+			resp.Sig = sig.Hex()
+		}
+
+		if err := enc.Encode(resp); err != nil {
+			logger.Error("write signer response failed: %v", err)
+			return
+		}
+	}
+}
+
+func signRequestLine(line []byte, signer TxnSigner) (cipher.Sig, error) {
+	var req signRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return cipher.Sig{}, errors.New("invalid request")
+	}
+
+	addr, err := cipher.DecodeBase58Address(req.Address)
+	if err != nil {
+		return cipher.Sig{}, fmt.Errorf("invalid address: %v", err)
+	}
+
+	hash, err := cipher.SHA256FromHex(req.Hash)
+	if err != nil {
+		return cipher.Sig{}, fmt.Errorf("invalid hash: %v", err)
+	}
+
+	return signer.SignHash(addr, hash)
+}
+
+// Signer returns the signer of the spends from the wallet, the external signer if it's
+// set, otherwise the keys of the wallet
+func (wlt *Wallet) Signer() (TxnSigner, error) {
+	if s := wlt.Meta["signer"]; s != "" {
+		return ParseSocketSigner(s)
+	}
+
+	if wlt.IsEncrypted() {
+		return nil, ErrWalletEncrypted
+	}
+	return NewKeySigner(wlt), nil
+}
+
+// HasExternalSigner returns true if the spends are signed by an external signer, the
+// wallet doesn't need the secret keys then
+func (wlt Wallet) HasExternalSigner() bool {
+	return wlt.Meta["signer"] != ""
+}
+
+// SetSigner sets the external signer of the form unix:<path> or tcp:<host:port>, the empty
+// signer removes it and the keys of the wallet are used
+func (wlt *Wallet) SetSigner(s string) error {
+	if s == "" {
+		delete(wlt.Meta, "signer")
+		return nil
+	}
+
+	if _, err := ParseSocketSigner(s); err != nil {
+		return err
+	}
+	wlt.Meta["signer"] = s
+	return nil
+}
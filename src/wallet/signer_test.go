@@ -0,0 +1,88 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+func TestSocketSigner(t *testing.T) {
+	dir, err := ioutil.TempDir("", "signer")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// the device has the keys, the wallet only has the addresses
+	device, err := NewWallet("device.wlt", OptSeed("signer seed"))
+	require.NoError(t, err)
+	addrs := device.GenerateAddresses(2)
+
+	sock := filepath.Join(dir, "signer.sock")
+	l, err := net.Listen("unix", sock)
+	require.NoError(t, err)
+	defer l.Close()
+	go ServeSigner(l, NewKeySigner(device))
+
+	w, err := NewWallet("a.wlt", OptSeed("other seed"))
+	require.NoError(t, err)
+	require.NoError(t, w.SetSigner("unix:"+sock))
+	assert.True(t, w.HasExternalSigner())
+
+	signer, err := w.Signer()
+	require.NoError(t, err)
+
+	hash := cipher.SumSHA256([]byte("txn"))
+	for _, a := range addrs {
+		sig, err := signer.SignHash(a, hash)
+		require.NoError(t, err)
+		assert.NoError(t, cipher.ChkSig(a, hash, sig))
+	}
+
+	// the device doesn't have the key
+	p, _ := cipher.GenerateKeyPair()
+	_, err = signer.SignHash(cipher.AddressFromPubKey(p), hash)
+	assert.Error(t, err)
+
+	// the keys of the wallet are used without the external signer
+	require.NoError(t, w.SetSigner(""))
+	assert.False(t, w.HasExternalSigner())
+	signer, err = w.Signer()
+	require.NoError(t, err)
+	_, ok := signer.(KeySigner)
+	assert.True(t, ok)
+
+	_, err = signer.SignHash(addrs[0], hash)
+	assert.Error(t, err)
+
+	_, err = (&SocketSigner{Network: "unix", Address: filepath.Join(dir, "none.sock")}).SignHash(addrs[0], hash)
+	assert.Error(t, err)
+}
+
+func TestParseSocketSigner(t *testing.T) {
+	s, err := ParseSocketSigner("tcp:127.0.0.1:7777")
+	require.NoError(t, err)
+	assert.Equal(t, "tcp", s.Network)
+	assert.Equal(t, "127.0.0.1:7777", s.Address)
+	assert.Equal(t, "tcp:127.0.0.1:7777", s.String())
+
+	s, err = ParseSocketSigner("unix:/run/signer.sock")
+	require.NoError(t, err)
+	assert.Equal(t, "unix", s.Network)
+	assert.Equal(t, "/run/signer.sock", s.Address)
+
+	for _, v := range []string{"", "tcp", "tcp:", "udp:127.0.0.1:7777", "/run/signer.sock"} {
+		_, err := ParseSocketSigner(v)
+		assert.Error(t, err, v)
+	}
+
+	w, err := NewWallet("a.wlt")
+	require.NoError(t, err)
+	assert.Error(t, w.SetSigner("udp:127.0.0.1:7777"))
+	assert.False(t, w.HasExternalSigner())
+}
@@ -0,0 +1,106 @@
+package wallet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+)
+
+// legacyVersion is the version of the wallet files without the checksum and the file meta,
+// they're upgraded to version when loaded
+const legacyVersion = "0.1"
+
+// DerivationSHA256Chain is the derivation of the deterministic wallet, the keys are derived
+// by hashing the seed repeatedly. The derivation of the HD wallet is its BIP44 account path.
+const DerivationSHA256Chain = "sha256_chain"
+
+// ErrWalletChecksum is returned if the wallet file doesn't match its checksum
+var ErrWalletChecksum = errors.New("wallet file checksum mismatch")
+
+// setFileMeta sets the version, the created time and the derivation of the wallet file,
+// the created time is kept if it's set already
+func (wlt *Wallet) setFileMeta(created time.Time) {
+	wlt.Meta["version"] = version
+	if wlt.Meta["createdAt"] == "" {
+		wlt.Meta["createdAt"] = created.UTC().Format(time.RFC3339)
+	}
+	wlt.Meta["derivation"] = wlt.derivation()
+}
+
+// derivation returns the key derivation of the wallet, e.g. sha256_chain or m/44'/8000'/0'
+func (wlt Wallet) derivation() string {
+	if !wlt.IsHD() {
+		return DerivationSHA256Chain
+	}
+
+	coinType, err := wlt.metaUint32("coinType")
+	if err != nil {
+		return HDWalletType
+	}
+
+	account, err := wlt.metaUint32("account")
+	if err != nil {
+		return HDWalletType
+	}
+	return FormatPath(BIP44AccountPath(coinType, account))
+}
+
+// createdTime returns the time the legacy wallet was created, it's the tm meta
+func (wlt Wallet) createdTime() time.Time {
+	tm, err := strconv.ParseInt(wlt.Meta["tm"], 10, 64)
+	if err != nil {
+		return time.Now()
+	}
+	return time.Unix(tm, 0)
+}
+
+// checksum returns the hex SHA256 of the json of the wallet, the checksum excluded
+func (rw ReadableWallet) checksum() (string, error) {
+	rw.Checksum = ""
+	b, err := json.Marshal(rw)
+	if err != nil {
+		return "", err
+	}
+	return cipher.SumSHA256(b).Hex(), nil
+}
+
+// setChecksum sets the checksum of the wallet before it's saved
+func (rw *ReadableWallet) setChecksum() error {
+	sum, err := rw.checksum()
+	if err != nil {
+		return err
+	}
+	rw.Checksum = sum
+	return nil
+}
+
+// verifyChecksum checks the checksum of the loaded wallet, the legacy wallet files have
+// no checksum
+func (rw ReadableWallet) verifyChecksum() error {
+	if rw.Meta["version"] != version {
+		return nil
+	}
+
+	sum, err := rw.checksum()
+	if err != nil {
+		return err
+	}
+
+	if rw.Checksum != sum {
+		return ErrWalletChecksum
+	}
+	return nil
+}
+
+// upgradeWallet upgrades the legacy wallet file to version, the keys are not changed
+func upgradeWallet(wlt *Wallet, dir string) error {
+	wlt.setFileMeta(wlt.createdTime())
+	if err := wlt.Save(dir); err != nil {
+		return fmt.Errorf("save upgraded wallet failed: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package wallet
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/skycoin/skycoin/src/util/file"
+)
+
+func TestWalletFileChecksum(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := NewWallet("a.wlt", OptSeed("file seed"))
+	require.NoError(t, err)
+	w.GenerateAddresses(2)
+	assert.Equal(t, version, w.GetVersion())
+	assert.Equal(t, DerivationSHA256Chain, w.Meta["derivation"])
+	assert.NotEmpty(t, w.Meta["createdAt"])
+
+	mnemonic := "abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about"
+	hw, err := NewWallet("b.wlt", OptSeed(mnemonic), OptHD(2))
+	require.NoError(t, err)
+	assert.Equal(t, "m/44'/8000'/2'", hw.Meta["derivation"])
+
+	require.NoError(t, w.Save(dir))
+	fn := filepath.Join(dir, "a.wlt")
+	loaded, err := Load(fn)
+	require.NoError(t, err)
+	assert.Equal(t, w.GetAddresses(), loaded.GetAddresses())
+
+	// the torn or edited file is rejected
+	b, err := ioutil.ReadFile(fn)
+	require.NoError(t, err)
+	edited := strings.Replace(string(b), `"label": ""`, `"label": "edited"`, 1)
+	require.NotEqual(t, string(b), edited)
+	require.NoError(t, ioutil.WriteFile(fn, []byte(edited), 0600))
+	_, err = LoadReadableWallet(fn)
+	assert.Equal(t, ErrWalletChecksum, err)
+	_, err = Load(fn)
+	assert.Error(t, err)
+}
+
+func TestUpgradeLegacyWalletFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "wallets")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	w, err := NewWallet("a.wlt", OptSeed("legacy seed"))
+	require.NoError(t, err)
+	w.GenerateAddresses(3)
+	w.Meta["version"] = legacyVersion
+	w.Meta["tm"] = "1500000000"
+	delete(w.Meta, "createdAt")
+	delete(w.Meta, "derivation")
+	lastSeed := w.Meta["lastSeed"]
+
+	// the legacy file has no checksum
+	rw := NewReadableWallet(*w)
+	require.NoError(t, file.SaveJSON(filepath.Join(dir, "a.wlt"), rw, 0600))
+
+	upgraded, err := LoadWallet(dir, "a.wlt")
+	require.NoError(t, err)
+	assert.Equal(t, version, upgraded.GetVersion())
+	assert.Equal(t, "2017-07-14T02:40:00Z", upgraded.Meta["createdAt"])
+	assert.Equal(t, DerivationSHA256Chain, upgraded.Meta["derivation"])
+	assert.Equal(t, lastSeed, upgraded.Meta["lastSeed"])
+	assert.Equal(t, w.GetAddresses(), upgraded.GetAddresses())
+
+	_, err = os.Stat(filepath.Join(dir, "backup", "a.wlt."+legacyVersion))
+	assert.NoError(t, err)
+
+	// the upgraded file is saved with the checksum
+	rw, err = LoadReadableWallet(filepath.Join(dir, "a.wlt"))
+	require.NoError(t, err)
+	assert.NotEmpty(t, rw.Checksum)
+	assert.Equal(t, version, rw.Meta["version"])
+}
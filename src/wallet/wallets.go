@@ -0,0 +1,314 @@
+package wallet
+
+import (
+	//"fmt"
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/skycoin/skycoin/src/cipher"
+	"github.com/skycoin/skycoin/src/util/file"
+)
+
+// Wallets wallets map
+type Wallets map[string]*Wallet
+
+// LoadWallets Loads all wallets contained in wallet dir.  If any regular file in wallet
+// dir fails to load, loading is aborted and error returned.  Only files with
+// extension WalletExt are considered. If encounter old wallet file, then backup
+// the wallet file into dir/backup/
+func LoadWallets(dir string) (Wallets, error) {
+	// TODO -- don't load duplicate wallets.
+	// TODO -- save a last_modified value in wallets to decide which to load
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	// create backup dir if not exist
+	bkpath := dir + "/backup/"
+	if _, err := os.Stat(bkpath); os.IsNotExist(err) {
+		// create the backup dir
+		logger.Critical("create wallet backup dir, %v", bkpath)
+		if err := os.Mkdir(bkpath, 0777); err != nil {
+			return nil, err
+		}
+	}
+
+	//have := make(map[WalletID]Wallet, len(entries))
+	wallets := Wallets{}
+	for i, e := range entries {
+		if e.Mode().IsRegular() {
+			name := e.Name()
+			if !strings.HasSuffix(name, WalletExt) {
+				continue
+			}
+
+			w, err := loadWalletFile(dir, name, time.Now().Unix()+int64(i))
+			if err != nil {
+				return nil, err
+			}
+			wallets[name] = w
+		}
+	}
+	return wallets, nil
+}
+
+// LoadWallet loads the wallet file of the name in dir, the old wallet file is backed up
+// into dir/backup/ and updated
+func LoadWallet(dir, name string) (*Wallet, error) {
+	if !strings.HasSuffix(name, WalletExt) {
+		return nil, fmt.Errorf("wallet file name must have %s extension", WalletExt)
+	}
+
+	// the wallet must be in dir
+	if filepath.Base(name) != name {
+		return nil, errors.New("wallet file name must not contain path")
+	}
+
+	bkpath := dir + "/backup/"
+	if _, err := os.Stat(bkpath); os.IsNotExist(err) {
+		if err := os.Mkdir(bkpath, 0777); err != nil {
+			return nil, err
+		}
+	}
+
+	return loadWalletFile(dir, name, time.Now().Unix())
+}
+
+// ListWalletFiles returns the names of the wallet files in dir
+func ListWalletFiles(dir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if e.Mode().IsRegular() && strings.HasSuffix(e.Name(), WalletExt) {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+func loadWalletFile(dir, name string, tm int64) (*Wallet, error) {
+	fullpath := filepath.Join(dir, name)
+	rw, err := LoadReadableWallet(fullpath)
+	if err != nil {
+		return nil, err
+	}
+	w, err := rw.ToWallet()
+	if err != nil {
+		return nil, err
+	}
+	logger.Info("Loaded wallet from %s", fullpath)
+	w.SetFilename(name)
+	// check the wallet version
+	switch w.GetVersion() {
+	case version:
+	case legacyVersion:
+		logger.Info("upgrade wallet %v to version %v", fullpath, version)
+		bkFile := filepath.Join(dir, "backup", fmt.Sprintf("%s.%s", w.GetFilename(), legacyVersion))
+		if err := backupWltFile(fullpath, bkFile); err != nil {
+			return nil, err
+		}
+
+		if err := upgradeWallet(&w, dir); err != nil {
+			return nil, err
+		}
+	default:
+		logger.Info("update wallet %v", fullpath)
+		bkFile := filepath.Join(dir, "backup", w.GetFilename())
+		if err := backupWltFile(fullpath, bkFile); err != nil {
+			return nil, err
+		}
+
+		// update wallet to new version.
+		mustUpdateWallet(&w, dir, tm)
+	}
+	return &w, nil
+}
+
+func backupWltFile(src, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%v file already exist", dst)
+	}
+
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	n, err := file.CopyFile(dst, bytes.NewBuffer(b))
+	if err != nil {
+		return err
+	}
+
+	// check if the content bytes are equal.
+	if n != int64(len(b)) {
+		return errors.New("copy file failed")
+	}
+	return nil
+}
+
+func mustUpdateWallet(wlt *Wallet, dir string, tm int64) {
+	// update version meta data.
+	wlt.Meta["version"] = version
+
+	// update lastSeed meta data.
+	lsd, seckeys := cipher.GenerateDeterministicKeyPairsSeed([]byte(wlt.Meta["seed"]), 1)
+	if seckeys[0] != wlt.Entries[0].Secret {
+		logger.Panic("update wallet failed, seckey not match")
+	}
+
+	wlt.Meta["lastSeed"] = hex.EncodeToString(lsd)
+
+	// update tm meta data.
+	wlt.Meta["tm"] = fmt.Sprintf("%v", tm)
+	wlt.setFileMeta(time.Unix(tm, 0))
+	if err := wlt.Save(dir); err != nil {
+		logger.Panic(err)
+	}
+}
+
+// Add add walet to current wallet
+func (wlts *Wallets) Add(w Wallet) error {
+	if _, dup := (*wlts)[w.GetFilename()]; dup {
+		return errors.New("Wallets.Add, Wallet name would conflict with existing wallet, renaming")
+	}
+
+	(*wlts)[w.GetFilename()] = &w
+	return nil
+}
+
+// Remove wallet of specific id
+func (wlts *Wallets) Remove(id string) {
+	delete(*wlts, id)
+}
+
+// Get returns wallet by wallet id
+func (wlts *Wallets) Get(wltID string) (Wallet, bool) {
+	if w, ok := (*wlts)[wltID]; ok {
+		return *w, true
+	}
+	return Wallet{}, false
+}
+
+// NewAddresses creates num addresses in given wallet
+func (wlts *Wallets) NewAddresses(wltID string, num int) ([]cipher.Address, error) {
+	if w, ok := (*wlts)[wltID]; ok {
+		if w.IsEncrypted() {
+			return nil, ErrWalletEncrypted
+		}
+		return w.GenerateAddresses(num), nil
+	}
+	return nil, fmt.Errorf("wallet: %v does not exist", wltID)
+}
+
+// NewChangeAddresses creates num change addresses in the given HD wallet
+func (wlts *Wallets) NewChangeAddresses(wltID string, num int) ([]cipher.Address, error) {
+	if w, ok := (*wlts)[wltID]; ok {
+		return w.GenerateChangeAddresses(num)
+	}
+	return nil, fmt.Errorf("wallet: %v does not exist", wltID)
+}
+
+// AddMultisig adds the multisig address of required signatures of the keys to the wallet
+func (wlts *Wallets) AddMultisig(wltID string, required int, pubKeys []cipher.PubKey) (Multisig, error) {
+	if w, ok := (*wlts)[wltID]; ok {
+		return w.AddMultisig(required, pubKeys)
+	}
+	return Multisig{}, fmt.Errorf("wallet: %v does not exist", wltID)
+}
+
+// SetAddressAnnotation annotates the address of the wallet
+func (wlts *Wallets) SetAddressAnnotation(wltID string, addr cipher.Address, a Annotation) error {
+	if w, ok := (*wlts)[wltID]; ok {
+		return w.SetAddressAnnotation(addr, a)
+	}
+	return fmt.Errorf("wallet: %v does not exist", wltID)
+}
+
+// SetTxnAnnotation annotates the transaction of the wallet
+func (wlts *Wallets) SetTxnAnnotation(wltID string, txid cipher.SHA256, a Annotation) error {
+	if w, ok := (*wlts)[wltID]; ok {
+		return w.SetTxnAnnotation(txid, a)
+	}
+	return fmt.Errorf("wallet: %v does not exist", wltID)
+}
+
+// MigrateToHD converts the wallet to HD and saves it, the wallet file is backed up to
+// dir/backup/ first
+func (wlts *Wallets) MigrateToHD(wltID, dir string) error {
+	w, ok := (*wlts)[wltID]
+	if !ok {
+		return fmt.Errorf("wallet: %v does not exist", wltID)
+	}
+
+	m := *w
+	if err := m.MigrateToHD(); err != nil {
+		return err
+	}
+
+	bkpath := filepath.Join(dir, "backup")
+	if err := os.MkdirAll(bkpath, 0700); err != nil {
+		return err
+	}
+
+	bkFile := filepath.Join(bkpath, fmt.Sprintf("%s.%d", wltID, time.Now().Unix()))
+	if err := backupWltFile(filepath.Join(dir, wltID), bkFile); err != nil {
+		return err
+	}
+
+	*w = m
+	logger.Info("Migrated wallet %s to HD, the old file is backed up to %s", wltID, bkFile)
+	return w.Save(dir)
+}
+
+// Save check for name conflicts!
+// resolve conflicts for saving wallets who have different names
+func (wlts Wallets) Save(dir string) map[string]error {
+	errs := make(map[string]error)
+	for id, w := range wlts {
+		if err := w.Save(dir); err != nil {
+			errs[id] = err
+		}
+	}
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+// GetAddressSet get all addresses.
+func (wlts Wallets) GetAddressSet() map[cipher.Address]byte {
+	set := make(map[cipher.Address]byte)
+	for _, w := range wlts {
+		for _, a := range w.GetAddresses() {
+			set[a] = byte(1)
+		}
+	}
+	return set
+}
+
+func (wlts Wallets) toReadable(f ReadableWalletCtor) []*ReadableWallet {
+	var rw []*ReadableWallet
+	for _, w := range wlts {
+		rw = append(rw, f(*w))
+	}
+	sort.Sort(ByTm(rw))
+	return rw
+}
+
+// ToReadable converts Wallets to *ReadableWallet array
+func (wlts Wallets) ToReadable() []*ReadableWallet {
+	return wlts.toReadable(NewReadableWallet)
+}